@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+)
+
+// runFixHeader is the "gones fixheader" subcommand: it checks a ROM's
+// iNES header against the file and the game database and writes a copy
+// with the header repaired, leaving the original alone.
+func runFixHeader(args []string) error {
+	fs := flag.NewFlagSet("fixheader", flag.ExitOnError)
+	out := fs.String("o", "", "Write the repaired ROM here (default <rom>-fixed.nes next to the ROM)")
+	dbFile := fs.String("db", "", "nes20db or NesCartDB XML file to look the ROM up in, on top of the built-in database")
+	dryRun := fs.Bool("n", false, "Only list the fixes; don't write anything")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s fixheader [-db nes20db.xml] [-o out.nes] [-n] <rom_file>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	romFile := fs.Arg(0)
+
+	if *dbFile != "" {
+		f, err := os.Open(*dbFile)
+		if err != nil {
+			return err
+		}
+		_, err = cartridge.LoadGameDB(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", *dbFile, err)
+		}
+	}
+
+	data, err := os.ReadFile(romFile)
+	if err != nil {
+		return err
+	}
+	entries, err := cartridge.FindROMs(data)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 1 {
+		return fmt.Errorf("%s holds %d ROMs; extract the one to fix", romFile, len(entries))
+	}
+	fixed, fixes, err := cartridge.RepairHeader(entries[0].Data)
+	if err != nil {
+		return err
+	}
+	if len(fixes) == 0 {
+		fmt.Println("Header looks fine; nothing to fix.")
+		return nil
+	}
+	for _, f := range fixes {
+		fmt.Println("  " + f)
+	}
+	if *dryRun {
+		return nil
+	}
+
+	path := *out
+	if path == "" {
+		name := romFile
+		if entries[0].Name != "" {
+			name = filepath.Join(filepath.Dir(romFile), filepath.Base(entries[0].Name))
+		}
+		path = strings.TrimSuffix(name, filepath.Ext(name)) + "-fixed.nes"
+	}
+	if err := os.WriteFile(path, fixed, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Repaired ROM written to %s\n", path)
+	return nil
+}
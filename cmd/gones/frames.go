@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/yoshiomiyamaegones/pkg/core"
+	"github.com/yoshiomiyamaegones/pkg/input"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/osd"
+	"github.com/yoshiomiyamaegones/pkg/ppu"
+)
+
+// frameOutput is what headless mode does with each finished frame:
+// write every every-th one to dir as PNG, and/or print its CRC-32 to
+// hashes, so CI can spot rendering changes without SDL.
+type frameOutput struct {
+	dir    string // "" = no PNGs
+	every  int
+	hashes io.Writer // nil = no hashes
+
+	// input, if set, has its controllers drawn over every frame before
+	// it is hashed or saved (-input-display).
+	input *input.Ports
+	argb  []uint32
+	pads  []core.ButtonState
+}
+
+func (o *frameOutput) enabled() bool { return o.dir != "" || o.hashes != nil }
+
+// capture returns the finished frame as RGBA bytes, reusing buf, with the
+// input display burned in when o.input is set. The NES's own framebuffer
+// is left as the PPU drew it.
+func (o *frameOutput) capture(n *nes.NES, buf []uint8) []uint8 {
+	if o.input == nil {
+		return n.GetFramebufferInto(buf)
+	}
+	o.argb = append(o.argb[:0], n.GetFramebufferRaw()...)
+	o.pads = o.input.AppendButtons(o.pads[:0])
+	osd.DrawPads(o.argb, ppu.ScreenWidth, ppu.ScreenHeight, o.pads)
+	return ppu.ARGBToRGBA(buf, o.argb)
+}
+
+// frame handles frame number n given as RGBA bytes (PPU.GetFramebuffer).
+func (o *frameOutput) frame(n uint64, rgba []uint8) error {
+	if o.hashes != nil {
+		fmt.Fprintf(o.hashes, "frame %d %08X\n", n, crc32.ChecksumIEEE(rgba))
+	}
+	if o.dir == "" || n%uint64(o.every) != 0 {
+		return nil
+	}
+	img := &image.RGBA{Pix: rgba, Stride: 256 * 4, Rect: image.Rect(0, 0, 256, 240)}
+	f, err := os.Create(filepath.Join(o.dir, fmt.Sprintf("frame_%06d.png", n)))
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
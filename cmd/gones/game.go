@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+
+	"github.com/yoshiomiyamaegones/pkg/apu"
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/config"
+	"github.com/yoshiomiyamaegones/pkg/gui"
+	"github.com/yoshiomiyamaegones/pkg/input"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/ppu"
+)
+
+// applyGame returns base with cart's game file (config.GamePath) applied,
+// set being the flags given on the command line. A [cartridge] section
+// goes into the game database and cart is rebuilt so it takes effect;
+// otherwise cart comes back as it was.
+func applyGame(base config.Config, set map[string]string, cart *cartridge.Cartridge) (config.Config, *cartridge.Cartridge, error) {
+	path := config.GamePath(base.Paths.Games, cart.Hashes().ROMSHA1)
+	cfg, game, err := base.WithGame(path, set)
+	if err != nil || cfg == base && game == config.NoGame {
+		return cfg, cart, err
+	}
+	logger.LogInfo("Game settings: %s", path)
+	if game == config.NoGame || cart.Header.IsNES20() {
+		return cfg, cart, nil
+	}
+	crc := cartridge.ROMCRC32(cart.PRGROM, cart.CHRROM)
+	info, _ := cartridge.LookupGame(crc)
+	info.Mapper = cart.MapperNumber()
+	if game.Submapper >= 0 {
+		info.Submapper = uint8(game.Submapper)
+	}
+	if game.CHRRAM > 0 {
+		info.CHRRAMSize = game.CHRRAM * 1024
+	}
+	cartridge.RegisterGame(crc, info)
+	cart, err = cart.Reload()
+	return cfg, cart, err
+}
+
+// loadPalette reads a -palette file; "" is the built-in palette (nil).
+func loadPalette(path string) (*[64][3]uint8, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ppu.ParsePalette(data)
+}
+
+// ntscControls converts the -ntsc-* settings to the TV controls they
+// describe.
+func ntscControls(v config.Video) ppu.NTSC {
+	return ppu.NTSC{
+		Hue:        float64(v.NTSCHue),
+		Saturation: float64(v.NTSCSaturation) / 100,
+		Brightness: float64(v.NTSCBrightness) / 100,
+		Contrast:   float64(v.NTSCContrast) / 100,
+		Gamma:      float64(v.NTSCGamma) / 100,
+	}
+}
+
+// saveVolume is gui.Options.SaveVolume: it writes the volume and mute
+// state into the settings file at path, over the file as it is on disk so
+// the flags of this run aren't saved with them. A volume of 0 is saved as
+// muted, the file keeping its volume, since audio.volume starts at 1.
+func saveVolume(path string) func(volume int, muted bool) error {
+	if path == "" {
+		return nil
+	}
+	return func(volume int, muted bool) error {
+		cfg, err := config.Load(path)
+		if err != nil {
+			return err
+		}
+		if volume < 1 {
+			muted = true
+		} else {
+			cfg.Audio.Volume = volume
+		}
+		cfg.Audio.Muted = muted
+		return cfg.Save(path)
+	}
+}
+
+// vsPPU reads -vs-ppu; "" leaves the PPU the header names (nil).
+func vsPPU(name string) (*ppu.Model, error) {
+	if name == "" {
+		return nil, nil
+	}
+	m, err := ppu.ParseModel(name)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// guiGame is gui.Options.Game: the settings of a ROM opened in the window,
+// from base and its game file as at startup.
+func guiGame(base config.Config, set map[string]string) func(*cartridge.Cartridge) (*cartridge.Cartridge, gui.GameSettings, error) {
+	return func(cart *cartridge.Cartridge) (*cartridge.Cartridge, gui.GameSettings, error) {
+		cfg, cart, err := applyGame(base, set, cart)
+		if err != nil {
+			return nil, gui.GameSettings{}, err
+		}
+		palette, err := loadPalette(cfg.Video.Palette)
+		if err != nil {
+			return nil, gui.GameSettings{}, err
+		}
+		expansion, err := input.NewExpansion(cfg.Emulation.Expansion)
+		if err != nil {
+			return nil, gui.GameSettings{}, err
+		}
+		model, err := vsPPU(cfg.Emulation.VSPPU)
+		if err != nil {
+			return nil, gui.GameSettings{}, err
+		}
+		region, err := apu.ParseRegion(cfg.Emulation.Region)
+		if err != nil {
+			return nil, gui.GameSettings{}, err
+		}
+		return cart, gui.GameSettings{
+			Overscan: gui.Overscan{
+				Top: cfg.Video.OverscanTop, Bottom: cfg.Video.OverscanBottom,
+				Left: cfg.Video.OverscanLeft, Right: cfg.Video.OverscanRight,
+			},
+			Palette:       palette,
+			NoSpriteLimit: cfg.Video.NoSpriteLimit,
+			FourScore:     cfg.Emulation.FourScore,
+			Expansion:     expansion,
+			VSDIPs:        uint8(cfg.Emulation.VSDIPs),
+			VSPPU:         model,
+			Region:        region,
+		}, nil
+	}
+}
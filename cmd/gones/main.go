@@ -4,7 +4,6 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,47 +11,89 @@ import (
 	"runtime/pprof"
 	"time"
 
+	"github.com/yoshiomiyamaegones/pkg/apu"
 	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/config"
 	"github.com/yoshiomiyamaegones/pkg/gui"
+	"github.com/yoshiomiyamaegones/pkg/input"
 	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/memory"
 	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/trace"
+	"github.com/yoshiomiyamaegones/pkg/vfilter"
 )
 
 func main() {
-	// Define command line flags
-	var (
-		logLevel   = flag.String("log-level", "info", "Log level (off, error, warn, info, debug, trace)")
-		logFile    = flag.String("log-file", "", "Log file path (empty for stdout)")
-		cpuLog     = flag.Bool("cpu-log", false, "Enable CPU instruction logging")
-		ppuLog     = flag.Bool("ppu-log", false, "Enable PPU logging")
-		apuLog     = flag.Bool("apu-log", false, "Enable APU logging")
-		mapperLog  = flag.Bool("mapper-log", false, "Enable mapper logging")
-		headless   = flag.Bool("headless", false, "Run in headless mode for testing")
-		testFrames = flag.Int("test-frames", 600, "Number of frames to run in headless mode")
-		cpuProfile = flag.String("cpuprofile", "", "Write CPU profile to file (use with -headless for clean run)")
-		memProfile = flag.String("memprofile", "", "Write heap profile to file at exit")
-	)
+	if len(os.Args) > 1 && os.Args[1] == "fixheader" {
+		if err := runFixHeader(os.Args[2:]); err != nil {
+			log.Fatalf("fixheader: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "testsuite" {
+		if err := runTestSuite(os.Args[2:]); err != nil {
+			log.Fatalf("testsuite: %v", err)
+		}
+		return
+	}
+
+	// Settings come from the config file, then the flags: Bind registers
+	// every flag with the file's value as its default, so only flags given
+	// on the command line override it.
+	cfgPath := config.PathFromArgs(os.Args[1:])
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		log.Fatalf("Config: %v", err)
+	}
+	cfg.Bind(flag.CommandLine)
+	flag.StringVar(&cfgPath, "config", cfgPath, "Config file to load settings from")
+	saveConfig := flag.Bool("save-config", false, "Write the effective settings (file + flags) back to the config file")
+	patchFile := flag.String("patch", "", "IPS or BPS patch to apply to the ROM (default: <rom>.ips or <rom>.bps beside it)")
 
 	flag.Usage = func() {
-		fmt.Printf("Usage: %s [options] <rom_file>\n\n", os.Args[0])
+		fmt.Printf("Usage: %s [options] <rom_file>\n", os.Args[0])
+		fmt.Printf("       %s fixheader [-db nes20db.xml] [-o out.nes] [-n] <rom_file>\n", os.Args[0])
+		fmt.Printf("       %s testsuite [-j N] [-frames N] [-timeout D] [-json out.json] [-baseline old.json] <dir>\n\n", os.Args[0])
+		fmt.Println("rom_file may be a .nes image, a .zip/.gz archive, or - to read from stdin.")
+		fmt.Println()
+		fmt.Println("Defaults below are read from the config file (-config); flags override it.")
+		fmt.Println()
 		fmt.Println("Options:")
 		flag.PrintDefaults()
 		fmt.Println("\nControls:")
-		fmt.Println("  Z - A button")
+		fmt.Println("  Z - A button (rebind in the [input] section of the config file)")
 		fmt.Println("  X - B button")
 		fmt.Println("  A - Select")
 		fmt.Println("  S - Start")
 		fmt.Println("  Arrow keys - D-pad")
 		fmt.Println("  Tab - Toggle turbo (fast-forward)")
 		fmt.Println("  Ctrl+R - Reset NES")
+		fmt.Println("  Ctrl+P - Power cycle NES")
 		fmt.Println("  F1-F10 - Save state to slot 1-10")
 		fmt.Println("  Ctrl+F1-F10 - Load state from slot 1-10")
 		fmt.Println("  F11 - Toggle FPS display")
 		fmt.Println("  F12 - Save screenshot")
-		fmt.Println("  ESC - Quit")
+		fmt.Println("  ESC - Menu (load ROM, settings, controls, cheats)")
+		fmt.Println("  Ctrl+Q - Quit")
 	}
 
 	flag.Parse()
+	flagsSet := config.FlagsSet(flag.CommandLine)
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Config: %v", err)
+	}
+	if *saveConfig {
+		if cfgPath == "" {
+			log.Fatalf("-save-config: no config file path")
+		}
+		if err := cfg.Save(cfgPath); err != nil {
+			log.Fatalf("-save-config: %v", err)
+		}
+		fmt.Printf("Settings saved to %s\n", cfgPath)
+		if flag.NArg() < 1 {
+			return
+		}
+	}
 
 	// Check if ROM file is provided
 	if flag.NArg() < 1 {
@@ -62,8 +103,8 @@ func main() {
 
 	romFile := flag.Arg(0)
 
-	if *cpuProfile != "" {
-		f, err := os.Create(*cpuProfile)
+	if cfg.Debug.CPUProfile != "" {
+		f, err := os.Create(cfg.Debug.CPUProfile)
 		if err != nil {
 			log.Fatalf("create cpu profile: %v", err)
 		}
@@ -73,9 +114,9 @@ func main() {
 		}
 		defer pprof.StopCPUProfile()
 	}
-	if *memProfile != "" {
+	if cfg.Debug.MemProfile != "" {
 		defer func() {
-			f, err := os.Create(*memProfile)
+			f, err := os.Create(cfg.Debug.MemProfile)
 			if err != nil {
 				logger.LogError("create mem profile: %v", err)
 				return
@@ -88,43 +129,76 @@ func main() {
 		}()
 	}
 
+	// -remote stdio speaks the protocol on stdout, so the logger (and
+	// anything else printing there) is sent to stderr instead.
+	stdout := os.Stdout
+	if cfg.Debug.Remote == remoteStdio {
+		if romFile == stdinROM {
+			log.Fatalf("-remote stdio needs stdin for commands; pass the ROM as a file")
+		}
+		os.Stdout = os.Stderr
+	}
+
 	// Initialize logger
-	level := logger.GetLogLevelFromString(*logLevel)
-	err := logger.Initialize(level, *logFile)
+	level := logger.GetLogLevelFromString(cfg.Log.Level)
+	err = logger.Initialize(level, cfg.Log.File)
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 	defer logger.Close()
+	defer logger.DumpOnPanic()
 
 	// Configure component logging
-	logger.SetCPULogging(*cpuLog)
-	logger.SetPPULogging(*ppuLog)
-	logger.SetAPULogging(*apuLog)
-	logger.SetMapperLogging(*mapperLog)
+	logger.SetCPULogging(cfg.Log.CPU)
+	logger.SetPPULogging(cfg.Log.PPU)
+	logger.SetAPULogging(cfg.Log.APU)
+	logger.SetMapperLogging(cfg.Log.Mapper)
+	logger.SetBusLogging(cfg.Log.Bus)
+	if err := logger.ParseLevels(cfg.Log.Components); err != nil {
+		log.Fatalf("-log-components: %v", err)
+	}
+	if cfg.Log.JSON {
+		logger.SetFormat(logger.FormatJSON)
+	}
+	logger.SetRingSize(cfg.Log.Ring)
 
 	logger.LogInfo("GoNES Emulator starting...")
-	logger.LogInfo("Log level: %s", *logLevel)
-	if *logFile != "" {
-		logger.LogInfo("Logging to file: %s", *logFile)
+	logger.LogInfo("Log level: %s", cfg.Log.Level)
+	if cfg.Log.File != "" {
+		logger.LogInfo("Logging to file: %s", cfg.Log.File)
 	}
 
 	// Check if file exists
-	if _, err := os.Stat(romFile); os.IsNotExist(err) {
-		log.Fatalf("ROM file not found: %s", romFile)
+	if romFile != stdinROM {
+		if _, err := os.Stat(romFile); os.IsNotExist(err) {
+			log.Fatalf("ROM file not found: %s", romFile)
+		}
 	}
 
-	// Load cartridge
-	file, err := os.Open(romFile)
-	if err != nil {
-		log.Fatalf("Failed to open ROM file: %v", err)
+	if err := loadFDSBIOS(cfg.Paths); err != nil {
+		log.Fatalf("FDS BIOS: %v", err)
+	}
+	if err := loadGameDB(cfg.Paths); err != nil {
+		log.Fatalf("Game database: %v", err)
 	}
-	defer file.Close()
 
-	cart, err := cartridge.LoadFromReader(file)
+	// Load cartridge (plain .nes or .fds, .zip, .gz, or "-" for stdin)
+	cart, err := loadROM(romFile, *patchFile)
+	if errors.Is(err, cartridge.ErrNoFDSBIOS) {
+		err = fmt.Errorf("%w; put it at %s or give -fds-bios", err, cfg.Paths.FDSBIOSPath())
+	}
 	if err != nil {
+		err = romLoadError(romFile, err)
 		logger.LogError("Failed to load ROM: %v", err)
 		log.Fatalf("Failed to load ROM: %v", err)
 	}
+	// The game file's settings go on top of the config file's, under
+	// the flags; a ROM opened in the window gets its own the same way.
+	baseCfg := cfg
+	cfg, cart, err = applyGame(baseCfg, flagsSet, cart)
+	if err != nil {
+		log.Fatalf("Game settings: %v", err)
+	}
 
 	mapperNumber := (cart.Header.Flags6 >> 4) | (cart.Header.Flags7 & 0xF0)
 
@@ -139,25 +213,185 @@ func main() {
 
 	// Create NES system
 	logger.LogInfo("Creating NES system...")
-	nesSystem := nes.NewNES()
+	if cfg.Emulation.PPUAlign < 0 || cfg.Emulation.PPUAlign >= nes.PPUAlignments {
+		log.Fatalf("-ppu-align must be 0-%d", nes.PPUAlignments-1)
+	}
+	nesOpts := []nes.Option{nes.WithPPUAlignment(cfg.Emulation.PPUAlign), nes.WithPPUWarmUp(cfg.Emulation.PPUWarmUp)}
+	if cfg.Emulation.Deterministic {
+		nesOpts = append(nesOpts, nes.WithDeterministic())
+	}
+	nesSystem := nes.NewNES(nesOpts...)
 	nesSystem.LoadCartridge(cart)
-	nesSystem.Reset()
+	nesSystem.RAMInit, err = memory.ParseRAMPattern(cfg.Emulation.RAMInit)
+	if err != nil {
+		log.Fatalf("-ram-init: %v", err)
+	}
+	nesSystem.RAMSeed = cfg.Emulation.RAMSeed
+	if nesSystem.RAMInit == memory.RAMRandom {
+		if nesSystem.RAMSeed == 0 {
+			if cfg.Emulation.Deterministic {
+				nesSystem.RAMSeed = nes.DeterministicSeed
+			} else {
+				nesSystem.RAMSeed = time.Now().UnixNano()
+			}
+		}
+		logger.LogInfo("RAM init: random, seed %d", nesSystem.RAMSeed)
+	}
+	nesSystem.PowerOn()
+	if cfg.Emulation.FourScore {
+		nesSystem.GetInput().SetFourScore(true)
+		logger.LogInfo("Four Score attached (4 players)")
+	}
+	expansion, err := input.NewExpansion(cfg.Emulation.Expansion)
+	if err != nil {
+		log.Fatalf("-expansion: %v", err)
+	}
+	if expansion != nil {
+		nesSystem.GetInput().SetExpansion(expansion)
+		logger.LogInfo("Expansion port: %s", cfg.Emulation.Expansion)
+	}
+	if vs := nesSystem.GetInput().VS(); vs != nil {
+		model, err := vsPPU(cfg.Emulation.VSPPU)
+		if err != nil {
+			log.Fatalf("-vs-ppu: %v", err)
+		}
+		if model != nil {
+			nesSystem.PPU.PaletteManager.SetModel(*model)
+		}
+		vs.DIPs = uint8(cfg.Emulation.VSDIPs)
+		logger.LogInfo("VS. System: PPU %s, DIP switches %08b", nesSystem.PPU.PaletteManager.Model(), vs.DIPs)
+	}
+	if cfg.Video.FastPPU {
+		nesSystem.PPU.SetScanlineRenderer(true)
+		logger.LogInfo("Scanline renderer enabled")
+	}
+	nesSystem.PPU.NoSpriteLimit = cfg.Video.NoSpriteLimit
+	nesSystem.APU.NESAudio = cfg.Audio.Console == "nes"
+	region, err := apu.ParseRegion(cfg.Emulation.Region)
+	if err != nil {
+		log.Fatalf("-region: %v", err)
+	}
+	nesSystem.APU.SetRegion(region)
+	logger.LogInfo("APU region: %s", region)
+	nesSystem.APU.SetExpansionLevel(apu.ChipFME7, float32(cfg.Audio.Level5B)/100)
+	nesSystem.APU.SetExpansionLevel(apu.ChipVRC6, float32(cfg.Audio.LevelVRC6)/100)
+	nesSystem.APU.SetExpansionLevel(apu.ChipMMC5, float32(cfg.Audio.LevelMMC5)/100)
+	nesSystem.APU.SetExpansionLevel(apu.ChipFDS, float32(cfg.Audio.LevelFDS)/100)
+	nesSystem.APU.SetExpansionLevel(apu.ChipN163, float32(cfg.Audio.LevelN163)/100)
+	if cfg.Video.Palette != "" {
+		colors, err := loadPalette(cfg.Video.Palette)
+		if err != nil {
+			log.Fatalf("-palette: %v", err)
+		}
+		nesSystem.PPU.PaletteManager.SetPalette(colors)
+		logger.LogInfo("Palette: %s", cfg.Video.Palette)
+	}
+	if cfg.Video.NTSCPalette {
+		ntsc := ntscControls(cfg.Video)
+		nesSystem.PPU.PaletteManager.SetNTSC(&ntsc)
+		logger.LogInfo("Palette: NTSC signal, %+v", ntsc)
+	}
+	nesSystem.Cheats.SetEnabled(cfg.Cheats.Enabled)
+	nesSystem.TrapOnHalt = cfg.Emulation.TrapJAM
+	traceFilter, err := trace.ParseFilter(cfg.Debug.TraceFilter)
+	if err != nil {
+		log.Fatalf("-trace-filter: %v", err)
+	}
+	watchKeep, err := nes.ParseSwapMode(cfg.Debug.WatchKeep)
+	if err != nil {
+		log.Fatalf("-watch-keep: %v", err)
+	}
+	if _, err := vfilter.New(cfg.Video.Filter); err != nil {
+		log.Fatalf("-filter: %v", err)
+	}
 	logger.LogInfo("NES system initialized")
 
-	// Battery-backed PRG RAM: load <rom>.sav if it exists, persist on exit.
-	savePath := nes.CompanionFile(romFile, ".sav")
-	if cart.HasBattery() {
-		loadBatterySave(cart, savePath)
-		defer saveBatterySave(cart, savePath)
+	// A ROM piped through stdin has no path to hang companion files
+	// (.sav, .stateN, .cht) off, so battery and state I/O are disabled.
+	romPath := romFile
+	if romFile == stdinROM {
+		romPath = ""
+	}
+
+	// Battery-backed PRG RAM, a disk or a save chip: load <rom>.sav if it
+	// exists, persist on exit.
+	// In GUI mode the window owns the save from here on — a ROM dropped
+	// onto it swaps the cartridge, so only the GUI knows which .sav the
+	// running cart belongs to when Destroy writes it back.
+	savePath := nes.CompanionFileIn(cfg.Paths.Saves, romPath, ".sav")
+	battery := cart.NonVolatile()
+	if battery != nil && romPath != "" {
+		nes.LoadBatterySave(battery, savePath)
 	}
 
-	if *headless {
+	if cfg.Debug.GDBPort != 0 && (cfg.Debug.Remote != "" || cfg.Debug.Headless) {
+		log.Fatalf("-gdb-port needs the GUI; it can't be combined with -headless or -remote")
+	}
+	if cfg.Debug.Watch && (cfg.Debug.Remote != "" || cfg.Debug.Headless || romPath == "") {
+		log.Fatalf("-watch needs the GUI and a ROM file; it can't be combined with -headless, -remote or a ROM on stdin")
+	}
+	if cfg.Debug.Remote != "" {
+		if battery != nil && romPath != "" {
+			defer nes.SaveBatterySave(battery, savePath)
+		}
+		runRemote(nesSystem, romPath, cfg.Debug.Remote, stdout)
+	} else if cfg.Debug.Headless {
+		if battery != nil && romPath != "" {
+			defer nes.SaveBatterySave(battery, savePath)
+		}
+		out := &frameOutput{dir: cfg.Debug.DumpFrames, every: cfg.Debug.DumpEvery}
+		if cfg.Video.InputDisplay {
+			out.input = nesSystem.GetInput()
+		}
+		if cfg.Debug.HashFrames {
+			out.hashes = os.Stdout
+		}
+		if out.dir != "" {
+			if err := os.MkdirAll(out.dir, 0o755); err != nil {
+				log.Fatalf("-dump-frames: %v", err)
+			}
+		}
+		if cfg.Debug.Trace > 0 || logger.CPUEnabled() {
+			defer traceHeadless(nesSystem, romPath, cfg.Debug.Trace, traceFilter)()
+		}
 		// Run in headless mode
-		runHeadless(nesSystem, *testFrames)
+		runHeadless(nesSystem, cfg.Debug.TestFrames, out)
 	} else {
 		// Create and run GUI
 		logger.LogInfo("Creating GUI...")
-		nesGUI, err := gui.NewNESGUI(nesSystem, romFile)
+		nesGUI, err := gui.NewNESGUI(nesSystem, romPath, gui.Options{
+			Scale:             cfg.Video.Scale,
+			AudioLatency:      time.Duration(cfg.Audio.LatencyMs) * time.Millisecond,
+			AudioBuffer:       cfg.Audio.BufferSamples,
+			Volume:            cfg.Audio.Volume,
+			Muted:             cfg.Audio.Muted,
+			PauseInBackground: cfg.Video.PauseInBackground,
+			Pacing:            cfg.Video.Pacing,
+			Filter:            cfg.Video.Filter,
+			FrameSkip:         cfg.Video.FrameSkip,
+			AutoFrameSkip:     cfg.Video.AutoFrameSkip,
+			Keys:              cfg.Input.Keys(),
+			SaveDir:           cfg.Paths.Saves,
+			StateDir:          cfg.Paths.States,
+			ScreenshotDir:     cfg.Paths.Screenshots,
+			NoCheatAutoLoad:   !cfg.Cheats.AutoLoad,
+			InputDisplay:      cfg.Video.InputDisplay,
+			NTSC:              ntscControls(cfg.Video),
+			GDBPort:           cfg.Debug.GDBPort,
+			GDBUndo:           cfg.Debug.GDBUndo,
+			Trace:             cfg.Debug.Trace,
+			TraceFilter:       traceFilter,
+			Watch:             cfg.Debug.Watch,
+			WatchKeep:         watchKeep,
+			StatsCSV:          cfg.Debug.StatsCSV,
+			Autosave:          time.Duration(cfg.Emulation.Autosave) * time.Second,
+			Overscan: gui.Overscan{
+				Top: cfg.Video.OverscanTop, Bottom: cfg.Video.OverscanBottom,
+				Left: cfg.Video.OverscanLeft, Right: cfg.Video.OverscanRight,
+			},
+			Game:       guiGame(baseCfg, flagsSet),
+			SaveVolume: saveVolume(cfgPath),
+		})
 		if err != nil {
 			logger.LogError("Failed to create GUI: %v", err)
 			log.Fatalf("Failed to create GUI: %v", err)
@@ -171,46 +405,50 @@ func main() {
 	}
 }
 
-func loadBatterySave(cart *cartridge.Cartridge, path string) {
-	f, err := os.Open(path)
-	if errors.Is(err, fs.ErrNotExist) {
-		logger.LogInfo("No save file at %s (fresh save)", path)
-		return
-	}
-	if err != nil {
-		logger.LogError("Failed to open save file %s: %v", path, err)
-		return
-	}
-	defer f.Close()
-	if err := cart.LoadRAM(f); err != nil {
-		logger.LogError("Failed to read save file %s: %v", path, err)
-		return
-	}
-	logger.LogInfo("Loaded save file: %s", path)
-}
-
-func saveBatterySave(cart *cartridge.Cartridge, path string) {
-	f, err := os.Create(path)
-	if err != nil {
-		logger.LogError("Failed to create save file %s: %v", path, err)
-		return
+// traceHeadless installs the -trace ring for a headless run. Deferred,
+// the function it returns writes the ring to <rom>.trace.txt if the run
+// ends in a CPU jam or a panic.
+func traceHeadless(nesSystem *nes.NES, romPath string, size int, filter trace.Filter) func() {
+	tracer := trace.New(nesSystem, size, filter)
+	path := "gones.trace.txt"
+	if romPath != "" {
+		path = nes.CompanionFile(romPath, ".trace.txt")
 	}
-	defer f.Close()
-	if err := cart.SaveRAM(f); err != nil {
-		logger.LogError("Failed to write save file %s: %v", path, err)
-		return
+	return func() {
+		r := recover()
+		if (r != nil || nesSystem.CPU.Halted()) && tracer.Len() > 0 {
+			if err := tracer.WriteFile(path); err != nil {
+				logger.LogError("Trace: %v", err)
+			} else {
+				logger.LogInfo("Trace: last %d instructions written to %s", tracer.Len(), path)
+			}
+		}
+		if r != nil {
+			panic(r)
+		}
 	}
-	logger.LogInfo("Wrote save file: %s", path)
 }
 
-func runHeadless(nesSystem *nes.NES, maxFrames int) {
+func runHeadless(nesSystem *nes.NES, maxFrames int, out *frameOutput) {
 	logger.LogInfo("Starting headless mode for %d frames", maxFrames)
 
 	startTime := time.Now()
 
+	var rgba []uint8 // reused by out.frame
 	for frame := 0; frame < maxFrames; frame++ {
 		// Run one frame
 		nesSystem.StepFrame()
+		if info := nesSystem.CPU.HaltInfo(); info != nil && nesSystem.TrapOnHalt {
+			logger.LogError("Frame %d: %v", frame, info)
+			break
+		}
+		if out.enabled() {
+			rgba = out.capture(nesSystem, rgba)
+			if err := out.frame(nesSystem.GetFrame(), rgba); err != nil {
+				logger.LogError("Frame %d: %v", frame, err)
+				break
+			}
+		}
 	}
 
 	elapsed := time.Since(startTime)
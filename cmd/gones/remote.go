@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/remote"
+)
+
+// remoteStdio is the -remote address that speaks the protocol on
+// stdin/stdout.
+const remoteStdio = "stdio"
+
+// runRemote hands nesSystem to a remote-control server on addr and serves
+// until stdin closes (stdio) or the process is interrupted. out is the
+// real standard output, which main has diverted from the logger.
+func runRemote(nesSystem *nes.NES, romPath, addr string, out io.Writer) {
+	srv := remote.NewServer(nesSystem, romPath)
+	stop := make(chan struct{})
+	defer close(stop)
+	go srv.Run(stop)
+
+	if addr == remoteStdio {
+		logger.LogInfo("Remote control on stdin/stdout")
+		rw := struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, out}
+		if err := srv.ServeConn(rw); err != nil {
+			logger.LogError("Remote: %v", err)
+		}
+		return
+	}
+
+	ln, err := remote.Listen(addr)
+	if err != nil {
+		log.Fatalf("-remote: %v", err)
+	}
+	// Closing the listener on Ctrl+C or SIGTERM ends Serve normally, so
+	// main's deferred battery save and profile writes still run (and a
+	// unix socket file is removed).
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		ln.Close()
+	}()
+	logger.LogInfo("Remote control listening on %s", ln.Addr())
+	srv.Serve(ln)
+	logger.LogInfo("Remote control stopped")
+}
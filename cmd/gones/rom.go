@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
+	"github.com/yoshiomiyamaegones/pkg/config"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/patch"
+)
+
+// stdinROM is the ROM path that means "read the image from standard input".
+const stdinROM = "-"
+
+// loadFDSBIOS hands the Disk System BIOS to the cartridge loader so .fds
+// images open. Without one only disk images fail, so a missing file is an
+// error only when paths names it explicitly.
+func loadFDSBIOS(paths config.Paths) error {
+	path := paths.FDSBIOSPath()
+	if path == "" {
+		return nil
+	}
+	bios, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && paths.FDSBIOS == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return cartridge.SetFDSBIOS(bios)
+}
+
+// loadGameDB registers the games in the database file -gamedb names
+// (nes20db or NesCartDB), or in the default one beside the config file if
+// there is one, on top of the database built into the binary.
+func loadGameDB(paths config.Paths) error {
+	path := paths.GameDBPath()
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && paths.GameDB == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := cartridge.LoadGameDB(f)
+	if err != nil {
+		return err
+	}
+	logger.LogInfo("Game database: %d games from %s", n, path)
+	return nil
+}
+
+// loadROM opens romFile (or stdin for "-") and builds a cartridge from it.
+// Archives holding several .nes files are resolved by asking on the
+// terminal; when the ROM itself arrived on stdin there is nobody to ask,
+// so the ambiguity is reported as an error instead. The chosen image is
+// patched first with patchFile, or with the .ips/.bps beside romFile.
+func loadROM(romFile, patchFile string) (*cartridge.Cartridge, error) {
+	var data []byte
+	var err error
+	if romFile == stdinROM {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(romFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := cartridge.FindROMs(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 {
+		return loadPatched(entries[0], romFile, patchFile)
+	}
+	if romFile == stdinROM {
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name
+		}
+		return nil, &cartridge.MultipleROMsError{Names: names}
+	}
+	idx, err := promptROMChoice(entries, os.Stdin, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	return loadPatched(entries[idx], romFile, patchFile)
+}
+
+// loadPatched builds the cartridge in e after applying its patch (see
+// loadROM). A ROM from stdin has no place for one beside it.
+func loadPatched(e cartridge.ROMEntry, romFile, patchFile string) (*cartridge.Cartridge, error) {
+	if romFile == stdinROM {
+		romFile = ""
+	}
+	data, path, err := patch.ApplyFor(e.Data, romFile, patchFile)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		logger.LogInfo("Patch: applied %s", filepath.Base(path))
+	}
+	e.Data = data
+	return cartridge.LoadEntry(e)
+}
+
+// romLoadError rewords the cartridge loader's errors for the terminal,
+// naming the file and what is wrong with it in words a player can act on.
+// Other errors come back as they are.
+func romLoadError(romFile string, err error) error {
+	name := romFile
+	if romFile == stdinROM {
+		name = "standard input"
+	}
+	var unsupported *cartridge.ErrUnsupportedMapper
+	switch {
+	case errors.As(err, &unsupported):
+		return fmt.Errorf("%s needs mapper %d, which GoNES doesn't emulate yet (it does %s)", name, unsupported.N, mapper.SupportedList())
+	case errors.Is(err, cartridge.ErrBadMagic):
+		return fmt.Errorf("%s is not an NES ROM: expected a .nes or .fds image, or a zip/gzip archive holding one", name)
+	case errors.Is(err, cartridge.ErrTruncatedHeader),
+		errors.Is(err, cartridge.ErrTruncatedPRG),
+		errors.Is(err, cartridge.ErrTruncatedCHR):
+		return fmt.Errorf("%s is incomplete, likely a bad dump or an interrupted download (%v)", name, err)
+	case errors.Is(err, patch.ErrWrongROM):
+		return fmt.Errorf("%s doesn't match its patch, which was made for another version or dump of the game (%v)", name, err)
+	case errors.Is(err, cartridge.ErrNoPRG):
+		return fmt.Errorf("%s has a damaged header (%v); \"gones fixheader\" may repair it", name, err)
+	}
+	return err
+}
+
+// promptROMChoice lists the archive members and reads a 1-based selection.
+func promptROMChoice(entries []cartridge.ROMEntry, in io.Reader, out io.Writer) (int, error) {
+	fmt.Fprintln(out, "Archive contains multiple ROMs:")
+	for i, e := range entries {
+		fmt.Fprintf(out, "  %d) %s\n", i+1, e.Name)
+	}
+	fmt.Fprintf(out, "Select ROM [1-%d]: ", len(entries))
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return 0, errors.New("no ROM selected")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || n < 1 || n > len(entries) {
+		return 0, fmt.Errorf("invalid selection %q", strings.TrimSpace(line))
+	}
+	return n - 1, nil
+}
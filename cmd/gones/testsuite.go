@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/yoshiomiyamaegones/pkg/testsuite"
+)
+
+// runTestSuite is the "gones testsuite" subcommand: it runs every test ROM
+// under a directory headlessly, several at once, and prints the pass/fail
+// table. With -baseline it compares against an earlier -json report and
+// fails when a ROM that passed there doesn't now.
+func runTestSuite(args []string) error {
+	fs := flag.NewFlagSet("testsuite", flag.ExitOnError)
+	jobs := fs.Int("j", runtime.NumCPU(), "How many ROMs to run at once")
+	frames := fs.Int("frames", testsuite.DefaultFrames, "Give each ROM at most this many frames to report a result")
+	timeout := fs.Duration("timeout", testsuite.DefaultTimeout, "Give each ROM at most this much wall-clock time")
+	jsonOut := fs.String("json", "", "Also write the results as JSON to this file (- for stdout, replacing the table)")
+	baseline := fs.String("baseline", "", "Compare with this earlier -json report and fail on regressions")
+	quiet := fs.Bool("q", false, "Don't print each ROM as it finishes")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s testsuite [-j N] [-frames N] [-timeout D] [-json out.json] [-baseline old.json] <dir>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *frames < 1 || *timeout <= 0 || *jobs < 1 {
+		return fmt.Errorf("-frames, -timeout and -j must be positive")
+	}
+
+	var base *testsuite.Report
+	if *baseline != "" {
+		f, err := os.Open(*baseline)
+		if err != nil {
+			return err
+		}
+		rep, err := testsuite.ReadJSON(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", *baseline, err)
+		}
+		base = &rep
+	}
+
+	opts := testsuite.Options{Limits: testsuite.Limits{Frames: *frames, Timeout: *timeout}, Jobs: *jobs}
+	if !*quiet {
+		opts.Progress = func(r testsuite.Result) {
+			fmt.Fprintf(os.Stderr, "%-8s %s\n", r.Outcome, r.ROM)
+		}
+	}
+	start := time.Now()
+	results, err := testsuite.Run(fs.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	if !*quiet {
+		fmt.Fprintf(os.Stderr, "%d ROMs in %s\n\n", len(results), time.Since(start).Round(time.Millisecond))
+	}
+
+	if *jsonOut != "-" {
+		if err := testsuite.WriteText(os.Stdout, results); err != nil {
+			return err
+		}
+	}
+	if *jsonOut != "" {
+		out := os.Stdout
+		if *jsonOut != "-" {
+			f, err := os.Create(*jsonOut)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := testsuite.WriteJSON(out, results); err != nil {
+			return err
+		}
+	}
+
+	if base == nil {
+		return nil
+	}
+	regressed := 0
+	changes := testsuite.Compare(*base, results)
+	if len(changes) > 0 {
+		fmt.Fprintf(os.Stderr, "\nChanged since %s:\n", *baseline)
+	}
+	for _, c := range changes {
+		fmt.Fprintf(os.Stderr, "  %s\n", c)
+		if c.Regressed() {
+			regressed++
+		}
+	}
+	if regressed > 0 {
+		return fmt.Errorf("%d ROMs regressed", regressed)
+	}
+	return nil
+}
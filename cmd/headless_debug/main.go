@@ -1,35 +1,149 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"hash/crc32"
 	"log"
 	"os"
 	"time"
 
 	"github.com/yoshiomiyamaegones/pkg/cartridge"
 	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
+	"github.com/yoshiomiyamaegones/pkg/expr"
 	"github.com/yoshiomiyamaegones/pkg/logger"
 	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/ntcheck"
+	"github.com/yoshiomiyamaegones/pkg/ppulog"
+	"github.com/yoshiomiyamaegones/pkg/profile"
+	"github.com/yoshiomiyamaegones/pkg/rules"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+	"github.com/yoshiomiyamaegones/pkg/trace"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: headless_debug <rom_file> [frames]")
+	inputsFile := flag.String("inputs", "", "input script: one frame:button:press|release[:controller] per line")
+	untilPC := flag.String("until-pc", "", "stop when the CPU is about to execute this address (hex, or a label from -symbols)")
+	untilMem := flag.String("until-mem", "", "stop when the byte at addr equals value (hex addr=value, RAM or $6000-$FFFF)")
+	untilExpr := flag.String("until-expr", "", "stop before the first instruction at which this expression is non-zero, e.g. \"[$0300+X] & $0F == 3\"")
+	untilStable := flag.Int("until-stable", 0, "stop once this many consecutive frames are identical")
+	rulesFile := flag.String("rules", "", "rule file (JSON, see package rules): print a line to stdout whenever a rule fires")
+	untilRules := flag.Bool("until-rules", false, "with -rules, stop once every rule has fired")
+	profileFile := flag.String("profile", "", "write a cycle profile of the hottest routines to this file (- for stdout)")
+	symbolsFile := flag.String("symbols", "", "symbol file (FCEUX .nl, NESASM .fns or ld65 .dbg) naming addresses for -until-pc, expressions, -profile and -trace")
+	var watchFlags stringList
+	flag.Var(&watchFlags, "watch", "log this expression's value after every frame and at the end (repeatable)")
+	traceFile := flag.String("trace", "", "write the last -trace-size executed instructions to this file (- for stdout) when the run ends, even by a panic")
+	traceSize := flag.Int("trace-size", 100000, "with -trace, how many instructions to keep")
+	traceFilterFlag := flag.String("trace-filter", "", "with -trace, keep only instructions matching these comma-separated terms: pc=8000-8FFF, bank=3, class=branch")
+	ppuLogFile := flag.String("ppu-log", "", "write one frame's PPU register writes, with scanline and dot, to this CSV file (- for stdout)")
+	ppuLogFrame := flag.Int("ppu-log-frame", -1, "with -ppu-log, the frame to log (counting from 0, as -inputs does); default the last frame run")
+	ntCheck := flag.Bool("nt-check", false, "report nametable and attribute bytes changed through $2007 while the PPU was drawing, and the instructions that did it")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: headless_debug [options] <rom_file> [frames]")
+		fmt.Fprintln(os.Stderr, "With an -until-* condition, exits 1 if frames run out before it is met.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	romFile := os.Args[1]
+	romFile := flag.Arg(0)
 	maxFrames := 10
-	if len(os.Args) >= 3 {
-		fmt.Sscanf(os.Args[2], "%d", &maxFrames)
+	if flag.NArg() >= 2 {
+		fmt.Sscanf(flag.Arg(1), "%d", &maxFrames)
+	}
+
+	var events []inputEvent
+	if *inputsFile != "" {
+		f, err := os.Open(*inputsFile)
+		if err != nil {
+			log.Fatalf("Failed to open input script: %v", err)
+		}
+		events, err = parseInputScript(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Input script %s: %v", *inputsFile, err)
+		}
+	}
+	var symTable *symbols.Table
+	if *symbolsFile != "" {
+		syms, err := symbols.LoadFile(*symbolsFile)
+		if err != nil {
+			log.Fatalf("Symbol file: %v", err)
+		}
+		symTable = symbols.NewTable(syms)
+	}
+	var stopPC *symbols.Symbol
+	if *untilPC != "" {
+		target, ok := symTable.Find(*untilPC)
+		if !ok {
+			pc, err := parseAddr(*untilPC)
+			if err != nil {
+				log.Fatalf("-until-pc: %v", err)
+			}
+			target = symbols.Symbol{Address: pc, PRG: -1}
+		}
+		stopPC = &target
+	}
+	var stopMem *memCondition
+	if *untilMem != "" {
+		c, err := parseMemCondition(*untilMem)
+		if err != nil {
+			log.Fatalf("-until-mem: %v", err)
+		}
+		stopMem = &c
+	}
+	var stopExpr *expr.Expr
+	if *untilExpr != "" {
+		e, err := expr.Compile(*untilExpr, symTable)
+		if err != nil {
+			log.Fatalf("-until-expr: %v", err)
+		}
+		stopExpr = e
+	}
+	var watches []*expr.Expr
+	for _, src := range watchFlags {
+		e, err := expr.Compile(src, symTable)
+		if err != nil {
+			log.Fatalf("-watch: %v", err)
+		}
+		watches = append(watches, e)
+	}
+	var ruleEngine *rules.Engine
+	if *rulesFile != "" {
+		f, err := os.Open(*rulesFile)
+		if err != nil {
+			log.Fatalf("Failed to open rule file: %v", err)
+		}
+		loaded, err := rules.Load(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Rule file %s: %v", *rulesFile, err)
+		}
+		ruleEngine = rules.NewEngine(loaded)
+		ruleEngine.OnEvent(func(ev rules.Event) { fmt.Printf("rule %q: %v\n", ev.Rule.Name, ev) })
+	} else if *untilRules {
+		log.Fatal("-until-rules needs -rules")
+	}
+	traceFilter, err := trace.ParseFilter(*traceFilterFlag)
+	if err != nil {
+		log.Fatalf("-trace-filter: %v", err)
+	}
+	if *traceFile != "" && *traceSize <= 0 {
+		log.Fatal("-trace-size must be positive")
 	}
+	hasCondition := stopPC != nil || stopMem != nil || stopExpr != nil || *untilStable > 0 || *untilRules
 
 	// Initialize logger
-	err := logger.Initialize(logger.LogLevelDebug, "")
+	err = logger.Initialize(logger.LogLevelDebug, "")
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 	defer logger.Close()
+	defer logger.DumpOnPanic()
 
 	// Load cartridge
 	file, err := os.Open(romFile)
@@ -53,7 +167,54 @@ func main() {
 	// Create NES system
 	nesSystem := nes.NewNES()
 	nesSystem.LoadCartridge(cart)
-	nesSystem.Reset()
+	nesSystem.PowerOn()
+	nesSystem.TrapOnHalt = true
+	var profiler *profile.Profiler
+	if *profileFile != "" {
+		profiler = profile.New(symTable.Symbols(), nesSystem.PRGOffset)
+		nesSystem.Profile = profiler.Record
+	}
+	var ppuLog *ppulog.Log
+	if *ppuLogFile != "" {
+		ppuLog = ppulog.Start(nesSystem)
+	}
+	var ntChecker *ntcheck.Checker
+	ntLabels := map[uint16]string{} // the first sighting's label per PC
+	if *ntCheck {
+		ntChecker = ntcheck.Start(nesSystem)
+		ntChecker.OnWrite = func(w ntcheck.Write) {
+			if _, seen := ntLabels[w.PC]; seen {
+				return
+			}
+			ntLabels[w.PC] = symTable.Label(w.PC, nesSystem.PRGOffset(w.PC))
+			logger.LogWarn("NT check: %v\n", w)
+		}
+	}
+	var tracer *trace.Tracer
+	if *traceFile != "" {
+		tracer = trace.New(nesSystem, *traceSize, traceFilter)
+		tracer.Symbols = symTable
+		defer writeTraceOnPanic(tracer, *traceFile)
+	}
+
+	// stopReason is set by the first exit condition met.
+	var stopReason string
+	if stopPC != nil || stopMem != nil || stopExpr != nil {
+		nesSystem.Break = func() bool {
+			switch {
+			case stopPC != nil && nesSystem.CPU.PC == stopPC.Address &&
+				(stopPC.PRG < 0 || nesSystem.PRGOffset(stopPC.Address) == stopPC.PRG):
+				stopReason = "PC reached " + symTable.Label(stopPC.Address, nesSystem.PRGOffset(stopPC.Address))
+			case stopMem != nil && nesSystem.Memory.Peek(stopMem.Addr) == stopMem.Value:
+				stopReason = fmt.Sprintf("$%04X == $%02X", stopMem.Addr, stopMem.Value)
+			case stopExpr != nil && stopExpr.True(nesSystem):
+				stopReason = stopExpr.String()
+			}
+			return stopReason != ""
+		}
+	}
+	var lastHash uint32
+	stableFrames := 0
 
 	logger.LogInfo("=== Initial State ===\n")
 	logger.LogInfo("Frame: %d\n", nesSystem.GetFrame())
@@ -68,24 +229,52 @@ func main() {
 	startTime := time.Now()
 
 	// Run for specified number of frames
+	framesRun := 0
+	var framebuffer []uint8
 	for i := 0; i < maxFrames; i++ {
 		frameStart := time.Now()
 
-		// Don't press START button - just let title screen display
-		// if i == 5 {
-		//	logger.LogInfo("Pressing START button to begin test\n")
-		//	nesSystem.Input.SetButton(0, 3, true) // START button (player 1, button 3)
-		// }
-		// if i == 6 {
-		//	nesSystem.Input.SetButton(0, 3, false)
-		// }
+		for _, ev := range events {
+			if ev.Frame == i {
+				logger.LogInfo("Controller %d button %d pressed=%v\n", ev.Controller+1, ev.Button, ev.Pressed)
+				nesSystem.Input.SetButton(ev.Controller, ev.Button, ev.Pressed)
+			}
+		}
 
 		nesSystem.StepFrame()
+		framesRun++
+		if profiler != nil {
+			profiler.EndFrame()
+		}
+		if ppuLog != nil {
+			ppuLog.EndFrame()
+			if i == *ppuLogFrame {
+				writePPULog(ppuLog.LastFrame(), *ppuLogFile)
+				ppuLog.Stop()
+				ppuLog = nil
+			}
+		}
+		if info := nesSystem.CPU.HaltInfo(); info != nil {
+			logger.LogError("=== CPU Halted ===\n")
+			logger.LogError("%v\n", info)
+			break
+		}
+		if stopReason != "" {
+			break
+		}
+		if ruleEngine != nil {
+			ruleEngine.Check(nesSystem.Memory.Peek, nesSystem.GetFrame())
+			if *untilRules && ruleEngine.Fired() == len(ruleEngine.Rules()) {
+				stopReason = fmt.Sprintf("all %d rules fired", len(ruleEngine.Rules()))
+				break
+			}
+		}
 
 		frameTime := time.Since(frameStart)
 
 		logger.LogInfo("Frame %d completed in %v\n", nesSystem.GetFrame(), frameTime)
 		logger.LogInfo("  Total cycles: %d\n", nesSystem.Cycles)
+		printWatches(watches, nesSystem)
 
 		// Print PPU register state for first frame
 		if i == 0 {
@@ -98,7 +287,7 @@ func main() {
 		}
 
 		// Check framebuffer content
-		framebuffer := nesSystem.GetFramebuffer()
+		framebuffer = nesSystem.GetFramebufferInto(framebuffer)
 		nonZeroPixels := 0
 		pixelStats := make(map[uint8]int)
 		for j := 0; j < len(framebuffer); j++ {
@@ -109,6 +298,22 @@ func main() {
 		}
 		logger.LogInfo("  Non-zero pixels in framebuffer: %d\n", nonZeroPixels)
 
+		if *untilStable > 0 {
+			hash := crc32.ChecksumIEEE(framebuffer)
+			if i > 0 && hash == lastHash {
+				stableFrames++
+			} else {
+				stableFrames = 1
+			}
+			lastHash = hash
+			logger.LogInfo("  Frame hash: %08X (unchanged for %d frames)\n", hash, stableFrames)
+			if stableFrames >= *untilStable {
+				stopReason = fmt.Sprintf("frame hash %08X stable for %d frames", hash, stableFrames)
+				logger.LogInfo("\n")
+				break
+			}
+		}
+
 		// Print pixel distribution for first frame to see if there's any variation
 		if i == 0 {
 			logger.LogInfo("  Pixel value distribution: ")
@@ -120,19 +325,22 @@ func main() {
 			logger.LogInfo("\n")
 		}
 
-		// Save framebuffer for the last frame
-		if i == maxFrames-1 {
-			logger.LogInfo("  Saving final framebuffer...\n")
-			saveFramebuffer(framebuffer, fmt.Sprintf("debug_frame_%d.raw", nesSystem.GetFrame()))
-		}
-
 		logger.LogInfo("\n")
 	}
 
+	logger.LogInfo("Saving final framebuffer...\n")
+	saveFramebuffer(nesSystem.GetFramebuffer(), fmt.Sprintf("debug_frame_%d.raw", nesSystem.GetFrame()))
+
 	totalTime := time.Since(startTime)
 	logger.LogInfo("=== Final Results ===\n")
+	if stopReason != "" {
+		logger.LogInfo("Stopped: %s (PC=$%04X)\n", stopReason, nesSystem.CPU.PC)
+	}
+	printWatches(watches, nesSystem)
 	logger.LogInfo("Completed %d frames in %v\n", nesSystem.GetFrame(), totalTime)
-	logger.LogInfo("Average frame time: %v\n", totalTime/time.Duration(maxFrames))
+	if framesRun > 0 {
+		logger.LogInfo("Average frame time: %v\n", totalTime/time.Duration(framesRun))
+	}
 	logger.LogInfo("Final cycle count: %d\n", nesSystem.Cycles)
 
 	// Final mapper state
@@ -140,6 +348,30 @@ func main() {
 		logger.LogInfo("\n=== Final Mapper 4 State ===\n")
 		printMapper4State(cart.Mapper, nesSystem.GetFrame())
 	}
+
+	if profiler != nil {
+		writeProfile(profiler, *profileFile)
+	}
+	if tracer != nil {
+		writeTrace(tracer, *traceFile)
+	}
+	if ppuLog != nil {
+		if *ppuLogFrame >= 0 {
+			logger.LogError("PPU log: frame %d was never run\n", *ppuLogFrame)
+		} else {
+			writePPULog(ppuLog.LastFrame(), *ppuLogFile)
+		}
+	}
+
+	if ntChecker != nil {
+		printNTCheck(ntChecker, ntLabels)
+	}
+
+	if hasCondition && stopReason == "" {
+		logger.LogError("No exit condition met within %d frames\n", maxFrames)
+		logger.Close()
+		os.Exit(1)
+	}
 }
 
 func printMapper4State(m mapper.Mapper, frame uint64) {
@@ -168,6 +400,89 @@ func printMapper4State(m mapper.Mapper, frame uint64) {
 	}
 }
 
+// writeProfile writes the profiler's report to path, or stdout for "-".
+// printWatches logs each -watch expression's current value.
+func printWatches(watches []*expr.Expr, nesSystem *nes.NES) {
+	for _, e := range watches {
+		logger.LogInfo("  Watch %s\n", e.Format(nesSystem))
+	}
+}
+
+func writeProfile(p *profile.Profiler, path string) {
+	w := os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			logger.LogError("Profile: %v\n", err)
+			return
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := p.WriteReport(w, 0); err != nil {
+		logger.LogError("Profile: %v\n", err)
+	}
+}
+
+// writePPULog writes one frame's PPU register writes to path as CSV, or
+// stdout for "-".
+func writePPULog(writes []ppulog.Write, path string) {
+	w := os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			logger.LogError("PPU log: %v\n", err)
+			return
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := ppulog.WriteCSV(w, writes); err != nil {
+		logger.LogError("PPU log: %v\n", err)
+	}
+}
+
+// printNTCheck logs the -nt-check findings, one line per instruction
+// responsible, most writes first.
+func printNTCheck(c *ntcheck.Checker, labels map[uint16]string) {
+	writes := c.Writes()
+	total := len(writes) + c.Dropped()
+	if total == 0 {
+		logger.LogInfo("NT check: no nametable writes while drawing\n")
+		return
+	}
+	sites := ntcheck.Sites(writes)
+	logger.LogInfo("NT check: %d nametable writes while drawing, from %d instructions\n", total, len(sites))
+	for _, s := range sites {
+		logger.LogInfo("  %-20s %6d  first %v\n", labels[s.PC], s.Count, s.First)
+	}
+	if c.Dropped() > 0 {
+		logger.LogInfo("  (only the first %d are broken down)\n", ntcheck.MaxWrites)
+	}
+}
+
+// writeTrace writes the tracer's ring to path, or stdout for "-".
+func writeTrace(t *trace.Tracer, path string) {
+	if path == "-" {
+		if _, err := t.WriteTo(os.Stdout); err != nil {
+			logger.LogError("Trace: %v\n", err)
+		}
+		return
+	}
+	if err := t.WriteFile(path); err != nil {
+		logger.LogError("Trace: %v\n", err)
+	}
+}
+
+// writeTraceOnPanic is deferred once the tracer is installed: a panic in
+// the core writes the instructions leading up to it before continuing.
+func writeTraceOnPanic(t *trace.Tracer, path string) {
+	if r := recover(); r != nil {
+		writeTrace(t, path)
+		panic(r)
+	}
+}
+
 func printPPUState(nesSystem *nes.NES) {
 	logger.LogInfo("  PPU State:\n")
 	logger.LogInfo("    Frame: %d, Scanline: %d, Cycle: %d\n",
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// inputEvent is one line of an -inputs script: before frame Frame (counted
+// from 0, the first frame run) press or release Button (0-7, A..Right) on
+// Controller (0-3).
+type inputEvent struct {
+	Frame      int
+	Controller int
+	Button     int
+	Pressed    bool
+}
+
+// buttonNames are the script's button names, in input.Controller bit order.
+var buttonNames = map[string]int{
+	"a": 0, "b": 1, "select": 2, "start": 3,
+	"up": 4, "down": 5, "left": 6, "right": 7,
+}
+
+// parseInputScript reads an input script: one "frame:button:press|release"
+// event per line, optionally followed by ":N" for controller N (1-4,
+// default 1). Blank lines and lines starting with # are ignored.
+//
+//	# skip the title screen
+//	5:start:press
+//	6:start:release
+//	60:right:press:2
+func parseInputScript(r io.Reader) ([]inputEvent, error) {
+	var events []inputEvent
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ":")
+		if len(fields) != 3 && len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: want frame:button:press|release[:controller], got %q", line, text)
+		}
+		var ev inputEvent
+		var err error
+		if ev.Frame, err = strconv.Atoi(fields[0]); err != nil || ev.Frame < 0 {
+			return nil, fmt.Errorf("line %d: bad frame %q", line, fields[0])
+		}
+		var ok bool
+		if ev.Button, ok = buttonNames[strings.ToLower(fields[1])]; !ok {
+			return nil, fmt.Errorf("line %d: unknown button %q", line, fields[1])
+		}
+		switch strings.ToLower(fields[2]) {
+		case "press":
+			ev.Pressed = true
+		case "release":
+		default:
+			return nil, fmt.Errorf("line %d: want press or release, got %q", line, fields[2])
+		}
+		if len(fields) == 4 {
+			n, err := strconv.Atoi(fields[3])
+			if err != nil || n < 1 || n > 4 {
+				return nil, fmt.Errorf("line %d: bad controller %q", line, fields[3])
+			}
+			ev.Controller = n - 1
+		}
+		events = append(events, ev)
+	}
+	return events, sc.Err()
+}
+
+// parseAddr parses a CPU address written as hex, with or without a $ or
+// 0x prefix.
+func parseAddr(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "$"), "0x")
+	v, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("bad address %q", s)
+	}
+	return uint16(v), nil
+}
+
+// memCondition is an -until-mem condition: stop once the byte at Addr
+// equals Value.
+type memCondition struct {
+	Addr  uint16
+	Value uint8
+}
+
+// parseMemCondition parses "addr=value", both hex. Only CPU RAM and
+// cartridge space ($6000-$FFFF) can be watched: reading the I/O registers
+// in between has side effects.
+func parseMemCondition(s string) (memCondition, error) {
+	a, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return memCondition{}, fmt.Errorf("memory condition %q: want addr=value", s)
+	}
+	addr, err := parseAddr(a)
+	if err != nil {
+		return memCondition{}, fmt.Errorf("memory condition %q: %w", s, err)
+	}
+	if addr >= 0x2000 && addr < 0x6000 {
+		return memCondition{}, fmt.Errorf("memory condition %q: $%04X is not RAM or cartridge space", s, addr)
+	}
+	v = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(v), "$"), "0x")
+	value, err := strconv.ParseUint(v, 16, 8)
+	if err != nil {
+		return memCondition{}, fmt.Errorf("memory condition %q: bad value", s)
+	}
+	return memCondition{addr, uint8(value)}, nil
+}
+
+// stringList is a flag that may be repeated, such as -watch.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseInputScript(t *testing.T) {
+	events, err := parseInputScript(strings.NewReader(`
+# boot
+5:start:press
+6:START:release
+60:right:press:2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []inputEvent{
+		{Frame: 5, Button: 3, Pressed: true},
+		{Frame: 6, Button: 3},
+		{Frame: 60, Controller: 1, Button: 7, Pressed: true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"5:start", "x:a:press", "5:turbo:press", "5:a:hold", "5:a:press:5"} {
+		if _, err := parseInputScript(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestParseMemCondition(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want memCondition
+		ok   bool
+	}{
+		{"0300=80", memCondition{0x0300, 0x80}, true},
+		{"$6000=$DE", memCondition{0x6000, 0xDE}, true},
+		{"0x7FFF=0x01", memCondition{0x7FFF, 0x01}, true},
+		{"2002=80", memCondition{}, false}, // PPU register
+		{"0300", memCondition{}, false},
+		{"0300=100", memCondition{}, false},
+	} {
+		got, err := parseMemCondition(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("%q: %+v, %v", tc.in, got, err)
+		}
+	}
+}
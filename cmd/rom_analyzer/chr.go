@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/ppu"
+)
+
+// chrBankSize is one pattern table: 256 tiles, drawn as a 16×16 sheet.
+const chrBankSize = 0x1000
+
+// chrPalettes are the -chr-palette presets, as NES colour indices for
+// pixel values 0-3.
+var chrPalettes = map[string][4]uint8{
+	"gray":  {0x0F, 0x00, 0x10, 0x30},
+	"red":   {0x0F, 0x06, 0x16, 0x26},
+	"green": {0x0F, 0x09, 0x19, 0x29},
+	"blue":  {0x0F, 0x01, 0x11, 0x21},
+}
+
+// parseCHRPalette resolves a -chr-palette value: a preset name or four
+// comma-separated hex colour indices ("0F,16,27,30").
+func parseCHRPalette(spec string) (color.Palette, error) {
+	idx, ok := chrPalettes[spec]
+	if !ok {
+		parts := strings.Split(spec, ",")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("palette %q: want gray, red, green, blue or four hex colour indices", spec)
+		}
+		for i, p := range parts {
+			v, err := strconv.ParseUint(strings.TrimSpace(p), 16, 8)
+			if err != nil || v > 0x3F {
+				return nil, fmt.Errorf("palette %q: bad colour index %q", spec, p)
+			}
+			idx[i] = uint8(v)
+		}
+	}
+	master := ppu.MasterPalette()
+	pal := make(color.Palette, 4)
+	for i, c := range idx {
+		rgb := master[c]
+		pal[i] = color.RGBA{rgb[0], rgb[1], rgb[2], 0xFF}
+	}
+	return pal, nil
+}
+
+// parseBankRange resolves a -chr-banks value ("", "3" or "2-5") against n
+// banks; empty means all of them.
+func parseBankRange(spec string, n int) (first, last int, err error) {
+	if spec == "" {
+		return 0, n - 1, nil
+	}
+	lo, hi, isRange := strings.Cut(spec, "-")
+	if first, err = strconv.Atoi(lo); err != nil {
+		return 0, 0, fmt.Errorf("bank range %q: %w", spec, err)
+	}
+	last = first
+	if isRange {
+		if last, err = strconv.Atoi(hi); err != nil {
+			return 0, 0, fmt.Errorf("bank range %q: %w", spec, err)
+		}
+	}
+	if first < 0 || first > last || last >= n {
+		return 0, 0, fmt.Errorf("bank range %q: ROM has banks 0-%d", spec, n-1)
+	}
+	return first, last, nil
+}
+
+// chrSheet draws one 4KB bank of 2bpp tiles. Each tile is 16 bytes: eight
+// rows of the low bit plane, then eight of the high one, leftmost pixel in
+// bit 7.
+func chrSheet(bank []byte, pal color.Palette) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, 128, 128), pal)
+	for t := 0; t < 256 && t*16+16 <= len(bank); t++ {
+		tile := bank[t*16 : t*16+16]
+		ox, oy := t%16*8, t/16*8
+		for y := 0; y < 8; y++ {
+			lo, hi := tile[y], tile[y+8]
+			for x := 0; x < 8; x++ {
+				bit := 7 - uint(x)
+				img.SetColorIndex(ox+x, oy+y, lo>>bit&1|hi>>bit&1<<1)
+			}
+		}
+	}
+	return img
+}
+
+// exportCHR writes the selected 4KB banks of chr as <dir>/<name>_chrNN.png
+// and returns the paths written.
+func exportCHR(chr []byte, dir, name, banks string, pal color.Palette) ([]string, error) {
+	if len(chr) == 0 {
+		return nil, fmt.Errorf("no CHR ROM: this board draws its tiles into CHR RAM at run time")
+	}
+	first, last, err := parseBankRange(banks, (len(chr)+chrBankSize-1)/chrBankSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for b := first; b <= last; b++ {
+		end := (b + 1) * chrBankSize
+		if end > len(chr) {
+			end = len(chr)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s_chr%02d.png", name, b))
+		f, err := os.Create(path)
+		if err != nil {
+			return paths, err
+		}
+		err = png.Encode(f, chrSheet(chr[b*chrBankSize:end], pal))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
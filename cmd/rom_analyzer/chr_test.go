@@ -0,0 +1,56 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestCHRSheet(t *testing.T) {
+	bank := make([]byte, chrBankSize)
+	// Tile 17 (column 1, row 1): row 2 is 0b10000001 in the low plane and
+	// 0b00000001 in the high one.
+	bank[17*16+2] = 0x81
+	bank[17*16+8+2] = 0x01
+	pal := color.Palette{color.Black, color.White, color.Black, color.White}
+	img := chrSheet(bank, pal)
+	for x, want := range map[int]uint8{8: 1, 9: 0, 15: 3} {
+		if got := img.ColorIndexAt(x, 10); got != want {
+			t.Errorf("pixel (%d,10) = %d, want %d", x, got, want)
+		}
+	}
+}
+
+func TestParseBankRange(t *testing.T) {
+	for _, tc := range []struct {
+		spec        string
+		first, last int
+		ok          bool
+	}{
+		{"", 0, 7, true},
+		{"3", 3, 3, true},
+		{"2-5", 2, 5, true},
+		{"5-2", 0, 0, false},
+		{"8", 0, 0, false},
+		{"x", 0, 0, false},
+	} {
+		first, last, err := parseBankRange(tc.spec, 8)
+		if (err == nil) != tc.ok || tc.ok && (first != tc.first || last != tc.last) {
+			t.Errorf("%q: %d-%d, %v", tc.spec, first, last, err)
+		}
+	}
+}
+
+func TestParseCHRPalette(t *testing.T) {
+	if _, err := parseCHRPalette("gray"); err != nil {
+		t.Error(err)
+	}
+	pal, err := parseCHRPalette("0F,16,27,30")
+	if err != nil || len(pal) != 4 {
+		t.Fatalf("custom palette: %v", err)
+	}
+	for _, bad := range []string{"purple", "0F,16,27", "0F,16,27,40"} {
+		if _, err := parseCHRPalette(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
@@ -1,122 +1,303 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/yoshiomiyamaegones/pkg/cartridge"
 	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
-	"github.com/yoshiomiyamaegones/pkg/logger"
 )
 
+// report is everything rom_analyzer finds out about one ROM; it is both
+// printed as text and, with -json, encoded for other tools.
+type report struct {
+	File   string `json:"file"`
+	Entry  string `json:"entry,omitempty"` // name inside an archive
+	Format string `json:"format"`          // "iNES" or "NES 2.0"
+	Header string `json:"header"`          // 16 bytes, hex
+
+	Mapper     uint8  `json:"mapper"`
+	Submapper  uint8  `json:"submapper"`
+	Mirroring  string `json:"mirroring"`
+	Battery    bool   `json:"battery"`
+	Trainer    bool   `json:"trainer"`
+	PRGROMSize int    `json:"prgRomSize"`
+	CHRROMSize int    `json:"chrRomSize"`
+	CHRRAMSize int    `json:"chrRamSize"`
+	PRGRAMSize int    `json:"prgRamSize"`
+
+	// Board is the mapper's registered name and BoardFeatures what its
+	// boards can have: battery, audio, irq (see mapper.Board).
+	Board         string   `json:"board,omitempty"`
+	BoardFeatures []string `json:"boardFeatures,omitempty"`
+
+	Hashes hashes  `json:"hashes"`
+	Game   *dbGame `json:"game,omitempty"`
+
+	Problems []string `json:"problems"`
+
+	// MMC3 initial PRG banks (mapper 4 only).
+	MMC3PRGBanks []int `json:"mmc3PrgBanks,omitempty"`
+}
+
+type hashes struct {
+	PRGCRC32 string `json:"prgCrc32"`
+	CHRCRC32 string `json:"chrCrc32"`
+	ROMCRC32 string `json:"romCrc32"`
+	PRGSHA1  string `json:"prgSha1"`
+	CHRSHA1  string `json:"chrSha1"`
+	ROMSHA1  string `json:"romSha1"`
+}
+
+type dbGame struct {
+	Name       string `json:"name,omitempty"`
+	Region     string `json:"region,omitempty"`
+	Board      string `json:"board,omitempty"`
+	Mapper     uint8  `json:"mapper"`
+	Submapper  uint8  `json:"submapper"`
+	CHRRAMSize int    `json:"chrRamSize,omitempty"`
+}
+
+var mirroringNames = map[cartridge.MirroringMode]string{
+	cartridge.MirroringHorizontal:        "horizontal",
+	cartridge.MirroringVertical:          "vertical",
+	cartridge.MirroringSingleScreenLower: "single-screen lower",
+	cartridge.MirroringSingleScreenUpper: "single-screen upper",
+	cartridge.MirroringFourScreen:        "four-screen",
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: rom_analyzer <rom_file>")
+	jsonOut := flag.Bool("json", false, "print the analysis as JSON")
+	dbFile := flag.String("db", "", "nes20db or NesCartDB XML file to look the ROM up in, on top of the built-in database")
+	chrDir := flag.String("chr", "", "instead of analysing, write CHR ROM banks as PNG tile sheets to this directory")
+	chrBanks := flag.String("chr-banks", "", "4KB CHR banks to export, e.g. 3 or 0-7 (default all)")
+	chrPalette := flag.String("chr-palette", "gray", "CHR sheet colours: gray, red, green, blue or four hex colour indices (0F,16,27,30)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: rom_analyzer [-json] [-db nes20db.xml] <rom_file>")
+		fmt.Fprintln(os.Stderr, "       rom_analyzer -chr <dir> [-chr-banks N-M] [-chr-palette P] <rom_file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
+	romFile := flag.Arg(0)
 
-	romFile := os.Args[1]
+	if *dbFile != "" {
+		f, err := os.Open(*dbFile)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		_, err = cartridge.LoadGameDB(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Failed to load database: %v", err)
+		}
+	}
 
-	// Load cartridge
-	file, err := os.Open(romFile)
+	data, err := os.ReadFile(romFile)
 	if err != nil {
-		log.Fatalf("Failed to open ROM file: %v", err)
+		log.Fatalf("Failed to read ROM file: %v", err)
 	}
-	defer file.Close()
-
-	cart, err := cartridge.LoadFromReader(file)
+	entries, err := cartridge.FindROMs(data)
 	if err != nil {
 		log.Fatalf("Failed to load ROM: %v", err)
 	}
 
-	// Display ROM information
-	logger.LogInfo("=== ROM Analysis ===\n")
-	logger.LogInfo("File: %s\n", romFile)
-	logger.LogInfo("\n=== Header Information ===\n")
-	logger.LogInfo("Magic: %s (0x%02X%02X%02X%02X)\n",
-		string(cart.Header.Magic[:]), cart.Header.Magic[0], cart.Header.Magic[1], cart.Header.Magic[2], cart.Header.Magic[3])
-	logger.LogInfo("PRG ROM Size: %d units (%d KB)\n", cart.Header.PRGROMSize, int(cart.Header.PRGROMSize)*16)
-	logger.LogInfo("CHR ROM Size: %d units (%d KB)\n", cart.Header.CHRROMSize, int(cart.Header.CHRROMSize)*8)
-	logger.LogInfo("Flags6: 0x%02X\n", cart.Header.Flags6)
-	logger.LogInfo("Flags7: 0x%02X\n", cart.Header.Flags7)
-	logger.LogInfo("Flags8: 0x%02X\n", cart.Header.Flags8)
-	logger.LogInfo("Flags9: 0x%02X\n", cart.Header.Flags9)
-	logger.LogInfo("Flags10: 0x%02X\n", cart.Header.Flags10)
-
-	// Mapper information
-	mapperNumber := (cart.Header.Flags6 >> 4) | (cart.Header.Flags7 & 0xF0)
-	logger.LogInfo("\n=== Mapper Information ===\n")
-	logger.LogInfo("Mapper Number: %d\n", mapperNumber)
-
-	// Additional flags
-	logger.LogInfo("\n=== ROM Configuration ===\n")
-	logger.LogInfo("Trainer Present: %v\n", cart.Header.Flags6&0x04 != 0)
-	logger.LogInfo("Battery Backed: %v\n", cart.Header.Flags6&0x02 != 0)
-	logger.LogInfo("Four Screen VRAM: %v\n", cart.Header.Flags6&0x08 != 0)
-
-	if cart.Header.Flags6&0x08 != 0 {
-		logger.LogInfo("Mirroring: Four Screen\n")
-	} else if cart.Header.Flags6&0x01 != 0 {
-		logger.LogInfo("Mirroring: Vertical\n")
-	} else {
-		logger.LogInfo("Mirroring: Horizontal\n")
-	}
-
-	// Memory sizes
-	logger.LogInfo("\n=== Memory Configuration ===\n")
-	logger.LogInfo("PRG ROM: %d bytes (0x%04X)\n", len(cart.PRGROM), len(cart.PRGROM))
-	if len(cart.CHRROM) > 0 {
-		logger.LogInfo("CHR ROM: %d bytes (0x%04X)\n", len(cart.CHRROM), len(cart.CHRROM))
-	}
-	if len(cart.CHRRAM) > 0 {
-		logger.LogInfo("CHR RAM: %d bytes (0x%04X)\n", len(cart.CHRRAM), len(cart.CHRRAM))
-	}
-	if len(cart.PRGRAM) > 0 {
-		logger.LogInfo("PRG RAM: %d bytes (0x%04X)\n", len(cart.PRGRAM), len(cart.PRGRAM))
-	}
-
-	// Mapper specific information
-	if mapperNumber == 4 {
-		logger.LogInfo("\n=== MMC3 (Mapper 4) Specific Information ===\n")
-		if mapper4, ok := cart.Mapper.(*mapper.Mapper4); ok {
-			// Get current bank configuration
-			banks := mapper4.GetCurrentPRGBanks()
-			logger.LogInfo("Initial PRG Bank Configuration:\n")
-			logger.LogInfo("  $8000-$9FFF: Bank %d\n", banks[0])
-			logger.LogInfo("  $A000-$BFFF: Bank %d\n", banks[1])
-			logger.LogInfo("  $C000-$DFFF: Bank %d (fixed)\n", banks[2])
-			logger.LogInfo("  $E000-$FFFF: Bank %d (fixed)\n", banks[3])
-
-			logger.LogInfo("Bank Counts:\n")
-			prgBankCount := len(cart.PRGROM) / 8192
-			logger.LogInfo("  PRG Banks (8KB each): %d\n", prgBankCount)
-
-			if len(cart.CHRROM) > 0 {
-				chrBankCount := len(cart.CHRROM) / 1024
-				logger.LogInfo("  CHR Banks (1KB each): %d\n", chrBankCount)
-			} else {
-				chrBankCount := len(cart.CHRRAM) / 1024
-				logger.LogInfo("  CHR RAM Banks (1KB each): %d\n", chrBankCount)
+	if *chrDir != "" {
+		pal, err := parseCHRPalette(*chrPalette)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, e := range entries {
+			cart, err := cartridge.LoadEntry(e)
+			if err != nil {
+				log.Fatalf("Failed to load ROM %s: %v", e.Name, err)
 			}
+			name := e.Name
+			if name == "" {
+				name = romFile
+			}
+			name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+			paths, err := exportCHR(cart.CHRROM, *chrDir, name, *chrBanks, pal)
+			for _, p := range paths {
+				fmt.Println(p)
+			}
+			if err != nil {
+				log.Fatalf("CHR export: %v", err)
+			}
+		}
+		return
+	}
+
+	// An archive may hold several ROMs; analyse them all.
+	var reports []report
+	for _, e := range entries {
+		cart, err := cartridge.LoadEntry(e)
+		if err != nil {
+			log.Fatalf("Failed to load ROM %s: %v", e.Name, err)
 		}
+		r := analyze(cart, e.Data)
+		r.File = romFile
+		r.Entry = e.Name
+		reports = append(reports, r)
 	}
 
-	logger.LogInfo("\n=== Raw Header Dump ===\n")
-	logger.LogInfo("00 01 02 03 04 05 06 07 08 09 0A 0B 0C 0D 0E 0F\n")
-	headerBytes := []uint8{
-		cart.Header.Magic[0], cart.Header.Magic[1], cart.Header.Magic[2], cart.Header.Magic[3],
-		cart.Header.PRGROMSize, cart.Header.CHRROMSize, cart.Header.Flags6, cart.Header.Flags7,
-		cart.Header.Flags8, cart.Header.Flags9, cart.Header.Flags10,
-		cart.Header.Padding[0], cart.Header.Padding[1], cart.Header.Padding[2], cart.Header.Padding[3], cart.Header.Padding[4],
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		var v interface{} = reports
+		if len(reports) == 1 {
+			v = reports[0]
+		}
+		if err := enc.Encode(v); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	for i, b := range headerBytes {
-		logger.LogInfo("%02X ", b)
-		if (i+1)%16 == 0 {
-			logger.LogInfo("\n")
+	for i, r := range reports {
+		if i > 0 {
+			fmt.Println()
 		}
+		printReport(r)
 	}
-	if len(headerBytes)%16 != 0 {
-		logger.LogInfo("\n")
+}
+
+func analyze(cart *cartridge.Cartridge, image []byte) report {
+	h := cart.Header
+	r := report{
+		Format:     "iNES",
+		Mapper:     cart.MapperNumber(),
+		Submapper:  cart.Submapper(),
+		Mirroring:  mirroringNames[cart.GetMirroring()],
+		Battery:    cart.HasBattery(),
+		Trainer:    h.Flags6&0x04 != 0,
+		PRGROMSize: len(cart.PRGROM),
+		CHRROMSize: len(cart.CHRROM),
+		CHRRAMSize: len(cart.CHRRAM),
+		PRGRAMSize: len(cart.PRGRAM),
+		Problems:   cartridge.ImageProblems(image, cart),
 	}
+	if h.IsNES20() {
+		r.Format = "NES 2.0"
+	}
+	if b, ok := mapper.Lookup(r.Mapper); ok {
+		r.Board = b.Name
+		for _, f := range []struct {
+			has  bool
+			name string
+		}{{b.Battery, "battery"}, {b.Audio, "audio"}, {b.IRQ, "irq"}} {
+			if f.has {
+				r.BoardFeatures = append(r.BoardFeatures, f.name)
+			}
+		}
+	}
+	if r.Problems == nil {
+		r.Problems = []string{}
+	}
+	r.Header = fmt.Sprintf("% X", append(append(h.Magic[:], h.PRGROMSize, h.CHRROMSize, h.Flags6, h.Flags7,
+		h.Flags8, h.Flags9, h.Flags10), h.Padding[:]...))
+
+	sums := cart.Hashes()
+	r.Hashes = hashes{
+		PRGCRC32: fmt.Sprintf("%08X", sums.PRGCRC32),
+		CHRCRC32: fmt.Sprintf("%08X", sums.CHRCRC32),
+		ROMCRC32: fmt.Sprintf("%08X", sums.ROMCRC32),
+		PRGSHA1:  sums.PRGSHA1,
+		CHRSHA1:  sums.CHRSHA1,
+		ROMSHA1:  sums.ROMSHA1,
+	}
+	if info, ok := cartridge.LookupGame(sums.ROMCRC32); ok {
+		r.Game = &dbGame{
+			Name:       info.Name,
+			Region:     info.Region,
+			Board:      info.Board,
+			Mapper:     info.Mapper,
+			Submapper:  info.Submapper,
+			CHRRAMSize: info.CHRRAMSize,
+		}
+	}
+
+	if m4, ok := cart.Mapper.(*mapper.Mapper4); ok {
+		for _, b := range m4.GetCurrentPRGBanks() {
+			r.MMC3PRGBanks = append(r.MMC3PRGBanks, int(b))
+		}
+	}
+	return r
+}
+
+func printReport(r report) {
+	fmt.Println("=== ROM Analysis ===")
+	fmt.Printf("File: %s\n", r.File)
+	if r.Entry != "" {
+		fmt.Printf("Entry: %s\n", r.Entry)
+	}
+	fmt.Printf("Format: %s\n", r.Format)
+
+	fmt.Println("\n=== Mapper Information ===")
+	fmt.Printf("Mapper Number: %d\n", r.Mapper)
+	fmt.Printf("Submapper: %d\n", r.Submapper)
+	if r.Board != "" {
+		fmt.Printf("Board: %s", r.Board)
+		if len(r.BoardFeatures) > 0 {
+			fmt.Printf(" (%s)", strings.Join(r.BoardFeatures, ", "))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Mirroring: %s\n", r.Mirroring)
+	fmt.Printf("Trainer Present: %v\n", r.Trainer)
+	fmt.Printf("Battery Backed: %v\n", r.Battery)
+
+	fmt.Println("\n=== Memory Configuration ===")
+	fmt.Printf("PRG ROM: %d bytes (%d KB)\n", r.PRGROMSize, r.PRGROMSize/1024)
+	if r.CHRROMSize > 0 {
+		fmt.Printf("CHR ROM: %d bytes (%d KB)\n", r.CHRROMSize, r.CHRROMSize/1024)
+	}
+	if r.CHRRAMSize > 0 {
+		fmt.Printf("CHR RAM: %d bytes (%d KB)\n", r.CHRRAMSize, r.CHRRAMSize/1024)
+	}
+	if r.PRGRAMSize > 0 {
+		fmt.Printf("PRG RAM: %d bytes (%d KB)\n", r.PRGRAMSize, r.PRGRAMSize/1024)
+	}
+	if len(r.MMC3PRGBanks) == 4 {
+		fmt.Printf("MMC3 initial PRG banks: %d %d %d (fixed) %d (fixed)\n",
+			r.MMC3PRGBanks[0], r.MMC3PRGBanks[1], r.MMC3PRGBanks[2], r.MMC3PRGBanks[3])
+	}
+
+	fmt.Println("\n=== Hashes ===")
+	fmt.Printf("PRG: CRC32 %s  SHA1 %s\n", r.Hashes.PRGCRC32, r.Hashes.PRGSHA1)
+	fmt.Printf("CHR: CRC32 %s  SHA1 %s\n", r.Hashes.CHRCRC32, r.Hashes.CHRSHA1)
+	fmt.Printf("ROM: CRC32 %s  SHA1 %s\n", r.Hashes.ROMCRC32, r.Hashes.ROMSHA1)
+
+	fmt.Println("\n=== Game Database ===")
+	if g := r.Game; g != nil {
+		fmt.Printf("Name: %s\n", g.Name)
+		if g.Region != "" {
+			fmt.Printf("Region: %s\n", g.Region)
+		}
+		if g.Board != "" {
+			fmt.Printf("Board: %s\n", g.Board)
+		}
+		fmt.Printf("Mapper: %d.%d\n", g.Mapper, g.Submapper)
+	} else {
+		fmt.Println("Not found")
+	}
+
+	fmt.Println("\n=== Problems ===")
+	if len(r.Problems) == 0 {
+		fmt.Println("None")
+	}
+	for _, p := range r.Problems {
+		fmt.Printf("- %s\n", p)
+	}
+
+	fmt.Println("\n=== Raw Header Dump ===")
+	fmt.Println("00 01 02 03 04 05 06 07 08 09 0A 0B 0C 0D 0E 0F")
+	fmt.Println(r.Header)
 }
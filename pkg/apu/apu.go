@@ -20,6 +20,22 @@ type ExpansionAudio interface {
 	AudioSample() float32
 }
 
+// AudioChipNamer is implemented by an ExpansionAudio that says which chip
+// it is (ChipFME7, ...), picking its level from ExpansionLevels.
+type AudioChipNamer interface {
+	AudioChip() string
+}
+
+// Expansion chip names, as AudioChip returns them and ExpansionLevels is
+// keyed.
+const (
+	ChipFME7 = "5b" // Sunsoft 5B, on FME-7 boards
+	ChipVRC6 = "vrc6"
+	ChipMMC5 = "mmc5"
+	ChipFDS  = "fds"  // the Disk System's wavetable channel
+	ChipN163 = "n163" // Namco 163 wavetable channels
+)
+
 // APU represents the Audio Processing Unit
 type APU struct {
 	// Pulse channels
@@ -40,6 +56,9 @@ type APU struct {
 	FrameStep        int
 	FrameIRQ         bool
 	FrameCycleCount  int // Counter for frame sequencer timing
+	// FrameResetDelay counts down the CPU cycles until a $4017 write
+	// restarts the sequencer; 0 when none is pending.
+	FrameResetDelay int
 
 	// Cycle counter
 	Cycles uint64
@@ -53,30 +72,55 @@ type APU struct {
 	// Memory interface for DMC
 	Memory MemoryReader
 
-	// Expansion is the optional cartridge-side sound chip (FME-7,
-	// VRC6, etc.). nil for vanilla 2A03 carts.
-	Expansion ExpansionAudio
+	// Sources are the sound chips mixed in on top of the 2A03, each at its
+	// own gain (see mixer.go). SetExpansionAudio replaces them with the
+	// cartridge's chip; empty for vanilla 2A03 carts.
+	Sources []MixSource
+
+	// ExpansionLevels is the gain of each expansion chip by name (ChipFME7,
+	// ...); a chip without an entry, or that doesn't name itself, plays at
+	// 1, its native level. Use SetExpansionLevel to change a playing one.
+	ExpansionLevels map[string]float32
 
 	// ExpansionMuted suppresses the expansion-audio contribution to the
 	// mixer without disconnecting the chip — the chip keeps running so
 	// state stays in sync, just the audible part is dropped.
 	ExpansionMuted bool
 
+	// NESAudio mixes as a front-loading NES does: its cartridge slot sends
+	// a chip's sound to the expansion port underneath instead of the
+	// output, so only the 2A03 is heard. The zero value is a Famicom.
+	NESAudio bool
+
 	// ChannelMute zeros a channel's contribution to the mixer without
 	// touching timer / sequencer state. Indexed by the channel ID
 	// constants (ChannelPulse1..ChannelDMC); use ToggleChannelMute.
 	ChannelMute [NumChannels]bool
 
+	// Taps, when set, records every channel's mixer input (see
+	// ChannelTaps) for an oscilloscope view. Not saved with state.
+	Taps *ChannelTaps
+
 	// FilterEnabled toggles the NES analog filter chain on the mixer
 	// output (default on; ToggleFilter flips it for A/B comparison).
 	FilterEnabled bool
 
+	// Volume is the master gain the mixer applies last, 0 (silent) to 1
+	// (the default, full scale); Muted silences the output without
+	// forgetting it. Front-end settings, so neither is saved with state.
+	Volume float32
+	Muted  bool
+
+	// region picks the period tables; see SetRegion. A setting, not
+	// saved with state.
+	region Region
+
 	hpfPrevIn  float32
 	hpfPrevOut float32
 	lpfPrevOut float32
 }
 
-// Channel IDs index into APU.ChannelMute and ChannelNames.
+// Channel IDs index into APU.ChannelMute, ChannelTaps and ChannelNames.
 const (
 	ChannelPulse1 = iota
 	ChannelPulse2
@@ -105,11 +149,28 @@ func (a *APU) ToggleFilter() bool {
 	return a.FilterEnabled
 }
 
+// SetVolume sets the master volume, clamped to 0-1, and returns it.
+func (a *APU) SetVolume(v float32) float32 {
+	if v < 0 {
+		v = 0
+	} else if v > 1 {
+		v = 1
+	}
+	a.Volume = v
+	return v
+}
+
+// ToggleMute flips the master mute and returns the new state.
+func (a *APU) ToggleMute() bool {
+	a.Muted = !a.Muted
+	return a.Muted
+}
+
 // ToggleExpansionMute flips the expansion-audio mixer mute and returns
 // the new muted state (true = expansion silenced). Returns false with
 // no effect when the cartridge has no expansion chip.
 func (a *APU) ToggleExpansionMute() (muted, hasExpansion bool) {
-	if a.Expansion == nil {
+	if len(a.Sources) == 0 {
 		return false, false
 	}
 	a.ExpansionMuted = !a.ExpansionMuted
@@ -156,11 +217,15 @@ type NoiseChannel struct {
 	Length     LengthCounter
 	Envelope   EnvelopeGenerator
 	Timer      uint16
-	TimerValue uint16
+	TimerValue uint16 // CPU cycles per LFSR clock, from Period and the region
+	Period     uint8  // $400E period index
 	ShiftReg   uint16
 	Mode       bool
 }
 
+// noisePowerUp is the noise LFSR's value at power-up and reset.
+const noisePowerUp = 1
+
 // DMCChannel represents the Delta Modulation Channel
 type DMCChannel struct {
 	Enabled        bool
@@ -226,6 +291,7 @@ func New() *APU {
 	apu := &APU{
 		Output:        make([]float32, 0, 4096),
 		FilterEnabled: true,
+		Volume:        1,
 	}
 	apu.initializeChannels()
 	return apu
@@ -236,12 +302,39 @@ func (a *APU) SetMemory(mem MemoryReader) {
 	a.Memory = mem
 }
 
-// SetExpansionAudio attaches a cartridge-side audio chip; nil clears it.
+// SetExpansionAudio attaches a cartridge-side audio chip at its level in
+// ExpansionLevels, replacing any Sources; nil clears them.
 func (a *APU) SetExpansionAudio(src ExpansionAudio) {
-	a.Expansion = src
+	a.Sources = a.Sources[:0]
+	if src != nil {
+		a.AddSource(src)
+	}
+}
+
+// SoftReset models the reset button. Per NESdev's APU power-up notes the
+// 2A03 reset silences every channel (as a $4015 write of 0 would), restarts
+// the frame counter as if $4017 were rewritten with its last value, zeroes
+// the triangle sequencer and keeps only bit 0 of the DMC output level;
+// channel registers and the noise LFSR are left as they were.
+func (a *APU) SoftReset() {
+	a.writeStatus(0)
+	a.writeFrameCounter(a.FrameCounter)
+	a.Triangle.Sequence = 0
+	a.DMC.LoadCounter &= 1
+}
+
+// PowerOn is Reset plus the sampling and filter state Reset keeps: the
+// frame sequencer's cycle count, the output sample phase and the analog
+// filter history. A power cycle then produces the same samples as a fresh
+// APU. User settings (volume, mutes, filter switch) are kept.
+func (a *APU) PowerOn() {
+	a.FrameCycleCount = 0
+	a.SampleAccumulator = 0
+	a.hpfPrevIn, a.hpfPrevOut, a.lpfPrevOut = 0, 0, 0
+	a.Reset()
 }
 
-// Reset resets the APU to initial state
+// Reset resets the APU to its power-on state: every register cleared.
 func (a *APU) Reset() {
 	a.Pulse1 = PulseChannel{}
 	a.Pulse2 = PulseChannel{}
@@ -251,6 +344,7 @@ func (a *APU) Reset() {
 	a.FrameCounter = 0
 	a.FrameStep = 0
 	a.FrameIRQ = false
+	a.FrameResetDelay = 0
 	a.Cycles = 0
 	a.initializeChannels()
 }
@@ -277,6 +371,15 @@ func (a *APU) StepN(n int) {
 	for i := 0; i < n; i++ {
 		cycles++
 
+		// A $4017 write restarts the sequence a few cycles after it.
+		if a.FrameResetDelay > 0 {
+			a.FrameResetDelay--
+			if a.FrameResetDelay == 0 {
+				frameCycleCount = 0
+				a.FrameStep = 0
+			}
+		}
+
 		// Frame counter runs at 240Hz (CPU speed / 7457.5)
 		frameCycleCount++
 		if frameCycleCount >= 7458 {
@@ -451,6 +554,7 @@ type apuSnapshot struct {
 	FrameStep         int
 	FrameIRQ          bool
 	FrameCycleCount   int
+	FrameResetDelay   int
 	Cycles            uint64
 	SampleAccumulator float64
 
@@ -473,6 +577,7 @@ func (a *APU) SaveState(w io.Writer) error {
 		FrameStep:         a.FrameStep,
 		FrameIRQ:          a.FrameIRQ,
 		FrameCycleCount:   a.FrameCycleCount,
+		FrameResetDelay:   a.FrameResetDelay,
 		Cycles:            a.Cycles,
 		SampleAccumulator: a.SampleAccumulator,
 		HpfPrevIn:         a.hpfPrevIn,
@@ -488,6 +593,36 @@ func (a *APU) SaveState(w io.Writer) error {
 	return err
 }
 
+// AppendState appends the part of the APU state the CPU can observe —
+// frame sequencer, length counters and the DMC's address, length and
+// flags, which drive $4015 reads, IRQs and DMA — to b without allocating.
+// Unlike SaveState it leaves out the waveform generators and filters:
+// NES.StateHash uses it every frame, and a gob encode would dominate
+// the cost of the hash.
+func (a *APU) AppendState(b []byte) []byte {
+	b = append(b, a.FrameCounter)
+	b = binary.LittleEndian.AppendUint64(b, uint64(a.FrameStep))
+	b = appendBool(b, a.FrameIRQ)
+	b = binary.LittleEndian.AppendUint64(b, uint64(a.FrameCycleCount))
+	b = append(b, uint8(a.FrameResetDelay))
+	b = binary.LittleEndian.AppendUint64(b, a.Cycles)
+	b = append(b, a.Pulse1.Length.Value, a.Pulse2.Length.Value,
+		a.Triangle.Length.Value, a.Noise.Length.Value)
+	b = binary.LittleEndian.AppendUint16(b, a.DMC.CurrentAddress)
+	b = binary.LittleEndian.AppendUint16(b, a.DMC.CurrentLength)
+	b = appendBool(b, a.DMC.Enabled)
+	b = appendBool(b, a.DMC.IRQEnabled)
+	b = appendBool(b, a.DMC.InterruptFlag)
+	return appendBool(b, a.DMC.BufferEmpty)
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
 // LoadState restores APU state written by SaveState. Reads the gob payload
 // into a bounded buffer so gob.Decoder doesn't over-consume the underlying
 // reader (which would misalign the cartridge/mapper sections that follow).
@@ -508,6 +643,7 @@ func (a *APU) LoadState(r io.Reader) error {
 	a.Triangle, a.Noise, a.DMC = s.Triangle, s.Noise, s.DMC
 	a.FrameCounter, a.FrameStep = s.FrameCounter, s.FrameStep
 	a.FrameIRQ, a.FrameCycleCount = s.FrameIRQ, s.FrameCycleCount
+	a.FrameResetDelay = s.FrameResetDelay
 	a.Cycles = s.Cycles
 	a.SampleAccumulator = s.SampleAccumulator
 	a.hpfPrevIn, a.hpfPrevOut = s.HpfPrevIn, s.HpfPrevOut
@@ -125,8 +125,139 @@ func TestNoiseChannelRegisters(t *testing.T) {
 	if !apu.Noise.Mode {
 		t.Error("Noise mode should be true")
 	}
-	if apu.Noise.TimerValue != noisePeriods[15] {
-		t.Errorf("Expected timer=%d, got %d", noisePeriods[15], apu.Noise.TimerValue)
+	if apu.Noise.TimerValue != noisePeriods[RegionNTSC][15] {
+		t.Errorf("Expected timer=%d, got %d", noisePeriods[RegionNTSC][15], apu.Noise.TimerValue)
+	}
+}
+
+// The noise periods follow the region, and the LFSR powers up as 1.
+func TestNoiseRegion(t *testing.T) {
+	apu := createTestAPU()
+	if apu.Noise.ShiftReg != 1 || apu.Noise.TimerValue != 4 {
+		t.Fatalf("power-up LFSR %d, period %d; want 1 and 4", apu.Noise.ShiftReg, apu.Noise.TimerValue)
+	}
+	apu.WriteRegister(0x400E, 0x05)
+	if apu.Noise.TimerValue != 96 {
+		t.Errorf("NTSC period 5 = %d, want 96", apu.Noise.TimerValue)
+	}
+	apu.SetRegion(RegionPAL)
+	if apu.Noise.TimerValue != 88 {
+		t.Errorf("period 5 after switching to PAL = %d, want 88", apu.Noise.TimerValue)
+	}
+	apu.WriteRegister(0x400E, 0x0E)
+	if apu.Noise.TimerValue != 1890 {
+		t.Errorf("PAL period 14 = %d, want 1890", apu.Noise.TimerValue)
+	}
+
+	// The LFSR is clocked every period's worth of CPU cycles.
+	for _, r := range []Region{RegionNTSC, RegionPAL} {
+		apu := createTestAPU()
+		apu.SetRegion(r)
+		apu.WriteRegister(0x4015, 0x08)
+		apu.WriteRegister(0x400E, 0x02)
+		last, clocks := 0, 0
+		for c := 1; c <= 1000; c++ {
+			before := apu.Noise.ShiftReg
+			apu.StepN(1)
+			if apu.Noise.ShiftReg == before {
+				continue
+			}
+			if clocks > 0 && c-last != int(noisePeriods[r][2]) {
+				t.Fatalf("%v: LFSR clocked %d cycles after the last, want %d", r, c-last, noisePeriods[r][2])
+			}
+			last = c
+			clocks++
+		}
+		if want := 1000 / int(noisePeriods[r][2]); clocks < want {
+			t.Errorf("%v: %d LFSR clocks in 1000 cycles, want %d", r, clocks, want)
+		}
+	}
+}
+
+func TestParseRegion(t *testing.T) {
+	for name, want := range map[string]Region{"ntsc": RegionNTSC, "PAL": RegionPAL} {
+		if r, err := ParseRegion(name); err != nil || r != want {
+			t.Errorf("ParseRegion(%q) = %v, %v; want %v", name, r, err, want)
+		}
+	}
+	if _, err := ParseRegion("dendy"); err == nil {
+		t.Error("ParseRegion(dendy) succeeded")
+	}
+}
+
+// noiseBits clocks the LFSR n times in the given mode and returns the
+// channel's gate (1 = sounding, ShiftReg bit 0 clear) after each clock.
+func noiseBits(apu *APU, short bool, n int) []float64 {
+	apu.Noise.Mode = short
+	apu.Noise.TimerValue = 1
+	bits := make([]float64, n)
+	for i := range bits {
+		apu.stepNoise()
+		if apu.Noise.ShiftReg&1 == 0 {
+			bits[i] = 1
+		}
+	}
+	return bits
+}
+
+// Mode 1 from the power-up state is the 93-step sequence: its spectrum is
+// a line spectrum with the fundamental at 1/93 of the LFSR clock, and no
+// energy between the harmonics.
+func TestNoisePeriodicSpectrum(t *testing.T) {
+	const period, repeats = 93, 8
+	apu := createTestAPU()
+	bits := noiseBits(apu, true, period*repeats)
+	for i := period; i < len(bits); i++ {
+		if bits[i] != bits[i-period] {
+			t.Fatalf("mode 1 sequence differs from 93 clocks before at clock %d", i)
+		}
+	}
+	if apu.Noise.ShiftReg != 1 {
+		t.Errorf("LFSR after %d mode 1 clocks = $%04X, want back at 1", period*repeats, apu.Noise.ShiftReg)
+	}
+
+	// DFT over whole periods: harmonic h of the 93-step period is in
+	// bin h*repeats. The mean (bin 0) is skipped.
+	n := len(bits)
+	power := make([]float64, n/2)
+	for k := 1; k < n/2; k++ {
+		var re, im float64
+		for i, b := range bits {
+			phase := 2 * math.Pi * float64(k*i) / float64(n)
+			re += b * math.Cos(phase)
+			im -= b * math.Sin(phase)
+		}
+		power[k] = re*re + im*im
+	}
+	// A 31-step cycle would have energy only on every third harmonic.
+	var harmonics, between, notThird float64
+	for k := 1; k < n/2; k++ {
+		switch {
+		case k%repeats != 0:
+			between += power[k]
+		case (k/repeats)%3 != 0:
+			notThird += power[k]
+			harmonics += power[k]
+		default:
+			harmonics += power[k]
+		}
+	}
+	if harmonics == 0 || between > harmonics*1e-9 {
+		t.Errorf("mode 1 spectrum: %g on the harmonics of 1/93, %g between them", harmonics, between)
+	}
+	if notThird == 0 {
+		t.Error("mode 1 spectrum has only the harmonics of a 31-step cycle")
+	}
+
+	// Mode 0 is the long sequence: 32767 clocks before it repeats.
+	apu = createTestAPU()
+	noiseBits(apu, false, 32766)
+	if apu.Noise.ShiftReg == 1 {
+		t.Error("mode 0 LFSR repeated before 32767 clocks")
+	}
+	noiseBits(apu, false, 1)
+	if apu.Noise.ShiftReg != 1 {
+		t.Errorf("mode 0 LFSR after 32767 clocks = $%04X, want back at 1", apu.Noise.ShiftReg)
 	}
 }
 
@@ -249,6 +380,48 @@ func TestFrameCounter(t *testing.T) {
 	}
 }
 
+// A $4017 write restarts the sequencer 3 cycles later on an even cycle,
+// 4 on an odd one, and in 5-step mode clocks the frame units at once.
+func TestFrameCounterWrite(t *testing.T) {
+	for _, tc := range []struct {
+		cycles uint64
+		delay  int
+	}{{100, 3}, {101, 4}} {
+		apu := createTestAPU()
+		apu.StepN(5000)
+		apu.Cycles = tc.cycles
+		apu.FrameStep = 2
+		apu.WriteRegister(0x4017, 0x00)
+		for i := 1; i < tc.delay; i++ {
+			apu.StepN(1)
+			if apu.FrameStep != 2 || apu.FrameCycleCount != 5000+i {
+				t.Fatalf("write on cycle %d: %d cycles on, step %d, count %d; want it unreset",
+					tc.cycles, i, apu.FrameStep, apu.FrameCycleCount)
+			}
+		}
+		apu.StepN(1)
+		if apu.FrameStep != 0 || apu.FrameCycleCount != 1 || apu.FrameResetDelay != 0 {
+			t.Errorf("write on cycle %d: %d cycles on, step %d, count %d; want the sequence restarted",
+				tc.cycles, tc.delay, apu.FrameStep, apu.FrameCycleCount)
+		}
+	}
+
+	apu := createTestAPU()
+	apu.WriteRegister(0x4015, 0x05) // Enable pulse 1 and triangle
+	apu.WriteRegister(0x4003, 0x08) // Length = 254
+	apu.WriteRegister(0x4008, 0x05) // Linear counter reload = 5
+	apu.WriteRegister(0x400B, 0x08)
+	apu.WriteRegister(0x4017, 0x00)
+	if apu.Pulse1.Length.Value != 254 || apu.Triangle.LinearCounter != 0 {
+		t.Errorf("4-step write clocked the frame units: length %d, linear %d",
+			apu.Pulse1.Length.Value, apu.Triangle.LinearCounter)
+	}
+	apu.WriteRegister(0x4017, 0x80)
+	if apu.Pulse1.Length.Value != 253 || apu.Triangle.LinearCounter != 5 {
+		t.Errorf("5-step write: length %d, linear %d; want 253 and 5", apu.Pulse1.Length.Value, apu.Triangle.LinearCounter)
+	}
+}
+
 // Test channel output
 func TestChannelOutput(t *testing.T) {
 	apu := createTestAPU()
@@ -305,6 +478,82 @@ func TestAudioMixing(t *testing.T) {
 	}
 }
 
+// TestMasterVolume checks the master gain is the last stage of the mix:
+// scaled, clamped to full scale, and zeroed outright when muted.
+func TestMasterVolume(t *testing.T) {
+	apu := createTestAPU()
+	apu.FilterEnabled = false
+	apu.DMC.Enabled = true
+	apu.DMC.LoadCounter = 127 // mixes to ~0.57 before gain
+
+	if got := apu.mixChannels(); got != 1 {
+		t.Errorf("full volume: %f, want clamped to 1", got)
+	}
+	if v := apu.SetVolume(1.5); v != 1 {
+		t.Errorf("SetVolume(1.5) = %f, want 1", v)
+	}
+	apu.SetVolume(0.5)
+	if got := apu.mixChannels(); got < 0.55 || got > 0.6 {
+		t.Errorf("half volume: %f, want ~0.57", got)
+	}
+	if !apu.ToggleMute() {
+		t.Fatal("ToggleMute should report muted")
+	}
+	if got := apu.mixChannels(); got != 0 {
+		t.Errorf("muted: %f, want 0", got)
+	}
+	if apu.ToggleMute() || apu.Volume != 0.5 {
+		t.Errorf("unmute: muted=%v volume=%f, want volume kept", apu.Muted, apu.Volume)
+	}
+}
+
+// constChip is an expansion chip with a fixed output.
+type constChip struct {
+	level float32
+	chip  string
+}
+
+func (c constChip) AudioSample() float32 { return c.level }
+func (c constChip) AudioChip() string    { return c.chip }
+
+// TestExpansionMix checks each expansion source is summed at its own
+// level, and that NES mode and the mute leave only the 2A03.
+func TestExpansionMix(t *testing.T) {
+	apu := createTestAPU()
+	apu.FilterEnabled = false
+	apu.Volume = 0.5 // after outputGain, the mix itself
+
+	apu.ExpansionLevels = map[string]float32{ChipFME7: 0.5}
+	apu.SetExpansionAudio(constChip{0.2, ChipFME7})
+	apu.AddSource(constChip{0.1, ChipVRC6})
+	if got := apu.mixChannels(); math.Abs(float64(got-0.2)) > 1e-6 {
+		t.Errorf("mix = %f, want 0.2 (0.2×0.5 + 0.1×1)", got)
+	}
+	if g := apu.SetExpansionLevel(ChipVRC6, 3); g != MaxExpansionLevel {
+		t.Errorf("SetExpansionLevel(3) = %f, want clamped to %d", g, MaxExpansionLevel)
+	}
+	if got := apu.mixChannels(); math.Abs(float64(got-0.3)) > 1e-6 {
+		t.Errorf("mix = %f, want 0.3 with VRC6 at 2×", got)
+	}
+
+	apu.NESAudio = true
+	if got := apu.mixChannels(); got != 0 {
+		t.Errorf("NES mode: %f, want the silent 2A03 only", got)
+	}
+	apu.NESAudio = false
+	if muted, ok := apu.ToggleExpansionMute(); !muted || !ok {
+		t.Fatal("ToggleExpansionMute should mute")
+	}
+	if got := apu.mixChannels(); got != 0 {
+		t.Errorf("muted: %f, want 0", got)
+	}
+
+	apu.SetExpansionAudio(nil)
+	if _, ok := apu.ToggleExpansionMute(); ok || len(apu.Sources) != 0 {
+		t.Error("SetExpansionAudio(nil) should leave no sources")
+	}
+}
+
 // Test frequency calculation helper
 func TestFrequencyCalculation(t *testing.T) {
 	// Test known frequency
@@ -359,3 +608,133 @@ func TestAPUStep(t *testing.T) {
 		t.Error("Expected output buffer to have at least one sample after stepping")
 	}
 }
+
+// TestAPUSoftReset checks the reset button silences the channels but keeps
+// their register contents, unlike the power-on Reset.
+func TestAPUSoftReset(t *testing.T) {
+	apu := createTestAPU()
+	apu.WriteRegister(0x4015, 0x1F)
+	apu.WriteRegister(0x4000, 0xBF)
+	apu.WriteRegister(0x4003, 0x08)
+	apu.Triangle.Sequence = 7
+	apu.DMC.LoadCounter = 0x45
+
+	apu.SoftReset()
+
+	if apu.Pulse1.Enabled || apu.Pulse1.Length.Value != 0 {
+		t.Errorf("Pulse 1 enabled=%v length=%d, want silenced", apu.Pulse1.Enabled, apu.Pulse1.Length.Value)
+	}
+	if apu.Pulse1.Volume != 0x0F || apu.Pulse1.DutyCycle != 2 {
+		t.Errorf("Pulse 1 volume=%d duty=%d, want $4000 contents kept", apu.Pulse1.Volume, apu.Pulse1.DutyCycle)
+	}
+	if apu.Triangle.Sequence != 0 {
+		t.Errorf("Triangle sequence = %d, want 0", apu.Triangle.Sequence)
+	}
+	if apu.DMC.LoadCounter != 0x01 {
+		t.Errorf("DMC output = %#02x, want bit 0 only", apu.DMC.LoadCounter)
+	}
+}
+
+// addrRecorder is a MemoryReader that logs the addresses the DMC fetches.
+type addrRecorder []uint16
+
+func (r *addrRecorder) Read(addr uint16) uint8 {
+	*r = append(*r, addr)
+	return 0
+}
+
+// TestDMCSampleAddresses: $4012 = A starts the sample at $C000 + A*64, and
+// the fetch address wraps from $FFFF to $8000, not $0000.
+func TestDMCSampleAddresses(t *testing.T) {
+	apu := createTestAPU()
+	var reads addrRecorder
+	apu.SetMemory(&reads)
+	apu.WriteRegister(0x4010, 0x0F)
+	apu.WriteRegister(0x4012, 0xFF) // $FFC0
+	apu.WriteRegister(0x4013, 0x04) // 65 bytes
+	apu.WriteRegister(0x4015, 0x10)
+	for i := 0; i < 100000 && len(reads) < 65; i++ {
+		apu.Step()
+	}
+	if len(reads) != 65 {
+		t.Fatalf("fetched %d bytes, want 65", len(reads))
+	}
+	if reads[0] != 0xFFC0 || reads[63] != 0xFFFF || reads[64] != 0x8000 {
+		t.Errorf("fetched $%04X, ..., $%04X, $%04X; want $FFC0, ..., $FFFF, $8000", reads[0], reads[63], reads[64])
+	}
+}
+
+// TestChannelTaps: with Taps attached each output sample records every
+// channel's level, muted or not, and the buffers stay bounded.
+func TestChannelTaps(t *testing.T) {
+	apu := createTestAPU()
+	apu.Taps = &ChannelTaps{}
+	apu.WriteRegister(0x4015, 0x01)
+	apu.WriteRegister(0x4000, 0x7F) // 50% duty, constant volume 15
+	apu.WriteRegister(0x4002, 0xFD) // ~440 Hz
+	apu.WriteRegister(0x4003, 0x00)
+	apu.ToggleChannelMute(ChannelPulse1)
+	for i := 0; i < 29780; i++ {
+		apu.Step()
+	}
+
+	p1 := apu.Taps.Samples[ChannelPulse1]
+	if n := len(apu.Taps.Samples[ChannelDMC]); n != len(p1) || n == 0 || n > 2*TapLength {
+		t.Fatalf("DMC has %d samples, pulse 1 %d; want the same, bounded", n, len(p1))
+	}
+	var high, low bool
+	for _, v := range p1 {
+		high = high || v == 15
+		low = low || v == 0
+	}
+	if !high || !low {
+		t.Error("muted pulse 1 tap isn't a 0/15 square wave")
+	}
+	if got := apu.Taps.Last(ChannelPulse1, 10); len(got) != 10 {
+		t.Errorf("Last(10) returned %d samples", len(got))
+	}
+	apu.Taps.Reset()
+	if len(apu.Taps.Samples[ChannelPulse1]) != 0 {
+		t.Error("Reset kept samples")
+	}
+}
+
+func TestChannelState(t *testing.T) {
+	apu := createTestAPU()
+	apu.WriteRegister(0x4015, 0x05)
+	apu.WriteRegister(0x4000, 0x7A) // duty 1, constant volume 10
+	apu.WriteRegister(0x4002, 0xFD)
+	apu.WriteRegister(0x4003, 0x08)
+	apu.WriteRegister(0x4008, 0xFF)
+	apu.WriteRegister(0x400A, 0x7E)
+	apu.WriteRegister(0x400B, 0x08)
+	apu.stepLinearCounter()
+
+	p := apu.ChannelState(ChannelPulse1)
+	if !p.Active || p.Volume != 10 || p.Duty != 1 || NoteName(p.Frequency) != "A4" {
+		t.Errorf("pulse 1 = %+v (%s), want active A4 at volume 10, duty 1", p, NoteName(p.Frequency))
+	}
+	tri := apu.ChannelState(ChannelTriangle)
+	if !tri.Active || tri.Volume != 15 || NoteName(tri.Frequency) != "A4" {
+		t.Errorf("triangle = %+v (%s), want active A4", tri, NoteName(tri.Frequency))
+	}
+	if s := apu.ChannelState(ChannelPulse2); s.Active {
+		t.Errorf("disabled pulse 2 = %+v, want inactive", s)
+	}
+	if s := apu.ChannelState(NumChannels); s != (ChannelState{}) {
+		t.Errorf("out of range = %+v, want zero", s)
+	}
+}
+
+func TestNoteName(t *testing.T) {
+	for _, tc := range []struct {
+		freq float64
+		want string
+	}{
+		{440, "A4"}, {261.63, "C4"}, {1108.73, "C#6"}, {16.35, "C0"}, {0, ""}, {5, ""}, {40000, ""},
+	} {
+		if got := NoteName(tc.freq); got != tc.want {
+			t.Errorf("NoteName(%g) = %q, want %q", tc.freq, got, tc.want)
+		}
+	}
+}
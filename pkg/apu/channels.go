@@ -14,11 +14,6 @@ var triangleSequence = [32]uint8{
 	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
 }
 
-// Noise periods for different frequencies
-var noisePeriods = [16]uint16{
-	4, 8, 16, 32, 64, 96, 128, 160, 202, 254, 380, 508, 762, 1016, 2034, 4068,
-}
-
 // DMC rate table (in CPU cycles)
 var dmcRates = [16]uint16{
 	428, 380, 340, 320, 286, 254, 226, 214, 190, 160, 142, 128, 106, 84, 72, 54,
@@ -63,10 +58,13 @@ func (a *APU) stepTriangle() {
 	}
 }
 
-// stepNoise steps the noise channel. Caller guards on Noise.Enabled.
+// stepNoise steps the noise channel: the LFSR is clocked once every
+// TimerValue CPU cycles. Caller guards on Noise.Enabled.
 func (a *APU) stepNoise() {
-	if tickTimer(&a.Noise.Timer, a.Noise.TimerValue) {
-		// Step LFSR
+	if tickTimer(&a.Noise.Timer, a.Noise.TimerValue-1) {
+		// Step LFSR. The mode flag only picks the second tap, so a
+		// $400E write changes the sequence from the next clock on,
+		// continuing from whatever the register holds.
 		bit := uint16(0)
 		if a.Noise.Mode {
 			// Mode 1: tap bits 0 and 6
@@ -100,6 +98,9 @@ func (a *APU) stepDMC() {
 // unit — see the NESdev "APU DMC" page for the canonical sequence.
 func (a *APU) stepDMCSample() {
 	// === Memory reader: fill sample buffer if empty. ===
+	// Memory is the CPU bus, so the fetch sees the mapper's PRG banks as
+	// they are now — a bank switch mid-sample changes the bytes played —
+	// and, like the address wrap below, never leaves $8000-$FFFF.
 	if a.DMC.BufferEmpty && a.DMC.CurrentLength > 0 && a.Memory != nil {
 		a.DMC.SampleBuffer = a.Memory.Read(a.DMC.CurrentAddress)
 		a.DMC.BufferEmpty = false
@@ -10,6 +10,57 @@ const (
 	lpf14kFeedback = 1.0 - lpf14kAlpha
 )
 
+// outputGain brings the filtered mix, which peaks around ±0.5 once the HPF
+// has centred it, up near full scale. Applied once, in mixChannels, so
+// every sink — SDL, the WAV recorder, a custom frontend — gets the same
+// -1..1 signal.
+const outputGain = 2.0
+
+// MixSource is one of the sound chips the mixer sums on top of the 2A03
+// channels.
+type MixSource struct {
+	Chip  string // ChipFME7, ...; "" for a chip that doesn't name itself
+	Audio ExpansionAudio
+	Gain  float32 // 1 is the chip's native level
+}
+
+// AddSource mixes src in at its level in ExpansionLevels.
+func (a *APU) AddSource(src ExpansionAudio) {
+	var chip string
+	if n, ok := src.(AudioChipNamer); ok {
+		chip = n.AudioChip()
+	}
+	a.Sources = append(a.Sources, MixSource{Chip: chip, Audio: src, Gain: a.ExpansionLevel(chip)})
+}
+
+// ExpansionLevel returns the gain ExpansionLevels gives chip.
+func (a *APU) ExpansionLevel(chip string) float32 {
+	if gain, ok := a.ExpansionLevels[chip]; ok && chip != "" {
+		return gain
+	}
+	return 1
+}
+
+// SetExpansionLevel sets chip's gain, clamped to 0-MaxExpansionLevel, in
+// ExpansionLevels and on any source playing it, and returns it.
+func (a *APU) SetExpansionLevel(chip string, gain float32) float32 {
+	gain = max(0, min(gain, MaxExpansionLevel))
+	if a.ExpansionLevels == nil {
+		a.ExpansionLevels = map[string]float32{}
+	}
+	a.ExpansionLevels[chip] = gain
+	for i := range a.Sources {
+		if a.Sources[i].Chip == chip {
+			a.Sources[i].Gain = gain
+		}
+	}
+	return gain
+}
+
+// MaxExpansionLevel is the loudest SetExpansionLevel allows: twice a
+// chip's native level, which already matches the 2A03's loudest.
+const MaxExpansionLevel = 2
+
 // mixChannels mixes all audio channels using proper NES mixing
 func (a *APU) mixChannels() float32 {
 	pulse1 := a.getPulseOutput(&a.Pulse1)
@@ -17,6 +68,9 @@ func (a *APU) mixChannels() float32 {
 	triangle := a.getTriangleOutput()
 	noise := a.getNoiseOutput()
 	dmc := a.getDMCOutput()
+	if a.Taps != nil {
+		a.Taps.add([NumChannels]uint8{pulse1, pulse2, triangle, noise, dmc})
+	}
 
 	// Apply diagnostic mutes. Channels keep ticking so timing-dependent
 	// state (length / linear / envelope) stays in sync — only the audible
@@ -52,8 +106,10 @@ func (a *APU) mixChannels() float32 {
 	}
 
 	output := pulseOut + tndOut
-	if a.Expansion != nil && !a.ExpansionMuted {
-		output += a.Expansion.AudioSample()
+	if !a.ExpansionMuted && !a.NESAudio {
+		for _, s := range a.Sources {
+			output += s.Audio.AudioSample() * s.Gain
+		}
 	}
 	if output > 1.0 {
 		output = 1.0
@@ -62,6 +118,16 @@ func (a *APU) mixChannels() float32 {
 	if a.FilterEnabled {
 		output = a.applyAnalogFilters(output)
 	}
+
+	if a.Muted {
+		return 0
+	}
+	output *= outputGain * a.Volume
+	if output > 1.0 {
+		output = 1.0
+	} else if output < -1.0 {
+		output = -1.0
+	}
 	return output
 }
 
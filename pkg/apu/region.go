@@ -0,0 +1,56 @@
+package apu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Region is the console the 2A03 (NTSC) or 2A07 (PAL) sits in. The two
+// chips count some periods differently; so far the noise channel's period
+// table is the part that follows the region, the rest of the APU runs NTSC
+// timing either way.
+type Region uint8
+
+const (
+	RegionNTSC Region = iota // the zero value
+	RegionPAL
+)
+
+func (r Region) String() string {
+	if r == RegionPAL {
+		return "PAL"
+	}
+	return "NTSC"
+}
+
+// ParseRegion parses a region's name, ntsc or pal, in any case.
+func ParseRegion(s string) (Region, error) {
+	switch strings.ToLower(s) {
+	case "ntsc":
+		return RegionNTSC, nil
+	case "pal":
+		return RegionPAL, nil
+	}
+	return RegionNTSC, fmt.Errorf("region %q must be ntsc or pal", s)
+}
+
+// Noise periods in CPU cycles per LFSR clock, by Region and the $400E
+// period index.
+var noisePeriods = [2][16]uint16{
+	RegionNTSC: {4, 8, 16, 32, 64, 96, 128, 160, 202, 254, 380, 508, 762, 1016, 2034, 4068},
+	RegionPAL:  {4, 8, 14, 30, 60, 88, 118, 148, 188, 236, 354, 472, 708, 944, 1890, 3778},
+}
+
+// Region returns the region the APU's period tables follow.
+func (a *APU) Region() Region { return a.region }
+
+// SetRegion switches the period tables to r. The noise period already
+// written to $400E is looked up again, so a switch is heard at once;
+// other values of r are taken as NTSC.
+func (a *APU) SetRegion(r Region) {
+	if r != RegionPAL {
+		r = RegionNTSC
+	}
+	a.region = r
+	a.Noise.TimerValue = noisePeriods[r][a.Noise.Period&0x0F]
+}
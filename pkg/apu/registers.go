@@ -74,8 +74,8 @@ func (a *APU) writeNoise(reg uint16, value uint8) {
 		
 	case 2: // $400E - Period, mode
 		a.Noise.Mode = (value & 0x80) != 0
-		periodIndex := value & 0x0F
-		a.Noise.TimerValue = noisePeriods[periodIndex]
+		a.Noise.Period = value & 0x0F
+		a.Noise.TimerValue = noisePeriods[a.region][a.Noise.Period]
 		
 	case 3: // $400F - Length counter
 		if a.Noise.Enabled {
@@ -149,20 +149,23 @@ func (a *APU) writeStatus(value uint8) {
 	}
 }
 
-// writeFrameCounter handles frame counter register writes
+// writeFrameCounter handles frame counter register writes. The new mode
+// and IRQ inhibit take effect at once, and with bit 7 set the quarter- and
+// half-frame units are clocked immediately; the sequencer itself restarts
+// 3 CPU cycles later if the write lands on an even cycle (during an APU
+// cycle), 4 on an odd one (FrameResetDelay).
 func (a *APU) writeFrameCounter(value uint8) {
 	a.FrameCounter = value
-	
-	// Reset frame counter
-	a.FrameStep = 0
-	
-	// If 5-step mode is set, step immediately
+	a.FrameResetDelay = 3 + int(a.Cycles&1)
+
+	// If 5-step mode is set, clock quarter and half frame immediately
 	if (value & 0x80) != 0 {
 		a.stepEnvelopes()
+		a.stepLinearCounter()
 		a.stepLengthCounters()
 		a.stepSweeps()
 	}
-	
+
 	// Clear frame IRQ if inhibit flag is set
 	if (value & 0x40) != 0 {
 		a.FrameIRQ = false
@@ -171,8 +174,9 @@ func (a *APU) writeFrameCounter(value uint8) {
 
 // initializeChannels initializes channel default values
 func (a *APU) initializeChannels() {
-	// Initialize noise shift register to 1
-	a.Noise.ShiftReg = 1
+	// The noise shift register powers up as 1, and the period as index 0
+	a.Noise.ShiftReg = noisePowerUp
+	a.Noise.TimerValue = noisePeriods[a.region][0]
 	
 	// Initialize envelope generators
 	a.Pulse1.Envelope.Volume = 15
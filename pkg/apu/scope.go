@@ -0,0 +1,140 @@
+package apu
+
+import (
+	"fmt"
+	"math"
+)
+
+// cpuClock is the NTSC CPU clock the channel timers count, in Hz.
+const cpuClock = 1789773.0
+
+// TapLength is how many samples per channel ChannelTaps keeps: a little
+// over a frame at 44.1 kHz, enough for an oscilloscope to trigger on.
+const TapLength = 1024
+
+// ChannelTaps records each channel's level going into the mixer, one
+// entry per output sample, so a debugger can draw the channels apart
+// rather than only the mix. Levels are the raw DAC inputs — 0-15, the
+// DMC 0-127 — taken before ChannelMute, so a muted channel still shows.
+// Attach one with APU.Taps; nil (the default) costs nothing.
+type ChannelTaps struct {
+	Samples [NumChannels][]uint8
+}
+
+// Reset drops the recorded samples, keeping the buffers.
+func (t *ChannelTaps) Reset() {
+	for ch := range t.Samples {
+		t.Samples[ch] = t.Samples[ch][:0]
+	}
+}
+
+// add appends one sample per channel, keeping at most TapLength: like
+// APU.Output, the buffers are trimmed in halves rather than every sample.
+func (t *ChannelTaps) add(levels [NumChannels]uint8) {
+	for ch, v := range levels {
+		s := append(t.Samples[ch], v)
+		if len(s) > 2*TapLength {
+			copy(s, s[len(s)-TapLength:])
+			s = s[:TapLength]
+		}
+		t.Samples[ch] = s
+	}
+}
+
+// Last returns up to the n most recent samples of channel ch.
+func (t *ChannelTaps) Last(ch, n int) []uint8 {
+	s := t.Samples[ch]
+	if len(s) > n {
+		s = s[len(s)-n:]
+	}
+	return s
+}
+
+// ChannelState is what a channel is playing, for display.
+type ChannelState struct {
+	// Active reports whether the channel is sounding: enabled, its
+	// length (and for the triangle, linear) counter running and, for a
+	// pulse, its period in range. For the DMC, a sample is playing.
+	Active bool
+	// Frequency is the pitch in Hz for the pulses and triangle, the
+	// shift-register clock for the noise and the bit rate for the DMC.
+	Frequency float64
+	// Volume is the envelope or constant volume (0-15), 15 for a
+	// sounding triangle and the output level (0-127) for the DMC.
+	Volume uint8
+	// Duty is the pulse duty setting (0-3); for the noise, 1 in the
+	// short (93-step) mode. Unused for the others.
+	Duty uint8
+}
+
+// ChannelState reports channel ch's current state; ch out of range
+// returns the zero value.
+func (a *APU) ChannelState(ch int) ChannelState {
+	switch ch {
+	case ChannelPulse1, ChannelPulse2:
+		p := &a.Pulse1
+		if ch == ChannelPulse2 {
+			p = &a.Pulse2
+		}
+		vol := p.Envelope.Counter
+		if p.Envelope.Constant {
+			vol = p.Volume
+		}
+		return ChannelState{
+			Active: p.Enabled && p.Length.Value > 0 && p.TimerValue >= 8 &&
+				p.TimerValue <= 0x7FF && !a.isSweepMuting(p, &p.Sweep),
+			Frequency: cpuClock / (16 * float64(p.TimerValue+1)),
+			Volume:    vol,
+			Duty:      p.DutyCycle,
+		}
+	case ChannelTriangle:
+		t := &a.Triangle
+		s := ChannelState{
+			Active:    t.Enabled && t.Length.Value > 0 && t.LinearCounter > 0,
+			Frequency: cpuClock / (32 * float64(t.TimerValue+1)),
+		}
+		if s.Active {
+			s.Volume = 15
+		}
+		return s
+	case ChannelNoise:
+		n := &a.Noise
+		s := ChannelState{
+			Active: n.Enabled && n.Length.Value > 0,
+			Volume: n.Envelope.Counter,
+		}
+		if n.TimerValue > 0 {
+			s.Frequency = cpuClock / float64(n.TimerValue)
+		}
+		if n.Envelope.Constant {
+			s.Volume = n.Volume
+		}
+		if n.Mode {
+			s.Duty = 1
+		}
+		return s
+	case ChannelDMC:
+		d := &a.DMC
+		return ChannelState{
+			Active:    d.Enabled && d.CurrentLength > 0,
+			Frequency: cpuClock / float64(dmcRates[d.Rate&0x0F]),
+			Volume:    d.LoadCounter,
+		}
+	}
+	return ChannelState{}
+}
+
+var noteNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// NoteName names the equal-tempered note nearest freq (A4 = 440 Hz), e.g.
+// "A4" or "C#6", or returns "" for a frequency outside C0-B9.
+func NoteName(freq float64) string {
+	if freq <= 0 {
+		return ""
+	}
+	n := int(math.Round(12*math.Log2(freq/440))) + 57 // semitones above C0
+	if n < 0 || n >= 120 {
+		return ""
+	}
+	return fmt.Sprintf("%s%d", noteNames[n%12], n/12)
+}
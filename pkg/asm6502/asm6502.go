@@ -0,0 +1,528 @@
+// Package asm6502 is a small two-pass 6502 assembler, for tests that want
+// a readable program instead of a hex array and for the debugger's
+// "assemble at address" command. It knows every documented opcode and
+// little else:
+//
+//	        .org $8000
+//	Reset:  ldx #0
+//	loop:   lda Message,x
+//	        beq done
+//	        sta $0300,x
+//	        inx
+//	        bne loop
+//	done:   jmp done
+//	Message: .db "HI", 0
+//	        .org $FFFA
+//	        .dw Reset, Reset, Reset
+//
+// One statement per line, with an optional "name:" label in front and
+// anything after ';' ignored. "name = expr" (or "name equ expr") defines a
+// constant. The directives are .org, .db (.byte) and .dw (.word), with or
+// without the dot. Operands use the usual syntax — #imm, zp, abs, zp,X,
+// abs,Y, (ind), (zp,X), (zp),Y, A for the accumulator — and a zero-page
+// address becomes the shorter zero-page form when the opcode has one and
+// the value is known by then, so a forward reference is always absolute.
+//
+// Expressions are numbers ($C000 or 0xC000 hex, %0101 binary, decimal, or
+// 'c'), labels, and * for the statement's own address, added and
+// subtracted; a leading < or > takes the low or high byte.
+package asm6502
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/cpu"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+)
+
+// Program is assembled code: a Segment for each run of bytes, in source
+// order, and the address of every label.
+type Program struct {
+	Segments []Segment
+	Labels   map[string]uint16
+}
+
+// Segment is code that starts at Addr.
+type Segment struct {
+	Addr uint16
+	Code []byte
+}
+
+// stmt is a line that emits bytes, sized in the first pass and encoded in
+// the second.
+type stmt struct {
+	line    int
+	addr    uint16
+	seg     int
+	name    string // upper-case mnemonic, or ".DB"/".DW"
+	mode    cpu.AddressingMode
+	operand string   // the expression, stripped of mode syntax
+	args    []string // .db/.dw items
+	size    int
+}
+
+type assembler struct {
+	syms   *symbols.Table
+	labels map[string]uint16
+	stmts  []stmt
+	segs   []Segment
+	pc     int // up to $10000, just past the end
+}
+
+// errUndefined is eval's error for a name nobody has defined (yet).
+var errUndefined = errors.New("undefined")
+
+// Assemble assembles src starting at org, until an .org moves it. Names
+// the source doesn't define are looked up in syms, which may be nil.
+func Assemble(src string, org uint16, syms *symbols.Table) (*Program, error) {
+	a := &assembler{syms: syms, labels: map[string]uint16{}, pc: int(org)}
+	a.segs = []Segment{{Addr: org}}
+	for i, text := range strings.Split(src, "\n") {
+		if err := a.first(i+1, text); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	for _, s := range a.stmts {
+		code, err := a.encode(&s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", s.line, err)
+		}
+		a.segs[s.seg].Code = append(a.segs[s.seg].Code, code...)
+	}
+	p := &Program{Labels: a.labels}
+	for _, seg := range a.segs {
+		if len(seg.Code) > 0 {
+			p.Segments = append(p.Segments, seg)
+		}
+	}
+	return p, nil
+}
+
+// first is the first pass over one line: it defines labels and constants,
+// follows .org, and sizes the statement.
+func (a *assembler) first(line int, text string) error {
+	text = strings.TrimSpace(stripComment(text))
+	if name, rest, ok := cutLabel(text); ok {
+		if err := a.define(name, uint16(a.pc)); err != nil {
+			return err
+		}
+		text = rest
+	}
+	if text == "" {
+		return nil
+	}
+	word, rest := text, ""
+	if i := strings.IndexAny(text, " \t"); i >= 0 {
+		word, rest = text[:i], strings.TrimSpace(text[i+1:])
+	}
+
+	// name = expr, name equ expr
+	if eq := strings.IndexByte(text, '='); eq >= 0 && isName(strings.TrimSpace(text[:eq])) {
+		return a.constant(strings.TrimSpace(text[:eq]), text[eq+1:])
+	}
+	if len(rest) > 3 && strings.EqualFold(rest[:3], "equ") && (rest[3] == ' ' || rest[3] == '\t') {
+		return a.constant(word, rest[4:])
+	}
+
+	s := stmt{line: line, addr: uint16(a.pc), seg: len(a.segs) - 1}
+	switch directive := strings.ToUpper(strings.TrimPrefix(word, ".")); directive {
+	case "ORG":
+		v, err := a.eval(rest, uint16(a.pc))
+		if err != nil {
+			return fmt.Errorf(".org: %w", err)
+		}
+		if v < 0 || v > 0xFFFF {
+			return fmt.Errorf(".org $%X is out of range", v)
+		}
+		a.pc = v
+		a.segs = append(a.segs, Segment{Addr: uint16(v)})
+		return nil
+	case "DB", "BYTE", "DW", "WORD":
+		args, err := splitArgs(rest)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return fmt.Errorf(".%s needs a value", strings.ToLower(directive))
+		}
+		s.args = args
+		if directive == "DB" || directive == "BYTE" {
+			s.name = ".DB"
+			for _, arg := range args {
+				if str, ok := quoted(arg); ok {
+					s.size += len(str)
+				} else {
+					s.size++
+				}
+			}
+		} else {
+			s.name = ".DW"
+			s.size = 2 * len(args)
+		}
+	default:
+		s.name = strings.ToUpper(word)
+		if err := a.size(&s, rest); err != nil {
+			return err
+		}
+	}
+	if a.pc+s.size > 0x10000 {
+		return fmt.Errorf("code runs past $FFFF")
+	}
+	a.pc += s.size
+	a.stmts = append(a.stmts, s)
+	return nil
+}
+
+// define names addr, once.
+func (a *assembler) define(name string, addr uint16) error {
+	if _, ok := a.labels[name]; ok {
+		return fmt.Errorf("%q defined twice", name)
+	}
+	a.labels[name] = addr
+	return nil
+}
+
+// constant defines name as expr, which must be known by now.
+func (a *assembler) constant(name, expr string) error {
+	if !isName(name) {
+		return fmt.Errorf("bad name %q", name)
+	}
+	v, err := a.eval(expr, uint16(a.pc))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if v < 0 || v > 0xFFFF {
+		return fmt.Errorf("%s = %d is out of range", name, v)
+	}
+	return a.define(name, uint16(v))
+}
+
+// size works out s's addressing mode from the operand's syntax and the
+// opcodes s.name has, and so its length.
+func (a *assembler) size(s *stmt, operand string) error {
+	has := func(mode cpu.AddressingMode) bool {
+		_, ok := cpu.Encode(s.name, mode)
+		return ok
+	}
+	upper := strings.ToUpper(strings.ReplaceAll(operand, " ", ""))
+	switch {
+	case has(cpu.AddrRelative):
+		if operand == "" {
+			return fmt.Errorf("%s needs a target", s.name)
+		}
+		s.mode, s.operand = cpu.AddrRelative, operand
+	case operand == "" || upper == "A" && has(cpu.AddrAccumulator):
+		switch {
+		case has(cpu.AddrImplied):
+			s.mode = cpu.AddrImplied
+		case has(cpu.AddrAccumulator):
+			s.mode = cpu.AddrAccumulator
+		default:
+			return a.noMode(s)
+		}
+	case strings.HasPrefix(operand, "#"):
+		s.mode, s.operand = cpu.AddrImmediate, operand[1:]
+	case strings.HasPrefix(upper, "(") && strings.HasSuffix(upper, ",X)"):
+		s.mode, s.operand = cpu.AddrIndexedIndirect, trimIndex(operand[1:], ",")
+	case strings.HasPrefix(upper, "(") && strings.HasSuffix(upper, "),Y"):
+		s.mode, s.operand = cpu.AddrIndirectIndexed, trimIndex(operand[1:], ")")
+	case strings.HasPrefix(upper, "(") && strings.HasSuffix(upper, ")"):
+		s.mode, s.operand = cpu.AddrIndirect, strings.TrimSpace(operand[1:len(operand)-1])
+	case strings.HasSuffix(upper, ",X"):
+		s.operand = trimIndex(operand, ",")
+		s.mode = a.pick(s, cpu.AddrZeroPageX, cpu.AddrAbsoluteX)
+	case strings.HasSuffix(upper, ",Y"):
+		s.operand = trimIndex(operand, ",")
+		s.mode = a.pick(s, cpu.AddrZeroPageY, cpu.AddrAbsoluteY)
+	default:
+		s.operand = operand
+		s.mode = a.pick(s, cpu.AddrZeroPage, cpu.AddrAbsolute)
+	}
+	op, ok := cpu.Encode(s.name, s.mode)
+	if !ok {
+		return a.noMode(s)
+	}
+	s.size = cpu.InstructionLength(op)
+	return nil
+}
+
+// noMode explains why s has no opcode: a mnemonic the 6502 doesn't have,
+// or an operand it doesn't take.
+func (a *assembler) noMode(s *stmt) error {
+	for mode := cpu.AddrImplied; mode <= cpu.AddrIndirectIndexed; mode++ {
+		if _, ok := cpu.Encode(s.name, mode); ok {
+			return fmt.Errorf("%s can't take that operand", s.name)
+		}
+	}
+	return fmt.Errorf("unknown instruction %q", s.name)
+}
+
+// pick chooses between an instruction's zero-page and absolute forms: zero
+// page when the opcode has it and the operand is already known to fit.
+func (a *assembler) pick(s *stmt, zp, abs cpu.AddressingMode) cpu.AddressingMode {
+	_, hasZP := cpu.Encode(s.name, zp)
+	if _, hasAbs := cpu.Encode(s.name, abs); !hasAbs {
+		return zp
+	}
+	if v, err := a.eval(s.operand, s.addr); hasZP && err == nil && v >= 0 && v <= 0xFF {
+		return zp
+	}
+	return abs
+}
+
+// encode is the second pass: s's bytes, with every name defined.
+func (a *assembler) encode(s *stmt) ([]byte, error) {
+	switch s.name {
+	case ".DB":
+		var b []byte
+		for _, arg := range s.args {
+			if str, ok := quoted(arg); ok {
+				b = append(b, str...)
+				continue
+			}
+			v, err := a.value(arg, s.addr, -128, 0xFF)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, byte(v))
+		}
+		return b, nil
+	case ".DW":
+		var b []byte
+		for _, arg := range s.args {
+			v, err := a.value(arg, s.addr, -0x8000, 0xFFFF)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, byte(v), byte(v>>8))
+		}
+		return b, nil
+	}
+	op, _ := cpu.Encode(s.name, s.mode)
+	switch s.size {
+	case 1:
+		return []byte{op}, nil
+	case 3:
+		v, err := a.value(s.operand, s.addr, 0, 0xFFFF)
+		return []byte{op, byte(v), byte(v >> 8)}, err
+	}
+	if s.mode == cpu.AddrRelative {
+		v, err := a.value(s.operand, s.addr, 0, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		dist := v - int(s.addr) - 2
+		if dist < -128 || dist > 127 {
+			return nil, fmt.Errorf("branch to $%04X is %d bytes away, out of range", v, dist)
+		}
+		return []byte{op, byte(int8(dist))}, nil
+	}
+	low := 0
+	if s.mode == cpu.AddrImmediate {
+		low = -128
+	}
+	v, err := a.value(s.operand, s.addr, low, 0xFF)
+	return []byte{op, byte(v)}, err
+}
+
+// value evaluates src and checks it's in [low, high].
+func (a *assembler) value(src string, pc uint16, low, high int) (int, error) {
+	v, err := a.eval(src, pc)
+	if errors.Is(err, errUndefined) {
+		return 0, fmt.Errorf("%w in %q", err, strings.TrimSpace(src))
+	}
+	if err != nil {
+		return 0, err
+	}
+	if v < low || v > high {
+		return 0, fmt.Errorf("%s = %d doesn't fit", strings.TrimSpace(src), v)
+	}
+	return v, nil
+}
+
+// eval evaluates an expression at pc. A name nobody has defined gives an
+// error wrapping errUndefined.
+func (a *assembler) eval(src string, pc uint16) (int, error) {
+	s := strings.TrimSpace(src)
+	if s == "" {
+		return 0, fmt.Errorf("missing value")
+	}
+	byteOf := s[0]
+	if byteOf == '<' || byteOf == '>' {
+		s = s[1:]
+	}
+	total, sign := 0, 1
+	for expectTerm := true; ; {
+		s = strings.TrimSpace(s)
+		if expectTerm {
+			if s != "" && s[0] == '-' {
+				sign, s = -sign, s[1:]
+				continue
+			}
+			v, rest, err := a.term(s, pc)
+			if err != nil {
+				return 0, err
+			}
+			total += sign * v
+			s, expectTerm = rest, false
+			continue
+		}
+		if s == "" {
+			break
+		}
+		switch s[0] {
+		case '+':
+			sign = 1
+		case '-':
+			sign = -1
+		default:
+			return 0, fmt.Errorf("unexpected %q", s)
+		}
+		s, expectTerm = s[1:], true
+	}
+	switch byteOf {
+	case '<':
+		return total & 0xFF, nil
+	case '>':
+		return total >> 8 & 0xFF, nil
+	}
+	return total, nil
+}
+
+// term reads one number, name or * from the front of s.
+func (a *assembler) term(s string, pc uint16) (int, string, error) {
+	if s == "" {
+		return 0, "", fmt.Errorf("missing value")
+	}
+	if s[0] == '*' {
+		return int(pc), s[1:], nil
+	}
+	if s[0] == '\'' {
+		if len(s) < 3 || s[2] != '\'' {
+			return 0, "", fmt.Errorf("bad character %q", s)
+		}
+		return int(s[1]), s[3:], nil
+	}
+	end := 0
+	for end < len(s) && (isNameByte(s[end]) || s[end] == '$' || s[end] == '%') {
+		end++
+	}
+	tok, rest := s[:end], s[end:]
+	if tok == "" {
+		return 0, "", fmt.Errorf("unexpected %q", s)
+	}
+	base, digits := 10, tok
+	switch {
+	case tok[0] == '$':
+		base, digits = 16, tok[1:]
+	case tok[0] == '%':
+		base, digits = 2, tok[1:]
+	case strings.HasPrefix(tok, "0x") || strings.HasPrefix(tok, "0X"):
+		base, digits = 16, tok[2:]
+	case tok[0] >= '0' && tok[0] <= '9':
+	default:
+		if v, ok := a.labels[tok]; ok {
+			return int(v), rest, nil
+		}
+		if sym, ok := a.syms.Find(tok); ok {
+			return int(sym.Address), rest, nil
+		}
+		return 0, "", fmt.Errorf("%w name %q", errUndefined, tok)
+	}
+	v, err := strconv.ParseUint(digits, base, 16)
+	if err != nil {
+		return 0, "", fmt.Errorf("bad number %q", tok)
+	}
+	return int(v), rest, nil
+}
+
+// stripComment drops everything from a ';' that isn't in quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ';':
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// cutLabel splits "name: rest".
+func cutLabel(s string) (name, rest string, ok bool) {
+	i := 0
+	for i < len(s) && isNameByte(s[i]) {
+		i++
+	}
+	if i == 0 || i >= len(s) || s[i] != ':' || !isName(s[:i]) {
+		return "", s, false
+	}
+	return s[:i], strings.TrimSpace(s[i+1:]), true
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// isName reports whether s can be a label: a letter, '_' or '.' first.
+func isName(s string) bool {
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// trimIndex cuts an operand at its last sep, leaving the expression in
+// front of ",X" or "),Y".
+func trimIndex(s, sep string) string {
+	return strings.TrimSpace(s[:strings.LastIndex(s, sep)])
+}
+
+// splitArgs splits a .db/.dw list on the commas outside quotes.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			args = append(args, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated string in %q", s)
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" || len(args) > 0 {
+		args = append(args, last)
+	}
+	return args, nil
+}
+
+// quoted returns the text of a "string"; 'c' is a number.
+func quoted(s string) (string, bool) {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1], true
+	}
+	return "", false
+}
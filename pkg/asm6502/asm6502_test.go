@@ -0,0 +1,135 @@
+package asm6502
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/cpu"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+)
+
+func TestAssemble(t *testing.T) {
+	src := `
+; copy a message to $0300
+PPU = $2000
+ptr equ $10
+
+        .org $8000
+Reset:  sei
+        ldx #0
+loop:   lda Message,x   ; a forward reference is absolute
+        beq done
+        sta $0300,x
+        inx
+        bne loop
+done:   lda (ptr),y
+        sta (ptr,x)
+        asl a
+        lsr
+        stx ptr,y
+        lda #<Message
+        ldy #>Message
+        sta PPU+1
+        jmp (Vector)
+Message: .db "HI;", 'x', -1
+Vector: .dw Reset, *+2
+        .org $FFFC
+        .word Reset
+`
+	p, err := Assemble(src, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x78,       // sei
+		0xA2, 0x00, // ldx #0
+		0xBD, 0x20, 0x80, // lda Message,x
+		0xF0, 0x06, // beq done
+		0x9D, 0x00, 0x03, // sta $0300,x
+		0xE8,       // inx
+		0xD0, 0xF5, // bne loop
+		0xB1, 0x10, // lda ($10),y
+		0x81, 0x10, // sta ($10,x)
+		0x0A,       // asl a
+		0x4A,       // lsr
+		0x96, 0x10, // stx $10,y
+		0xA9, 0x20, // lda #<Message
+		0xA0, 0x80, // ldy #>Message
+		0x8D, 0x01, 0x20, // sta $2001
+		0x6C, 0x25, 0x80, // jmp (Vector)
+		'H', 'I', ';', 'x', 0xFF,
+		0x00, 0x80, 0x27, 0x80,
+	}
+	if len(p.Segments) != 2 {
+		t.Fatalf("%d segments, want 2", len(p.Segments))
+	}
+	if seg := p.Segments[0]; seg.Addr != 0x8000 || !bytes.Equal(seg.Code, want) {
+		t.Errorf("segment at $%04X:\n% X\nwant\n% X", seg.Addr, seg.Code, want)
+	}
+	if seg := p.Segments[1]; seg.Addr != 0xFFFC || !bytes.Equal(seg.Code, []byte{0x00, 0x80}) {
+		t.Errorf("segment at $%04X: % X", seg.Addr, seg.Code)
+	}
+	if p.Labels["done"] != 0x800E || p.Labels["ptr"] != 0x10 {
+		t.Errorf("done = $%04X, ptr = $%04X", p.Labels["done"], p.Labels["ptr"])
+	}
+}
+
+// TestEveryOpcode assembles each documented opcode's own disassembly
+// back to it.
+func TestEveryOpcode(t *testing.T) {
+	for op := 0; op < 256; op++ {
+		if cpu.Classify(uint8(op))&cpu.ClassIllegal != 0 {
+			continue
+		}
+		// Operand bytes above $FF keep absolute modes absolute.
+		b := [3]uint8{uint8(op), 0x34, 0x12}
+		if cpu.InstructionLength(uint8(op)) == 2 {
+			b[1] = 0x44
+		}
+		text := cpu.Disassemble(0x8000, b)
+		p, err := Assemble(text, 0x8000, nil)
+		if err != nil {
+			t.Errorf("$%02X %q: %v", op, text, err)
+			continue
+		}
+		n := cpu.InstructionLength(uint8(op))
+		if got := p.Segments[0].Code; !bytes.Equal(got, b[:n]) {
+			t.Errorf("%q = % X, want % X", text, got, b[:n])
+		}
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	syms := symbols.NewTable([]symbols.Symbol{{Name: "Update", Address: 0xC123, PRG: -1}})
+	p, err := Assemble("jsr Update\nUpdate2: rts", 0x0300, syms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Segments[0].Code; !bytes.Equal(got, []byte{0x20, 0x23, 0xC1, 0x60}) {
+		t.Errorf("code = % X", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []struct{ src, want string }{
+		{"lda", "line 1: LDA can't take that operand"},
+		{"nop\nfoo #1", `line 2: unknown instruction "FOO"`},
+		{"sta #1", "STA can't take that operand"},
+		{"jmp nowhere", `undefined name "nowhere"`},
+		{"x: nop\nx: nop", `"x" defined twice`},
+		{"lda #$100", "doesn't fit"},
+		{"stx $1234,y", "doesn't fit"},
+		{"far: .db 0\n.org $8100\nbne far", "out of range"},
+		{".db \"abc", "unterminated string"},
+		{"lda $12 +", "missing value"},
+		{"lda $GG", `bad number "$GG"`},
+		{".org $FFFF\nnop\nnop", "runs past $FFFF"},
+		{"n = later\nlater: nop", `undefined name "later"`},
+	} {
+		_, err := Assemble(tc.src, 0x8000, nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Assemble(%q) error = %v, want %q", tc.src, err, tc.want)
+		}
+	}
+}
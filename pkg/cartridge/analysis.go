@@ -0,0 +1,93 @@
+package cartridge
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
+)
+
+// Hashes identifies a dump the way ROM databases do: CRC-32 and SHA-1 of
+// the PRG ROM, the CHR ROM, and both together in that order (ROM is the
+// checksum the game database is keyed by). Header and trainer are left
+// out, so a re-headered dump hashes the same. A disk image's sides stand
+// in for PRG ROM, as does a flash board's PRG ROM as it was loaded.
+type Hashes struct {
+	PRGCRC32, CHRCRC32, ROMCRC32 uint32
+	PRGSHA1, CHRSHA1, ROMSHA1    string // lowercase hex
+}
+
+// Hashes computes the cartridge's Hashes.
+func (c *Cartridge) Hashes() Hashes {
+	prgROM := c.PRGROM
+	if c.disk != nil {
+		prgROM = c.disk
+	} else if c.dumpPRG != nil {
+		prgROM = c.dumpPRG
+	}
+	rom := sha1.New()
+	rom.Write(prgROM)
+	rom.Write(c.CHRROM)
+	prg := sha1.Sum(prgROM)
+	chr := sha1.Sum(c.CHRROM)
+	return Hashes{
+		PRGCRC32: crc32.ChecksumIEEE(prgROM),
+		CHRCRC32: crc32.ChecksumIEEE(c.CHRROM),
+		ROMCRC32: ROMCRC32(prgROM, c.CHRROM),
+		PRGSHA1:  hex.EncodeToString(prg[:]),
+		CHRSHA1:  hex.EncodeToString(chr[:]),
+		ROMSHA1:  hex.EncodeToString(rom.Sum(nil)),
+	}
+}
+
+// MapperNumber returns the iNES mapper number from flags 6 and 7.
+func (h iNESHeader) MapperNumber() uint8 {
+	return h.Flags6>>4 | h.Flags7&0xF0
+}
+
+// dirty reports whether an iNES 1.0 header has junk in bytes 7-15, left
+// there by a ripper signing its dumps.
+func (h iNESHeader) dirty() bool {
+	return !h.IsNES20() && (h.Flags7&0x0C != 0 || h.Padding != [5]uint8{})
+}
+
+// ImageProblems lists what is wrong with image, the uncompressed iNES file
+// cart was loaded from, short of what stops LoadEntry: data past the end
+// of CHR ROM, a dirty iNES 1.0 header (bytes 7-15 left over from a ripper
+// signature such as "DiskDude!", which also garbles the mapper's high
+// nibble), a game database entry naming another mapper, a dump the
+// database doesn't know running as another mapper than its header's (see
+// looksLikeDxROM), and a submapper the mapper doesn't tell apart from 0.
+func ImageProblems(image []byte, cart *Cartridge) []string {
+	var problems []string
+	h := cart.Header
+	want := 16 + len(cart.PRGROM) + len(cart.CHRROM)
+	if h.Flags6&0x04 != 0 {
+		want += 512
+	}
+	if len(image) > want {
+		problems = append(problems, fmt.Sprintf("file is %d bytes, header describes %d (%d trailing bytes ignored)", len(image), want, len(image)-want))
+	}
+
+	if h.dirty() {
+		problems = append(problems, fmt.Sprintf("dirty iNES 1.0 header (junk in unused bytes 7-15); mapper %d may really be %d", h.MapperNumber(), h.Flags6>>4))
+	}
+
+	if info, ok := LookupGame(ROMCRC32(cart.PRGROM, cart.CHRROM)); ok && info.Mapper != h.MapperNumber() {
+		p := fmt.Sprintf("game database lists mapper %d, header says %d", info.Mapper, h.MapperNumber())
+		if cart.mapperNumber == info.Mapper {
+			p += "; it runs as the database's"
+		}
+		problems = append(problems, p)
+	} else if !ok && cart.fds == nil && cart.mapperNumber != h.MapperNumber() {
+		problems = append(problems, fmt.Sprintf("header says mapper %d, but the code only writes mapper %d's registers; it runs as %d",
+			h.MapperNumber(), cart.mapperNumber, cart.mapperNumber))
+	}
+
+	if b, ok := mapper.Lookup(cart.mapperNumber); ok && !b.HandlesSubmapper(cart.submapper) {
+		problems = append(problems, fmt.Sprintf("submapper %d of mapper %d isn't emulated; it runs as submapper 0", cart.submapper, cart.mapperNumber))
+	}
+	return problems
+}
@@ -0,0 +1,241 @@
+package cartridge
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash/crc32"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestHashes(t *testing.T) {
+	image := buildINES(0, 1, 1)
+	image[16] = 0x4C       // PRG
+	image[16+16384] = 0xFF // CHR
+	cart, err := LoadFromReader(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := cart.Hashes()
+	prg, chr := image[16:16+16384], image[16+16384:]
+	if h.PRGCRC32 != crc32.ChecksumIEEE(prg) || h.CHRCRC32 != crc32.ChecksumIEEE(chr) {
+		t.Errorf("PRG/CHR CRC32 = %08X/%08X", h.PRGCRC32, h.CHRCRC32)
+	}
+	if h.ROMCRC32 != crc32.ChecksumIEEE(image[16:]) {
+		t.Errorf("ROM CRC32 = %08X, want the CRC of PRG+CHR", h.ROMCRC32)
+	}
+	rom := sha1.Sum(image[16:])
+	if h.ROMSHA1 != hex.EncodeToString(rom[:]) {
+		t.Errorf("ROM SHA1 = %s", h.ROMSHA1)
+	}
+}
+
+func TestImageProblems(t *testing.T) {
+	load := func(image []byte) *Cartridge {
+		t.Helper()
+		cart, err := LoadFromReader(bytes.NewReader(image))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cart
+	}
+
+	clean := buildINES(0, 1, 1)
+	if p := ImageProblems(clean, load(clean)); len(p) != 0 {
+		t.Errorf("clean image: %q", p)
+	}
+
+	long := append(buildINES(0, 1, 1), make([]byte, 10)...)
+	if p := ImageProblems(long, load(long)); len(p) != 1 || !strings.Contains(p[0], "10 trailing bytes") {
+		t.Errorf("trailing data: %q", p)
+	}
+
+	dirty := buildINES(0, 1, 1)
+	copy(dirty[11:], "Dude!")
+	if p := ImageProblems(dirty, load(dirty)); len(p) != 1 || !strings.Contains(p[0], "dirty") {
+		t.Errorf("dirty header: %q", p)
+	}
+
+	// NES 2.0 MMC3 submapper 2 (MMC3C) runs as plain MMC3; 1 (MMC6) doesn't.
+	for sub, want := range map[uint8]int{1: 0, 2: 1} {
+		mmc3 := buildINES(4, 2, 1)
+		mmc3[7] |= 0x08
+		mmc3[8] = sub << 4
+		if p := ImageProblems(mmc3, load(mmc3)); len(p) != want || want == 1 && !strings.Contains(p[0], "submapper 2 of mapper 4") {
+			t.Errorf("MMC3 submapper %d: %q", sub, p)
+		}
+	}
+
+	crc := ROMCRC32(clean[16:16+16384], clean[16+16384:])
+	RegisterGame(crc, GameInfo{Mapper: 3})
+	defer func() {
+		gameDBMu.Lock()
+		delete(gameDB, crc)
+		gameDBMu.Unlock()
+	}()
+	if p := ImageProblems(clean, load(clean)); len(p) != 1 || !strings.Contains(p[0], "mapper 3") {
+		t.Errorf("database mismatch: %q", p)
+	}
+}
+
+func TestGame(t *testing.T) {
+	image := buildINES(0, 1, 1)
+	image[16] = 0xA9
+	cart, err := LoadFromReader(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cart.Game(); ok {
+		t.Fatal("unknown dump found in the database")
+	}
+	crc := cart.Hashes().ROMCRC32
+	RegisterGame(crc, GameInfo{Name: "Test Game (Japan)", Region: "NTSC"})
+	defer func() {
+		gameDBMu.Lock()
+		delete(gameDB, crc)
+		gameDBMu.Unlock()
+	}()
+	if info, ok := cart.Game(); !ok || info.Name != "Test Game (Japan)" {
+		t.Errorf("Game = %+v, %v; want the entry registered after loading", info, ok)
+	}
+}
+
+func TestLoadNES20DB(t *testing.T) {
+	const db = `<?xml version="1.0" encoding="UTF-8"?>
+<nes20db date="2024-01-01">
+<game>
+<!-- Test Game (Europe) -->
+<rom size="40960" crc32="1234ABCD" sha1="0000000000000000000000000000000000000000"/>
+<prgrom size="32768" crc32="00000000"/>
+<chrrom size="8192" crc32="00000000"/>
+<prgnvram size="8192"/>
+<pcb mapper="4" submapper="1" mirroring="H" battery="1"/>
+<chrram size="32768"/>
+<console type="0" region="1"/>
+</game>
+<game>
+<!-- Too Big Mapper -->
+<rom size="8192" crc32="00000001"/>
+<pcb mapper="268" submapper="0"/>
+<console type="0" region="0"/>
+</game>
+</nes20db>`
+	defer func() {
+		gameDBMu.Lock()
+		delete(gameDB, 0x1234ABCD)
+		gameDBMu.Unlock()
+	}()
+	n, err := LoadNES20DB(strings.NewReader(db))
+	if err != nil || n != 1 {
+		t.Fatalf("LoadNES20DB = %d, %v; want 1 game", n, err)
+	}
+	want := GameInfo{
+		Mapper: 4, Submapper: 1, CHRRAMSize: 32768,
+		PRGROMSize: 32768, CHRROMSize: 8192, PRGNVRAMSize: 8192, Mirroring: "H", Battery: true,
+		Name: "Test Game (Europe)", Region: "PAL",
+	}
+	if got, ok := LookupGame(0x1234ABCD); !ok || got != want {
+		t.Errorf("LookupGame = %+v, %v; want %+v", got, ok, want)
+	}
+	if _, ok := LookupGame(1); ok {
+		t.Error("mapper 268 entry should be skipped")
+	}
+}
+
+func TestLoadGameDB(t *testing.T) {
+	const nes20db = `<nes20db date="2024-01-01">
+<game>
+<!-- Test Game (USA) -->
+<rom size="65536" crc32="0BAD0001"/>
+<pcb mapper="4" submapper="4" mirroring="V" battery="0"/>
+<console type="0" region="0"/>
+</game>
+</nes20db>`
+	const nescartdb = `<?xml version="1.0" encoding="UTF-8"?>
+<database version="1.0" conformance="strict">
+<game name="Test Game" region="USA">
+<cartridge system="NES-NTSC" crc="0BAD0001">
+<board type="NES-TLROM" mapper="4">
+<prg size="32k"/><chr size="32k"/>
+</board>
+</cartridge>
+<cartridge system="NES-PAL-B" crc="0BAD0002">
+<board type="NES-SNROM" mapper="1">
+<prg size="128k"/><vram size="8k"/><wram size="8k" battery="1"/>
+</board>
+</cartridge>
+</game>
+</database>`
+	defer func() {
+		gameDBMu.Lock()
+		delete(gameDB, 0x0BAD0001)
+		delete(gameDB, 0x0BAD0002)
+		gameDBMu.Unlock()
+	}()
+	// NesCartDB after nes20db adds the board and keeps the submapper; the
+	// other order keeps the board.
+	for _, order := range [][]string{{nes20db, nescartdb}, {nescartdb, nes20db}} {
+		for _, db := range order {
+			if _, err := LoadGameDB(strings.NewReader(db)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, _ := LookupGame(0x0BAD0001)
+		if got.Board != "NES-TLROM" || got.Submapper != 4 || got.Name != "Test Game (USA)" {
+			t.Errorf("merged entry %+v", got)
+		}
+	}
+	want := GameInfo{
+		Mapper: 1, PRGROMSize: 128 << 10, CHRRAMSize: 8 << 10, PRGNVRAMSize: 8 << 10, Battery: true,
+		Name: "Test Game", Region: "PAL", Board: "NES-SNROM",
+	}
+	if got, ok := LookupGame(0x0BAD0002); !ok || got != want {
+		t.Errorf("NesCartDB entry %+v, %v; want %+v", got, ok, want)
+	}
+
+	if _, err := LoadGameDB(strings.NewReader("<games/>")); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+// TestEmbeddedGameDB checks that the databases built in from gamedb/
+// parse: loadEmbedded has nowhere to report an error.
+func TestEmbeddedGameDB(t *testing.T) {
+	names, err := fs.Glob(embeddedDB, "gamedb/*.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		data, err := embeddedDB.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var games int
+		err = withGameDB(func() error {
+			games, err = loadGameDB(bytes.NewReader(data))
+			return err
+		})
+		if err != nil || games == 0 {
+			t.Errorf("%s: %d games, %v", name, games, err)
+		}
+	}
+}
+
+// withGameDB runs f and then puts the game database back as it was.
+func withGameDB(f func() error) error {
+	embeddedOnce.Do(loadEmbedded)
+	gameDBMu.Lock()
+	saved := make(map[uint32]GameInfo, len(gameDB))
+	for k, v := range gameDB {
+		saved[k] = v
+	}
+	gameDBMu.Unlock()
+	defer func() {
+		gameDBMu.Lock()
+		gameDB = saved
+		gameDBMu.Unlock()
+	}()
+	return f()
+}
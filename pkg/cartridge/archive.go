@@ -0,0 +1,123 @@
+package cartridge
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Container signatures sniffed by FindROMs. ROM sets are almost always
+// distributed compressed, so the loader recognises the common wrappers by
+// their leading bytes rather than trusting the file extension.
+var (
+	zipMagic        = []byte("PK\x03\x04")
+	zipEmptyMagic   = []byte("PK\x05\x06") // end-of-central-directory only: empty archive
+	gzipMagic       = []byte{0x1F, 0x8B}
+	sevenZipMagic   = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+	inesMagic       = []byte("NES\x1A")
+	errNoROMInZip   = errors.New("archive contains no .nes or .fds file")
+	errSevenZipArch = errors.New("7z archives are not supported; extract the .nes file first")
+)
+
+// ROMEntry is one iNES image located by FindROMs. Name is the entry path
+// inside the archive (or the original file name stored in a gzip header);
+// it is empty for a bare, uncompressed image.
+type ROMEntry struct {
+	Name string
+	Data []byte
+}
+
+// MultipleROMsError is returned by LoadFromReader when an archive holds
+// more than one .nes or .fds file and the caller therefore has to pick.
+// Names lists the candidates in archive order; pass the chosen entry from
+// FindROMs to LoadEntry.
+type MultipleROMsError struct {
+	Names []string
+}
+
+func (e *MultipleROMsError) Error() string {
+	return fmt.Sprintf("archive contains %d ROMs (%s); choose one", len(e.Names), strings.Join(e.Names, ", "))
+}
+
+// FindROMs unwraps data if it is a zip or gzip container and returns every
+// iNES or FDS image inside. Uncompressed input is returned as a single
+// unnamed entry without being validated — LoadEntry reports a bad header.
+//
+// Zip entries are selected by a .nes or .fds extension (case-insensitive);
+// when none match, entries that start with the iNES or FDS magic are used
+// instead so archives with odd naming ("Game (U).NES.bin") still load.
+func FindROMs(data []byte) ([]ROMEntry, error) {
+	switch {
+	case bytes.HasPrefix(data, zipMagic), bytes.HasPrefix(data, zipEmptyMagic):
+		return findZipROMs(data)
+	case bytes.HasPrefix(data, gzipMagic):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		defer zr.Close()
+		inner, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		return []ROMEntry{{Name: zr.Name, Data: inner}}, nil
+	case bytes.HasPrefix(data, sevenZipMagic):
+		return nil, errSevenZipArch
+	}
+	return []ROMEntry{{Data: data}}, nil
+}
+
+// findZipROMs collects the .nes and .fds members of a zip archive, falling
+// back to any member carrying the iNES or FDS magic.
+func findZipROMs(data []byte) ([]ROMEntry, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("zip: %w", err)
+	}
+
+	var named, sniffed []ROMEntry
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		ext := path.Ext(f.Name)
+		isImage := strings.EqualFold(ext, ".nes") || strings.EqualFold(ext, ".fds")
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("zip %s: %w", f.Name, err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("zip %s: %w", f.Name, err)
+		}
+		entry := ROMEntry{Name: f.Name, Data: body}
+		switch {
+		case isImage:
+			named = append(named, entry)
+		case bytes.HasPrefix(body, inesMagic), IsFDS(body):
+			sniffed = append(sniffed, entry)
+		}
+	}
+	if len(named) > 0 {
+		return named, nil
+	}
+	if len(sniffed) > 0 {
+		return sniffed, nil
+	}
+	return nil, errNoROMInZip
+}
+
+// LoadEntry builds a cartridge from one entry returned by FindROMs. A
+// disk image goes in the RAM adapter with the BIOS from SetFDSBIOS.
+func LoadEntry(e ROMEntry) (*Cartridge, error) {
+	if IsFDS(e.Data) {
+		return loadFDSEntry(e.Data)
+	}
+	return loadINES(e.Data)
+}
@@ -0,0 +1,120 @@
+package cartridge
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+)
+
+func buildZip(t *testing.T, files map[string][]byte, order []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range order {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip create %s: %v", name, err)
+		}
+		w.Write(files[name])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestLoadFromReaderZip(t *testing.T) {
+	rom := buildINES(2, 2, 0)
+	data := buildZip(t, map[string][]byte{
+		"readme.txt":   []byte("hello"),
+		"Game (U).NES": rom,
+	}, []string{"readme.txt", "Game (U).NES"})
+
+	cart, err := LoadFromReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("LoadFromReader(zip): %v", err)
+	}
+	if len(cart.PRGROM) != 2*16384 {
+		t.Errorf("PRG ROM = %d bytes, want %d", len(cart.PRGROM), 2*16384)
+	}
+}
+
+func TestLoadFromReaderZipSniffsMagic(t *testing.T) {
+	// No .nes extension anywhere: the member starting with NES\x1A wins.
+	data := buildZip(t, map[string][]byte{
+		"notes.txt": []byte("not a rom"),
+		"game.bin":  buildINES(0, 1, 1),
+	}, []string{"notes.txt", "game.bin"})
+
+	if _, err := LoadFromReader(bytes.NewReader(data)); err != nil {
+		t.Fatalf("LoadFromReader(zip without .nes): %v", err)
+	}
+}
+
+func TestLoadFromReaderZipMultiple(t *testing.T) {
+	data := buildZip(t, map[string][]byte{
+		"a.nes": buildINES(0, 1, 1),
+		"b.nes": buildINES(0, 2, 1),
+	}, []string{"a.nes", "b.nes"})
+
+	_, err := LoadFromReader(bytes.NewReader(data))
+	var multi *MultipleROMsError
+	if !errors.As(err, &multi) {
+		t.Fatalf("want *MultipleROMsError, got %v", err)
+	}
+	if len(multi.Names) != 2 || multi.Names[0] != "a.nes" || multi.Names[1] != "b.nes" {
+		t.Fatalf("Names = %v, want [a.nes b.nes]", multi.Names)
+	}
+
+	// The caller resolves the ambiguity through FindROMs + LoadEntry.
+	entries, err := FindROMs(data)
+	if err != nil {
+		t.Fatalf("FindROMs: %v", err)
+	}
+	cart, err := LoadEntry(entries[1])
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+	if len(cart.PRGROM) != 2*16384 {
+		t.Errorf("picked wrong entry: PRG ROM = %d bytes", len(cart.PRGROM))
+	}
+}
+
+func TestLoadFromReaderZipNoROM(t *testing.T) {
+	data := buildZip(t, map[string][]byte{"readme.txt": []byte("x")}, []string{"readme.txt"})
+	if _, err := LoadFromReader(bytes.NewReader(data)); !errors.Is(err, errNoROMInZip) {
+		t.Fatalf("want errNoROMInZip, got %v", err)
+	}
+}
+
+func TestLoadFromReaderGzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = "game.nes"
+	zw.Write(buildINES(3, 2, 2))
+	zw.Close()
+
+	entries, err := FindROMs(buf.Bytes())
+	if err != nil {
+		t.Fatalf("FindROMs(gzip): %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "game.nes" {
+		t.Fatalf("entries = %+v, want one named game.nes", entries)
+	}
+	cart, err := LoadFromReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("LoadFromReader(gzip): %v", err)
+	}
+	if len(cart.CHRROM) != 2*8192 {
+		t.Errorf("CHR ROM = %d bytes, want %d", len(cart.CHRROM), 2*8192)
+	}
+}
+
+func TestLoadFromReaderSevenZipRejected(t *testing.T) {
+	data := append([]byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}, make([]byte, 32)...)
+	if _, err := LoadFromReader(bytes.NewReader(data)); !errors.Is(err, errSevenZipArch) {
+		t.Fatalf("want errSevenZipArch, got %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 package cartridge
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"unsafe"
 
 	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
 )
@@ -37,8 +39,15 @@ type Cartridge struct {
 	// whether to route CPU R/W there to the mapper or leave open bus.
 	hasExpansion bool
 
+	// prgRAMGate caches the optional mapper.PRGRAMGate assertion.
+	prgRAMGate mapper.PRGRAMGate
+
+	// prgBanks is the mapper's live $8000-$FFFF window table when it
+	// implements mapper.PRGBankMapper, nil otherwise.
+	prgBanks *[4][]uint8
+
 	// hasIRQ is true when the mapper can assert the CPU IRQ line (it
-	// implements mapper.IRQCapable). nes.Step polls IsIRQPending every
+	// implements mapper.IRQCapable). nes.Step polls IRQLine every
 	// instruction; for carts whose mapper can never IRQ this stays false so
 	// that poll is skipped entirely.
 	hasIRQ bool
@@ -52,6 +61,17 @@ type Cartridge struct {
 	// PPU $2000 writes can tell the mapper whether 8×16 mode is on.
 	spriteSizeHinter mapper.SpriteSizeHinter
 
+	// addressWatcher caches the optional mapper.PPUAddressWatcher (MMC3's
+	// A12 edges, MMC2/MMC4 tile latches); watchesFetches is true when it
+	// also wants rendering pattern fetches (mapper.PatternFetchWatcher).
+	// The PPU asks WatchesPatternFetches once at SetCartridge and skips
+	// the per-fetch call for every other mapper.
+	addressWatcher mapper.PPUAddressWatcher
+	watchesFetches bool
+
+	// resetter caches the optional mapper.Resetter.
+	resetter mapper.Resetter
+
 	// scanlineNotifier caches the optional mapper.ScanlineNotifier
 	// so the PPU can hand MMC5 explicit per-scanline ticks (A12
 	// edges don't fire on games whose BG and sprites share a
@@ -60,6 +80,33 @@ type Cartridge struct {
 
 	// Mirroring
 	Mirroring MirroringMode
+
+	// mapperNumber is the mapper the cartridge runs as: the header's,
+	// or the game database's for an iNES 1.0 dump whose header names a
+	// board it is often mistaken for (misdumpedAs). submapper is the
+	// board variant the mapper was built for: from a NES 2.0 header, else
+	// from the game database, else 0.
+	mapperNumber uint8
+	submapper    uint8
+
+	// fds is the RAM adapter of a Disk System image (LoadFDS), and disk
+	// the image's sides as loaded, which Hashes identifies it by (PRG
+	// ROM is the BIOS every disk shares). Both are nil for a cartridge.
+	fds  *mapper.FDS
+	disk []uint8
+
+	// outWatcher caches the optional mapper.OUTLatchWatcher, and
+	// vsProtection is the protection chip of a VS. System board that has
+	// one (nil otherwise).
+	outWatcher   mapper.OUTLatchWatcher
+	vsProtection *vsProtection
+
+	// saveChip is the mapper's own save memory (mapper.NonVolatileMemory:
+	// flash, EEPROM), nil when it has none. dumpPRG is PRG ROM as loaded
+	// when that memory is PRG ROM itself (UNROM 512's flash), so Hashes
+	// identifies the game by the dump, not by what the game has written.
+	saveChip *chipMemory
+	dumpPRG  []uint8
 }
 
 // iNESHeader represents the iNES file header
@@ -72,69 +119,163 @@ type iNESHeader struct {
 	Flags8     uint8    // PRG-RAM size (rarely used)
 	Flags9     uint8    // TV system (rarely used)
 	Flags10    uint8    // TV system, PRG-RAM presence (unofficial)
-	Padding    [5]uint8 // Unused padding (should be zero)
+	Padding    [5]uint8 // Unused padding (should be zero); NES 2.0 byte 11 is Padding[0]
+}
+
+// IsNES20 reports whether the header is in NES 2.0 format (flags 7 bits
+// 2-3 = %10).
+func (h iNESHeader) IsNES20() bool {
+	return h.Flags7&0x0C == 0x08
+}
+
+// Submapper returns the NES 2.0 submapper number (byte 8 bits 4-7), or 0
+// for an iNES 1.0 header, where byte 8 means something else.
+func (h iNESHeader) Submapper() uint8 {
+	if !h.IsNES20() {
+		return 0
+	}
+	return h.Flags8 >> 4
+}
+
+// CHRRAMSize returns the volatile CHR RAM size in bytes from a NES 2.0
+// header (byte 11 bits 0-3, 64 << shift, 0 = none), or 0 for iNES 1.0.
+func (h iNESHeader) CHRRAMSize() int {
+	return nes20RAMSize(h, h.Padding[0]&0x0F)
+}
+
+// CHRNVRAMSize returns the battery-backed CHR RAM size in bytes from a
+// NES 2.0 header (byte 11 bits 4-7), or 0 for iNES 1.0.
+func (h iNESHeader) CHRNVRAMSize() int {
+	return nes20RAMSize(h, h.Padding[0]>>4)
+}
+
+func nes20RAMSize(h iNESHeader, shift uint8) int {
+	if !h.IsNES20() || shift == 0 {
+		return 0
+	}
+	return 64 << shift
+}
+
+// misdumpedAs maps a board to the mapper number old iNES 1.0 dumps of it
+// carry. For such a header the game database's entry names the board:
+// DxROM games (206) were long dumped as MMC3 (4), whose extra registers
+// they trip over.
+var misdumpedAs = map[uint8]uint8{206: 4}
+
+// looksLikeDxROM is the fallback for a mapper 4 dump the database doesn't
+// know: it runs as DxROM (206) when it fits the board — at most 128KB of
+// PRG and 64KB of CHR ROM, no battery — and its code writes the bank
+// select and data at $8000/$8001 but none of the registers MMC3 adds at
+// $A000-$E001. An MMC3 game that sets neither mirroring nor an IRQ loses
+// nothing on DxROM but its bank modes; a DxROM game whose data happens to
+// hold such a store stays MMC3, as without the check.
+func looksLikeDxROM(h iNESHeader, prg, chr []byte) bool {
+	if h.Flags6&0x02 != 0 || len(prg) > 128<<10 || len(chr) == 0 || len(chr) > 64<<10 {
+		return false
+	}
+	var bankSelect, bankData bool
+	for i := 0; i+2 < len(prg); i++ {
+		switch prg[i] {
+		case 0x8C, 0x8D, 0x8E: // STY/STA/STX absolute
+		default:
+			continue
+		}
+		lo, hi := prg[i+1], prg[i+2]
+		switch {
+		case lo > 1:
+		case hi == 0x80:
+			bankSelect = bankSelect || lo == 0
+			bankData = bankData || lo == 1
+		case hi == 0xA0 || hi == 0xC0 || hi == 0xE0:
+			return false
+		}
+	}
+	return bankSelect && bankData
 }
 
-// MirroringMode represents the mirroring mode
-type MirroringMode int
+// minCHRRAM is the CHR RAM allocated when the header doesn't say: the
+// whole $0000-$1FFF pattern space. Headers asking for less are rounded up
+// so no mapper's unbanked CHR RAM path falls off the end.
+const minCHRRAM = 0x2000
+
+// MirroringMode is a nametable arrangement, shared with the mappers and
+// the PPU (see mapper.MirroringMode).
+type MirroringMode = mapper.MirroringMode
 
 const (
-	MirroringHorizontal MirroringMode = iota
-	MirroringVertical
-	MirroringFourScreen
-	MirroringSingleScreenA
-	MirroringSingleScreenB
+	MirroringHorizontal        = mapper.MirroringHorizontal
+	MirroringVertical          = mapper.MirroringVertical
+	MirroringSingleScreenLower = mapper.MirroringSingleScreenLower
+	MirroringSingleScreenUpper = mapper.MirroringSingleScreenUpper
+	MirroringFourScreen        = mapper.MirroringFourScreen
 )
 
-// LoadFromReader loads a cartridge from an iNES file
+// LoadFromReader loads a cartridge from an iNES file or an FDS disk image
+// (see LoadEntry). The stream may also be a zip or gzip archive wrapping
+// the image (see FindROMs); a zip holding several images yields a
+// *MultipleROMsError so the caller can choose.
 func LoadFromReader(reader io.Reader) (*Cartridge, error) {
-	cart := &Cartridge{}
-
-	// Read header
-	err := cart.readHeader(reader)
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ROM: %w", err)
+	}
+	entries, err := FindROMs(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read header: %w", err)
+		return nil, err
+	}
+	if len(entries) > 1 {
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name
+		}
+		return nil, &MultipleROMsError{Names: names}
 	}
+	return LoadEntry(entries[0])
+}
 
-	// Validate header
-	if string(cart.Header.Magic[:]) != "NES\x1A" {
-		return nil, fmt.Errorf("invalid iNES magic number")
+// loadINES parses a raw (uncompressed) iNES image. The sizes the header
+// declares are checked against the image before anything is copied, so a
+// damaged or partly downloaded file gives one of the errors in errors.go.
+func loadINES(data []byte) (*Cartridge, error) {
+	cart := &Cartridge{}
+
+	if !bytes.HasPrefix(data, inesMagic) {
+		if len(data) < len(inesMagic) && bytes.HasPrefix(inesMagic, data) {
+			return nil, fmt.Errorf("%w: %d bytes", ErrTruncatedHeader, len(data))
+		}
+		return nil, fmt.Errorf("%w (starts % X)", ErrBadMagic, data[:min(len(data), len(inesMagic))])
+	}
+	if len(data) < 16 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTruncatedHeader, len(data))
+	}
+	cart.readHeader(data)
+	if cart.Header.PRGROMSize == 0 {
+		return nil, ErrNoPRG
 	}
 
-	mapperNumber := (cart.Header.Flags6 >> 4) | (cart.Header.Flags7 & 0xF0)
+	mapperNumber := cart.Header.MapperNumber()
 
-	// Skip trainer if present
+	// The trainer, if present, sits between the header and PRG ROM;
+	// nothing uses it.
+	off := 16
 	if cart.Header.Flags6&0x04 != 0 {
-		trainer := make([]uint8, 512)
-		_, err := io.ReadFull(reader, trainer)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read trainer: %w", err)
-		}
+		off += 512
 	}
 
-	// Read PRG ROM
 	prgSize := int(cart.Header.PRGROMSize) * 16384
-	cart.PRGROM = make([]uint8, prgSize)
-	_, err = io.ReadFull(reader, cart.PRGROM)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read PRG ROM: %w", err)
+	prgEnd := off + prgSize
+	if len(data) < prgEnd {
+		return nil, truncated(ErrTruncatedPRG, "PRG ROM", prgSize, prgEnd, len(data))
 	}
+	cart.PRGROM = bytes.Clone(data[off:prgEnd])
 
-	// Read CHR ROM
 	chrSize := int(cart.Header.CHRROMSize) * 8192
+	chrEnd := prgEnd + chrSize
+	if len(data) < chrEnd {
+		return nil, truncated(ErrTruncatedCHR, "CHR ROM", chrSize, chrEnd, len(data))
+	}
 	if chrSize > 0 {
-		cart.CHRROM = make([]uint8, chrSize)
-		_, err = io.ReadFull(reader, cart.CHRROM)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read CHR ROM: %w", err)
-		}
-	} else {
-		// CHR RAM size — MMC3 games often expect 32KB, others use 8KB
-		chrRAMSize := 8192
-		if mapperNumber == 4 {
-			chrRAMSize = 32768
-		}
-		cart.CHRRAM = make([]uint8, chrRAMSize)
+		cart.CHRROM = bytes.Clone(data[prgEnd:chrEnd])
 	}
 
 	// PRG RAM: 32KB for battery-backed carts (e.g. Final Fantasy II), 8KB
@@ -149,7 +290,11 @@ func LoadFromReader(reader io.Reader) (*Cartridge, error) {
 	}
 
 	// Determine mirroring
-	if cart.Header.Flags6&0x08 != 0 {
+	if cart.Header.Flags6&0x09 == 0x08 && mapperNumber == 30 {
+		// UNROM 512 reads the four-screen bit alone as one-screen
+		// mirroring switched by its bank register.
+		cart.Mirroring = MirroringSingleScreenLower
+	} else if cart.Header.Flags6&0x08 != 0 {
 		cart.Mirroring = MirroringFourScreen
 	} else if cart.Header.Flags6&0x01 != 0 {
 		cart.Mirroring = MirroringVertical
@@ -157,16 +302,62 @@ func LoadFromReader(reader io.Reader) (*Cartridge, error) {
 		cart.Mirroring = MirroringHorizontal
 	}
 
-	mapperData := &mapper.CartridgeData{
-		PRGROM: cart.PRGROM,
-		CHRROM: cart.CHRROM,
-		PRGRAM: cart.PRGRAM,
-		CHRRAM: cart.CHRRAM,
+	// CHR RAM: the NES 2.0 volatile and battery-backed sizes (both live in
+	// the one array; the battery half isn't saved to .sav), else 8KB.
+	chrRAMSize := cart.Header.CHRRAMSize() + cart.Header.CHRNVRAMSize()
+
+	// An iNES 1.0 header has no submapper; the game database supplies it,
+	// and the board itself when the header names one it is often
+	// mistaken for. A mapper 4 dump it doesn't know is checked for DxROM.
+	cart.submapper = cart.Header.Submapper()
+	info, known := GameInfo{}, false
+	if !cart.Header.IsNES20() {
+		info, known = LookupGame(ROMCRC32(cart.PRGROM, cart.CHRROM))
+		if as, ok := misdumpedAs[info.Mapper]; known && ok && as == mapperNumber {
+			mapperNumber = info.Mapper
+		} else if !known && mapperNumber == 4 && looksLikeDxROM(cart.Header, cart.PRGROM, cart.CHRROM) {
+			mapperNumber = 206
+		}
+		if known = known && info.Mapper == mapperNumber; known {
+			cart.submapper = info.Submapper
+		}
 	}
+	cart.mapperNumber = mapperNumber
 
-	cart.Mapper, err = mapper.NewMapper(mapperNumber, mapperData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create mapper: %w", err)
+	board, ok := mapper.Lookup(mapperNumber)
+	if !ok {
+		return nil, &ErrUnsupportedMapper{N: int(mapperNumber)}
+	}
+	if !cart.Header.IsNES20() {
+		chrRAMSize = board.CHRRAM
+		if known {
+			chrRAMSize = info.CHRRAMSize
+		}
+	}
+	if chrSize == 0 {
+		if chrRAMSize < minCHRRAM {
+			chrRAMSize = minCHRRAM
+		}
+		cart.CHRRAM = make([]uint8, chrRAMSize)
+	}
+
+	mapperData := &mapper.CartridgeData{
+		PRGROM:    cart.PRGROM,
+		CHRROM:    cart.CHRROM,
+		PRGRAM:    cart.PRGRAM,
+		CHRRAM:    cart.CHRRAM,
+		Submapper: cart.submapper,
+		Mirroring: cart.Mirroring,
+		Battery:   cart.HasBattery(),
+	}
+	cart.Mapper = board.New(mapperNumber, mapperData)
+	if nv, ok := cart.Mapper.(mapper.NonVolatileMemory); ok {
+		if data, kind := nv.NonVolatile(); len(data) > 0 {
+			cart.saveChip = &chipMemory{data: data, kind: kind}
+			if len(data) == len(cart.PRGROM) && &data[0] == &cart.PRGROM[0] {
+				cart.dumpPRG = bytes.Clone(cart.PRGROM)
+			}
+		}
 	}
 	if t, ok := cart.Mapper.(mapper.CPUTicker); ok {
 		cart.cpuTicker = t
@@ -183,25 +374,88 @@ func LoadFromReader(reader io.Reader) (*Cartridge, error) {
 	if h, ok := cart.Mapper.(mapper.SpriteSizeHinter); ok {
 		cart.spriteSizeHinter = h
 	}
+	if w, ok := cart.Mapper.(mapper.PPUAddressWatcher); ok {
+		cart.addressWatcher = w
+		_, cart.watchesFetches = cart.Mapper.(mapper.PatternFetchWatcher)
+	}
+	if r, ok := cart.Mapper.(mapper.Resetter); ok {
+		cart.resetter = r
+	}
 	if n, ok := cart.Mapper.(mapper.ScanlineNotifier); ok {
 		cart.scanlineNotifier = n
 	}
 	if _, ok := cart.Mapper.(mapper.IRQCapable); ok {
 		cart.hasIRQ = true
 	}
+	if g, ok := cart.Mapper.(mapper.PRGRAMGate); ok {
+		cart.prgRAMGate = g
+	}
+	if b, ok := cart.Mapper.(mapper.PRGBankMapper); ok {
+		cart.prgBanks = b.PRGBanks()
+	}
+	if w, ok := cart.Mapper.(mapper.OUTLatchWatcher); ok {
+		cart.outWatcher = w
+	}
+	switch kind := cart.Header.VSHardwareType(); kind {
+	case vsRBIBaseball, vsTKOBoxing, vsSuperXevious:
+		cart.vsProtection = &vsProtection{kind: kind}
+		cart.hasExpansion = true
+	}
 
 	return cart, nil
 }
 
+// MapperNumber returns the mapper the cartridge runs as (see the
+// mapperNumber field), which can differ from the header's.
+func (c *Cartridge) MapperNumber() uint8 { return c.mapperNumber }
+
+// Submapper returns the board variant the mapper was configured for (see
+// the submapper field).
+func (c *Cartridge) Submapper() uint8 { return c.submapper }
+
 // HasIRQ reports whether the mapper can assert the CPU IRQ line. False for
 // mappers (NROM, UxROM, CNROM, …) that never IRQ, letting nes.Step skip its
-// per-instruction IsIRQPending poll for those carts.
+// per-instruction IRQLine poll for those carts.
 func (c *Cartridge) HasIRQ() bool { return c.hasIRQ }
 
 // HasExpansion reports whether the mapper decodes the $4020-$5FFF
 // cartridge-expansion window.
 func (c *Cartridge) HasExpansion() bool { return c.hasExpansion }
 
+// PRGBanks returns the mapper's live table of 8KB PRG ROM windows for
+// $8000-$FFFF, or nil when the mapper resolves PRG reads itself. The
+// memory bus indexes it directly instead of calling ReadPRG.
+func (c *Cartridge) PRGBanks() *[4][]uint8 { return c.prgBanks }
+
+// PRGOffset returns the offset in PRGROM of the byte the CPU sees at addr,
+// telling apart code that different banks map to the same address — what
+// bank-aware symbols and debuggers need. It is -1 below $8000, for an
+// unmapped window, and for mappers without a PRGBanks table, which
+// resolve their banks inside ReadPRG.
+func (c *Cartridge) PRGOffset(addr uint16) int {
+	if addr < 0x8000 || c.prgBanks == nil || len(c.PRGROM) == 0 {
+		return -1
+	}
+	w := c.prgBanks[(addr>>13)&3]
+	if len(w) == 0 {
+		return -1
+	}
+	// Every window is a slice of PRGROM, so the distance between their
+	// first bytes is the bank's offset.
+	off := int(uintptr(unsafe.Pointer(&w[0])) - uintptr(unsafe.Pointer(&c.PRGROM[0])))
+	if off < 0 || off >= len(c.PRGROM) {
+		return -1
+	}
+	return off + int(addr&0x1FFF)
+}
+
+// PRGRAMEnabled reports whether $6000-$7FFF is currently driven by the
+// cartridge. Always true unless the mapper has a RAM enable bit
+// (mapper.PRGRAMGate) and it is off.
+func (c *Cartridge) PRGRAMEnabled() bool {
+	return c.prgRAMGate == nil || c.prgRAMGate.PRGRAMEnabled()
+}
+
 // ReadCHRSprite routes sprite pattern fetches through the mapper's
 // sprite-specific CHR path when available (MMC5 8×16 mode), falling
 // back to the unified ReadCHR for every other mapper.
@@ -228,14 +482,8 @@ func (c *Cartridge) NotifyScanline(scanline int, renderingEnabled bool) {
 	}
 }
 
-// readHeader reads the iNES header
-func (c *Cartridge) readHeader(reader io.Reader) error {
-	headerBytes := make([]uint8, 16)
-	_, err := io.ReadFull(reader, headerBytes)
-	if err != nil {
-		return err
-	}
-
+// readHeader decodes the 16-byte iNES header at the start of headerBytes.
+func (c *Cartridge) readHeader(headerBytes []byte) {
 	copy(c.Header.Magic[:], headerBytes[0:4])
 	c.Header.PRGROMSize = headerBytes[4]
 	c.Header.CHRROMSize = headerBytes[5]
@@ -245,12 +493,13 @@ func (c *Cartridge) readHeader(reader io.Reader) error {
 	c.Header.Flags9 = headerBytes[9]
 	c.Header.Flags10 = headerBytes[10]
 	copy(c.Header.Padding[:], headerBytes[11:16])
-
-	return nil
 }
 
 // ReadPRG reads from PRG space
 func (c *Cartridge) ReadPRG(addr uint16) uint8 {
+	if c.vsProtection != nil && addr >= 0x5000 && addr < 0x6000 {
+		return c.vsProtection.read(addr)
+	}
 	if c.Mapper != nil {
 		return c.Mapper.ReadPRG(addr)
 	}
@@ -296,19 +545,19 @@ func (c *Cartridge) TickCPU(cycles int) {
 	}
 }
 
-// AudioSample returns the cartridge's expansion-sound mixer output
-// (0 when the mapper has no audio chip). Used by the APU.
-func (c *Cartridge) AudioSample() float32 {
-	if c.audioSource != nil {
-		return c.audioSource.AudioSample()
+// ExpansionAudio returns the cartridge's sound chip, or nil when the
+// mapper has none, so the APU mixes nothing for ordinary carts.
+func (c *Cartridge) ExpansionAudio() ExpansionAudio {
+	if c.audioSource == nil {
+		return nil
 	}
-	return 0
+	return c.audioSource
 }
 
-// IsIRQPending returns whether mapper IRQ is pending
-func (c *Cartridge) IsIRQPending() bool {
+// IRQLine reports whether the mapper is holding the CPU /IRQ line low.
+func (c *Cartridge) IRQLine() bool {
 	if c.Mapper != nil {
-		return c.Mapper.IsIRQPending()
+		return c.Mapper.IRQLine()
 	}
 	return false
 }
@@ -320,16 +569,31 @@ func (c *Cartridge) ClearIRQ() {
 	}
 }
 
-// NotifyA12 notifies the mapper of A12 line state for MMC3 IRQ timing
-func (c *Cartridge) NotifyA12(chrAddr uint16, renderingEnabled bool) {
-	if c.Mapper == nil {
-		return
+// Reset returns the mapper's power-up-cleared state (IRQ counters and
+// flags) for a console power cycle; see mapper.Resetter. Banks and RAM
+// are kept.
+func (c *Cartridge) Reset() {
+	if c.resetter != nil {
+		c.resetter.Reset()
 	}
-	if n, ok := c.Mapper.(mapper.A12Notifier); ok {
-		n.NotifyA12(chrAddr, renderingEnabled)
+	if c.vsProtection != nil {
+		c.vsProtection.counter = 0
 	}
 }
 
+// PPUAddressBus tells the mapper the PPU has put addr on its address bus.
+// A no-op unless the mapper implements mapper.PPUAddressWatcher.
+func (c *Cartridge) PPUAddressBus(addr uint16) {
+	if c.addressWatcher != nil {
+		c.addressWatcher.PPUAddressBus(addr)
+	}
+}
+
+// WatchesPatternFetches reports whether the mapper wants PPUAddressBus
+// for rendering pattern fetches as well, so the PPU can skip the call
+// per fetch when it doesn't.
+func (c *Cartridge) WatchesPatternFetches() bool { return c.watchesFetches }
+
 // HasBattery reports whether the cartridge has battery-backed PRG RAM (iNES
 // header flag 6 bit 1). Games with this flag (Zelda, Final Fantasy, etc.)
 // expect SRAM contents to persist across power cycles.
@@ -337,6 +601,65 @@ func (c *Cartridge) HasBattery() bool {
 	return c.Header.Flags6&0x02 != 0
 }
 
+// NonVolatile returns the memory the cartridge keeps with the power off,
+// for the save manager's .sav file: the disk of a disk image so what the
+// game writes to it persists, the mapper's own save chip, else PRG RAM
+// when the header has the battery bit. Nil when there is none.
+func (c *Cartridge) NonVolatile() NonVolatile {
+	switch {
+	case c.fds != nil:
+		return fdsDisk{c.fds}
+	case c.saveChip != nil:
+		return c.saveChip
+	case c.HasBattery():
+		return batteryRAM{c}
+	}
+	return nil
+}
+
+// Battery is NonVolatile as a BatteryBacked, for callers from before
+// there were save chips.
+func (c *Cartridge) Battery() BatteryBacked {
+	if nv := c.NonVolatile(); nv != nil {
+		return nv
+	}
+	return nil
+}
+
+// batteryRAM is the NonVolatile of battery-backed PRG RAM.
+type batteryRAM struct{ *Cartridge }
+
+func (batteryRAM) Kind() string { return "PRG RAM" }
+
+// chipMemory is the NonVolatile of a mapper's save chip: the .sav file
+// holds its whole contents.
+type chipMemory struct {
+	data []uint8
+	kind string
+}
+
+func (m *chipMemory) Kind() string { return m.kind }
+
+func (m *chipMemory) SaveRAM(w io.Writer) error {
+	_, err := w.Write(m.data)
+	return err
+}
+
+// LoadRAM takes only a save the chip's size: anything else, such as the
+// PRG RAM an older build saved for the battery bit, could overwrite a
+// flash chip's code with garbage.
+func (m *chipMemory) LoadRAM(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) != len(m.data) {
+		return fmt.Errorf("save is %d bytes, the %s is %d", len(data), m.kind, len(m.data))
+	}
+	copy(m.data, data)
+	return nil
+}
+
 // SaveState writes the cartridge's writable RAM regions (PRG RAM + CHR RAM)
 // to w, then delegates mapper-internal register state to any mapper that
 // implements mapper.Stateful. PRG/CHR ROM are immutable and re-loaded from
@@ -354,6 +677,18 @@ func (c *Cartridge) SaveState(w io.Writer) error {
 	return nil
 }
 
+// AppendState appends the bytes SaveState writes to b, provided the mapper
+// implements mapper.StateAppender alongside mapper.Stateful (every mapper
+// in this tree does).
+func (c *Cartridge) AppendState(b []byte) []byte {
+	b = append(b, c.PRGRAM...)
+	b = append(b, c.CHRRAM...)
+	if sa, ok := c.Mapper.(mapper.StateAppender); ok {
+		return sa.AppendState(b)
+	}
+	return b
+}
+
 // LoadState restores PRG/CHR RAM contents in-place (so mappers that hold
 // slice references to the same backing arrays see the update), then
 // delegates to any mapper that implements mapper.Stateful.
@@ -399,19 +734,17 @@ func (c *Cartridge) LoadRAM(r io.Reader) error {
 }
 
 // GetMirroring returns the current mirroring mode
-func (c *Cartridge) GetMirroring() int {
+func (c *Cartridge) GetMirroring() MirroringMode {
+	// A four-screen board's own nametable RAM overrides any mirroring
+	// register the mapper has (MMC3 on Rad Racer II, Gauntlet)
+	if c.Mirroring == MirroringFourScreen {
+		return MirroringFourScreen
+	}
 	// Some mappers (like MMC1, MMC3) can change mirroring dynamically
 	if m, ok := c.Mapper.(mapper.MirroringSource); ok {
-		return int(m.GetMirroringMode())
+		return m.GetMirroringMode()
 	}
 
 	// Fall back to cartridge header mirroring
-	switch c.Mirroring {
-	case MirroringHorizontal:
-		return 0
-	case MirroringVertical:
-		return 1
-	default:
-		return 0 // Default to horizontal
-	}
+	return c.Mirroring
 }
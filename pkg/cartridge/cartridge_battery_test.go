@@ -18,13 +18,16 @@ func newBatteryCart(t *testing.T) *Cartridge {
 
 func TestHasBattery(t *testing.T) {
 	c := &Cartridge{}
-	if c.HasBattery() {
-		t.Error("expected HasBattery=false when flag bit 1 is clear")
+	if c.HasBattery() || c.NonVolatile() != nil || c.Battery() != nil {
+		t.Error("expected HasBattery=false and no NonVolatile when flag bit 1 is clear")
 	}
 	c.Header.Flags6 = 0x02
 	if !c.HasBattery() {
 		t.Error("expected HasBattery=true when flag bit 1 is set")
 	}
+	if nv := c.NonVolatile(); nv == nil || nv.Kind() != "PRG RAM" {
+		t.Errorf("NonVolatile = %v, want the PRG RAM", nv)
+	}
 }
 
 func TestSaveLoadRoundTrip(t *testing.T) {
@@ -73,3 +76,52 @@ func TestSaveLoadEmptyRAM(t *testing.T) {
 		t.Errorf("LoadRAM with no RAM: %v", err)
 	}
 }
+
+// TestFlashSave covers UNROM 512 with flash: its .sav is the whole PRG
+// ROM as the game rewrote it, while Hashes keeps naming the dump.
+func TestFlashSave(t *testing.T) {
+	rom := buildINESFlags(30, 4, 0, 0x02|0x08, false) // battery, one-screen
+	for i := 16; i < len(rom); i++ {
+		rom[i] = 0xFF // erased flash
+	}
+	cart, err := LoadFromReader(bytes.NewReader(rom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart.CHRRAM) != 32768 {
+		t.Errorf("CHR RAM = %d, want UNROM 512's 32768", len(cart.CHRRAM))
+	}
+	if got := cart.GetMirroring(); got != MirroringSingleScreenLower {
+		t.Errorf("mirroring = %d, want one-screen", got)
+	}
+	hashes := cart.Hashes()
+
+	// Program $12 at offset 0: unlock, $A0, byte.
+	for _, w := range []struct {
+		addr  uint16
+		value uint8
+	}{{0xC000, 1}, {0x9555, 0xAA}, {0xC000, 0}, {0xAAAA, 0x55}, {0xC000, 1}, {0x9555, 0xA0}, {0xC000, 0}, {0x8000, 0x12}} {
+		cart.WritePRG(w.addr, w.value)
+	}
+	if cart.PRGROM[0] != 0x12 {
+		t.Fatalf("flash not programmed: %02X", cart.PRGROM[0])
+	}
+	if cart.Hashes() != hashes {
+		t.Error("Hashes changed with the flash contents")
+	}
+
+	if kind := cart.NonVolatile().Kind(); kind != "flash" {
+		t.Errorf("NonVolatile is %s, want flash", kind)
+	}
+	var sav bytes.Buffer
+	if err := cart.NonVolatile().SaveRAM(&sav); err != nil || sav.Len() != len(cart.PRGROM) {
+		t.Fatalf("SaveRAM: %d bytes, %v", sav.Len(), err)
+	}
+	fresh, _ := LoadFromReader(bytes.NewReader(rom))
+	if err := fresh.Battery().LoadRAM(&sav); err != nil || fresh.PRGROM[0] != 0x12 {
+		t.Errorf("LoadRAM: %v, PRG[0] = %02X", err, fresh.PRGROM[0])
+	}
+	if err := fresh.Battery().LoadRAM(bytes.NewReader(make([]byte, 8192))); err == nil || fresh.PRGROM[0] != 0x12 {
+		t.Errorf("a PRG RAM-sized save should be refused, got %v", err)
+	}
+}
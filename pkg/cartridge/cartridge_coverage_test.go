@@ -2,6 +2,7 @@ package cartridge
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
@@ -35,6 +36,9 @@ func TestCartridgeWrappers(t *testing.T) {
 	if cart.HasExpansion() {
 		t.Error("MMC3 cart should not decode expansion space")
 	}
+	if cart.WatchesPatternFetches() {
+		t.Error("MMC3 cart should not ask for pattern-fetch notifications")
+	}
 
 	// PRG/CHR pass-throughs.
 	_ = cart.ReadPRG(0x8000)
@@ -45,13 +49,16 @@ func TestCartridgeWrappers(t *testing.T) {
 
 	// IRQ / timing wrappers.
 	cart.Step()
-	cart.NotifyA12(0x1000, true)
+	cart.PPUAddressBus(0x1000)
 	cart.NotifyScanline(10, true)
 	cart.TickCPU(3)
-	_ = cart.IsIRQPending()
+	_ = cart.IRQLine()
 	cart.ClearIRQ()
-	if cart.AudioSample() != 0 {
-		t.Error("non-expansion cart should produce 0 audio sample")
+	if cart.ExpansionAudio() != nil {
+		t.Error("non-expansion cart should have no expansion audio")
+	}
+	if cart.Battery() != nil {
+		t.Error("cart without the battery flag should have no battery RAM")
 	}
 	cart.SetSpriteSize(true) // no hinter -> no-op
 	_ = cart.GetMirroring()
@@ -77,7 +84,7 @@ func TestCartridgeWrappers(t *testing.T) {
 }
 
 func TestCartridgeNonIRQMapper(t *testing.T) {
-	// NROM (mapper 0): HasIRQ false, IsIRQPending false.
+	// NROM (mapper 0): HasIRQ false, IRQLine false.
 	cart, err := LoadFromReader(bytes.NewReader(buildINES(0, 2, 1)))
 	if err != nil {
 		t.Fatalf("LoadFromReader: %v", err)
@@ -85,11 +92,105 @@ func TestCartridgeNonIRQMapper(t *testing.T) {
 	if cart.HasIRQ() {
 		t.Error("NROM should report no IRQ")
 	}
-	if cart.IsIRQPending() {
+	if cart.IRQLine() {
 		t.Error("NROM should never have a pending IRQ")
 	}
 }
 
+func TestCartridgeCHRFetchHook(t *testing.T) {
+	// MMC2 (mapper 9), 128KB CHR: banks 0 and 1 of the low table tagged.
+	rom := buildINES(9, 8, 16)
+	chr := rom[16+8*16384:]
+	chr[0x0000], chr[0x1000] = 0xB0, 0xB1
+	cart, err := LoadFromReader(bytes.NewReader(rom))
+	if err != nil {
+		t.Fatalf("LoadFromReader: %v", err)
+	}
+	if !cart.WatchesPatternFetches() {
+		t.Fatal("MMC2 cart should watch pattern fetches")
+	}
+	cart.WritePRG(0xB000, 0) // $FD bank
+	cart.WritePRG(0xC000, 1) // $FE bank
+	if got := cart.ReadCHR(0); got != 0xB1 {
+		t.Errorf("latch $FE: $0000 = %#02x, want 0xB1", got)
+	}
+	cart.PPUAddressBus(0x0FD8)
+	if got := cart.ReadCHR(0); got != 0xB0 {
+		t.Errorf("after $0FD8 fetch: $0000 = %#02x, want 0xB0", got)
+	}
+}
+
+func TestCartridgeMirroring(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mapper uint8
+		flags6 uint8
+		want   MirroringMode
+	}{
+		{"NROM horizontal", 0, 0x00, MirroringHorizontal},
+		{"NROM vertical", 0, 0x01, MirroringVertical},
+		{"NROM four-screen", 0, 0x08, MirroringFourScreen},
+		{"MMC3 register", 4, 0x00, MirroringVertical}, // $A000 = 0 at power-up
+		{"MMC3 four-screen board", 4, 0x08, MirroringFourScreen},
+	} {
+		rom := buildINES(tc.mapper, 2, 1)
+		rom[6] |= tc.flags6
+		cart, err := LoadFromReader(bytes.NewReader(rom))
+		if err != nil {
+			t.Fatalf("%s: LoadFromReader: %v", tc.name, err)
+		}
+		if got := cart.GetMirroring(); got != tc.want {
+			t.Errorf("%s: GetMirroring = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCartridgeResetClearsMapperIRQ(t *testing.T) {
+	cart, err := LoadFromReader(bytes.NewReader(buildINES(4, 2, 1)))
+	if err != nil {
+		t.Fatalf("LoadFromReader: %v", err)
+	}
+	cart.WritePRG(0xC000, 0) // latch 0: IRQ on every clock
+	cart.WritePRG(0xC001, 0)
+	cart.WritePRG(0xE001, 0)
+	cart.PPUAddressBus(0x0000)
+	cart.PPUAddressBus(0x1000) // A12 rise
+	if !cart.IRQLine() {
+		t.Fatal("A12 rise with IRQs enabled should assert the IRQ line")
+	}
+	cart.Reset()
+	if cart.IRQLine() {
+		t.Error("Reset should release the IRQ line")
+	}
+	cart.PPUAddressBus(0x0000)
+	cart.PPUAddressBus(0x1000)
+	if cart.IRQLine() {
+		t.Error("IRQs should be disabled after Reset")
+	}
+
+	// Mappers without a Resetter just ignore it.
+	nrom, err := LoadFromReader(bytes.NewReader(buildINES(0, 2, 1)))
+	if err != nil {
+		t.Fatalf("LoadFromReader: %v", err)
+	}
+	nrom.Reset()
+}
+
+func TestCartridgeOptionalCapabilities(t *testing.T) {
+	rom := buildINES(69, 2, 1)
+	rom[6] |= 0x02 // battery
+	cart, err := LoadFromReader(bytes.NewReader(rom))
+	if err != nil {
+		t.Fatalf("LoadFromReader: %v", err)
+	}
+	if cart.ExpansionAudio() == nil {
+		t.Error("FME-7 cart should expose its sound chip")
+	}
+	if cart.Battery() == nil {
+		t.Error("battery-flagged cart should expose its RAM")
+	}
+}
+
 func TestLoadFromReaderRejectsBadMagic(t *testing.T) {
 	if _, err := LoadFromReader(bytes.NewReader([]byte("not-an-ines-image"))); err == nil {
 		t.Error("LoadFromReader should reject a bad magic header")
@@ -139,12 +240,248 @@ func TestLoadFromReaderHeaderVariants(t *testing.T) {
 		t.Errorf("vertical-mirror ROM: %v", err)
 	}
 
-	// MMC3 with no CHR ROM allocates 32KB CHR RAM.
+	// An iNES 1.0 image without CHR ROM gets 8KB of CHR RAM, whatever
+	// the mapper.
 	cart, err = LoadFromReader(bytes.NewReader(buildINES(4, 2, 0)))
 	if err != nil {
 		t.Fatalf("MMC3 CHR-RAM ROM: %v", err)
 	}
+	if len(cart.CHRRAM) != 8192 {
+		t.Errorf("MMC3 CHR RAM = %d, want 8192", len(cart.CHRRAM))
+	}
+}
+
+func TestCHRRAMSizeFromHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		nes20  bool
+		byte11 uint8
+		want   int
+	}{
+		{"iNES 1.0 ignores byte 11", false, 0x09, 8192},
+		{"NES 2.0 no size", true, 0x00, 8192},
+		{"NES 2.0 8KB", true, 0x07, 8192},
+		{"NES 2.0 16KB", true, 0x08, 16384},
+		{"NES 2.0 32KB", true, 0x09, 32768},
+		{"NES 2.0 8KB + 8KB battery", true, 0x77, 16384},
+		{"NES 2.0 2KB rounded up", true, 0x05, 8192},
+	} {
+		rom := buildINES(1, 2, 0)
+		if tc.nes20 {
+			rom[7] |= 0x08
+		}
+		rom[11] = tc.byte11
+		cart, err := LoadFromReader(bytes.NewReader(rom))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(cart.CHRRAM) != tc.want {
+			t.Errorf("%s: CHR RAM = %d, want %d", tc.name, len(cart.CHRRAM), tc.want)
+		}
+	}
+
+	// The database can size CHR RAM for an iNES 1.0 dump.
+	crc := ROMCRC32(make([]byte, 32768), nil)
+	RegisterGame(crc, GameInfo{Mapper: 4, CHRRAMSize: 32768})
+	defer func() {
+		gameDBMu.Lock()
+		delete(gameDB, crc)
+		gameDBMu.Unlock()
+	}()
+	cart, err := LoadFromReader(bytes.NewReader(buildINES(4, 2, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(cart.CHRRAM) != 32768 {
-		t.Errorf("MMC3 CHR RAM = %d, want 32768", len(cart.CHRRAM))
+		t.Errorf("database CHR RAM = %d, want 32768", len(cart.CHRRAM))
+	}
+}
+
+// Reload rebuilds a loaded cartridge with database entries added since,
+// trainer and all.
+func TestReloadPicksUpGameDB(t *testing.T) {
+	img := buildINESFlags(4, 2, 1, 0x04, true)
+	img[16+512] = 0xA5 // a dump of its own, so the entry below is ours
+	cart, err := LoadFromReader(bytes.NewReader(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crc := ROMCRC32(cart.PRGROM, cart.CHRROM)
+	RegisterGame(crc, GameInfo{Mapper: 4, Submapper: 4})
+	defer func() {
+		gameDBMu.Lock()
+		delete(gameDB, crc)
+		gameDBMu.Unlock()
+	}()
+	again, err := cart.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cart.Submapper() != 0 || again.Submapper() != 4 {
+		t.Errorf("submapper %d before reload, %d after; want 0 and 4", cart.Submapper(), again.Submapper())
+	}
+	if again.Hashes() != cart.Hashes() {
+		t.Error("reloaded cartridge has different ROM contents")
+	}
+}
+
+// 16KB and 32KB of CHR RAM are banked like CHR ROM by mappers with CHR
+// bank registers, not just MMC3.
+func TestLargeCHRRAMBanking(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		mapper     uint8
+		byte11     uint8
+		bank       int // offset from the end of CHR RAM of the bank selectBank maps at $0000
+		selectBank func(c *Cartridge)
+	}{
+		{"MMC1 32KB", 1, 0x09, 4096, func(c *Cartridge) {
+			mmc1Write(c, 0x8000, 0x10) // 4KB CHR mode
+			mmc1Write(c, 0xA000, 7)    // 4KB bank 7 at $0000
+		}},
+		{"FME-7 16KB", 69, 0x08, 1024, func(c *Cartridge) {
+			c.WritePRG(0x8000, 0)
+			c.WritePRG(0xA000, 15)
+		}},
+		{"VRC4 32KB", 21, 0x09, 1024, func(c *Cartridge) {
+			c.WritePRG(0xB000, 0x0F) // CHR bank 0 = 31: low nibble
+			c.WritePRG(0xB002, 0x01) // high bits at chip $B001 (VRC4a: A1)
+		}},
+	} {
+		rom := buildINES(tc.mapper, 2, 0)
+		rom[7] |= 0x08
+		rom[11] = tc.byte11
+		cart, err := LoadFromReader(bytes.NewReader(rom))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		base := len(cart.CHRRAM) - tc.bank
+		cart.CHRRAM[base] = 0x5A
+		tc.selectBank(cart)
+		if got := cart.ReadCHR(0x0000); got != 0x5A {
+			t.Errorf("%s: $0000 = %#02x, want the RAM's last bank (0x5A)", tc.name, got)
+		}
+		cart.WriteCHR(0x0001, 0xA5)
+		if cart.CHRRAM[base+1] != 0xA5 {
+			t.Errorf("%s: write to $0001 didn't land in the selected bank", tc.name)
+		}
+	}
+}
+
+func TestSubmapperSelection(t *testing.T) {
+	// NES 2.0 header: flags7 bits 2-3 = %10, submapper in byte 8 bits 4-7.
+	nes2 := buildINES(4, 2, 1)
+	nes2[7] |= 0x08
+	nes2[8] = 0x40
+	cart, err := LoadFromReader(bytes.NewReader(nes2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cart.Header.IsNES20() || cart.Submapper() != 4 {
+		t.Errorf("NES 2.0 header: IsNES20=%v submapper=%d, want true 4", cart.Header.IsNES20(), cart.Submapper())
+	}
+
+	// iNES 1.0 ignores byte 8's high nibble.
+	ines := buildINES(4, 2, 1)
+	ines[8] = 0x40
+	if cart, _ := LoadFromReader(bytes.NewReader(ines)); cart.Submapper() != 0 {
+		t.Errorf("iNES 1.0 submapper = %d, want 0", cart.Submapper())
+	}
+
+	// The database supplies it for an iNES 1.0 dump of the right mapper
+	// (ROMs here are all zero: CRC over 32KB PRG + 8KB CHR), but a NES 2.0
+	// header wins and an entry for another mapper is ignored.
+	crc := ROMCRC32(make([]byte, 32768), make([]byte, 8192))
+	RegisterGame(crc, GameInfo{Mapper: 4, Submapper: 1})
+	defer func() {
+		gameDBMu.Lock()
+		delete(gameDB, crc)
+		gameDBMu.Unlock()
+	}()
+	if cart, _ := LoadFromReader(bytes.NewReader(ines)); cart.Submapper() != 1 {
+		t.Errorf("database submapper = %d, want 1", cart.Submapper())
+	}
+	if cart, _ := LoadFromReader(bytes.NewReader(nes2)); cart.Submapper() != 4 {
+		t.Errorf("NES 2.0 header overridden by database: %d", cart.Submapper())
+	}
+	if cart, _ := LoadFromReader(bytes.NewReader(buildINES(1, 2, 1))); cart.Submapper() != 0 {
+		t.Errorf("entry for mapper 4 applied to mapper 1: %d", cart.Submapper())
+	}
+}
+
+// An iNES 1.0 dump of a DxROM game labelled MMC3 runs as mapper 206 when
+// the database says so; a NES 2.0 header is taken at its word.
+func TestDatabaseRoutesDxROM(t *testing.T) {
+	crc := ROMCRC32(make([]byte, 32768), make([]byte, 8192))
+	RegisterGame(crc, GameInfo{Mapper: 206})
+	defer func() {
+		gameDBMu.Lock()
+		delete(gameDB, crc)
+		gameDBMu.Unlock()
+	}()
+
+	ines := buildINES(4, 2, 1)
+	cart, err := LoadFromReader(bytes.NewReader(ines))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cart.Mapper.(*mapper.Mapper206); !ok || cart.MapperNumber() != 206 || cart.HasIRQ() {
+		t.Errorf("mapper-4 DxROM dump runs as %d (%T)", cart.MapperNumber(), cart.Mapper)
+	}
+	if p := ImageProblems(ines, cart); len(p) != 1 || !strings.Contains(p[0], "runs as the database's") {
+		t.Errorf("problems %q", p)
+	}
+
+	nes2 := buildINES(4, 2, 1)
+	nes2[7] |= 0x08
+	if cart, _ := LoadFromReader(bytes.NewReader(nes2)); cart.MapperNumber() != 4 {
+		t.Errorf("NES 2.0 header rerouted to %d", cart.MapperNumber())
+	}
+	// Only the known mislabelling is rerouted.
+	if cart, _ := LoadFromReader(bytes.NewReader(buildINES(1, 2, 1))); cart.MapperNumber() != 1 {
+		t.Errorf("mapper 1 header rerouted to %d", cart.MapperNumber())
+	}
+}
+
+func TestDxROMWithoutDatabase(t *testing.T) {
+	// Bank switching the DxROM way, junk in the select's top bits.
+	code := []byte{
+		0xA9, 0xC6, 0x8D, 0x00, 0x80, // LDA #$C6; STA $8000
+		0xA9, 0x03, 0x8D, 0x01, 0x80, // LDA #$03; STA $8001
+	}
+	dump := func(prg16k int, battery bool, extra ...byte) []byte {
+		image := buildINES(4, prg16k, 2)
+		if battery {
+			image[6] |= 0x02
+		}
+		copy(image[16:], append(append([]byte(nil), code...), extra...))
+		return image
+	}
+
+	image := dump(8, false)
+	if _, ok := LookupGame(ROMCRC32(image[16:16+8*16384], image[16+8*16384:])); ok {
+		t.Fatal("test dump is in the database")
+	}
+	cart, err := LoadFromReader(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cart.Mapper.(*mapper.Mapper206); !ok || cart.MapperNumber() != 206 {
+		t.Errorf("DxROM dump runs as %d (%T)", cart.MapperNumber(), cart.Mapper)
+	}
+	if p := ImageProblems(image, cart); len(p) != 1 || !strings.Contains(p[0], "runs as 206") {
+		t.Errorf("problems %q", p)
+	}
+
+	for name, image := range map[string][]byte{
+		"mirroring write": dump(8, false, 0x8D, 0x00, 0xA0), // STA $A000
+		"IRQ write":       dump(8, false, 0x8E, 0x01, 0xE0), // STX $E001
+		"256KB PRG":       dump(16, false),
+		"battery":         dump(8, true),
+		"no bank writes":  buildINES(4, 8, 2),
+	} {
+		if cart, _ := LoadFromReader(bytes.NewReader(image)); cart.MapperNumber() != 4 {
+			t.Errorf("%s: runs as %d, want MMC3", name, cart.MapperNumber())
+		}
 	}
 }
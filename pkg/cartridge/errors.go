@@ -0,0 +1,35 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
+)
+
+// Errors LoadFromReader and LoadEntry return for an image they can't use,
+// wrapped with the details (test with errors.Is). A truncated image says
+// how much the header declared and how much the file holds.
+var (
+	ErrBadMagic        = errors.New("not an NES ROM: no iNES header")
+	ErrTruncatedHeader = errors.New("file is too short for an iNES header")
+	ErrNoPRG           = errors.New("iNES header declares no PRG ROM")
+	ErrTruncatedPRG    = errors.New("file ends inside the PRG ROM")
+	ErrTruncatedCHR    = errors.New("file ends inside the CHR ROM")
+)
+
+// ErrUnsupportedMapper is returned for an image whose mapper isn't in the
+// registry (mapper.Register); N is the mapper number from its header.
+type ErrUnsupportedMapper struct {
+	N int
+}
+
+func (e *ErrUnsupportedMapper) Error() string {
+	return fmt.Sprintf("mapper %d is not supported (supported: %s)", e.N, mapper.SupportedList())
+}
+
+// truncated wraps err, one of the ErrTruncated errors, with how far into
+// the file the section runs and how long the file is.
+func truncated(err error, what string, size, end, have int) error {
+	return fmt.Errorf("%w: header declares %s of %d bytes ending at offset %d, file has %d bytes", err, what, size, end, have)
+}
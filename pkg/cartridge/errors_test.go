@@ -0,0 +1,69 @@
+package cartridge
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadErrors(t *testing.T) {
+	good := buildINES(0, 2, 1)
+	trainer := buildINESFlags(0, 1, 1, 0x04, true)
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want error
+		text string // in the message
+	}{
+		{"empty file", nil, ErrTruncatedHeader, "0 bytes"},
+		{"magic cut short", []byte("NE"), ErrTruncatedHeader, "2 bytes"},
+		{"header cut short", good[:10], ErrTruncatedHeader, "10 bytes"},
+		{"not a ROM", []byte("<html>404</html>"), ErrBadMagic, "3C 68 74 6D"},
+		{"no PRG ROM", buildINES(0, 0, 1), ErrNoPRG, ""},
+		{"PRG cut short", good[:16+20000], ErrTruncatedPRG, "32768 bytes ending at offset 32784, file has 20016 bytes"},
+		{"CHR cut short", good[:len(good)-1], ErrTruncatedCHR, "8192 bytes"},
+		{"trainer counted", trainer[:16+16384], ErrTruncatedPRG, "ending at offset 16912"},
+	} {
+		_, err := LoadFromReader(bytes.NewReader(tc.data))
+		if !errors.Is(err, tc.want) || !strings.Contains(err.Error(), tc.text) {
+			t.Errorf("%s: error %v, want %v mentioning %q", tc.name, err, tc.want, tc.text)
+		}
+	}
+
+	_, err := LoadFromReader(bytes.NewReader(buildINES(0xEE, 2, 1)))
+	var unsupported *ErrUnsupportedMapper
+	if !errors.As(err, &unsupported) || unsupported.N != 0xEE {
+		t.Errorf("mapper 238: error %v, want ErrUnsupportedMapper{238}", err)
+	}
+	if !strings.Contains(err.Error(), "mapper 238") {
+		t.Errorf("message %q should name the mapper", err)
+	}
+}
+
+// TestLoadNeverPanics feeds the loader every prefix of a ROM and every
+// mapper number with the smallest ROM a header can declare: each must
+// load or fail with an error, and a loaded cartridge must survive reads
+// across the whole address space.
+func TestLoadNeverPanics(t *testing.T) {
+	good := buildINES(4, 2, 1)
+	for n := 0; n < len(good); n += 997 {
+		if _, err := LoadFromReader(bytes.NewReader(good[:n])); err == nil {
+			t.Errorf("%d of %d bytes loaded", n, len(good))
+		}
+	}
+	for m := 0; m < 256; m++ {
+		for _, chr := range []int{0, 1} {
+			cart, err := LoadFromReader(bytes.NewReader(buildINES(uint8(m), 1, chr)))
+			if err != nil {
+				continue
+			}
+			for addr := 0x6000; addr <= 0xFFFF; addr += 0x100 {
+				cart.ReadPRG(uint16(addr))
+			}
+			for addr := 0; addr < 0x2000; addr += 0x40 {
+				cart.ReadCHR(uint16(addr))
+			}
+		}
+	}
+}
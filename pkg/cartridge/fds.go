@@ -0,0 +1,146 @@
+package cartridge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
+)
+
+// Famicom Disk System images. A .fds file is the disk's sides back to
+// back, mapper.FDSSideSize bytes each, optionally behind a 16-byte
+// "FDS\x1A" header giving the side count. Each side opens with its disk
+// info block, which is how a headerless image is recognised.
+var (
+	fdsMagic     = []byte("FDS\x1A")
+	fdsDiskMagic = []byte("\x01*NINTENDO-HVC*")
+
+	// ErrNoFDSBIOS is returned for a disk image when SetFDSBIOS hasn't
+	// been given the Disk System's BIOS: it isn't part of the image.
+	ErrNoFDSBIOS = errors.New("FDS disk image needs the Disk System BIOS (disksys.rom), which isn't loaded")
+)
+
+// FDSBIOSSize is the size of the RAM adapter's BIOS ROM.
+const FDSBIOSSize = 8192
+
+var (
+	fdsBIOSMu sync.RWMutex
+	fdsBIOS   []byte
+)
+
+// SetFDSBIOS sets the BIOS that LoadEntry and LoadFromReader put behind
+// disk images.
+func SetFDSBIOS(bios []byte) error {
+	if len(bios) != FDSBIOSSize {
+		return fmt.Errorf("FDS BIOS is %d bytes, want %d", len(bios), FDSBIOSSize)
+	}
+	fdsBIOSMu.Lock()
+	defer fdsBIOSMu.Unlock()
+	fdsBIOS = bytes.Clone(bios)
+	return nil
+}
+
+// IsFDS reports whether data is a Famicom Disk System image.
+func IsFDS(data []byte) bool {
+	return bytes.HasPrefix(data, fdsMagic) || bytes.HasPrefix(data, fdsDiskMagic)
+}
+
+// loadFDSEntry builds a disk cartridge around the BIOS from SetFDSBIOS.
+func loadFDSEntry(image []byte) (*Cartridge, error) {
+	fdsBIOSMu.RLock()
+	bios := fdsBIOS
+	fdsBIOSMu.RUnlock()
+	if bios == nil {
+		return nil, ErrNoFDSBIOS
+	}
+	return LoadFDS(image, bios)
+}
+
+// LoadFDS builds a cartridge for the disk image (a .fds file, with or
+// without its header) in the RAM adapter (mapper 20) with bios as its
+// ROM. The first side is in the drive.
+func LoadFDS(image, bios []byte) (*Cartridge, error) {
+	if len(bios) != FDSBIOSSize {
+		return nil, fmt.Errorf("FDS BIOS is %d bytes, want %d", len(bios), FDSBIOSSize)
+	}
+	body := image
+	if bytes.HasPrefix(image, fdsMagic) {
+		if len(image) < 16 {
+			return nil, fmt.Errorf("FDS header is truncated")
+		}
+		body = image[16:]
+	}
+	n := len(body) / mapper.FDSSideSize
+	if n == 0 || !bytes.HasPrefix(body, fdsDiskMagic) {
+		return nil, fmt.Errorf("invalid FDS image: no disk side")
+	}
+	cart := &Cartridge{
+		PRGROM: bytes.Clone(bios),
+		PRGRAM: make([]uint8, 32768),
+		CHRRAM: make([]uint8, minCHRRAM),
+		disk:   bytes.Clone(body[:n*mapper.FDSSideSize]),
+
+		mapperNumber: 20,
+	}
+	copy(cart.Header.Magic[:], inesMagic)
+	// Mapper 20, the number emulators give the adapter.
+	cart.Header.Flags6 = 20 & 0x0F << 4
+	cart.Header.Flags7 = 20 & 0xF0
+	sides := make([][]uint8, n)
+	for i := range sides {
+		sides[i] = cart.disk[i*mapper.FDSSideSize : (i+1)*mapper.FDSSideSize]
+	}
+	cart.fds = mapper.NewFDS(&mapper.CartridgeData{
+		PRGROM: cart.PRGROM,
+		PRGRAM: cart.PRGRAM,
+		CHRRAM: cart.CHRRAM,
+	}, sides)
+	cart.Mapper = cart.fds
+	cart.cpuTicker = cart.fds
+	cart.audioSource = cart.fds
+	cart.hasExpansion = true
+	cart.hasIRQ = true
+	cart.resetter = cart.fds
+	return cart, nil
+}
+
+// Disk returns the disk drive of a Disk System cartridge, or nil.
+func (c *Cartridge) Disk() mapper.DiskDrive {
+	if c.fds == nil {
+		return nil
+	}
+	return c.fds
+}
+
+// fdsDisk is the NonVolatile of a disk cartridge: the .sav file holds
+// the disk as the game has written it, the sides back to back without a
+// header, so it also opens as a .fds image.
+type fdsDisk struct{ fds *mapper.FDS }
+
+func (fdsDisk) Kind() string { return "disk" }
+
+func (d fdsDisk) SaveRAM(w io.Writer) error {
+	for _, side := range d.fds.Disk() {
+		if _, err := w.Write(side); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d fdsDisk) LoadRAM(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var sides [][]uint8
+	for len(data) >= mapper.FDSSideSize {
+		sides = append(sides, data[:mapper.FDSSideSize])
+		data = data[mapper.FDSSideSize:]
+	}
+	d.fds.SetDisk(sides)
+	return nil
+}
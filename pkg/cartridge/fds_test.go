@@ -0,0 +1,88 @@
+package cartridge
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
+)
+
+// buildFDS returns a headered .fds image of sides blank sides, each with
+// just its disk info block.
+func buildFDS(sides int) []byte {
+	img := make([]byte, 16+sides*mapper.FDSSideSize)
+	copy(img, "FDS\x1A")
+	img[4] = byte(sides)
+	for i := 0; i < sides; i++ {
+		side := img[16+i*mapper.FDSSideSize:]
+		copy(side, fdsDiskMagic)
+		side[0x16] = byte(i) // side number
+	}
+	return img
+}
+
+func TestLoadFDS(t *testing.T) {
+	fdsBIOSMu.Lock()
+	saved := fdsBIOS
+	fdsBIOS = nil
+	fdsBIOSMu.Unlock()
+	t.Cleanup(func() {
+		fdsBIOSMu.Lock()
+		fdsBIOS = saved
+		fdsBIOSMu.Unlock()
+	})
+
+	img := buildFDS(2)
+	data := buildZip(t, map[string][]byte{"Game (J).fds": img}, []string{"Game (J).fds"})
+	if _, err := LoadFromReader(bytes.NewReader(data)); !errors.Is(err, ErrNoFDSBIOS) {
+		t.Fatalf("disk image without a BIOS: err = %v, want ErrNoFDSBIOS", err)
+	}
+	if err := SetFDSBIOS(make([]byte, 4096)); err == nil {
+		t.Error("SetFDSBIOS should refuse a BIOS of the wrong size")
+	}
+	bios := make([]byte, FDSBIOSSize)
+	bios[FDSBIOSSize-3] = 0xE0 // reset vector high byte
+	if err := SetFDSBIOS(bios); err != nil {
+		t.Fatal(err)
+	}
+	cart, err := LoadFromReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("LoadFromReader(zip with .fds): %v", err)
+	}
+	if cart.Header.MapperNumber() != 20 || cart.ReadPRG(0xFFFD) != 0xE0 || !cart.HasExpansion() {
+		t.Error("a disk image should load as mapper 20 with the BIOS at $E000")
+	}
+	disk := cart.Disk()
+	if disk == nil || disk.DiskSides() != 2 || disk.DiskSide() != 0 {
+		t.Fatalf("Disk() = %v, want two sides with side A in", disk)
+	}
+
+	// Headerless images hash the same.
+	bare, err := LoadEntry(ROMEntry{Data: img[16:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bare.Hashes() != cart.Hashes() || cart.Hashes().PRGCRC32 == ROMCRC32(bios, nil) {
+		t.Error("a disk should be identified by its sides, header or not, not by the BIOS")
+	}
+
+	// The disk is the battery: what the game writes comes back from .sav.
+	fds := cart.Mapper.(*mapper.FDS)
+	sides := fds.Disk()
+	sides[1][0x38], sides[1][0x39] = 2, 7 // a file-count block
+	fds.SetDisk(sides)
+	var sav bytes.Buffer
+	if err := cart.Battery().SaveRAM(&sav); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sav.Bytes()[mapper.FDSSideSize:][0x38:0x3A], []byte{2, 7}) {
+		t.Error("the .sav should hold the disk as written")
+	}
+	if err := bare.Battery().LoadRAM(&sav); err != nil {
+		t.Fatal(err)
+	}
+	if got := bare.Mapper.(*mapper.FDS).Disk()[1][0x39]; got != 7 {
+		t.Errorf("disk after LoadRAM has file count %d, want 7", got)
+	}
+}
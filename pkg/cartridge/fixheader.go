@@ -0,0 +1,180 @@
+package cartridge
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"strings"
+)
+
+// RepairHeader checks the header of image, an uncompressed iNES file,
+// against the image itself and the game database, and returns a copy
+// with the header corrected and a description of each correction. It
+// fixes:
+//
+//   - a dirty iNES 1.0 header: bytes 7-15 are cleared;
+//   - PRG/CHR ROM counts that don't match the file: taken from the
+//     database when it knows the dump, else from the data that is there
+//     (data past the described ROM that isn't a whole CHR bank is
+//     dropped);
+//   - mapper, mirroring and battery flags the database disagrees with;
+//   - an iNES 1.0 header for a board only NES 2.0 can describe (a
+//     submapper, CHR RAM other than 8KB): it becomes a NES 2.0 header.
+//
+// An image with nothing to fix comes back unchanged with no fixes. The
+// trainer, if any, is kept.
+func RepairHeader(image []byte) ([]byte, []string, error) {
+	if !bytes.HasPrefix(image, inesMagic) {
+		return nil, nil, ErrBadMagic
+	}
+	if len(image) < 16 {
+		return nil, nil, ErrTruncatedHeader
+	}
+	var c Cartridge
+	c.readHeader(image)
+	h := &c.Header
+	var fixes []string
+
+	if h.dirty() {
+		junk := strings.TrimSpace(strings.Map(func(r rune) rune {
+			if r < 0x20 || r > 0x7E {
+				return -1
+			}
+			return r
+		}, string(image[7:16])))
+		was := h.MapperNumber()
+		h.Flags7, h.Flags8, h.Flags9, h.Flags10, h.Padding = 0, 0, 0, 0, [5]uint8{}
+		fixes = append(fixes, fmt.Sprintf("cleared junk %q in bytes 7-15 (mapper %d becomes %d)", junk, was, h.MapperNumber()))
+	}
+
+	var trainer []byte
+	body := image[16:]
+	if h.Flags6&0x04 != 0 {
+		if len(body) < 512 {
+			return nil, nil, fmt.Errorf("trainer is truncated")
+		}
+		trainer, body = body[:512], body[512:]
+	}
+
+	// The database is keyed by the CRC of PRG+CHR, which doesn't depend
+	// on where the split is: try the ROM as described, then all of it.
+	described := int(h.PRGROMSize)*16384 + int(h.CHRROMSize)*8192
+	var rom []byte
+	info, known := GameInfo{}, false
+	for _, n := range []int{described, len(body)} {
+		if n <= len(body) {
+			if info, known = LookupGame(crc32.ChecksumIEEE(body[:n])); known {
+				rom = body[:n]
+				break
+			}
+		}
+	}
+
+	prg, chr := int(h.PRGROMSize), int(h.CHRROMSize)
+	switch {
+	case known && info.PRGROMSize > 0 && info.PRGROMSize+info.CHRROMSize == len(rom):
+		prg, chr = info.PRGROMSize/16384, info.CHRROMSize/8192
+	case known:
+		// Sizes unknown to the database; the dump's length is right.
+		if chr8k := (len(rom) - prg*16384) / 8192; prg*16384 <= len(rom) && (len(rom)-prg*16384)%8192 == 0 {
+			chr = chr8k
+		}
+	case len(body) > described:
+		rom = body[:described]
+		if extra := len(body) - described; extra%8192 == 0 {
+			rom, chr = body, chr+extra/8192
+		}
+	case len(body) < described:
+		rom = body
+		switch {
+		case prg*16384 <= len(body) && (len(body)-prg*16384)%8192 == 0:
+			chr = (len(body) - prg*16384) / 8192
+		case chr*8192 <= len(body) && (len(body)-chr*8192)%16384 == 0:
+			prg = (len(body) - chr*8192) / 16384
+		default:
+			return nil, nil, fmt.Errorf("file has %d bytes of ROM, header describes %d, and no PRG/CHR split fits", len(body), described)
+		}
+	default:
+		rom = body
+	}
+	if extra := len(body) - len(rom); extra > 0 {
+		fixes = append(fixes, fmt.Sprintf("dropped %d bytes after the ROM", extra))
+	}
+	if prg == 0 || prg > 255 || chr > 255 {
+		return nil, nil, fmt.Errorf("can't describe %d×16KB PRG and %d×8KB CHR in an iNES header", prg, chr)
+	}
+	if prg != int(h.PRGROMSize) || chr != int(h.CHRROMSize) {
+		fixes = append(fixes, fmt.Sprintf("ROM size %d×16KB PRG + %d×8KB CHR, was %d + %d", prg, chr, h.PRGROMSize, h.CHRROMSize))
+		h.PRGROMSize, h.CHRROMSize = uint8(prg), uint8(chr)
+	}
+
+	if known {
+		fixes = repairFromDB(h, info, fixes)
+	}
+	if len(fixes) == 0 {
+		return image, nil, nil
+	}
+
+	out := make([]byte, 0, 16+len(trainer)+len(rom))
+	out = append(out, h.Magic[:]...)
+	out = append(out, h.PRGROMSize, h.CHRROMSize, h.Flags6, h.Flags7, h.Flags8, h.Flags9, h.Flags10)
+	out = append(out, h.Padding[:]...)
+	out = append(out, trainer...)
+	out = append(out, rom...)
+	return out, fixes, nil
+}
+
+// repairFromDB brings the header's board description in line with the
+// database entry, appending what changed to fixes.
+func repairFromDB(h *iNESHeader, info GameInfo, fixes []string) []string {
+	if m := h.MapperNumber(); m != info.Mapper {
+		h.Flags6 = h.Flags6&0x0F | info.Mapper<<4
+		h.Flags7 = h.Flags7&0x0F | info.Mapper&0xF0
+		fixes = append(fixes, fmt.Sprintf("mapper %d, was %d", info.Mapper, m))
+	}
+
+	flags6 := h.Flags6
+	switch info.Mirroring {
+	case "H":
+		flags6 &^= 0x09
+	case "V":
+		flags6 = flags6&^0x08 | 0x01
+	case "4":
+		flags6 |= 0x08
+	}
+	if info.Battery {
+		flags6 |= 0x02
+	} else {
+		flags6 &^= 0x02
+	}
+	if flags6 != h.Flags6 {
+		fixes = append(fixes, fmt.Sprintf("flags 6 $%02X (mirroring %s, battery %t), was $%02X", flags6, info.Mirroring, info.Battery, h.Flags6))
+		h.Flags6 = flags6
+	}
+
+	chrRAM := info.CHRRAMSize != 0 && info.CHRRAMSize != minCHRRAM && h.CHRROMSize == 0
+	if !h.IsNES20() && (info.Submapper != 0 || chrRAM) {
+		h.Flags7 = h.Flags7&0xF3 | 0x08
+		h.Flags8 = info.Submapper << 4
+		h.Flags9 = 0
+		h.Flags10 = ramShift(info.PRGRAMSize) | ramShift(info.PRGNVRAMSize)<<4
+		h.Padding = [5]uint8{ramShift(info.CHRRAMSize)}
+		for i, r := range nes20dbRegions {
+			if r == info.Region {
+				h.Padding[1] = uint8(i)
+			}
+		}
+		fixes = append(fixes, fmt.Sprintf("NES 2.0 header for submapper %d", info.Submapper))
+	}
+	return fixes
+}
+
+// ramShift is the NES 2.0 shift count for a RAM of size bytes (64 <<
+// shift), 0 for none.
+func ramShift(size int) uint8 {
+	if size < 128 {
+		return 0
+	}
+	return uint8(bits.Len(uint(size)) - 7)
+}
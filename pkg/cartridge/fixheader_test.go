@@ -0,0 +1,83 @@
+package cartridge
+
+import (
+	"bytes"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+func TestRepairHeader(t *testing.T) {
+	clean := buildINES(4, 2, 1)
+	if out, fixes, err := RepairHeader(clean); err != nil || fixes != nil || !bytes.Equal(out, clean) {
+		t.Errorf("clean image: fixes %q, err %v", fixes, err)
+	}
+	if _, _, err := RepairHeader([]byte("not a ROM at all")); err == nil {
+		t.Error("RepairHeader should refuse a file that isn't iNES")
+	}
+
+	dirty := buildINES(4, 2, 1)
+	copy(dirty[7:], "DiskDude!")
+	out, fixes, err := RepairHeader(dirty)
+	if err != nil || len(fixes) != 1 || !strings.Contains(fixes[0], "DiskDude!") {
+		t.Fatalf("dirty header: fixes %q, err %v", fixes, err)
+	}
+	if !bytes.Equal(out, clean) {
+		t.Errorf("dirty header repaired to % X, want % X", out[:16], clean[:16])
+	}
+
+	// Trailing junk that isn't a CHR bank is dropped; a missing bank
+	// shrinks the count that claimed it.
+	long := append(buildINES(4, 2, 1), make([]byte, 100)...)
+	if out, fixes, err := RepairHeader(long); err != nil || len(fixes) != 1 || !bytes.Equal(out, clean) {
+		t.Errorf("trailing bytes: fixes %q, err %v", fixes, err)
+	}
+	short := buildINES(4, 2, 2)[:len(clean)]
+	if out, fixes, err := RepairHeader(short); err != nil || len(fixes) != 1 || !bytes.Equal(out, clean) {
+		t.Errorf("short CHR: fixes %q, err %v", fixes, err)
+	}
+	if _, _, err := RepairHeader(buildINES(4, 2, 1)[:16+100]); err == nil {
+		t.Error("RepairHeader should give up when no PRG/CHR split fits the file")
+	}
+}
+
+func TestRepairHeaderFromDB(t *testing.T) {
+	// A 32KB PRG, CHR RAM board the database knows as mapper 2
+	// submapper 2 with vertical mirroring, battery and 32KB of CHR RAM;
+	// the dump's header has PRG and CHR swapped around and mapper 0.
+	prg := bytes.Repeat([]byte{0xA5}, 32768)
+	crc := crc32.ChecksumIEEE(prg)
+	RegisterGame(crc, GameInfo{
+		Mapper: 2, Submapper: 2, CHRRAMSize: 32768, Region: "PAL",
+		PRGROMSize: 32768, PRGNVRAMSize: 8192, Mirroring: "V", Battery: true,
+	})
+	defer func() {
+		gameDBMu.Lock()
+		delete(gameDB, crc)
+		gameDBMu.Unlock()
+	}()
+
+	img := append([]byte("NES\x1A\x01\x02\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"), prg...)
+	out, fixes, err := RepairHeader(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 4 {
+		t.Errorf("fixes = %q, want size, mapper, flags 6 and NES 2.0", fixes)
+	}
+	want := []byte("NES\x1A\x02\x00\x23\x08\x20\x00\x70\x09\x01\x00\x00\x00")
+	if !bytes.Equal(out[:16], want) {
+		t.Errorf("header = % X, want % X", out[:16], want)
+	}
+
+	cart, err := LoadFromReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cart.Submapper() != 2 || cart.Header.CHRRAMSize() != 32768 {
+		t.Errorf("repaired cartridge has submapper %d, %d bytes CHR RAM", cart.Submapper(), cart.Header.CHRRAMSize())
+	}
+	if _, fixes, _ := RepairHeader(out); fixes != nil {
+		t.Errorf("repairing a repaired image: %q", fixes)
+	}
+}
@@ -0,0 +1,193 @@
+package cartridge
+
+import (
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// GameInfo is what the game database knows about a dump beyond its iNES
+// 1.0 header — the board's NES 2.0 submapper and CHR RAM size, which an
+// old header can't express (e.g. an MMC3A board needing the alternate IRQ
+// behaviour, mapper 4 submapper 4, or 32KB of CHR RAM) — plus what
+// rom_analyzer shows about it and RepairHeader checks a header against.
+type GameInfo struct {
+	Mapper    uint8
+	Submapper uint8
+	// CHRRAMSize is the CHR RAM in bytes for boards without CHR ROM;
+	// 0 means the 8KB default.
+	CHRRAMSize int
+
+	// PRGROMSize and CHRROMSize are the ROM sizes in bytes, and
+	// PRGRAMSize and PRGNVRAMSize the work and battery-backed RAM; 0 when
+	// the database doesn't say (or, for RAM, there is none).
+	PRGROMSize, CHRROMSize   int
+	PRGRAMSize, PRGNVRAMSize int
+	Mirroring                string // "H", "V" or "4"; "" when unknown
+	Battery                  bool
+
+	Name   string
+	Region string // "NTSC", "PAL", "Dendy" or "multi"
+	Board  string // PCB name (NES-SNROM, …) when the database has one
+}
+
+// gameDB maps ROMCRC32 checksums to GameInfo. It starts out as the
+// embedded database (embeddedDB), loaded the first time it is used;
+// RegisterGame and the database files a frontend loads (LoadGameDB) add
+// to it and override it.
+var (
+	gameDBMu sync.RWMutex
+	gameDB   = map[uint32]GameInfo{}
+)
+
+// embeddedDB is the game database built into the binary: every *.xml in
+// gamedb/, in either format LoadGameDB reads. The repository ships no
+// database of its own; a build copies nes20db.xml (or a NesCartDB dump)
+// into gamedb/ to have it without any file beside the executable.
+//
+//go:embed gamedb
+var embeddedDB embed.FS
+
+var embeddedOnce sync.Once
+
+// loadEmbedded registers the embedded databases' games, in file name
+// order. A file that fails to parse keeps the games read before the
+// error; TestEmbeddedGameDB is what catches a broken one.
+func loadEmbedded() {
+	names, _ := fs.Glob(embeddedDB, "gamedb/*.xml")
+	for _, name := range names {
+		f, err := embeddedDB.Open(name)
+		if err != nil {
+			continue
+		}
+		loadGameDB(f)
+		f.Close()
+	}
+}
+
+// RegisterGame adds (or replaces) the database entry for the dump whose
+// ROMCRC32 is crc. It applies to cartridges loaded afterwards, and only
+// when their header is iNES 1.0 and names the same mapper: a NES 2.0
+// header already carries the submapper and always wins.
+func RegisterGame(crc uint32, info GameInfo) {
+	embeddedOnce.Do(loadEmbedded)
+	gameDBMu.Lock()
+	defer gameDBMu.Unlock()
+	gameDB[crc] = info
+}
+
+// mergeGame registers an nes20db entry like RegisterGame, keeping the
+// board of an entry already there, and its name and region when info
+// lacks them: nes20db names no boards, and loading NesCartDB as well
+// should get both.
+func mergeGame(crc uint32, info GameInfo) {
+	gameDBMu.Lock()
+	defer gameDBMu.Unlock()
+	if old, ok := gameDB[crc]; ok {
+		info.Board = old.Board
+		if info.Name == "" {
+			info.Name = old.Name
+		}
+		if info.Region == "" {
+			info.Region = old.Region
+		}
+	}
+	gameDB[crc] = info
+}
+
+// mergeBoard registers a NesCartDB entry. For a dump already known it
+// only adds the board, and the name and region if missing: the entry
+// there may have the submapper and CHR RAM size NesCartDB doesn't record.
+func mergeBoard(crc uint32, info GameInfo) {
+	gameDBMu.Lock()
+	defer gameDBMu.Unlock()
+	if old, ok := gameDB[crc]; ok {
+		old.Board = info.Board
+		if old.Name == "" {
+			old.Name = info.Name
+		}
+		if old.Region == "" {
+			old.Region = info.Region
+		}
+		info = old
+	}
+	gameDB[crc] = info
+}
+
+// LookupGame returns the database entry for crc, if any.
+func LookupGame(crc uint32) (GameInfo, bool) {
+	embeddedOnce.Do(loadEmbedded)
+	gameDBMu.RLock()
+	defer gameDBMu.RUnlock()
+	info, ok := gameDB[crc]
+	return info, ok
+}
+
+// LoadGameDB registers every game in a database file and returns how
+// many it read. Two formats are understood, told apart by the root
+// element: nes20db (see LoadNES20DB) and NesCartDB, the one with board
+// names (see decodeNesCartDB). Either can follow the other: what one file
+// doesn't know about a dump (mergeGame, mergeBoard) is kept.
+func LoadGameDB(r io.Reader) (int, error) {
+	embeddedOnce.Do(loadEmbedded)
+	return loadGameDB(r)
+}
+
+func loadGameDB(r io.Reader) (int, error) {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return 0, fmt.Errorf("game database: no root element")
+		}
+		if err != nil {
+			return 0, fmt.Errorf("game database: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "nes20db":
+			return decodeNES20DB(dec, mergeGame)
+		case "database":
+			return decodeNesCartDB(dec, mergeBoard)
+		}
+		return 0, fmt.Errorf("game database: unknown format <%s>", start.Name.Local)
+	}
+}
+
+// ROMCRC32 is the CRC-32 of the PRG ROM followed by the CHR ROM, header
+// and trainer excluded — the checksum NES 2.0 databases identify dumps by.
+func ROMCRC32(prg, chr []byte) uint32 {
+	return crc32.Update(crc32.ChecksumIEEE(prg), crc32.IEEETable, chr)
+}
+
+// Game returns the database entry for the cartridge's dump, if there is
+// one — looked up now, so entries registered since loading count.
+func (c *Cartridge) Game() (GameInfo, bool) {
+	return LookupGame(c.Hashes().ROMCRC32)
+}
+
+// Reload builds a new cartridge from c's header and ROM as if the image
+// were opened again, so database entries registered since it was loaded —
+// a per-game submapper, say — take effect. The trainer, which nothing
+// uses, isn't carried over. A disk image, which the database doesn't
+// cover, comes back as it is.
+func (c *Cartridge) Reload() (*Cartridge, error) {
+	if c.fds != nil {
+		return c, nil
+	}
+	h := c.Header
+	img := make([]byte, 0, 16+len(c.PRGROM)+len(c.CHRROM))
+	img = append(img, h.Magic[:]...)
+	img = append(img, h.PRGROMSize, h.CHRROMSize, h.Flags6&^0x04, h.Flags7, h.Flags8, h.Flags9, h.Flags10)
+	img = append(img, h.Padding[:]...)
+	img = append(img, c.PRGROM...)
+	img = append(img, c.CHRROM...)
+	return loadINES(img)
+}
@@ -0,0 +1,65 @@
+package cartridge
+
+import "io"
+
+// CartridgeInterface is everything the console asks of a cartridge: the
+// CPU and PPU buses, the mapper's clocks, its IRQ line and a power-cycle
+// reset. *Cartridge implements it over any mapper, turning the mapper's
+// optional interfaces into no-ops where they're missing. The PPU and
+// memory bus each declare the subset they use (ppu.CartridgeBus,
+// memory.CartridgeBus).
+type CartridgeInterface interface {
+	// CPU side
+	ReadPRG(addr uint16) uint8
+	WritePRG(addr uint16, value uint8)
+	HasExpansion() bool // decodes $4020-$5FFF (MMC5)
+	TickCPU(cycles int)
+
+	// PPU side
+	ReadCHR(addr uint16) uint8
+	ReadCHRSprite(addr uint16) uint8
+	WriteCHR(addr uint16, value uint8)
+	GetMirroring() MirroringMode
+	PPUAddressBus(addr uint16)
+	WatchesPatternFetches() bool
+	SetSpriteSize(is8x16 bool)
+	NotifyScanline(scanline int, renderingEnabled bool)
+	Step()
+
+	// IRQLine reports whether the cartridge holds the CPU /IRQ line low;
+	// ClearIRQ releases it.
+	IRQLine() bool
+	ClearIRQ()
+	Reset()
+
+	// Optional capabilities; nil when the cartridge lacks them.
+	Battery() BatteryBacked
+	NonVolatile() NonVolatile
+	ExpansionAudio() ExpansionAudio
+}
+
+// BatteryBacked is the capability of a cartridge whose PRG RAM survives
+// power-off, read and written as .sav files.
+type BatteryBacked interface {
+	SaveRAM(w io.Writer) error
+	LoadRAM(r io.Reader) error
+}
+
+// NonVolatile is the capability of a cartridge with memory that keeps its
+// contents with the power off — battery-backed PRG RAM, a disk, flash the
+// game rewrites, a serial EEPROM — which the save manager keeps in the
+// ROM's .sav file. Kind names the memory for messages ("PRG RAM",
+// "disk", "flash", "EEPROM").
+type NonVolatile interface {
+	BatteryBacked
+	Kind() string
+}
+
+// ExpansionAudio is the capability of a cartridge with its own sound chip
+// (FME-7). AudioSample is on the APU's 0..1 scale and mixed into the 2A03
+// output.
+type ExpansionAudio interface {
+	AudioSample() float32
+}
+
+var _ CartridgeInterface = (*Cartridge)(nil)
@@ -0,0 +1,313 @@
+package mapper
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// BandaiFCG implements Bandai's FCG-1/FCG-2 and LZ93D50 boards (iNES
+// mappers 16, 153, 157 and 159) — the Dragon Ball Z and SD Gundam RPGs,
+// Famicom Jump II and the Datach games. The ASICs share one register set;
+// the boards differ in where it is decoded and in what sits at
+// $6000-$7FFF: nothing, a serial EEPROM the game bit-bangs (eeprom), or
+// battery-backed PRG RAM.
+//
+// Registers, by the low four address bits ($6000-$7FFF on FCG-1/2,
+// $8000-$FFFF on LZ93D50; both on iNES 1.0 mapper 16 images, which don't
+// say which chip they have):
+//
+//	$x0-$x7  CHR banks 0-7 (1 KiB each); on mapper 153 bit 0 of $x0-$x3 is
+//	         the 256 KiB outer PRG bank instead
+//	$x8      PRG bank (16 KiB at $8000; $C000 holds the last bank)
+//	$x9      mirroring (0=vert 1=horiz 2/3=one-screen lower/upper)
+//	$xA      IRQ control: bit 0 enables counting; writing acknowledges, and
+//	         on the LZ93D50 copies the latch into the counter
+//	$xB/$xC  IRQ counter low/high (FCG-1/2) or latch low/high (LZ93D50)
+//	$xD      EEPROM: bit 5 SCL, bit 6 SDA, bit 7 releases SDA for reads;
+//	         on mapper 153 bit 5 enables the PRG RAM
+//
+// Reads of $6000-$7FFF on EEPROM boards return the chip's SDA in bit 4.
+// The counter drops once per CPU cycle while enabled and raises an IRQ as
+// it reaches zero.
+//
+// Mapper 157 (Datach) runs with the 24C02 of the game cartridge; the
+// base unit's barcode reader and its X24C01 are not emulated.
+type BandaiFCG struct {
+	cartridge *CartridgeData
+
+	fcg    bool    // FCG-1/2 registers at $6000-$7FFF
+	lz     bool    // LZ93D50 registers at $8000-$FFFF
+	sram   bool    // mapper 153: PRG RAM and outer PRG bank
+	eeprom *eeprom // nil on boards without one
+
+	chrBanks [8]uint8
+	prgBank  uint8
+	outer    uint8
+	control  uint8 // $xD
+	prg      prgBankTable
+
+	mirroring uint8
+
+	irqEnabled bool
+	irqPending bool
+	irqCounter uint16
+	irqLatch   uint16
+}
+
+func init() {
+	newFCG := func(n uint8, d *CartridgeData) Mapper { return NewBandaiFCG(n, d) }
+	Register(Board{Name: "Bandai FCG-1/2, LZ93D50 + 24C02", Submappers: []uint8{4, 5}, Battery: true, IRQ: true, New: newFCG}, 16)
+	Register(Board{Name: "Bandai LZ93D50 + SRAM", Battery: true, IRQ: true, New: newFCG}, 153)
+	Register(Board{Name: "Bandai Datach", Battery: true, IRQ: true, New: newFCG}, 157)
+	Register(Board{Name: "Bandai LZ93D50 + 24C01", Battery: true, IRQ: true, New: newFCG}, 159)
+}
+
+// NewBandaiFCG creates the board for mapper 16, 153, 157 or 159. Mapper 16
+// submapper 4 is the FCG-1/2 without EEPROM, submapper 5 the LZ93D50 with
+// a 24C02; submapper 0 decodes both register ranges and has the 24C02.
+func NewBandaiFCG(mapperNumber uint8, data *CartridgeData) *BandaiFCG {
+	m := &BandaiFCG{cartridge: data, lz: true}
+	switch mapperNumber {
+	case 16:
+		switch data.Submapper {
+		case 4:
+			m.fcg, m.lz = true, false
+		case 5:
+			m.eeprom = new24C02()
+		default:
+			m.fcg = true
+			m.eeprom = new24C02()
+		}
+	case 153:
+		m.sram = true
+	case 157:
+		m.eeprom = new24C02()
+	case 159:
+		m.eeprom = newX24C01()
+	}
+	m.updatePRGBanks()
+	return m
+}
+
+// PRGBanks implements PRGBankMapper.
+func (m *BandaiFCG) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+// updatePRGBanks maps the selected bank at $8000 and the last at $C000,
+// both within the outer bank.
+func (m *BandaiFCG) updatePRGBanks() {
+	rom := m.cartridge.PRGROM
+	base := int(m.outer) * 16
+	m.prg.set16K(0, rom, base+int(m.prgBank&0x0F))
+	m.prg.set16K(2, rom, base+15)
+}
+
+// ReadPRG reads PRG ROM, PRG RAM on mapper 153, or the EEPROM's data line.
+func (m *BandaiFCG) ReadPRG(addr uint16) uint8 {
+	switch {
+	case addr >= 0x8000:
+		return m.prg.read(addr)
+	case addr < 0x6000:
+		return 0
+	case m.sram:
+		return readPRGRAM(m.cartridge, addr)
+	case m.eeprom != nil && m.eeprom.sda() && m.control&0xC0 != 0:
+		return 0x10
+	}
+	return 0
+}
+
+// PRGRAMEnabled implements PRGRAMGate: $6000-$7FFF is open bus except for
+// the EEPROM's bit and mapper 153's RAM while enabled.
+func (m *BandaiFCG) PRGRAMEnabled() bool {
+	if m.sram {
+		return m.control&0x20 != 0
+	}
+	return m.eeprom != nil
+}
+
+// WritePRG decodes register writes in the ranges the board wires, and
+// PRG RAM writes on mapper 153.
+func (m *BandaiFCG) WritePRG(addr uint16, value uint8) {
+	switch {
+	case addr >= 0x8000 && m.lz, addr >= 0x6000 && addr < 0x8000 && m.fcg:
+		m.writeRegister(addr&0x0F, value)
+	case m.sram && m.control&0x20 != 0:
+		writePRGRAM(m.cartridge, addr, value)
+	}
+}
+
+func (m *BandaiFCG) writeRegister(reg uint16, value uint8) {
+	switch reg {
+	case 0, 1, 2, 3, 4, 5, 6, 7:
+		m.chrBanks[reg] = value
+		if m.sram && reg < 4 {
+			m.outer = value & 1
+			m.updatePRGBanks()
+		}
+	case 8:
+		m.prgBank = value
+		m.updatePRGBanks()
+	case 9:
+		m.mirroring = value & 3
+	case 0xA:
+		m.irqEnabled = value&1 != 0
+		m.irqPending = false
+		if m.lz {
+			m.irqCounter = m.irqLatch
+		}
+	case 0xB, 0xC:
+		shift := (reg - 0xB) * 8
+		m.irqLatch = m.irqLatch&^(0xFF<<shift) | uint16(value)<<shift
+		if m.fcg {
+			m.irqCounter = m.irqCounter&^(0xFF<<shift) | uint16(value)<<shift
+		}
+	case 0xD:
+		m.control = value
+		if m.eeprom != nil {
+			m.eeprom.setLines(value&0x20 != 0, value&0xC0 != 0)
+		}
+	}
+}
+
+// chrOffset maps addr through its 1 KiB bank onto CHR ROM. CHR RAM boards
+// (153, 157) have 8 KiB and ignore the bank registers.
+func (m *BandaiFCG) chrOffset(addr uint16) int {
+	if len(m.cartridge.CHRROM) == 0 {
+		return int(addr & 0x1FFF)
+	}
+	n := len(m.cartridge.CHRROM) / 1024
+	if n == 0 {
+		return -1
+	}
+	return int(m.chrBanks[(addr>>10)&7])%n*1024 + int(addr&0x3FF)
+}
+
+// ReadCHR reads through the CHR banks.
+func (m *BandaiFCG) ReadCHR(addr uint16) uint8 {
+	chr := chrMemory(m.cartridge)
+	if off := m.chrOffset(addr); off >= 0 && off < len(chr) {
+		return chr[off]
+	}
+	return 0
+}
+
+// WriteCHR writes CHR RAM when the board has it.
+func (m *BandaiFCG) WriteCHR(addr uint16, value uint8) {
+	writeBankedCHRRAM(m.cartridge, m.chrOffset(addr), value)
+}
+
+// TickCPU runs the IRQ counter and the EEPROM's write cycle.
+func (m *BandaiFCG) TickCPU(cycles int) {
+	if m.eeprom != nil {
+		m.eeprom.tick(cycles)
+	}
+	if !m.irqEnabled {
+		return
+	}
+	for i := 0; i < cycles; i++ {
+		m.irqCounter--
+		if m.irqCounter == 0 {
+			m.irqPending = true
+		}
+	}
+}
+
+// Step is a no-op: the IRQ runs on the CPU clock (TickCPU).
+func (m *BandaiFCG) Step() {}
+
+func (m *BandaiFCG) IRQLine() bool { return m.irqPending }
+func (m *BandaiFCG) ClearIRQ()     { m.irqPending = false }
+
+// IRQCapable marks the FCG boards as IRQ-asserting (CPU-clock counter).
+func (m *BandaiFCG) IRQCapable() {}
+
+// Reset implements Resetter: the counter powers up stopped.
+func (m *BandaiFCG) Reset() {
+	m.irqEnabled, m.irqPending = false, false
+}
+
+// GetMirroringMode returns the PPU-encoded mirroring.
+func (m *BandaiFCG) GetMirroringMode() MirroringMode {
+	switch m.mirroring {
+	case 0:
+		return MirroringVertical
+	case 1:
+		return MirroringHorizontal
+	default:
+		return MirroringMode(m.mirroring)
+	}
+}
+
+// NonVolatile implements NonVolatileMemory: the EEPROM. Mapper 153's PRG
+// RAM is saved as battery RAM instead.
+func (m *BandaiFCG) NonVolatile() ([]uint8, string) {
+	if m.eeprom == nil {
+		return nil, ""
+	}
+	return m.eeprom.data, "EEPROM"
+}
+
+type bandaiState struct {
+	CHRBanks   [8]uint8
+	PRGBank    uint8
+	Outer      uint8
+	Control    uint8
+	Mirroring  uint8
+	IRQEnabled bool
+	IRQPending bool
+	IRQCounter uint16
+	IRQLatch   uint16
+	EEPROM     eepromState
+}
+
+func (m *BandaiFCG) state() bandaiState {
+	s := bandaiState{
+		CHRBanks:   m.chrBanks,
+		PRGBank:    m.prgBank,
+		Outer:      m.outer,
+		Control:    m.control,
+		Mirroring:  m.mirroring,
+		IRQEnabled: m.irqEnabled,
+		IRQPending: m.irqPending,
+		IRQCounter: m.irqCounter,
+		IRQLatch:   m.irqLatch,
+	}
+	if m.eeprom != nil {
+		s.EEPROM = m.eeprom.state()
+	}
+	return s
+}
+
+// SaveState persists the registers, the IRQ counter and the EEPROM's
+// transfer in progress. The EEPROM contents are the save file's.
+func (m *BandaiFCG) SaveState(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, m.state())
+}
+
+// AppendState implements StateAppender.
+func (m *BandaiFCG) AppendState(b []byte) []byte {
+	s := m.state()
+	b = append(b, s.CHRBanks[:]...)
+	b = append(b, s.PRGBank, s.Outer, s.Control, s.Mirroring)
+	b = appendBool(b, s.IRQEnabled)
+	b = appendBool(b, s.IRQPending)
+	b = appendUint16s(b, []uint16{s.IRQCounter, s.IRQLatch})
+	return s.EEPROM.appendState(b)
+}
+
+// LoadState restores state written by SaveState.
+func (m *BandaiFCG) LoadState(r io.Reader) error {
+	var s bandaiState
+	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
+		return err
+	}
+	m.chrBanks = s.CHRBanks
+	m.prgBank, m.outer, m.control, m.mirroring = s.PRGBank, s.Outer, s.Control, s.Mirroring
+	m.irqEnabled, m.irqPending = s.IRQEnabled, s.IRQPending
+	m.irqCounter, m.irqLatch = s.IRQCounter, s.IRQLatch
+	if m.eeprom != nil {
+		m.eeprom.setState(s.EEPROM)
+	}
+	m.updatePRGBanks()
+	return nil
+}
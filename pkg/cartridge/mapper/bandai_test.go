@@ -0,0 +1,190 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newBandai builds mapper n with each 16 KiB PRG bank and 1 KiB CHR bank
+// tagged in its first byte.
+func newBandai(n, submapper uint8, prgKB int, chr bool) *BandaiFCG {
+	prg := make([]uint8, prgKB<<10)
+	for b := 0; b < len(prg)/0x4000; b++ {
+		prg[b*0x4000] = uint8(b)
+	}
+	d := &CartridgeData{PRGROM: prg, PRGRAM: make([]uint8, 8<<10), Submapper: submapper}
+	if chr {
+		d.CHRROM = make([]uint8, 256<<10)
+		for b := 0; b < 256; b++ {
+			d.CHRROM[b*1024] = uint8(b)
+		}
+	} else {
+		d.CHRRAM = make([]uint8, 8<<10)
+	}
+	return NewBandaiFCG(n, d)
+}
+
+// bandaiBus drives the EEPROM through $800D and $6000 the way the games
+// do, releasing SDA (bit 7) rather than driving it high.
+type bandaiBus struct{ m *BandaiFCG }
+
+func (b bandaiBus) setLines(scl, sda bool) {
+	v := uint8(0)
+	if scl {
+		v |= 0x20
+	}
+	if sda {
+		v |= 0x80
+	}
+	b.m.WritePRG(0x800D, v)
+}
+
+func (b bandaiBus) sda() bool { return b.m.ReadPRG(0x6000)&0x10 != 0 }
+
+func TestBandaiFCGBanking(t *testing.T) {
+	m := newBandai(16, 0, 256, true)
+	if m.ReadPRG(0x8000) != 0 || m.ReadPRG(0xC000) != 15 {
+		t.Errorf("power-on: $8000 bank %d, $C000 bank %d", m.ReadPRG(0x8000), m.ReadPRG(0xC000))
+	}
+	m.WritePRG(0x8008, 5)
+	m.WritePRG(0x6003, 0x42) // FCG range on an iNES 1.0 image
+	m.WritePRG(0x8009, 1)
+	if got := m.ReadPRG(0x8000); got != 5 {
+		t.Errorf("PRG bank = %d, want 5", got)
+	}
+	if got := m.ReadCHR(0x0C00); got != 0x42 {
+		t.Errorf("CHR bank 3 = %d, want $42", got)
+	}
+	if got := m.GetMirroringMode(); got != MirroringHorizontal {
+		t.Errorf("mirroring = %d, want horizontal", got)
+	}
+
+	// The LZ93D50 ignores $6000-$7FFF writes, the FCG-1/2 $8000-$FFFF ones.
+	lz, fcg := newBandai(16, 5, 256, true), newBandai(16, 4, 256, true)
+	lz.WritePRG(0x6008, 3)
+	fcg.WritePRG(0x8008, 3)
+	if lz.ReadPRG(0x8000) != 0 || fcg.ReadPRG(0x8000) != 0 {
+		t.Error("register decoded outside the chip's range")
+	}
+
+	// Mapper 153: CHR bit 0 picks the 256 KiB half, PRG RAM behind $800D.
+	m = newBandai(153, 0, 512, false)
+	m.WritePRG(0x8000, 1)
+	m.WritePRG(0x8008, 2)
+	if m.ReadPRG(0x8000) != 18 || m.ReadPRG(0xC000) != 31 {
+		t.Errorf("outer bank: $8000 bank %d, $C000 bank %d", m.ReadPRG(0x8000), m.ReadPRG(0xC000))
+	}
+	m.WritePRG(0x6000, 0x77)
+	if m.PRGRAMEnabled() || m.cartridge.PRGRAM[0] != 0 {
+		t.Error("PRG RAM writable while disabled")
+	}
+	m.WritePRG(0x800D, 0x20)
+	m.WritePRG(0x6000, 0x77)
+	if !m.PRGRAMEnabled() || m.ReadPRG(0x6000) != 0x77 {
+		t.Error("PRG RAM not enabled by $800D bit 5")
+	}
+}
+
+func TestBandaiFCGIRQ(t *testing.T) {
+	// LZ93D50: the latch loads the counter on the $800A write.
+	m := newBandai(16, 5, 256, true)
+	m.WritePRG(0x800B, 0x10)
+	m.WritePRG(0x800C, 0x00)
+	m.WritePRG(0x800A, 1)
+	m.TickCPU(15)
+	if m.IRQLine() {
+		t.Fatal("IRQ before the counter reached zero")
+	}
+	m.TickCPU(1)
+	if !m.IRQLine() {
+		t.Fatal("no IRQ at zero")
+	}
+	m.WritePRG(0x800A, 0)
+	if m.IRQLine() {
+		t.Error("$800A didn't acknowledge")
+	}
+
+	// FCG-1/2: $600B/$600C write the counter itself.
+	m = newBandai(16, 4, 256, true)
+	m.WritePRG(0x600A, 1)
+	m.WritePRG(0x600B, 0x03)
+	m.WritePRG(0x600C, 0x00)
+	m.TickCPU(3)
+	if !m.IRQLine() {
+		t.Error("FCG counter didn't count down from its written value")
+	}
+}
+
+func TestBandaiFCGEEPROM(t *testing.T) {
+	for _, tc := range []struct {
+		mapper uint8
+		size   int
+		lsb    bool
+	}{{16, 256, false}, {159, 128, true}} {
+		m := newBandai(tc.mapper, 0, 256, true)
+		data, kind := m.NonVolatile()
+		if len(data) != tc.size || kind != "EEPROM" {
+			t.Fatalf("mapper %d: NonVolatile gives %d bytes of %q", tc.mapper, len(data), kind)
+		}
+		bus := i2c{e: bandaiBus{m}, lsb: tc.lsb}
+		first := []uint8{0x12}
+		if !tc.lsb {
+			first = []uint8{0xA0, 0x12}
+		}
+		bus.start()
+		for _, v := range append(first, 0x5A) {
+			if !bus.send(v) {
+				t.Fatalf("mapper %d: %02X not acknowledged", tc.mapper, v)
+			}
+		}
+		bus.stop()
+		if data[0x12] != 0x5A {
+			t.Errorf("mapper %d: EEPROM[$12] = %02X, want 5A", tc.mapper, data[0x12])
+		}
+		m.TickCPU(eepromWriteCycles)
+
+		// And reads back through $6000 bit 4.
+		bus.start()
+		if tc.lsb {
+			bus.send(0x80 | 0x12)
+		} else {
+			bus.send(0xA0)
+			bus.send(0x12)
+			bus.start()
+			bus.send(0xA1)
+		}
+		if got := bus.recv(false); got != 0x5A {
+			t.Errorf("mapper %d: read back %02X, want 5A", tc.mapper, got)
+		}
+		bus.stop()
+	}
+
+	if data, _ := newBandai(153, 0, 512, false).NonVolatile(); data != nil {
+		t.Error("mapper 153 has an EEPROM")
+	}
+}
+
+func TestBandaiFCGState(t *testing.T) {
+	m := newBandai(16, 0, 256, true)
+	m.WritePRG(0x8008, 3)
+	m.WritePRG(0x8002, 9)
+	m.WritePRG(0x800B, 0x34)
+	m.WritePRG(0x800C, 0x12)
+	m.WritePRG(0x800A, 1)
+	m.TickCPU(4)
+	var buf bytes.Buffer
+	if err := m.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.AppendState(nil); !bytes.Equal(got, buf.Bytes()) {
+		t.Errorf("AppendState % X, SaveState % X", got, buf.Bytes())
+	}
+
+	n := newBandai(16, 0, 256, true)
+	if err := n.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n.ReadPRG(0x8000) != 3 || n.ReadCHR(0x0800) != 9 || n.irqCounter != 0x1230 || !n.irqEnabled {
+		t.Errorf("restored PRG %d, CHR %d, counter %04X", n.ReadPRG(0x8000), n.ReadCHR(0x0800), n.irqCounter)
+	}
+}
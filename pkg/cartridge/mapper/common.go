@@ -49,6 +49,25 @@ func readCHRROMOrRAM(data *CartridgeData, addr uint16) uint8 {
 	return 0
 }
 
+// chrMemory returns the memory a mapper's CHR bank registers select
+// from: CHR ROM when the cartridge has it, else CHR RAM. Boards whose
+// NES 2.0 header asks for 16 or 32 KiB of CHR RAM bank it like ROM.
+func chrMemory(data *CartridgeData) []uint8 {
+	if len(data.CHRROM) > 0 {
+		return data.CHRROM
+	}
+	return data.CHRRAM
+}
+
+// writeBankedCHRRAM stores value at offset into CHR RAM, offset having
+// gone through the same bank math as reads. A no-op on CHR ROM boards and
+// for offsets outside the RAM.
+func writeBankedCHRRAM(data *CartridgeData, offset int, value uint8) {
+	if len(data.CHRROM) == 0 && offset >= 0 && offset < len(data.CHRRAM) {
+		data.CHRRAM[offset] = value
+	}
+}
+
 // writeCHRRAM writes to CHR RAM when present, no-op otherwise.
 func writeCHRRAM(data *CartridgeData, addr uint16, value uint8) {
 	if len(data.CHRRAM) == 0 {
@@ -58,3 +77,52 @@ func writeCHRRAM(data *CartridgeData, addr uint16, value uint8) {
 		data.CHRRAM[addr] = value
 	}
 }
+
+// prgBankTable is the precomputed $8000-$FFFF mapping used by the
+// table-driven mappers: entry i is the 8KB slice of PRG ROM visible at
+// $8000+i*$2000. Mappers rebuild it only when a bank register changes, so
+// a PRG fetch is one index into a slice instead of re-deriving the bank
+// from mode bits on every CPU read. The memory bus reads the same table
+// directly (see PRGBankMapper).
+type prgBankTable [4][]uint8
+
+// set points window w at 8KB bank `bank` of rom. The bank number wraps
+// modulo the ROM's bank count, which is how the unconnected high select
+// lines behave on real boards. A ROM whose size isn't a whole number of
+// 8KB banks leaves the window nil (unmapped, reads 0).
+func (t *prgBankTable) set(w int, rom []uint8, bank int) {
+	n := len(rom) / 0x2000
+	if n == 0 || len(rom)%0x2000 != 0 {
+		t[w] = nil
+		return
+	}
+	bank %= n
+	if bank < 0 {
+		bank += n
+	}
+	t[w] = rom[bank*0x2000 : (bank+1)*0x2000 : (bank+1)*0x2000]
+}
+
+// set16K maps a 16KB bank across windows w and w+1.
+func (t *prgBankTable) set16K(w int, rom []uint8, bank int) {
+	t.set(w, rom, bank*2)
+	t.set(w+1, rom, bank*2+1)
+}
+
+// setFixed maps rom straight through from $8000, for boards without PRG
+// banking. A 16KB ROM appears twice (its second copy supplies the vectors
+// at $FFFA-$FFFF), an 8KB one four times.
+func (t *prgBankTable) setFixed(rom []uint8) {
+	for w := range t {
+		t.set(w, rom, w)
+	}
+}
+
+// read returns the PRG ROM byte at addr ($8000-$FFFF).
+func (t *prgBankTable) read(addr uint16) uint8 {
+	b := t[(addr>>13)&3]
+	if b == nil {
+		return 0
+	}
+	return b[addr&0x1FFF]
+}
@@ -0,0 +1,277 @@
+package mapper
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Discrete-logic boards: a latch (74x161, 74x377 …) whose bits drive the
+// high PRG and/or CHR address lines directly. Every such board is "a write
+// to some address range stores the byte, and fixed bit fields of it pick
+// the banks", so rather than one file per board they are described as
+// data (discreteBoard) and run by one implementation (Discrete).
+
+// discreteField is one bank-select field of a latch: (value>>shift)&mask
+// picks the bank for PRG or CHR window slot.
+type discreteField struct {
+	chr   bool // CHR window (else PRG)
+	slot  int
+	shift uint8
+	mask  uint8
+}
+
+// discreteReg is one latch and the addresses that write it.
+type discreteReg struct {
+	lo, hi uint16
+	fields []discreteField
+}
+
+// discreteBoard describes a board for Discrete.
+type discreteBoard struct {
+	// prgWindow is the switchable PRG size: 0x8000 (one 32 KiB window) or
+	// 0x4000 (16 KiB at $8000, last 16 KiB fixed at $C000).
+	prgWindow int
+	// chrWindow is the CHR bank size: 0x2000 or 0x1000 (two windows).
+	// Boards with CHR RAM ignore CHR fields.
+	chrWindow int
+	regs      []discreteReg
+	// busConflicts is true for latches decoded in ROM space that the ROM
+	// drives at the same time (no /OE gating): the latch sees the written
+	// value ANDed with the ROM byte, so games write to a matching byte.
+	busConflicts bool
+	// prgRAM is true when $6000-$7FFF is RAM on this board.
+	prgRAM bool
+}
+
+// Board descriptions, from the NESdev wiki pages of the same names.
+var (
+	// Mapper 11, Color Dreams: PRG 32K in D0-D1, CHR 8K in D4-D7.
+	boardColorDreams = discreteBoard{
+		prgWindow: 0x8000, chrWindow: 0x2000, busConflicts: true,
+		regs: []discreteReg{{0x8000, 0xFFFF, []discreteField{
+			{slot: 0, shift: 0, mask: 0x03},
+			{chr: true, slot: 0, shift: 4, mask: 0x0F},
+		}}},
+	}
+	// Mapper 66, GxROM: PRG 32K in D4-D5, CHR 8K in D0-D1.
+	boardGxROM = discreteBoard{
+		prgWindow: 0x8000, chrWindow: 0x2000, busConflicts: true,
+		regs: []discreteReg{{0x8000, 0xFFFF, []discreteField{
+			{slot: 0, shift: 4, mask: 0x03},
+			{chr: true, slot: 0, shift: 0, mask: 0x03},
+		}}},
+	}
+	// Mapper 34 submapper 2, BNROM: PRG 32K, CHR RAM. The board has two
+	// select bits; oversize homebrew uses all eight, which wrap anyway.
+	boardBNROM = discreteBoard{
+		prgWindow: 0x8000, chrWindow: 0x2000, busConflicts: true,
+		regs: []discreteReg{{0x8000, 0xFFFF, []discreteField{
+			{slot: 0, shift: 0, mask: 0xFF},
+		}}},
+	}
+	// Mapper 34 submapper 1, AVE NINA-001: three latches at the top of
+	// its 8 KiB PRG RAM (which stores the writes as well): $7FFD PRG 32K,
+	// $7FFE CHR 4K at $0000, $7FFF CHR 4K at $1000.
+	boardNINA001 = discreteBoard{
+		prgWindow: 0x8000, chrWindow: 0x1000, prgRAM: true,
+		regs: []discreteReg{
+			{0x7FFD, 0x7FFD, []discreteField{{slot: 0, shift: 0, mask: 0x01}}},
+			{0x7FFE, 0x7FFE, []discreteField{{chr: true, slot: 0, shift: 0, mask: 0x0F}}},
+			{0x7FFF, 0x7FFF, []discreteField{{chr: true, slot: 1, shift: 0, mask: 0x0F}}},
+		},
+	}
+	// Mapper 38, Bit Corp. UNL-PCI556 (Crime Busters): latch at
+	// $7000-$7FFF, PRG 32K in D0-D1, CHR 8K in D2-D3.
+	boardBitCorp38 = discreteBoard{
+		prgWindow: 0x8000, chrWindow: 0x2000,
+		regs: []discreteReg{{0x7000, 0x7FFF, []discreteField{
+			{slot: 0, shift: 0, mask: 0x03},
+			{chr: true, slot: 0, shift: 2, mask: 0x03},
+		}}},
+	}
+	// Mapper 140, Jaleco JF-11/JF-14: latch at $6000-$7FFF, PRG 32K in
+	// D4-D5, CHR 8K in D0-D3.
+	boardJalecoJF11 = discreteBoard{
+		prgWindow: 0x8000, chrWindow: 0x2000,
+		regs: []discreteReg{{0x6000, 0x7FFF, []discreteField{
+			{slot: 0, shift: 4, mask: 0x03},
+			{chr: true, slot: 0, shift: 0, mask: 0x0F},
+		}}},
+	}
+)
+
+// discreteBoardFor picks the board for a mapper number handled by
+// Discrete. Mapper 34 is two unrelated boards: NES 2.0 submapper 1 is
+// NINA-001 and 2 is BNROM; for iNES 1.0 images CHR ROM over 8 KiB means
+// NINA-001 (BNROM has CHR RAM).
+func discreteBoardFor(mapperNumber uint8, data *CartridgeData) discreteBoard {
+	switch mapperNumber {
+	case 11:
+		return boardColorDreams
+	case 34:
+		if data.Submapper == 1 || (data.Submapper == 0 && len(data.CHRROM) > 0x2000) {
+			return boardNINA001
+		}
+		return boardBNROM
+	case 38:
+		return boardBitCorp38
+	case 66:
+		return boardGxROM
+	default: // 140
+		return boardJalecoJF11
+	}
+}
+
+// Discrete runs a discreteBoard: mappers 11, 34, 38, 66 and 140.
+type Discrete struct {
+	cartridge *CartridgeData
+	board     discreteBoard
+
+	prgBanks [2]uint8
+	chrBanks [2]uint8
+	prg      prgBankTable // $8000-$FFFF windows, rebuilt by updatePRGBanks
+
+	// busConflictMode has Mapper3's meaning (1 = none, 2 = AND-type); it
+	// starts from the board and can be overridden.
+	busConflictMode uint8
+}
+
+func init() {
+	newDiscrete := func(n uint8, d *CartridgeData) Mapper { return NewDiscrete(n, d) }
+	Register(Board{Name: "Color Dreams", New: newDiscrete}, 11)
+	Register(Board{Name: "BNROM / NINA-001", Submappers: []uint8{1, 2}, New: newDiscrete}, 34)
+	Register(Board{Name: "Bit Corp. PCI556", New: newDiscrete}, 38)
+	Register(Board{Name: "GxROM", New: newDiscrete}, 66)
+	Register(Board{Name: "Jaleco JF-11/JF-14", New: newDiscrete}, 140)
+}
+
+// NewDiscrete creates the discrete-logic mapper for mapperNumber.
+func NewDiscrete(mapperNumber uint8, data *CartridgeData) *Discrete {
+	m := &Discrete{cartridge: data, board: discreteBoardFor(mapperNumber, data)}
+	m.busConflictMode = 1
+	if m.board.busConflicts {
+		m.busConflictMode = 2
+	}
+	m.updatePRGBanks()
+	return m
+}
+
+// SetBusConflictMode overrides the board's bus-conflict behaviour:
+// 1 = no conflicts, 2 = AND-type conflicts (0 is treated as 1).
+func (m *Discrete) SetBusConflictMode(mode uint8) {
+	if mode <= 2 {
+		m.busConflictMode = mode
+	}
+}
+
+// PRGBanks implements PRGBankMapper.
+func (m *Discrete) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+func (m *Discrete) updatePRGBanks() {
+	rom := m.cartridge.PRGROM
+	if m.board.prgWindow == 0x4000 {
+		m.prg.set16K(0, rom, int(m.prgBanks[0]))
+		m.prg.set16K(2, rom, len(rom)/0x4000-1)
+		return
+	}
+	for w := 0; w < 4; w++ {
+		m.prg.set(w, rom, int(m.prgBanks[0])*4+w)
+	}
+}
+
+// ReadPRG reads from PRG space.
+func (m *Discrete) ReadPRG(addr uint16) uint8 {
+	if addr >= 0x8000 {
+		return m.prg.read(addr)
+	}
+	if m.board.prgRAM {
+		return readPRGRAM(m.cartridge, addr)
+	}
+	return 0
+}
+
+// WritePRG latches bank numbers from writes to a register range, and
+// stores $6000-$7FFF writes in RAM on boards that have it.
+func (m *Discrete) WritePRG(addr uint16, value uint8) {
+	if addr < 0x8000 && m.board.prgRAM {
+		writePRGRAM(m.cartridge, addr, value)
+	}
+	if addr >= 0x8000 && m.busConflictMode == 2 {
+		value &= m.prg.read(addr)
+	}
+	for _, r := range m.board.regs {
+		if addr < r.lo || addr > r.hi {
+			continue
+		}
+		for _, f := range r.fields {
+			bank := value >> f.shift & f.mask
+			if f.chr {
+				m.chrBanks[f.slot] = bank
+			} else {
+				m.prgBanks[f.slot] = bank
+			}
+		}
+		m.updatePRGBanks()
+	}
+}
+
+// ReadCHR reads through the selected CHR bank, or CHR RAM when the board
+// has no CHR ROM.
+func (m *Discrete) ReadCHR(addr uint16) uint8 {
+	rom := m.cartridge.CHRROM
+	if len(rom) == 0 {
+		return readCHRROMOrRAM(m.cartridge, addr)
+	}
+	size := m.board.chrWindow
+	slot := int(addr) / size
+	n := len(rom) / size
+	if n == 0 {
+		return 0
+	}
+	offset := int(m.chrBanks[slot])%n*size + int(addr)%size
+	return rom[offset]
+}
+
+// WriteCHR writes CHR RAM when present.
+func (m *Discrete) WriteCHR(addr uint16, value uint8) {
+	if addr < 0x2000 {
+		writeCHRRAM(m.cartridge, addr, value)
+	}
+}
+
+// Step is a no-op: no discrete board has an IRQ.
+func (m *Discrete) Step() {}
+
+// IRQLine always false.
+func (m *Discrete) IRQLine() bool { return false }
+
+// ClearIRQ is a no-op.
+func (m *Discrete) ClearIRQ() {}
+
+type discreteState struct {
+	PRGBanks [2]uint8
+	CHRBanks [2]uint8
+}
+
+// SaveState writes the latched bank numbers. The board and bus-conflict
+// mode are configuration, not state.
+func (m *Discrete) SaveState(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, discreteState{m.prgBanks, m.chrBanks})
+}
+
+// AppendState implements StateAppender.
+func (m *Discrete) AppendState(b []byte) []byte {
+	b = append(b, m.prgBanks[:]...)
+	return append(b, m.chrBanks[:]...)
+}
+
+// LoadState restores the bank numbers written by SaveState.
+func (m *Discrete) LoadState(r io.Reader) error {
+	var s discreteState
+	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
+		return err
+	}
+	m.prgBanks, m.chrBanks = s.PRGBanks, s.CHRBanks
+	m.updatePRGBanks()
+	return nil
+}
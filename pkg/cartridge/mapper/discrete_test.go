@@ -0,0 +1,125 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+)
+
+// discreteData is makeData(8, 16) — four 32KB PRG banks tagged 0xA0+2n at
+// their first byte, sixteen 4KB CHR banks tagged 0xC0+n — with the rest of
+// PRG ROM set to $FF so register writes away from a tag see no bus
+// conflict.
+func discreteData(submapper uint8, chrRAM bool) *CartridgeData {
+	d := makeData(8, 16)
+	for i := range d.PRGROM {
+		if i%16384 != 0 {
+			d.PRGROM[i] = 0xFF
+		}
+	}
+	if chrRAM {
+		d.CHRROM = nil
+	}
+	d.Submapper = submapper
+	return d
+}
+
+type discreteWrite struct {
+	addr  uint16
+	value uint8
+}
+
+func TestDiscreteBoards(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		mapper, sub uint8
+		chrRAM      bool
+		writes      []discreteWrite
+		prg         uint8            // tag at $8000 afterwards
+		chr         map[uint16]uint8 // CHR address -> tag (CHR ROM boards)
+	}{
+		{"Color Dreams", 11, 0, false, []discreteWrite{{0x8001, 0x32}}, 0xA4, map[uint16]uint8{0x0000: 0xC6}},
+		{"GxROM", 66, 0, false, []discreteWrite{{0xC123, 0x13}}, 0xA2, map[uint16]uint8{0x0000: 0xC6}},
+		{"BNROM", 34, 2, true, []discreteWrite{{0x8001, 0x03}}, 0xA6, nil},
+		{"BNROM by CHR RAM", 34, 0, true, []discreteWrite{{0xFFF0, 0x02}}, 0xA4, nil},
+		{"NINA-001", 34, 1, false, []discreteWrite{{0x7FFD, 1}, {0x7FFE, 5}, {0x7FFF, 9}}, 0xA2,
+			map[uint16]uint8{0x0000: 0xC5, 0x1000: 0xC9}},
+		{"NINA-001 by CHR size", 34, 0, false, []discreteWrite{{0x7FFF, 2}, {0x8001, 0xFF}}, 0xA0,
+			map[uint16]uint8{0x0000: 0xC0, 0x1000: 0xC2}},
+		{"Bit Corp 38", 38, 0, false, []discreteWrite{{0x7000, 0x0E}, {0x8001, 0x00}}, 0xA4, map[uint16]uint8{0x0000: 0xC6}},
+		{"Jaleco JF-11", 140, 0, false, []discreteWrite{{0x6000, 0x25}}, 0xA4, map[uint16]uint8{0x0000: 0xCA}},
+	} {
+		m := NewDiscrete(tc.mapper, discreteData(tc.sub, tc.chrRAM))
+		for _, w := range tc.writes {
+			m.WritePRG(w.addr, w.value)
+		}
+		if got := m.ReadPRG(0x8000); got != tc.prg {
+			t.Errorf("%s: $8000 = %#02x, want %#02x", tc.name, got, tc.prg)
+		}
+		if got := m.PRGBanks()[0][0]; got != tc.prg {
+			t.Errorf("%s: PRGBanks window 0 = %#02x, want %#02x", tc.name, got, tc.prg)
+		}
+		for addr, want := range tc.chr {
+			if got := m.ReadCHR(addr); got != want {
+				t.Errorf("%s: CHR $%04X = %#02x, want %#02x", tc.name, addr, got, want)
+			}
+		}
+		if tc.chrRAM {
+			m.WriteCHR(0x1234, 0x5A)
+			if got := m.ReadCHR(0x1234); got != 0x5A {
+				t.Errorf("%s: CHR RAM = %#02x, want 0x5A", tc.name, got)
+			}
+		}
+	}
+}
+
+func TestDiscreteBusConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		mapper    uint8
+		mode      uint8 // 0 = board default
+		prg, chr0 uint8
+	}{
+		// $8000 holds the tag 0xA0: writing $FF latches $A0.
+		{"Color Dreams", 11, 0, 0xA0, 0xC4},              // PRG 0, CHR 10 % 8 = 2
+		{"Color Dreams no conflicts", 11, 1, 0xA6, 0xCE}, // PRG 3, CHR 15 % 8 = 7
+		{"GxROM", 66, 0, 0xA4, 0xC0},                     // PRG 2, CHR 0
+	} {
+		m := NewDiscrete(tc.mapper, discreteData(0, false))
+		if tc.mode != 0 {
+			m.SetBusConflictMode(tc.mode)
+		}
+		m.WritePRG(0x8000, 0xFF)
+		if got := m.ReadPRG(0x8000); got != tc.prg {
+			t.Errorf("%s: $8000 = %#02x, want %#02x", tc.name, got, tc.prg)
+		}
+		if got := m.ReadCHR(0); got != tc.chr0 {
+			t.Errorf("%s: CHR $0000 = %#02x, want %#02x", tc.name, got, tc.chr0)
+		}
+	}
+}
+
+func TestDiscretePRGRAMAndState(t *testing.T) {
+	nina := NewDiscrete(34, discreteData(1, false))
+	nina.WritePRG(0x6000, 0x77)
+	nina.WritePRG(0x7FFE, 0x03)
+	if nina.ReadPRG(0x6000) != 0x77 || nina.ReadPRG(0x7FFE) != 0x03 {
+		t.Error("NINA-001 PRG RAM should hold $6000 and register writes alike")
+	}
+	jf11 := NewDiscrete(140, discreteData(0, false))
+	jf11.WritePRG(0x6000, 0x10)
+	if got := jf11.ReadPRG(0x6000); got != 0 {
+		t.Errorf("JF-11 has no PRG RAM, $6000 read %#02x", got)
+	}
+
+	var buf bytes.Buffer
+	if err := nina.SaveState(&buf); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	m2 := NewDiscrete(34, discreteData(1, false))
+	if err := m2.LoadState(&buf); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if m2.ReadCHR(0x0000) != 0xC3 {
+		t.Errorf("restored CHR $0000 = %#02x, want 0xC3", m2.ReadCHR(0x0000))
+	}
+}
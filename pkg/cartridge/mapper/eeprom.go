@@ -0,0 +1,240 @@
+package mapper
+
+import "encoding/binary"
+
+// eeprom is the serial EEPROM on Bandai's FCG boards, driven bit by bit
+// through a mapper register. The 24C02 (256 bytes) speaks standard I²C: a
+// device address byte ($A0 to write, $A1 to read) comes before the word
+// address, bits go most significant first and a page is 8 bytes. The
+// X24C01 (128 bytes) has no device byte — the first byte after a start
+// is the word address with the read bit on top — shifts bits least
+// significant first and has 4-byte pages.
+//
+// The mapper calls setLines with the clock (SCL) and data (SDA) it drives
+// and reads the chip's data output with sda. SDA falling while SCL is high
+// is a start, rising a stop; otherwise the chip samples SDA as SCL rises
+// and changes its own output after SCL falls, pulling it low for the
+// acknowledge bit after each byte it receives. The stop that ends a write
+// starts the chip's write cycle (eepromWriteCycles, counted down by
+// tick), during which it acknowledges nothing: games poll for that.
+type eeprom struct {
+	data   []uint8
+	x24c01 bool
+
+	scl, sdaIn bool // the lines as the mapper last drove them
+	out        bool // the chip's SDA output, true when released (high)
+
+	phase   eepromPhase
+	next    eepromPhase // the phase after the acknowledge bit
+	shift   uint8       // the byte coming in or going out
+	bits    uint8       // bits of it clocked so far
+	addr    uint8       // word address
+	ack     bool        // the mapper acknowledged the byte just read
+	written bool        // data has been written since the start
+	busy    int32       // CPU cycles left of the write cycle
+}
+
+// eepromPhase is where an eeprom is in a transfer.
+type eepromPhase uint8
+
+const (
+	eepromIdle    eepromPhase = iota // waiting for a start
+	eepromDevice                     // receiving the device address (24C02)
+	eepromAddress                    // receiving the word address
+	eepromWrite                      // receiving data
+	eepromRead                       // sending data
+	eepromAck                        // acknowledging a received byte
+	eepromReadAck                    // released for the mapper's acknowledge
+)
+
+// eepromWriteCycles is the write cycle, 5 ms in NTSC CPU cycles.
+const eepromWriteCycles = 8_948
+
+// new24C02 and newX24C01 build the two chips, erased.
+func new24C02() *eeprom  { return newEEPROM(256, false) }
+func newX24C01() *eeprom { return newEEPROM(128, true) }
+
+func newEEPROM(size int, x24c01 bool) *eeprom {
+	e := &eeprom{data: make([]uint8, size), x24c01: x24c01, out: true, scl: true, sdaIn: true}
+	for i := range e.data {
+		e.data[i] = 0xFF
+	}
+	return e
+}
+
+// sda is the chip's data output.
+func (e *eeprom) sda() bool { return e.out }
+
+// tick counts down a write cycle.
+func (e *eeprom) tick(cycles int) {
+	if e.busy > 0 {
+		e.busy -= int32(cycles)
+	}
+}
+
+// setLines drives SCL and SDA.
+func (e *eeprom) setLines(scl, sda bool) {
+	switch {
+	case e.scl && scl && e.sdaIn && !sda:
+		e.start()
+	case e.scl && scl && !e.sdaIn && sda:
+		e.stop()
+	case !e.scl && scl:
+		e.rise(sda)
+	case e.scl && !scl:
+		e.fall()
+	}
+	e.scl, e.sdaIn = scl, sda
+}
+
+func (e *eeprom) start() {
+	e.out, e.written = true, false
+	e.shift, e.bits = 0, 0
+	switch {
+	case e.busy > 0:
+		e.phase = eepromIdle
+	case e.x24c01:
+		e.phase = eepromAddress
+	default:
+		e.phase = eepromDevice
+	}
+}
+
+func (e *eeprom) stop() {
+	if e.written {
+		e.busy = eepromWriteCycles
+	}
+	e.phase, e.out, e.written = eepromIdle, true, false
+}
+
+// rise samples SDA on SCL's rising edge.
+func (e *eeprom) rise(sda bool) {
+	switch e.phase {
+	case eepromDevice, eepromAddress, eepromWrite:
+		var bit uint8
+		if sda {
+			bit = 1
+		}
+		if e.x24c01 {
+			e.shift |= bit << e.bits
+		} else {
+			e.shift = e.shift<<1 | bit
+		}
+		e.bits++
+	case eepromReadAck:
+		e.ack = !sda
+	}
+}
+
+// fall moves the chip's output on after SCL's falling edge.
+func (e *eeprom) fall() {
+	switch e.phase {
+	case eepromDevice, eepromAddress, eepromWrite:
+		if e.bits == 8 {
+			e.receive(e.shift)
+		}
+	case eepromAck:
+		e.out = true
+		e.phase, e.shift, e.bits = e.next, 0, 0
+		if e.phase == eepromRead {
+			e.load()
+		}
+	case eepromRead:
+		if e.bits < 8 {
+			e.out = e.bit(e.bits)
+			e.bits++
+		} else {
+			e.phase, e.out = eepromReadAck, true
+		}
+	case eepromReadAck:
+		if !e.ack {
+			e.phase = eepromIdle
+			return
+		}
+		e.addr = uint8(int(e.addr+1) % len(e.data))
+		e.phase = eepromRead
+		e.load()
+	}
+}
+
+// receive acts on a whole byte from the mapper and acknowledges it, or
+// for a device address that isn't the chip's drops out of the transfer.
+func (e *eeprom) receive(b uint8) {
+	switch e.phase {
+	case eepromDevice:
+		if b&0xF0 != 0xA0 {
+			e.phase = eepromIdle
+			return
+		}
+		e.next = eepromAddress
+		if b&1 != 0 {
+			e.next = eepromRead
+		}
+	case eepromAddress:
+		e.next = eepromWrite
+		if e.x24c01 {
+			if b&0x80 != 0 {
+				e.next = eepromRead
+			}
+			b &= 0x7F
+		}
+		e.addr = uint8(int(b) % len(e.data))
+	case eepromWrite:
+		e.data[e.addr] = b
+		e.written = true
+		page := uint8(8)
+		if e.x24c01 {
+			page = 4
+		}
+		e.addr = e.addr&^(page-1) | (e.addr+1)&(page-1)
+		e.next = eepromWrite
+	}
+	e.phase, e.out = eepromAck, false
+}
+
+// load puts the byte at addr in the shift register and its first bit on
+// the output.
+func (e *eeprom) load() {
+	e.shift = e.data[e.addr]
+	e.out, e.bits = e.bit(0), 1
+}
+
+// bit is bit i of the outgoing byte in the chip's order.
+func (e *eeprom) bit(i uint8) bool {
+	if e.x24c01 {
+		return e.shift>>i&1 != 0
+	}
+	return e.shift<<i&0x80 != 0
+}
+
+// eepromState is the transfer a save state keeps; the contents are the
+// save file's.
+type eepromState struct {
+	SCL, SDAIn, Out bool
+	Phase, Next     eepromPhase
+	Shift, Bits     uint8
+	Addr            uint8
+	Ack, Written    bool
+	Busy            int32
+}
+
+func (e *eeprom) state() eepromState {
+	return eepromState{e.scl, e.sdaIn, e.out, e.phase, e.next, e.shift, e.bits, e.addr, e.ack, e.written, e.busy}
+}
+
+func (e *eeprom) setState(s eepromState) {
+	e.scl, e.sdaIn, e.out = s.SCL, s.SDAIn, s.Out
+	e.phase, e.next, e.shift, e.bits, e.addr = s.Phase, s.Next, s.Shift, s.Bits, s.Addr
+	e.ack, e.written, e.busy = s.Ack, s.Written, s.Busy
+}
+
+// appendState appends s as binary.Write lays it out.
+func (s eepromState) appendState(b []byte) []byte {
+	b = appendBool(b, s.SCL)
+	b = appendBool(b, s.SDAIn)
+	b = appendBool(b, s.Out)
+	b = append(b, uint8(s.Phase), uint8(s.Next), s.Shift, s.Bits, s.Addr)
+	b = appendBool(b, s.Ack)
+	b = appendBool(b, s.Written)
+	return binary.LittleEndian.AppendUint32(b, uint32(s.Busy))
+}
@@ -0,0 +1,159 @@
+package mapper
+
+import "testing"
+
+// i2cLines is SCL and SDA as the game sees them: the chip itself, or the
+// mapper register wired to it.
+type i2cLines interface {
+	setLines(scl, sda bool)
+	sda() bool
+}
+
+// i2c drives an eeprom the way a game's bit-banging routine does.
+type i2c struct {
+	e   i2cLines
+	lsb bool // X24C01 bit order
+}
+
+func (m i2c) start() {
+	m.e.setLines(false, true)
+	m.e.setLines(true, true)
+	m.e.setLines(true, false)
+	m.e.setLines(false, false)
+}
+
+func (m i2c) stop() {
+	m.e.setLines(false, false)
+	m.e.setLines(true, false)
+	m.e.setLines(true, true)
+}
+
+// send clocks b out and reports whether the chip acknowledged it.
+func (m i2c) send(b uint8) bool {
+	for i := 0; i < 8; i++ {
+		bit := b&0x80 != 0
+		if m.lsb {
+			bit = b>>i&1 != 0
+		} else {
+			b <<= 1
+		}
+		m.e.setLines(false, bit)
+		m.e.setLines(true, bit)
+		m.e.setLines(false, bit)
+	}
+	m.e.setLines(false, true)
+	m.e.setLines(true, true)
+	ack := !m.e.sda()
+	m.e.setLines(false, true)
+	return ack
+}
+
+// recv clocks a byte in, acknowledging it when ack is set.
+func (m i2c) recv(ack bool) uint8 {
+	var b uint8
+	for i := 0; i < 8; i++ {
+		m.e.setLines(false, true)
+		m.e.setLines(true, true)
+		if m.e.sda() {
+			if m.lsb {
+				b |= 1 << i
+			} else {
+				b |= 0x80 >> i
+			}
+		}
+	}
+	m.e.setLines(false, !ack)
+	m.e.setLines(true, !ack)
+	m.e.setLines(false, !ack)
+	return b
+}
+
+func Test24C02(t *testing.T) {
+	e := new24C02()
+	m := i2c{e: e}
+
+	// Page write of 3 bytes at $FE: the address wraps within the 8-byte page.
+	m.start()
+	if !m.send(0xA0) || !m.send(0xFE) {
+		t.Fatal("device or word address not acknowledged")
+	}
+	for _, b := range []uint8{0x11, 0x22, 0x33} {
+		if !m.send(b) {
+			t.Fatalf("data %02X not acknowledged", b)
+		}
+	}
+	m.stop()
+	if e.data[0xFE] != 0x11 || e.data[0xFF] != 0x22 || e.data[0xF8] != 0x33 {
+		t.Errorf("page write: % X", e.data[0xF8:])
+	}
+
+	// During the write cycle the chip doesn't answer (acknowledge polling).
+	m.start()
+	if m.send(0xA0) {
+		t.Error("busy chip acknowledged its address")
+	}
+	m.stop()
+	e.tick(eepromWriteCycles)
+
+	// Random read: dummy write of the address, repeated start, sequential read.
+	m.start()
+	m.send(0xA0)
+	m.send(0xFE)
+	m.start()
+	if !m.send(0xA1) {
+		t.Fatal("read address not acknowledged")
+	}
+	if a, b := m.recv(true), m.recv(false); a != 0x11 || b != 0x22 {
+		t.Errorf("read %02X %02X, want 11 22", a, b)
+	}
+	m.stop()
+
+	// Another device's address is ignored.
+	m.start()
+	if m.send(0x50) {
+		t.Error("acknowledged a foreign device address")
+	}
+	m.stop()
+}
+
+func TestX24C01(t *testing.T) {
+	e := newX24C01()
+	m := i2c{e: e, lsb: true}
+
+	m.start()
+	if !m.send(0x05) || !m.send(0xA5) || !m.send(0x3C) {
+		t.Fatal("write not acknowledged")
+	}
+	m.stop()
+	if e.data[5] != 0xA5 || e.data[6] != 0x3C {
+		t.Errorf("wrote % X", e.data[4:8])
+	}
+	e.tick(eepromWriteCycles)
+
+	m.start()
+	if !m.send(0x80 | 0x05) {
+		t.Fatal("read not acknowledged")
+	}
+	if a, b := m.recv(true), m.recv(false); a != 0xA5 || b != 0x3C {
+		t.Errorf("read %02X %02X, want A5 3C", a, b)
+	}
+	m.stop()
+}
+
+func TestEEPROMState(t *testing.T) {
+	e := new24C02()
+	m := i2c{e: e}
+	m.start()
+	m.send(0xA0)
+	m.send(0x10) // mid-transfer: the next byte lands at $10
+
+	f := new24C02()
+	f.setState(e.state())
+	i2c{e: f}.send(0x77)
+	if f.data[0x10] != 0x77 {
+		t.Error("restored transfer didn't continue")
+	}
+	if got, want := len(e.state().appendState(nil)), 14; got != want {
+		t.Errorf("appendState is %d bytes, want %d", got, want)
+	}
+}
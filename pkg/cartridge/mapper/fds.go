@@ -0,0 +1,576 @@
+package mapper
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// FDS emulates the Famicom Disk System's RAM adapter (iNES mapper 20):
+// 32KB of PRG RAM at $6000-$DFFF, the 8KB BIOS ROM at $E000-$FFFF, 8KB
+// of CHR RAM, a CPU-clock IRQ timer, the disk drive interface and the
+// wavetable sound channel (fds_audio.go). NESdev "Family Computer Disk
+// System":
+//
+//	$4020/$4021 write  timer reload value, low/high
+//	$4022 write        timer control: bit 0 repeat, bit 1 enable
+//	$4023 write        I/O enable: bit 0 disk registers, bit 1 sound
+//	$4024 write        next byte to write to the disk
+//	$4025 write        bit 0 motor on, 1 transfer reset, 2 read (0 =
+//	                   write), 3 horizontal mirroring, 4 CRC out, 6
+//	                   transfer start, 7 disk IRQ on each byte
+//	$4030 read         bit 0 timer IRQ, 1 byte transferred, 6 end of
+//	                   head; acknowledges both IRQs
+//	$4031 read         last byte read from the disk
+//	$4032 read         bit 0 no disk, 1 not ready, 2 write protected
+//	$4033 read         external connector; bit 7 battery good
+//
+// Each side turns under the head as a raw track (see rawSide): after a
+// spin-up delay the drive moves one byte every fdsByteCycles CPU cycles
+// until the end of the track, when the motor stops and the head returns.
+type FDS struct {
+	cartridge   *CartridgeData
+	sides       [][]uint8 // raw tracks, one per side
+	side        int       // side in the drive, -1 for none
+	nextSide    int       // side going in when insertDelay runs out
+	insertDelay int
+
+	timerReload  uint16
+	timerCounter uint16
+	timerRepeat  bool
+	timerEnabled bool
+	timerIRQ     bool
+	diskEnabled  bool // $4023 bit 0
+	soundEnabled bool // $4023 bit 1
+
+	control     uint8 // $4025
+	writeData   uint8
+	readData    uint8
+	position    int // byte under the head
+	delay       int // CPU cycles until the next byte
+	scanning    bool
+	endOfHead   bool
+	gapEnded    bool
+	transferred bool
+	diskIRQ     bool
+	crc         uint16
+	crcWasOn    bool // $4025 bit 4 as of the previous byte
+
+	audio fdsAudio
+}
+
+// FDSSideSize is one disk side in a .fds image: its blocks back to
+// back, without gaps or CRCs.
+const FDSSideSize = 65500
+
+const (
+	fdsByteCycles = 150   // CPU cycles per byte under the head
+	fdsSpinUp     = 50000 // CPU cycles from motor on to the first byte
+	// fdsInsertDelay is how long InsertDisk leaves the drive empty
+	// (half a second): long enough for a game polling $4032 to see
+	// the old side go before the new one arrives.
+	fdsInsertDelay = 1789773 / 2
+
+	fdsLeadIn   = 28300 / 8 // gap before the first block
+	fdsBlockGap = 976 / 8   // gap after each block
+	// fdsTrackSize is the shortest raw track: room for the lead-in,
+	// a full side and the start marks, CRCs and gaps of ~100 blocks,
+	// so a game saving a longer file doesn't run off the end.
+	fdsTrackSize = 0x14000
+)
+
+// NewFDS returns the RAM adapter with data.PRGROM as its BIOS and sides
+// (FDSSideSize each, as in a .fds image) to put in the drive. Side 0 is
+// inserted.
+func NewFDS(data *CartridgeData, sides [][]uint8) *FDS {
+	m := &FDS{
+		cartridge:    data,
+		nextSide:     -1,
+		diskEnabled:  true,
+		soundEnabled: true,
+		endOfHead:    true,
+		audio:        newFDSAudio(),
+	}
+	for _, s := range sides {
+		m.sides = append(m.sides, rawSide(s))
+	}
+	m.side = -1
+	if len(m.sides) > 0 {
+		m.side = 0
+	}
+	return m
+}
+
+// fdsBlockLength is the length of a block of type kind, or 0 when kind
+// isn't one. A file's data (4) is as long as fileSize, from the file
+// header (3) before it, plus its type byte.
+func fdsBlockLength(kind uint8, fileSize int) int {
+	switch kind {
+	case 1:
+		return 56 // disk info
+	case 2:
+		return 2 // file count
+	case 3:
+		return 16 // file header
+	case 4:
+		return 1 + fileSize
+	}
+	return 0
+}
+
+// fdsFileSize is the data size a file header block gives.
+func fdsFileSize(header []uint8) int { return int(header[13]) | int(header[14])<<8 }
+
+// rawSide lays a .fds side out as the drive sees it: a lead-in gap, then
+// for each block a $80 start mark, the block, its CRC and a gap.
+func rawSide(side []uint8) []uint8 {
+	raw := make([]uint8, fdsLeadIn, fdsTrackSize)
+	fileSize := 0
+	for i := 0; i < len(side); {
+		n := fdsBlockLength(side[i], fileSize)
+		if n == 0 || i+n > len(side) {
+			break
+		}
+		block := side[i : i+n]
+		if block[0] == 3 {
+			fileSize = fdsFileSize(block)
+		}
+		crc := fdsCRC(block)
+		raw = append(raw, 0x80)
+		raw = append(raw, block...)
+		raw = append(raw, uint8(crc), uint8(crc>>8))
+		raw = append(raw, make([]uint8, fdsBlockGap)...)
+		i += n
+	}
+	if len(raw) < fdsTrackSize {
+		raw = raw[:fdsTrackSize]
+	}
+	return raw
+}
+
+// fdsSide turns a raw track back into a .fds side: every block after a
+// start mark, up to the first that isn't a valid one.
+func fdsSide(raw []uint8) []uint8 {
+	side := make([]uint8, FDSSideSize)
+	pos, fileSize := 0, 0
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != 0x80 || i+1 >= len(raw) {
+			continue
+		}
+		n := fdsBlockLength(raw[i+1], fileSize)
+		if n == 0 || i+1+n > len(raw) || pos+n > len(side) {
+			break
+		}
+		block := raw[i+1 : i+1+n]
+		if block[0] == 3 {
+			fileSize = fdsFileSize(block)
+		}
+		pos += copy(side[pos:], block)
+		i += n + 2 // and the CRC
+	}
+	return side
+}
+
+// fdsCRC is the CRC-16/KERMIT the drive appends to each block, over the
+// $80 start mark and the block.
+func fdsCRC(block []uint8) uint16 {
+	var crc uint16
+	crcByte := func(v uint8) {
+		for bit := uint8(1); bit != 0; bit <<= 1 {
+			carry := crc & 1
+			crc >>= 1
+			if carry != 0 {
+				crc ^= 0x8408
+			}
+			if v&bit != 0 {
+				crc ^= 0x8000
+			}
+		}
+	}
+	crcByte(0x80)
+	for _, v := range block {
+		crcByte(v)
+	}
+	crcByte(0)
+	crcByte(0)
+	return crc
+}
+
+// Disk returns the sides as .fds images, with whatever the game wrote.
+func (m *FDS) Disk() [][]uint8 {
+	sides := make([][]uint8, len(m.sides))
+	for i, raw := range m.sides {
+		sides[i] = fdsSide(raw)
+	}
+	return sides
+}
+
+// SetDisk replaces the sides' contents with the .fds images in sides, as
+// Disk returned them; extra images are ignored.
+func (m *FDS) SetDisk(sides [][]uint8) {
+	for i := range m.sides {
+		if i < len(sides) {
+			m.sides[i] = rawSide(sides[i])
+		}
+	}
+}
+
+// DiskSides implements DiskDrive.
+func (m *FDS) DiskSides() int { return len(m.sides) }
+
+// DiskSide implements DiskDrive: the side in the drive or on its way in.
+func (m *FDS) DiskSide() int {
+	if m.insertDelay > 0 {
+		return m.nextSide
+	}
+	return m.side
+}
+
+// InsertDisk implements DiskDrive.
+func (m *FDS) InsertDisk(side int) {
+	m.side = -1
+	m.nextSide, m.insertDelay = -1, 0
+	if side >= 0 && side < len(m.sides) {
+		m.nextSide, m.insertDelay = side, fdsInsertDelay
+	}
+}
+
+func (m *FDS) ReadPRG(addr uint16) uint8 {
+	switch {
+	case addr >= 0xE000:
+		return m.cartridge.PRGROM[int(addr-0xE000)%len(m.cartridge.PRGROM)]
+	case addr >= 0x6000:
+		return m.cartridge.PRGRAM[addr-0x6000]
+	case addr >= 0x4040 && addr < 0x4098 && m.soundEnabled:
+		return m.audio.read(addr)
+	case addr >= 0x4030 && addr <= 0x4033 && m.diskEnabled:
+		return m.readRegister(addr)
+	}
+	// Nothing drives the bus: the high byte of the address, which is
+	// what an absolute-mode read leaves on it.
+	return uint8(addr >> 8)
+}
+
+func (m *FDS) readRegister(addr uint16) uint8 {
+	switch addr {
+	case 0x4030:
+		var v uint8
+		if m.timerIRQ {
+			v |= 0x01
+		}
+		if m.transferred {
+			v |= 0x02
+		}
+		if m.endOfHead {
+			v |= 0x40
+		}
+		m.transferred, m.timerIRQ, m.diskIRQ = false, false, false
+		return v
+	case 0x4031:
+		m.transferred, m.diskIRQ = false, false
+		return m.readData
+	case 0x4032:
+		v := uint8(0x40)
+		if m.side < 0 {
+			v |= 0x07
+		} else if !m.scanning {
+			v |= 0x02
+		}
+		return v
+	}
+	return 0x80 // $4033: battery good
+}
+
+func (m *FDS) WritePRG(addr uint16, value uint8) {
+	switch {
+	case addr >= 0xE000:
+	case addr >= 0x6000:
+		m.cartridge.PRGRAM[addr-0x6000] = value
+	case addr >= 0x4040 && addr <= 0x408A:
+		if m.soundEnabled {
+			m.audio.write(addr, value)
+		}
+	case addr == 0x4023:
+		m.diskEnabled = value&0x01 != 0
+		m.soundEnabled = value&0x02 != 0
+		if !m.diskEnabled {
+			m.timerEnabled = false
+			m.timerIRQ, m.diskIRQ = false, false
+		}
+	case addr >= 0x4020 && addr <= 0x4025 && m.diskEnabled:
+		m.writeRegister(addr, value)
+	}
+}
+
+func (m *FDS) writeRegister(addr uint16, value uint8) {
+	switch addr {
+	case 0x4020:
+		m.timerReload = m.timerReload&0xFF00 | uint16(value)
+	case 0x4021:
+		m.timerReload = m.timerReload&0x00FF | uint16(value)<<8
+	case 0x4022:
+		m.timerRepeat = value&0x01 != 0
+		m.timerEnabled = value&0x02 != 0
+		if m.timerEnabled {
+			m.timerCounter = m.timerReload
+		} else {
+			m.timerIRQ = false
+		}
+	case 0x4024:
+		m.writeData = value
+		m.transferred, m.diskIRQ = false, false
+	case 0x4025:
+		m.control = value
+		m.diskIRQ = false
+	}
+}
+
+// CHR is 8KB of RAM, unbanked.
+func (m *FDS) ReadCHR(addr uint16) uint8 { return m.cartridge.CHRRAM[addr&0x1FFF] }
+
+func (m *FDS) WriteCHR(addr uint16, value uint8) { m.cartridge.CHRRAM[addr&0x1FFF] = value }
+
+func (m *FDS) Step() {}
+
+// TickCPU implements CPUTicker: the timer, the drive and the sound
+// channel all run on the CPU clock.
+func (m *FDS) TickCPU(cycles int) {
+	for i := 0; i < cycles; i++ {
+		if m.insertDelay > 0 {
+			if m.insertDelay--; m.insertDelay == 0 {
+				m.side = m.nextSide
+			}
+		}
+		if m.timerEnabled && m.diskEnabled {
+			if m.timerCounter == 0 {
+				m.timerIRQ = true
+				m.timerCounter = m.timerReload
+				m.timerEnabled = m.timerRepeat
+			} else {
+				m.timerCounter--
+			}
+		}
+		m.clockDrive()
+	}
+	m.audio.tick(cycles)
+}
+
+// clockDrive advances the disk by one CPU cycle, after Mesen's drive
+// model: a byte every fdsByteCycles while the motor runs, read into
+// $4031 once the gap before a block has passed, or written from $4024
+// (then the CRC, while $4025 bit 4 is set).
+func (m *FDS) clockDrive() {
+	if m.side < 0 || m.control&0x01 == 0 {
+		m.endOfHead = true
+		m.scanning = false
+		return
+	}
+	if m.control&0x02 != 0 && !m.scanning {
+		return // transfer reset holds the head at the start
+	}
+	if m.endOfHead {
+		m.delay = fdsSpinUp
+		m.endOfHead = false
+		m.position = 0
+		m.gapEnded = false
+		return
+	}
+	if m.delay > 0 {
+		m.delay--
+		return
+	}
+	m.scanning = true
+	track := m.sides[m.side]
+	ready := m.control&0x40 != 0
+	crcOn := m.control&0x10 != 0
+	needIRQ := m.control&0x80 != 0
+	if m.control&0x04 != 0 { // read
+		data := track[m.position]
+		if !m.crcWasOn {
+			m.updateCRC(data)
+		}
+		if !ready {
+			m.gapEnded = false
+			m.crc = 0
+		} else if data != 0 && !m.gapEnded {
+			m.gapEnded = true // the start mark: no byte for the game yet
+			needIRQ = false
+		}
+		if m.gapEnded {
+			m.transferred = true
+			m.readData = data
+			if needIRQ {
+				m.diskIRQ = true
+			}
+		}
+	} else { // write
+		var data uint8
+		if !crcOn {
+			m.transferred = true
+			data = m.writeData
+			if needIRQ {
+				m.diskIRQ = true
+			}
+		}
+		if !ready {
+			data = 0
+		}
+		if !crcOn {
+			m.updateCRC(data)
+		} else {
+			if !m.crcWasOn {
+				m.updateCRC(0)
+				m.updateCRC(0)
+			}
+			data = uint8(m.crc)
+			m.crc >>= 8
+		}
+		// The write head trails the read head by two bytes.
+		if m.position >= 2 {
+			track[m.position-2] = data
+		}
+		m.gapEnded = false
+	}
+	m.crcWasOn = crcOn
+	m.position++
+	if m.position >= len(track) {
+		m.control &^= 0x01 // the motor stops at the end of the track
+	} else {
+		m.delay = fdsByteCycles
+	}
+}
+
+func (m *FDS) updateCRC(v uint8) {
+	for bit := uint8(1); bit != 0; bit <<= 1 {
+		carry := m.crc & 1
+		m.crc >>= 1
+		if carry != 0 {
+			m.crc ^= 0x8408
+		}
+		if v&bit != 0 {
+			m.crc ^= 0x8000
+		}
+	}
+}
+
+// AudioSample implements AudioSource.
+func (m *FDS) AudioSample() float32 { return m.audio.sample() }
+
+// AudioChip names the sound chip for the mixer's per-chip levels
+// (apu.ChipFDS).
+func (m *FDS) AudioChip() string { return "fds" }
+
+func (m *FDS) IRQLine() bool { return m.timerIRQ || m.diskIRQ }
+func (m *FDS) ClearIRQ()     { m.timerIRQ, m.diskIRQ = false, false }
+
+// IRQCapable marks the RAM adapter as IRQ-asserting (timer and drive).
+func (m *FDS) IRQCapable() {}
+
+// DecodesExpansion marks $4020-$5FFF as decoded: every FDS register
+// lives there.
+func (m *FDS) DecodesExpansion() {}
+
+// Reset implements Resetter: the timer and the drive stop and their IRQs
+// clear. The disk stays in.
+func (m *FDS) Reset() {
+	m.timerEnabled, m.timerIRQ, m.diskIRQ = false, false, false
+	m.control = 0
+	m.transferred = false
+}
+
+// GetMirroringMode implements MirroringSource from $4025 bit 3.
+func (m *FDS) GetMirroringMode() MirroringMode {
+	if m.control&0x08 != 0 {
+		return MirroringHorizontal
+	}
+	return MirroringVertical
+}
+
+// fdsState is the save-state image of the adapter. The raw tracks follow
+// it, since games write to them.
+type fdsState struct {
+	Side, NextSide, InsertDelay int32
+	TimerReload, TimerCounter   uint16
+	TimerRepeat, TimerEnabled   bool
+	TimerIRQ                    bool
+	DiskEnabled, SoundEnabled   bool
+	Control, WriteData          uint8
+	ReadData                    uint8
+	Position, Delay             int32
+	Scanning, EndOfHead         bool
+	GapEnded, Transferred       bool
+	DiskIRQ                     bool
+	CRC                         uint16
+	CRCWasOn                    bool
+	Audio                       fdsAudioState
+}
+
+func (m *FDS) SaveState(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, fdsState{
+		Side: int32(m.side), NextSide: int32(m.nextSide), InsertDelay: int32(m.insertDelay),
+		TimerReload: m.timerReload, TimerCounter: m.timerCounter,
+		TimerRepeat: m.timerRepeat, TimerEnabled: m.timerEnabled, TimerIRQ: m.timerIRQ,
+		DiskEnabled: m.diskEnabled, SoundEnabled: m.soundEnabled,
+		Control: m.control, WriteData: m.writeData, ReadData: m.readData,
+		Position: int32(m.position), Delay: int32(m.delay),
+		Scanning: m.scanning, EndOfHead: m.endOfHead,
+		GapEnded: m.gapEnded, Transferred: m.transferred, DiskIRQ: m.diskIRQ,
+		CRC: m.crc, CRCWasOn: m.crcWasOn,
+		Audio: m.audio.state(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, track := range m.sides {
+		if _, err := w.Write(track); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendState implements StateAppender.
+func (m *FDS) AppendState(b []byte) []byte {
+	for _, v := range []int{m.side, m.nextSide, m.insertDelay} {
+		b = binary.LittleEndian.AppendUint32(b, uint32(int32(v)))
+	}
+	b = appendUint16s(b, []uint16{m.timerReload, m.timerCounter})
+	for _, v := range []bool{m.timerRepeat, m.timerEnabled, m.timerIRQ, m.diskEnabled, m.soundEnabled} {
+		b = appendBool(b, v)
+	}
+	b = append(b, m.control, m.writeData, m.readData)
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(m.position)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(m.delay)))
+	for _, v := range []bool{m.scanning, m.endOfHead, m.gapEnded, m.transferred, m.diskIRQ} {
+		b = appendBool(b, v)
+	}
+	b = binary.LittleEndian.AppendUint16(b, m.crc)
+	b = appendBool(b, m.crcWasOn)
+	b = m.audio.appendState(b)
+	for _, track := range m.sides {
+		b = append(b, track...)
+	}
+	return b
+}
+
+func (m *FDS) LoadState(r io.Reader) error {
+	var s fdsState
+	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
+		return err
+	}
+	for _, track := range m.sides {
+		if _, err := io.ReadFull(r, track); err != nil {
+			return err
+		}
+	}
+	m.side, m.nextSide, m.insertDelay = int(s.Side), int(s.NextSide), int(s.InsertDelay)
+	m.timerReload, m.timerCounter = s.TimerReload, s.TimerCounter
+	m.timerRepeat, m.timerEnabled, m.timerIRQ = s.TimerRepeat, s.TimerEnabled, s.TimerIRQ
+	m.diskEnabled, m.soundEnabled = s.DiskEnabled, s.SoundEnabled
+	m.control, m.writeData, m.readData = s.Control, s.WriteData, s.ReadData
+	m.position, m.delay = int(s.Position), int(s.Delay)
+	m.scanning, m.endOfHead = s.Scanning, s.EndOfHead
+	m.gapEnded, m.transferred, m.diskIRQ = s.GapEnded, s.Transferred, s.DiskIRQ
+	m.crc, m.crcWasOn = s.CRC, s.CRCWasOn
+	m.audio.restore(s.Audio)
+	return nil
+}
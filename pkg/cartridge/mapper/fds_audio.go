@@ -0,0 +1,355 @@
+package mapper
+
+import "encoding/binary"
+
+// FDS expansion audio: one wavetable channel whose pitch a second,
+// table-driven modulator bends (NESdev "FDS audio").
+//
+//	$4040-$407F  64-step wavetable, 6 bits per step; writable only while
+//	             $4089 bit 7 holds the channel
+//	$4080        volume envelope: bit 7 off (bits 0-5 are then the gain),
+//	             bit 6 increase, bits 0-5 speed
+//	$4082/$4083  wave pitch, 12 bits; $4083 bit 7 halts the wave and
+//	             resets its position, bit 6 stops both envelopes
+//	$4084        modulation envelope, as $4080
+//	$4085        modulation counter, 7-bit signed
+//	$4086/$4087  modulation pitch, 12 bits; $4087 bit 7 halts the
+//	             modulator, which is when $4088 may fill its table
+//	$4088        appends a 3-bit step to the 64-entry modulation table
+//	             (each write fills two entries)
+//	$4089        bit 7 wavetable write, bits 0-1 master volume
+//	$408A        envelope speed multiplier
+//	$4090/$4092  read: volume and modulation gain
+//
+// Everything runs on the CPU clock. The hardware's ~2 kHz output
+// low-pass isn't modelled; the APU's own filters follow the mix.
+type fdsAudio struct {
+	wave      [64]uint8
+	modTable  [64]uint8
+	volume    fdsEnvelope
+	mod       fdsEnvelope
+	wavePitch uint16
+	modPitch  uint16
+
+	wavePos    uint8
+	waveAcc    uint32
+	modPos     uint8
+	modAcc     uint32
+	modCounter int8 // -64..63
+	modOutput  int32
+
+	haltWave     bool
+	haltEnvelope bool
+	haltMod      bool
+	waveWrite    bool
+	master       uint8 // $4089 bits 0-1
+	envSpeed     uint8 // $408A
+	output       uint8 // 0..63, the wave step scaled by gain and master
+}
+
+// fdsEnvelope is the volume or modulation gain unit.
+type fdsEnvelope struct {
+	speed    uint8 // bits 0-5 of $4080/$4084
+	increase bool
+	off      bool
+	gain     uint8 // 0..63; the volume unit counts no higher than 32
+	timer    uint32
+}
+
+// fdsMasterVolume scales the output by 2/2, 2/3, 2/4 and 2/5 ($4089),
+// over a denominator of 36 × 32 (the top level times the top gain).
+var fdsMasterVolume = [4]uint32{36, 24, 17, 14}
+
+// fdsModSteps is what each 3-bit modulation table entry adds to the
+// counter; fdsModReset (entry 4) zeroes it instead.
+var fdsModSteps = [8]int8{0, 1, 2, 4, 0, -4, -2, -1}
+
+const fdsModReset = 4
+
+// fdsAudioPeak is AudioSample at the loudest wave step: about 2.4 times
+// a 2A03 pulse at full volume, the two chips' measured ratio.
+const fdsAudioPeak = 0.36
+
+func newFDSAudio() fdsAudio {
+	return fdsAudio{envSpeed: 0xE8}
+}
+
+// read handles $4040-$4097 reads. Bits the chip doesn't drive read as
+// the $40 left on the bus.
+func (a *fdsAudio) read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4080:
+		if a.waveWrite {
+			return a.wave[addr&0x3F] | 0x40
+		}
+		return a.wave[a.wavePos] | 0x40
+	case addr == 0x4090:
+		return a.volume.gain | 0x40
+	case addr == 0x4092:
+		return a.mod.gain | 0x40
+	}
+	return 0x40
+}
+
+// write handles $4040-$408A writes.
+func (a *fdsAudio) write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x4080:
+		if a.waveWrite {
+			a.wave[addr&0x3F] = value & 0x3F
+		}
+	case addr == 0x4080:
+		a.volume.write(value, a.envSpeed)
+	case addr == 0x4082:
+		a.wavePitch = a.wavePitch&0x0F00 | uint16(value)
+	case addr == 0x4083:
+		a.wavePitch = a.wavePitch&0x00FF | uint16(value&0x0F)<<8
+		a.haltWave = value&0x80 != 0
+		a.haltEnvelope = value&0x40 != 0
+		if a.haltWave {
+			a.wavePos, a.waveAcc = 0, 0
+		}
+		if a.haltEnvelope {
+			a.volume.resetTimer(a.envSpeed)
+			a.mod.resetTimer(a.envSpeed)
+		}
+	case addr == 0x4084:
+		a.mod.write(value, a.envSpeed)
+	case addr == 0x4085:
+		a.setModCounter(int(value & 0x7F))
+	case addr == 0x4086:
+		a.modPitch = a.modPitch&0x0F00 | uint16(value)
+	case addr == 0x4087:
+		a.modPitch = a.modPitch&0x00FF | uint16(value&0x0F)<<8
+		a.haltMod = value&0x80 != 0
+		if a.haltMod {
+			a.modAcc = 0
+		}
+	case addr == 0x4088:
+		if a.haltMod {
+			a.modTable[a.modPos] = value & 0x07
+			a.modTable[(a.modPos+1)&0x3F] = value & 0x07
+			a.modPos = (a.modPos + 2) & 0x3F
+		}
+	case addr == 0x4089:
+		a.master = value & 0x03
+		a.waveWrite = value&0x80 != 0
+	case addr == 0x408A:
+		a.envSpeed = value
+	}
+	if addr >= 0x4082 && addr <= 0x4085 {
+		a.updateModOutput() // pitch, gain or counter changed
+	}
+}
+
+func (e *fdsEnvelope) write(value, envSpeed uint8) {
+	e.speed = value & 0x3F
+	e.increase = value&0x40 != 0
+	e.off = value&0x80 != 0
+	e.resetTimer(envSpeed)
+	if e.off {
+		e.gain = e.speed
+	}
+}
+
+func (e *fdsEnvelope) resetTimer(envSpeed uint8) {
+	e.timer = 8 * (uint32(e.speed) + 1) * uint32(envSpeed)
+}
+
+// tick counts the envelope down one CPU cycle and steps the gain when it
+// runs out, reporting whether it did.
+func (e *fdsEnvelope) tick(envSpeed uint8) bool {
+	if e.off || envSpeed == 0 {
+		return false
+	}
+	if e.timer > 0 {
+		e.timer--
+	}
+	if e.timer > 0 {
+		return false
+	}
+	e.resetTimer(envSpeed)
+	if e.increase && e.gain < 32 {
+		e.gain++
+	} else if !e.increase && e.gain > 0 {
+		e.gain--
+	}
+	return true
+}
+
+// setModCounter wraps v into the counter's 7-bit signed range.
+func (a *fdsAudio) setModCounter(v int) {
+	if v >= 64 {
+		v -= 128
+	} else if v < -64 {
+		v += 128
+	}
+	a.modCounter = int8(v)
+}
+
+// updateModOutput recomputes the pitch offset the modulator adds to the
+// wave, with the hardware's rounding (the NESdev wiki's reference code).
+func (a *fdsAudio) updateModOutput() {
+	temp := int32(a.modCounter) * int32(a.mod.gain)
+	remainder := temp & 0x0F
+	temp >>= 4
+	if remainder > 0 && temp&0x80 == 0 {
+		if a.modCounter < 0 {
+			temp--
+		} else {
+			temp += 2
+		}
+	}
+	if temp >= 192 {
+		temp -= 256
+	} else if temp < -64 {
+		temp += 256
+	}
+	temp *= int32(a.wavePitch)
+	remainder = temp & 0x3F
+	temp >>= 6
+	if remainder >= 32 {
+		temp++
+	}
+	a.modOutput = temp
+}
+
+// tick advances the envelopes, the modulator and the wave by cycles CPU
+// cycles.
+func (a *fdsAudio) tick(cycles int) {
+	for i := 0; i < cycles; i++ {
+		a.clock()
+	}
+}
+
+func (a *fdsAudio) clock() {
+	if !a.haltWave && !a.haltEnvelope {
+		a.volume.tick(a.envSpeed)
+		if a.mod.tick(a.envSpeed) {
+			a.updateModOutput()
+		}
+	}
+	if !a.haltMod && a.modPitch > 0 {
+		a.modAcc += uint32(a.modPitch)
+		if a.modAcc > 0xFFFF {
+			a.modAcc -= 0x10000
+			step := a.modTable[a.modPos]
+			a.modPos = (a.modPos + 1) & 0x3F
+			if step == fdsModReset {
+				a.setModCounter(0)
+			} else {
+				a.setModCounter(int(a.modCounter) + int(fdsModSteps[step]))
+			}
+			a.updateModOutput()
+		}
+	}
+	a.updateOutput()
+	if a.haltWave || a.waveWrite {
+		return
+	}
+	if pitch := int32(a.wavePitch) + a.modOutput; pitch > 0 {
+		a.waveAcc += uint32(pitch)
+		if a.waveAcc > 0xFFFF {
+			a.waveAcc -= 0x10000
+			a.wavePos = (a.wavePos + 1) & 0x3F
+		}
+	}
+}
+
+// updateOutput latches the current wave step at the envelope's gain and
+// the master volume. While the wavetable is being written the output
+// holds its last value.
+func (a *fdsAudio) updateOutput() {
+	if a.waveWrite {
+		return
+	}
+	level := uint32(min(a.volume.gain, 32)) * fdsMasterVolume[a.master]
+	a.output = uint8(uint32(a.wave[a.wavePos]) * level / (36 * 32))
+}
+
+func (a *fdsAudio) sample() float32 {
+	return float32(a.output) / 63 * fdsAudioPeak
+}
+
+// fdsAudioState is the save-state image of the channel.
+type fdsAudioState struct {
+	Wave, ModTable      [64]uint8
+	Volume, Mod         fdsEnvelopeState
+	WavePitch, ModPitch uint16
+	WavePos             uint8
+	WaveAcc             uint32
+	ModPos              uint8
+	ModAcc              uint32
+	ModCounter          int8
+	HaltWave, HaltEnv   bool
+	HaltMod, WaveWrite  bool
+	Master, EnvSpeed    uint8
+	Output              uint8
+}
+
+type fdsEnvelopeState struct {
+	Speed         uint8
+	Increase, Off bool
+	Gain          uint8
+	Timer         uint32
+}
+
+func (e *fdsEnvelope) state() fdsEnvelopeState {
+	return fdsEnvelopeState{e.speed, e.increase, e.off, e.gain, e.timer}
+}
+
+func (e *fdsEnvelope) appendState(b []byte) []byte {
+	b = append(b, e.speed)
+	b = appendBool(b, e.increase)
+	b = appendBool(b, e.off)
+	b = append(b, e.gain)
+	return binary.LittleEndian.AppendUint32(b, e.timer)
+}
+
+func (a *fdsAudio) state() fdsAudioState {
+	return fdsAudioState{
+		Wave: a.wave, ModTable: a.modTable,
+		Volume: a.volume.state(), Mod: a.mod.state(),
+		WavePitch: a.wavePitch, ModPitch: a.modPitch,
+		WavePos: a.wavePos, WaveAcc: a.waveAcc,
+		ModPos: a.modPos, ModAcc: a.modAcc, ModCounter: a.modCounter,
+		HaltWave: a.haltWave, HaltEnv: a.haltEnvelope,
+		HaltMod: a.haltMod, WaveWrite: a.waveWrite,
+		Master: a.master, EnvSpeed: a.envSpeed, Output: a.output,
+	}
+}
+
+// appendState appends what state() encodes, without building it.
+func (a *fdsAudio) appendState(b []byte) []byte {
+	b = append(b, a.wave[:]...)
+	b = append(b, a.modTable[:]...)
+	b = a.volume.appendState(b)
+	b = a.mod.appendState(b)
+	b = appendUint16s(b, []uint16{a.wavePitch, a.modPitch})
+	b = append(b, a.wavePos)
+	b = binary.LittleEndian.AppendUint32(b, a.waveAcc)
+	b = append(b, a.modPos)
+	b = binary.LittleEndian.AppendUint32(b, a.modAcc)
+	b = append(b, uint8(a.modCounter))
+	b = appendBool(b, a.haltWave)
+	b = appendBool(b, a.haltEnvelope)
+	b = appendBool(b, a.haltMod)
+	b = appendBool(b, a.waveWrite)
+	return append(b, a.master, a.envSpeed, a.output)
+}
+
+func (a *fdsAudio) restore(s fdsAudioState) {
+	restoreEnvelope := func(e *fdsEnvelope, s fdsEnvelopeState) {
+		*e = fdsEnvelope{speed: s.Speed, increase: s.Increase, off: s.Off, gain: s.Gain, timer: s.Timer}
+	}
+	a.wave, a.modTable = s.Wave, s.ModTable
+	restoreEnvelope(&a.volume, s.Volume)
+	restoreEnvelope(&a.mod, s.Mod)
+	a.wavePitch, a.modPitch = s.WavePitch, s.ModPitch
+	a.wavePos, a.waveAcc = s.WavePos, s.WaveAcc
+	a.modPos, a.modAcc, a.modCounter = s.ModPos, s.ModAcc, s.ModCounter
+	a.haltWave, a.haltEnvelope = s.HaltWave, s.HaltEnv
+	a.haltMod, a.waveWrite = s.HaltMod, s.WaveWrite
+	a.master, a.envSpeed, a.output = s.Master, s.EnvSpeed, s.Output
+	a.updateModOutput()
+}
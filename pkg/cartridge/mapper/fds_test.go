@@ -0,0 +1,161 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testFDSSide builds a .fds side holding one file with the given data.
+func testFDSSide(data []uint8) []uint8 {
+	side := make([]uint8, FDSSideSize)
+	info := append([]uint8{1}, "*NINTENDO-HVC*"...)
+	n := copy(side, info)
+	n += 56 - len(info)
+	n += copy(side[n:], []uint8{2, 1})
+	header := make([]uint8, 16)
+	header[0] = 3
+	copy(header[3:], "TESTFILE")
+	header[13], header[14] = uint8(len(data)), uint8(len(data)>>8)
+	n += copy(side[n:], header)
+	side[n] = 4
+	copy(side[n+1:], data)
+	return side
+}
+
+func newTestFDS(sides ...[]uint8) *FDS {
+	return NewFDS(&CartridgeData{
+		PRGROM: make([]uint8, 8192),
+		PRGRAM: make([]uint8, 32768),
+		CHRRAM: make([]uint8, 8192),
+	}, sides)
+}
+
+func TestFDSDiskRoundTrip(t *testing.T) {
+	side := testFDSSide([]uint8{0xDE, 0xAD, 0x80, 0x00, 0xBE})
+	raw := rawSide(side)
+	if len(raw) != fdsTrackSize || raw[fdsLeadIn] != 0x80 || raw[fdsLeadIn+1] != 1 {
+		t.Fatalf("raw track starts % X at the lead-in, len %d", raw[fdsLeadIn:fdsLeadIn+2], len(raw))
+	}
+	if got := fdsSide(raw); !bytes.Equal(got, side) {
+		t.Error("a side doesn't survive the trip through a raw track")
+	}
+}
+
+func TestFDSDriveReadsDisk(t *testing.T) {
+	m := newTestFDS(testFDSSide([]uint8{0x42}))
+	m.WritePRG(0x4025, 0xC5) // motor on, read, transfer start, IRQ per byte
+
+	var got []uint8
+	for cycles := 0; len(got) < 15 && cycles < fdsSpinUp+fdsLeadIn*fdsByteCycles*2; cycles++ {
+		m.TickCPU(1)
+		if m.IRQLine() {
+			if m.ReadPRG(0x4032)&0x02 != 0 {
+				t.Fatal("$4032 says not ready while the disk is turning")
+			}
+			got = append(got, m.ReadPRG(0x4031))
+			if m.IRQLine() {
+				t.Fatal("$4031 should acknowledge the disk IRQ")
+			}
+		}
+	}
+	if want := append([]uint8{1}, "*NINTENDO-HVC*"...); !bytes.Equal(got, want) {
+		t.Errorf("first bytes read = %q, want the disk info block %q", got, want)
+	}
+}
+
+func TestFDSTimerIRQ(t *testing.T) {
+	m := newTestFDS(testFDSSide(nil))
+	m.WritePRG(0x4020, 100)
+	m.WritePRG(0x4021, 0)
+	m.WritePRG(0x4022, 0x02) // enabled, one-shot
+	m.TickCPU(100)
+	if m.IRQLine() {
+		t.Fatal("timer IRQ before the counter ran out")
+	}
+	m.TickCPU(1)
+	if !m.IRQLine() {
+		t.Fatal("no timer IRQ after reload+1 cycles")
+	}
+	if v := m.ReadPRG(0x4030); v&0x01 == 0 {
+		t.Errorf("$4030 = %02X, want the timer flag", v)
+	}
+	if m.IRQLine() {
+		t.Error("$4030 should acknowledge the timer IRQ")
+	}
+	m.TickCPU(500)
+	if m.IRQLine() {
+		t.Error("a one-shot timer fired twice")
+	}
+}
+
+func TestFDSInsertDisk(t *testing.T) {
+	m := newTestFDS(testFDSSide(nil), testFDSSide(nil))
+	if m.DiskSides() != 2 || m.DiskSide() != 0 || m.ReadPRG(0x4032)&0x01 != 0 {
+		t.Fatal("side A should be in the drive at power-on")
+	}
+	m.InsertDisk(1)
+	if m.ReadPRG(0x4032)&0x07 != 0x07 {
+		t.Error("the drive should read empty while the disk is turned over")
+	}
+	if m.DiskSide() != 1 {
+		t.Errorf("DiskSide = %d while side B goes in, want 1", m.DiskSide())
+	}
+	m.TickCPU(fdsInsertDelay)
+	if m.side != 1 || m.ReadPRG(0x4032)&0x01 != 0 {
+		t.Error("side B should be in after the insert delay")
+	}
+	m.InsertDisk(-1)
+	if m.DiskSide() != -1 || m.ReadPRG(0x4032)&0x01 == 0 {
+		t.Error("InsertDisk(-1) should leave the drive empty")
+	}
+}
+
+func TestFDSAudio(t *testing.T) {
+	m := newTestFDS(testFDSSide(nil))
+	m.WritePRG(0x4089, 0x80) // wavetable writable
+	for i := uint16(0); i < 64; i++ {
+		m.WritePRG(0x4040+i, 0x3F)
+	}
+	m.WritePRG(0x4089, 0x00)    // master volume 2/2
+	m.WritePRG(0x4080, 0x80|32) // envelope off, gain 32
+	m.WritePRG(0x4087, 0x80)    // modulator off
+	m.WritePRG(0x4082, 0x00)    // pitch $100
+	m.WritePRG(0x4083, 0x01)
+	m.TickCPU(1)
+	if got := m.AudioSample(); got != fdsAudioPeak {
+		t.Errorf("AudioSample at full gain = %v, want %v", got, fdsAudioPeak)
+	}
+	if v := m.ReadPRG(0x4090); v&0x3F != 32 {
+		t.Errorf("$4090 = %02X, want gain 32", v)
+	}
+	m.WritePRG(0x4023, 0x01) // sound registers off
+	m.WritePRG(0x4080, 0x80)
+	if v := m.ReadPRG(0x4090); v != 0x40 {
+		t.Errorf("$4090 with sound disabled = %02X, want open bus", v)
+	}
+}
+
+func TestFDSState(t *testing.T) {
+	m := newTestFDS(testFDSSide([]uint8{1, 2, 3}))
+	m.WritePRG(0x4020, 0x34)
+	m.WritePRG(0x4022, 0x03)
+	m.WritePRG(0x4080, 0x85)
+	m.WritePRG(0x4025, 0x45)
+	m.TickCPU(fdsSpinUp + 1000)
+
+	var buf bytes.Buffer
+	if err := m.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.AppendState([]byte{0xEE}); !bytes.Equal(got[1:], buf.Bytes()) {
+		t.Fatal("AppendState differs from SaveState")
+	}
+	saved := bytes.Clone(buf.Bytes())
+	other := newTestFDS(testFDSSide(nil))
+	if err := other.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := other.AppendState(nil); !bytes.Equal(got, saved) {
+		t.Error("LoadState didn't restore everything SaveState wrote")
+	}
+}
@@ -0,0 +1,163 @@
+package mapper
+
+import "encoding/binary"
+
+// sstFlash is the SST39SF010/020/040 flash chip that self-flashing
+// homebrew boards (UNROM 512) use as PRG ROM. Reads come straight from
+// data; writes are commands, unlocked by $AA to $5555 and $55 to $2AAA
+// (the chip decodes the low 15 address bits), then one of:
+//
+//	$A0 to $5555, then the byte to program (bits can only go 1 → 0)
+//	$80 to $5555, unlock again, then $10 to $5555 to erase the chip
+//	                             or $30 to a 4 KiB sector to erase it
+//	$90 to $5555 for the ID mode ($F0 anywhere leaves it)
+//
+// A write that breaks a sequence starts it over.
+//
+// Programming and erasing take the chip's time (flashProgramCycles and
+// friends, counted down by tick). The array already holds the result, but
+// until the time is up reads return the status byte games poll — bit 7
+// the complement of the programmed bit 7 (0 while erasing), bit 6
+// toggling on every read — and writes are ignored.
+type sstFlash struct {
+	data []uint8
+
+	cycle   uint8 // unlock writes seen: 0, 1 ($AA) or 2 ($55)
+	erase   bool  // $80 seen; the next command erases
+	program bool  // the next write is the byte to program
+	id      bool  // reads give the manufacturer and device IDs
+
+	busy   int32 // CPU cycles left of a program or erase
+	status uint8 // bit 7 to report while busy, and the bit 6 toggle
+}
+
+// flashSector is the size the sector-erase command clears.
+const flashSector = 0x1000
+
+// The SST39SF0x0's typical program and erase times in NTSC CPU cycles
+// (14 µs, 18 ms and 70 ms).
+const (
+	flashProgramCycles     = 25
+	flashSectorEraseCycles = 32_216
+	flashChipEraseCycles   = 125_284
+)
+
+// busyOrID reports whether reads come from the chip's status or ID
+// rather than the array, so the mapper must route them through read.
+func (f *sstFlash) busyOrID() bool { return f.busy > 0 || f.id }
+
+// tick counts down a program or erase in progress.
+func (f *sstFlash) tick(cycles int) {
+	if f.busy > 0 {
+		f.busy -= int32(cycles)
+	}
+}
+
+// read returns the byte at offset off in the chip.
+func (f *sstFlash) read(off int) uint8 {
+	if f.busy > 0 {
+		f.status ^= 0x40
+		return f.status
+	}
+	if f.id {
+		if off&1 == 0 {
+			return 0xBF // SST
+		}
+		switch {
+		case len(f.data) <= 128<<10:
+			return 0xB5 // SST39SF010
+		case len(f.data) <= 256<<10:
+			return 0xB6 // SST39SF020
+		}
+		return 0xB7 // SST39SF040
+	}
+	if off < len(f.data) {
+		return f.data[off]
+	}
+	return 0
+}
+
+// write feeds the command state machine a write of v at offset off.
+func (f *sstFlash) write(off int, v uint8) {
+	a := off & 0x7FFF
+	switch {
+	case f.busy > 0:
+	case f.program:
+		if off < len(f.data) {
+			f.data[off] &= v
+		}
+		f.program = false
+		f.start(flashProgramCycles, ^v&0x80)
+	case v == 0xF0:
+		f.cycle, f.erase, f.id = 0, false, false
+	case f.cycle == 0 && a == 0x5555 && v == 0xAA:
+		f.cycle = 1
+	case f.cycle == 1 && a == 0x2AAA && v == 0x55:
+		f.cycle = 2
+	case f.cycle == 2 && f.erase && v == 0x30:
+		f.fill(off&^(flashSector-1), flashSector)
+		f.cycle, f.erase = 0, false
+		f.start(flashSectorEraseCycles, 0)
+	case f.cycle == 2 && a == 0x5555:
+		f.cycle = 0
+		erase := f.erase
+		f.erase = false
+		switch v {
+		case 0xA0:
+			f.program = !erase
+		case 0x80:
+			f.erase = true
+		case 0x10:
+			if erase {
+				f.fill(0, len(f.data))
+				f.start(flashChipEraseCycles, 0)
+			}
+		case 0x90:
+			f.id = true
+		}
+	default:
+		f.cycle, f.erase = 0, false
+	}
+}
+
+// start makes the chip busy for cycles, reporting bit7 in its status.
+func (f *sstFlash) start(cycles int32, bit7 uint8) {
+	f.busy = cycles
+	f.status = bit7 | f.status&0x40
+}
+
+// fill erases n bytes from off to $FF.
+func (f *sstFlash) fill(off, n int) {
+	for i := off; i < off+n && i < len(f.data); i++ {
+		f.data[i] = 0xFF
+	}
+}
+
+// flashState is the command progress a save state keeps.
+type flashState struct {
+	Cycle   uint8
+	Erase   bool
+	Program bool
+	ID      bool
+	Busy    int32
+	Status  uint8
+}
+
+func (f *sstFlash) state() flashState {
+	return flashState{f.cycle, f.erase, f.program, f.id, f.busy, f.status}
+}
+
+func (f *sstFlash) setState(s flashState) {
+	f.cycle, f.erase, f.program, f.id = s.Cycle, s.Erase, s.Program, s.ID
+	f.busy, f.status = s.Busy, s.Status
+}
+
+// appendState appends state() as binary.Write lays it out.
+func (s flashState) appendState(b []byte) []byte {
+	b = append(b, s.Cycle)
+	b = appendBool(b, s.Erase)
+	b = appendBool(b, s.Program)
+	b = appendBool(b, s.ID)
+	b = binary.LittleEndian.AppendUint32(b, uint32(s.Busy))
+	return append(b, s.Status)
+}
@@ -1,10 +1,15 @@
 package mapper
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 )
 
+// ErrUnsupported is NewMapper's error for a mapper number it doesn't know.
+var ErrUnsupported = errors.New("unsupported mapper")
+
 // Mapper interface for different mappers
 type Mapper interface {
 	ReadPRG(addr uint16) uint8
@@ -12,7 +17,8 @@ type Mapper interface {
 	ReadCHR(addr uint16) uint8
 	WriteCHR(addr uint16, value uint8)
 	Step()
-	IsIRQPending() bool
+	// IRQLine reports whether the mapper is holding the CPU /IRQ line low.
+	IRQLine() bool
 	ClearIRQ()
 }
 
@@ -25,12 +31,57 @@ type Stateful interface {
 	LoadState(r io.Reader) error
 }
 
-// A12Notifier is the optional interface for mappers that need PPU A12-line
-// edge notifications (used by MMC3 for its scanline IRQ counter). Mappers
-// without IRQ scanline timing don't implement this and the cartridge layer
-// simply skips the call.
-type A12Notifier interface {
-	NotifyA12(chrAddr uint16, renderingEnabled bool)
+// StateAppender is the optional allocation-free view of a Stateful
+// mapper's state: AppendState appends exactly the bytes SaveState writes
+// (binary.Write's little-endian layout, bools as one byte) to b. The NES
+// state hash uses it every frame, where a binary.Write per call would
+// allocate.
+type StateAppender interface {
+	AppendState(b []byte) []byte
+}
+
+// appendBool appends v the way binary.Write encodes a bool.
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+// appendUint16s appends vs in little-endian order.
+func appendUint16s(b []byte, vs []uint16) []byte {
+	for _, v := range vs {
+		b = binary.LittleEndian.AppendUint16(b, v)
+	}
+	return b
+}
+
+// Resetter is the optional interface for mappers with state that a
+// console power cycle must clear even though the cartridge stays loaded
+// (IRQ counters and pending flags; bank registers power up undefined and
+// are left alone). Cartridge.Reset calls it.
+type Resetter interface {
+	Reset()
+}
+
+// PPUAddressWatcher is the optional interface for mappers that watch the
+// PPU address bus. PPUAddressBus receives every address the CPU puts
+// there through $2006/$2007 (the v register after the second $2006 write
+// and after each $2007 increment, and the address of each $2007 pattern
+// read); MMC3 clocks its scanline counter on the A12 rising edges among
+// them. Keeping this out of ReadCHR leaves reads free of side effects.
+type PPUAddressWatcher interface {
+	PPUAddressBus(addr uint16)
+}
+
+// PatternFetchWatcher is a marker for PPUAddressWatchers that also need
+// the rendering pipeline's pattern fetches (MMC2/MMC4 flip their CHR
+// latches when tiles $FD/$FE are fetched, so the switch lands on the next
+// fetch exactly as on hardware). MMC3 doesn't claim it: the PPU follows
+// A12 through its own fetches, filters the rises as the MMC3 does and
+// calls Step for each one it counts.
+type PatternFetchWatcher interface {
+	WatchesPatternFetches()
 }
 
 // CPUTicker is the optional interface for mappers whose internal timing
@@ -46,7 +97,8 @@ type CPUTicker interface {
 // sound (FME-7's three square channels, VRC6/VRC7 etc. once those
 // land). The APU's mixer pulls AudioSample() per output sample and
 // mixes the value into the 2A03 channel sum. Range is the same 0..1
-// the APU uses internally.
+// the APU uses internally. A source may also say which chip it is with
+// AudioChip() string (apu.AudioChipNamer), for the mixer's per-chip levels.
 type AudioSource interface {
 	AudioSample() float32
 }
@@ -85,54 +137,108 @@ type ScanlineNotifier interface {
 	NotifyScanline(scanline int, renderingEnabled bool)
 }
 
+// MirroringMode is a nametable arrangement. The values are the PPU's:
+// the cartridge, the PPU and every MirroringSource share this type.
+type MirroringMode uint8
+
+const (
+	MirroringHorizontal        MirroringMode = iota // $2000 = $2400, $2800 = $2C00
+	MirroringVertical                               // $2000 = $2800, $2400 = $2C00
+	MirroringSingleScreenLower                      // all four on CIRAM page 0
+	MirroringSingleScreenUpper                      // all four on CIRAM page 1
+	MirroringFourScreen                             // four distinct nametables (cartridge VRAM)
+)
+
 // MirroringSource is the optional interface for mappers that override the
 // iNES-header mirroring mode dynamically (MMC1, MMC3 — anything with a
 // mirroring register). Falls back to the header value when the mapper
 // doesn't implement it.
 type MirroringSource interface {
-	GetMirroringMode() uint8
+	GetMirroringMode() MirroringMode
+}
+
+// PRGRAMGate is the optional interface for mappers with a PRG RAM
+// enable bit (MMC1's $E000 bit 4, MMC3's $A001 bit 7). While it reports
+// false the RAM chip is deselected and $6000-$7FFF reads see CPU open bus
+// instead of whatever ReadPRG would return.
+type PRGRAMGate interface {
+	PRGRAMEnabled() bool
+}
+
+// PRGBankMapper is the optional interface for mappers that keep their
+// $8000-$FFFF mapping as four precomputed 8KB slices of PRG ROM (one per
+// $2000 window). The returned table is live — the mapper rewrites it in
+// place whenever a bank register changes — so the memory bus can fetch
+// PRG bytes with a single slice index and skip the ReadPRG call. A nil
+// window means "ask ReadPRG". Only mappers whose PRG reads have no side
+// effects may implement this.
+type PRGBankMapper interface {
+	PRGBanks() *[4][]uint8
 }
 
 // IRQCapable is a marker interface implemented only by mappers that can
 // assert the CPU IRQ line (MMC3, MMC5, FME-7). Every mapper satisfies the
-// base Mapper.IsIRQPending(), so that method can't discriminate; this marker
-// lets the cartridge layer learn at load time whether IsIRQPending is worth
+// base Mapper.IRQLine(), so that method can't discriminate; this marker
+// lets the cartridge layer learn at load time whether IRQLine is worth
 // polling. nes.Step skips its per-instruction mapper-IRQ poll for carts whose
 // mapper doesn't implement this.
 type IRQCapable interface {
 	IRQCapable()
 }
 
+// DiskDrive is the optional interface for mappers with a disk drive (the
+// FDS RAM adapter): how many disk sides there are and which is in it.
+type DiskDrive interface {
+	DiskSides() int
+	// DiskSide is the side in the drive or on its way in, -1 when the
+	// drive is empty.
+	DiskSide() int
+	// InsertDisk ejects the disk and, for a side >= 0, puts that side in
+	// once the game has had time to see the drive empty.
+	InsertDisk(side int)
+}
+
+// OUTLatchWatcher is the optional interface for boards wired to the
+// $4016 OUT latch rather than (or as well as) the cartridge bus: the VS.
+// System's mapper 99 switches banks on OUT2 (bit 2).
+type OUTLatchWatcher interface {
+	WriteOUT(value uint8)
+}
+
+// NonVolatileMemory is the optional interface for mappers with save
+// memory on a chip of their own — flash the game rewrites (UNROM 512), a
+// serial EEPROM (Bandai FCG) — instead of battery-backed PRG RAM.
+// NonVolatile returns the chip's contents, live, and what kind of chip it
+// is ("flash", "EEPROM"); nil data when this board has none. The
+// cartridge saves and restores it through Cartridge.NonVolatile.
+type NonVolatileMemory interface {
+	NonVolatile() (data []uint8, kind string)
+}
+
 // CartridgeData contains cartridge data for mappers
 type CartridgeData struct {
 	PRGROM []uint8
 	CHRROM []uint8
 	PRGRAM []uint8
 	CHRRAM []uint8
+
+	// Submapper is the NES 2.0 submapper number (0 for iNES 1.0 images
+	// not found in the game database). Mappers with board variants pick
+	// their behaviour from it at construction.
+	Submapper uint8
+
+	// Mirroring and Battery are the header's nametable arrangement and
+	// battery bit, for boards whose wiring they describe (UNROM 512's
+	// mirroring mode and flash).
+	Mirroring MirroringMode
+	Battery   bool
 }
 
-// NewMapper creates a new mapper instance
+// NewMapper builds the mapper registered for mapperNumber (see Register).
 func NewMapper(mapperNumber uint8, data *CartridgeData) (Mapper, error) {
-	switch mapperNumber {
-	case 0:
-		return NewMapper0(data), nil
-	case 1:
-		return NewMapper1(data), nil
-	case 2:
-		return NewMapper2(data), nil
-	case 3:
-		return NewMapper3(data), nil
-	case 4:
-		return NewMapper4(data), nil
-	case 5:
-		return NewMapper5(data), nil
-	case 10:
-		return NewMapper10(data), nil
-	case 69:
-		return NewMapper69(data), nil
-	case 70:
-		return NewMapper70(data), nil
-	default:
-		return nil, fmt.Errorf("unsupported mapper: %d", mapperNumber)
+	b, ok := Lookup(mapperNumber)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d (supported: %s)", ErrUnsupported, mapperNumber, SupportedList())
 	}
-}
\ No newline at end of file
+	return b.New(mapperNumber, data), nil
+}
@@ -3,25 +3,32 @@ package mapper
 // Mapper0 (NROM) - No mapping
 type Mapper0 struct {
 	cartridge *CartridgeData
+	prg       prgBankTable
+}
+
+func init() {
+	Register(Board{Name: "NROM", New: func(_ uint8, d *CartridgeData) Mapper {
+		if len(d.PRGROM) == nrom368Size {
+			return NewNROM368(d)
+		}
+		return NewMapper0(d)
+	}}, 0)
 }
 
 // NewMapper0 creates a new Mapper0 instance
 func NewMapper0(data *CartridgeData) *Mapper0 {
-	return &Mapper0{cartridge: data}
+	m := &Mapper0{cartridge: data}
+	m.prg.setFixed(data.PRGROM)
+	return m
 }
 
+// PRGBanks implements PRGBankMapper.
+func (m *Mapper0) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
 // ReadPRG reads from PRG ROM
 func (m *Mapper0) ReadPRG(addr uint16) uint8 {
 	if addr >= 0x8000 {
-		addr -= 0x8000
-		if len(m.cartridge.PRGROM) == 16384 {
-			// 16KB ROM, mirror at 0xC000
-			addr = addr % 16384
-		}
-		if int(addr) < len(m.cartridge.PRGROM) {
-			return m.cartridge.PRGROM[addr]
-		}
-		return 0
+		return m.prg.read(addr) // 16KB ROMs mirror at $C000
 	}
 	return readPRGRAM(m.cartridge, addr)
 }
@@ -48,8 +55,8 @@ func (m *Mapper0) Step() {
 	// No special timing for NROM
 }
 
-// IsIRQPending returns false for Mapper0 (no IRQ support)
-func (m *Mapper0) IsIRQPending() bool {
+// IRQLine returns false for Mapper0 (no IRQ support)
+func (m *Mapper0) IRQLine() bool {
 	return false
 }
 
@@ -59,4 +66,60 @@ func (m *Mapper0) ClearIRQ() {
 }
 
 // Mapper0 (NROM) has no internal state, so it intentionally does not
-// implement mapper.Stateful. Save-state code skips the mapper section.
\ No newline at end of file
+// implement mapper.Stateful. Save-state code skips the mapper section.
+
+// nrom368Size is the PRG ROM size that marks an NROM-368 image.
+const nrom368Size = 0xC000
+
+// NROM368 is the homebrew NROM-368 board: mapper 0 with 46 KiB of PRG ROM
+// decoded from $4020 up, the extra 14 KiB filling $4020-$7FFF where NROM
+// has open bus and PRG RAM. Its images are 48 KiB, the CPU address minus
+// $4000 being the offset, so the first $20 bytes (under the APU and I/O
+// registers) are never seen. The board has no PRG RAM.
+type NROM368 struct {
+	cartridge *CartridgeData
+	prg       prgBankTable
+}
+
+// NewNROM368 creates the NROM-368 mapper for a 48 KiB PRG ROM.
+func NewNROM368(data *CartridgeData) *NROM368 {
+	m := &NROM368{cartridge: data}
+	for w := range m.prg {
+		m.prg.set(w, data.PRGROM, w+2) // $8000 is offset $4000
+	}
+	return m
+}
+
+// PRGBanks implements PRGBankMapper.
+func (m *NROM368) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+// DecodesExpansion claims $4020-$5FFF, which holds ROM on this board.
+func (m *NROM368) DecodesExpansion() {}
+
+// ReadPRG reads the ROM at $4020-$FFFF.
+func (m *NROM368) ReadPRG(addr uint16) uint8 {
+	if addr >= 0x8000 {
+		return m.prg.read(addr)
+	}
+	if i := int(addr) - 0x4000; addr >= 0x4020 && i < len(m.cartridge.PRGROM) {
+		return m.cartridge.PRGROM[i]
+	}
+	return 0
+}
+
+// WritePRG ignores writes: there is nothing writable on the board.
+func (m *NROM368) WritePRG(addr uint16, value uint8) {}
+
+// ReadCHR reads from CHR ROM/RAM.
+func (m *NROM368) ReadCHR(addr uint16) uint8 {
+	return readCHRROMOrRAM(m.cartridge, addr)
+}
+
+// WriteCHR writes to CHR RAM.
+func (m *NROM368) WriteCHR(addr uint16, value uint8) {
+	writeCHRRAM(m.cartridge, addr, value)
+}
+
+func (m *NROM368) Step()         {}
+func (m *NROM368) IRQLine() bool { return false }
+func (m *NROM368) ClearIRQ()     {}
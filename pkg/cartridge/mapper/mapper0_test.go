@@ -120,7 +120,7 @@ func TestMapper0_NROM(t *testing.T) {
 		mapper := NewMapper0(data)
 		
 		// IRQ should always be false
-		if mapper.IsIRQPending() {
+		if mapper.IRQLine() {
 			t.Errorf("NROM should not support IRQ")
 		}
 		
@@ -130,4 +130,35 @@ func TestMapper0_NROM(t *testing.T) {
 		// Step should do nothing (no panic)
 		mapper.Step()
 	})
-}
\ No newline at end of file
+}
+// TestNROM368 checks that a 48 KiB mapper 0 image maps as NROM-368: the
+// CPU address minus $4000 is the ROM offset from $4020 up.
+func TestNROM368(t *testing.T) {
+	prg := make([]uint8, nrom368Size)
+	for i := range prg {
+		prg[i] = uint8(i >> 8)
+	}
+	m, err := NewMapper(0, &CartridgeData{PRGROM: prg, CHRROM: testCHRROM8KB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nrom, ok := m.(*NROM368)
+	if !ok {
+		t.Fatalf("48 KiB NROM built %T, want *NROM368", m)
+	}
+	if _, ok := m.(ExpansionDecoder); !ok {
+		t.Error("NROM-368 must decode $4020-$5FFF")
+	}
+	for _, addr := range []uint16{0x4020, 0x5FFF, 0x6000, 0x7FFF, 0x8000, 0xFFFF} {
+		if got, want := nrom.ReadPRG(addr), uint8((addr-0x4000)>>8); got != want {
+			t.Errorf("$%04X = %02X, want %02X", addr, got, want)
+		}
+	}
+	nrom.WritePRG(0x6000, 0xEE)
+	if nrom.ReadPRG(0x6000) != 0x20 {
+		t.Error("$6000 is ROM on NROM-368, not RAM")
+	}
+	if _, ok := any(NewMapper0(&CartridgeData{PRGROM: testPRGROM32KB})).(ExpansionDecoder); ok {
+		t.Error("plain NROM must leave $4020-$5FFF open")
+	}
+}
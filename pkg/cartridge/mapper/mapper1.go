@@ -23,64 +23,31 @@ type Mapper1 struct {
 	prgMode   uint8  // PRG ROM bank mode (0: 32KB, 1: 16KB)
 	chrMode   uint8  // CHR ROM bank mode (0: 8KB, 1: 4KB)
 	mirroring uint8  // Nametable mirroring mode
+
+	prg prgBankTable // $8000-$FFFF windows, rebuilt by updatePRGBanks
+}
+
+func init() {
+	Register(Board{Name: "MMC1 (SxROM)", Battery: true, New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper1(d) }}, 1)
 }
 
 // NewMapper1 creates a new Mapper1 instance
 func NewMapper1(data *CartridgeData) *Mapper1 {
-	return &Mapper1{
+	m := &Mapper1{
 		cartridge: data,
 		control:   0x0C, // Default: PRG mode 3, CHR mode 0
 		prgMode:   3,    // 16KB mode, high bank fixed
 		chrMode:   0,    // 8KB mode
 		mirroring: 0,    // One-screen lower
 	}
+	m.updatePRGBanks()
+	return m
 }
 
 // ReadPRG reads from PRG ROM/RAM
 func (m *Mapper1) ReadPRG(addr uint16) uint8 {
 	if addr >= 0x8000 {
-		// PRG ROM area
-		addr -= 0x8000
-		prgSize := len(m.cartridge.PRGROM)
-		
-		switch m.prgMode {
-		case 0, 1: // 32KB mode
-			bank := m.prgBank >> 1 // Use bits 4-1 for 32KB banks
-			offset := uint32(bank) * 0x8000 + uint32(addr)
-			if int(offset) < prgSize {
-				return m.cartridge.PRGROM[offset]
-			}
-		case 2: // 16KB mode, first bank fixed at $8000
-			if addr < 0x4000 {
-				// Fixed first bank
-				if int(addr) < prgSize {
-					return m.cartridge.PRGROM[addr]
-				}
-			} else {
-				// Switchable bank at $C000
-				bank := m.prgBank & 0x0F
-				offset := uint32(bank) * 0x4000 + uint32(addr-0x4000)
-				if int(offset) < prgSize {
-					return m.cartridge.PRGROM[offset]
-				}
-			}
-		case 3: // 16KB mode, last bank fixed at $C000
-			if addr < 0x4000 {
-				// Switchable bank at $8000
-				bank := m.prgBank & 0x0F
-				offset := uint32(bank) * 0x4000 + uint32(addr)
-				if int(offset) < prgSize {
-					return m.cartridge.PRGROM[offset]
-				}
-			} else {
-				// Fixed last bank
-				lastBank := (prgSize / 0x4000) - 1
-				offset := uint32(lastBank) * 0x4000 + uint32(addr-0x4000)
-				if int(offset) < prgSize {
-					return m.cartridge.PRGROM[offset]
-				}
-			}
-		}
+		return m.prg.read(addr)
 	} else if addr >= 0x6000 && (m.prgBank&0x10) == 0 {
 		// PRG RAM area - enabled when bit 4 of the PRG bank register is 0.
 		return readPRGRAM(m.cartridge, addr)
@@ -88,6 +55,32 @@ func (m *Mapper1) ReadPRG(addr uint16) uint8 {
 	return 0
 }
 
+// PRGBanks implements PRGBankMapper.
+func (m *Mapper1) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+// updatePRGBanks rebuilds the $8000-$FFFF table from the PRG mode (control
+// bits 2-3) and the PRG bank register. Bit 4 of the bank register is the
+// RAM disable, not a bank bit, so only bits 0-3 select ROM.
+func (m *Mapper1) updatePRGBanks() {
+	rom := m.cartridge.PRGROM
+	bank := int(m.prgBank & 0x0F)
+	switch m.prgMode {
+	case 0, 1: // 32KB mode — low bit ignored
+		m.prg.set16K(0, rom, bank&^1)
+		m.prg.set16K(2, rom, bank|1)
+	case 2: // 16KB mode, first bank fixed at $8000
+		m.prg.set16K(0, rom, 0)
+		m.prg.set16K(2, rom, bank)
+	case 3: // 16KB mode, last bank fixed at $C000
+		m.prg.set16K(0, rom, bank)
+		m.prg.set16K(2, rom, len(rom)/0x4000-1)
+	}
+}
+
+// PRGRAMEnabled implements PRGRAMGate: bit 4 of the PRG bank register
+// disables the RAM.
+func (m *Mapper1) PRGRAMEnabled() bool { return m.prgBank&0x10 == 0 }
+
 // WritePRG handles PRG writes (mapper control and PRG RAM)
 func (m *Mapper1) WritePRG(addr uint16, value uint8) {
 	if addr >= 0x8000 {
@@ -98,6 +91,7 @@ func (m *Mapper1) WritePRG(addr uint16, value uint8) {
 			m.shiftCount = 0
 			m.control |= 0x0C // Set PRG mode to 3
 			m.prgMode = 3
+			m.updatePRGBanks()
 		} else {
 			// Write bit to shift register
 			m.shiftRegister = (m.shiftRegister >> 1) | ((value & 1) << 4)
@@ -124,6 +118,7 @@ func (m *Mapper1) writeRegister(addr uint16, value uint8) {
 		m.mirroring = value & 3
 		m.prgMode = (value >> 2) & 3
 		m.chrMode = (value >> 4) & 1
+		m.updatePRGBanks()
 		
 	case addr <= 0xBFFF: // CHR bank 0
 		m.chrBank0 = value
@@ -133,44 +128,42 @@ func (m *Mapper1) writeRegister(addr uint16, value uint8) {
 		
 	case addr <= 0xFFFF: // PRG bank
 		m.prgBank = value
+		m.updatePRGBanks()
 	}
 }
 
 // ReadCHR reads from CHR ROM/RAM
 func (m *Mapper1) ReadCHR(addr uint16) uint8 {
-	if len(m.cartridge.CHRROM) > 0 {
-		// CHR ROM
-		chrSize := len(m.cartridge.CHRROM)
-		var offset uint32
-		
-		if m.chrMode == 0 {
-			// 8KB mode
-			bank := m.chrBank0 >> 1 // Use bits 4-1 for 8KB banks
-			offset = uint32(bank) * 0x2000 + uint32(addr)
-		} else {
-			// 4KB mode
-			if addr < 0x1000 {
-				// First 4KB bank
-				offset = uint32(m.chrBank0) * 0x1000 + uint32(addr)
-			} else {
-				// Second 4KB bank
-				offset = uint32(m.chrBank1) * 0x1000 + uint32(addr-0x1000)
-			}
-		}
-		
-		if int(offset) < chrSize {
-			return m.cartridge.CHRROM[offset]
-		}
-		return 0
+	if offset := m.chrOffset(addr); offset >= 0 {
+		return chrMemory(m.cartridge)[offset]
 	}
-	// CHR RAM fallback — typically 8KB, no banking.
-	return readCHRROMOrRAM(m.cartridge, addr)
+	return 0
 }
 
-// WriteCHR writes to CHR RAM
+// chrOffset maps a pattern address through the CHR bank registers onto
+// chrMemory, 4KB bank numbers wrapping at its size; -1 when there is no
+// CHR memory. 8KB mode ignores the low bit of CHR bank 0.
+func (m *Mapper1) chrOffset(addr uint16) int {
+	banks := len(chrMemory(m.cartridge)) / 0x1000
+	if banks == 0 {
+		return -1
+	}
+	var bank int
+	switch {
+	case m.chrMode == 0:
+		bank = int(m.chrBank0&^1) + int(addr>>12)
+	case addr < 0x1000:
+		bank = int(m.chrBank0)
+	default:
+		bank = int(m.chrBank1)
+	}
+	return bank%banks*0x1000 + int(addr&0x0FFF)
+}
+
+// WriteCHR writes to CHR RAM through the same banking as ReadCHR; CHR ROM
+// writes are ignored.
 func (m *Mapper1) WriteCHR(addr uint16, value uint8) {
-	// CHR ROM writes are ignored.
-	writeCHRRAM(m.cartridge, addr, value)
+	writeBankedCHRRAM(m.cartridge, m.chrOffset(addr), value)
 }
 
 // Step does nothing for Mapper1 (no IRQ counter)
@@ -178,8 +171,8 @@ func (m *Mapper1) Step() {
 	// MMC1 has no IRQ functionality
 }
 
-// IsIRQPending returns false for Mapper1 (no IRQ support) 
-func (m *Mapper1) IsIRQPending() bool {
+// IRQLine returns false for Mapper1 (no IRQ support) 
+func (m *Mapper1) IRQLine() bool {
 	return false
 }
 
@@ -188,8 +181,8 @@ func (m *Mapper1) ClearIRQ() {
 	// No IRQ to clear
 }
 
-// GetMirroringMode translates MMC1 control bits 0-1 into the PPU's mirroring
-// code (see pkg/ppu constants). MMC1 mirroring map:
+// GetMirroringMode translates MMC1 control bits 0-1 into a MirroringMode.
+// MMC1 mirroring map:
 //
 //	0 = one-screen lower  -> PPU single-screen lower (2)
 //	1 = one-screen upper  -> PPU single-screen upper (3)
@@ -200,16 +193,16 @@ func (m *Mapper1) ClearIRQ() {
 // dynamically) would always render with the iNES header's static mirroring
 // because the cartridge layer falls back to the header when no mapper exposes
 // GetMirroringMode.
-func (m *Mapper1) GetMirroringMode() uint8 {
+func (m *Mapper1) GetMirroringMode() MirroringMode {
 	switch m.mirroring & 3 {
 	case 0:
-		return 2 // single-screen lower
+		return MirroringSingleScreenLower
 	case 1:
-		return 3 // single-screen upper
+		return MirroringSingleScreenUpper
 	case 2:
-		return 1 // vertical
+		return MirroringVertical
 	case 3:
-		return 0 // horizontal
+		return MirroringHorizontal
 	}
 	return 0
 }
@@ -230,6 +223,12 @@ func (m *Mapper1) SaveState(w io.Writer) error {
 	})
 }
 
+// AppendState implements StateAppender.
+func (m *Mapper1) AppendState(b []byte) []byte {
+	return append(b, m.shiftRegister, m.shiftCount, m.control,
+		m.chrBank0, m.chrBank1, m.prgBank, m.prgMode, m.chrMode, m.mirroring)
+}
+
 // LoadState restores MMC1 state written by SaveState.
 func (m *Mapper1) LoadState(r io.Reader) error {
 	var s mapper1State
@@ -240,5 +239,6 @@ func (m *Mapper1) LoadState(r io.Reader) error {
 	m.control = s.Control
 	m.chrBank0, m.chrBank1, m.prgBank = s.ChrBank0, s.ChrBank1, s.PrgBank
 	m.prgMode, m.chrMode, m.mirroring = s.PrgMode, s.ChrMode, s.Mirroring
+	m.updatePRGBanks()
 	return nil
 }
\ No newline at end of file
@@ -20,7 +20,7 @@ import (
 //	$E000-$EFFF: CHR bank for $1000-$1FFF when latch1 == $FE
 //	$F000-$FFFF: Mirroring (bit 0: 0=vertical, 1=horizontal)
 //
-// Latch transitions (on PPU CHR read):
+// Latch transitions (after a PPU pattern fetch; see PPUAddressBus):
 //
 //	$0FD8-$0FDF -> latch0 = $FD
 //	$0FE8-$0FEF -> latch0 = $FE
@@ -44,6 +44,10 @@ type Mapper10 struct {
 	mirroring uint8 // raw MMC4 bit (0=vertical, 1=horizontal)
 }
 
+func init() {
+	Register(Board{Name: "MMC4 (FxROM)", Battery: true, New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper10(d) }}, 10)
+}
+
 // NewMapper10 creates a new MMC4 mapper.
 func NewMapper10(data *CartridgeData) *Mapper10 {
 	m := &Mapper10{
@@ -54,9 +58,7 @@ func NewMapper10(data *CartridgeData) *Mapper10 {
 		latch1: 0xFE,
 	}
 	m.prgBankCount = uint8(len(data.PRGROM) / 16384)
-	if len(data.CHRROM) > 0 {
-		m.chrBankCount = uint8(len(data.CHRROM) / 4096)
-	}
+	m.chrBankCount = uint8(len(chrMemory(data)) / 4096)
 	return m
 }
 
@@ -109,12 +111,15 @@ func (m *Mapper10) WritePRG(addr uint16, value uint8) {
 	}
 }
 
-// ReadCHR fetches a CHR byte and then updates the latch state if this
-// address falls in one of the trigger ranges. The fetch uses the *current*
-// latch — the trigger applies to subsequent reads (per NESdev MMC4 docs).
+// ReadCHR returns a CHR byte from the bank the current latch selects.
 func (m *Mapper10) ReadCHR(addr uint16) uint8 {
-	value := m.chrFetch(addr)
+	return m.chrFetch(addr)
+}
 
+// PPUAddressBus updates the latch when the PPU has just put one of the
+// trigger ranges on its bus. The triggering fetch itself used the old
+// latch — the switch applies to subsequent reads (per NESdev MMC4 docs).
+func (m *Mapper10) PPUAddressBus(addr uint16) {
 	switch {
 	case addr >= 0x0FD8 && addr <= 0x0FDF:
 		m.latch0 = 0xFD
@@ -125,14 +130,28 @@ func (m *Mapper10) ReadCHR(addr uint16) uint8 {
 	case addr >= 0x1FE8 && addr <= 0x1FEF:
 		m.latch1 = 0xFE
 	}
-
-	return value
 }
 
-// chrFetch picks the active 4KB bank using the current latch state and
-// returns the byte. Address bit 12 selects which pattern table half and
-// thus which latch / register pair to consult.
+// WatchesPatternFetches marks MMC4 as needing rendering pattern fetches
+// on PPUAddressBus, like MMC2.
+func (m *Mapper10) WatchesPatternFetches() {}
+
+// chrFetch returns the byte at addr in the active 4KB bank.
 func (m *Mapper10) chrFetch(addr uint16) uint8 {
+	if offset := m.chrOffset(addr); offset >= 0 {
+		return chrMemory(m.cartridge)[offset]
+	}
+	return 0
+}
+
+// chrOffset picks the active 4KB bank using the current latch state and
+// maps addr onto chrMemory (-1 without CHR memory). Address bit 12
+// selects which pattern table half and thus which latch / register pair
+// to consult.
+func (m *Mapper10) chrOffset(addr uint16) int {
+	if m.chrBankCount == 0 {
+		return -1
+	}
 	var bank uint8
 	if addr < 0x1000 {
 		if m.latch0 == 0xFD {
@@ -147,46 +166,33 @@ func (m *Mapper10) chrFetch(addr uint16) uint8 {
 			bank = m.chrBank1FE
 		}
 	}
-	if m.chrBankCount > 0 {
-		bank %= m.chrBankCount
-	}
-	offset := uint32(bank)*4096 + uint32(addr&0x0FFF)
-	if offset < uint32(len(m.cartridge.CHRROM)) {
-		return m.cartridge.CHRROM[offset]
-	}
-	if int(offset) < len(m.cartridge.CHRRAM) {
-		return m.cartridge.CHRRAM[offset]
-	}
-	return 0
+	return int(bank%m.chrBankCount)*4096 + int(addr&0x0FFF)
 }
 
-// WriteCHR writes to CHR RAM if present. MMC4 carts usually have CHR ROM
-// (read-only), so this is mostly a no-op.
+// WriteCHR writes to CHR RAM, banked like reads, if present. MMC4 carts
+// usually have CHR ROM (read-only), so this is mostly a no-op.
 func (m *Mapper10) WriteCHR(addr uint16, value uint8) {
-	if len(m.cartridge.CHRRAM) == 0 || addr >= 0x2000 {
-		return
-	}
-	if int(addr) < len(m.cartridge.CHRRAM) {
-		m.cartridge.CHRRAM[addr] = value
+	if addr < 0x2000 {
+		writeBankedCHRRAM(m.cartridge, m.chrOffset(addr), value)
 	}
 }
 
 // Step is a no-op: MMC4 has no IRQ counter or scanline timing.
 func (m *Mapper10) Step() {}
 
-// IsIRQPending always false: MMC4 has no IRQ source.
-func (m *Mapper10) IsIRQPending() bool { return false }
+// IRQLine always false: MMC4 has no IRQ source.
+func (m *Mapper10) IRQLine() bool { return false }
 
 // ClearIRQ is a no-op.
 func (m *Mapper10) ClearIRQ() {}
 
 // GetMirroringMode returns the PPU-encoded mirroring (0=horizontal,
 // 1=vertical). MMC4 stores the inverse: bit 0 = 0 means vertical.
-func (m *Mapper10) GetMirroringMode() uint8 {
+func (m *Mapper10) GetMirroringMode() MirroringMode {
 	if m.mirroring == 0 {
-		return 1 // vertical
+		return MirroringVertical
 	}
-	return 0 // horizontal
+	return MirroringHorizontal
 }
 
 type mapper10State struct {
@@ -207,6 +213,12 @@ func (m *Mapper10) SaveState(w io.Writer) error {
 	})
 }
 
+// AppendState implements StateAppender.
+func (m *Mapper10) AppendState(b []byte) []byte {
+	return append(b, m.prgBank, m.chrBank0FD, m.chrBank0FE, m.chrBank1FD, m.chrBank1FE,
+		m.latch0, m.latch1, m.mirroring)
+}
+
 // LoadState restores state written by SaveState.
 func (m *Mapper10) LoadState(r io.Reader) error {
 	var s mapper10State
@@ -0,0 +1,269 @@
+package mapper
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Mapper19 implements the Namco 163 (iNES mapper 19) — Megami Tensei II,
+// Erika to Satoru no Yume Bouken, Rolling Thunder, King of Kings and the
+// other late Namcot games, several with the chip's wavetable sound
+// (n163Audio).
+//
+// Bus map:
+//
+//	$4800-$4FFF  sound RAM data port (n163Audio)
+//	$5000-$57FF  IRQ counter bits 0-7 (R/W)
+//	$5800-$5FFF  IRQ counter bits 8-14, bit 7 enables it (R/W)
+//	$6000-$7FFF  8 KiB PRG RAM
+//	$8000-$BFFF  CHR banks 0-7 (1 KiB each), one per $800
+//	$C000-$DFFF  nametable banks for $2000/$2400/$2800/$2C00, one per $800
+//	$E000-$E7FF  PRG bank at $8000 (bits 0-5), bit 6 silences the sound
+//	$E800-$EFFF  PRG bank at $A000, bits 6-7 keep CHR banks $E0-$FF off
+//	             the nametable RAM for $0000/$1000
+//	$F000-$F7FF  PRG bank at $C000; $E000 holds the last 8 KiB bank
+//	$F800-$FFFF  PRG RAM write protect (bits 4-7 = %0100 unlock, bits 0-3
+//	             protect each 2 KiB), and the sound RAM address
+//
+// The IRQ counter counts up once per CPU cycle while enabled and raises
+// an IRQ on reaching $7FFF, where it stops; writing either half
+// acknowledges. Nametable bank values $E0-$FF pick the console's
+// nametable RAM page (bit 0); the PPU only has its four mirroring
+// arrangements, so those are what GetMirroringMode folds the banks into,
+// and CHR ROM as nametables or the nametable RAM as pattern tables (banks
+// $E0-$FF in $8000-$BFFF) read CHR ROM instead.
+type Mapper19 struct {
+	cartridge *CartridgeData
+
+	prgBanks  [3]uint8
+	chrBanks  [8]uint8
+	ntBanks   [4]uint8
+	chrRAMOff uint8 // $E800 bits 6-7
+	protect   uint8 // $F800
+	prg       prgBankTable
+
+	irqCounter uint16
+	irqEnabled bool
+	irqPending bool
+
+	audio n163Audio
+}
+
+func init() {
+	Register(Board{Name: "Namco 163", Battery: true, Audio: true, IRQ: true,
+		New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper19(d) }}, 19)
+}
+
+// NewMapper19 creates a new Mapper19 instance.
+func NewMapper19(data *CartridgeData) *Mapper19 {
+	m := &Mapper19{cartridge: data, audio: newN163Audio()}
+	m.updatePRGBanks()
+	return m
+}
+
+// PRGBanks implements PRGBankMapper.
+func (m *Mapper19) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+func (m *Mapper19) updatePRGBanks() {
+	rom := m.cartridge.PRGROM
+	for w, b := range m.prgBanks {
+		m.prg.set(w, rom, int(b))
+	}
+	m.prg.set(3, rom, -1)
+}
+
+// DecodesExpansion marks the sound port and IRQ counter at $4800-$5FFF.
+func (m *Mapper19) DecodesExpansion() {}
+
+// ReadPRG reads the sound port, the IRQ counter, PRG RAM and PRG ROM.
+func (m *Mapper19) ReadPRG(addr uint16) uint8 {
+	switch {
+	case addr >= 0x8000:
+		return m.prg.read(addr)
+	case addr >= 0x6000:
+		return readPRGRAM(m.cartridge, addr)
+	case addr >= 0x5800:
+		v := uint8(m.irqCounter >> 8)
+		if m.irqEnabled {
+			v |= 0x80
+		}
+		return v
+	case addr >= 0x5000:
+		return uint8(m.irqCounter)
+	case addr >= 0x4800:
+		return m.audio.readData()
+	}
+	return 0
+}
+
+// WritePRG decodes the registers and PRG RAM writes.
+func (m *Mapper19) WritePRG(addr uint16, value uint8) {
+	switch {
+	case addr < 0x4800:
+	case addr < 0x5000:
+		m.audio.writeData(value)
+	case addr < 0x5800:
+		m.irqCounter = m.irqCounter&0x7F00 | uint16(value)
+		m.irqPending = false
+	case addr < 0x6000:
+		m.irqCounter = m.irqCounter&0x00FF | uint16(value&0x7F)<<8
+		m.irqEnabled = value&0x80 != 0
+		m.irqPending = false
+	case addr < 0x8000:
+		if m.protect&0xF0 == 0x40 && m.protect>>((addr-0x6000)>>11)&1 == 0 {
+			writePRGRAM(m.cartridge, addr, value)
+		}
+	case addr < 0xC000:
+		m.chrBanks[(addr-0x8000)>>11] = value
+	case addr < 0xE000:
+		m.ntBanks[(addr-0xC000)>>11] = value
+	case addr < 0xE800:
+		m.prgBanks[0] = value & 0x3F
+		m.audio.disabled = value&0x40 != 0
+		m.updatePRGBanks()
+	case addr < 0xF000:
+		m.prgBanks[1] = value & 0x3F
+		m.chrRAMOff = value & 0xC0
+		m.updatePRGBanks()
+	case addr < 0xF800:
+		m.prgBanks[2] = value & 0x3F
+		m.updatePRGBanks()
+	default:
+		m.protect = value
+		m.audio.writeAddress(value)
+	}
+}
+
+// chrOffset maps addr through its 1 KiB bank onto CHR memory; -1 when
+// there is none.
+func (m *Mapper19) chrOffset(addr uint16) int {
+	n := len(chrMemory(m.cartridge)) / 1024
+	if n == 0 || addr >= 0x2000 {
+		return -1
+	}
+	return int(m.chrBanks[addr>>10])%n*1024 + int(addr&0x3FF)
+}
+
+// ReadCHR reads through the 1 KiB bank for addr.
+func (m *Mapper19) ReadCHR(addr uint16) uint8 {
+	if off := m.chrOffset(addr); off >= 0 {
+		return chrMemory(m.cartridge)[off]
+	}
+	return 0
+}
+
+// WriteCHR writes CHR RAM, banked like reads, when the board has it.
+func (m *Mapper19) WriteCHR(addr uint16, value uint8) {
+	writeBankedCHRRAM(m.cartridge, m.chrOffset(addr), value)
+}
+
+// GetMirroringMode folds the nametable banks into the PPU's encoding.
+// Arrangements it has no code for, and CHR ROM nametables, fall back to
+// the header's mirroring.
+func (m *Mapper19) GetMirroringMode() MirroringMode {
+	var page [4]uint8
+	for i, b := range m.ntBanks {
+		if b < 0xE0 {
+			return m.cartridge.Mirroring
+		}
+		page[i] = b & 1
+	}
+	switch page {
+	case [4]uint8{0, 0, 1, 1}:
+		return MirroringHorizontal
+	case [4]uint8{0, 1, 0, 1}:
+		return MirroringVertical
+	case [4]uint8{0, 0, 0, 0}:
+		return MirroringSingleScreenLower
+	case [4]uint8{1, 1, 1, 1}:
+		return MirroringSingleScreenUpper
+	}
+	return m.cartridge.Mirroring
+}
+
+// TickCPU runs the IRQ counter and the sound channels.
+func (m *Mapper19) TickCPU(cycles int) {
+	m.audio.tick(cycles)
+	if !m.irqEnabled || m.irqCounter >= 0x7FFF {
+		return
+	}
+	m.irqCounter += uint16(min(cycles, int(0x7FFF-m.irqCounter)))
+	if m.irqCounter == 0x7FFF {
+		m.irqPending = true
+	}
+}
+
+// AudioSample exposes the wavetable channels' mix for the APU's mixer.
+func (m *Mapper19) AudioSample() float32 { return m.audio.sample() }
+
+// AudioChip names the sound chip for the mixer's per-chip levels
+// (apu.ChipN163).
+func (m *Mapper19) AudioChip() string { return "n163" }
+
+// Step is a no-op: the IRQ runs on the CPU clock (TickCPU).
+func (m *Mapper19) Step() {}
+
+func (m *Mapper19) IRQLine() bool { return m.irqPending }
+func (m *Mapper19) ClearIRQ()     { m.irqPending = false }
+
+// IRQCapable marks the Namco 163 as IRQ-asserting (CPU-clock counter).
+func (m *Mapper19) IRQCapable() {}
+
+// Reset implements Resetter: the counter powers up stopped.
+func (m *Mapper19) Reset() {
+	m.irqEnabled, m.irqPending = false, false
+}
+
+type mapper19State struct {
+	PRGBanks   [3]uint8
+	CHRBanks   [8]uint8
+	NTBanks    [4]uint8
+	CHRRAMOff  uint8
+	Protect    uint8
+	IRQCounter uint16
+	IRQEnabled bool
+	IRQPending bool
+	Audio      n163AudioState
+}
+
+// SaveState persists the bank registers, the IRQ counter and the sound
+// chip, its RAM included.
+func (m *Mapper19) SaveState(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, mapper19State{
+		PRGBanks:   m.prgBanks,
+		CHRBanks:   m.chrBanks,
+		NTBanks:    m.ntBanks,
+		CHRRAMOff:  m.chrRAMOff,
+		Protect:    m.protect,
+		IRQCounter: m.irqCounter,
+		IRQEnabled: m.irqEnabled,
+		IRQPending: m.irqPending,
+		Audio:      m.audio.state(),
+	})
+}
+
+// AppendState implements StateAppender.
+func (m *Mapper19) AppendState(b []byte) []byte {
+	b = append(b, m.prgBanks[:]...)
+	b = append(b, m.chrBanks[:]...)
+	b = append(b, m.ntBanks[:]...)
+	b = append(b, m.chrRAMOff, m.protect)
+	b = binary.LittleEndian.AppendUint16(b, m.irqCounter)
+	b = appendBool(b, m.irqEnabled)
+	b = appendBool(b, m.irqPending)
+	return m.audio.appendState(b)
+}
+
+// LoadState restores state written by SaveState.
+func (m *Mapper19) LoadState(r io.Reader) error {
+	var s mapper19State
+	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
+		return err
+	}
+	m.prgBanks, m.chrBanks, m.ntBanks = s.PRGBanks, s.CHRBanks, s.NTBanks
+	m.chrRAMOff, m.protect = s.CHRRAMOff, s.Protect
+	m.irqCounter, m.irqEnabled, m.irqPending = s.IRQCounter, s.IRQEnabled, s.IRQPending
+	m.audio.restore(s.Audio)
+	m.updatePRGBanks()
+	return nil
+}
@@ -0,0 +1,154 @@
+package mapper
+
+// Namco 163 expansion audio: up to eight wavetable channels that play
+// 4-bit samples out of the chip's 128 bytes of sound RAM (NESdev "Namco
+// 163 audio").
+//
+//	$F800-$FFFF  bits 0-6 RAM address for the data port, bit 7 increments
+//	             it after each access
+//	$4800-$4FFF  data port, read and write
+//
+// The channel registers are the top of the same RAM, eight bytes each,
+// channel 8 at $78-$7F down to channel 1 at $40-$47:
+//
+//	+0 +2 +4  frequency, 18 bits (+4 bits 0-1 are the top)
+//	+1 +3 +5  phase, 24 bits; the top byte is the sample position
+//	+4        bits 2-7: the wave is 256 - (+4 & $FC) samples long
+//	+6        wave start, in samples (two per byte, low nibble first)
+//	+7        bits 0-3 volume; at $7F bits 4-6 are the number of enabled
+//	          channels minus one, counted down from channel 8
+//
+// The chip updates one channel every 15 CPU cycles, round-robin over the
+// enabled ones, adding its frequency to its phase and outputting
+// (sample - 8) × volume. On the real chip the channels take turns on the
+// DAC; the mixer here averages them, which is what that whine sounds like
+// once filtered. Bit 6 of $E000 silences the chip.
+type n163Audio struct {
+	ram      [128]uint8
+	addr     uint8 // data port address
+	autoInc  bool
+	disabled bool
+
+	cycles  uint8    // CPU cycles into the current 15-cycle update
+	current uint8    // the channel updated next, 7 for channel 8
+	outputs [8]int16 // each channel's last (sample - 8) × volume
+}
+
+// n163UpdateCycles is how long the chip spends on each channel.
+const n163UpdateCycles = 15
+
+// n163AudioPeak is AudioSample at the loudest output: about twice a
+// full-volume 2A03 pulse, in the middle of the spread of the boards'
+// measured mixing resistors.
+const n163AudioPeak = 0.3
+
+func newN163Audio() n163Audio {
+	return n163Audio{current: 7}
+}
+
+// writeAddress handles $F800-$FFFF writes.
+func (a *n163Audio) writeAddress(value uint8) {
+	a.addr = value & 0x7F
+	a.autoInc = value&0x80 != 0
+}
+
+// readData handles $4800-$4FFF reads.
+func (a *n163Audio) readData() uint8 {
+	v := a.ram[a.addr]
+	a.step()
+	return v
+}
+
+// writeData handles $4800-$4FFF writes.
+func (a *n163Audio) writeData(value uint8) {
+	a.ram[a.addr] = value
+	a.step()
+}
+
+func (a *n163Audio) step() {
+	if a.autoInc {
+		a.addr = (a.addr + 1) & 0x7F
+	}
+}
+
+// channels is the number of enabled channels, 1-8.
+func (a *n163Audio) channels() uint8 {
+	return a.ram[0x7F]>>4&7 + 1
+}
+
+// tick runs the channel updates for cycles CPU cycles.
+func (a *n163Audio) tick(cycles int) {
+	if a.disabled {
+		return
+	}
+	for i := 0; i < cycles; i++ {
+		a.cycles++
+		if a.cycles < n163UpdateCycles {
+			continue
+		}
+		a.cycles = 0
+		a.update(a.current)
+		if a.current <= 8-a.channels() {
+			a.current = 7
+		} else {
+			a.current--
+		}
+	}
+}
+
+// update advances channel ch (0-7) and latches its output.
+func (a *n163Audio) update(ch uint8) {
+	r := a.ram[0x40+int(ch)*8:][:8]
+	freq := uint32(r[0]) | uint32(r[2])<<8 | uint32(r[4]&3)<<16
+	phase := uint32(r[1]) | uint32(r[3])<<8 | uint32(r[5])<<16
+	length := 256 - uint32(r[4]&0xFC)
+	phase = (phase + freq) % (length << 16)
+	r[1], r[3], r[5] = uint8(phase), uint8(phase>>8), uint8(phase>>16)
+
+	s := uint8(phase>>16) + r[6]
+	nibble := a.ram[s>>1&0x7F] >> (s & 1 * 4) & 0x0F
+	a.outputs[ch] = (int16(nibble) - 8) * int16(r[7]&0x0F)
+}
+
+func (a *n163Audio) sample() float32 {
+	if a.disabled {
+		return 0
+	}
+	n := a.channels()
+	var sum int32
+	for ch := 8 - n; ch < 8; ch++ {
+		sum += int32(a.outputs[ch])
+	}
+	return float32(sum) / float32(n) / 120 * n163AudioPeak
+}
+
+// n163AudioState is the save-state image of the chip, sound RAM included.
+type n163AudioState struct {
+	RAM               [128]uint8
+	Addr              uint8
+	AutoInc, Disabled bool
+	Cycles, Current   uint8
+	Outputs           [8]int16
+}
+
+func (a *n163Audio) state() n163AudioState {
+	return n163AudioState{a.ram, a.addr, a.autoInc, a.disabled, a.cycles, a.current, a.outputs}
+}
+
+// appendState appends what state() encodes, without building it.
+func (a *n163Audio) appendState(b []byte) []byte {
+	b = append(b, a.ram[:]...)
+	b = append(b, a.addr)
+	b = appendBool(b, a.autoInc)
+	b = appendBool(b, a.disabled)
+	b = append(b, a.cycles, a.current)
+	for _, o := range a.outputs {
+		b = append(b, uint8(o), uint8(uint16(o)>>8))
+	}
+	return b
+}
+
+func (a *n163Audio) restore(s n163AudioState) {
+	a.ram, a.addr, a.autoInc, a.disabled = s.RAM, s.Addr, s.AutoInc, s.Disabled
+	a.cycles, a.current, a.outputs = s.Cycles, s.Current, s.Outputs
+}
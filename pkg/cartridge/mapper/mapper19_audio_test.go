@@ -0,0 +1,87 @@
+package mapper
+
+import "testing"
+
+// n163Triangle is a 32-sample wave as games upload it: sixteen bytes, two
+// 4-bit samples each, low nibble first — a triangle rising 0,1,…,15 and
+// falling back 15,14,…,0. n163TriangleSamples is the same wave spelled
+// out a sample at a time.
+var (
+	n163Triangle = []uint8{
+		0x10, 0x32, 0x54, 0x76, 0x98, 0xBA, 0xDC, 0xFE,
+		0xEF, 0xCD, 0xAB, 0x89, 0x67, 0x45, 0x23, 0x01,
+	}
+	n163TriangleSamples = []int16{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0,
+	}
+)
+
+// n163Upload writes data to sound RAM from addr through the mapper's
+// auto-incrementing port, as the games' drivers do.
+func n163Upload(m *Mapper19, addr uint8, data ...uint8) {
+	m.WritePRG(0xF800, 0x80|addr)
+	for _, v := range data {
+		m.WritePRG(0x4800, v)
+	}
+}
+
+func TestN163AudioWave(t *testing.T) {
+	m := newMapper19()
+	n163Upload(m, 0x00, n163Triangle...)
+	// Channel 8 alone: frequency $10000 steps one sample per update, 32
+	// samples long from sample 0, volume 15.
+	n163Upload(m, 0x78, 0x00, 0x00, 0x00, 0x00, 0x01|(256-32), 0x00, 0x00, 0x0F)
+
+	// The port reads back what it wrote, and the address wraps at $7F.
+	m.WritePRG(0xF800, 0x80|0x7E)
+	if a, b, c := m.ReadPRG(0x4800), m.ReadPRG(0x4800), m.ReadPRG(0x4800); a != 0x00 || b != 0x0F || c != n163Triangle[0] {
+		t.Errorf("port read % X", []uint8{a, b, c})
+	}
+
+	// One channel updates every 15 cycles: sample k of the wave comes out
+	// after update k, then the wave repeats.
+	for k := 1; k <= 40; k++ {
+		m.TickCPU(n163UpdateCycles)
+		if got, want := m.audio.outputs[7], (n163TriangleSamples[k%32]-8)*15; got != want {
+			t.Fatalf("update %d: output %d, want %d", k, got, want)
+		}
+	}
+	if got, want := m.AudioSample(), float32(m.audio.outputs[7])/120*n163AudioPeak; got != want {
+		t.Errorf("AudioSample %v, want %v", got, want)
+	}
+
+	// $E000 bit 6 silences the chip and stops its updates.
+	m.WritePRG(0xE000, 0x40)
+	phase := m.audio.ram[0x7D]
+	m.TickCPU(n163UpdateCycles * 4)
+	if m.AudioSample() != 0 || m.audio.ram[0x7D] != phase {
+		t.Error("disabled chip still playing")
+	}
+}
+
+func TestN163AudioChannels(t *testing.T) {
+	m := newMapper19()
+	n163Upload(m, 0x00, n163Triangle...)
+	// Channel 7 plays sample 31 (nibble 0) at volume 4 and holds it;
+	// channel 8 the triangle's first sample (nibble 0) at 15 and holds it.
+	n163Upload(m, 0x70, 0x00, 0x00, 0x00, 0x00, 256-32, 0x00, 31, 0x04)
+	n163Upload(m, 0x78, 0x00, 0x00, 0x00, 0x00, 256-32, 0x00, 0x00, 0x1F) // two channels
+
+	// Round-robin: channel 8, then 7, then 8 again.
+	m.TickCPU(n163UpdateCycles)
+	if m.audio.outputs[7] != -8*15 || m.audio.outputs[6] != 0 {
+		t.Fatalf("first update: outputs %v", m.audio.outputs)
+	}
+	m.TickCPU(n163UpdateCycles)
+	if m.audio.outputs[6] != -8*4 {
+		t.Fatalf("second update: outputs %v", m.audio.outputs)
+	}
+	if m.audio.current != 7 {
+		t.Errorf("next channel %d, want 7 (channel 8)", m.audio.current)
+	}
+	// The mix is the channels' average.
+	if got, want := m.AudioSample(), float32(-8*15-8*4)/2/120*n163AudioPeak; got != want {
+		t.Errorf("AudioSample %v, want %v", got, want)
+	}
+}
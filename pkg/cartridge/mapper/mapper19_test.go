@@ -0,0 +1,111 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newMapper19 builds a 256 KiB PRG / 256 KiB CHR Namco 163 with each 8 KiB
+// PRG bank and 1 KiB CHR bank tagged in its first byte.
+func newMapper19() *Mapper19 {
+	prg := make([]uint8, 256<<10)
+	for b := 0; b < 32; b++ {
+		prg[b*0x2000] = uint8(b)
+	}
+	chr := make([]uint8, 256<<10)
+	for b := 0; b < 256; b++ {
+		chr[b*1024] = uint8(b)
+	}
+	return NewMapper19(&CartridgeData{
+		PRGROM: prg, CHRROM: chr, PRGRAM: make([]uint8, 8<<10),
+		Mirroring: MirroringVertical,
+	})
+}
+
+func TestMapper19Banking(t *testing.T) {
+	m := newMapper19()
+	m.WritePRG(0xE000, 4)
+	m.WritePRG(0xE800, 5)
+	m.WritePRG(0xF000, 6)
+	for addr, want := range map[uint16]uint8{0x8000: 4, 0xA000: 5, 0xC000: 6, 0xE000: 31} {
+		if got := m.ReadPRG(addr); got != want {
+			t.Errorf("$%04X bank %d, want %d", addr, got, want)
+		}
+	}
+	m.WritePRG(0xB800, 0x99)
+	if got := m.ReadCHR(0x1C00); got != 0x99 {
+		t.Errorf("CHR bank 7 = %02X, want 99", got)
+	}
+
+	// Nametable banks on the console's RAM fold into mirroring.
+	for _, tc := range []struct {
+		banks [4]uint8
+		want  MirroringMode
+	}{
+		{[4]uint8{0xE0, 0xE0, 0xE1, 0xE1}, MirroringHorizontal},
+		{[4]uint8{0xE0, 0xE1, 0xE0, 0xE1}, MirroringVertical},
+		{[4]uint8{0xE1, 0xE1, 0xE1, 0xE1}, MirroringSingleScreenUpper},
+		{[4]uint8{0xE0, 0x10, 0xE0, 0xE1}, MirroringVertical}, // CHR ROM: the header's
+	} {
+		for i, b := range tc.banks {
+			m.WritePRG(0xC000+uint16(i)*0x800, b)
+		}
+		if got := m.GetMirroringMode(); got != tc.want {
+			t.Errorf("banks % X: mirroring %d, want %d", tc.banks, got, tc.want)
+		}
+	}
+
+	// PRG RAM writes need $F800's unlock, and bits 0-3 protect 2 KiB each.
+	m.WritePRG(0x6000, 0x11)
+	m.WritePRG(0xF800, 0x42) // unlocked, $6800-$6FFF protected
+	m.WritePRG(0x6800, 0x22)
+	m.WritePRG(0x7000, 0x33)
+	if m.ReadPRG(0x6000) != 0 || m.ReadPRG(0x6800) != 0 || m.ReadPRG(0x7000) != 0x33 {
+		t.Errorf("PRG RAM % X", []uint8{m.ReadPRG(0x6000), m.ReadPRG(0x6800), m.ReadPRG(0x7000)})
+	}
+}
+
+func TestMapper19IRQ(t *testing.T) {
+	m := newMapper19()
+	m.WritePRG(0x5000, 0xF0)
+	m.WritePRG(0x5800, 0xFF) // enable, counter $7FF0
+	m.TickCPU(14)
+	if m.IRQLine() {
+		t.Fatal("IRQ before $7FFF")
+	}
+	m.TickCPU(7)
+	if !m.IRQLine() || m.ReadPRG(0x5000) != 0xFF || m.ReadPRG(0x5800) != 0xFF {
+		t.Fatalf("at $7FFF: IRQ %v, counter %02X%02X", m.IRQLine(), m.ReadPRG(0x5800), m.ReadPRG(0x5000))
+	}
+	m.WritePRG(0x5800, 0x00)
+	if m.IRQLine() {
+		t.Error("counter write didn't acknowledge")
+	}
+}
+
+func TestMapper19State(t *testing.T) {
+	m := newMapper19()
+	m.WritePRG(0xE800, 3)
+	m.WritePRG(0x8800, 0x12)
+	m.WritePRG(0xF800, 0x80|0x7F)
+	m.WritePRG(0x4800, 0x30) // four channels
+	m.WritePRG(0x5000, 0x20)
+	m.WritePRG(0x5800, 0x81)
+	m.TickCPU(100)
+	var buf bytes.Buffer
+	if err := m.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.AppendState(nil); !bytes.Equal(got, buf.Bytes()) {
+		t.Errorf("AppendState % X, SaveState % X", got, buf.Bytes())
+	}
+
+	n := newMapper19()
+	if err := n.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n.ReadPRG(0xA000) != 3 || n.ReadCHR(0x0400) != 0x12 || n.irqCounter != 0x0184 || n.audio.channels() != 4 {
+		t.Errorf("restored PRG %d, CHR %02X, counter %04X, %d channels",
+			n.ReadPRG(0xA000), n.ReadCHR(0x0400), n.irqCounter, n.audio.channels())
+	}
+}
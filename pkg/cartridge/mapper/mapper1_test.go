@@ -191,4 +191,22 @@ func TestMapper1_MMC1(t *testing.T) {
 			t.Logf("Set mirroring mode %d", mode)
 		}
 	})
-}
\ No newline at end of file
+}
+// BenchmarkMMC1BankSwitch reloads the PRG and CHR bank registers through
+// the serial port (five writes each) and reads through the new banks.
+func BenchmarkMMC1BankSwitch(b *testing.B) {
+	m := NewMapper1(makeData(16, 32))
+	load := func(addr uint16, v uint8) {
+		for bit := 0; bit < 5; bit++ {
+			m.WritePRG(addr, v>>bit&1)
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		load(0xE000, uint8(i))
+		load(0xA000, uint8(i))
+		load(0xC000, uint8(i)+1)
+		_ = m.ReadPRG(0x8000) + m.ReadCHR(0x0000) + m.ReadCHR(0x1000)
+	}
+}
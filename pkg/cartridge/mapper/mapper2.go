@@ -12,6 +12,12 @@ type Mapper2 struct {
 	// Bank selection
 	prgBank      uint8 // Current PRG bank (0-15)
 	prgBankCount uint8 // Number of 16KB PRG banks
+
+	prg prgBankTable // $8000-$FFFF windows, rebuilt by updatePRGBanks
+}
+
+func init() {
+	Register(Board{Name: "UxROM", New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper2(d) }}, 2)
 }
 
 // NewMapper2 creates a new Mapper2 instance
@@ -23,6 +29,7 @@ func NewMapper2(data *CartridgeData) *Mapper2 {
 	
 	// Calculate PRG bank count (16KB banks)
 	m.prgBankCount = uint8(len(data.PRGROM) / 16384)
+	m.updatePRGBanks()
 	
 	return m
 }
@@ -30,26 +37,7 @@ func NewMapper2(data *CartridgeData) *Mapper2 {
 // ReadPRG reads from PRG space
 func (m *Mapper2) ReadPRG(addr uint16) uint8 {
 	if addr >= 0x8000 {
-		// PRG ROM area
-		if addr < 0xC000 {
-			// $8000-$BFFF: Switchable 16KB bank
-			bank := m.prgBank % m.prgBankCount
-			offset := addr - 0x8000
-			finalAddr := uint32(bank)*16384 + uint32(offset)
-			
-			if finalAddr < uint32(len(m.cartridge.PRGROM)) {
-				return m.cartridge.PRGROM[finalAddr]
-			}
-		} else {
-			// $C000-$FFFF: Fixed to last 16KB bank
-			lastBank := m.prgBankCount - 1
-			offset := addr - 0xC000
-			finalAddr := uint32(lastBank)*16384 + uint32(offset)
-			
-			if finalAddr < uint32(len(m.cartridge.PRGROM)) {
-				return m.cartridge.PRGROM[finalAddr]
-			}
-		}
+		return m.prg.read(addr)
 	} else if addr >= 0x6000 {
 		return readPRGRAM(m.cartridge, addr)
 	}
@@ -57,11 +45,23 @@ func (m *Mapper2) ReadPRG(addr uint16) uint8 {
 	return 0
 }
 
+// PRGBanks implements PRGBankMapper.
+func (m *Mapper2) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+// updatePRGBanks maps the selected 16KB bank at $8000 and the fixed last
+// bank at $C000.
+func (m *Mapper2) updatePRGBanks() {
+	rom := m.cartridge.PRGROM
+	m.prg.set16K(0, rom, int(m.prgBank))
+	m.prg.set16K(2, rom, int(m.prgBankCount)-1)
+}
+
 // WritePRG writes to PRG space (handles bank switching)
 func (m *Mapper2) WritePRG(addr uint16, value uint8) {
 	if addr >= 0x8000 {
 		// Bank register write - any write to $8000-$FFFF changes PRG bank
 		m.prgBank = value & 0x0F // Only lower 4 bits used for bank selection
+		m.updatePRGBanks()
 		return
 	}
 	// PRG RAM write ($6000-$7FFF). Out-of-range addresses are ignored.
@@ -91,8 +91,8 @@ func (m *Mapper2) GetCurrentPRGBank() uint8 {
 	return m.prgBank
 }
 
-// IsIRQPending returns false for Mapper2 (no IRQ support)
-func (m *Mapper2) IsIRQPending() bool {
+// IRQLine returns false for Mapper2 (no IRQ support)
+func (m *Mapper2) IRQLine() bool {
 	return false
 }
 
@@ -106,7 +106,16 @@ func (m *Mapper2) SaveState(w io.Writer) error {
 	return binary.Write(w, binary.LittleEndian, m.prgBank)
 }
 
+// AppendState implements StateAppender.
+func (m *Mapper2) AppendState(b []byte) []byte {
+	return append(b, m.prgBank)
+}
+
 // LoadState restores the selected PRG bank.
 func (m *Mapper2) LoadState(r io.Reader) error {
-	return binary.Read(r, binary.LittleEndian, &m.prgBank)
+	if err := binary.Read(r, binary.LittleEndian, &m.prgBank); err != nil {
+		return err
+	}
+	m.updatePRGBanks()
+	return nil
 }
\ No newline at end of file
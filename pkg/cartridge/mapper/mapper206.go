@@ -0,0 +1,136 @@
+package mapper
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Mapper206 implements DxROM — Nintendo's boards for the Namco 108 (Tengen
+// 800002), the chip MMC3 grew out of: Gauntlet, Pac-Mania, Ring King,
+// Karnov and the early Namcot Famicom games. It is MMC3's bank-select and
+// bank-data pair with nothing else:
+//
+//	$8000-$9FFE even  bank select, bits 0-2 (no PRG or CHR mode bits)
+//	$8001-$9FFF odd   bank data
+//	R0/R1             2 KiB CHR at $0000/$0800 (bit 0 ignored)
+//	R2-R5             1 KiB CHR at $1000/$1400/$1800/$1C00
+//	R6/R7             8 KiB PRG at $8000/$A000; $C000/$E000 hold the last two
+//
+// Banks are six bits of CHR and four of PRG. Mirroring is wired on the
+// board (the header's), there is no IRQ, and writes to $A000-$FFFF reach
+// nothing — games written for it leave junk in the bank select's top bits
+// and write where MMC3 has its mirroring and IRQ registers, which is why
+// running them as MMC3 breaks them.
+type Mapper206 struct {
+	cartridge *CartridgeData
+
+	bankSelect uint8
+	banks      [8]uint8
+	prg        prgBankTable
+}
+
+func init() {
+	Register(Board{Name: "DxROM (Namco 108)",
+		New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper206(d) }}, 206)
+}
+
+// NewMapper206 creates a new Mapper206 instance.
+func NewMapper206(data *CartridgeData) *Mapper206 {
+	m := &Mapper206{cartridge: data}
+	m.updatePRGBanks()
+	return m
+}
+
+// PRGBanks implements PRGBankMapper.
+func (m *Mapper206) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+func (m *Mapper206) updatePRGBanks() {
+	rom := m.cartridge.PRGROM
+	m.prg.set(0, rom, int(m.banks[6]&0x0F))
+	m.prg.set(1, rom, int(m.banks[7]&0x0F))
+	m.prg.set(2, rom, -2)
+	m.prg.set(3, rom, -1)
+}
+
+// ReadPRG reads from PRG space; $6000-$7FFF is PRG RAM.
+func (m *Mapper206) ReadPRG(addr uint16) uint8 {
+	if addr >= 0x8000 {
+		return m.prg.read(addr)
+	}
+	return readPRGRAM(m.cartridge, addr)
+}
+
+// WritePRG decodes the two registers at $8000-$9FFF.
+func (m *Mapper206) WritePRG(addr uint16, value uint8) {
+	switch {
+	case addr < 0x8000:
+		writePRGRAM(m.cartridge, addr, value)
+	case addr >= 0xA000:
+	case addr&1 == 0:
+		m.bankSelect = value & 7
+	default:
+		m.banks[m.bankSelect] = value
+		if m.bankSelect >= 6 {
+			m.updatePRGBanks()
+		}
+	}
+}
+
+// chrOffset maps addr through its bank onto CHR memory; -1 when there is
+// none.
+func (m *Mapper206) chrOffset(addr uint16) int {
+	n := len(chrMemory(m.cartridge)) / 1024
+	if n == 0 || addr >= 0x2000 {
+		return -1
+	}
+	var bank int
+	if addr < 0x1000 {
+		bank = int(m.banks[addr>>11]&0x3E) | int(addr>>10&1)
+	} else {
+		bank = int(m.banks[2+(addr-0x1000)>>10] & 0x3F)
+	}
+	return bank%n*1024 + int(addr&0x3FF)
+}
+
+// ReadCHR reads through the CHR banks.
+func (m *Mapper206) ReadCHR(addr uint16) uint8 {
+	if off := m.chrOffset(addr); off >= 0 {
+		return chrMemory(m.cartridge)[off]
+	}
+	return 0
+}
+
+// WriteCHR writes CHR RAM, banked like reads, when the board has it.
+func (m *Mapper206) WriteCHR(addr uint16, value uint8) {
+	writeBankedCHRRAM(m.cartridge, m.chrOffset(addr), value)
+}
+
+func (m *Mapper206) Step()         {}
+func (m *Mapper206) IRQLine() bool { return false }
+func (m *Mapper206) ClearIRQ()     {}
+
+type mapper206State struct {
+	BankSelect uint8
+	Banks      [8]uint8
+}
+
+// SaveState persists the bank select and the eight bank registers.
+func (m *Mapper206) SaveState(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, mapper206State{m.bankSelect, m.banks})
+}
+
+// AppendState implements StateAppender.
+func (m *Mapper206) AppendState(b []byte) []byte {
+	return append(append(b, m.bankSelect), m.banks[:]...)
+}
+
+// LoadState restores state written by SaveState.
+func (m *Mapper206) LoadState(r io.Reader) error {
+	var s mapper206State
+	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
+		return err
+	}
+	m.bankSelect, m.banks = s.BankSelect, s.Banks
+	m.updatePRGBanks()
+	return nil
+}
@@ -0,0 +1,78 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newMapper206 builds a 128 KiB PRG / 64 KiB CHR DxROM with each 8 KiB PRG
+// bank and 1 KiB CHR bank tagged in its first byte.
+func newMapper206() *Mapper206 {
+	prg := make([]uint8, 128<<10)
+	for b := 0; b < 16; b++ {
+		prg[b*0x2000] = uint8(b)
+	}
+	chr := make([]uint8, 64<<10)
+	for b := 0; b < 64; b++ {
+		chr[b*1024] = uint8(b)
+	}
+	return NewMapper206(&CartridgeData{PRGROM: prg, CHRROM: chr, PRGRAM: make([]uint8, 8<<10)})
+}
+
+func TestMapper206Banking(t *testing.T) {
+	m := newMapper206()
+	// Junk in the select's top bits, which on MMC3 would swap the PRG
+	// and CHR layouts, changes nothing here.
+	for reg, v := range []uint8{0x05, 0x0A, 0x20, 0x21, 0x22, 0x23, 0x03, 0x04} {
+		m.WritePRG(0x8000, 0xC0|uint8(reg))
+		m.WritePRG(0x8001, v)
+	}
+	for addr, want := range map[uint16]uint8{0x8000: 3, 0xA000: 4, 0xC000: 14, 0xE000: 15} {
+		if got := m.ReadPRG(addr); got != want {
+			t.Errorf("$%04X bank %d, want %d", addr, got, want)
+		}
+	}
+	// R0/R1 are 2 KiB banks: bit 0 of the value is ignored.
+	for addr, want := range map[uint16]uint8{0x0000: 4, 0x0400: 5, 0x0800: 10, 0x0C00: 11, 0x1000: 0x20, 0x1C00: 0x23} {
+		if got := m.ReadCHR(addr); got != want {
+			t.Errorf("CHR $%04X bank %d, want %d", addr, got, want)
+		}
+	}
+
+	// MMC3's mirroring and IRQ registers aren't there.
+	for _, addr := range []uint16{0xA000, 0xA001, 0xC000, 0xC001, 0xE000, 0xE001} {
+		m.WritePRG(addr, 0xFF)
+	}
+	if m.ReadPRG(0x8000) != 3 || m.IRQLine() {
+		t.Error("a write above $9FFF had an effect")
+	}
+	if _, ok := any(m).(IRQCapable); ok {
+		t.Error("DxROM claims an IRQ")
+	}
+	if _, ok := any(m).(MirroringSource); ok {
+		t.Error("DxROM claims to control mirroring")
+	}
+}
+
+func TestMapper206State(t *testing.T) {
+	m := newMapper206()
+	m.WritePRG(0x8000, 7)
+	m.WritePRG(0x8001, 9)
+	m.WritePRG(0x8000, 2)
+	var buf bytes.Buffer
+	if err := m.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.AppendState(nil); !bytes.Equal(got, buf.Bytes()) {
+		t.Errorf("AppendState % X, SaveState % X", got, buf.Bytes())
+	}
+
+	n := newMapper206()
+	if err := n.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	n.WritePRG(0x8001, 0x30)
+	if n.ReadPRG(0xA000) != 9 || n.ReadCHR(0x1000) != 0x30 {
+		t.Errorf("restored $A000 bank %d, R2 %02X", n.ReadPRG(0xA000), n.ReadCHR(0x1000))
+	}
+}
@@ -13,15 +13,18 @@ type Mapper3 struct {
 	chrBank      uint8 // Current CHR bank (0-3)
 	chrBankCount uint8 // Number of 8KB CHR banks
 
-	// prgMask masks the $8000-offset address into the PRG ROM. $3FFF for
-	// 16KB carts (so $8000-$BFFF and $C000-$FFFF mirror), $7FFF for 32KB.
-	// Cached at construction to avoid a length-compare on every CPU fetch.
-	prgMask uint16
+	// prg is the fixed $8000-$FFFF mapping, built at construction. 16KB
+	// carts appear at both $8000 and $C000.
+	prg prgBankTable
 
 	// Bus conflict behavior
 	busConflictMode uint8 // 0=unknown, 1=no conflicts, 2=AND-type conflicts
 }
 
+func init() {
+	Register(Board{Name: "CNROM", New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper3(d) }}, 3)
+}
+
 // NewMapper3 creates a new Mapper3 instance
 func NewMapper3(data *CartridgeData) *Mapper3 {
 	m := &Mapper3{
@@ -35,27 +38,17 @@ func NewMapper3(data *CartridgeData) *Mapper3 {
 		m.chrBankCount = uint8(len(data.CHRROM) / 8192)
 	}
 
-	switch len(data.PRGROM) {
-	case 16384:
-		m.prgMask = 0x3FFF
-	default:
-		m.prgMask = 0x7FFF
-	}
+	m.prg.setFixed(data.PRGROM)
 
 	return m
 }
 
 // ReadPRG reads from PRG space. 16KB CNROM carts mirror their PRG bank at
-// $8000-$BFFF and $C000-$FFFF (prgMask=$3FFF, set at construction);
-// otherwise the reset/NMI/IRQ vectors at $FFFA-$FFFF would fall outside
-// ROM and read as 0.
+// $8000-$BFFF and $C000-$FFFF (see setFixed); otherwise the reset/NMI/IRQ
+// vectors at $FFFA-$FFFF would fall outside ROM and read as 0.
 func (m *Mapper3) ReadPRG(addr uint16) uint8 {
 	if addr >= 0x8000 {
-		offset := (addr - 0x8000) & m.prgMask
-		if int(offset) < len(m.cartridge.PRGROM) {
-			return m.cartridge.PRGROM[offset]
-		}
-		return 0
+		return m.prg.read(addr)
 	}
 	if addr >= 0x6000 {
 		return readPRGRAM(m.cartridge, addr)
@@ -63,6 +56,9 @@ func (m *Mapper3) ReadPRG(addr uint16) uint8 {
 	return 0
 }
 
+// PRGBanks implements PRGBankMapper.
+func (m *Mapper3) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
 // WritePRG writes to PRG space (handles CHR bank switching with bus conflicts)
 func (m *Mapper3) WritePRG(addr uint16, value uint8) {
 	if addr >= 0x8000 {
@@ -118,8 +114,8 @@ func (m *Mapper3) GetCurrentCHRBank() uint8 {
 	return m.chrBank
 }
 
-// IsIRQPending returns false for Mapper3 (no IRQ support)
-func (m *Mapper3) IsIRQPending() bool {
+// IRQLine returns false for Mapper3 (no IRQ support)
+func (m *Mapper3) IRQLine() bool {
 	return false
 }
 
@@ -143,6 +139,11 @@ func (m *Mapper3) SaveState(w io.Writer) error {
 	return binary.Write(w, binary.LittleEndian, m.chrBank)
 }
 
+// AppendState implements StateAppender.
+func (m *Mapper3) AppendState(b []byte) []byte {
+	return append(b, m.chrBank)
+}
+
 // LoadState restores the CHR bank selection.
 func (m *Mapper3) LoadState(r io.Reader) error {
 	return binary.Read(r, binary.LittleEndian, &m.chrBank)
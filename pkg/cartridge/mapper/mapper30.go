@@ -0,0 +1,203 @@
+package mapper
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Mapper30 (UNROM 512) — the homebrew UxROM successor with up to 512 KiB
+// of PRG, 32 KiB of banked CHR RAM and, on boards with the header's
+// battery bit, a flash chip as PRG ROM that the game can rewrite to keep
+// its saves (sstFlash).
+//
+// Bus map:
+//
+//	$8000-$BFFF  switchable 16 KiB PRG bank (flash commands when flashable)
+//	$C000-$FFFF  fixed to last 16 KiB PRG bank, bank register
+//	$0000-$1FFF  switchable 8 KiB CHR RAM bank
+//
+// Bank register (MCCP PPPP; $C000-$FFFF on flashable boards, $8000-$FFFF
+// with bus conflicts on the others):
+//
+//	bits 0-4  PRG bank (16 KiB), also flash address lines A14-A18
+//	bits 5-6  CHR RAM bank (8 KiB)
+//	bit  7    one-screen nametable, when the header asks for that mode
+//
+// The header's mirroring bits read %00 horizontal, %01 vertical,
+// %10 one-screen switched by bit 7, %11 four-screen; the loader passes
+// %10 as MirroringSingleScreenLower.
+type Mapper30 struct {
+	cartridge *CartridgeData
+
+	bank      uint8
+	mirroring MirroringMode
+	flash     *sstFlash // nil on boards without a writable flash
+	prg       prgBankTable
+}
+
+func init() {
+	Register(Board{Name: "UNROM 512", Battery: true, CHRRAM: 32 << 10,
+		New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper30(d) }}, 30)
+}
+
+// NewMapper30 creates a new Mapper30 instance.
+func NewMapper30(data *CartridgeData) *Mapper30 {
+	m := &Mapper30{cartridge: data, mirroring: data.Mirroring}
+	if data.Battery {
+		m.flash = &sstFlash{data: data.PRGROM}
+	}
+	m.updatePRGBanks()
+	return m
+}
+
+// PRGBanks implements PRGBankMapper.
+func (m *Mapper30) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+// updatePRGBanks maps the selected bank at $8000 and the last at $C000.
+// While the flash reports status or IDs every window is left to ReadPRG.
+func (m *Mapper30) updatePRGBanks() {
+	if m.flash != nil && m.flash.busyOrID() {
+		m.prg = prgBankTable{}
+		return
+	}
+	rom := m.cartridge.PRGROM
+	m.prg.set16K(0, rom, int(m.bank&0x1F))
+	m.prg.set16K(2, rom, len(rom)/0x4000-1)
+}
+
+// prgOffset is the PRG ROM (flash) offset the CPU reaches at addr.
+func (m *Mapper30) prgOffset(addr uint16) int {
+	bank := int(m.bank & 0x1F)
+	if addr >= 0xC000 {
+		bank = len(m.cartridge.PRGROM)/0x4000 - 1
+	}
+	if n := len(m.cartridge.PRGROM) / 0x4000; n > 0 {
+		bank %= n
+	}
+	return bank*0x4000 + int(addr&0x3FFF)
+}
+
+// ReadPRG reads from PRG space.
+func (m *Mapper30) ReadPRG(addr uint16) uint8 {
+	if addr >= 0x8000 {
+		if m.flash != nil {
+			return m.flash.read(m.prgOffset(addr))
+		}
+		return m.prg.read(addr)
+	}
+	return readPRGRAM(m.cartridge, addr)
+}
+
+// WritePRG latches the bank register or, on flashable boards, passes
+// $8000-$BFFF writes to the flash.
+func (m *Mapper30) WritePRG(addr uint16, value uint8) {
+	switch {
+	case addr < 0x8000:
+		writePRGRAM(m.cartridge, addr, value)
+	case m.flash == nil:
+		m.bank = value & m.prg.read(addr)
+		m.updatePRGBanks()
+	case addr >= 0xC000:
+		m.bank = value
+		m.updatePRGBanks()
+	default:
+		m.flash.write(m.prgOffset(addr), value)
+		m.updatePRGBanks()
+	}
+}
+
+// chrOffset is the CHR RAM offset of addr in the selected bank.
+func (m *Mapper30) chrOffset(addr uint16) int {
+	off := int(m.bank>>5&3)*0x2000 + int(addr&0x1FFF)
+	if n := len(chrMemory(m.cartridge)); n > 0 {
+		off %= n
+	}
+	return off
+}
+
+// ReadCHR reads through the selected 8 KiB CHR bank.
+func (m *Mapper30) ReadCHR(addr uint16) uint8 {
+	chr := chrMemory(m.cartridge)
+	if len(chr) == 0 {
+		return 0
+	}
+	return chr[m.chrOffset(addr)]
+}
+
+// WriteCHR writes CHR RAM through the selected bank.
+func (m *Mapper30) WriteCHR(addr uint16, value uint8) {
+	writeBankedCHRRAM(m.cartridge, m.chrOffset(addr), value)
+}
+
+// GetMirroringMode implements MirroringSource: the header's mode, or the
+// nametable bit 7 selects when the header asks for one-screen.
+func (m *Mapper30) GetMirroringMode() MirroringMode {
+	if m.mirroring != MirroringSingleScreenLower {
+		return m.mirroring
+	}
+	if m.bank&0x80 != 0 {
+		return MirroringSingleScreenUpper
+	}
+	return MirroringSingleScreenLower
+}
+
+// NonVolatile implements NonVolatileMemory: the flash, which is PRG ROM.
+// Boards without one have nothing to keep.
+func (m *Mapper30) NonVolatile() ([]uint8, string) {
+	if m.flash == nil {
+		return nil, ""
+	}
+	return m.flash.data, "flash"
+}
+
+// TickCPU implements CPUTicker, timing the flash's program and erase.
+func (m *Mapper30) TickCPU(cycles int) {
+	if m.flash != nil && m.flash.busy > 0 {
+		m.flash.tick(cycles)
+		if m.flash.busy <= 0 {
+			m.updatePRGBanks()
+		}
+	}
+}
+
+func (m *Mapper30) Step()         {}
+func (m *Mapper30) IRQLine() bool { return false }
+func (m *Mapper30) ClearIRQ()     {}
+
+type mapper30State struct {
+	Bank  uint8
+	Flash flashState
+}
+
+// SaveState writes the bank register and the flash's command progress.
+// The flash contents are the save file's, not the state's.
+func (m *Mapper30) SaveState(w io.Writer) error {
+	s := mapper30State{Bank: m.bank}
+	if m.flash != nil {
+		s.Flash = m.flash.state()
+	}
+	return binary.Write(w, binary.LittleEndian, s)
+}
+
+// AppendState implements StateAppender.
+func (m *Mapper30) AppendState(b []byte) []byte {
+	var s flashState
+	if m.flash != nil {
+		s = m.flash.state()
+	}
+	return s.appendState(append(b, m.bank))
+}
+
+// LoadState restores the bank register and flash command progress.
+func (m *Mapper30) LoadState(r io.Reader) error {
+	var s mapper30State
+	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
+		return err
+	}
+	m.bank = s.Bank
+	if m.flash != nil {
+		m.flash.setState(s.Flash)
+	}
+	m.updatePRGBanks()
+	return nil
+}
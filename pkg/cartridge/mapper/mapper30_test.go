@@ -0,0 +1,147 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newMapper30 builds a 512 KiB UNROM 512 with each 16 KiB bank tagged in
+// its first byte and 32 KiB of CHR RAM.
+func newMapper30(flash bool, mirroring MirroringMode) *Mapper30 {
+	prg := make([]uint8, 512<<10)
+	for b := 0; b < 32; b++ {
+		prg[b*0x4000] = uint8(b)
+	}
+	return NewMapper30(&CartridgeData{
+		PRGROM:    prg,
+		CHRRAM:    make([]uint8, 32<<10),
+		Battery:   flash,
+		Mirroring: mirroring,
+	})
+}
+
+// flashCommand writes an unlocked command the way homebrew does: the
+// bank register supplies A14 for $5555 and $2AAA.
+func flashCommand(m *Mapper30, cmd uint8) {
+	m.WritePRG(0xC000, 1)
+	m.WritePRG(0x9555, 0xAA)
+	m.WritePRG(0xC000, 0)
+	m.WritePRG(0xAAAA, 0x55)
+	m.WritePRG(0xC000, 1)
+	m.WritePRG(0x9555, cmd)
+}
+
+func TestMapper30Banking(t *testing.T) {
+	m := newMapper30(true, MirroringSingleScreenLower)
+	if m.ReadPRG(0x8000) != 0 || m.ReadPRG(0xC000) != 31 {
+		t.Errorf("power-on: $8000 bank %d, $C000 bank %d", m.ReadPRG(0x8000), m.ReadPRG(0xC000))
+	}
+
+	// MCCP PPPP: PRG 5, CHR 2, upper nametable.
+	m.WritePRG(0xC000, 0x80|2<<5|5)
+	if got := m.ReadPRG(0x8000); got != 5 {
+		t.Errorf("PRG bank = %d, want 5", got)
+	}
+	m.WriteCHR(0x0010, 0x42)
+	if m.cartridge.CHRRAM[2*0x2000+0x10] != 0x42 || m.ReadCHR(0x0010) != 0x42 {
+		t.Error("CHR RAM bank 2 not selected")
+	}
+	if got := m.GetMirroringMode(); got != MirroringSingleScreenUpper {
+		t.Errorf("mirroring = %d, want one-screen upper", got)
+	}
+	if got := newMapper30(true, MirroringVertical).GetMirroringMode(); got != MirroringVertical {
+		t.Errorf("header mirroring = %d, want vertical", got)
+	}
+
+	// Without flash the register is all of $8000-$FFFF, with bus conflicts.
+	m = newMapper30(false, MirroringVertical)
+	m.prg[0][0x0100] = 0x03 // the ROM drives 3 under the write
+	m.WritePRG(0x8100, 0x07)
+	if got := m.ReadPRG(0x8000); got != 3 {
+		t.Errorf("bus-conflicted bank = %d, want 3", got)
+	}
+}
+
+func TestMapper30Flash(t *testing.T) {
+	m := newMapper30(true, MirroringVertical)
+	rom := m.cartridge.PRGROM
+
+	// Sector erase of bank 3's first 4 KiB, then program a byte there.
+	flashCommand(m, 0x80)
+	m.WritePRG(0xC000, 1)
+	m.WritePRG(0x9555, 0xAA)
+	m.WritePRG(0xC000, 0)
+	m.WritePRG(0xAAAA, 0x55)
+	m.WritePRG(0xC000, 3)
+	m.WritePRG(0x8000, 0x30)
+	if rom[3*0x4000] != 0xFF || rom[3*0x4000+0xFFF] != 0xFF || rom[3*0x4000+0x1000] != 0 {
+		t.Error("sector erase didn't clear exactly 4 KiB")
+	}
+	m.TickCPU(flashSectorEraseCycles)
+	flashCommand(m, 0xA0)
+	m.WritePRG(0xC000, 3)
+	m.WritePRG(0x8010, 0x5A)
+
+	// While it programs, reads are status: bit 7 inverted, bit 6 toggling.
+	if m.prg[0] != nil {
+		t.Error("busy flash left in the bank table")
+	}
+	if a, b := m.ReadPRG(0x8010), m.ReadPRG(0x8010); a&0x80 != 0x80 || a&0x40 == b&0x40 {
+		t.Errorf("status reads %02X %02X, want bit 7 set and bit 6 toggling", a, b)
+	}
+	m.TickCPU(flashProgramCycles)
+	if rom[3*0x4000+0x10] != 0x5A || m.ReadPRG(0x8010) != 0x5A || m.prg[0] == nil {
+		t.Errorf("programmed byte = %02X, want 5A", rom[3*0x4000+0x10])
+	}
+	// Programming only clears bits, and a write without unlock is ignored.
+	flashCommand(m, 0xA0)
+	m.WritePRG(0xC000, 3)
+	m.WritePRG(0x8010, 0xF0)
+	m.TickCPU(flashProgramCycles)
+	m.WritePRG(0x8011, 0x00)
+	if rom[3*0x4000+0x10] != 0x50 || rom[3*0x4000+0x11] != 0xFF {
+		t.Errorf("reprogram gave %02X %02X, want 50 FF", rom[3*0x4000+0x10], rom[3*0x4000+0x11])
+	}
+
+	// Software ID: reads leave the bank table for ReadPRG until $F0.
+	flashCommand(m, 0x90)
+	if m.prg[0] != nil || m.ReadPRG(0x8000) != 0xBF || m.ReadPRG(0x8001) != 0xB7 {
+		t.Errorf("ID mode reads %02X %02X", m.ReadPRG(0x8000), m.ReadPRG(0x8001))
+	}
+	m.WritePRG(0x8000, 0xF0)
+	m.WritePRG(0xC000, 3)
+	if m.prg[0] == nil || m.ReadPRG(0x8010) != 0x50 {
+		t.Error("$F0 didn't return to reading the array")
+	}
+
+	// Chip erase.
+	flashCommand(m, 0x80)
+	flashCommand(m, 0x10)
+	m.WritePRG(0x8000, 0x00) // ignored while erasing
+	m.TickCPU(flashChipEraseCycles)
+	if !bytes.Equal(rom, bytes.Repeat([]byte{0xFF}, len(rom))) {
+		t.Error("chip erase left data behind")
+	}
+}
+
+func TestMapper30State(t *testing.T) {
+	m := newMapper30(true, MirroringVertical)
+	m.WritePRG(0xC000, 0x27)
+	flashCommand(m, 0xA0) // leaves a program armed
+	var buf bytes.Buffer
+	if err := m.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.AppendState(nil); !bytes.Equal(got, buf.Bytes()) {
+		t.Errorf("AppendState % X, SaveState % X", got, buf.Bytes())
+	}
+
+	n := newMapper30(true, MirroringVertical)
+	if err := n.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	n.WritePRG(0x8000, 0x00)
+	if n.bank != 1 || n.cartridge.PRGROM[1*0x4000] != 0 {
+		t.Errorf("restored bank %#x, armed program not restored", n.bank)
+	}
+}
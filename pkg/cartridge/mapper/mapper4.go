@@ -9,7 +9,7 @@ package mapper
 //                         outer $E001 switch and delegates IRQ-register
 //                         arms to helpers in mapper4_irq.go.
 //   - mapper4_irq.go    : A12 IRQ notification stub, IRQ register-write
-//                         helpers, and IRQ status API (IsIRQPending /
+//                         helpers, and IRQ status API (IRQLine /
 //                         ClearIRQ).
 
 import (
@@ -43,8 +43,15 @@ type Mapper4 struct {
 	irqPending     bool
 	irqReloadFlag  bool // Set when $C001 is written
 
+	// irqAlt selects the "alternate" (old) IRQ behaviour of the NEC MMC3A,
+	// MMC6 and Acclaim MC-ACC: the IRQ fires only when the counter is
+	// decremented to 0 or reloaded with 0 after a $C001 write, never when
+	// a natural reload from 0 leaves it at 0. Fixed by the board, so it
+	// comes from the submapper and isn't saved with the state.
+	irqAlt bool
+
 	// lastA12High tracks the A12 line state across CPU-driven PPU register
-	// accesses ($2006 second-write, $2007 R/W increments). NotifyA12 reads
+	// accesses ($2006 second-write, $2007 R/W increments). PPUAddressBus reads
 	// this to detect 0→1 transitions; Step() (per-scanline path) resets it
 	// to false so the next CPU-driven A12 high write is treated as a fresh
 	// rising edge rather than being suppressed by a stale "still high".
@@ -61,6 +68,17 @@ type Mapper4 struct {
 	// index this table instead. Rebuilt by recalcCHRBanks at init, on those
 	// writes, and after LoadState.
 	chrWindowOffset [8]uint32
+
+	// prg is the PRG counterpart: the four 8KB windows at $8000-$FFFF,
+	// rebuilt by recalcPRGBanks whenever bankSelect bit 6 or R6/R7 change.
+	prg prgBankTable
+}
+
+func init() {
+	Register(Board{
+		Name: "MMC3/MMC6 (TxROM, HKROM)", Submappers: []uint8{1, 3, 4}, Battery: true, IRQ: true,
+		New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper4(d) },
+	}, 4)
 }
 
 // NewMapper4 creates a new MMC3 mapper instance
@@ -69,6 +87,7 @@ func NewMapper4(data *CartridgeData) *Mapper4 {
 		data:          data,
 		prgBankCount:  uint8(len(data.PRGROM) / 8192), // 8KB banks
 		prgRAMProtect: 0x80,                           // PRG RAM enabled by default
+		irqAlt:        mmc3AltIRQSubmapper(data.Submapper),
 	}
 
 	// Set CHR bank count based on available CHR ROM or RAM
@@ -103,13 +122,14 @@ func NewMapper4(data *CartridgeData) *Mapper4 {
 	logger.LogInfo("CHR RAM initialized: size=%d bytes", len(data.CHRRAM))
 
 	m.recalcCHRBanks()
+	m.recalcPRGBanks()
 
 	return m
 }
 
 // Step is invoked by the PPU at the per-scanline A12-rising-edge tick.
 // Resets lastA12High so a CPU $2006 = $1xxx write between scanlines is
-// recognised as a fresh rising edge by NotifyA12 instead of being
+// recognised as a fresh rising edge by PPUAddressBus instead of being
 // suppressed as "still high".
 func (m *Mapper4) Step() {
 	m.clockIRQ()
@@ -126,11 +146,11 @@ func (m *Mapper4) Step() {
 //   bit 0 = 1    | vertical    | horizontal           | $2000 = $2400 (vert scroll)
 //
 // So we invert the stored MMC3 bit to get the PPU's mirroring encoding.
-func (m *Mapper4) GetMirroringMode() uint8 {
+func (m *Mapper4) GetMirroringMode() MirroringMode {
 	if m.mirroringMode == 0 {
-		return 1 // MMC3 horizontal arrangement -> PPU vertical mirroring
+		return MirroringVertical // MMC3 horizontal arrangement -> PPU vertical mirroring
 	}
-	return 0 // MMC3 vertical arrangement -> PPU horizontal mirroring
+	return MirroringHorizontal // MMC3 vertical arrangement -> PPU horizontal mirroring
 }
 
 // GetBankSelect returns the current bank select register for debugging
@@ -238,6 +258,15 @@ func (m *Mapper4) SaveState(w io.Writer) error {
 	})
 }
 
+// AppendState implements StateAppender.
+func (m *Mapper4) AppendState(b []byte) []byte {
+	b = append(b, m.bankRegisters[:]...)
+	b = append(b, m.bankSelect, m.mirroringMode, m.prgRAMProtect, m.irqReloadValue, m.irqCounter)
+	b = appendBool(b, m.irqEnabled)
+	b = appendBool(b, m.irqPending)
+	return appendBool(b, m.irqReloadFlag)
+}
+
 // LoadState restores MMC3 state written by SaveState.
 func (m *Mapper4) LoadState(r io.Reader) error {
 	var s mapper4State
@@ -253,6 +282,7 @@ func (m *Mapper4) LoadState(r io.Reader) error {
 	m.irqEnabled = s.IrqEnabled
 	m.irqPending = s.IrqPending
 	m.irqReloadFlag = s.IrqReloadFlag
-	m.recalcCHRBanks() // rebuild window table from restored bank registers
+	m.recalcCHRBanks() // rebuild window tables from restored bank registers
+	m.recalcPRGBanks()
 	return nil
 }
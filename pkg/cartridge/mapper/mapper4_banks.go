@@ -10,6 +10,9 @@ import (
 	"github.com/yoshiomiyamaegones/pkg/logger"
 )
 
+// PRGRAMEnabled implements PRGRAMGate: $A001 bit 7 is the chip enable.
+func (m *Mapper4) PRGRAMEnabled() bool { return m.prgRAMProtect&0x80 != 0 }
+
 // ReadPRG reads from PRG ROM/RAM address space
 func (m *Mapper4) ReadPRG(addr uint16) uint8 {
 	switch {
@@ -20,50 +23,26 @@ func (m *Mapper4) ReadPRG(addr uint16) uint8 {
 		}
 		return 0
 
-	case addr >= 0x8000 && addr <= 0xFFFF:
-		// Correct MMC3 PRG ROM banking according to NESdev wiki
-		var bank uint8
-		prgMode := (m.bankSelect >> 6) & 1
-
-		switch {
-		case addr >= 0x8000 && addr <= 0x9FFF:
-			// $8000-$9FFF: R6 in mode 0, second-to-last in mode 1
-			if prgMode == 0 {
-				bank = m.bankRegisters[6]
-			} else {
-				bank = m.prgBankCount - 2 // second-to-last bank
-			}
-
-		case addr >= 0xA000 && addr <= 0xBFFF:
-			// $A000-$BFFF: Always R7
-			bank = m.bankRegisters[7]
+	case addr >= 0x8000:
+		return m.prg.read(addr)
+	}
 
-		case addr >= 0xC000 && addr <= 0xDFFF:
-			// $C000-$DFFF: second-to-last in mode 0, R6 in mode 1
-			if prgMode == 0 {
-				bank = m.prgBankCount - 2 // second-to-last bank
-			} else {
-				bank = m.bankRegisters[6]
-			}
+	return 0
+}
 
-		case addr >= 0xE000 && addr <= 0xFFFF:
-			// $E000-$FFFF: Always last bank (this is crucial for reset vector)
-			bank = m.prgBankCount - 1
-		}
+// PRGBanks implements PRGBankMapper.
+func (m *Mapper4) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
 
-		// Ensure bank is in valid range
+// recalcPRGBanks rebuilds the $8000-$FFFF window table (see
+// GetCurrentPRGBanks for the mode 0/1 layout). Out-of-range bank numbers
+// clamp to the last bank. Call after any change to bankSelect or R6/R7.
+func (m *Mapper4) recalcPRGBanks() {
+	for w, bank := range m.GetCurrentPRGBanks() {
 		if bank >= m.prgBankCount {
 			bank = m.prgBankCount - 1
 		}
-
-		// Calculate offset within PRG ROM (8KB banks)
-		offset := uint32(bank)*0x2000 + uint32(addr&0x1FFF)
-		if offset < uint32(len(m.data.PRGROM)) {
-			return m.data.PRGROM[offset]
-		}
+		m.prg.set(w, m.data.PRGROM, int(bank))
 	}
-
-	return 0
 }
 
 // WritePRG writes to PRG ROM/RAM address space or mapper registers.
@@ -84,6 +63,7 @@ func (m *Mapper4) WritePRG(addr uint16, value uint8) {
 			logger.LogMapper("MMC3 bank select set: %d", value)
 			m.bankSelect = value
 			m.recalcCHRBanks() // CHR mode bit 7 may have flipped
+			m.recalcPRGBanks() // ...and PRG mode bit 6
 
 		case 0x8001: // Bank data ($8001-$9FFF, odd)
 			regIndex := m.bankSelect & 0x07
@@ -101,7 +81,8 @@ func (m *Mapper4) WritePRG(addr uint16, value uint8) {
 					}
 				}
 			}
-			m.recalcCHRBanks() // refresh windows for the just-written CHR reg
+			m.recalcCHRBanks() // refresh windows for the just-written reg
+			m.recalcPRGBanks()
 
 		case 0xA000: // Mirroring ($A000-$BFFE, even)
 			m.mirroringMode = value & 1
@@ -2,14 +2,23 @@ package mapper
 
 // This file hosts the MMC3 IRQ subsystem: the A12 rising-edge notification
 // path, the IRQ register-write handlers ($C000/$C001/$E000/$E001), and the
-// public IRQ status API (IsIRQPending / ClearIRQ). The IRQ counter is
+// public IRQ status API (IRQLine / ClearIRQ). The IRQ counter is
 // clocked by:
-//   - Step() at PPU cycle 260 of each rendering-active scanline (covers
-//     the BG→sprite A12 rising edge in the common BG=$0000/Sprites=$1000
-//     layout); and
-//   - NotifyA12 on CPU-driven $2006/$2007 accesses that flip A12 from 0→1
+//   - Step() for each A12 rise the PPU's rendering fetches make that
+//     gets past the M2 filter — normally one per scanline, at the first
+//     sprite fetch (BG=$0000/Sprites=$1000) or the BG prefetch (the
+//     reverse), but none or two with some 8×16 sprite layouts; and
+//   - PPUAddressBus on CPU-driven $2006/$2007 accesses that flip A12 from 0→1
 //     (blargg's mmc3_test suite drives the counter exclusively through this
 //     path with rendering disabled).
+//
+// Two IRQ behaviours exist (NESdev "MMC3: IRQ Specifics"). The Sharp
+// MMC3B/MMC3C "normal" one, the default, raises the IRQ whenever the
+// counter is 0 after a clock, so a latch of 0 fires on every scanline.
+// The NEC MMC3A, MMC6 and Acclaim MC-ACC "alternate" one (irqAlt) only
+// fires when the counter goes from nonzero to 0 or is reloaded after a
+// $C001 write; a latch of 0 then fires once, after $C001, and not again.
+// blargg's 6-MMC6 and mmc3_irq_tests' rev A ROMs test the alternate one.
 
 import (
 	"github.com/yoshiomiyamaegones/pkg/logger"
@@ -43,46 +52,66 @@ func (m *Mapper4) writeIRQEnable() {
 	logger.LogMapper("MMC3 IRQ enabled")
 }
 
+// mmc3AltIRQSubmapper reports whether NES 2.0 mapper 4 submapper sub has
+// the alternate IRQ behaviour: 1 (MMC6), 3 (MC-ACC) and 4 (MMC3A). The
+// MC-ACC also clocks on A12 falling edges, a few PPU dots later than the
+// MMC3; that timing difference isn't modelled.
+func mmc3AltIRQSubmapper(sub uint8) bool {
+	return sub == 1 || sub == 3 || sub == 4
+}
+
 // clockIRQ is the shared counter-tick used by both the per-scanline Step()
 // path and the CPU-driven A12 rising-edge path. It implements the canonical
 // MMC3 reload/decrement sequence: on reload-flag or zero, refill from the
-// latch (without firing IRQ from that refill alone); otherwise decrement;
-// fire IRQ when the post-tick counter is zero and IRQ is enabled.
+// latch; otherwise decrement. The IRQ fires when the post-tick counter is
+// zero and IRQ is enabled — except that the alternate behaviour doesn't
+// fire on a natural refill from zero (counter was 0, no $C001 reload).
 func (m *Mapper4) clockIRQ() {
+	wasZero, forced := m.irqCounter == 0, m.irqReloadFlag
 	if m.irqReloadFlag || m.irqCounter == 0 {
 		m.irqCounter = m.irqReloadValue
 		m.irqReloadFlag = false
 	} else {
 		m.irqCounter--
 	}
+	if m.irqAlt && wasZero && !forced {
+		return
+	}
 	if m.irqCounter == 0 && m.irqEnabled {
 		m.irqPending = true
 	}
 }
 
-// NotifyA12 is called by the PPU on CPU-driven accesses that change the v
-// register's A12 bit ($2006 second write, $2007 increment after R/W). The
+// PPUAddressBus is called by the PPU on CPU-driven accesses that put an
+// address on its bus ($2006 second write, $2007 R/W and increment). The
 // per-scanline rendering path uses Step() instead — the two never both fire
 // for the same rising edge because rendering doesn't touch the CPU-side
 // lastA12High tracker, and Step() resets it to false so the next CPU-driven
 // $2006 = $1xxx write is correctly recognised as a fresh rising edge.
-func (m *Mapper4) NotifyA12(chrAddr uint16, renderingEnabled bool) {
-	_ = renderingEnabled
-	newA12 := (chrAddr & 0x1000) != 0
+func (m *Mapper4) PPUAddressBus(addr uint16) {
+	newA12 := (addr & 0x1000) != 0
 	if newA12 && !m.lastA12High {
 		m.clockIRQ()
 	}
 	m.lastA12High = newA12
 }
 
-// IsIRQPending returns true if an IRQ is pending
-func (m *Mapper4) IsIRQPending() bool {
+// IRQLine returns true if an IRQ is pending
+func (m *Mapper4) IRQLine() bool {
 	return m.irqPending
 }
 
 // IRQCapable marks MMC3 as an IRQ-asserting mapper (scanline counter).
 func (m *Mapper4) IRQCapable() {}
 
+// Reset implements Resetter: the IRQ counter, latch and enable power up
+// clear, so a power cycle with the cartridge still in leaves no stale IRQ.
+func (m *Mapper4) Reset() {
+	m.irqCounter, m.irqReloadValue = 0, 0
+	m.irqEnabled, m.irqPending, m.irqReloadFlag = false, false, false
+	m.lastA12High = false
+}
+
 // ClearIRQ clears the pending IRQ
 func (m *Mapper4) ClearIRQ() {
 	m.irqPending = false
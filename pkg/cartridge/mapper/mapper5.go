@@ -75,6 +75,10 @@ type Mapper5 struct {
 	sprite8x16 bool
 }
 
+func init() {
+	Register(Board{Name: "MMC5 (ExROM)", Battery: true, IRQ: true, New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper5(d) }}, 5)
+}
+
 // NewMapper5 constructs a fresh MMC5 around the cartridge's PRG/CHR
 // ROM. PRG RAM is allocated by the cartridge layer.
 func NewMapper5(data *CartridgeData) *Mapper5 {
@@ -403,12 +407,6 @@ func (m *Mapper5) Step() {}
 // window to the mapper instead of falling back to open bus.
 func (m *Mapper5) DecodesExpansion() {}
 
-// NotifyA12 — MMC5 doesn't drive its scanline counter from A12 (games
-// like Metal Slader Glory share a single CHR pattern table between
-// BG and sprites and never flip A12 during normal rendering). The
-// per-scanline tick comes from NotifyScanline instead.
-func (m *Mapper5) NotifyA12(chrAddr uint16, renderingEnabled bool) {}
-
 // NotifyScanline drives the MMC5 scanline counter. The "in-frame"
 // flag rises on scanline 0 and falls when the post-render scanline
 // fires (scanline 240 isn't a render line, so the next notify after
@@ -430,16 +428,24 @@ func (m *Mapper5) NotifyScanline(scanline int, renderingEnabled bool) {
 	}
 }
 
-// IsIRQPending reports the latched IRQ status. MMC5 only asserts the
+// IRQLine reports the latched IRQ status. MMC5 only asserts the
 // CPU IRQ line when both the per-scanline match flag and the enable
 // bit ($5204 bit 7) are set.
-func (m *Mapper5) IsIRQPending() bool {
+func (m *Mapper5) IRQLine() bool {
 	return m.irqPending && m.irqEnable
 }
 
 // IRQCapable marks MMC5 as an IRQ-asserting mapper (scanline-match IRQ).
 func (m *Mapper5) IRQCapable() {}
 
+// Reset implements Resetter. The in-frame flag would clear on its own
+// once the PPU stops rendering; a power cycle clears it straight away,
+// along with the scanline counter and the IRQ enable and status.
+func (m *Mapper5) Reset() {
+	m.inFrame, m.scanline = false, 0
+	m.irqEnable, m.irqPending = false, false
+}
+
 // ClearIRQ is a no-op for MMC5 — the IRQ status is consumed via $5204
 // reads, which the CPU does explicitly to ack the interrupt.
 func (m *Mapper5) ClearIRQ() {}
@@ -448,22 +454,22 @@ func (m *Mapper5) ClearIRQ() {}
 // 2 bits each) onto the PPU's four scheme codes. Mixed configurations using
 // ExRAM or Fill as a nametable can't be expressed in those codes, so they
 // fall back to vertical — proper support needs per-NT routing in the PPU.
-func (m *Mapper5) GetMirroringMode() uint8 {
+func (m *Mapper5) GetMirroringMode() MirroringMode {
 	nt0 := m.ntMapping & 0x03
 	nt1 := (m.ntMapping >> 2) & 0x03
 	nt2 := (m.ntMapping >> 4) & 0x03
 	nt3 := (m.ntMapping >> 6) & 0x03
 	switch {
 	case nt0 == 0 && nt1 == 0 && nt2 == 1 && nt3 == 1:
-		return 0 // horizontal ($2000/$2400 → NT0, $2800/$2C00 → NT1)
+		return MirroringHorizontal // ($2000/$2400 → NT0, $2800/$2C00 → NT1)
 	case nt0 == 0 && nt1 == 1 && nt2 == 0 && nt3 == 1:
-		return 1 // vertical ($2000/$2800 → NT0, $2400/$2C00 → NT1)
+		return MirroringVertical // ($2000/$2800 → NT0, $2400/$2C00 → NT1)
 	case nt0 == 0 && nt1 == 0 && nt2 == 0 && nt3 == 0:
-		return 2 // single-screen lower
+		return MirroringSingleScreenLower
 	case nt0 == 1 && nt1 == 1 && nt2 == 1 && nt3 == 1:
-		return 3 // single-screen upper
+		return MirroringSingleScreenUpper
 	}
-	return 1 // default approximation
+	return MirroringVertical // default approximation
 }
 
 type mapper5State struct {
@@ -493,6 +499,21 @@ func (m *Mapper5) SaveState(w io.Writer) error {
 	})
 }
 
+// AppendState implements StateAppender.
+func (m *Mapper5) AppendState(b []byte) []byte {
+	b = append(b, m.prgMode, m.chrMode, m.prgRAMW1, m.prgRAMW2, m.exRAMMode, m.ntMapping,
+		m.fillTile, m.fillAttrib, m.chrHigh)
+	b = append(b, m.prgBanks[:]...)
+	b = appendUint16s(b, m.chrA[:])
+	b = appendUint16s(b, m.chrB[:])
+	b = append(b, m.irqTarget)
+	b = appendBool(b, m.irqEnable)
+	b = appendBool(b, m.irqPending)
+	b = appendBool(b, m.inFrame)
+	b = append(b, m.scanline, m.multA, m.multB)
+	return append(b, m.exRAM[:]...)
+}
+
 func (m *Mapper5) LoadState(r io.Reader) error {
 	var s mapper5State
 	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
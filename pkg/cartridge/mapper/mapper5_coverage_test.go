@@ -152,12 +152,12 @@ func TestMapper5IRQAndMisc(t *testing.T) {
 	m.WritePRG(0x5204, 0x80) // IRQ enable
 
 	// Drive scanlines past the target; the scanline-match latch must fire and,
-	// with the enable bit set, surface through IsIRQPending.
+	// with the enable bit set, surface through IRQLine.
 	for s := 0; s < 20; s++ {
 		m.NotifyScanline(s, true)
 		m.Step()
 	}
-	if !m.IsIRQPending() {
+	if !m.IRQLine() {
 		t.Error("MMC5 IRQ should be pending after the scanline counter reaches the target")
 	}
 	// Disabling rendering drops the in-frame flag (rendering-off branch).
@@ -165,7 +165,6 @@ func TestMapper5IRQAndMisc(t *testing.T) {
 	_ = m.GetMirroringMode()
 
 	m.DecodesExpansion()
-	m.NotifyA12(0x1000, true)
 	m.ClearIRQ()
 	m.IRQCapable()
 
@@ -23,9 +23,9 @@ import (
 //	$8000-$9FFF write: command (low 4 bits) — selects which internal
 //	                   register the next $A000-$BFFF write targets
 //	$A000-$BFFF write: data for the selected command
-//	$C000-$DFFF write: audio register select (not implemented — only
-//	                   Gimmick! uses the expansion sound channels)
-//	$E000-$FFFF write: audio register data (ditto)
+//	$C000-$DFFF write: Sunsoft 5B audio register select
+//	$E000-$FFFF write: audio register data (see mapper69_audio.go;
+//	                   only Gimmick! uses the expansion channels)
 //
 // Mirroring is mapper-controlled (reg C). The 16-bit IRQ counter
 // (regs D-F) decrements every CPU cycle when enabled and fires on
@@ -36,15 +36,15 @@ type Mapper69 struct {
 
 	command uint8 // last $8000-write selected register
 
-	prgRAMSelect uint8 // raw value written to reg 8 (RAM-enable + bank)
+	prgRAMSelect uint8    // raw value written to reg 8 (RAM-enable + bank)
 	prgBanks     [3]uint8 // regs 9, A, B → $8000/$A000/$C000
 	chrBanks     [8]uint8 // regs 0-7 → $0000/$0400/.../$1C00
 
 	mirroring uint8
 
-	irqControl    uint8
-	irqCounter    uint16
-	irqPending    bool
+	irqControl uint8
+	irqCounter uint16
+	irqPending bool
 
 	audio fme7Audio
 
@@ -52,14 +52,19 @@ type Mapper69 struct {
 	chrBankCount uint16
 }
 
+func init() {
+	Register(Board{
+		Name: "Sunsoft FME-7/5B", Battery: true, Audio: true, IRQ: true,
+		New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper69(d) },
+	}, 69)
+}
+
 func NewMapper69(data *CartridgeData) *Mapper69 {
 	m := &Mapper69{cartridge: data}
 	if len(data.PRGROM) > 0 {
 		m.prgBankCount = uint8(len(data.PRGROM) / 8192)
 	}
-	if len(data.CHRROM) > 0 {
-		m.chrBankCount = uint16(len(data.CHRROM) / 1024)
-	}
+	m.chrBankCount = uint16(len(chrMemory(data)) / 1024)
 	// $E000-$FFFF is hardware-fixed to the last PRG bank, so the reset
 	// vector is reachable before the game programs any of regs 9/A/B.
 	return m
@@ -141,20 +146,24 @@ func (m *Mapper69) writeParam(value uint8) {
 }
 
 func (m *Mapper69) ReadCHR(addr uint16) uint8 {
-	if m.chrBankCount == 0 {
-		return readCHRROMOrRAM(m.cartridge, addr)
-	}
-	region := (addr >> 10) & 0x07
-	bank := uint16(m.chrBanks[region]) % m.chrBankCount
-	finalAddr := uint32(bank)*1024 + uint32(addr&0x3FF)
-	if int(finalAddr) < len(m.cartridge.CHRROM) {
-		return m.cartridge.CHRROM[finalAddr]
+	if offset := m.chrOffset(addr); offset >= 0 {
+		return chrMemory(m.cartridge)[offset]
 	}
 	return 0
 }
 
+// chrOffset maps addr through its 1KB bank register onto chrMemory; -1
+// when there is no CHR memory.
+func (m *Mapper69) chrOffset(addr uint16) int {
+	if m.chrBankCount == 0 || addr >= 0x2000 {
+		return -1
+	}
+	bank := uint16(m.chrBanks[(addr>>10)&0x07]) % m.chrBankCount
+	return int(bank)*1024 + int(addr&0x3FF)
+}
+
 func (m *Mapper69) WriteCHR(addr uint16, value uint8) {
-	writeCHRRAM(m.cartridge, addr, value)
+	writeBankedCHRRAM(m.cartridge, m.chrOffset(addr), value)
 }
 
 func (m *Mapper69) Step() {}
@@ -186,25 +195,34 @@ func (m *Mapper69) AudioSample() float32 {
 	return m.audio.sample()
 }
 
-func (m *Mapper69) IsIRQPending() bool { return m.irqPending }
-func (m *Mapper69) ClearIRQ()          { m.irqPending = false }
+// AudioChip names the sound chip for the mixer's per-chip levels
+// (apu.ChipFME7).
+func (m *Mapper69) AudioChip() string { return "5b" }
+
+func (m *Mapper69) IRQLine() bool { return m.irqPending }
+func (m *Mapper69) ClearIRQ()     { m.irqPending = false }
 
 // IRQCapable marks FME-7 as an IRQ-asserting mapper (CPU-clock counter).
 func (m *Mapper69) IRQCapable() {}
 
+// Reset implements Resetter: counting and IRQ generation power up off.
+func (m *Mapper69) Reset() {
+	m.irqControl, m.irqPending = 0, false
+}
+
 // GetMirroringMode reports the current mapper-controlled mirroring in
 // the PPU's encoding (0=horizontal, 1=vertical, 2/3=single-screen
 // lower/upper). FME-7 reg-12 codes: 0=vert, 1=horiz, 2=lower, 3=upper.
-func (m *Mapper69) GetMirroringMode() uint8 {
+func (m *Mapper69) GetMirroringMode() MirroringMode {
 	switch m.mirroring {
 	case 0:
-		return 1 // vertical
+		return MirroringVertical
 	case 1:
-		return 0 // horizontal
+		return MirroringHorizontal
 	case 2:
-		return 2 // single-screen lower
+		return MirroringSingleScreenLower
 	default:
-		return 3 // single-screen upper
+		return MirroringSingleScreenUpper
 	}
 }
 
@@ -214,6 +232,7 @@ type mapper69State struct {
 	CHRBanks                                     [8]uint8
 	IRQCounter                                   uint16
 	IRQPending                                   bool
+	Audio                                        fme7AudioState
 }
 
 func (m *Mapper69) SaveState(w io.Writer) error {
@@ -226,9 +245,20 @@ func (m *Mapper69) SaveState(w io.Writer) error {
 		CHRBanks:     m.chrBanks,
 		IRQCounter:   m.irqCounter,
 		IRQPending:   m.irqPending,
+		Audio:        m.audio.state(),
 	})
 }
 
+// AppendState implements StateAppender.
+func (m *Mapper69) AppendState(b []byte) []byte {
+	b = append(b, m.command, m.prgRAMSelect, m.mirroring, m.irqControl)
+	b = append(b, m.prgBanks[:]...)
+	b = append(b, m.chrBanks[:]...)
+	b = binary.LittleEndian.AppendUint16(b, m.irqCounter)
+	b = appendBool(b, m.irqPending)
+	return m.audio.appendState(b)
+}
+
 func (m *Mapper69) LoadState(r io.Reader) error {
 	var s mapper69State
 	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
@@ -242,5 +272,6 @@ func (m *Mapper69) LoadState(r io.Reader) error {
 	m.chrBanks = s.CHRBanks
 	m.irqCounter = s.IRQCounter
 	m.irqPending = s.IRQPending
+	m.audio.restore(s.Audio)
 	return nil
 }
@@ -1,5 +1,7 @@
 package mapper
 
+import "encoding/binary"
+
 // FME-7 / Sunsoft 5B expansion audio: three square-wave channels and a
 // shared envelope generator driven by the YM2149-style register file
 // at the back of the bus map.
@@ -115,6 +117,54 @@ func (a *fme7Audio) writeAudioData(value uint8) {
 	}
 }
 
+// fme7AudioState is the save-state image of the 5B: the register file
+// plus the envelope position, which a replay of R13 would otherwise
+// re-arm. Tone phase counters are not kept; restarting them costs at
+// most one square-wave period.
+type fme7AudioState struct {
+	RegSelect  uint8
+	Regs       [16]uint8
+	EnvCounter uint32
+	EnvStep    uint8
+	EnvOutput  uint8
+	EnvHolding bool
+}
+
+func (a *fme7Audio) state() fme7AudioState {
+	return fme7AudioState{
+		RegSelect:  a.regSelect,
+		Regs:       a.regs,
+		EnvCounter: a.envelope.counter,
+		EnvStep:    a.envelope.step,
+		EnvOutput:  a.envelope.output,
+		EnvHolding: a.envelope.holding,
+	}
+}
+
+// appendState appends what state() encodes, without building it.
+func (a *fme7Audio) appendState(b []byte) []byte {
+	b = append(b, a.regSelect)
+	b = append(b, a.regs[:]...)
+	b = binary.LittleEndian.AppendUint32(b, a.envelope.counter)
+	b = append(b, a.envelope.step, a.envelope.output)
+	return appendBool(b, a.envelope.holding)
+}
+
+// restore rebuilds the channels by replaying the register file, then puts
+// the envelope back where it was.
+func (a *fme7Audio) restore(s fme7AudioState) {
+	*a = fme7Audio{}
+	for r, v := range s.Regs {
+		a.writeAudioSelect(uint8(r))
+		a.writeAudioData(v)
+	}
+	a.regSelect = s.RegSelect
+	a.envelope.counter = s.EnvCounter
+	a.envelope.step = s.EnvStep
+	a.envelope.output = s.EnvOutput
+	a.envelope.holding = s.EnvHolding
+}
+
 // tick advances the three tone-generator phase counters and the
 // shared envelope unit by `cycles` CPU cycles. The 5B / YM2149
 // divides the input clock by 16 before counting against `period`.
@@ -0,0 +1,148 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fme7Write issues one command/parameter register pair.
+func fme7Write(m *Mapper69, command, value uint8) {
+	m.WritePRG(0x8000, command)
+	m.WritePRG(0xA000, value)
+}
+
+// armFME7IRQ loads the counter and enables counting and IRQ generation.
+func armFME7IRQ(m *Mapper69, counter uint16) {
+	fme7Write(m, 14, uint8(counter))
+	fme7Write(m, 15, uint8(counter>>8))
+	fme7Write(m, 13, 0x81)
+}
+
+// The counter decrements once per CPU cycle and the IRQ fires on the
+// $0000 -> $FFFF underflow, i.e. counter+1 cycles after it is armed,
+// however TickCPU batches the cycles.
+func TestMapper69IRQCycleCount(t *testing.T) {
+	m := NewMapper69(makeData(2, 2))
+	armFME7IRQ(m, 300)
+	cycles := 0
+	for !m.IRQLine() && cycles < 1000 {
+		m.TickCPU(1)
+		cycles++
+	}
+	if cycles != 301 {
+		t.Errorf("IRQ after %d cycles, want 301", cycles)
+	}
+
+	for _, batch := range []int{2, 3, 7} {
+		m := NewMapper69(makeData(2, 2))
+		armFME7IRQ(m, 300)
+		done := 0
+		for done+batch <= 300 {
+			m.TickCPU(batch)
+			done += batch
+		}
+		m.TickCPU(300 - done)
+		if m.IRQLine() {
+			t.Errorf("batch %d: IRQ after 300 cycles, one early", batch)
+		}
+		m.TickCPU(1)
+		if !m.IRQLine() {
+			t.Errorf("batch %d: no IRQ after 301 cycles", batch)
+		}
+		if m.irqCounter != 0xFFFF {
+			t.Errorf("batch %d: counter after underflow = $%04X, want $FFFF", batch, m.irqCounter)
+		}
+	}
+}
+
+func TestMapper69IRQControl(t *testing.T) {
+	m := NewMapper69(makeData(2, 2))
+	fme7Write(m, 14, 0x05)
+	fme7Write(m, 15, 0x00)
+
+	// Counter disabled: no decrement at all.
+	m.TickCPU(100)
+	if m.irqCounter != 5 || m.IRQLine() {
+		t.Fatalf("disabled counter moved: $%04X pending=%v", m.irqCounter, m.IRQLine())
+	}
+
+	// Counting without IRQ generation wraps silently.
+	fme7Write(m, 13, 0x80)
+	m.TickCPU(10)
+	if m.irqCounter != 0xFFFB || m.IRQLine() {
+		t.Errorf("count-only mode: counter $%04X pending=%v, want $FFFB and no IRQ", m.irqCounter, m.IRQLine())
+	}
+
+	// Any reg 13 write acknowledges a pending IRQ.
+	armFME7IRQ(m, 0)
+	m.TickCPU(1)
+	if !m.IRQLine() {
+		t.Fatal("underflow from $0000 with IRQ enabled should fire")
+	}
+	fme7Write(m, 13, 0x81)
+	if m.IRQLine() {
+		t.Error("reg 13 write should acknowledge the IRQ")
+	}
+}
+
+func TestMapper69Banking(t *testing.T) {
+	m := NewMapper69(makeData(4, 8)) // 8 × 8KB PRG, 32 × 1KB CHR
+
+	// $E000 is fixed to the last 8KB bank; odd 8KB banks are untagged.
+	fme7Write(m, 9, 2)  // $8000 -> 8KB bank 2 (16KB bank 1)
+	fme7Write(m, 10, 4) // $A000 -> 8KB bank 4
+	fme7Write(m, 11, 6) // $C000 -> 8KB bank 6
+	for addr, want := range map[uint16]uint8{0x8000: 0xA1, 0xA000: 0xA2, 0xC000: 0xA3, 0xE000: 0x00} {
+		if got := m.ReadPRG(addr); got != want {
+			t.Errorf("$%04X = %#02x, want %#02x", addr, got, want)
+		}
+	}
+
+	// $6000: ROM bank 0 when enabled without the RAM bit, RAM with it.
+	fme7Write(m, 8, 0x80)
+	if got := m.ReadPRG(0x6000); got != 0xA0 {
+		t.Errorf("$6000 as ROM = %#02x, want 0xA0", got)
+	}
+	fme7Write(m, 8, 0xC0)
+	m.WritePRG(0x6000, 0x5A)
+	if got := m.ReadPRG(0x6000); got != 0x5A {
+		t.Errorf("$6000 as RAM = %#02x, want 0x5A", got)
+	}
+
+	// 1KB CHR: 4KB bank n is tagged at 1KB bank 4n.
+	fme7Write(m, 5, 12) // $1400 -> 1KB bank 12 (4KB bank 3)
+	if got := m.ReadCHR(0x1400); got != 0xC3 {
+		t.Errorf("$1400 = %#02x, want 0xC3", got)
+	}
+
+	fme7Write(m, 12, 3)
+	if m.GetMirroringMode() != 3 {
+		t.Errorf("mirroring 3 -> %d, want 3 (single-screen upper)", m.GetMirroringMode())
+	}
+}
+
+func TestMapper69StateKeepsAudio(t *testing.T) {
+	m := NewMapper69(makeData(2, 2))
+	m.WritePRG(0xC000, 0)
+	m.WritePRG(0xE000, 0x40) // channel A period
+	m.WritePRG(0xC000, 8)
+	m.WritePRG(0xE000, 0x0F) // channel A volume 15
+	m.WritePRG(0xC000, 7)
+	m.WritePRG(0xE000, 0x3E) // tone A only
+	m.TickCPU(5000)
+
+	var buf bytes.Buffer
+	if err := m.SaveState(&buf); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	m2 := NewMapper69(makeData(2, 2))
+	if err := m2.LoadState(&buf); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if m2.audio.regs != m.audio.regs || m2.audio.regSelect != 7 {
+		t.Errorf("audio registers not restored: %v select %d", m2.audio.regs, m2.audio.regSelect)
+	}
+	if !m2.audio.channels[0].enable || m2.audio.channels[0].period != 0x40 || m2.audio.channels[0].volume != 15 {
+		t.Errorf("channel A not rebuilt: %+v", m2.audio.channels[0])
+	}
+}
@@ -31,6 +31,10 @@ type Mapper70 struct {
 	chrBankCount uint8
 }
 
+func init() {
+	Register(Board{Name: "Bandai 74161", New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper70(d) }}, 70)
+}
+
 // NewMapper70 creates a new Mapper70 instance.
 func NewMapper70(data *CartridgeData) *Mapper70 {
 	m := &Mapper70{cartridge: data}
@@ -95,14 +99,19 @@ func (m *Mapper70) WriteCHR(addr uint16, value uint8) {
 	writeCHRRAM(m.cartridge, addr, value)
 }
 
-func (m *Mapper70) Step()              {}
-func (m *Mapper70) IsIRQPending() bool { return false }
-func (m *Mapper70) ClearIRQ()          {}
+func (m *Mapper70) Step()         {}
+func (m *Mapper70) IRQLine() bool { return false }
+func (m *Mapper70) ClearIRQ()     {}
 
 func (m *Mapper70) SaveState(w io.Writer) error {
 	return binary.Write(w, binary.LittleEndian, [2]uint8{m.prgBank, m.chrBank})
 }
 
+// AppendState implements StateAppender.
+func (m *Mapper70) AppendState(b []byte) []byte {
+	return append(b, m.prgBank, m.chrBank)
+}
+
 func (m *Mapper70) LoadState(r io.Reader) error {
 	var b [2]uint8
 	if err := binary.Read(r, binary.LittleEndian, &b); err != nil {
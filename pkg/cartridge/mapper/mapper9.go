@@ -0,0 +1,224 @@
+package mapper
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Mapper9 implements MMC2 (PxROM), the board of Mike Tyson's Punch-Out!!
+// and Punch-Out!!. Its CHR side is MMC4's latch scheme (see Mapper10):
+// two 4KB banks per pattern table, chosen by a latch the PPU flips by
+// fetching tile $FD or $FE, which is how Punch-Out!! draws the large
+// opponent sprites and the ring from different CHR mid-frame. The PRG side
+// is finer: one switchable 8KB bank and the last three 8KB banks fixed.
+//
+// Registers (write):
+//
+//	$A000-$AFFF: PRG bank (8KB at $8000); $A000-$FFFF fixed to the last 24KB
+//	$B000-$BFFF: CHR bank for $0000-$0FFF when latch0 == $FD
+//	$C000-$CFFF: CHR bank for $0000-$0FFF when latch0 == $FE
+//	$D000-$DFFF: CHR bank for $1000-$1FFF when latch1 == $FD
+//	$E000-$EFFF: CHR bank for $1000-$1FFF when latch1 == $FE
+//	$F000-$FFFF: Mirroring (bit 0: 0=vertical, 1=horizontal)
+//
+// Latch transitions differ from MMC4 for the low pattern table, which
+// triggers on the exact addresses $0FD8 / $0FE8 only (NESdev "MMC2"):
+//
+//	$0FD8        -> latch0 = $FD
+//	$0FE8        -> latch0 = $FE
+//	$1FD8-$1FDF  -> latch1 = $FD
+//	$1FE8-$1FEF  -> latch1 = $FE
+type Mapper9 struct {
+	cartridge *CartridgeData
+
+	prgBank uint8        // lower 4 bits select the 8KB bank at $8000
+	prg     prgBankTable // $8000-$FFFF windows, rebuilt by updatePRGBanks
+
+	chrBank0FD   uint8
+	chrBank0FE   uint8
+	chrBank1FD   uint8
+	chrBank1FE   uint8
+	chrBankCount uint8
+
+	latch0 uint8 // $FD or $FE — controls $0000-$0FFF bank selection
+	latch1 uint8 // $FD or $FE — controls $1000-$1FFF bank selection
+
+	mirroring uint8 // raw MMC2 bit (0=vertical, 1=horizontal)
+}
+
+func init() {
+	Register(Board{Name: "MMC2 (PxROM)", New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper9(d) }}, 9)
+}
+
+// NewMapper9 creates a new MMC2 mapper.
+func NewMapper9(data *CartridgeData) *Mapper9 {
+	m := &Mapper9{
+		cartridge: data,
+		// Power-on latch state is unspecified; start both at $FE like
+		// Mapper10. Punch-Out!! sets up its banks before enabling rendering.
+		latch0: 0xFE,
+		latch1: 0xFE,
+	}
+	m.chrBankCount = uint8(len(chrMemory(data)) / 4096)
+	m.updatePRGBanks()
+	return m
+}
+
+// PRGBanks implements PRGBankMapper.
+func (m *Mapper9) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+// updatePRGBanks maps the selected bank at $8000 and the last three 8KB
+// banks at $A000-$FFFF.
+func (m *Mapper9) updatePRGBanks() {
+	rom := m.cartridge.PRGROM
+	m.prg.set(0, rom, int(m.prgBank))
+	m.prg.set(1, rom, -3)
+	m.prg.set(2, rom, -2)
+	m.prg.set(3, rom, -1)
+}
+
+// ReadPRG reads from PRG space. $6000-$7FFF is PRG RAM if present (only
+// the PlayChoice-10 version of Punch-Out!! has it).
+func (m *Mapper9) ReadPRG(addr uint16) uint8 {
+	if addr >= 0x8000 {
+		return m.prg.read(addr)
+	}
+	return readPRGRAM(m.cartridge, addr)
+}
+
+// WritePRG dispatches register writes ($A000-$FFFF) and handles PRG RAM
+// writes ($6000-$7FFF). $8000-$9FFF has no register.
+func (m *Mapper9) WritePRG(addr uint16, value uint8) {
+	switch {
+	case addr >= 0xF000:
+		m.mirroring = value & 1
+	case addr >= 0xE000:
+		m.chrBank1FE = value & 0x1F
+	case addr >= 0xD000:
+		m.chrBank1FD = value & 0x1F
+	case addr >= 0xC000:
+		m.chrBank0FE = value & 0x1F
+	case addr >= 0xB000:
+		m.chrBank0FD = value & 0x1F
+	case addr >= 0xA000:
+		m.prgBank = value & 0x0F
+		m.updatePRGBanks()
+	case addr >= 0x6000 && addr < 0x8000:
+		writePRGRAM(m.cartridge, addr, value)
+	}
+}
+
+// ReadCHR returns a CHR byte from the bank the current latch selects.
+func (m *Mapper9) ReadCHR(addr uint16) uint8 {
+	if offset := m.chrOffset(addr); offset >= 0 {
+		return chrMemory(m.cartridge)[offset]
+	}
+	return 0
+}
+
+// chrOffset maps addr through the 4KB bank its half's latch selects onto
+// chrMemory; -1 when there is no CHR memory.
+func (m *Mapper9) chrOffset(addr uint16) int {
+	if m.chrBankCount == 0 {
+		return -1
+	}
+	var bank uint8
+	if addr < 0x1000 {
+		if m.latch0 == 0xFD {
+			bank = m.chrBank0FD
+		} else {
+			bank = m.chrBank0FE
+		}
+	} else {
+		if m.latch1 == 0xFD {
+			bank = m.chrBank1FD
+		} else {
+			bank = m.chrBank1FE
+		}
+	}
+	return int(bank%m.chrBankCount)*4096 + int(addr&0x0FFF)
+}
+
+// PPUAddressBus flips a latch once the PPU has put a trigger address on
+// its bus; the triggering fetch itself still used the old bank.
+func (m *Mapper9) PPUAddressBus(addr uint16) {
+	switch {
+	case addr == 0x0FD8:
+		m.latch0 = 0xFD
+	case addr == 0x0FE8:
+		m.latch0 = 0xFE
+	case addr >= 0x1FD8 && addr <= 0x1FDF:
+		m.latch1 = 0xFD
+	case addr >= 0x1FE8 && addr <= 0x1FEF:
+		m.latch1 = 0xFE
+	}
+}
+
+// WatchesPatternFetches asks for every rendering pattern fetch too: the
+// latches follow the tiles the PPU draws.
+func (m *Mapper9) WatchesPatternFetches() {}
+
+// WriteCHR writes to CHR RAM, banked like ReadCHR, if present. PxROM
+// boards carry CHR ROM, so in practice this does nothing.
+func (m *Mapper9) WriteCHR(addr uint16, value uint8) {
+	if addr < 0x2000 {
+		writeBankedCHRRAM(m.cartridge, m.chrOffset(addr), value)
+	}
+}
+
+// Step is a no-op: MMC2 has no IRQ counter.
+func (m *Mapper9) Step() {}
+
+// IRQLine always false: MMC2 has no IRQ source.
+func (m *Mapper9) IRQLine() bool { return false }
+
+// ClearIRQ is a no-op.
+func (m *Mapper9) ClearIRQ() {}
+
+// GetMirroringMode returns the PPU-encoded mirroring (0=horizontal,
+// 1=vertical); MMC2's register bit has the opposite sense.
+func (m *Mapper9) GetMirroringMode() MirroringMode {
+	if m.mirroring == 0 {
+		return MirroringVertical
+	}
+	return MirroringHorizontal
+}
+
+type mapper9State struct {
+	PrgBank                                        uint8
+	ChrBank0FD, ChrBank0FE, ChrBank1FD, ChrBank1FE uint8
+	Latch0, Latch1                                 uint8
+	Mirroring                                      uint8
+}
+
+// SaveState persists bank registers, latches, and mirroring.
+func (m *Mapper9) SaveState(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, mapper9State{
+		PrgBank:    m.prgBank,
+		ChrBank0FD: m.chrBank0FD, ChrBank0FE: m.chrBank0FE,
+		ChrBank1FD: m.chrBank1FD, ChrBank1FE: m.chrBank1FE,
+		Latch0: m.latch0, Latch1: m.latch1,
+		Mirroring: m.mirroring,
+	})
+}
+
+// AppendState implements StateAppender.
+func (m *Mapper9) AppendState(b []byte) []byte {
+	return append(b, m.prgBank, m.chrBank0FD, m.chrBank0FE, m.chrBank1FD, m.chrBank1FE,
+		m.latch0, m.latch1, m.mirroring)
+}
+
+// LoadState restores state written by SaveState.
+func (m *Mapper9) LoadState(r io.Reader) error {
+	var s mapper9State
+	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
+		return err
+	}
+	m.prgBank = s.PrgBank
+	m.chrBank0FD, m.chrBank0FE = s.ChrBank0FD, s.ChrBank0FE
+	m.chrBank1FD, m.chrBank1FE = s.ChrBank1FD, s.ChrBank1FE
+	m.latch0, m.latch1 = s.Latch0, s.Latch1
+	m.mirroring = s.Mirroring
+	m.updatePRGBanks()
+	return nil
+}
@@ -0,0 +1,101 @@
+package mapper
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Mapper99 is the VS. System's cartridge slot: no mapper chip, just the
+// $4016 OUT2 line (bit 2), which selects the 8KB CHR bank and, on the
+// 40KB PRG boards (Vs. Gumshoe), the 8KB PRG bank at $8000. The rest of
+// PRG is fixed. The mainboard's 2KB of work RAM at $6000 is PRG RAM here.
+type Mapper99 struct {
+	cartridge *CartridgeData
+	bank      uint8 // OUT2, 0 or 1
+	prg       prgBankTable
+}
+
+func init() {
+	Register(Board{Name: "VS. System", New: func(_ uint8, d *CartridgeData) Mapper { return NewMapper99(d) }}, 99)
+}
+
+// NewMapper99 creates a new Mapper99 instance
+func NewMapper99(data *CartridgeData) *Mapper99 {
+	m := &Mapper99{cartridge: data}
+	m.updateBanks()
+	return m
+}
+
+func (m *Mapper99) updateBanks() {
+	m.prg.setFixed(m.cartridge.PRGROM)
+	if len(m.cartridge.PRGROM) > 0x8000 {
+		m.prg.set(0, m.cartridge.PRGROM, int(m.bank)*4)
+	}
+}
+
+// PRGBanks implements PRGBankMapper.
+func (m *Mapper99) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+// WriteOUT implements OUTLatchWatcher.
+func (m *Mapper99) WriteOUT(value uint8) {
+	if bank := value >> 2 & 1; bank != m.bank {
+		m.bank = bank
+		m.updateBanks()
+	}
+}
+
+// ReadPRG reads from PRG space
+func (m *Mapper99) ReadPRG(addr uint16) uint8 {
+	if addr >= 0x8000 {
+		return m.prg.read(addr)
+	}
+	return readPRGRAM(m.cartridge, addr)
+}
+
+// WritePRG writes to PRG RAM; the board has no registers.
+func (m *Mapper99) WritePRG(addr uint16, value uint8) {
+	writePRGRAM(m.cartridge, addr, value)
+}
+
+// ReadCHR reads from the selected 8KB CHR bank
+func (m *Mapper99) ReadCHR(addr uint16) uint8 {
+	chr := chrMemory(m.cartridge)
+	if len(chr) == 0 {
+		return 0
+	}
+	return chr[(int(m.bank)*0x2000+int(addr&0x1FFF))%len(chr)]
+}
+
+// WriteCHR writes to CHR RAM
+func (m *Mapper99) WriteCHR(addr uint16, value uint8) {
+	writeCHRRAM(m.cartridge, addr, value)
+}
+
+// Step does nothing for Mapper99
+func (m *Mapper99) Step() {}
+
+// IRQLine returns false for Mapper99 (no IRQ support)
+func (m *Mapper99) IRQLine() bool { return false }
+
+// ClearIRQ does nothing for Mapper99 (no IRQ support)
+func (m *Mapper99) ClearIRQ() {}
+
+// SaveState writes the OUT2 bank.
+func (m *Mapper99) SaveState(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, m.bank)
+}
+
+// AppendState implements StateAppender.
+func (m *Mapper99) AppendState(b []byte) []byte {
+	return append(b, m.bank)
+}
+
+// LoadState restores the OUT2 bank.
+func (m *Mapper99) LoadState(r io.Reader) error {
+	if err := binary.Read(r, binary.LittleEndian, &m.bank); err != nil {
+		return err
+	}
+	m.bank &= 1
+	m.updateBanks()
+	return nil
+}
@@ -0,0 +1,42 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestMapper99 covers OUT2 banking: CHR always, and the $8000 PRG window
+// on a 40KB board.
+func TestMapper99(t *testing.T) {
+	prgROM := make([]uint8, 40*1024)
+	for i := 0; i < 5; i++ {
+		prgROM[i*8192] = uint8(i + 1)
+	}
+	chrROM := make([]uint8, 16*1024)
+	chrROM[0], chrROM[8192] = 0x10, 0x11
+	m := NewMapper99(&CartridgeData{PRGROM: prgROM, CHRROM: chrROM, PRGRAM: make([]uint8, 8192)})
+
+	if m.ReadPRG(0x8000) != 1 || m.ReadPRG(0xA000) != 2 || m.ReadCHR(0) != 0x10 {
+		t.Fatal("power-on banks should be PRG 0-3 and CHR 0")
+	}
+	m.WritePRG(0x8000, 0xFF) // no registers in ROM space
+	m.WriteOUT(0x04)
+	if got := m.ReadPRG(0x8000); got != 5 {
+		t.Errorf("$8000 with OUT2 set = %d, want the fifth 8KB bank", got)
+	}
+	if m.ReadPRG(0xE000) != 4 || m.ReadCHR(0) != 0x11 {
+		t.Error("OUT2 should switch CHR and leave $A000-$FFFF fixed")
+	}
+
+	var buf bytes.Buffer
+	if err := m.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m.WriteOUT(0x00)
+	if err := m.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if m.ReadPRG(0x8000) != 5 || m.ReadCHR(0) != 0x11 {
+		t.Error("LoadState should restore the OUT2 banks")
+	}
+}
@@ -31,7 +31,7 @@ func makeData(prg16k, chr4k int) *CartridgeData {
 
 func TestNewMapperFactory(t *testing.T) {
 	data := makeData(2, 2)
-	for _, num := range []uint8{0, 1, 2, 3, 4, 5, 10, 69, 70} {
+	for _, num := range []uint8{0, 1, 2, 3, 4, 5, 9, 10, 11, 21, 22, 23, 25, 34, 38, 66, 69, 70, 140} {
 		m, err := NewMapper(num, data)
 		if err != nil || m == nil {
 			t.Errorf("NewMapper(%d): m=%v err=%v", num, m, err)
@@ -86,13 +86,18 @@ func TestMapper10CHRLatch(t *testing.T) {
 	if got := m.ReadCHR(0x0000); got != 0xC1 {
 		t.Errorf("$0000 (latch0=FE) = %#02x, want 0xC1 (bank 1)", got)
 	}
-	// Reading the $0FD8-$0FDF trigger flips latch0 to $FD for *subsequent* reads.
+	// Reads alone never move the latch; only the PPU fetch hook does.
 	m.ReadCHR(0x0FD8)
+	if got := m.ReadCHR(0x0000); got != 0xC1 {
+		t.Errorf("ReadCHR($0FD8) switched the bank: $0000 = %#02x", got)
+	}
+	// Fetching from $0FD8-$0FDF flips latch0 to $FD for *subsequent* reads.
+	m.PPUAddressBus(0x0FDF)
 	if got := m.ReadCHR(0x0000); got != 0xC0 {
 		t.Errorf("$0000 (latch0=FD) = %#02x, want 0xC0 (bank 0)", got)
 	}
 	// $0FE8 trigger flips latch0 back to $FE.
-	m.ReadCHR(0x0FE8)
+	m.PPUAddressBus(0x0FE8)
 	if got := m.ReadCHR(0x0000); got != 0xC1 {
 		t.Errorf("$0000 (latch0=FE again) = %#02x, want 0xC1", got)
 	}
@@ -101,11 +106,11 @@ func TestMapper10CHRLatch(t *testing.T) {
 	if got := m.ReadCHR(0x1000); got != 0xC3 {
 		t.Errorf("$1000 (latch1=FE) = %#02x, want 0xC3 (bank 3)", got)
 	}
-	m.ReadCHR(0x1FD8)
+	m.PPUAddressBus(0x1FD8)
 	if got := m.ReadCHR(0x1000); got != 0xC2 {
 		t.Errorf("$1000 (latch1=FD) = %#02x, want 0xC2 (bank 2)", got)
 	}
-	m.ReadCHR(0x1FE8)
+	m.PPUAddressBus(0x1FE8)
 	if got := m.ReadCHR(0x1000); got != 0xC3 {
 		t.Errorf("$1000 (latch1=FE again) = %#02x, want 0xC3", got)
 	}
@@ -127,20 +132,19 @@ func TestMapper10MiscAndState(t *testing.T) {
 	// No IRQ source.
 	m.Step()
 	m.ClearIRQ()
-	if m.IsIRQPending() {
+	if m.IRQLine() {
 		t.Error("MMC4 should never have a pending IRQ")
 	}
 
-	// CHR RAM write path (CHRROM present means chrFetch uses ROM, but WriteCHR
-	// still targets CHRRAM when allocated).
+	// With CHR ROM, writes go nowhere — not even to the unused CHR RAM.
 	m.WriteCHR(0x0010, 0xEE)
-	if m.cartridge.CHRRAM[0x0010] != 0xEE {
-		t.Errorf("WriteCHR to CHR RAM failed: %#02x", m.cartridge.CHRRAM[0x0010])
+	if m.cartridge.CHRRAM[0x0010] != 0 || m.ReadCHR(0x0010) == 0xEE {
+		t.Error("WriteCHR on a CHR ROM board should be ignored")
 	}
 
 	// State round-trip.
 	m.WritePRG(0xA000, 0x03)
-	m.ReadCHR(0x0FD8) // latch0 = FD
+	m.PPUAddressBus(0x0FD8) // latch0 = FD
 	var buf bytes.Buffer
 	if err := m.SaveState(&buf); err != nil {
 		t.Fatalf("SaveState: %v", err)
@@ -154,6 +158,99 @@ func TestMapper10MiscAndState(t *testing.T) {
 	}
 }
 
+// --- MMC2 (mapper9) ---
+
+func TestMapper9PRGBanking(t *testing.T) {
+	m := NewMapper9(makeData(4, 4)) // 64KB PRG = 8 × 8KB banks
+
+	// Even 8KB banks carry the 16KB tag; odd ones are zero at offset 0.
+	if got := m.ReadPRG(0x8000); got != 0xA0 {
+		t.Errorf("$8000 default = %#02x, want 0xA0 (bank 0)", got)
+	}
+	for addr, want := range map[uint16]uint8{0xA000: 0x00, 0xC000: 0xA3, 0xE000: 0x00} {
+		if got := m.ReadPRG(addr); got != want {
+			t.Errorf("$%04X fixed = %#02x, want %#02x", addr, got, want)
+		}
+	}
+	m.WritePRG(0xA000, 0x04)
+	if got := m.ReadPRG(0x8000); got != 0xA2 {
+		t.Errorf("$8000 after bank=4 = %#02x, want 0xA2", got)
+	}
+	if got := m.PRGBanks()[0][0]; got != 0xA2 {
+		t.Errorf("PRGBanks window 0 = %#02x, want 0xA2", got)
+	}
+	m.WritePRG(0x8000, 0x00) // no register here
+	if got := m.ReadPRG(0x8000); got != 0xA2 {
+		t.Errorf("$8000 write changed the bank: %#02x", got)
+	}
+}
+
+func TestMapper9CHRLatch(t *testing.T) {
+	m := NewMapper9(makeData(4, 4))
+	m.WritePRG(0xB000, 0x00)
+	m.WritePRG(0xC000, 0x01)
+	m.WritePRG(0xD000, 0x02)
+	m.WritePRG(0xE000, 0x03)
+
+	// Low table: only the exact addresses $0FD8/$0FE8 trigger.
+	m.PPUAddressBus(0x0FD9)
+	if got := m.ReadCHR(0x0000); got != 0xC1 {
+		t.Errorf("$0FD9 fetch moved latch0: $0000 = %#02x, want 0xC1", got)
+	}
+	m.PPUAddressBus(0x0FD8)
+	if got := m.ReadCHR(0x0000); got != 0xC0 {
+		t.Errorf("$0000 (latch0=FD) = %#02x, want 0xC0", got)
+	}
+	m.PPUAddressBus(0x0FE8)
+	if got := m.ReadCHR(0x0000); got != 0xC1 {
+		t.Errorf("$0000 (latch0=FE) = %#02x, want 0xC1", got)
+	}
+
+	// High table: the whole 8-byte row triggers, as on MMC4.
+	m.PPUAddressBus(0x1FDD)
+	if got := m.ReadCHR(0x1000); got != 0xC2 {
+		t.Errorf("$1000 (latch1=FD) = %#02x, want 0xC2", got)
+	}
+	m.PPUAddressBus(0x1FEF)
+	if got := m.ReadCHR(0x1000); got != 0xC3 {
+		t.Errorf("$1000 (latch1=FE) = %#02x, want 0xC3", got)
+	}
+}
+
+func TestMapper9MiscAndState(t *testing.T) {
+	m := NewMapper9(makeData(4, 4))
+	m.WritePRG(0xF000, 0x00)
+	if m.GetMirroringMode() != 1 {
+		t.Errorf("mirroring 0 -> %d, want 1 (vertical)", m.GetMirroringMode())
+	}
+	m.WritePRG(0xF000, 0x01)
+	if m.GetMirroringMode() != 0 {
+		t.Errorf("mirroring 1 -> %d, want 0 (horizontal)", m.GetMirroringMode())
+	}
+	if m.IRQLine() {
+		t.Error("MMC2 should never have a pending IRQ")
+	}
+	m.WritePRG(0x6000, 0x55)
+	if got := m.ReadPRG(0x6000); got != 0x55 {
+		t.Errorf("PRG RAM = %#02x, want 0x55", got)
+	}
+
+	m.WritePRG(0xA000, 0x06)
+	m.WritePRG(0xD000, 0x02)
+	m.PPUAddressBus(0x1FD8)
+	var buf bytes.Buffer
+	if err := m.SaveState(&buf); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	m2 := NewMapper9(makeData(4, 4))
+	if err := m2.LoadState(&buf); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if m2.ReadPRG(0x8000) != 0xA3 || m2.ReadCHR(0x1000) != 0xC2 || m2.GetMirroringMode() != 0 {
+		t.Errorf("restored state mismatch: $8000=%#02x $1000=%#02x", m2.ReadPRG(0x8000), m2.ReadCHR(0x1000))
+	}
+}
+
 // --- MMC1 (mapper1) ---
 
 // mmc1Serial writes a 5-bit value to an MMC1 register via the serial port.
@@ -195,7 +292,7 @@ func TestMapper1PRGModes(t *testing.T) {
 
 func TestMapper1Mirroring(t *testing.T) {
 	m := NewMapper1(makeData(2, 2))
-	wantByCtrl := map[uint8]uint8{0: 2, 1: 3, 2: 1, 3: 0}
+	wantByCtrl := map[uint8]MirroringMode{0: MirroringSingleScreenLower, 1: MirroringSingleScreenUpper, 2: MirroringVertical, 3: MirroringHorizontal}
 	for ctrl, want := range wantByCtrl {
 		mmc1Serial(m, 0x8000, ctrl) // control low 2 bits = mirroring
 		if got := m.GetMirroringMode(); got != want {
@@ -249,7 +346,7 @@ func TestMapper1ResetAndState(t *testing.T) {
 
 	m.Step()
 	m.ClearIRQ()
-	if m.IsIRQPending() {
+	if m.IRQLine() {
 		t.Error("MMC1 has no IRQ")
 	}
 	m.WriteCHR(0x0001, 0x42) // CHR RAM write (no CHR ROM banking conflict here)
@@ -273,11 +370,11 @@ func TestMapper1ResetAndState(t *testing.T) {
 func TestMapperNoOpsAndState(t *testing.T) {
 	data := makeData(8, 8)
 
-	// mapper0 (NROM): Step / ClearIRQ are no-ops; IsIRQPending false.
+	// mapper0 (NROM): Step / ClearIRQ are no-ops; IRQLine false.
 	m0 := NewMapper0(data)
 	m0.Step()
 	m0.ClearIRQ()
-	if m0.IsIRQPending() {
+	if m0.IRQLine() {
 		t.Error("NROM has no IRQ")
 	}
 
@@ -285,7 +382,7 @@ func TestMapperNoOpsAndState(t *testing.T) {
 	m2 := NewMapper2(data)
 	m2.WritePRG(0x8000, 0x03)
 	_ = m2.GetCurrentPRGBank()
-	if m2.IsIRQPending() {
+	if m2.IRQLine() {
 		t.Error("UxROM should never have a pending IRQ")
 	}
 	m2.Step()
@@ -296,7 +393,7 @@ func TestMapperNoOpsAndState(t *testing.T) {
 	m3 := NewMapper3(data)
 	m3.Step()
 	m3.ClearIRQ()
-	_ = m3.IsIRQPending()
+	_ = m3.IRQLine()
 	roundTrip(t, "mapper3", m3.SaveState, m3.LoadState)
 
 	// mapper70: WriteCHR / Step / ClearIRQ.
@@ -304,7 +401,7 @@ func TestMapperNoOpsAndState(t *testing.T) {
 	m70.WriteCHR(0x0000, 0x11)
 	m70.Step()
 	m70.ClearIRQ()
-	_ = m70.IsIRQPending()
+	_ = m70.IRQLine()
 
 	// mapper69 (FME-7): WriteCHR / Step / ClearIRQ / IRQCapable / state.
 	m69 := NewMapper69(data)
@@ -312,7 +409,7 @@ func TestMapperNoOpsAndState(t *testing.T) {
 	m69.Step()
 	m69.ClearIRQ()
 	m69.IRQCapable()
-	_ = m69.IsIRQPending()
+	_ = m69.IRQLine()
 	roundTrip(t, "mapper69", m69.SaveState, m69.LoadState)
 
 	// mapper5 (MMC5): trivial members + state round-trip.
@@ -321,11 +418,10 @@ func TestMapperNoOpsAndState(t *testing.T) {
 	m5.ClearIRQ()
 	m5.IRQCapable()
 	m5.DecodesExpansion()
-	m5.NotifyA12(0x1000, true)
 	m5.WriteCHR(0x0000, 0x33)
 	m5.WritePRG(0x6000, 0x44) // exercises prgRAMUnlocked on the RAM path
 	_ = m5.ReadPRG(0x6000)
-	_ = m5.IsIRQPending()
+	_ = m5.IRQLine()
 	roundTrip(t, "mapper5", m5.SaveState, m5.LoadState)
 
 	// mapper4 (MMC3): debug helpers + IRQCapable + ClearIRQ.
@@ -355,3 +451,27 @@ func roundTrip(t *testing.T, name string, save func(io.Writer) error, load func(
 		t.Fatalf("%s LoadState: %v", name, err)
 	}
 }
+
+// TestAppendStateMatchesSaveState pins every StateAppender to the bytes its
+// SaveState writes, so the two can't drift apart when a field is added.
+func TestAppendStateMatchesSaveState(t *testing.T) {
+	for _, num := range []uint8{1, 2, 3, 4, 5, 9, 10, 11, 21, 22, 23, 25, 34, 38, 66, 69, 70, 140} {
+		m, err := NewMapper(num, makeData(8, 8))
+		if err != nil {
+			t.Fatalf("mapper %d: %v", num, err)
+		}
+		// Scatter writes over the register space so most fields are non-zero.
+		for i := 0; i < 64; i++ {
+			m.WritePRG(uint16(0x5000+i*0x2F3), uint8(i*37+1))
+			m.Step()
+		}
+		var buf bytes.Buffer
+		if err := m.(Stateful).SaveState(&buf); err != nil {
+			t.Fatalf("mapper %d SaveState: %v", num, err)
+		}
+		got := m.(StateAppender).AppendState([]byte{0xEE})
+		if !bytes.Equal(got[1:], buf.Bytes()) || got[0] != 0xEE {
+			t.Errorf("mapper %d: AppendState\n% X\nwant\n% X", num, got[1:], buf.Bytes())
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package mapper
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Board describes a mapper to the registry: what its boards are called,
+// what they can do, and how to build one. Each mapper in this package
+// registers itself from an init function; a program can register its own
+// for a homebrew board before loading the ROM:
+//
+//	mapper.Register(mapper.Board{
+//		Name: "MyBoard",
+//		New:  func(n uint8, d *mapper.CartridgeData) mapper.Mapper { return newMyBoard(d) },
+//	}, 218)
+//
+// The capability fields describe the boards for tools such as
+// rom_analyzer; the cartridge learns what the mapper actually does from
+// the optional interfaces it implements (IRQCapable, AudioSource, ...).
+type Board struct {
+	Name string
+	// Submappers lists the NES 2.0 submappers New tells apart; any other
+	// runs like submapper 0.
+	Submappers []uint8
+	Battery    bool // boards can keep saves (PRG RAM on a battery, flash)
+	Audio      bool // expansion sound (AudioSource)
+	IRQ        bool // can interrupt the CPU (IRQCapable)
+	// CHRRAM is the CHR RAM the boards have when an image without CHR ROM
+	// doesn't say (iNES 1.0); 0 means the usual 8 KiB.
+	CHRRAM int
+	// New builds the mapper for an image; number is the mapper number it
+	// was registered under, for a Board shared by several.
+	New func(number uint8, data *CartridgeData) Mapper
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[uint8]Board{}
+)
+
+// Register makes b the mapper for each of numbers. Like database/sql's
+// Register it is meant for init time and panics on a number already
+// taken or a Board without New.
+func Register(b Board, numbers ...uint8) {
+	if b.New == nil {
+		panic(fmt.Sprintf("mapper: Register %q without New", b.Name))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, n := range numbers {
+		if old, dup := registry[n]; dup {
+			panic(fmt.Sprintf("mapper: Register %q as mapper %d, already %q", b.Name, n, old.Name))
+		}
+		registry[n] = b
+	}
+}
+
+// Lookup returns the Board registered for number.
+func Lookup(number uint8) (Board, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	b, ok := registry[number]
+	return b, ok
+}
+
+// Numbers returns the registered mapper numbers in order.
+func Numbers() []uint8 {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	ns := make([]uint8, 0, len(registry))
+	for n := range registry {
+		ns = append(ns, n)
+	}
+	slices.Sort(ns)
+	return ns
+}
+
+// SupportedList is Numbers as text, "0, 1, 2, ...", for error messages.
+func SupportedList() string {
+	ns := Numbers()
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = fmt.Sprint(n)
+	}
+	return strings.Join(s, ", ")
+}
+
+// HandlesSubmapper reports whether b tells submapper sub apart from 0.
+func (b Board) HandlesSubmapper(sub uint8) bool {
+	return sub == 0 || slices.Contains(b.Submappers, sub)
+}
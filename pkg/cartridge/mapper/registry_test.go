@@ -0,0 +1,82 @@
+package mapper
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	want := []uint8{0, 1, 2, 3, 4, 5, 9, 10, 11, 16, 19, 21, 22, 23, 25, 30, 34, 38, 66, 69, 70, 99, 140, 153, 157, 159, 206}
+	if got := Numbers(); !slices.Equal(got, want) {
+		t.Errorf("Numbers() = %v, want %v", got, want)
+	}
+
+	// The capability flags agree with the interfaces the mappers implement.
+	for _, n := range Numbers() {
+		b, _ := Lookup(n)
+		m, err := NewMapper(n, &CartridgeData{
+			PRGROM: make([]uint8, 128*1024),
+			CHRROM: make([]uint8, 128*1024),
+			PRGRAM: make([]uint8, 8*1024),
+		})
+		if err != nil || m == nil {
+			t.Fatalf("mapper %d (%s): %v", n, b.Name, err)
+		}
+		_, irq := m.(IRQCapable)
+		_, audio := m.(AudioSource)
+		if b.IRQ != irq || b.Audio != audio {
+			t.Errorf("mapper %d (%s): IRQ %v Audio %v, implements %v %v", n, b.Name, b.IRQ, b.Audio, irq, audio)
+		}
+		if b.Name == "" {
+			t.Errorf("mapper %d has no name", n)
+		}
+	}
+
+	b, _ := Lookup(4)
+	if !b.HandlesSubmapper(1) || !b.HandlesSubmapper(0) || b.HandlesSubmapper(2) {
+		t.Errorf("MMC3 submappers %v", b.Submappers)
+	}
+
+	_, err := NewMapper(238, &CartridgeData{})
+	if !errors.Is(err, ErrUnsupported) || !strings.Contains(err.Error(), "0, 1, 2, 3, 4, 5, 9") {
+		t.Errorf("unknown mapper: %v, want ErrUnsupported listing the supported ones", err)
+	}
+}
+
+func TestRegisterCustom(t *testing.T) {
+	defer func() {
+		registryMu.Lock()
+		delete(registry, 238)
+		registryMu.Unlock()
+	}()
+	var got uint8
+	Register(Board{Name: "Homebrew", New: func(n uint8, d *CartridgeData) Mapper {
+		got = n
+		return NewMapper0(d)
+	}}, 238)
+	if m, err := NewMapper(238, &CartridgeData{PRGROM: make([]uint8, 16*1024)}); err != nil || m == nil || got != 238 {
+		t.Errorf("custom mapper: %v, %v, built as %d", m, err, got)
+	}
+	if !strings.Contains(SupportedList(), "206, 238") {
+		t.Errorf("SupportedList() = %s", SupportedList())
+	}
+
+	for name, f := range map[string]func(){
+		"duplicate": func() { Register(Board{Name: "again", New: newNROM}, 0) },
+		"no New":    func() { Register(Board{Name: "empty"}, 239) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: Register didn't panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+// newNROM is NewMapper0 with Board.New's signature.
+func newNROM(_ uint8, d *CartridgeData) Mapper { return NewMapper0(d) }
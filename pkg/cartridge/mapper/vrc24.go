@@ -0,0 +1,375 @@
+package mapper
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// VRC24 implements Konami's VRC2 and VRC4 (iNES mappers 21, 22, 23 and 25)
+// — Contra (J), Gradius II, Ganbare Goemon 2, Tiny Toon Adventures and
+// others. All four mapper numbers are the same chip family; they differ
+// only in which CPU address lines the board wires to the chip's two
+// register-select pins, and in whether the chip is a VRC2 (no IRQ, no PRG
+// swap mode, 1-bit mirroring) or a VRC4.
+//
+// Registers, by chip address (A15-A12 plus the two select pins):
+//
+//	$8000-$8003  PRG bank 0 (8 KiB; at $8000, or $C000 in swap mode)
+//	$9000-$9001  mirroring (VRC4: 0=vert 1=horiz 2/3=one-screen; VRC2: bit 0)
+//	$9002-$9003  VRC4: bit 1 = PRG swap mode (VRC2: more mirroring)
+//	$A000-$A003  PRG bank 1 (8 KiB at $A000)
+//	$B000-$E003  CHR banks 0-7 (1 KiB each), low nibble / high bits pairs:
+//	             $B000/$B001 bank 0, $B002/$B003 bank 1, … $E002/$E003 bank 7
+//	$F000/$F001  VRC4 IRQ latch low / high nibble
+//	$F002        VRC4 IRQ control (bit 0 A, bit 1 E, bit 2 M=cycle mode)
+//	$F003        VRC4 IRQ acknowledge
+//
+// The two fixed windows hold the second-last and last 8 KiB banks.
+type VRC24 struct {
+	cartridge *CartridgeData
+	pins      vrcPins
+
+	prgBanks [2]uint8
+	prgSwap  bool
+	prg      prgBankTable // $8000-$FFFF windows, rebuilt by updatePRGBanks
+
+	chrBanks     [8]uint16 // register values before the VRC2a shift
+	chrBankCount uint16
+
+	mirroring uint8
+
+	// VRC4 IRQ. In scanline mode (M=0) a prescaler turns CPU cycles into
+	// scanlines: it drops by 3 per cycle from 341 and clocks the counter
+	// each time it runs out, which is 113⅔ cycles on average. In cycle
+	// mode every CPU cycle clocks the counter. The counter counts up and
+	// fires on overflow from $FF, reloading from the latch.
+	irqLatch     uint8
+	irqCounter   uint8
+	irqControl   uint8
+	irqPrescaler int
+	irqPending   bool
+}
+
+// vrcPins describes one board wiring. a0 and a1 are the CPU address bits
+// that drive the chip's A0 and A1 select pins. iNES 1.0 images don't say
+// which board they are, so for those the masks cover both candidate lines
+// of the mapper number (no game writes registers through an address that
+// would set both at once).
+type vrcPins struct {
+	a0, a1 uint16
+	vrc2   bool
+	// chrShift is 1 on VRC2a (mapper 22), whose CHR A10 comes from the
+	// register's bit 1: the bank number is the register value halved.
+	chrShift uint
+}
+
+// vrcPinsFor resolves the wiring from the mapper number and NES 2.0
+// submapper (NESdev "VRC2 and VRC4": 21/1 VRC4a, 21/2 VRC4c, 22 VRC2a,
+// 23/1 VRC4f, 23/2 VRC4e, 23/3 VRC2b, 25/1 VRC4b, 25/2 VRC4d, 25/3 VRC2c).
+func vrcPinsFor(mapperNumber, submapper uint8) vrcPins {
+	switch mapperNumber {
+	case 21:
+		switch submapper {
+		case 1:
+			return vrcPins{a0: 0x02, a1: 0x04}
+		case 2:
+			return vrcPins{a0: 0x40, a1: 0x80}
+		}
+		return vrcPins{a0: 0x42, a1: 0x84}
+	case 22:
+		return vrcPins{a0: 0x02, a1: 0x01, vrc2: true, chrShift: 1}
+	case 23:
+		switch submapper {
+		case 1:
+			return vrcPins{a0: 0x01, a1: 0x02}
+		case 2:
+			return vrcPins{a0: 0x04, a1: 0x08}
+		case 3:
+			return vrcPins{a0: 0x01, a1: 0x02, vrc2: true}
+		}
+		return vrcPins{a0: 0x05, a1: 0x0A}
+	default: // 25
+		switch submapper {
+		case 1:
+			return vrcPins{a0: 0x02, a1: 0x01}
+		case 2:
+			return vrcPins{a0: 0x08, a1: 0x04}
+		case 3:
+			return vrcPins{a0: 0x02, a1: 0x01, vrc2: true}
+		}
+		return vrcPins{a0: 0x0A, a1: 0x05}
+	}
+}
+
+func init() {
+	newVRC := func(n uint8, d *CartridgeData) Mapper { return NewVRC24(n, d) }
+	Register(Board{Name: "VRC4a/VRC4c", Submappers: []uint8{1, 2}, Battery: true, IRQ: true, New: newVRC}, 21)
+	Register(Board{Name: "VRC2a", IRQ: true, New: newVRC}, 22)
+	Register(Board{Name: "VRC4e/VRC4f/VRC2b", Submappers: []uint8{1, 2, 3}, Battery: true, IRQ: true, New: newVRC}, 23)
+	Register(Board{Name: "VRC4b/VRC4d/VRC2c", Submappers: []uint8{1, 2, 3}, Battery: true, IRQ: true, New: newVRC}, 25)
+}
+
+// NewVRC24 creates a VRC2/VRC4 for iNES mapper 21, 22, 23 or 25, wired as
+// data.Submapper selects. Unknown-board 23/25 images get a VRC4, whose
+// registers are a superset of the VRC2's.
+func NewVRC24(mapperNumber uint8, data *CartridgeData) *VRC24 {
+	m := &VRC24{
+		cartridge:    data,
+		pins:         vrcPinsFor(mapperNumber, data.Submapper),
+		irqPrescaler: 341,
+	}
+	m.chrBankCount = uint16(len(chrMemory(data)) / 1024)
+	m.updatePRGBanks()
+	return m
+}
+
+// IsVRC2 reports whether the board was resolved to a VRC2.
+func (m *VRC24) IsVRC2() bool { return m.pins.vrc2 }
+
+// PRGBanks implements PRGBankMapper.
+func (m *VRC24) PRGBanks() *[4][]uint8 { return (*[4][]uint8)(&m.prg) }
+
+// updatePRGBanks maps the two switchable banks and the fixed second-last
+// and last banks according to the swap mode.
+func (m *VRC24) updatePRGBanks() {
+	rom := m.cartridge.PRGROM
+	if m.prgSwap {
+		m.prg.set(0, rom, -2)
+		m.prg.set(2, rom, int(m.prgBanks[0]))
+	} else {
+		m.prg.set(0, rom, int(m.prgBanks[0]))
+		m.prg.set(2, rom, -2)
+	}
+	m.prg.set(1, rom, int(m.prgBanks[1]))
+	m.prg.set(3, rom, -1)
+}
+
+// ReadPRG reads from PRG space; $6000-$7FFF is PRG RAM. (VRC2 boards
+// without RAM have a one-bit latch there instead, which RAM subsumes.)
+func (m *VRC24) ReadPRG(addr uint16) uint8 {
+	if addr >= 0x8000 {
+		return m.prg.read(addr)
+	}
+	return readPRGRAM(m.cartridge, addr)
+}
+
+// register folds a CPU address onto the chip's register address: A15-A12
+// plus the two select pins as bits 1-0.
+func (m *VRC24) register(addr uint16) uint16 {
+	reg := addr & 0xF000
+	if addr&m.pins.a0 != 0 {
+		reg |= 1
+	}
+	if addr&m.pins.a1 != 0 {
+		reg |= 2
+	}
+	return reg
+}
+
+// WritePRG decodes register writes ($8000-$FFFF) and PRG RAM writes.
+func (m *VRC24) WritePRG(addr uint16, value uint8) {
+	if addr < 0x8000 {
+		writePRGRAM(m.cartridge, addr, value)
+		return
+	}
+	reg := m.register(addr)
+	switch reg & 0xF000 {
+	case 0x8000:
+		m.prgBanks[0] = value & 0x1F
+		m.updatePRGBanks()
+	case 0x9000:
+		switch {
+		case m.pins.vrc2:
+			m.mirroring = value & 1
+		case reg&2 == 0:
+			m.mirroring = value & 3
+		default:
+			m.prgSwap = value&2 != 0
+			m.updatePRGBanks()
+		}
+	case 0xA000:
+		m.prgBanks[1] = value & 0x1F
+		m.updatePRGBanks()
+	case 0xB000, 0xC000, 0xD000, 0xE000:
+		bank := int(reg-0xB000)>>11 | int(reg&2)>>1
+		if reg&1 == 0 {
+			m.chrBanks[bank] = m.chrBanks[bank]&0x1F0 | uint16(value&0x0F)
+		} else {
+			high := uint16(value & 0x1F)
+			if m.pins.vrc2 {
+				high &= 0x0F
+			}
+			m.chrBanks[bank] = m.chrBanks[bank]&0x00F | high<<4
+		}
+	case 0xF000:
+		if !m.pins.vrc2 {
+			m.writeIRQ(reg&3, value)
+		}
+	}
+}
+
+// writeIRQ handles $F000-$F003 on a VRC4.
+func (m *VRC24) writeIRQ(reg uint16, value uint8) {
+	switch reg {
+	case 0:
+		m.irqLatch = m.irqLatch&0xF0 | value&0x0F
+	case 1:
+		m.irqLatch = m.irqLatch&0x0F | value<<4
+	case 2:
+		m.irqControl = value & 0x07
+		if m.irqControl&0x02 != 0 {
+			m.irqCounter = m.irqLatch
+			m.irqPrescaler = 341
+		}
+		m.irqPending = false
+	case 3:
+		// Acknowledge, and copy A (enable-after-acknowledge) into E.
+		m.irqPending = false
+		if m.irqControl&0x01 != 0 {
+			m.irqControl |= 0x02
+		} else {
+			m.irqControl &^= 0x02
+		}
+	}
+}
+
+// TickCPU runs the VRC4 IRQ prescaler and counter for the given number of
+// CPU cycles.
+func (m *VRC24) TickCPU(cycles int) {
+	if m.irqControl&0x02 == 0 {
+		return
+	}
+	for i := 0; i < cycles; i++ {
+		if m.irqControl&0x04 != 0 {
+			m.clockIRQCounter()
+			continue
+		}
+		m.irqPrescaler -= 3
+		if m.irqPrescaler <= 0 {
+			m.irqPrescaler += 341
+			m.clockIRQCounter()
+		}
+	}
+}
+
+func (m *VRC24) clockIRQCounter() {
+	if m.irqCounter == 0xFF {
+		m.irqCounter = m.irqLatch
+		m.irqPending = true
+		return
+	}
+	m.irqCounter++
+}
+
+// ReadCHR reads through the 1 KiB bank for addr.
+func (m *VRC24) ReadCHR(addr uint16) uint8 {
+	if offset := m.chrOffset(addr); offset >= 0 {
+		return chrMemory(m.cartridge)[offset]
+	}
+	return 0
+}
+
+// chrOffset maps addr through its 1KB bank register onto chrMemory; -1
+// when there is no CHR memory.
+func (m *VRC24) chrOffset(addr uint16) int {
+	if m.chrBankCount == 0 || addr >= 0x2000 {
+		return -1
+	}
+	bank := (m.chrBanks[(addr>>10)&7] >> m.pins.chrShift) % m.chrBankCount
+	return int(bank)*1024 + int(addr&0x3FF)
+}
+
+// WriteCHR writes CHR RAM, banked like reads, when the board has it.
+func (m *VRC24) WriteCHR(addr uint16, value uint8) {
+	writeBankedCHRRAM(m.cartridge, m.chrOffset(addr), value)
+}
+
+// Step is a no-op: the VRC4 IRQ runs on the CPU clock (TickCPU).
+func (m *VRC24) Step() {}
+
+// IRQLine reports a VRC4 IRQ; always false on a VRC2.
+func (m *VRC24) IRQLine() bool { return m.irqPending }
+
+// ClearIRQ drops a pending IRQ.
+func (m *VRC24) ClearIRQ() { m.irqPending = false }
+
+// IRQCapable marks the family as IRQ-asserting. A VRC2 never raises one,
+// but the marker is per type, and polling it costs little.
+func (m *VRC24) IRQCapable() {}
+
+// Reset implements Resetter: the IRQ control register powers up clear
+// (counter stopped, IRQ disabled) with the prescaler at the start of a
+// scanline.
+func (m *VRC24) Reset() {
+	m.irqControl, m.irqPending = 0, false
+	m.irqPrescaler = 341
+}
+
+// GetMirroringMode returns the PPU-encoded mirroring (0=horizontal,
+// 1=vertical, 2/3=single-screen lower/upper).
+func (m *VRC24) GetMirroringMode() MirroringMode {
+	switch m.mirroring {
+	case 0:
+		return MirroringVertical
+	case 1:
+		return MirroringHorizontal
+	default:
+		return MirroringMode(m.mirroring)
+	}
+}
+
+type vrc24State struct {
+	PRGBanks     [2]uint8
+	PRGSwap      bool
+	CHRBanks     [8]uint16
+	Mirroring    uint8
+	IRQLatch     uint8
+	IRQCounter   uint8
+	IRQControl   uint8
+	IRQPrescaler int32
+	IRQPending   bool
+}
+
+// SaveState persists the bank registers, mirroring and IRQ state.
+func (m *VRC24) SaveState(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, vrc24State{
+		PRGBanks:     m.prgBanks,
+		PRGSwap:      m.prgSwap,
+		CHRBanks:     m.chrBanks,
+		Mirroring:    m.mirroring,
+		IRQLatch:     m.irqLatch,
+		IRQCounter:   m.irqCounter,
+		IRQControl:   m.irqControl,
+		IRQPrescaler: int32(m.irqPrescaler),
+		IRQPending:   m.irqPending,
+	})
+}
+
+// AppendState implements StateAppender.
+func (m *VRC24) AppendState(b []byte) []byte {
+	b = append(b, m.prgBanks[:]...)
+	b = appendBool(b, m.prgSwap)
+	b = appendUint16s(b, m.chrBanks[:])
+	b = append(b, m.mirroring, m.irqLatch, m.irqCounter, m.irqControl)
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(m.irqPrescaler)))
+	return appendBool(b, m.irqPending)
+}
+
+// LoadState restores state written by SaveState.
+func (m *VRC24) LoadState(r io.Reader) error {
+	var s vrc24State
+	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
+		return err
+	}
+	m.prgBanks = s.PRGBanks
+	m.prgSwap = s.PRGSwap
+	m.chrBanks = s.CHRBanks
+	m.mirroring = s.Mirroring
+	m.irqLatch = s.IRQLatch
+	m.irqCounter = s.IRQCounter
+	m.irqControl = s.IRQControl
+	m.irqPrescaler = int(s.IRQPrescaler)
+	m.irqPending = s.IRQPending
+	m.updatePRGBanks()
+	return nil
+}
@@ -0,0 +1,192 @@
+package mapper
+
+import (
+	"bytes"
+	"testing"
+)
+
+// vrcData is makeData with a submapper: 8 × 16KB PRG (16 × 8KB banks, even
+// ones tagged 0xA0+n/2) and 32 × 4KB CHR (1KB bank 4n tagged 0xC0+n).
+func vrcData(submapper uint8) *CartridgeData {
+	d := makeData(8, 32)
+	d.Submapper = submapper
+	return d
+}
+
+// Each board reaches CHR bank 1's low nibble ($B002 on the chip, i.e. A1
+// set) through a different CPU address; 1KB bank 4 holds tag 0xC1.
+func TestVRC24PinLayouts(t *testing.T) {
+	for _, tc := range []struct {
+		mapper, sub uint8
+		addr        uint16 // chip $B002 on this board
+		vrc2        bool
+	}{
+		{21, 1, 0xB004, false}, // VRC4a: A2
+		{21, 2, 0xB080, false}, // VRC4c: A7
+		{21, 0, 0xB080, false},
+		{21, 0, 0xB004, false},
+		{22, 0, 0xB001, true},  // VRC2a: A0
+		{23, 1, 0xB002, false}, // VRC4f: A1
+		{23, 2, 0xB008, false}, // VRC4e: A3
+		{23, 3, 0xB002, true},  // VRC2b: A1
+		{23, 0, 0xB008, false},
+		{25, 1, 0xB001, false}, // VRC4b: A0
+		{25, 2, 0xB004, false}, // VRC4d: A2
+		{25, 3, 0xB001, true},  // VRC2c: A0
+		{25, 0, 0xB004, false},
+	} {
+		m := NewVRC24(tc.mapper, vrcData(tc.sub))
+		if m.IsVRC2() != tc.vrc2 {
+			t.Errorf("mapper %d/%d: IsVRC2 = %v", tc.mapper, tc.sub, m.IsVRC2())
+		}
+		bank := uint8(4)
+		if tc.mapper == 22 {
+			bank = 8 // VRC2a drops the register's low bit
+		}
+		m.WritePRG(tc.addr, bank)
+		if got := m.ReadCHR(0x0400); got != 0xC1 {
+			t.Errorf("mapper %d/%d: write to $%04X then $0400 = %#02x, want 0xC1", tc.mapper, tc.sub, tc.addr, got)
+		}
+	}
+}
+
+func TestVRC24CHRHighBits(t *testing.T) {
+	m := NewVRC24(23, vrcData(1))
+	// Bank $14 = 20 (4KB bank 5): low nibble 4 at $E000, high 1 at $E001.
+	m.WritePRG(0xE000, 0x04)
+	m.WritePRG(0xE001, 0x01)
+	if got := m.ReadCHR(0x1800); got != 0xC5 {
+		t.Errorf("$1800 = %#02x, want 0xC5 (1KB bank 20)", got)
+	}
+	if got := m.chrBanks[6]; got != 0x14 {
+		t.Errorf("CHR bank 6 register = %#x, want 0x14", got)
+	}
+}
+
+func TestVRC24PRGSwapMode(t *testing.T) {
+	m := NewVRC24(25, vrcData(1))
+	m.WritePRG(0x8000, 2) // PRG bank 0 = 8KB bank 2 (tag 0xA1)
+	m.WritePRG(0xA000, 4) // PRG bank 1 = 8KB bank 4 (tag 0xA2)
+	for addr, want := range map[uint16]uint8{0x8000: 0xA1, 0xA000: 0xA2, 0xC000: 0xA7, 0xE000: 0x00} {
+		if got := m.ReadPRG(addr); got != want {
+			t.Errorf("normal mode $%04X = %#02x, want %#02x", addr, got, want)
+		}
+	}
+	// VRC4b wires CPU A0 to the chip's A1, so chip $9002 is CPU $9001.
+	m.WritePRG(0x9001, 0x02)
+	for addr, want := range map[uint16]uint8{0x8000: 0xA7, 0xA000: 0xA2, 0xC000: 0xA1} {
+		if got := m.ReadPRG(addr); got != want {
+			t.Errorf("swap mode $%04X = %#02x, want %#02x", addr, got, want)
+		}
+	}
+
+	// A VRC2 has no swap mode: every $9000-$9003 write is mirroring.
+	v := NewVRC24(23, vrcData(3))
+	v.WritePRG(0x8000, 2)
+	v.WritePRG(0x9002, 0x03)
+	if v.ReadPRG(0x8000) != 0xA1 || v.GetMirroringMode() != 0 {
+		t.Errorf("VRC2 $9002: $8000=%#02x mirroring=%d, want 0xA1 and horizontal", v.ReadPRG(0x8000), v.GetMirroringMode())
+	}
+}
+
+func TestVRC24Mirroring(t *testing.T) {
+	m := NewVRC24(21, vrcData(1))
+	for value, want := range map[uint8]MirroringMode{0: MirroringVertical, 1: MirroringHorizontal, 2: MirroringSingleScreenLower, 3: MirroringSingleScreenUpper} {
+		m.WritePRG(0x9000, value)
+		if got := m.GetMirroringMode(); got != want {
+			t.Errorf("$9000=%d: mirroring %d, want %d", value, got, want)
+		}
+	}
+}
+
+// armVRC4IRQ sets the latch and writes the control register on a VRC4f.
+func armVRC4IRQ(m *VRC24, latch, control uint8) {
+	m.WritePRG(0xF000, latch&0x0F)
+	m.WritePRG(0xF001, latch>>4)
+	m.WritePRG(0xF002, control)
+}
+
+func TestVRC24IRQScanlineMode(t *testing.T) {
+	m := NewVRC24(23, vrcData(1))
+	armVRC4IRQ(m, 0xFD, 0x02) // 3 scanlines to overflow
+
+	// The prescaler clocks the counter every 341/3 CPU cycles: at 114,
+	// 228 and 341 cycles for the first three.
+	cycles := 0
+	for !m.IRQLine() && cycles < 1000 {
+		m.TickCPU(1)
+		cycles++
+	}
+	if cycles != 341 {
+		t.Errorf("IRQ after %d cycles, want 341 (three scanlines)", cycles)
+	}
+	if m.irqCounter != 0xFD {
+		t.Errorf("counter after overflow = %#02x, want the latch 0xFD", m.irqCounter)
+	}
+
+	// $F003 acknowledges; with A clear it also stops the counter.
+	m.WritePRG(0xF003, 0)
+	if m.IRQLine() {
+		t.Error("$F003 should acknowledge the IRQ")
+	}
+	m.TickCPU(2000)
+	if m.IRQLine() || m.irqCounter != 0xFD {
+		t.Errorf("counter ran after acknowledge with A=0: %#02x", m.irqCounter)
+	}
+}
+
+func TestVRC24IRQCycleMode(t *testing.T) {
+	m := NewVRC24(23, vrcData(1))
+	armVRC4IRQ(m, 0xF0, 0x07) // cycle mode, A and E set
+	m.TickCPU(15)
+	if m.IRQLine() {
+		t.Fatal("IRQ one cycle early")
+	}
+	m.TickCPU(1)
+	if !m.IRQLine() {
+		t.Fatal("no IRQ after 16 cycles from $F0")
+	}
+	// With A set, acknowledging keeps counting.
+	m.WritePRG(0xF003, 0)
+	m.TickCPU(16)
+	if !m.IRQLine() {
+		t.Error("counter should keep running after acknowledge with A=1")
+	}
+}
+
+func TestVRC2IgnoresIRQRegisters(t *testing.T) {
+	m := NewVRC24(22, vrcData(0))
+	m.WritePRG(0xF000, 0x0F)
+	m.WritePRG(0xF001, 0x0F)
+	m.WritePRG(0xF002, 0x07)
+	m.TickCPU(10)
+	if m.IRQLine() || m.irqControl != 0 {
+		t.Error("VRC2 has no IRQ")
+	}
+}
+
+func TestVRC24State(t *testing.T) {
+	m := NewVRC24(21, vrcData(2))
+	m.WritePRG(0x8000, 6)
+	m.WritePRG(0x9080, 0x02) // swap mode (VRC4c $9002)
+	m.WritePRG(0xC040, 0x08) // VRC4c $C001: CHR bank 2 high bits
+	m.WritePRG(0xF000, 0x0E)
+	m.WritePRG(0xF040, 0x0F)
+	m.WritePRG(0xF080, 0x06)
+	m.TickCPU(50)
+
+	var buf bytes.Buffer
+	if err := m.SaveState(&buf); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	m2 := NewVRC24(21, vrcData(2))
+	if err := m2.LoadState(&buf); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if m2.ReadPRG(0xC000) != m.ReadPRG(0xC000) || m2.chrBanks != m.chrBanks {
+		t.Error("bank state not restored")
+	}
+	if m2.irqCounter != m.irqCounter || m2.irqPrescaler != m.irqPrescaler || m2.irqControl != 0x06 {
+		t.Errorf("IRQ state not restored: counter %#02x prescaler %d", m2.irqCounter, m2.irqPrescaler)
+	}
+}
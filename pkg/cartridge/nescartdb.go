@@ -0,0 +1,110 @@
+package cartridge
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// nesCartDBGame is one <game> of a NesCartDB XML export (the cartridge
+// database started by BootGod): a title and the dumps of its releases,
+// each on the board it was found on.
+type nesCartDBGame struct {
+	Name       string `xml:"name,attr"`
+	Cartridges []struct {
+		System string `xml:"system,attr"`
+		CRC    string `xml:"crc,attr"`
+		Board  struct {
+			Type   string          `xml:"type,attr"`
+			Mapper int             `xml:"mapper,attr"`
+			PRG    []nesCartDBChip `xml:"prg"`
+			CHR    []nesCartDBChip `xml:"chr"`
+			VRAM   []nesCartDBChip `xml:"vram"`
+			WRAM   []nesCartDBChip `xml:"wram"`
+		} `xml:"board"`
+	} `xml:"cartridge"`
+}
+
+// nesCartDBChip is a memory chip on a NesCartDB board; sizes are written
+// like "128k".
+type nesCartDBChip struct {
+	Size    string `xml:"size,attr"`
+	Battery int    `xml:"battery,attr"`
+}
+
+// nesCartDBSize adds up the chips' sizes in bytes, and reports whether
+// any of them is battery-backed.
+func nesCartDBSize(chips []nesCartDBChip) (int, bool) {
+	total, battery := 0, false
+	for _, c := range chips {
+		kb, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(c.Size), "k"))
+		if err == nil {
+			total += kb * 1024
+		}
+		battery = battery || c.Battery != 0
+	}
+	return total, battery
+}
+
+// nesCartDBRegion maps a cartridge's system to GameInfo.Region.
+func nesCartDBRegion(system string) string {
+	switch {
+	case strings.HasPrefix(system, "NES-PAL"):
+		return "PAL"
+	case system == "Dendy":
+		return "Dendy"
+	}
+	return "NTSC"
+}
+
+// decodeNesCartDB passes each dump of each <game> the decoder reads on to
+// add, the board type as Board. NesCartDB records no submappers, and the
+// solder pads it gives for mirroring are left to the header; dumps on
+// mappers above 255 are skipped as in nes20db.
+func decodeNesCartDB(dec *xml.Decoder, add func(uint32, GameInfo)) (int, error) {
+	n := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("NesCartDB: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "game" {
+			continue
+		}
+		var g nesCartDBGame
+		if err := dec.DecodeElement(&g, &start); err != nil {
+			return n, fmt.Errorf("NesCartDB: %w", err)
+		}
+		for _, c := range g.Cartridges {
+			crc, err := strconv.ParseUint(c.CRC, 16, 32)
+			if err != nil || c.Board.Mapper < 0 || c.Board.Mapper > 255 {
+				continue
+			}
+			info := GameInfo{
+				Mapper: uint8(c.Board.Mapper),
+				Name:   g.Name,
+				Region: nesCartDBRegion(c.System),
+				Board:  c.Board.Type,
+			}
+			info.PRGROMSize, _ = nesCartDBSize(c.Board.PRG)
+			info.CHRROMSize, _ = nesCartDBSize(c.Board.CHR)
+			if info.CHRROMSize == 0 {
+				info.CHRRAMSize, _ = nesCartDBSize(c.Board.VRAM)
+			}
+			ram, battery := nesCartDBSize(c.Board.WRAM)
+			if battery {
+				info.PRGNVRAMSize, info.Battery = ram, true
+			} else {
+				info.PRGRAMSize = ram
+			}
+			add(uint32(crc), info)
+			n++
+		}
+	}
+}
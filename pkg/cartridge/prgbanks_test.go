@@ -0,0 +1,173 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/memory"
+)
+
+// loadTaggedCart loads an iNES image and stamps every byte of each 8KB PRG
+// bank with that bank's number, so a window's first byte names its bank.
+func loadTaggedCart(t testing.TB, mapperNum uint8, prg16k int) *Cartridge {
+	t.Helper()
+	cart, err := LoadFromReader(bytes.NewReader(buildINES(mapperNum, prg16k, 1)))
+	if err != nil {
+		t.Fatalf("LoadFromReader: %v", err)
+	}
+	for i := range cart.PRGROM {
+		cart.PRGROM[i] = uint8(i >> 13)
+	}
+	return cart
+}
+
+func windowBanks(cart *Cartridge) [4]uint8 {
+	var got [4]uint8
+	for w := range got {
+		got[w] = cart.ReadPRG(0x8000 + uint16(w)*0x2000)
+	}
+	return got
+}
+
+// mmc1Write feeds a 5-bit value through MMC1's serial port.
+func mmc1Write(cart *Cartridge, addr uint16, v uint8) {
+	for i := 0; i < 5; i++ {
+		cart.WritePRG(addr, v>>i&1)
+	}
+}
+
+func TestPRGBankTables(t *testing.T) {
+	cases := []struct {
+		name   string
+		mapper uint8
+		prg16k int
+		setup  func(*Cartridge)
+		want   [4]uint8
+	}{
+		{"NROM-128 mirrors", 0, 1, nil, [4]uint8{0, 1, 0, 1}},
+		{"NROM-256", 0, 2, nil, [4]uint8{0, 1, 2, 3}},
+		{"CNROM-128 mirrors", 3, 1, nil, [4]uint8{0, 1, 0, 1}},
+		{"UxROM bank 5", 2, 8, func(c *Cartridge) { c.WritePRG(0x8000, 5) }, [4]uint8{10, 11, 14, 15}},
+		{"MMC1 power-on", 1, 8, nil, [4]uint8{0, 1, 14, 15}},
+		{"MMC1 mode 3 bank 2", 1, 8, func(c *Cartridge) { mmc1Write(c, 0xE000, 2) }, [4]uint8{4, 5, 14, 15}},
+		{"MMC1 mode 2 bank 2", 1, 8, func(c *Cartridge) {
+			mmc1Write(c, 0x8000, 0x08)
+			mmc1Write(c, 0xE000, 2)
+		}, [4]uint8{0, 1, 4, 5}},
+		{"MMC1 32KB bank 3 (low bit ignored)", 1, 8, func(c *Cartridge) {
+			mmc1Write(c, 0x8000, 0x00)
+			mmc1Write(c, 0xE000, 3)
+		}, [4]uint8{4, 5, 6, 7}},
+		{"MMC1 RAM-disable bit is not a bank bit", 1, 8, func(c *Cartridge) { mmc1Write(c, 0xE000, 0x12) }, [4]uint8{4, 5, 14, 15}},
+		{"MMC3 mode 0", 4, 4, func(c *Cartridge) {
+			c.WritePRG(0x8000, 6)
+			c.WritePRG(0x8001, 3)
+			c.WritePRG(0x8000, 7)
+			c.WritePRG(0x8001, 4)
+		}, [4]uint8{3, 4, 6, 7}},
+		{"MMC3 mode 1", 4, 4, func(c *Cartridge) {
+			c.WritePRG(0x8000, 6)
+			c.WritePRG(0x8001, 3)
+			c.WritePRG(0x8000, 0x47)
+			c.WritePRG(0x8001, 4)
+		}, [4]uint8{6, 4, 3, 7}},
+	}
+	for _, c := range cases {
+		cart := loadTaggedCart(t, c.mapper, c.prg16k)
+		if cart.PRGBanks() == nil {
+			t.Errorf("%s: mapper %d should expose a PRG bank table", c.name, c.mapper)
+			continue
+		}
+		if c.setup != nil {
+			c.setup(cart)
+		}
+		if got := windowBanks(cart); got != c.want {
+			t.Errorf("%s: banks %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// TestBusReadsThroughPRGTable checks the memory bus fast path returns the
+// same bytes as ReadPRG, including after a bank switch and a state load.
+func TestBusReadsThroughPRGTable(t *testing.T) {
+	cart := loadTaggedCart(t, 4, 4)
+	mem := memory.New()
+	mem.SetCartridge(cart)
+
+	check := func(stage string) {
+		t.Helper()
+		for addr := 0x8000; addr <= 0xFFFF; addr += 0x3FF {
+			if got, want := mem.Read(uint16(addr)), cart.ReadPRG(uint16(addr)); got != want {
+				t.Fatalf("%s: bus $%04X = %d, ReadPRG = %d", stage, addr, got, want)
+			}
+		}
+	}
+	check("power-on")
+
+	var state bytes.Buffer
+	if err := cart.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	cart.WritePRG(0x8000, 0x46)
+	cart.WritePRG(0x8001, 2)
+	check("after bank switch")
+	if mem.Read(0xC000) != 2 {
+		t.Errorf("bus didn't follow the R6 switch: $C000 = %d", mem.Read(0xC000))
+	}
+
+	if err := cart.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	check("after LoadState")
+}
+
+// plainPRG hides Cartridge.PRGBanks so the bus takes the ReadPRG path.
+type plainPRG struct{ c *Cartridge }
+
+func (p plainPRG) ReadPRG(addr uint16) uint8     { return p.c.ReadPRG(addr) }
+func (p plainPRG) WritePRG(addr uint16, v uint8) { p.c.WritePRG(addr, v) }
+func (p plainPRG) HasExpansion() bool            { return false }
+
+func benchmarkBusPRG(b *testing.B, bus memory.CartridgeBus) {
+	mem := memory.New()
+	mem.SetCartridge(bus)
+	b.ResetTimer()
+	var sink uint8
+	for i := 0; i < b.N; i++ {
+		sink += mem.Read(0x8000 | uint16(i)&0x7FFF)
+	}
+	_ = sink
+}
+
+// BenchmarkBusReadPRGTable / BenchmarkBusReadPRGMapper compare MMC3 PRG
+// fetches through the bank table against the per-read ReadPRG dispatch.
+func BenchmarkBusReadPRGTable(b *testing.B) {
+	benchmarkBusPRG(b, loadTaggedCart(b, 4, 16))
+}
+
+func BenchmarkBusReadPRGMapper(b *testing.B) {
+	benchmarkBusPRG(b, plainPRG{loadTaggedCart(b, 4, 16)})
+}
+
+func TestPRGOffset(t *testing.T) {
+	cart := loadTaggedCart(t, 2, 8) // UxROM, 128KB
+	cart.WritePRG(0x8000, 5)
+	for _, tc := range []struct {
+		addr uint16
+		want int
+	}{
+		{0x8000, 5 * 0x4000},
+		{0xA123, 5*0x4000 + 0x2123},
+		{0xC000, 7 * 0x4000},
+		{0xFFFF, 8*0x4000 - 1},
+		{0x6000, -1},
+	} {
+		if got := cart.PRGOffset(tc.addr); got != tc.want {
+			t.Errorf("PRGOffset($%04X) = $%X, want $%X", tc.addr, got, tc.want)
+		}
+	}
+	cart.prgBanks = nil
+	if got := cart.PRGOffset(0x8000); got != -1 {
+		t.Errorf("PRGOffset without a bank table = %d, want -1", got)
+	}
+}
@@ -0,0 +1,110 @@
+package cartridge
+
+// VS. System (Nintendo VS. UniSystem) arcade boards. The cartridge side
+// is an ordinary mapper (99 for the slot's own OUT2 banking, others for
+// the later boards); what sets a VS. game apart is in the header: which
+// RGB PPU the board has (see ppu.VSModel) and, for a few titles, a
+// protection chip in $5000-$5FFF that the game checks for.
+
+// NES 2.0 Vs. hardware types (header byte 13 bits 4-7) with protection.
+const (
+	vsRBIBaseball  = 1 // RBI Baseball: $5E00 resets, $5E01 counts
+	vsTKOBoxing    = 2 // TKO Boxing: $5E01 steps through a table
+	vsSuperXevious = 3 // Super Xevious: fixed answers, one toggle
+)
+
+// VSSystem reports whether the image is for a VS. System: flags 7 bit 0,
+// or console type 1 in a NES 2.0 header.
+func (h iNESHeader) VSSystem() bool {
+	if h.IsNES20() {
+		return h.Flags7&0x03 == 1
+	}
+	return h.Flags7&0x01 != 0
+}
+
+// VSPPUType returns the NES 2.0 Vs. PPU type (byte 13 bits 0-3; see
+// ppu.VSModel), or 0 (RP2C03B) when the header doesn't say.
+func (h iNESHeader) VSPPUType() uint8 {
+	if !h.IsNES20() || !h.VSSystem() {
+		return 0
+	}
+	return h.Padding[2] & 0x0F
+}
+
+// VSHardwareType returns the NES 2.0 Vs. hardware type (byte 13 bits
+// 4-7): 0 for the plain UniSystem, 1-3 for the protected boards above.
+func (h iNESHeader) VSHardwareType() uint8 {
+	if !h.IsNES20() || !h.VSSystem() {
+		return 0
+	}
+	return h.Padding[2] >> 4
+}
+
+// VS reports whether the cartridge is a VS. System game, which runs in
+// the cabinet's inputs (input.VSPanel) and on an RGB PPU.
+func (c *Cartridge) VS() bool { return c.Header.VSSystem() }
+
+// vsProtection answers a protected board's $5000-$5FFF reads.
+type vsProtection struct {
+	kind    uint8
+	counter uint8
+}
+
+// tkoBoxingData is what $5E01 returns on TKO Boxing, in order.
+var tkoBoxingData = [32]uint8{
+	0xFF, 0xBF, 0xB7, 0x97, 0x97, 0x17, 0x57, 0x4F, 0x6F, 0x6B, 0xEB, 0xA9, 0xB1, 0x90, 0x94, 0x14,
+	0x56, 0x4E, 0x6F, 0x6B, 0xEB, 0xA9, 0xB1, 0x90, 0xD4, 0x5C, 0x3E, 0x26, 0x87, 0x83, 0x13, 0x00,
+}
+
+// read returns the value at addr. Addresses the chip doesn't answer
+// read as open bus, which after an absolute read is the high byte of the
+// address.
+func (p *vsProtection) read(addr uint16) uint8 {
+	switch p.kind {
+	case vsRBIBaseball, vsTKOBoxing:
+		switch addr {
+		case 0x5E00:
+			p.counter = 0
+		case 0x5E01:
+			n := p.counter
+			p.counter++
+			if p.kind == vsTKOBoxing {
+				return tkoBoxingData[n&0x1F]
+			}
+			if n == 9 {
+				return 0x6F
+			}
+			return 0xB4
+		}
+	case vsSuperXevious:
+		switch addr {
+		case 0x54FF:
+			return 0x05
+		case 0x5678:
+			if p.counter != 0 {
+				return 0x00
+			}
+			return 0x01
+		case 0x578F:
+			if p.counter != 0 {
+				return 0xD1
+			}
+			return 0x89
+		case 0x5567:
+			p.counter ^= 1
+			if p.counter != 0 {
+				return 0x37
+			}
+			return 0x3E
+		}
+	}
+	return uint8(addr >> 8)
+}
+
+// WriteOUT passes a $4016 write to boards wired to the OUT latch (see
+// mapper.OUTLatchWatcher).
+func (c *Cartridge) WriteOUT(value uint8) {
+	if c.outWatcher != nil {
+		c.outWatcher.WriteOUT(value)
+	}
+}
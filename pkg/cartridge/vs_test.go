@@ -0,0 +1,54 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVSHeader(t *testing.T) {
+	img := buildINES(99, 2, 1)
+	img[7] |= 0x01 // iNES 1.0 VS. System flag
+	cart, err := LoadFromReader(bytes.NewReader(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cart.VS() || cart.Header.VSPPUType() != 0 || cart.HasExpansion() {
+		t.Error("an iNES 1.0 VS. game should load with the default PPU and no protection")
+	}
+
+	// NES 2.0: console type 1, RP2C04-0002 with TKO Boxing's protection.
+	img[7] = img[7]&0xF0 | 0x09
+	img[13] = 2<<4 | 3
+	cart, err = LoadFromReader(bytes.NewReader(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cart.VS() || cart.Header.VSPPUType() != 3 || !cart.HasExpansion() {
+		t.Fatal("NES 2.0 byte 13 should give the PPU type and the protection chip")
+	}
+	cart.ReadPRG(0x5E00)
+	var got []uint8
+	for i := 0; i < 3; i++ {
+		got = append(got, cart.ReadPRG(0x5E01))
+	}
+	if !bytes.Equal(got, tkoBoxingData[:3]) {
+		t.Errorf("$5E01 reads % X, want % X", got, tkoBoxingData[:3])
+	}
+	if v := cart.ReadPRG(0x5123); v != 0x51 {
+		t.Errorf("unanswered $5123 = %02X, want open bus $51", v)
+	}
+}
+
+func TestVSProtectionRBIBaseball(t *testing.T) {
+	p := &vsProtection{kind: vsRBIBaseball}
+	p.read(0x5E00)
+	for i := 0; i < 10; i++ {
+		want := uint8(0xB4)
+		if i == 9 {
+			want = 0x6F
+		}
+		if got := p.read(0x5E01); got != want {
+			t.Errorf("read %d of $5E01 = %02X, want %02X", i, got, want)
+		}
+	}
+}
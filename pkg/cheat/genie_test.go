@@ -79,4 +79,22 @@ func TestApply(t *testing.T) {
 	if got := m.Apply(0x8001, 0x00); got != 0x00 {
 		t.Errorf("disabled: got %#02x, want passthrough 0x00", got)
 	}
+	m.SetEnabled(true)
+	if got := m.Apply(0x8001, 0x00); got != 0x42 {
+		t.Errorf("re-enabled: got %#02x, want 0x42", got)
+	}
+
+	// One cheat off leaves the others patching.
+	if m.Toggle(0) {
+		t.Error("Toggle(0) reported the cheat still on")
+	}
+	if got := m.Apply(0x8001, 0x00); got != 0x00 {
+		t.Errorf("cheat 0 off: got %#02x, want passthrough 0x00", got)
+	}
+	if got := m.Apply(0x8002, 0x55); got != 0x99 {
+		t.Errorf("cheat 0 off: cheat 1 got %#02x, want 0x99", got)
+	}
+	if !m.Toggle(0) || m.Toggle(5) {
+		t.Error("Toggle(0) should turn it back on and Toggle(5) do nothing")
+	}
 }
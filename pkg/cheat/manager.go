@@ -42,18 +42,36 @@ func (m *Manager) Add(c Cheat) {
 	m.cheats = append(m.cheats, c)
 }
 
+// Clear drops every loaded cheat, e.g. when a different ROM is swapped in
+// and the old codes would patch unrelated addresses. The global enable
+// switch is left as the user set it.
+func (m *Manager) Clear() { m.cheats = nil }
+
 // Count returns the number of loaded cheats.
 func (m *Manager) Count() int { return len(m.cheats) }
 
 // Enabled reports whether the global switch is on.
 func (m *Manager) Enabled() bool { return m.enabled }
 
+// SetEnabled sets the global switch, e.g. from the configured default.
+func (m *Manager) SetEnabled(on bool) { m.enabled = on }
+
 // ToggleAll flips the global enable switch and returns the new state.
 func (m *Manager) ToggleAll() bool {
 	m.enabled = !m.enabled
 	return m.enabled
 }
 
+// Toggle flips cheat i (an index into List) on or off and returns its new
+// state; an index out of range changes nothing and reports false.
+func (m *Manager) Toggle(i int) bool {
+	if i < 0 || i >= len(m.cheats) {
+		return false
+	}
+	m.cheats[i].Enabled = !m.cheats[i].Enabled
+	return m.cheats[i].Enabled
+}
+
 // Apply returns the patched byte for addr if a matching enabled cheat
 // exists, otherwise it returns current unchanged. current is the byte the
 // underlying memory subsystem would have returned — needed for the
@@ -128,4 +128,9 @@ func TestManagerListAndString(t *testing.T) {
 			t.Errorf("list[%d].String() = %q, want %q", i, got, w)
 		}
 	}
+
+	m.Clear()
+	if m.Count() != 0 || m.Apply(0x1234, 0x00) != 0x00 {
+		t.Errorf("after Clear: Count=%d, Apply still patches", m.Count())
+	}
 }
@@ -0,0 +1,232 @@
+// Package chrdiff watches the pattern tables ($0000-$1FFF of PPU space)
+// change from frame to frame, a 1KB slot at a time, and blames each
+// change on the CPU write that made it: a mapper register write that
+// switched a CHR ROM bank in, or a $2007 write into CHR RAM. It's meant
+// for mapper debugging — a game drawing garbage tiles usually means a
+// bank register write went to the wrong slot, or never happened.
+//
+// A write's effect is found by comparing the pattern tables before it
+// with what they hold at the next write (or the end of the frame), so
+// writes to the mapper range cost a read of all 8KB each while a Diff is
+// on. With mapper logging on, every change is also logged at the point
+// it happens, among the mapper's own messages.
+//
+// A slot can change with no write to blame: MMC2/MMC4 switch banks on
+// the tiles the PPU fetches, and loading a state replaces everything.
+package chrdiff
+
+import (
+	"fmt"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/memory"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// Slots is the number of 1KB slots the pattern tables are watched in;
+// SlotSize is their size.
+const (
+	Slots    = 8
+	SlotSize = 0x400
+)
+
+// MaxWrites bounds the writes a Slot keeps for one frame; later ones are
+// only counted.
+const MaxWrites = 16
+
+// Write is one CPU write that changed the pattern tables.
+type Write struct {
+	Frame    uint64 // ppu.PPU.Frame
+	Scanline int    // -1 for the pre-render line
+	Dot      int    // 0-340
+	PC       uint16 // the writing instruction
+	Address  uint16 // the CPU address written: a mapper register or $2007
+	Target   uint16 // for $2007, the pattern table byte written
+	Value    uint8
+}
+
+// DataWrite reports whether w is a $2007 write into CHR RAM rather than
+// a mapper register write.
+func (w Write) DataWrite() bool { return w.Address < 0x4000 }
+
+// String renders w for a log line:
+//
+//	F123 SL 31 DOT 256  $C123  $8001 = $04
+//	F123 SL 241 DOT 20  $C5A0  $2007 = $FF (CHR $0400)
+func (w Write) String() string {
+	s := fmt.Sprintf("F%d SL %d DOT %d  $%04X  $%04X = $%02X", w.Frame, w.Scanline, w.Dot, w.PC, w.Address, w.Value)
+	if w.DataWrite() {
+		s += fmt.Sprintf(" (CHR $%04X)", w.Target)
+	}
+	return s
+}
+
+// Slot is what happened to one 1KB slot in a frame.
+type Slot struct {
+	// Changed reports whether the slot holds other data than at the end
+	// of the frame before.
+	Changed bool
+	// Writes are the writes that changed the slot during the frame,
+	// oldest first and up to MaxWrites; More counts the rest. A bank
+	// switched in and back out again within the frame shows here with
+	// Changed false.
+	Writes []Write
+	More   int
+}
+
+// Diff watches one NES. Like the machine, it isn't safe for concurrent
+// use.
+type Diff struct {
+	nes   *nes.NES
+	hooks [2]memory.HookID
+
+	// cur is the pattern tables as of the last write settled; prev as of
+	// the end of the last frame.
+	cur, prev [Slots][SlotSize]uint8
+
+	// pending is the write whose effect settle hasn't looked at yet;
+	// for a $2007 write, old is the byte it overwrites.
+	pending    Write
+	hasPending bool
+	old        uint8
+
+	// current collects the frame being run; last is the one EndFrame
+	// finished before it.
+	current, last [Slots]Slot
+}
+
+// Start installs a diff on n's bus, taking the pattern tables as they are
+// now as the previous frame's. Stop removes it.
+func Start(n *nes.NES) *Diff {
+	d := &Diff{nes: n}
+	d.read(&d.cur)
+	d.prev = d.cur
+	d.hooks = [2]memory.HookID{
+		n.Memory.AddWriteHook(0x2000, 0x3FFF, d.ppuWrite),
+		n.Memory.AddWriteHook(0x4020, 0xFFFF, d.mapperWrite),
+	}
+	return d
+}
+
+// Stop uninstalls the diff's bus hooks; what it recorded stays readable.
+func (d *Diff) Stop() {
+	for _, id := range d.hooks {
+		d.nes.Memory.RemoveHook(id)
+	}
+}
+
+// read copies the pattern tables as the PPU sees them now into dst.
+func (d *Diff) read(dst *[Slots][SlotSize]uint8) {
+	cart := d.nes.Cartridge
+	if cart == nil {
+		*dst = [Slots][SlotSize]uint8{}
+		return
+	}
+	for s := range dst {
+		for i := range dst[s] {
+			dst[s][i] = cart.ReadCHR(uint16(s*SlotSize + i))
+		}
+	}
+}
+
+// ppuWrite runs before a PPU register write lands: a $2007 write into
+// the pattern tables may change one byte.
+func (d *Diff) ppuWrite(addr uint16, value uint8) uint8 {
+	if addr&7 != 7 {
+		return value
+	}
+	target := d.nes.PPU.VRAMAddress()
+	if target >= 0x2000 {
+		return value
+	}
+	d.settle()
+	if d.nes.Cartridge != nil {
+		d.old = d.nes.Cartridge.ReadCHR(target)
+	}
+	d.hold(addr, target, value)
+	return value
+}
+
+// mapperWrite runs before a write to the cartridge lands.
+func (d *Diff) mapperWrite(addr uint16, value uint8) uint8 {
+	d.settle()
+	d.hold(addr, 0, value)
+	return value
+}
+
+// hold makes a write the pending one.
+func (d *Diff) hold(addr, target uint16, value uint8) {
+	p := d.nes.PPU
+	d.pending = Write{
+		Frame:    p.Frame,
+		Scanline: p.Scanline,
+		Dot:      p.Cycle,
+		PC:       d.nes.CPU.InstructionPC(),
+		Address:  addr,
+		Target:   target,
+		Value:    value,
+	}
+	d.hasPending = true
+}
+
+// settle looks at what the pending write did, now that it has landed,
+// and charges it to the slots it changed.
+func (d *Diff) settle() {
+	if !d.hasPending {
+		return
+	}
+	d.hasPending = false
+	w := d.pending
+	if w.DataWrite() {
+		s, i := int(w.Target/SlotSize), int(w.Target%SlotSize)
+		if d.nes.Cartridge == nil {
+			return
+		}
+		if v := d.nes.Cartridge.ReadCHR(w.Target); v != d.old {
+			d.cur[s][i] = v
+			d.blame(s, w)
+		}
+		return
+	}
+	var now [Slots][SlotSize]uint8
+	d.read(&now)
+	for s := range now {
+		if now[s] != d.cur[s] {
+			d.cur[s] = now[s]
+			d.blame(s, w)
+		}
+	}
+}
+
+func (d *Diff) blame(slot int, w Write) {
+	sl := &d.current[slot]
+	if len(sl.Writes) < MaxWrites {
+		sl.Writes = append(sl.Writes, w)
+	} else {
+		sl.More++
+	}
+	logger.LogMapper("CHR $%04X-$%04X changed by %v", slot*SlotSize, slot*SlotSize+SlotSize-1, w)
+}
+
+// EndFrame is called after each StepFrame: the frame's changes become
+// LastFrame and Snapshot, and recording starts afresh.
+func (d *Diff) EndFrame() {
+	d.settle()
+	d.read(&d.cur)
+	for s := range d.current {
+		d.current[s].Changed = d.cur[s] != d.prev[s]
+	}
+	d.prev = d.cur
+	d.last, d.current = d.current, d.last
+	for s := range d.current {
+		d.current[s] = Slot{Writes: d.current[s].Writes[:0]}
+	}
+}
+
+// LastFrame returns what happened to each slot in the frame EndFrame last
+// finished, $0000-$03FF first. It is only valid until the next EndFrame.
+func (d *Diff) LastFrame() *[Slots]Slot { return &d.last }
+
+// Snapshot returns the pattern tables as they were at the end of that
+// frame, a slot per 1KB. It is only valid until the next EndFrame.
+func (d *Diff) Snapshot() *[Slots][SlotSize]uint8 { return &d.prev }
@@ -0,0 +1,90 @@
+package chrdiff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
+)
+
+// testNES runs program from $8000 on a one-bank cartridge of the given
+// mapper with chr (CHR RAM when empty). The PPU skips its warm-up so
+// the program can write $2006 straight away.
+func testNES(t *testing.T, mapper int, program, chr []byte) *nes.NES {
+	t.Helper()
+	rom := testrom.New().Mapper(mapper).Org(0x8000, program)
+	if len(chr) == 0 {
+		rom.CHRRAM(8)
+	} else {
+		rom.CHR(len(chr)/1024).CHRAt(0, chr)
+	}
+	n, err := rom.NES(nes.WithPPUWarmUp(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestBankSwitch(t *testing.T) {
+	// CNROM: bank 1 is all $FF; the program switches it in, then spins.
+	chr := append(make([]byte, 0x2000), bytes.Repeat([]byte{0xFF}, 0x2000)...)
+	n := testNES(t, 3, []byte{
+		0xA9, 0x01, 0x8D, 0x00, 0x80, // LDA #$01; STA $8000
+		0x4C, 0x05, 0x80, // JMP $8005
+	}, chr)
+	d := Start(n)
+	defer d.Stop()
+
+	n.StepFrame()
+	d.EndFrame()
+	for s, sl := range d.LastFrame() {
+		if !sl.Changed || len(sl.Writes) != 1 {
+			t.Fatalf("slot %d: %+v, want changed by one write", s, sl)
+		}
+		if w := sl.Writes[0]; w.PC != 0x8002 || w.Address != 0x8000 || w.Value != 1 || w.DataWrite() {
+			t.Errorf("slot %d blamed on %v", s, w)
+		}
+	}
+	if d.Snapshot()[7][0x3FF] != 0xFF {
+		t.Error("the snapshot should hold bank 1")
+	}
+
+	n.StepFrame()
+	d.EndFrame()
+	for s, sl := range d.LastFrame() {
+		if sl.Changed || len(sl.Writes) != 0 {
+			t.Errorf("slot %d changed again in an idle frame: %+v", s, sl)
+		}
+	}
+}
+
+func TestCHRRAMWrites(t *testing.T) {
+	n := testNES(t, 0, []byte{
+		0xA9, 0x04, 0x8D, 0x06, 0x20, // LDA #$04; STA $2006
+		0xA9, 0x00, 0x8D, 0x06, 0x20, // LDA #$00; STA $2006
+		0xA9, 0x55, 0x8D, 0x07, 0x20, // LDA #$55; STA $2007 -> $0400
+		0x8D, 0x07, 0x20, // STA $2007 -> $0401
+		0xA9, 0x00, 0x8D, 0x07, 0x20, // LDA #$00; STA $2007 -> $0402, unchanged
+		0x4C, 0x17, 0x80, // JMP $8017
+	}, nil)
+	d := Start(n)
+	defer d.Stop()
+
+	n.StepFrame()
+	d.EndFrame()
+	slots := d.LastFrame()
+	for s, sl := range slots {
+		if want := s == 1; sl.Changed != want {
+			t.Errorf("slot %d Changed = %v, want %v", s, sl.Changed, want)
+		}
+	}
+	writes := slots[1].Writes
+	if len(writes) != 2 || writes[0].Target != 0x0400 || writes[1].Target != 0x0401 || writes[1].PC != 0x800F {
+		t.Fatalf("slot 1 writes %v, want $0400 and $0401", writes)
+	}
+	if s := writes[0].String(); !strings.Contains(s, "$800C  $2007 = $55 (CHR $0400)") {
+		t.Errorf("String = %q", s)
+	}
+}
@@ -0,0 +1,490 @@
+// Package config loads and saves the emulator's settings file,
+// <user config dir>/gones/config.toml (~/.config/gones/config.toml on
+// Linux), and binds every setting to the cmd/gones command-line flag that
+// overrides it.
+//
+// The precedence is defaults < file < flags: Load decodes the file over
+// Default(), Bind registers the flags with the loaded values as their
+// defaults, and flag parsing then overwrites only what was given on the
+// command line. Saving the result writes back a file that reproduces the
+// same run, which is what -save-config does.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+)
+
+// Config is the whole settings file. Each field is one [section].
+type Config struct {
+	Video     Video     `toml:"video"`
+	Audio     Audio     `toml:"audio"`
+	Emulation Emulation `toml:"emulation"`
+	Input     Input     `toml:"input"`
+	Paths     Paths     `toml:"paths"`
+	Cheats    Cheats    `toml:"cheats"`
+	Log       Log       `toml:"log"`
+	Debug     Debug     `toml:"debug"`
+}
+
+// Video holds display and window settings.
+type Video struct {
+	Scale   int    `toml:"scale"`    // window size as a multiple of 256×240
+	Palette string `toml:"palette"`  // .pal file; empty for the built-in palette
+	FastPPU bool   `toml:"fast_ppu"` // scanline renderer (-fast-ppu)
+	// NoSpriteLimit draws every sprite on a scanline instead of the
+	// hardware's 8, to stop flicker; the overflow flag is unaffected.
+	NoSpriteLimit bool `toml:"no_sprite_limit"`
+	// PauseInBackground holds emulation while the window is unfocused.
+	PauseInBackground bool `toml:"pause_in_background"`
+	// Pacing times frames: "hybrid" (sleep, then spin to the deadline),
+	// "sleep", or "vsync" (present on vblank, run off the audio clock).
+	Pacing string `toml:"pacing"`
+	// Filter scales the picture in software before it is shown: nearest
+	// (none), scale2x, xbr or crt (package vfilter).
+	Filter string `toml:"filter"`
+	// FrameSkip (0-9) draws one frame in FrameSkip+1, still emulating
+	// every one; AutoFrameSkip also skips while emulation runs behind.
+	FrameSkip     int  `toml:"frame_skip"`
+	AutoFrameSkip bool `toml:"auto_frame_skip"`
+	// Overscan crops this many pixels (0-64) off each edge of the picture
+	// in the window and screenshots.
+	OverscanTop    int `toml:"overscan_top"`
+	OverscanBottom int `toml:"overscan_bottom"`
+	OverscanLeft   int `toml:"overscan_left"`
+	OverscanRight  int `toml:"overscan_right"`
+	// InputDisplay draws the controllers' buttons in the corner of the
+	// picture, in the window and in -dump-frames PNGs.
+	InputDisplay bool `toml:"input_display"`
+	// NTSCPalette generates the colours from the 2C02's video signal
+	// instead of a fixed palette (ppu.NTSC), with a TV's picture
+	// controls: hue in degrees (-180-180), saturation and contrast in
+	// percent (0-200), brightness in percent of white (-100-100) and the
+	// TV's gamma in hundredths (100-350). It wins over Palette.
+	NTSCPalette    bool `toml:"ntsc_palette"`
+	NTSCHue        int  `toml:"ntsc_hue"`
+	NTSCSaturation int  `toml:"ntsc_saturation"`
+	NTSCBrightness int  `toml:"ntsc_brightness"`
+	NTSCContrast   int  `toml:"ntsc_contrast"`
+	NTSCGamma      int  `toml:"ntsc_gamma"`
+}
+
+// Audio holds sound output settings.
+type Audio struct {
+	// LatencyMs caps how much audio is queued ahead of the device. 0 keeps
+	// the automatic cap of two device buffers.
+	LatencyMs int `toml:"latency_ms"`
+	// BufferSamples is the SDL device buffer in sample frames, a power of
+	// two. 0 picks one from LatencyMs.
+	BufferSamples int `toml:"buffer_samples"`
+	// Volume is the master volume in percent (1-100); Muted starts with
+	// sound off. Both can be changed while running with the volume hotkeys.
+	Volume int  `toml:"volume"`
+	Muted  bool `toml:"muted"`
+	// Console is "famicom", which mixes a cartridge's sound chip in with
+	// the 2A03, or "nes": the front-loader's slot didn't route it.
+	Console string `toml:"console"`
+	// Level5B, LevelVRC6, LevelMMC5, LevelFDS and LevelN163 are each
+	// expansion chip's level in percent of its native one (0-200).
+	Level5B   int `toml:"level_5b"`
+	LevelVRC6 int `toml:"level_vrc6"`
+	LevelMMC5 int `toml:"level_mmc5"`
+	LevelFDS  int `toml:"level_fds"`
+	LevelN163 int `toml:"level_n163"`
+}
+
+// Emulation holds console and power-on settings.
+type Emulation struct {
+	Region    string `toml:"region"` // "ntsc", or "pal" for the APU's period tables only
+	RAMInit   string `toml:"ram_init"`
+	RAMSeed   int64  `toml:"ram_seed"`
+	PPUAlign  int    `toml:"ppu_align"`
+	PPUWarmUp bool   `toml:"ppu_warmup"`
+	TrapJAM   bool   `toml:"trap_jam"`
+	FourScore bool   `toml:"four_score"`
+	// Expansion is the device in the Famicom expansion port, by its
+	// input.RegisterExpansion name ("keyboard"); "" for none.
+	Expansion string `toml:"expansion"`
+	// VSDIPs are a VS. System game's DIP switches, switch 1 in bit 0.
+	VSDIPs int `toml:"vs_dips"`
+	// VSPPU overrides the RGB PPU a VS. System game runs on, by its
+	// ppu.ParseModel name ("2c04-0001"); "" takes it from the header.
+	VSPPU string `toml:"vs_ppu"`
+	// Deterministic pins every power-on input left to chance (see
+	// nes.WithDeterministic), for movies and netplay.
+	Deterministic bool `toml:"deterministic"`
+	// Autosave writes <rom>.autosave every Autosave seconds of play and
+	// on exit, and offers to resume it next time; 0 = off.
+	Autosave int `toml:"autosave"`
+}
+
+// Input holds player 1's keyboard bindings, as SDL key names ("Z", "Up",
+// "Left Shift", ...).
+type Input struct {
+	A      string `toml:"a"`
+	B      string `toml:"b"`
+	Select string `toml:"select"`
+	Start  string `toml:"start"`
+	Up     string `toml:"up"`
+	Down   string `toml:"down"`
+	Left   string `toml:"left"`
+	Right  string `toml:"right"`
+}
+
+// Keys returns the bindings in NES button order (A, B, Select, Start, Up,
+// Down, Left, Right).
+func (in Input) Keys() [8]string {
+	return [8]string{in.A, in.B, in.Select, in.Start, in.Up, in.Down, in.Left, in.Right}
+}
+
+// Paths holds where companion files go. Empty means next to the ROM
+// (saves, states), the working directory (screenshots) or
+// <user config dir>/gones/games (per-game settings; see GamePath).
+// FDSBIOS is the Disk System BIOS, by default disksys.rom in the user
+// config dir (see FDSBIOSPath).
+type Paths struct {
+	Saves       string `toml:"saves"`
+	States      string `toml:"states"`
+	Screenshots string `toml:"screenshots"`
+	Games       string `toml:"games"`
+	FDSBIOS     string `toml:"fds_bios"`
+	GameDB      string `toml:"gamedb"`
+}
+
+// FDSBIOSPath returns p.FDSBIOS, or <user config dir>/gones/disksys.rom
+// when it is empty ("" when the platform has no config directory).
+func (p Paths) FDSBIOSPath() string {
+	if p.FDSBIOS != "" {
+		return p.FDSBIOS
+	}
+	base := DefaultPath()
+	if base == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(base), "disksys.rom")
+}
+
+// GameDBPath returns p.GameDB, or <user config dir>/gones/nes20db.xml
+// when it is empty ("" when the platform has no config directory).
+func (p Paths) GameDBPath() string {
+	if p.GameDB != "" {
+		return p.GameDB
+	}
+	base := DefaultPath()
+	if base == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(base), "nes20db.xml")
+}
+
+// Cheats holds cheat defaults.
+type Cheats struct {
+	AutoLoad bool `toml:"autoload"` // load <rom>.cht when a ROM is opened
+	Enabled  bool `toml:"enabled"`  // initial state of the Ctrl+H switch
+}
+
+// Log holds logger settings; see package logger.
+type Log struct {
+	Level      string `toml:"level"`
+	File       string `toml:"file"`
+	CPU        bool   `toml:"cpu"`
+	PPU        bool   `toml:"ppu"`
+	APU        bool   `toml:"apu"`
+	Mapper     bool   `toml:"mapper"`
+	Bus        bool   `toml:"bus"`
+	Components string `toml:"components"`
+	JSON       bool   `toml:"json"`
+	Ring       int    `toml:"ring"`
+}
+
+// Debug holds headless-run and profiling settings.
+type Debug struct {
+	Headless   bool   `toml:"headless"`
+	TestFrames int    `toml:"test_frames"`
+	CPUProfile string `toml:"cpuprofile"`
+	MemProfile string `toml:"memprofile"`
+	// DumpFrames is a directory to write every DumpEvery-th headless
+	// frame to as PNG; HashFrames prints a CRC-32 of every frame.
+	DumpFrames string `toml:"dump_frames"`
+	DumpEvery  int    `toml:"dump_every"`
+	HashFrames bool   `toml:"hash_frames"`
+	// Remote serves the remote-control protocol (package remote) on a
+	// unix socket, TCP address or "stdio" instead of opening a window.
+	Remote string `toml:"remote"`
+	// GDBPort serves the GDB remote protocol (package gdb) on this
+	// localhost port in GUI mode; 0 = off. GDBUndo is how many
+	// instructions a debugger can step back through (package undo).
+	GDBPort int `toml:"gdb_port"`
+	GDBUndo int `toml:"gdb_undo"`
+	// Trace keeps the last Trace instructions in memory (package trace),
+	// written out on demand, on a CPU jam or on a crash; 0 = off.
+	// TraceFilter narrows them, as parsed by trace.ParseFilter.
+	Trace       int    `toml:"trace"`
+	TraceFilter string `toml:"trace_filter"`
+	// Watch reloads the ROM whenever its file changes, keeping what
+	// WatchKeep names: power (nothing), ram or state (see nes.SwapMode).
+	Watch     bool   `toml:"watch"`
+	WatchKeep string `toml:"watch_keep"`
+	// StatsCSV writes the GUI's per-frame timing (gui.Stats) to this file.
+	StatsCSV string `toml:"stats_csv"`
+}
+
+// Default returns the settings used when there is no config file — the
+// same values the flags defaulted to before the file existed.
+func Default() Config {
+	return Config{
+		Video: Video{
+			Scale: 3, Pacing: "hybrid", Filter: "nearest", OverscanTop: 8, OverscanBottom: 8,
+			NTSCSaturation: 100, NTSCContrast: 100, NTSCGamma: 220,
+		},
+		Audio:     Audio{Volume: 100, Console: "famicom", Level5B: 100, LevelVRC6: 100, LevelMMC5: 100, LevelFDS: 100, LevelN163: 100},
+		Emulation: Emulation{Region: "ntsc", RAMInit: "00", PPUWarmUp: true},
+		Input: Input{
+			A: "Z", B: "X", Select: "A", Start: "S",
+			Up: "Up", Down: "Down", Left: "Left", Right: "Right",
+		},
+		Cheats: Cheats{AutoLoad: true, Enabled: true},
+		Log:    Log{Level: "info", Ring: logger.DefaultRingSize},
+		Debug:  Debug{TestFrames: 600, DumpEvery: 1, GDBUndo: 100000, WatchKeep: "power"},
+	}
+}
+
+// DefaultPath returns <user config dir>/gones/config.toml, or "" when the
+// platform has no config directory.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gones", "config.toml")
+}
+
+// Load reads the file at path over Default(). A missing file (or an empty
+// path) is not an error: the defaults are returned as they are.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := decodeTOML(bytes.NewReader(data), &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Save writes c to path, creating its directory if needed.
+func (c *Config) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("# GoNES configuration. Command-line flags override these values.\n\n")
+	if err := encodeTOML(&buf, c); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// Validate rejects values no part of the emulator can use. Settings owned
+// by another package (ram_init, ppu_align, log levels) are checked there.
+func (c *Config) Validate() error {
+	switch {
+	case c.Video.Scale < 1 || c.Video.Scale > 8:
+		return fmt.Errorf("video.scale %d out of range 1-8", c.Video.Scale)
+	case c.Video.Pacing != "hybrid" && c.Video.Pacing != "sleep" && c.Video.Pacing != "vsync":
+		return fmt.Errorf("video.pacing %q must be hybrid, sleep or vsync", c.Video.Pacing)
+	case !inRange(c.Video.FrameSkip, 0, 9):
+		return fmt.Errorf("video.frame_skip %d out of range 0-9", c.Video.FrameSkip)
+	case !inRange(c.Video.OverscanTop, 0, 64) || !inRange(c.Video.OverscanBottom, 0, 64) ||
+		!inRange(c.Video.OverscanLeft, 0, 64) || !inRange(c.Video.OverscanRight, 0, 64):
+		return fmt.Errorf("video.overscan_* must each be 0-64, got top %d bottom %d left %d right %d",
+			c.Video.OverscanTop, c.Video.OverscanBottom, c.Video.OverscanLeft, c.Video.OverscanRight)
+	case !inRange(c.Video.NTSCHue, -180, 180) || !inRange(c.Video.NTSCSaturation, 0, 200) ||
+		!inRange(c.Video.NTSCBrightness, -100, 100) || !inRange(c.Video.NTSCContrast, 0, 200) ||
+		!inRange(c.Video.NTSCGamma, 100, 350):
+		return fmt.Errorf("video.ntsc_* out of range: hue %d (-180-180), saturation %d (0-200), brightness %d (-100-100), contrast %d (0-200), gamma %d (100-350)",
+			c.Video.NTSCHue, c.Video.NTSCSaturation, c.Video.NTSCBrightness, c.Video.NTSCContrast, c.Video.NTSCGamma)
+	case c.Audio.LatencyMs < 0:
+		return fmt.Errorf("audio.latency_ms %d is negative", c.Audio.LatencyMs)
+	case c.Audio.Volume < 1 || c.Audio.Volume > 100:
+		return fmt.Errorf("audio.volume %d out of range 1-100 (use muted for silence)", c.Audio.Volume)
+	case c.Audio.BufferSamples != 0 && (c.Audio.BufferSamples < 64 || c.Audio.BufferSamples > 8192 || c.Audio.BufferSamples&(c.Audio.BufferSamples-1) != 0):
+		return fmt.Errorf("audio.buffer_samples %d must be 0 or a power of two from 64 to 8192", c.Audio.BufferSamples)
+	case c.Audio.Console != "famicom" && c.Audio.Console != "nes":
+		return fmt.Errorf("audio.console %q must be famicom or nes", c.Audio.Console)
+	case !inRange(c.Audio.Level5B, 0, 200) || !inRange(c.Audio.LevelVRC6, 0, 200) ||
+		!inRange(c.Audio.LevelMMC5, 0, 200) || !inRange(c.Audio.LevelFDS, 0, 200) ||
+		!inRange(c.Audio.LevelN163, 0, 200):
+		return fmt.Errorf("audio.level_* must each be 0-200, got 5b %d vrc6 %d mmc5 %d fds %d n163 %d",
+			c.Audio.Level5B, c.Audio.LevelVRC6, c.Audio.LevelMMC5, c.Audio.LevelFDS, c.Audio.LevelN163)
+	case !strings.EqualFold(c.Emulation.Region, "ntsc") && !strings.EqualFold(c.Emulation.Region, "pal"):
+		return fmt.Errorf("emulation.region %q must be ntsc or pal", c.Emulation.Region)
+	case c.Emulation.Autosave < 0:
+		return fmt.Errorf("emulation.autosave %d is negative", c.Emulation.Autosave)
+	case !inRange(c.Emulation.VSDIPs, 0, 255):
+		return fmt.Errorf("emulation.vs_dips %d out of range 0-255", c.Emulation.VSDIPs)
+	case c.Log.Ring < 0:
+		return fmt.Errorf("log.ring %d is negative", c.Log.Ring)
+	case c.Debug.DumpEvery < 1:
+		return fmt.Errorf("debug.dump_every %d must be at least 1", c.Debug.DumpEvery)
+	case !inRange(c.Debug.GDBPort, 0, 65535):
+		return fmt.Errorf("debug.gdb_port %d out of range 0-65535", c.Debug.GDBPort)
+	case c.Debug.GDBUndo < 0:
+		return fmt.Errorf("debug.gdb_undo %d is negative", c.Debug.GDBUndo)
+	case c.Debug.Trace < 0:
+		return fmt.Errorf("debug.trace %d is negative", c.Debug.Trace)
+	case c.Debug.WatchKeep != "power" && c.Debug.WatchKeep != "ram" && c.Debug.WatchKeep != "state":
+		return fmt.Errorf("debug.watch_keep %q must be power, ram or state", c.Debug.WatchKeep)
+	}
+	return nil
+}
+
+func inRange(v, lo, hi int) bool { return v >= lo && v <= hi }
+
+// PathFromArgs finds a -config value in args without parsing the rest, so
+// the file can be loaded before the flags that override it are defined.
+// Returns DefaultPath() when there is none.
+func PathFromArgs(args []string) string {
+	for i, a := range args {
+		if a == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if !strings.HasPrefix(a, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return DefaultPath()
+}
+
+// Bind registers a flag for every command-line setting on fs, pointing at
+// c's fields and defaulting to their current values.
+func (c *Config) Bind(fs *flag.FlagSet) {
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "Log level (off, error, warn, info, debug, trace)")
+	fs.StringVar(&c.Log.File, "log-file", c.Log.File, "Log file path (empty for stdout)")
+	fs.BoolVar(&c.Log.CPU, "cpu-log", c.Log.CPU, "Enable CPU instruction logging")
+	fs.BoolVar(&c.Log.PPU, "ppu-log", c.Log.PPU, "Enable PPU logging")
+	fs.BoolVar(&c.Log.APU, "apu-log", c.Log.APU, "Enable APU logging")
+	fs.BoolVar(&c.Log.Mapper, "mapper-log", c.Log.Mapper, "Enable mapper logging")
+	fs.BoolVar(&c.Log.Bus, "bus-log", c.Log.Bus, "Enable CPU bus (memory map) logging")
+	fs.StringVar(&c.Log.Components, "log-components", c.Log.Components, "Per-component log levels, e.g. ppu=trace,mapper=debug (cpu, ppu, apu, mapper, bus, general)")
+	fs.BoolVar(&c.Log.JSON, "log-json", c.Log.JSON, "Write log entries as JSON lines")
+	fs.IntVar(&c.Log.Ring, "log-ring", c.Log.Ring, "Recent log entries kept in memory and dumped to stderr on a crash (0 = off)")
+	fs.BoolVar(&c.Debug.Headless, "headless", c.Debug.Headless, "Run in headless mode for testing")
+	fs.IntVar(&c.Debug.TestFrames, "test-frames", c.Debug.TestFrames, "Number of frames to run in headless mode")
+	fs.StringVar(&c.Debug.CPUProfile, "cpuprofile", c.Debug.CPUProfile, "Write CPU profile to file (use with -headless for clean run)")
+	fs.StringVar(&c.Debug.MemProfile, "memprofile", c.Debug.MemProfile, "Write heap profile to file at exit")
+	fs.StringVar(&c.Debug.StatsCSV, "stats-csv", c.Debug.StatsCSV, "Write each frame's CPU/PPU/APU, render and audio-queue timing to this CSV file")
+	fs.StringVar(&c.Debug.DumpFrames, "dump-frames", c.Debug.DumpFrames, "Headless mode: write frames as PNG files to this directory")
+	fs.IntVar(&c.Debug.DumpEvery, "dump-every", c.Debug.DumpEvery, "Headless mode: with -dump-frames, write only every Nth frame")
+	fs.BoolVar(&c.Debug.HashFrames, "hash-frames", c.Debug.HashFrames, "Headless mode: print a CRC-32 of every frame to stdout")
+	fs.IntVar(&c.Debug.GDBPort, "gdb-port", c.Debug.GDBPort, "Serve the GDB remote protocol on this localhost TCP port so a debugger can attach (0 = off)")
+	fs.IntVar(&c.Debug.GDBUndo, "gdb-undo", c.Debug.GDBUndo, "With -gdb-port, how many instructions the debugger can step back through (0 = off)")
+	fs.IntVar(&c.Debug.Trace, "trace", c.Debug.Trace, "Keep the last N executed instructions in memory, written to <rom>.trace.txt on Ctrl+T, a CPU jam or a crash (0 = off)")
+	fs.StringVar(&c.Debug.TraceFilter, "trace-filter", c.Debug.TraceFilter, "Only trace instructions matching these comma-separated terms: pc=8000-8FFF, bank=3, class=branch|jump|load|store|alu|transfer|stack|nop|illegal")
+	fs.BoolVar(&c.Debug.Watch, "watch", c.Debug.Watch, "Reload the ROM whenever its file changes, e.g. after a rebuild")
+	fs.StringVar(&c.Debug.WatchKeep, "watch-keep", c.Debug.WatchKeep, "What -watch keeps across a reload: power (nothing), ram (CPU and PRG RAM) or state (everything)")
+	fs.StringVar(&c.Debug.Remote, "remote", c.Debug.Remote, "Run without a window, driven over the remote-control protocol on unix:/path, tcp:host:port or stdio")
+	fs.BoolVar(&c.Emulation.FourScore, "four-score", c.Emulation.FourScore, "Attach a Four Score 4-player adapter (players 3-4 use gamepads 3-4)")
+	fs.StringVar(&c.Emulation.Expansion, "expansion", c.Emulation.Expansion, "Famicom expansion port device: keyboard (Family BASIC; Ctrl+K to type), or empty for none")
+	fs.IntVar(&c.Emulation.VSDIPs, "vs-dips", c.Emulation.VSDIPs, "VS. System DIP switches as a number, switch 1 in bit 0 (e.g. 0x05 for switches 1 and 3)")
+	fs.StringVar(&c.Emulation.VSPPU, "vs-ppu", c.Emulation.VSPPU, "VS. System PPU for games whose header doesn't name it: 2c03 or 2c04-0001 to 2c04-0004 (empty = from the header)")
+	fs.BoolVar(&c.Video.FastPPU, "fast-ppu", c.Video.FastPPU, "Render whole scanlines at once when no mid-line PPU/mapper writes occur (faster on slow machines)")
+	fs.BoolVar(&c.Video.NoSpriteLimit, "no-sprite-limit", c.Video.NoSpriteLimit, "Draw every sprite on a scanline rather than 8, removing flicker (8 toggles; the overflow flag still behaves as on hardware)")
+	fs.StringVar(&c.Emulation.RAMInit, "ram-init", c.Emulation.RAMInit, "CPU RAM contents at power-on: 00, ff or random")
+	fs.Int64Var(&c.Emulation.RAMSeed, "ram-seed", c.Emulation.RAMSeed, "Seed for -ram-init random (0 = pick one and log it)")
+	fs.IntVar(&c.Emulation.PPUAlign, "ppu-align", c.Emulation.PPUAlign, "CPU/PPU clock alignment at power-on (0-2)")
+	fs.Var(invertedBool{&c.Emulation.PPUWarmUp}, "no-ppu-warmup", "Accept PPU register writes immediately after power-on instead of ignoring them until the first pre-render line")
+	fs.BoolVar(&c.Emulation.TrapJAM, "trap-jam", c.Emulation.TrapJAM, "Stop emulation when a JAM/KIL opcode halts the CPU instead of letting the PPU/APU run on")
+	fs.BoolVar(&c.Emulation.Deterministic, "deterministic", c.Emulation.Deterministic, "Make every power-on identical (fixed -ram-init random seed) for movies and netplay")
+	fs.IntVar(&c.Emulation.Autosave, "autosave", c.Emulation.Autosave, "Save the game to <rom>.autosave every N seconds of play and on exit, and offer to resume it next time (0 = off)")
+	fs.StringVar(&c.Emulation.Region, "region", c.Emulation.Region, "Console region: ntsc, or pal for the APU's period tables (the CPU and PPU stay NTSC)")
+	fs.IntVar(&c.Video.Scale, "scale", c.Video.Scale, "Window size as a multiple of 256x240 (1-8)")
+	fs.StringVar(&c.Video.Pacing, "pacing", c.Video.Pacing, "Frame pacing: hybrid (sleep then spin), sleep, or vsync (sync to the display, clocked by audio)")
+	fs.StringVar(&c.Video.Filter, "filter", c.Video.Filter, "Video filter: nearest, scale2x, xbr or crt (also in the Esc menu)")
+	fs.IntVar(&c.Video.FrameSkip, "frameskip", c.Video.FrameSkip, "Draw only one frame in N+1, still emulating them all (0-9)")
+	fs.BoolVar(&c.Video.AutoFrameSkip, "auto-frameskip", c.Video.AutoFrameSkip, "Skip drawing frames while emulation falls behind real time, keeping the sound continuous on slow machines")
+	fs.IntVar(&c.Video.OverscanTop, "overscan-top", c.Video.OverscanTop, "Pixels to crop from the top of the picture (0-64)")
+	fs.IntVar(&c.Video.OverscanBottom, "overscan-bottom", c.Video.OverscanBottom, "Pixels to crop from the bottom of the picture (0-64)")
+	fs.IntVar(&c.Video.OverscanLeft, "overscan-left", c.Video.OverscanLeft, "Pixels to crop from the left of the picture (0-64)")
+	fs.IntVar(&c.Video.OverscanRight, "overscan-right", c.Video.OverscanRight, "Pixels to crop from the right of the picture (0-64)")
+	fs.BoolVar(&c.Video.InputDisplay, "input-display", c.Video.InputDisplay, "Show the controllers' buttons in the corner of the picture (Ctrl+I toggles; also drawn into -dump-frames)")
+	fs.BoolVar(&c.Video.PauseInBackground, "pause-in-background", c.Video.PauseInBackground, "Pause emulation while the window doesn't have focus")
+	fs.StringVar(&c.Video.Palette, "palette", c.Video.Palette, "Load the master palette from a .pal file")
+	fs.BoolVar(&c.Video.NTSCPalette, "ntsc-palette", c.Video.NTSCPalette, "Generate the colours from the NTSC video signal, adjustable like a TV (9 picks a control, [ and ] turn it)")
+	fs.IntVar(&c.Video.NTSCHue, "ntsc-hue", c.Video.NTSCHue, "NTSC palette hue in degrees (-180-180)")
+	fs.IntVar(&c.Video.NTSCSaturation, "ntsc-saturation", c.Video.NTSCSaturation, "NTSC palette saturation in percent (0-200)")
+	fs.IntVar(&c.Video.NTSCBrightness, "ntsc-brightness", c.Video.NTSCBrightness, "NTSC palette brightness in percent of white (-100-100)")
+	fs.IntVar(&c.Video.NTSCContrast, "ntsc-contrast", c.Video.NTSCContrast, "NTSC palette contrast in percent (0-200)")
+	fs.IntVar(&c.Video.NTSCGamma, "ntsc-gamma", c.Video.NTSCGamma, "NTSC palette: the TV's gamma in hundredths (100-350; 220 leaves colours as decoded)")
+	fs.IntVar(&c.Audio.LatencyMs, "audio-latency", c.Audio.LatencyMs, "Maximum audio queued ahead of playback in ms (0 = two device buffers)")
+	fs.IntVar(&c.Audio.Volume, "volume", c.Audio.Volume, "Master volume in percent (1-100)")
+	fs.BoolVar(&c.Audio.Muted, "mute", c.Audio.Muted, "Start with sound muted (Ctrl+M toggles)")
+	fs.IntVar(&c.Audio.BufferSamples, "audio-buffer", c.Audio.BufferSamples, "Audio device buffer in samples, a power of two from 64 to 8192 (0 = from -audio-latency)")
+	fs.StringVar(&c.Audio.Console, "console", c.Audio.Console, "Expansion audio routing: famicom (mixed in) or nes (not heard, as on a front-loader)")
+	fs.IntVar(&c.Audio.Level5B, "level-5b", c.Audio.Level5B, "Sunsoft 5B (FME-7) expansion audio level in percent (0-200)")
+	fs.IntVar(&c.Audio.LevelVRC6, "level-vrc6", c.Audio.LevelVRC6, "VRC6 expansion audio level in percent (0-200)")
+	fs.IntVar(&c.Audio.LevelMMC5, "level-mmc5", c.Audio.LevelMMC5, "MMC5 expansion audio level in percent (0-200)")
+	fs.IntVar(&c.Audio.LevelFDS, "level-fds", c.Audio.LevelFDS, "Disk System expansion audio level in percent (0-200)")
+	fs.IntVar(&c.Audio.LevelN163, "level-n163", c.Audio.LevelN163, "Namco 163 expansion audio level in percent (0-200)")
+	fs.StringVar(&c.Paths.Saves, "save-dir", c.Paths.Saves, "Directory for battery saves (empty = next to the ROM)")
+	fs.StringVar(&c.Paths.States, "state-dir", c.Paths.States, "Directory for save states (empty = next to the ROM)")
+	fs.StringVar(&c.Paths.Screenshots, "screenshot-dir", c.Paths.Screenshots, "Directory for screenshots (empty = working directory)")
+	fs.StringVar(&c.Paths.FDSBIOS, "fds-bios", c.Paths.FDSBIOS, "Disk System BIOS for .fds images (empty = disksys.rom beside the config file)")
+	fs.StringVar(&c.Paths.GameDB, "gamedb", c.Paths.GameDB, "nes20db or NesCartDB XML game database for names and board details, on top of the built-in one (empty = nes20db.xml beside the config file)")
+	fs.StringVar(&c.Paths.Games, "game-dir", c.Paths.Games, "Directory of per-game settings files, <ROM SHA-1>.toml (empty = games/ beside the config file)")
+	fs.BoolVar(&c.Cheats.AutoLoad, "cheats", c.Cheats.AutoLoad, "Load <rom>.cht when a ROM is opened")
+	fs.BoolVar(&c.Cheats.Enabled, "cheats-on", c.Cheats.Enabled, "Start with loaded cheats active (Ctrl+H toggles)")
+}
+
+// invertedBool backs a -no-X flag for a setting stored as X.
+type invertedBool struct{ p *bool }
+
+func (b invertedBool) String() string {
+	if b.p == nil {
+		return "false"
+	}
+	return fmt.Sprint(!*b.p)
+}
+
+func (b invertedBool) Set(s string) error {
+	v, err := parseBool(s)
+	if err != nil {
+		return err
+	}
+	*b.p = !v
+	return nil
+}
+
+func (b invertedBool) IsBoolFlag() bool { return true }
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "t", "true":
+		return true, nil
+	case "0", "f", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", s)
+}
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadMissingFileGivesDefaults(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "none.toml"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg != Default() {
+		t.Errorf("Load of a missing file = %+v, want defaults", cfg)
+	}
+	if cfg, err := Load(""); err != nil || cfg != Default() {
+		t.Errorf("Load(\"\") = %+v, %v", cfg, err)
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gones", "config.toml")
+	want := Default()
+	want.Video.Scale = 4
+	want.Video.PauseInBackground = true
+	want.Video.Pacing = "vsync"
+	want.Video.Filter = "xbr"
+	want.Video.FrameSkip = 2
+	want.Video.AutoFrameSkip = true
+	want.Video.OverscanLeft = 8
+	want.Video.OverscanTop = 0
+	want.Video.InputDisplay = true
+	want.Video.NoSpriteLimit = true
+	want.Video.Palette = `C:\pal\"smooth".pal`
+	want.Audio.LatencyMs = 45
+	want.Audio.BufferSamples = 512
+	want.Audio.Volume = 70
+	want.Audio.Muted = true
+	want.Audio.Console = "nes"
+	want.Audio.Level5B = 150
+	want.Audio.LevelFDS = 40
+	want.Emulation.RAMSeed = -12345
+	want.Emulation.PPUWarmUp = false
+	want.Emulation.Deterministic = true
+	want.Emulation.Autosave = 30
+	want.Emulation.Expansion = "keyboard"
+	want.Emulation.VSDIPs = 0x85
+	want.Emulation.VSPPU = "2c04-0002"
+	want.Input.A = "Left Shift"
+	want.Paths.States = "/tmp/states # not a comment"
+	want.Paths.FDSBIOS = "/roms/disksys.rom"
+	want.Paths.GameDB = "/roms/nes20db.xml"
+	want.Cheats.AutoLoad = false
+	want.Log.Components = "ppu=trace,bus=debug"
+	want.Debug.Remote = "unix:/tmp/gones.sock"
+	want.Debug.GDBPort = 2345
+	want.Debug.GDBUndo = 5000
+	want.Debug.Trace = 1000000
+	want.Debug.TraceFilter = "pc=8000-8FFF,class=jump"
+	want.Debug.Watch = true
+	want.Debug.WatchKeep = "state"
+	want.Debug.StatsCSV = "/tmp/stats.csv"
+
+	if err := want.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got != want {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLoadPartialFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	data := "# only a couple of keys\n[video]\nscale = 2 # comment\n\n[input]\na = 'J'\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Video.Scale != 2 || cfg.Input.A != "J" {
+		t.Errorf("scale=%d a=%q, want 2 and J", cfg.Video.Scale, cfg.Input.A)
+	}
+	if cfg.Input.B != "X" || cfg.Debug.TestFrames != 600 {
+		t.Error("keys absent from the file should keep their defaults")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tc := range []struct{ data, want string }{
+		{"[video]\nzoom = 2\n", `line 2: unknown key "zoom"`},
+		{"[screen]\n", "line 1: unknown section [screen]"},
+		{"scale = 2\n", "outside any section"},
+		{"[video]\nscale = big\n", "line 2: scale: want an integer"},
+		{"[video]\nfast_ppu = yes\n", "want true or false"},
+		{"[video]\npalette = bare\n", "want a quoted string"},
+		{"[video]\nscale = 0\n", "video.scale 0 out of range"},
+		{"[emulation]\nregion = \"dendy\"\n", "must be ntsc or pal"},
+		{"[emulation]\nautosave = -5\n", "emulation.autosave -5"},
+		{"[emulation]\nvs_dips = 0x100\n", "emulation.vs_dips 256"},
+		{"[debug]\ndump_every = 0\n", "debug.dump_every 0"},
+		{"[debug]\ngdb_port = 70000\n", "debug.gdb_port 70000"},
+		{"[debug]\ngdb_undo = -1\n", "debug.gdb_undo -1"},
+		{"[debug]\ntrace = -1\n", "debug.trace -1"},
+		{"[debug]\nwatch_keep = \"vars\"\n", "debug.watch_keep \"vars\""},
+		{"[audio]\nbuffer_samples = 1000\n", "audio.buffer_samples 1000"},
+		{"[audio]\nvolume = 0\n", "audio.volume 0"},
+		{"[audio]\nconsole = 'twin'\n", `audio.console "twin"`},
+		{"[audio]\nlevel_vrc6 = 201\n", "vrc6 201"},
+		{"[audio]\nlevel_fds = -1\n", "fds -1"},
+		{"[audio]\nlevel_n163 = 250\n", "n163 250"},
+		{"[video]\noverscan_left = 65\n", "left 65"},
+		{"[video]\nntsc_gamma = 50\n", "gamma 50"},
+		{"[video]\nntsc_hue = -200\n", "hue -200"},
+		{"[video]\npacing = \"gsync\"\n", "video.pacing \"gsync\""},
+		{"[video]\nframe_skip = 10\n", "video.frame_skip 10"},
+	} {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tc.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Load(%q) error = %v, want it to mention %q", tc.data, err, tc.want)
+		}
+	}
+}
+
+func TestBindFlagsOverrideFile(t *testing.T) {
+	cfg := Default()
+	cfg.Video.Scale = 4 // as if read from the file
+	cfg.Log.Level = "debug"
+
+	fs := flag.NewFlagSet("gones", flag.ContinueOnError)
+	cfg.Bind(fs)
+	err := fs.Parse([]string{"-scale", "2", "-no-ppu-warmup", "-save-dir", "/saves", "-ram-seed", "7", "game.nes"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cfg.Video.Scale != 2 || cfg.Paths.Saves != "/saves" || cfg.Emulation.RAMSeed != 7 {
+		t.Errorf("flags not applied: %+v", cfg)
+	}
+	if cfg.Emulation.PPUWarmUp {
+		t.Error("-no-ppu-warmup should clear emulation.ppu_warmup")
+	}
+	if cfg.Log.Level != "debug" {
+		t.Errorf("unset flag overwrote file value: log level %q", cfg.Log.Level)
+	}
+	if fs.Arg(0) != "game.nes" {
+		t.Errorf("positional arg = %q", fs.Arg(0))
+	}
+}
+
+// Every flag Bind registers must write into Config, so that -save-config
+// captures the whole command line: parse a non-default value for each one
+// and check the config moved away from the defaults.
+func TestBindEveryFlagRoundTrips(t *testing.T) {
+	probe := Default()
+	fs := flag.NewFlagSet("gones", flag.ContinueOnError)
+	probe.Bind(fs)
+	fs.VisitAll(func(f *flag.Flag) {
+		cfg := Default()
+		one := flag.NewFlagSet("gones", flag.ContinueOnError)
+		cfg.Bind(one)
+		value := "9"
+		switch {
+		case isBoolFlag(f):
+			value = "true"
+			if f.DefValue == "true" {
+				value = "false"
+			}
+		case f.DefValue == "ntsc":
+			value = "NTSC"
+		case f.DefValue == "":
+			value = "x"
+		case f.DefValue == "00" || f.DefValue == "info":
+			value = "ff"
+		}
+		if err := one.Set(f.Name, value); err != nil {
+			t.Errorf("-%s=%s: %v", f.Name, value, err)
+			return
+		}
+		if cfg == Default() {
+			t.Errorf("-%s=%s didn't change the config", f.Name, value)
+			return
+		}
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := cfg.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		if back, err := loadUnvalidated(path); err != nil || back != cfg {
+			t.Errorf("-%s: saved config reloads as %+v (%v)", f.Name, back, err)
+		}
+	})
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// loadUnvalidated is Load without the range checks, for values the probe
+// above picks only to be different from the default.
+func loadUnvalidated(path string) (Config, error) {
+	cfg := Default()
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+	return cfg, decodeTOML(f, &cfg)
+}
+
+func TestPathFromArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-config", "a.toml", "rom.nes"}, "a.toml"},
+		{[]string{"--config=b.toml"}, "b.toml"},
+		{[]string{"-scale", "2", "-config=c.toml"}, "c.toml"},
+		{[]string{"-config="}, ""},
+		{[]string{"--", "-config", "d.toml"}, DefaultPath()},
+		{[]string{"rom.nes"}, DefaultPath()},
+	} {
+		if got := PathFromArgs(tc.args); got != tc.want {
+			t.Errorf("PathFromArgs(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestWithGame(t *testing.T) {
+	dir := t.TempDir()
+	path := GamePath(dir, "0123abcd")
+	data := "[video]\npalette = 'smooth.pal'\noverscan_top = 16\nno_sprite_limit = true\n\n[emulation]\nregion = 'pal'\nfour_score = true\nexpansion = 'keyboard'\nvs_dips = 0x05\n\n[cartridge]\nsubmapper = 4\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	base := Default()
+	base.Video.Scale = 2
+	fs := flag.NewFlagSet("gones", flag.ContinueOnError)
+	base.Bind(fs)
+	if err := fs.Parse([]string{"-overscan-top", "4", "-scale", "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, game, err := base.WithGame(path, FlagsSet(fs))
+	if err != nil {
+		t.Fatalf("WithGame: %v", err)
+	}
+	if cfg.Video.Palette != filepath.Join(dir, "smooth.pal") || !cfg.Video.NoSpriteLimit || !cfg.Emulation.FourScore || cfg.Emulation.Expansion != "keyboard" || cfg.Emulation.VSDIPs != 5 || cfg.Emulation.Region != "pal" {
+		t.Errorf("game file not applied: %+v %+v", cfg.Video, cfg.Emulation)
+	}
+	if cfg.Video.OverscanTop != 4 || cfg.Video.OverscanBottom != 8 || cfg.Video.Scale != 2 {
+		t.Errorf("overscan top/bottom %d/%d, scale %d; want the flag's 4, the default 8 and 2",
+			cfg.Video.OverscanTop, cfg.Video.OverscanBottom, cfg.Video.Scale)
+	}
+	if game != (Game{Submapper: 4}) {
+		t.Errorf("cartridge section = %+v", game)
+	}
+	if base.Video.NoSpriteLimit {
+		t.Error("WithGame changed the config it was called on")
+	}
+
+	if cfg, game, err := base.WithGame(GamePath(dir, "missing"), nil); err != nil || cfg != base || game != NoGame {
+		t.Errorf("missing file: %v, %+v", err, game)
+	}
+
+	for _, tc := range []struct{ data, want string }{
+		{"[video]\nscale = 2\n", `unknown key "scale"`},
+		{"[input]\na = 'J'\n", "unknown section [input]"},
+		{"[emulation]\nregion = 'dendy'\n", "must be ntsc or pal"},
+		{"[video]\noverscan_left = 99\n", "left 99"},
+		{"[cartridge]\nsubmapper = 16\n", "cartridge.submapper 16"},
+	} {
+		if err := os.WriteFile(path, []byte(tc.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := base.WithGame(path, nil); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("WithGame(%q) error = %v, want it to mention %q", tc.data, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Game is a game file's [cartridge] section: how to build the cartridge,
+// for iNES 1.0 dumps whose header can't say (see cartridge.GameInfo).
+type Game struct {
+	// Submapper is the board variant; -1 leaves it to the game database.
+	Submapper int `toml:"submapper"`
+	// CHRRAM is the CHR RAM size in KB on boards without CHR ROM; 0
+	// leaves it to the game database.
+	CHRRAM int `toml:"chr_ram_kb"`
+}
+
+// NoGame is the Game of a ROM without a file, or whose file has no
+// [cartridge] section.
+var NoGame = Game{Submapper: -1}
+
+// gameVideo and gameEmulation are the game-dependent parts of Video and
+// Emulation; any other key in a game file is an error.
+type gameVideo struct {
+	Palette        string `toml:"palette"`
+	OverscanTop    int    `toml:"overscan_top"`
+	OverscanBottom int    `toml:"overscan_bottom"`
+	OverscanLeft   int    `toml:"overscan_left"`
+	OverscanRight  int    `toml:"overscan_right"`
+	NoSpriteLimit  bool   `toml:"no_sprite_limit"`
+}
+
+type gameEmulation struct {
+	Region    string `toml:"region"`
+	FourScore bool   `toml:"four_score"`
+	Expansion string `toml:"expansion"`
+	VSDIPs    int    `toml:"vs_dips"`
+	VSPPU     string `toml:"vs_ppu"`
+}
+
+type gameFile struct {
+	Video     gameVideo     `toml:"video"`
+	Emulation gameEmulation `toml:"emulation"`
+	Cartridge Game          `toml:"cartridge"`
+}
+
+// GamePath returns the game file of the ROM whose SHA-1
+// (cartridge.Hashes.ROMSHA1) is sha1: <sha1>.toml in dir, or in
+// <user config dir>/gones/games when dir is empty; "" when there is no
+// config directory. A game file has the settings file's format but only
+// the keys that depend on the game:
+//
+//	[video]
+//	palette = "smooth.pal"   # relative to the game file
+//	overscan_top = 16
+//	no_sprite_limit = true
+//
+//	[emulation]
+//	region = "ntsc"
+//	four_score = true
+//	expansion = "keyboard"
+//	vs_dips = 0x05
+//	vs_ppu = "2c04-0004"
+//
+//	[cartridge]
+//	submapper = 4            # MMC3A IRQ behaviour for an iNES 1.0 dump
+//
+// The precedence becomes defaults < settings file < game file < flags.
+func GamePath(dir, sha1 string) string {
+	if dir == "" {
+		base := DefaultPath()
+		if base == "" {
+			return ""
+		}
+		dir = filepath.Join(filepath.Dir(base), "games")
+	}
+	return filepath.Join(dir, sha1+".toml")
+}
+
+// WithGame returns c with the game file at path decoded over it, and the
+// file's [cartridge] section. The flags in set, as FlagsSet returns them,
+// are applied again afterwards so the command line still wins. A missing
+// file (or empty path) returns c and NoGame unchanged.
+func (c Config) WithGame(path string, set map[string]string) (Config, Game, error) {
+	if path == "" {
+		return c, NoGame, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, NoGame, nil
+	}
+	if err != nil {
+		return c, NoGame, err
+	}
+	v, e := c.Video, c.Emulation
+	g := gameFile{
+		Video: gameVideo{
+			Palette:     v.Palette,
+			OverscanTop: v.OverscanTop, OverscanBottom: v.OverscanBottom,
+			OverscanLeft: v.OverscanLeft, OverscanRight: v.OverscanRight,
+			NoSpriteLimit: v.NoSpriteLimit,
+		},
+		Emulation: gameEmulation{
+			Region: e.Region, FourScore: e.FourScore, Expansion: e.Expansion,
+			VSDIPs: e.VSDIPs, VSPPU: e.VSPPU,
+		},
+		Cartridge: NoGame,
+	}
+	if err := decodeTOML(bytes.NewReader(data), &g); err != nil {
+		return c, NoGame, fmt.Errorf("%s: %w", path, err)
+	}
+	if g.Video.Palette != v.Palette && g.Video.Palette != "" && !filepath.IsAbs(g.Video.Palette) {
+		g.Video.Palette = filepath.Join(filepath.Dir(path), g.Video.Palette)
+	}
+	c.Video.Palette = g.Video.Palette
+	c.Video.OverscanTop, c.Video.OverscanBottom = g.Video.OverscanTop, g.Video.OverscanBottom
+	c.Video.OverscanLeft, c.Video.OverscanRight = g.Video.OverscanLeft, g.Video.OverscanRight
+	c.Video.NoSpriteLimit = g.Video.NoSpriteLimit
+	c.Emulation.Region, c.Emulation.FourScore = g.Emulation.Region, g.Emulation.FourScore
+	c.Emulation.Expansion = g.Emulation.Expansion
+	c.Emulation.VSDIPs, c.Emulation.VSPPU = g.Emulation.VSDIPs, g.Emulation.VSPPU
+
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	c.Bind(flags)
+	for name, value := range set {
+		if flags.Lookup(name) != nil {
+			if err := flags.Set(name, value); err != nil {
+				return c, NoGame, fmt.Errorf("-%s: %w", name, err)
+			}
+		}
+	}
+	switch {
+	case g.Cartridge.Submapper < -1 || g.Cartridge.Submapper > 15:
+		return c, NoGame, fmt.Errorf("%s: cartridge.submapper %d out of range 0-15", path, g.Cartridge.Submapper)
+	case g.Cartridge.CHRRAM < 0 || g.Cartridge.CHRRAM > 512:
+		return c, NoGame, fmt.Errorf("%s: cartridge.chr_ram_kb %d out of range 0-512", path, g.Cartridge.CHRRAM)
+	}
+	if err := c.Validate(); err != nil {
+		return c, NoGame, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, g.Cartridge, nil
+}
+
+// FlagsSet returns the flags given on fs's command line with their values,
+// for WithGame.
+func FlagsSet(fs *flag.FlagSet) map[string]string {
+	set := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = f.Value.String() })
+	return set
+}
@@ -0,0 +1,164 @@
+package config
+
+// A reader and writer for the slice of TOML the config file uses: one level
+// of [section] tables holding string, bool and integer keys. That covers
+// every setting without pulling in a TOML library; anything else in the
+// file (arrays, inline tables, dotted keys, floats) is reported as an error
+// with its line number rather than silently ignored.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// encodeTOML writes v, a pointer to a struct of section structs, as TOML.
+// Sections and keys come out in field order, named by their toml tags.
+func encodeTOML(w io.Writer, v interface{}) error {
+	bw := bufio.NewWriter(w)
+	root := reflect.ValueOf(v).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		fmt.Fprintf(bw, "[%s]\n", tomlName(root.Type().Field(i)))
+		sec := root.Field(i)
+		for j := 0; j < sec.NumField(); j++ {
+			key := tomlName(sec.Type().Field(j))
+			f := sec.Field(j)
+			switch f.Kind() {
+			case reflect.String:
+				fmt.Fprintf(bw, "%s = %s\n", key, strconv.Quote(f.String()))
+			case reflect.Bool:
+				fmt.Fprintf(bw, "%s = %t\n", key, f.Bool())
+			case reflect.Int, reflect.Int64:
+				fmt.Fprintf(bw, "%s = %d\n", key, f.Int())
+			default:
+				return fmt.Errorf("config: %s.%s has unsupported type %s", tomlName(root.Type().Field(i)), key, f.Type())
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// decodeTOML reads r into v (same shape as for encodeTOML). Keys absent from
+// the file keep whatever v already held, so decoding over the defaults
+// yields a complete configuration.
+func decodeTOML(r io.Reader, v interface{}) error {
+	root := reflect.ValueOf(v).Elem()
+	var sec reflect.Value
+	secName := ""
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(stripComment(sc.Text()))
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") {
+				return fmt.Errorf("line %d: malformed table header %q", line, text)
+			}
+			secName = strings.TrimSpace(text[1 : len(text)-1])
+			sec = fieldByTOMLName(root, secName)
+			if !sec.IsValid() {
+				return fmt.Errorf("line %d: unknown section [%s]", line, secName)
+			}
+			continue
+		}
+		key, raw, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("line %d: want key = value", line)
+		}
+		key = strings.TrimSpace(key)
+		if !sec.IsValid() {
+			return fmt.Errorf("line %d: key %q outside any section", line, key)
+		}
+		f := fieldByTOMLName(sec, key)
+		if !f.IsValid() {
+			return fmt.Errorf("line %d: unknown key %q in [%s]", line, key, secName)
+		}
+		if err := setTOMLValue(f, strings.TrimSpace(raw)); err != nil {
+			return fmt.Errorf("line %d: %s: %v", line, key, err)
+		}
+	}
+	return sc.Err()
+}
+
+func setTOMLValue(f reflect.Value, raw string) error {
+	switch f.Kind() {
+	case reflect.String:
+		s, err := parseTOMLString(raw)
+		if err != nil {
+			return err
+		}
+		f.SetString(s)
+	case reflect.Bool:
+		switch raw {
+		case "true":
+			f.SetBool(true)
+		case "false":
+			f.SetBool(false)
+		default:
+			return fmt.Errorf("want true or false, got %s", raw)
+		}
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.ReplaceAll(raw, "_", ""), 0, 64)
+		if err != nil {
+			return fmt.Errorf("want an integer, got %s", raw)
+		}
+		f.SetInt(n)
+	}
+	return nil
+}
+
+// parseTOMLString accepts a basic ("...", with escapes) or literal ('...')
+// string.
+func parseTOMLString(raw string) (string, error) {
+	if len(raw) >= 2 && raw[0] == '\'' && raw[len(raw)-1] == '\'' {
+		return raw[1 : len(raw)-1], nil
+	}
+	if len(raw) >= 2 && raw[0] == '"' {
+		return strconv.Unquote(raw)
+	}
+	return "", fmt.Errorf("want a quoted string, got %s", raw)
+}
+
+// stripComment cuts a trailing # comment, leaving # inside strings alone.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func tomlName(f reflect.StructField) string {
+	if tag := f.Tag.Get("toml"); tag != "" {
+		return tag
+	}
+	return strings.ToLower(f.Name)
+}
+
+func fieldByTOMLName(v reflect.Value, name string) reflect.Value {
+	for i := 0; i < v.NumField(); i++ {
+		if tomlName(v.Type().Field(i)) == name {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
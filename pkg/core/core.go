@@ -0,0 +1,70 @@
+// Package core is the contract between the emulator and a frontend.
+//
+// A frontend hands nes.NES a VideoSink, an AudioSink and one InputProvider
+// per controller, then calls StepFrame at its own pace; each StepFrame
+// polls the providers, runs one frame, and delivers that frame's picture
+// and sound to the sinks. Any of the three may be left unset. pkg/gui is
+// one implementation (SDL2); a terminal renderer, a WebAssembly canvas or
+// a test harness only needs these interfaces, not the emulator internals:
+//
+//	n := nes.NewNES()
+//	n.LoadCartridge(cart)
+//	n.PowerOn()
+//	n.SetVideoSink(myScreen)
+//	n.SetAudioSink(mySpeaker)
+//	n.SetInputProvider(0, myPad)
+//	for running {
+//		n.StepFrame()
+//	}
+//
+// Sinks are called on the goroutine that runs StepFrame, and the slices
+// they receive belong to the emulator: they are valid only for the
+// duration of the call, so copy anything kept.
+package core
+
+// Frame and audio format delivered to the sinks.
+const (
+	FrameWidth  = 256   // pixels per scanline
+	FrameHeight = 240   // visible scanlines
+	SampleRate  = 44100 // mono samples per second
+)
+
+// ButtonState is one standard controller's buttons, a bit per button in
+// the order the controller reports them (A first, Right last).
+type ButtonState uint8
+
+// Controller buttons.
+const (
+	ButtonA ButtonState = 1 << iota
+	ButtonB
+	ButtonSelect
+	ButtonStart
+	ButtonUp
+	ButtonDown
+	ButtonLeft
+	ButtonRight
+)
+
+// Pressed reports whether every button in mask is held.
+func (b ButtonState) Pressed(mask ButtonState) bool {
+	return b&mask == mask
+}
+
+// VideoSink receives each finished frame: FrameWidth×FrameHeight pixels,
+// row-major, as 0xAARRGGBB.
+type VideoSink interface {
+	ReceiveFrame(frame []uint32)
+}
+
+// AudioSink receives the samples generated during each frame (about 734
+// at SampleRate), as mono float32 in roughly [-1, 1].
+type AudioSink interface {
+	ReceiveSamples(samples []float32)
+}
+
+// InputProvider supplies one controller's buttons. Poll is called once at
+// the start of every frame, and its result holds for the whole frame
+// (unless the NES is set to latch input on each controller strobe).
+type InputProvider interface {
+	Poll() ButtonState
+}
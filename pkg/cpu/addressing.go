@@ -137,19 +137,21 @@ func (c *CPU) getOperandAddress(mode AddressingMode) (uint16, bool) {
 	return 0, false
 }
 
-// getOperand gets the operand value for an addressing mode
-func (c *CPU) getOperand(mode AddressingMode) (uint8, bool) {
+// getOperand gets the operand value for an addressing mode. A page crossed
+// while indexing is recorded in c.pageCrossed for executeInstruction.
+func (c *CPU) getOperand(mode AddressingMode) uint8 {
 	switch mode {
 	case AddrAccumulator:
-		return c.A, false
+		return c.A
 
 	case AddrImmediate:
 		addr, _ := c.getOperandAddress(mode)
-		return c.read(addr), false
+		return c.read(addr)
 
 	default:
 		addr, pageCrossed := c.getOperandAddress(mode)
-		return c.read(addr), pageCrossed
+		c.pageCrossed = pageCrossed
+		return c.read(addr)
 	}
 }
 
@@ -0,0 +1,45 @@
+// Package cpu — config.go holds the behaviour switches for undocumented
+// opcodes whose results differ between individual 2A03 chips.
+package cpu
+
+// UnstableMode selects how the "magic constant" opcodes XAA ($8B) and
+// LXA/ATX ($AB) compute their result. On hardware both OR the accumulator
+// with a chip- and temperature-dependent constant before ANDing, so no
+// single answer is right; games avoid them and test ROMs skip them.
+type UnstableMode uint8
+
+const (
+	// UnstableStable uses $FF as the constant: XAA gives X & imm and LXA
+	// loads imm into A and X, independent of the prior accumulator. This is
+	// what most emulators do and what gones did before the switch existed.
+	UnstableStable UnstableMode = iota
+	// UnstableMagic uses Config.Magic as the constant.
+	UnstableMagic
+)
+
+// Config holds per-CPU behaviour switches. The zero value is not the
+// default; start from DefaultConfig.
+type Config struct {
+	Unstable UnstableMode
+	// Magic is the constant ORed into A by XAA/LXA in UnstableMagic mode.
+	// $EE matches most measured NMOS parts; $FF and $00 are also seen.
+	Magic uint8
+}
+
+// DefaultConfig is the configuration New starts with.
+var DefaultConfig = Config{Unstable: UnstableStable, Magic: 0xEE}
+
+// SetConfig replaces the CPU's behaviour switches. Runtime preference — not
+// part of save-state, untouched by Reset.
+func (c *CPU) SetConfig(cfg Config) { c.config = cfg }
+
+// Config returns the CPU's current behaviour switches.
+func (c *CPU) Config() Config { return c.config }
+
+// magic is the constant XAA and LXA OR into the accumulator.
+func (c *CPU) magic() uint8 {
+	if c.config.Unstable == UnstableMagic {
+		return c.config.Magic
+	}
+	return 0xFF
+}
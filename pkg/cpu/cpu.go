@@ -57,11 +57,28 @@ type CPU struct {
 	suppressPostPoll bool
 
 	// extraCycles is added to the next CPU.Step's returned cycle count.
-	// Currently used for OAM DMA (STA $4014 halts the CPU for 513 extra
-	// cycles while the DMA controller copies 256 bytes into OAM); the
-	// Quietust scanline test calibrates NMI-handler scanline positions
-	// against the full ~517 cycle cost.
+	// Used for OAM DMA (STA $4014 halts the CPU for 513 extra cycles
+	// while the DMA controller copies 256 bytes into OAM; the Quietust
+	// scanline test calibrates NMI-handler scanline positions against the
+	// full ~517 cycle cost) and for the taken-branch penalty.
 	extraCycles int
+
+	// opcode is the instruction Step is executing, fetched from opPC.
+	opcode uint8
+	opPC   uint16
+
+	// halted is set by a JAM opcode (see halt.go); haltOpcode records
+	// which one for HaltInfo, and haltInfo caches what it built.
+	halted     bool
+	haltOpcode uint8
+	haltInfo   *HaltError
+
+	// config holds the unstable-opcode switches (see config.go).
+	config Config
+
+	// pageCrossed is set by getOperand when an indexed read crosses a
+	// page, so executeInstruction can add the opcode's page-cross cycle.
+	pageCrossed bool
 }
 
 // Status flag bits
@@ -82,13 +99,24 @@ func New(mem *memory.Memory) *CPU {
 		Memory: mem,
 		SP:     0xFD,
 		P:      FlagUnused | FlagInterrupt,
+		config: DefaultConfig,
 	}
 }
 
 // Step executes one instruction and returns cycles taken
 func (c *CPU) Step() int {
+	// A jammed CPU never reaches an instruction boundary, so interrupts
+	// are never serviced; an NMI edge arriving meanwhile is lost.
+	if c.halted {
+		c.NMI = false
+		c.Cycles++
+		return 1
+	}
+
 	if c.NMI {
-		logger.LogCPU("NMI triggered at PC=$%04X", c.PC)
+		if logger.CPUEnabled() {
+			logger.LogCPU("NMI triggered at PC=$%04X", c.PC)
+		}
 		c.handleNMI()
 		c.NMI = false
 		c.pollIIsSet = false
@@ -108,10 +136,11 @@ func (c *CPU) Step() int {
 	// this pre-write value.
 	preI := c.getFlag(FlagInterrupt)
 
-	opcode := c.read(c.PC)
+	c.opPC = c.PC
+	c.opcode = c.read(c.PC)
 	c.PC++
 
-	cycles := c.executeInstruction(opcode)
+	cycles := c.executeInstruction(c.opcode)
 	// Add any extra cycles charged by side effects (e.g. OAM DMA on a
 	// $4014 write, which stalls the CPU for 513 cycles).
 	cycles += c.extraCycles
@@ -132,6 +161,11 @@ func (c *CPU) Step() int {
 	return cycles
 }
 
+// InstructionPC is the address of the instruction Step last fetched — the
+// one still executing when a bus hook runs, whose PC has already moved
+// past its operands.
+func (c *CPU) InstructionPC() uint16 { return c.opPC }
+
 // PollIRQ samples the IRQ line for the just-completed instruction. Called
 // by NES.Step after the PPU and APU have advanced through this
 // instruction's cycles, so IRQ assertions that happened mid-instruction
@@ -225,6 +259,8 @@ type cpuState struct {
 	P           uint8
 	Cycles      int64 // widened from int for stable on-disk layout
 	NMI, IRQ    bool
+	Halted      bool
+	HaltOpcode  uint8
 }
 
 // SaveState writes the CPU's register / interrupt state to w.
@@ -234,9 +270,30 @@ func (c *CPU) SaveState(w io.Writer) error {
 		PC: c.PC, P: c.P,
 		Cycles: int64(c.Cycles),
 		NMI:    c.NMI, IRQ: c.IRQ,
+		Halted: c.halted, HaltOpcode: c.haltOpcode,
 	})
 }
 
+// AppendState appends the bytes SaveState writes to b without allocating
+// once b has the capacity; NES.StateHash uses it.
+func (c *CPU) AppendState(b []byte) []byte {
+	b = append(b, c.A, c.X, c.Y, c.SP)
+	b = binary.LittleEndian.AppendUint16(b, c.PC)
+	b = append(b, c.P)
+	b = binary.LittleEndian.AppendUint64(b, uint64(c.Cycles))
+	b = appendBool(b, c.NMI)
+	b = appendBool(b, c.IRQ)
+	b = appendBool(b, c.halted)
+	return append(b, c.haltOpcode)
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
 // LoadState restores CPU state written by SaveState.
 func (c *CPU) LoadState(r io.Reader) error {
 	var s cpuState
@@ -247,5 +304,7 @@ func (c *CPU) LoadState(r io.Reader) error {
 	c.PC, c.P = s.PC, s.P
 	c.Cycles = int(s.Cycles)
 	c.NMI, c.IRQ = s.NMI, s.IRQ
+	c.halted, c.haltOpcode = s.Halted, s.HaltOpcode
+	c.haltInfo = nil
 	return nil
 }
@@ -146,3 +146,142 @@ func TestTriggerIRQ(t *testing.T) {
 		t.Error("TriggerIRQ should assert the IRQ line")
 	}
 }
+
+// TestOpcodeTable checks the dispatch table's invariants: page-cross
+// penalties only sit on indexed modes, and the cycle-2 dummy fetch is tied
+// to implied/accumulator addressing. (init already panics on a missing or
+// duplicate opcode.)
+func TestOpcodeTable(t *testing.T) {
+	for i, op := range opcodes {
+		if op.pageCross && op.mode != AddrAbsoluteX && op.mode != AddrAbsoluteY && op.mode != AddrIndirectIndexed {
+			t.Errorf("$%02X %s: page-cross penalty on non-indexed mode %d", i, op.name, op.mode)
+		}
+		implied := op.mode == AddrImplied || op.mode == AddrAccumulator
+		if op.dummyFetch != implied {
+			t.Errorf("$%02X %s: dummyFetch=%v for mode %d", i, op.name, op.dummyFetch, op.mode)
+		}
+	}
+}
+
+// refCycles is the NMOS 6502 base cycle count for every opcode (branches not
+// taken, no page crossed; JAM counted up to the halt), transcribed from the
+// NESdev "6502 cycle times" and "CPU unofficial opcodes" tables rather than
+// from opcodeDefs so the two can be checked against each other.
+var refCycles = [256]uint8{
+	//       0  1  2  3  4  5  6  7  8  9  A  B  C  D  E  F
+	/* 0 */ 7, 6, 2, 8, 3, 3, 5, 5, 3, 2, 2, 2, 4, 4, 6, 6,
+	/* 1 */ 2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/* 2 */ 6, 6, 2, 8, 3, 3, 5, 5, 4, 2, 2, 2, 4, 4, 6, 6,
+	/* 3 */ 2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/* 4 */ 6, 6, 2, 8, 3, 3, 5, 5, 3, 2, 2, 2, 3, 4, 6, 6,
+	/* 5 */ 2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/* 6 */ 6, 6, 2, 8, 3, 3, 5, 5, 4, 2, 2, 2, 5, 4, 6, 6,
+	/* 7 */ 2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/* 8 */ 2, 6, 2, 6, 3, 3, 3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
+	/* 9 */ 2, 6, 2, 6, 4, 4, 4, 4, 2, 5, 2, 5, 5, 5, 5, 5,
+	/* A */ 2, 6, 2, 6, 3, 3, 3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
+	/* B */ 2, 5, 2, 5, 4, 4, 4, 4, 2, 4, 2, 4, 4, 4, 4, 4,
+	/* C */ 2, 6, 2, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
+	/* D */ 2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/* E */ 2, 6, 2, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
+	/* F */ 2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+}
+
+// refPageCross lists the opcodes that take one extra cycle when indexing
+// crosses a page: the indexed reads, including LAX/LAS and the abs,X NOPs.
+// Indexed stores and read-modify-writes always pay for the fix-up and are
+// already counted in refCycles.
+var refPageCross = map[uint8]bool{
+	0x11: true, 0x19: true, 0x1C: true, 0x1D: true, // ORA, NOP
+	0x31: true, 0x39: true, 0x3C: true, 0x3D: true, // AND, NOP
+	0x51: true, 0x59: true, 0x5C: true, 0x5D: true, // EOR, NOP
+	0x71: true, 0x79: true, 0x7C: true, 0x7D: true, // ADC, NOP
+	0xB1: true, 0xB3: true, 0xB9: true, 0xBB: true, // LDA, LAX, LDA, LAS
+	0xBC: true, 0xBD: true, 0xBE: true, 0xBF: true, // LDY, LDA, LDX, LAX
+	0xD1: true, 0xD9: true, 0xDC: true, 0xDD: true, // CMP, NOP
+	0xF1: true, 0xF9: true, 0xFC: true, 0xFD: true, // SBC, NOP
+}
+
+// runTimed executes opcode once at $0200 with X=Y=index and returns the
+// cycles Step charged. The operand bytes point at $0301 (abs) / $01 (zp),
+// and the zero-page pointers at $01 and $02 both lead into page 3, so an
+// index of $FF crosses a page on every indexed mode and $00 crosses none.
+// Branch flags are set so the branch is not taken.
+func runTimed(opcode, index uint8) int {
+	c := createTestCPU()
+	c.Memory.Write(0x0200, opcode)
+	c.Memory.Write(0x0201, 0x01)
+	c.Memory.Write(0x0202, 0x03)
+	c.Memory.Write(0x01, 0x10)
+	c.Memory.Write(0x02, 0x03)
+	c.Memory.Write(0x03, 0x03)
+	c.X, c.Y = index, index
+	if opcode&0x1F == 0x10 {
+		flag := [4]uint8{FlagNegative, FlagOverflow, FlagCarry, FlagZero}[opcode>>6]
+		c.setFlag(flag, opcode&0x20 == 0)
+	}
+	c.PC = 0x0200
+	return c.Step()
+}
+
+// TestOpcodeCycles runs every opcode and checks the cycles charged against
+// refCycles, then re-runs the indexed ones across a page boundary against
+// refPageCross.
+func TestOpcodeCycles(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		op := uint8(i)
+		if got := runTimed(op, 0); got != int(refCycles[op]) {
+			t.Errorf("$%02X %s: %d cycles, want %d", op, opcodes[op].name, got, refCycles[op])
+		}
+		switch opcodes[op].mode {
+		case AddrAbsoluteX, AddrAbsoluteY, AddrIndirectIndexed:
+		default:
+			continue
+		}
+		want := int(refCycles[op])
+		if refPageCross[op] {
+			want++
+		}
+		if got := runTimed(op, 0xFF); got != want {
+			t.Errorf("$%02X %s crossing a page: %d cycles, want %d", op, opcodes[op].name, got, want)
+		}
+	}
+}
+
+// newDispatchCPU loads a tight loop of mixed-mode instructions at $0200:
+// LDA abs,X / ADC zp / STA (zp),Y / INX / BNE back.
+func newDispatchCPU() *CPU {
+	c := createTestCPU()
+	prog := []uint8{
+		0xBD, 0x00, 0x03, // LDA $0300,X
+		0x65, 0x10, // ADC $10
+		0x91, 0x20, // STA ($20),Y
+		0xE8,       // INX
+		0xD0, 0xF6, // BNE -10
+		0x4C, 0x00, 0x02, // JMP $0200
+	}
+	for i, v := range prog {
+		c.Memory.Write(0x0200+uint16(i), v)
+	}
+	c.Memory.Write(0x20, 0x00)
+	c.Memory.Write(0x21, 0x04)
+	c.PC = 0x0200
+	return c
+}
+
+func BenchmarkDispatch(b *testing.B) {
+	c := newDispatchCPU()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Step()
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds()/1e6, "Minstr/s")
+}
+
+func TestDispatchZeroAllocs(t *testing.T) {
+	c := newDispatchCPU()
+	if allocs := testing.AllocsPerRun(1000, func() { c.Step() }); allocs != 0 {
+		t.Errorf("Step: %.1f allocations per instruction, want 0", allocs)
+	}
+}
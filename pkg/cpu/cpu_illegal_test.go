@@ -1,6 +1,7 @@
 package cpu
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 )
@@ -208,7 +209,7 @@ func TestIllegalNOPs(t *testing.T) {
 			{"NOP_D4", 0xD4, 4, 2}, // Zero page,X
 			{"NOP_F4", 0xF4, 4, 2}, // Zero page,X
 			{"NOP_0C", 0x0C, 4, 3}, // Absolute
-			{"NOP_1C", 0x1C, 4, 3}, // Absolute,X (simplified, no page crossing)
+			{"NOP_1C", 0x1C, 4, 3}, // Absolute,X (X=0, no page crossing)
 			{"NOP_3C", 0x3C, 4, 3}, // Absolute,X
 			{"NOP_5C", 0x5C, 4, 3}, // Absolute,X
 			{"NOP_7C", 0x7C, 4, 3}, // Absolute,X
@@ -256,50 +257,99 @@ func TestIllegalNOPs(t *testing.T) {
 	})
 }
 
-// Test behavior of completely undefined opcodes
-func TestUndefinedOpcodes(t *testing.T) {
-	t.Run("Undefined_Opcodes_Behavior", func(t *testing.T) {
-		// Test some undefined opcodes that might cause different behavior
-		undefinedOpcodes := []uint8{
-			0x02, 0x12, 0x22, 0x32, 0x42, 0x52, 0x62, 0x72,
-			0x92, 0xB2, 0xD2, 0xF2,
-		}
-		
-		for _, opcode := range undefinedOpcodes {
-			t.Run(fmt.Sprintf("Opcode_0x%02X", opcode), func(t *testing.T) {
-				cpu := createTestCPU()
-				cpu.PC = 0x0200
-				cpu.Memory.Write(0x0200, opcode)
-				
-				// Store original state
-				originalA := cpu.A
-				originalX := cpu.X
-				originalY := cpu.Y
-				originalP := cpu.P
-				originalSP := cpu.SP
-				originalPC := cpu.PC
-				
-				// Execute the undefined opcode
-				cycles := cpu.Step()
-				
-				// Document the behavior for regression testing
-				t.Logf("Opcode 0x%02X: PC=%04X->%04X, A=%02X->%02X, X=%02X->%02X, Y=%02X->%02X, P=%02X->%02X, SP=%02X->%02X, cycles=%d",
-					opcode,
-					originalPC, cpu.PC,
-					originalA, cpu.A,
-					originalX, cpu.X,
-					originalY, cpu.Y,
-					originalP, cpu.P,
-					originalSP, cpu.SP,
-					cycles)
-				
-				// At minimum, PC should advance
-				if cpu.PC == originalPC {
-					t.Errorf("PC did not advance for undefined opcode 0x%02X", opcode)
+// TestJAMOpcodes checks that every KIL opcode halts the CPU in place: PC
+// stays on the opcode, registers are untouched, later Steps only burn
+// cycles (NMI included), and only a reset releases it.
+func TestJAMOpcodes(t *testing.T) {
+	jamOpcodes := []uint8{
+		0x02, 0x12, 0x22, 0x32, 0x42, 0x52, 0x62, 0x72,
+		0x92, 0xB2, 0xD2, 0xF2,
+	}
+
+	for _, opcode := range jamOpcodes {
+		t.Run(fmt.Sprintf("Opcode_0x%02X", opcode), func(t *testing.T) {
+			cpu := createTestCPU()
+			cpu.PC = 0x0200
+			cpu.Memory.Write(0x0200, opcode)
+			cpu.A, cpu.X, cpu.Y = 0x11, 0x22, 0x33
+			before := *cpu
+
+			cpu.Step()
+			if !cpu.Halted() {
+				t.Fatal("CPU not halted")
+			}
+			if cpu.PC != 0x0200 || cpu.A != before.A || cpu.X != before.X ||
+				cpu.Y != before.Y || cpu.P != before.P || cpu.SP != before.SP {
+				t.Errorf("registers changed: PC=%04X A=%02X X=%02X Y=%02X P=%02X SP=%02X",
+					cpu.PC, cpu.A, cpu.X, cpu.Y, cpu.P, cpu.SP)
+			}
+
+			cpu.TriggerNMI()
+			for i := 0; i < 10; i++ {
+				if cycles := cpu.Step(); cycles != 1 {
+					t.Fatalf("halted Step returned %d cycles, want 1", cycles)
 				}
-			})
-		}
-	})
+			}
+			if cpu.PC != 0x0200 || cpu.SP != before.SP {
+				t.Errorf("halted CPU moved: PC=%04X SP=%02X", cpu.PC, cpu.SP)
+			}
+
+			cpu.Reset()
+			if cpu.Halted() || cpu.PC != 0x0200 {
+				t.Errorf("after Reset: halted=%v PC=%04X", cpu.Halted(), cpu.PC)
+			}
+		})
+	}
+}
+
+func TestHaltInfo(t *testing.T) {
+	cpu := createTestCPU()
+	if cpu.HaltInfo() != nil {
+		t.Fatal("HaltInfo non-nil on a running CPU")
+	}
+	cpu.PC = 0x0300
+	cpu.Memory.Write(0x0300, 0x92)
+	cpu.SP = 0xFB
+	cpu.Memory.Write(0x01FC, 0xAB)
+	cpu.Memory.Write(0x01FD, 0xCD)
+	cpu.Memory.Write(0x01FE, 0x01)
+	cpu.Memory.Write(0x01FF, 0x02)
+	cpu.Step()
+
+	info := cpu.HaltInfo()
+	if info == nil {
+		t.Fatal("HaltInfo nil after JAM")
+	}
+	if info.PC != 0x0300 || info.Opcode != 0x92 || info.SP != 0xFB {
+		t.Errorf("HaltInfo = PC %04X opcode %02X SP %02X, want 0300/92/FB", info.PC, info.Opcode, info.SP)
+	}
+	if want := []uint8{0xAB, 0xCD, 0x01, 0x02}; fmt.Sprint(info.Stack) != fmt.Sprint(want) {
+		t.Errorf("Stack = % X, want % X", info.Stack, want)
+	}
+	want := "CPU halted by JAM opcode $92 at $0300 (A=$00 X=$00 Y=$00 P=$24 SP=$FB) stack: AB CD 01 02"
+	if got := info.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	cpu.Step()
+	if cpu.HaltInfo() != info {
+		t.Error("HaltInfo rebuilt its diagnostic for a CPU still jammed")
+	}
+
+	// The halted state survives a save-state round trip.
+	var buf bytes.Buffer
+	if err := cpu.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	cpu.SoftReset()
+	if cpu.Halted() || cpu.HaltInfo() != nil {
+		t.Fatal("SoftReset did not release the CPU")
+	}
+	if err := cpu.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if info := cpu.HaltInfo(); info == nil || info.Opcode != 0x92 {
+		t.Errorf("HaltInfo after LoadState = %v, want JAM $92", info)
+	}
 }
 
 // Test some additional illegal instructions that have specific behaviors
@@ -404,4 +454,105 @@ func TestAdditionalIllegalInstructions(t *testing.T) {
 		// A = 0x10 + 0x81 = 0x91
 		t.Logf("RRA test executed with %d cycles", cycles)
 	})
-}
\ No newline at end of file
+}
+// TestHighByteAndStores covers SHY/SHX/AHX/TAS: the stored value is ANDed
+// with the base address's high byte + 1, and on a page cross that value
+// also replaces the target's high byte.
+func TestHighByteAndStores(t *testing.T) {
+	cases := []struct {
+		name       string
+		program    []uint8
+		a, x, y    uint8
+		wantAddr   uint16
+		wantValue  uint8
+		wantSP     uint8 // 0 = unchanged
+		wantCycles int
+	}{
+		{"SHY abs,X", []uint8{0x9C, 0x00, 0x03}, 0, 0x05, 0xFF, 0x0305, 0x04, 0, 5},
+		{"SHY abs,X page cross", []uint8{0x9C, 0xF0, 0x04}, 0, 0x20, 0x01, 0x0110, 0x01, 0, 5},
+		{"SHX abs,Y", []uint8{0x9E, 0x00, 0x05}, 0, 0xFF, 0x02, 0x0502, 0x06, 0, 5},
+		{"AHX abs,Y", []uint8{0x9F, 0x00, 0x03}, 0xF3, 0x3F, 0x01, 0x0301, 0x04 & 0x33, 0, 5},
+		{"AHX (zp),Y", []uint8{0x93, 0x40}, 0xFF, 0xFF, 0x10, 0x0410, 0x05, 0, 6},
+		{"TAS abs,Y", []uint8{0x9B, 0x00, 0x03}, 0xF7, 0x7E, 0x00, 0x0300, 0x04, 0x76, 5},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cpu := createTestCPU()
+			cpu.PC = 0x0200
+			for i, b := range tc.program {
+				cpu.Memory.Write(0x0200+uint16(i), b)
+			}
+			cpu.Memory.Write(0x40, 0x00) // ($40) = $0400
+			cpu.Memory.Write(0x41, 0x04)
+			cpu.A, cpu.X, cpu.Y = tc.a, tc.x, tc.y
+			sp := cpu.SP
+
+			cycles := cpu.Step()
+			if got := cpu.Memory.Read(tc.wantAddr); got != tc.wantValue {
+				t.Errorf("[$%04X] = %02X, want %02X", tc.wantAddr, got, tc.wantValue)
+			}
+			if tc.wantSP != 0 {
+				sp = tc.wantSP
+			}
+			if cpu.SP != sp {
+				t.Errorf("SP = %02X, want %02X", cpu.SP, sp)
+			}
+			if cycles != tc.wantCycles {
+				t.Errorf("cycles = %d, want %d", cycles, tc.wantCycles)
+			}
+		})
+	}
+}
+
+func TestLAS(t *testing.T) {
+	cpu := createTestCPU()
+	cpu.PC = 0x0200
+	cpu.Memory.Write(0x0200, 0xBB) // LAS $02F0,Y
+	cpu.Memory.Write(0x0201, 0xF0)
+	cpu.Memory.Write(0x0202, 0x02)
+	cpu.Memory.Write(0x0310, 0xB5)
+	cpu.Y = 0x20
+	cpu.SP = 0xF3
+
+	if cycles := cpu.Step(); cycles != 5 {
+		t.Errorf("cycles = %d, want 5 (page cross)", cycles)
+	}
+	if cpu.A != 0xB1 || cpu.X != 0xB1 || cpu.SP != 0xB1 {
+		t.Errorf("A/X/SP = %02X/%02X/%02X, want B1", cpu.A, cpu.X, cpu.SP)
+	}
+	if !cpu.getFlag(FlagNegative) || cpu.getFlag(FlagZero) {
+		t.Errorf("N=%v Z=%v, want N set", cpu.getFlag(FlagNegative), cpu.getFlag(FlagZero))
+	}
+}
+
+// TestMagicConstantOpcodes runs XAA and LXA under both UnstableMode
+// settings.
+func TestMagicConstantOpcodes(t *testing.T) {
+	cases := []struct {
+		name         string
+		opcode, imm  uint8
+		config       Config
+		a, x         uint8
+		wantA, wantX uint8
+	}{
+		{"XAA stable", 0x8B, 0x0F, DefaultConfig, 0x00, 0x3C, 0x0C, 0x3C},
+		{"XAA magic $EE", 0x8B, 0xFF, Config{Unstable: UnstableMagic, Magic: 0xEE}, 0x01, 0xFF, 0xEF, 0xFF},
+		{"XAA magic $00", 0x8B, 0xFF, Config{Unstable: UnstableMagic, Magic: 0x00}, 0x30, 0xF0, 0x30, 0xF0},
+		{"LXA stable", 0xAB, 0x55, DefaultConfig, 0x00, 0x00, 0x55, 0x55},
+		{"LXA magic $EE", 0xAB, 0xFF, Config{Unstable: UnstableMagic, Magic: 0xEE}, 0x00, 0x00, 0xEE, 0xEE},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cpu := createTestCPU()
+			cpu.SetConfig(tc.config)
+			cpu.A, cpu.X = tc.a, tc.x
+			runImm(cpu, tc.opcode, tc.imm)
+			if cpu.A != tc.wantA || cpu.X != tc.wantX {
+				t.Errorf("A/X = %02X/%02X, want %02X/%02X", cpu.A, cpu.X, tc.wantA, tc.wantX)
+			}
+		})
+	}
+	if New(nil).Config() != DefaultConfig {
+		t.Error("New did not start from DefaultConfig")
+	}
+}
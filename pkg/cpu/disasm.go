@@ -0,0 +1,136 @@
+package cpu
+
+import "fmt"
+
+// OpcodeClass groups opcodes by what they do, for trace filters. Classes
+// are bits so a filter can accept several.
+type OpcodeClass uint16
+
+const (
+	ClassLoad     OpcodeClass = 1 << iota // LDA, LDX, LDY, LAX, LAS
+	ClassStore                            // STA, STX, STY, SAX, SHX, …
+	ClassALU                              // arithmetic, logic, compares, shifts, INC/DEC
+	ClassTransfer                         // register transfers and flag changes
+	ClassStack                            // PHA, PLA, PHP, PLP
+	ClassBranch                           // conditional branches
+	ClassJump                             // JMP, JSR, RTS, RTI, BRK, and KIL (JAM)
+	ClassNOP                              // NOP, documented or not
+	ClassIllegal                          // any undocumented opcode, on top of its own class
+)
+
+// ClassNames maps the names trace filters use to classes.
+var ClassNames = map[string]OpcodeClass{
+	"load":     ClassLoad,
+	"store":    ClassStore,
+	"alu":      ClassALU,
+	"transfer": ClassTransfer,
+	"stack":    ClassStack,
+	"branch":   ClassBranch,
+	"jump":     ClassJump,
+	"nop":      ClassNOP,
+	"illegal":  ClassIllegal,
+}
+
+// mnemonicClass classifies every mnemonic in opcodeDefs.
+var mnemonicClass = map[string]OpcodeClass{
+	"LDA": ClassLoad, "LDX": ClassLoad, "LDY": ClassLoad, "LAX": ClassLoad, "LAS": ClassLoad,
+	"STA": ClassStore, "STX": ClassStore, "STY": ClassStore, "SAX": ClassStore,
+	"AHX": ClassStore, "SHX": ClassStore, "SHY": ClassStore, "TAS": ClassStore,
+	"TAX": ClassTransfer, "TAY": ClassTransfer, "TXA": ClassTransfer, "TYA": ClassTransfer,
+	"TSX": ClassTransfer, "TXS": ClassTransfer,
+	"CLC": ClassTransfer, "SEC": ClassTransfer, "CLI": ClassTransfer, "SEI": ClassTransfer,
+	"CLV": ClassTransfer, "CLD": ClassTransfer, "SED": ClassTransfer,
+	"PHA": ClassStack, "PLA": ClassStack, "PHP": ClassStack, "PLP": ClassStack,
+	"BPL": ClassBranch, "BMI": ClassBranch, "BVC": ClassBranch, "BVS": ClassBranch,
+	"BCC": ClassBranch, "BCS": ClassBranch, "BNE": ClassBranch, "BEQ": ClassBranch,
+	"JMP": ClassJump, "JSR": ClassJump, "RTS": ClassJump, "RTI": ClassJump, "BRK": ClassJump,
+	"KIL": ClassJump,
+	"NOP": ClassNOP,
+}
+
+// illegalMnemonics are the undocumented opcodes' names; undocumented NOPs
+// and the $EB SBC alias are told apart by opcode instead.
+var illegalMnemonics = map[string]bool{
+	"AAC": true, "AHX": true, "ARR": true, "ASR": true, "ATX": true, "AXS": true,
+	"DCP": true, "ISB": true, "KIL": true, "LAS": true, "LAX": true, "RLA": true,
+	"RRA": true, "SAX": true, "SHX": true, "SHY": true, "SLO": true, "SRE": true,
+	"TAS": true, "XAA": true,
+}
+
+// Classify returns the classes opcode belongs to: one of the kinds, plus
+// ClassIllegal for undocumented opcodes. Mnemonics missing from
+// mnemonicClass compute something (ADC, DCP, ASL, …) and are ClassALU.
+func Classify(opcode uint8) OpcodeClass {
+	name := opcodes[opcode].name
+	class, ok := mnemonicClass[name]
+	if !ok {
+		class = ClassALU
+	}
+	if illegalMnemonics[name] || (name == "NOP" && opcode != 0xEA) || opcode == 0xEB {
+		class |= ClassIllegal
+	}
+	return class
+}
+
+// InstructionLength is the size in bytes of the instruction opcode starts.
+func InstructionLength(opcode uint8) int {
+	switch opcodes[opcode].mode {
+	case AddrImplied, AddrAccumulator:
+		return 1
+	case AddrAbsolute, AddrAbsoluteX, AddrAbsoluteY, AddrIndirect:
+		return 3
+	}
+	return 2
+}
+
+// Mnemonic returns opcode's three-letter name, as the illegal-opcode
+// documents name the undocumented ones.
+func Mnemonic(opcode uint8) string { return opcodes[opcode].name }
+
+// Encode returns the documented opcode for mnemonic name in mode, the
+// inverse of Mnemonic for assemblers; ok is false when the 6502 has no
+// such instruction. Undocumented opcodes are never chosen.
+func Encode(name string, mode AddressingMode) (opcode uint8, ok bool) {
+	for op := range opcodes {
+		if opcodes[op].name == name && opcodes[op].mode == mode && Classify(uint8(op))&ClassIllegal == 0 {
+			return uint8(op), true
+		}
+	}
+	return 0, false
+}
+
+// Disassemble formats the instruction in b (opcode and up to two operand
+// bytes) at pc in the usual assembler syntax, e.g. "LDA $0300,X"; branch
+// targets are resolved to absolute addresses.
+func Disassemble(pc uint16, b [3]uint8) string {
+	info := opcodes[b[0]]
+	zp := b[1]
+	abs := uint16(b[1]) | uint16(b[2])<<8
+	switch info.mode {
+	case AddrAccumulator:
+		return info.name + " A"
+	case AddrImmediate:
+		return fmt.Sprintf("%s #$%02X", info.name, zp)
+	case AddrZeroPage:
+		return fmt.Sprintf("%s $%02X", info.name, zp)
+	case AddrZeroPageX:
+		return fmt.Sprintf("%s $%02X,X", info.name, zp)
+	case AddrZeroPageY:
+		return fmt.Sprintf("%s $%02X,Y", info.name, zp)
+	case AddrRelative:
+		return fmt.Sprintf("%s $%04X", info.name, pc+2+uint16(int8(zp)))
+	case AddrAbsolute:
+		return fmt.Sprintf("%s $%04X", info.name, abs)
+	case AddrAbsoluteX:
+		return fmt.Sprintf("%s $%04X,X", info.name, abs)
+	case AddrAbsoluteY:
+		return fmt.Sprintf("%s $%04X,Y", info.name, abs)
+	case AddrIndirect:
+		return fmt.Sprintf("%s ($%04X)", info.name, abs)
+	case AddrIndexedIndirect:
+		return fmt.Sprintf("%s ($%02X,X)", info.name, zp)
+	case AddrIndirectIndexed:
+		return fmt.Sprintf("%s ($%02X),Y", info.name, zp)
+	}
+	return info.name
+}
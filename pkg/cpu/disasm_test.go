@@ -0,0 +1,101 @@
+package cpu
+
+import "testing"
+
+func TestDisassemble(t *testing.T) {
+	for _, tc := range []struct {
+		pc   uint16
+		b    [3]uint8
+		want string
+		len  int
+	}{
+		{0x8000, [3]uint8{0xA9, 0x05}, "LDA #$05", 2},
+		{0x8000, [3]uint8{0xBD, 0x00, 0x03}, "LDA $0300,X", 3},
+		{0x8000, [3]uint8{0x0A}, "ASL A", 1},
+		{0x8000, [3]uint8{0xEA}, "NOP", 1},
+		{0x8010, [3]uint8{0xD0, 0xFC}, "BNE $800E", 2},
+		{0x8010, [3]uint8{0xF0, 0x10}, "BEQ $8022", 2},
+		{0x8000, [3]uint8{0x6C, 0xFC, 0xFF}, "JMP ($FFFC)", 3},
+		{0x8000, [3]uint8{0xA1, 0x20}, "LDA ($20,X)", 2},
+		{0x8000, [3]uint8{0xB1, 0x20}, "LDA ($20),Y", 2},
+		{0x8000, [3]uint8{0xB6, 0x20}, "LDX $20,Y", 2},
+		{0x8000, [3]uint8{0xA7, 0x20}, "LAX $20", 2},
+	} {
+		if got := Disassemble(tc.pc, tc.b); got != tc.want {
+			t.Errorf("Disassemble(%02X) = %q, want %q", tc.b[0], got, tc.want)
+		}
+		if got := InstructionLength(tc.b[0]); got != tc.len {
+			t.Errorf("InstructionLength(%02X) = %d, want %d", tc.b[0], got, tc.len)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		op   uint8
+		want OpcodeClass
+	}{
+		{0xA9, ClassLoad},
+		{0x8D, ClassStore},
+		{0x69, ClassALU},
+		{0xC9, ClassALU},
+		{0xAA, ClassTransfer},
+		{0x78, ClassTransfer},
+		{0x48, ClassStack},
+		{0xD0, ClassBranch},
+		{0x20, ClassJump},
+		{0x00, ClassJump},
+		{0xEA, ClassNOP},
+		{0x1A, ClassNOP | ClassIllegal},
+		{0xEB, ClassALU | ClassIllegal},
+		{0xA7, ClassLoad | ClassIllegal},
+		{0xC7, ClassALU | ClassIllegal},
+		{0x02, ClassJump | ClassIllegal},
+	} {
+		if got := Classify(tc.op); got != tc.want {
+			t.Errorf("Classify(%02X) = %b, want %b", tc.op, got, tc.want)
+		}
+	}
+	// Every mnemonic is either classified or computes something.
+	for op := 0; op < 256; op++ {
+		if Classify(uint8(op))&^ClassIllegal == 0 {
+			t.Errorf("opcode %02X (%s) has no class", op, Mnemonic(uint8(op)))
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mode AddressingMode
+		op   uint8
+		ok   bool
+	}{
+		{"LDA", AddrImmediate, 0xA9, true},
+		{"ASL", AddrAccumulator, 0x0A, true},
+		{"JMP", AddrIndirect, 0x6C, true},
+		{"NOP", AddrImplied, 0xEA, true},
+		{"SBC", AddrImmediate, 0xE9, true}, // not the $EB alias
+		{"STA", AddrImmediate, 0, false},
+		{"LAX", AddrZeroPage, 0, false}, // undocumented
+	} {
+		op, ok := Encode(tc.name, tc.mode)
+		if op != tc.op || ok != tc.ok {
+			t.Errorf("Encode(%s, %d) = %02X, %v; want %02X, %v", tc.name, tc.mode, op, ok, tc.op, tc.ok)
+		}
+	}
+	// 151 documented opcodes, each encoding back to itself.
+	n := 0
+	for op := 0; op < 256; op++ {
+		if Classify(uint8(op))&ClassIllegal != 0 {
+			continue
+		}
+		n++
+		if got, ok := Encode(Mnemonic(uint8(op)), opcodes[op].mode); !ok || got != uint8(op) {
+			t.Errorf("opcode %02X encodes back as %02X, %v", op, got, ok)
+		}
+	}
+	if n != 151 {
+		t.Errorf("%d documented opcodes, want 151", n)
+	}
+}
@@ -0,0 +1,78 @@
+// Package cpu — halt.go covers the JAM (KIL) opcodes and the halted state
+// they leave the CPU in.
+//
+// Twelve opcodes ($02 $12 $22 $32 $42 $52 $62 $72 $92 $B2 $D2 $F2) send
+// the 6502's decode logic into a loop it never leaves: the data bus stays
+// at $FF, no further opcode is fetched, and NMI/IRQ are never serviced.
+// Only the reset line recovers it. The PPU and APU are separate chips and
+// keep running, so a jammed game shows a frozen picture over live audio.
+package cpu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+)
+
+// HaltError describes a CPU stopped by a JAM opcode: where it happened and
+// the register and stack contents left behind, which usually point at the
+// bad jump or corrupted return address that led there.
+type HaltError struct {
+	PC             uint16 // address of the JAM opcode
+	Opcode         uint8
+	A, X, Y, P, SP uint8
+	Stack          []uint8 // $0100+SP+1 through $01FF, top of stack first
+}
+
+func (e *HaltError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CPU halted by JAM opcode $%02X at $%04X (A=$%02X X=$%02X Y=$%02X P=$%02X SP=$%02X)",
+		e.Opcode, e.PC, e.A, e.X, e.Y, e.P, e.SP)
+	if len(e.Stack) > 0 {
+		b.WriteString(" stack:")
+		for _, v := range e.Stack {
+			fmt.Fprintf(&b, " %02X", v)
+		}
+	}
+	return b.String()
+}
+
+// execKIL jams the CPU. PC is wound back onto the opcode so debuggers and
+// the diagnostic show where execution died.
+func (c *CPU) execKIL(AddressingMode) {
+	c.PC--
+	c.halted = true
+	c.haltOpcode = c.opcode
+	c.haltInfo = nil
+	logger.LogCPU("JAM $%02X at $%04X, CPU halted", c.haltOpcode, c.PC)
+}
+
+// Halted reports whether a JAM opcode has stopped the CPU. Only Reset or
+// SoftReset clear it.
+func (c *CPU) Halted() bool {
+	return c.halted
+}
+
+// HaltInfo returns the diagnostic for a halted CPU, or nil while it is
+// running. The stack is read straight from RAM, without bus side effects.
+// The first call after the JAM builds it and later ones return the same
+// value, so NES.Step can hand it back on every step of a frozen CPU
+// without allocating.
+func (c *CPU) HaltInfo() *HaltError {
+	if !c.halted {
+		return nil
+	}
+	if c.haltInfo != nil {
+		return c.haltInfo
+	}
+	e := &HaltError{
+		PC: c.PC, Opcode: c.haltOpcode,
+		A: c.A, X: c.X, Y: c.Y, P: c.P, SP: c.SP,
+	}
+	for sp := int(c.SP) + 1; sp <= 0xFF; sp++ {
+		e.Stack = append(e.Stack, c.Memory.RAM[0x100+sp])
+	}
+	c.haltInfo = e
+	return e
+}
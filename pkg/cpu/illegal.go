@@ -3,46 +3,26 @@ package cpu
 // This file contains implementations of the 6502's illegal/undocumented
 // opcodes. They are split out from instructions.go purely for readability;
 // each method remains on *CPU and shares package state with the legal
-// instruction implementations.
+// instruction implementations. Cycle costs live in opcodeDefs alongside
+// the legal opcodes.
 
 // LAX - Load Accumulator and X register
-func (c *CPU) execLAX(mode AddressingMode) int {
-	value, pageCrossed := c.getOperand(mode)
+func (c *CPU) execLAX(mode AddressingMode) {
+	value := c.getOperand(mode)
 	c.A = value
 	c.X = value
 	c.setZN(value)
-
-	baseCycles := map[AddressingMode]int{
-		AddrAbsolute:        4,
-		AddrAbsoluteY:       4,
-		AddrZeroPage:        3,
-		AddrZeroPageY:       4,
-		AddrIndexedIndirect: 6,
-		AddrIndirectIndexed: 5,
-	}[mode]
-
-	if pageCrossed && (mode == AddrAbsoluteY || mode == AddrIndirectIndexed) {
-		baseCycles++
-	}
-	return baseCycles
 }
 
 // SAX - Store A AND X
-func (c *CPU) execSAX(mode AddressingMode) int {
+func (c *CPU) execSAX(mode AddressingMode) {
 	addr, _ := c.getOperandAddress(mode)
 	result := c.A & c.X
 	c.write(addr, result)
-
-	return map[AddressingMode]int{
-		AddrAbsolute:        4,
-		AddrZeroPage:        3,
-		AddrZeroPageY:       4,
-		AddrIndexedIndirect: 6,
-	}[mode]
 }
 
 // DCP - Decrement and Compare
-func (c *CPU) execDCP(mode AddressingMode) int {
+func (c *CPU) execDCP(mode AddressingMode) {
 	addr := c.getWriteAddress(mode)
 	value := c.rmwRead(addr)
 	value--
@@ -52,22 +32,10 @@ func (c *CPU) execDCP(mode AddressingMode) int {
 	result := uint16(c.A) - uint16(value)
 	c.setFlag(FlagCarry, result < 0x100)
 	c.setZN(uint8(result))
-
-	baseCycles := map[AddressingMode]int{
-		AddrAbsolute:        6,
-		AddrAbsoluteX:       7,
-		AddrAbsoluteY:       7,
-		AddrZeroPage:        5,
-		AddrZeroPageX:       6,
-		AddrIndexedIndirect: 8,
-		AddrIndirectIndexed: 8,
-	}[mode]
-
-	return baseCycles
 }
 
 // ISB - Increment and Subtract with Borrow (also known as ISC)
-func (c *CPU) execISB(mode AddressingMode) int {
+func (c *CPU) execISB(mode AddressingMode) {
 	addr := c.getWriteAddress(mode)
 	value := c.rmwRead(addr)
 	value++
@@ -75,22 +43,10 @@ func (c *CPU) execISB(mode AddressingMode) int {
 
 	// Perform SBC with the incremented value
 	c.performSBC(value)
-
-	baseCycles := map[AddressingMode]int{
-		AddrAbsolute:        6,
-		AddrAbsoluteX:       7,
-		AddrAbsoluteY:       7,
-		AddrZeroPage:        5,
-		AddrZeroPageX:       6,
-		AddrIndexedIndirect: 8,
-		AddrIndirectIndexed: 8,
-	}[mode]
-
-	return baseCycles
 }
 
 // SLO - Shift Left and OR
-func (c *CPU) execSLO(mode AddressingMode) int {
+func (c *CPU) execSLO(mode AddressingMode) {
 	addr := c.getWriteAddress(mode)
 	value := c.rmwRead(addr)
 
@@ -102,22 +58,10 @@ func (c *CPU) execSLO(mode AddressingMode) int {
 	// OR with A
 	c.A |= value
 	c.setZN(c.A)
-
-	baseCycles := map[AddressingMode]int{
-		AddrAbsolute:        6,
-		AddrAbsoluteX:       7,
-		AddrAbsoluteY:       7,
-		AddrZeroPage:        5,
-		AddrZeroPageX:       6,
-		AddrIndexedIndirect: 8,
-		AddrIndirectIndexed: 8,
-	}[mode]
-
-	return baseCycles
 }
 
 // RLA - Rotate Left and AND
-func (c *CPU) execRLA(mode AddressingMode) int {
+func (c *CPU) execRLA(mode AddressingMode) {
 	addr := c.getWriteAddress(mode)
 	value := c.rmwRead(addr)
 
@@ -134,22 +78,10 @@ func (c *CPU) execRLA(mode AddressingMode) int {
 	// AND with A
 	c.A &= value
 	c.setZN(c.A)
-
-	baseCycles := map[AddressingMode]int{
-		AddrAbsolute:        6,
-		AddrAbsoluteX:       7,
-		AddrAbsoluteY:       7,
-		AddrZeroPage:        5,
-		AddrZeroPageX:       6,
-		AddrIndexedIndirect: 8,
-		AddrIndirectIndexed: 8,
-	}[mode]
-
-	return baseCycles
 }
 
 // SRE - Shift Right and EOR
-func (c *CPU) execSRE(mode AddressingMode) int {
+func (c *CPU) execSRE(mode AddressingMode) {
 	addr := c.getWriteAddress(mode)
 	value := c.rmwRead(addr)
 
@@ -161,22 +93,10 @@ func (c *CPU) execSRE(mode AddressingMode) int {
 	// EOR with A
 	c.A ^= value
 	c.setZN(c.A)
-
-	baseCycles := map[AddressingMode]int{
-		AddrAbsolute:        6,
-		AddrAbsoluteX:       7,
-		AddrAbsoluteY:       7,
-		AddrZeroPage:        5,
-		AddrZeroPageX:       6,
-		AddrIndexedIndirect: 8,
-		AddrIndirectIndexed: 8,
-	}[mode]
-
-	return baseCycles
 }
 
 // RRA - Rotate Right and Add
-func (c *CPU) execRRA(mode AddressingMode) int {
+func (c *CPU) execRRA(mode AddressingMode) {
 	addr := c.getWriteAddress(mode)
 	value := c.rmwRead(addr)
 
@@ -192,18 +112,6 @@ func (c *CPU) execRRA(mode AddressingMode) int {
 
 	// Add to A with carry
 	c.performADC(value)
-
-	baseCycles := map[AddressingMode]int{
-		AddrAbsolute:        6,
-		AddrAbsoluteX:       7,
-		AddrAbsoluteY:       7,
-		AddrZeroPage:        5,
-		AddrZeroPageX:       6,
-		AddrIndexedIndirect: 8,
-		AddrIndirectIndexed: 8,
-	}[mode]
-
-	return baseCycles
 }
 
 // Helper function for SBC operation (used by ISB)
@@ -233,21 +141,17 @@ func (c *CPU) performADC(value uint8) {
 
 // AAC - AND accumulator with immediate (also sets carry flag).
 // Also known as ANC in some references.
-func (c *CPU) execAAC() int {
-	value := c.read(c.PC)
-	c.PC++
+func (c *CPU) execAAC(mode AddressingMode) {
+	value := c.getOperand(mode)
 
 	c.A &= value
 	c.setZN(c.A)
 	c.setFlag(FlagCarry, c.A&0x80 != 0) // Set carry flag based on bit 7
-
-	return 2
 }
 
 // ASR - AND with immediate, then LSR (also known as ALR).
-func (c *CPU) execASR() int {
-	value := c.read(c.PC)
-	c.PC++
+func (c *CPU) execASR(mode AddressingMode) {
+	value := c.getOperand(mode)
 
 	// AND with immediate
 	c.A &= value
@@ -256,14 +160,11 @@ func (c *CPU) execASR() int {
 	c.setFlag(FlagCarry, c.A&0x01 != 0)
 	c.A >>= 1
 	c.setZN(c.A)
-
-	return 2
 }
 
 // ARR - AND with immediate, then ROR
-func (c *CPU) execARR() int {
-	value := c.read(c.PC)
-	c.PC++
+func (c *CPU) execARR(mode AddressingMode) {
+	value := c.getOperand(mode)
 
 	// AND with immediate
 	c.A &= value
@@ -283,29 +184,23 @@ func (c *CPU) execARR() int {
 	c.setFlag(FlagOverflow, ((c.A>>6)&1)^((c.A>>5)&1) != 0)
 	// C = bit 6 of result
 	c.setFlag(FlagCarry, c.A&0x40 != 0)
-
-	return 2
 }
 
 // ATX - Load immediate to A and X (also known as LXA).
-func (c *CPU) execATX() int {
-	value := c.read(c.PC)
-	c.PC++
+func (c *CPU) execATX(mode AddressingMode) {
+	value := c.getOperand(mode)
 
-	// ATX (LXA) loads immediate value to both A and X
-	// Simple implementation: just load the value
-	c.A = value
-	c.X = value
+	// ATX (LXA) loads (A | magic) & imm into both A and X; see config.go
+	// for the magic constant.
+	c.A = (c.A | c.magic()) & value
+	c.X = c.A
 	c.setZN(c.A)
-
-	return 2
 }
 
 // AXS - AND X with A, then subtract immediate (without borrow).
 // Also known as SBX.
-func (c *CPU) execAXS() int {
-	value := c.read(c.PC)
-	c.PC++
+func (c *CPU) execAXS(mode AddressingMode) {
+	value := c.getOperand(mode)
 
 	// AND X with A
 	temp := c.A & c.X
@@ -317,6 +212,64 @@ func (c *CPU) execAXS() int {
 	// Set flags
 	c.setFlag(FlagCarry, result < 0x100) // Set carry if no borrow
 	c.setZN(c.X)
+}
+
+// XAA - Transfer X to A, then AND with immediate (also known as ANE). The
+// accumulator is ORed with the magic constant first; see config.go.
+func (c *CPU) execXAA(mode AddressingMode) {
+	value := c.getOperand(mode)
+	c.A = (c.A | c.magic()) & c.X & value
+	c.setZN(c.A)
+}
+
+// LAS - AND memory with SP, then load the result into A, X and SP (also
+// known as LAR).
+func (c *CPU) execLAS(mode AddressingMode) {
+	value := c.getOperand(mode) & c.SP
+	c.A = value
+	c.X = value
+	c.SP = value
+	c.setZN(value)
+}
+
+// SHY - Store Y AND (high byte of base address + 1) (also known as SYA).
+func (c *CPU) execSHY(mode AddressingMode) {
+	c.storeHighAnd(mode, c.Y)
+}
+
+// SHX - Store X AND (high byte of base address + 1) (also known as SXA).
+func (c *CPU) execSHX(mode AddressingMode) {
+	c.storeHighAnd(mode, c.X)
+}
+
+// AHX - Store A AND X AND (high byte of base address + 1) (also known as
+// SHA / AXA).
+func (c *CPU) execAHX(mode AddressingMode) {
+	c.storeHighAnd(mode, c.A&c.X)
+}
+
+// TAS - SP = A AND X, then store SP AND (high byte of base address + 1)
+// (also known as SHS / XAS).
+func (c *CPU) execTAS(mode AddressingMode) {
+	c.SP = c.A & c.X
+	c.storeHighAnd(mode, c.SP)
+}
 
-	return 2
+// storeHighAnd is the store shared by SHY/SHX/AHX/TAS. The value written is
+// ANDed with the base address's high byte plus one — the bus still holds
+// that byte when the value is driven. When indexing crosses a page the
+// CPU's carry fix-up is skipped and the written value becomes the target's
+// high byte instead (blargg's instr_test 07-abs_xy checks SHY/SHX).
+func (c *CPU) storeHighAnd(mode AddressingMode, value uint8) {
+	index := c.Y
+	if mode == AddrAbsoluteX {
+		index = c.X
+	}
+	addr := c.getWriteAddress(mode)
+	base := addr - uint16(index)
+	value &= uint8(base>>8) + 1
+	if base&0xFF00 != addr&0xFF00 {
+		addr = uint16(value)<<8 | addr&0x00FF
+	}
+	c.write(addr, value)
 }
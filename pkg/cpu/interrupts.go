@@ -12,8 +12,10 @@ import (
 
 // Reset performs a power-on reset: A,X,Y=0, P=$34 (B|I|U set in the
 // pushed copy), S=$FD, PC loaded from $FFFC. blargg's cpu_reset suite
-// distinguishes this from SoftReset.
+// distinguishes this from SoftReset. Reset also releases a CPU halted by
+// a JAM opcode.
 func (c *CPU) Reset() {
+	c.halted, c.haltInfo = false, nil
 	c.A = 0
 	c.X = 0
 	c.Y = 0
@@ -26,8 +28,9 @@ func (c *CPU) Reset() {
 // SoftReset models the user pressing the reset button: A,X,Y are
 // untouched, I is forced set, and S decrements by 3 (the reset
 // "pushes" 3 bytes but the writes are suppressed by the reset line —
-// the stack contents are preserved).
+// the stack contents are preserved). This is the only way out of a JAM.
 func (c *CPU) SoftReset() {
+	c.halted, c.haltInfo = false, nil
 	c.SP -= 3
 	c.setFlag(FlagInterrupt, true)
 	c.PC = c.read16(0xFFFC)
@@ -35,7 +38,10 @@ func (c *CPU) SoftReset() {
 
 // handleNMI handles Non-Maskable Interrupt
 func (c *CPU) handleNMI() {
-	logger.LogCPU("NMI triggered: PC=$%04X, pushing to stack", c.PC)
+	// Guarded: boxing PC for the variadic call allocates once per frame.
+	if logger.CPUEnabled() {
+		logger.LogCPU("NMI triggered: PC=$%04X, pushing to stack", c.PC)
+	}
 	c.push16(c.PC)
 	c.push(c.P)
 	c.vector(0xFFFA)
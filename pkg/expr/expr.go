@@ -0,0 +1,318 @@
+// Package expr evaluates the small expressions debuggers use for
+// conditional breakpoints, watches and logpoints, such as
+//
+//	[$0300+X] & $0F == 3
+//	scanline >= 240 && [PlayerState] != 0
+//
+// An expression is compiled once and then evaluated against a running
+// NES as often as needed — before every instruction, for a breakpoint
+// condition — without allocating.
+//
+// Operands are numbers ($C000 or 0xC000 hex, %0101 binary, or decimal),
+// the CPU registers A, X, Y, P, SP and PC, the PPU position frame,
+// scanline and dot, labels from a symbol table (their address), and
+// memory: [addr] is the byte at addr, read without side effects (see
+// memory.Memory.Peek). Operators and their precedence follow Go, so
+// & binds tighter than ==:
+//
+//	5  *  /  %  <<  >>  &
+//	4  +  -  |  ^
+//	3  ==  !=  <  <=  >  >=
+//	2  &&
+//	1  ||
+//
+// with unary -, ! and ~ (^ also works as Go's complement). Values are
+// signed integers; comparisons and logical operators give 1 or 0, and
+// division by zero gives 0 rather than an error mid-run.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+)
+
+// Expr is a compiled expression.
+type Expr struct {
+	src  string
+	eval node
+}
+
+// node evaluates one subexpression.
+type node func(n *nes.NES) int
+
+// Compile parses src, resolving labels through syms (which may be nil).
+func Compile(src string, syms *symbols.Table) (*Expr, error) {
+	p := &parser{src: src, syms: syms}
+	p.next()
+	e, err := p.parseBinary(1)
+	if err == nil && p.tok.kind != tokEOF {
+		err = p.errorf("unexpected %s", p.tok)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{src: strings.TrimSpace(src), eval: e}, nil
+}
+
+// Eval evaluates e against n.
+func (e *Expr) Eval(n *nes.NES) int { return e.eval(n) }
+
+// True reports whether e evaluates to non-zero.
+func (e *Expr) True(n *nes.NES) bool { return e.eval(n) != 0 }
+
+// String returns the expression as written.
+func (e *Expr) String() string { return e.src }
+
+// Format renders e's current value for a watch list or logpoint:
+// "[$0300] = 3 ($03)".
+func (e *Expr) Format(n *nes.NES) string {
+	v := e.eval(n)
+	if v >= 0 && v <= 0xFF {
+		return fmt.Sprintf("%s = %d ($%02X)", e.src, v, v)
+	}
+	if v >= 0 && v <= 0xFFFF {
+		return fmt.Sprintf("%s = %d ($%04X)", e.src, v, v)
+	}
+	return fmt.Sprintf("%s = %d", e.src, v)
+}
+
+// variables are the named operands other than labels.
+var variables = map[string]node{
+	"a":        func(n *nes.NES) int { return int(n.CPU.A) },
+	"x":        func(n *nes.NES) int { return int(n.CPU.X) },
+	"y":        func(n *nes.NES) int { return int(n.CPU.Y) },
+	"p":        func(n *nes.NES) int { return int(n.CPU.P) },
+	"sp":       func(n *nes.NES) int { return int(n.CPU.SP) },
+	"pc":       func(n *nes.NES) int { return int(n.CPU.PC) },
+	"frame":    func(n *nes.NES) int { return int(n.PPU.Frame) },
+	"scanline": func(n *nes.NES) int { return n.PPU.Scanline },
+	"dot":      func(n *nes.NES) int { return n.PPU.Cycle },
+}
+
+// binaryOps holds each binary operator's precedence and function.
+var binaryOps = map[string]struct {
+	prec int
+	fn   func(a, b int) int
+}{
+	"||": {1, func(a, b int) int { return bool2int(a != 0 || b != 0) }},
+	"&&": {2, func(a, b int) int { return bool2int(a != 0 && b != 0) }},
+	"==": {3, func(a, b int) int { return bool2int(a == b) }},
+	"!=": {3, func(a, b int) int { return bool2int(a != b) }},
+	"<":  {3, func(a, b int) int { return bool2int(a < b) }},
+	"<=": {3, func(a, b int) int { return bool2int(a <= b) }},
+	">":  {3, func(a, b int) int { return bool2int(a > b) }},
+	">=": {3, func(a, b int) int { return bool2int(a >= b) }},
+	"+":  {4, func(a, b int) int { return a + b }},
+	"-":  {4, func(a, b int) int { return a - b }},
+	"|":  {4, func(a, b int) int { return a | b }},
+	"^":  {4, func(a, b int) int { return a ^ b }},
+	"*":  {5, func(a, b int) int { return a * b }},
+	"/": {5, func(a, b int) int {
+		if b == 0 {
+			return 0
+		}
+		return a / b
+	}},
+	"%": {5, func(a, b int) int {
+		if b == 0 {
+			return 0
+		}
+		return a % b
+	}},
+	"<<": {5, func(a, b int) int { return a << uint(b&63) }},
+	">>": {5, func(a, b int) int { return a >> uint(b&63) }},
+	"&":  {5, func(a, b int) int { return a & b }},
+}
+
+func bool2int(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNum
+	tokName
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	num  int
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// parser is a precedence-climbing parser over a one-token lookahead.
+type parser struct {
+	src  string
+	syms *symbols.Table
+	pos  int
+	tok  token
+	err  error
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("expression %q at %d: %s", p.src, p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+// operators longest first, so "<=" isn't read as "<".
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "<<", ">>",
+	"<", ">", "+", "-", "|", "^", "*", "/", "%", "&", "!", "~", "(", ")", "[", "]"}
+
+// next reads the token at p.pos into p.tok. In operand position a % starts
+// a binary number; lexing it needs the previous token, so next decides by
+// whether the last token could end an operand.
+func (p *parser) next() {
+	prev := p.tok
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+	c := p.src[p.pos]
+	operandEnd := prev.kind == tokNum || prev.kind == tokName || prev.text == ")" || prev.text == "]"
+	switch {
+	case c == '$' || (c == '%' && !operandEnd) || isDigit(c):
+		base, digits := 10, ""
+		switch {
+		case c == '$':
+			base, p.pos = 16, p.pos+1
+		case c == '%':
+			base, p.pos = 2, p.pos+1
+		case c == '0' && p.pos+1 < len(p.src) && (p.src[p.pos+1] == 'x' || p.src[p.pos+1] == 'X'):
+			base, p.pos = 16, p.pos+2
+		}
+		for p.pos < len(p.src) && isAlnum(p.src[p.pos]) {
+			p.pos++
+		}
+		digits = p.src[start:p.pos]
+		digits = strings.TrimLeft(strings.TrimPrefix(strings.TrimPrefix(digits, "0x"), "0X"), "$%")
+		v, err := strconv.ParseInt(digits, base, 64)
+		if err != nil {
+			p.tok = token{kind: tokNum, text: p.src[start:p.pos], pos: start}
+			p.err = p.errorf("bad number %q", p.src[start:p.pos])
+			return
+		}
+		p.tok = token{kind: tokNum, text: p.src[start:p.pos], num: int(v), pos: start}
+	case isAlpha(c):
+		for p.pos < len(p.src) && (isAlnum(p.src[p.pos]) || p.src[p.pos] == '@' || p.src[p.pos] == ':') {
+			p.pos++
+		}
+		p.tok = token{kind: tokName, text: p.src[start:p.pos], pos: start}
+	default:
+		for _, op := range operators {
+			if strings.HasPrefix(p.src[p.pos:], op) {
+				p.pos += len(op)
+				p.tok = token{kind: tokOp, text: op, pos: start}
+				return
+			}
+		}
+		p.tok = token{kind: tokOp, text: string(c), pos: start}
+		p.err = p.errorf("unexpected %q", c)
+	}
+}
+
+// parseBinary parses operands joined by operators of precedence minPrec
+// or higher.
+func (p *parser) parseBinary(minPrec int) (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := binaryOps[p.tok.text]
+		if p.tok.kind != tokOp || !ok || op.prec < minPrec {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(op.prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		l, r, fn := left, right, op.fn
+		left = func(n *nes.NES) int { return fn(l(n), r(n)) }
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind == tokOp {
+		switch op := p.tok.text; op {
+		case "-", "!", "~", "^":
+			p.next()
+			x, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			switch op {
+			case "-":
+				return func(n *nes.NES) int { return -x(n) }, nil
+			case "!":
+				return func(n *nes.NES) int { return bool2int(x(n) == 0) }, nil
+			}
+			return func(n *nes.NES) int { return ^x(n) }, nil
+		}
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.tok
+	switch {
+	case t.kind == tokNum:
+		p.next()
+		v := t.num
+		return func(*nes.NES) int { return v }, p.err
+	case t.kind == tokName:
+		p.next()
+		if v, ok := variables[strings.ToLower(t.text)]; ok {
+			return v, p.err
+		}
+		if s, ok := p.syms.Find(t.text); ok {
+			addr := int(s.Address)
+			return func(*nes.NES) int { return addr }, p.err
+		}
+		return nil, fmt.Errorf("expression %q at %d: unknown name %q", p.src, t.pos+1, t.text)
+	case t.text == "(" || t.text == "[":
+		closing := map[string]string{"(": ")", "[": "]"}[t.text]
+		p.next()
+		x, err := p.parseBinary(1)
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.text != closing {
+			return nil, p.errorf("want %q, got %s", closing, p.tok)
+		}
+		p.next()
+		if t.text == "(" {
+			return x, p.err
+		}
+		return func(n *nes.NES) int { return int(n.Memory.Peek(uint16(x(n)))) }, p.err
+	}
+	return nil, p.errorf("want an operand, got %s", t)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+func isAlpha(c byte) bool { return c == '_' || c == '.' || (c|0x20 >= 'a' && c|0x20 <= 'z') }
+func isAlnum(c byte) bool { return isDigit(c) || isAlpha(c) }
@@ -0,0 +1,74 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+)
+
+func TestEval(t *testing.T) {
+	n := nes.NewNES()
+	n.CPU.A, n.CPU.X, n.CPU.Y, n.CPU.PC = 0x10, 0x05, 0x02, 0xC123
+	n.PPU.Scanline, n.PPU.Cycle = 241, 30
+	n.Memory.Write(0x0305, 0x23)
+	n.Memory.Write(0x0010, 0x80)
+	syms := symbols.NewTable([]symbols.Symbol{{Name: "PlayerX", Address: 0x0010, PRG: -1}})
+
+	for _, tc := range []struct {
+		src  string
+		want int
+	}{
+		{"[$0300+X] & $0F == 3", 1}, // & binds tighter than ==, as in Go
+		{"[$0300+x] & $0F", 3},
+		{"a + x * 2", 0x1A},
+		{"(a + x) * 2", 0x2A},
+		{"0x10 | %0001", 0x11},
+		{"17 % 5", 2},
+		{"1 << 4 >> 2", 4},
+		{"[PlayerX] == $80 && scanline >= 240", 1},
+		{"pc == $C123 || 1/0", 1},
+		{"7 / 0", 0},
+		{"-y + 1", -1},
+		{"!0 + !5", 1},
+		{"~0", -1},
+		{"dot > 29 && dot < 31", 1},
+		{"[[$0010] - $7B + $0300]", 0x23},
+	} {
+		e, err := Compile(tc.src, syms)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tc.src, err)
+			continue
+		}
+		if got := e.Eval(n); got != tc.want {
+			t.Errorf("%q = %d, want %d", tc.src, got, tc.want)
+		}
+	}
+
+	e, _ := Compile(" [PlayerX] ", syms)
+	if got := e.Format(n); got != "[PlayerX] = 128 ($80)" {
+		t.Errorf("Format = %q", got)
+	}
+	if !e.True(n) {
+		t.Error("True([PlayerX]) = false")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, tc := range []struct{ src, want string }{
+		{"", "want an operand"},
+		{"a +", "want an operand"},
+		{"[$0300", `want "]"`},
+		{"(a", `want ")"`},
+		{"a b", `unexpected "b"`},
+		{"$xyz", "bad number"},
+		{"Missing + 1", `unknown name "Missing"`},
+		{"a # 1", "unexpected '#'"},
+	} {
+		_, err := Compile(tc.src, nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Compile(%q) error = %v, want %q", tc.src, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package gdb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/asm6502"
+	"github.com/yoshiomiyamaegones/pkg/cpu"
+	"github.com/yoshiomiyamaegones/pkg/expr"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+)
+
+// monitorHelp lists the stub's own monitor commands.
+const monitorHelp = `break                list conditional breakpoints and logpoints
+break ADDR if EXPR   stop at ADDR when EXPR is non-zero
+log ADDR EXPR[, ...] print EXPRs each time ADDR runs, without stopping
+clear ADDR           remove the condition and logpoint at ADDR
+watch [EXPR]         add a watch shown at every stop, or list them
+unwatch [N]          remove watch N, or all of them
+print EXPR           evaluate EXPR once
+back [N]             step back N instructions (for clients without reverse-stepi)
+asm ADDR INSN[; ...] assemble instructions into memory at ADDR
+`
+
+// monitor runs one of the stub's own monitor commands, with lock held.
+// ok is false when cmd isn't one, so the frontend's Stub.Monitor gets it.
+//
+// Addresses are expressions too, evaluated once, so "break Reset if A == 0"
+// uses the label. Expressions are described in package expr.
+func (s *Stub) monitor(cmd string) (out string, ok bool) {
+	verb, arg, _ := strings.Cut(strings.TrimSpace(cmd), " ")
+	arg = strings.TrimSpace(arg)
+	switch verb {
+	case "break":
+		if arg == "" {
+			return s.breakList(), true
+		}
+		a, cond, found := strings.Cut(arg, " if ")
+		if !found {
+			return "usage: break ADDR if EXPR (plain breakpoints are gdb's own)\n", true
+		}
+		addr, err := s.address(a)
+		if err != nil {
+			return err.Error() + "\n", true
+		}
+		e, err := expr.Compile(cond, s.Symbols)
+		if err != nil {
+			return err.Error() + "\n", true
+		}
+		s.conditions[addr] = e
+		return fmt.Sprintf("break at $%04X if %s\n", addr, e), true
+	case "log":
+		a, list, _ := strings.Cut(arg, " ")
+		addr, err := s.address(a)
+		if err != nil {
+			return err.Error() + "\n", true
+		}
+		var logs []*expr.Expr
+		for _, src := range strings.Split(list, ",") {
+			e, err := expr.Compile(src, s.Symbols)
+			if err != nil {
+				return err.Error() + "\n", true
+			}
+			logs = append(logs, e)
+		}
+		s.logpoints[addr] = append(s.logpoints[addr], logs...)
+		return fmt.Sprintf("logpoint at $%04X\n", addr), true
+	case "clear":
+		addr, err := s.address(arg)
+		if err != nil {
+			return err.Error() + "\n", true
+		}
+		delete(s.conditions, addr)
+		delete(s.logpoints, addr)
+		return "", true
+	case "watch":
+		if arg == "" {
+			if len(s.watches) == 0 {
+				return "no watches\n", true
+			}
+			return s.watchReport(), true
+		}
+		e, err := expr.Compile(arg, s.Symbols)
+		if err != nil {
+			return err.Error() + "\n", true
+		}
+		s.watches = append(s.watches, e)
+		return fmt.Sprintf("watch %d: %s\n", len(s.watches), e.Format(s.nes)), true
+	case "unwatch":
+		if arg == "" {
+			s.watches = nil
+			return "", true
+		}
+		i, err := strconv.Atoi(arg)
+		if err != nil || i < 1 || i > len(s.watches) {
+			return fmt.Sprintf("no watch %q\n", arg), true
+		}
+		s.watches = append(s.watches[:i-1], s.watches[i:]...)
+		return "", true
+	case "print":
+		e, err := expr.Compile(arg, s.Symbols)
+		if err != nil {
+			return err.Error() + "\n", true
+		}
+		return e.Format(s.nes) + "\n", true
+	case "back":
+		if s.Undo == nil {
+			return "no history (start with -gdb-undo N)\n", true
+		}
+		count := 1
+		if arg != "" {
+			c, err := strconv.Atoi(arg)
+			if err != nil || c < 1 {
+				return fmt.Sprintf("bad count %q\n", arg), true
+			}
+			count = c
+		}
+		done := 0
+		for done < count && s.Undo.Back() {
+			done++
+		}
+		return fmt.Sprintf("back %d to $%04X, %d more in history\n", done, s.nes.CPU.PC, s.Undo.Len()), true
+	case "asm":
+		a, src, _ := strings.Cut(arg, " ")
+		addr, err := s.address(a)
+		if err != nil {
+			return err.Error() + "\n", true
+		}
+		return s.assemble(addr, strings.ReplaceAll(src, ";", "\n")), true
+	case "help":
+		out := monitorHelp
+		if s.Monitor != nil {
+			out += s.Monitor(cmd)
+		}
+		return out, true
+	}
+	return "", false
+}
+
+// address evaluates a monitor command's address argument.
+func (s *Stub) address(src string) (uint16, error) {
+	e, err := expr.Compile(src, s.Symbols)
+	if err != nil {
+		return 0, err
+	}
+	v := e.Eval(s.nes)
+	if v < 0 || v > 0xFFFF {
+		return 0, fmt.Errorf("address %s = %d is out of range", e, v)
+	}
+	return uint16(v), nil
+}
+
+// assemble writes src, assembled at addr, through the CPU bus as an M
+// packet would, and lists what it wrote.
+func (s *Stub) assemble(addr uint16, src string) string {
+	p, err := asm6502.Assemble(src, addr, s.Symbols)
+	if err != nil {
+		return err.Error() + "\n"
+	}
+	var b strings.Builder
+	for _, seg := range p.Segments {
+		for i, v := range seg.Code {
+			s.nes.Memory.Write(seg.Addr+uint16(i), v)
+		}
+		for i := 0; i < len(seg.Code); {
+			var insn [3]uint8
+			copy(insn[:], seg.Code[i:])
+			pc := seg.Addr + uint16(i)
+			fmt.Fprintf(&b, "$%04X  %s\n", pc, cpu.Disassemble(pc, insn))
+			i += cpu.InstructionLength(insn[0])
+		}
+	}
+	return b.String()
+}
+
+// breakList renders the conditions and logpoints by address.
+func (s *Stub) breakList() string {
+	var b strings.Builder
+	for _, addr := range sortedAddrs(s.conditions) {
+		fmt.Fprintf(&b, "$%04X  break if %s\n", addr, s.conditions[addr])
+	}
+	for _, addr := range sortedAddrs(s.logpoints) {
+		srcs := make([]string, len(s.logpoints[addr]))
+		for i, e := range s.logpoints[addr] {
+			srcs[i] = e.String()
+		}
+		fmt.Fprintf(&b, "$%04X  log %s\n", addr, strings.Join(srcs, ", "))
+	}
+	if b.Len() == 0 {
+		return "no conditional breakpoints or logpoints\n"
+	}
+	return b.String()
+}
+
+// watchReport renders the watches, one per line, or "" when there are
+// none. Called with lock held.
+func (s *Stub) watchReport() string {
+	var b strings.Builder
+	for i, e := range s.watches {
+		fmt.Fprintf(&b, "%d: %s\n", i+1, e.Format(s.nes))
+	}
+	return b.String()
+}
+
+// logpoint prints the logpoint at pc to the log and, when a client is
+// waiting on the target, to its console. Called from checkBreak.
+func (s *Stub) logpoint(pc uint16, logs []*expr.Expr) {
+	parts := make([]string, len(logs))
+	for i, e := range logs {
+		parts[i] = e.Format(s.nes)
+	}
+	line := fmt.Sprintf("$%04X: %s", pc, strings.Join(parts, ", "))
+	logger.LogInfo("GDB: %s", line)
+	select {
+	case s.console <- line + "\n":
+	default:
+	}
+}
+
+// sortedAddrs returns m's keys in order, for a stable listing.
+func sortedAddrs[V any](m map[uint16]V) []uint16 {
+	addrs := make([]uint16, 0, len(m))
+	for a := range m {
+		addrs = append(addrs, a)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	return addrs
+}
@@ -0,0 +1,82 @@
+package gdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// interruptByte is what a client sends, outside any packet, to stop a
+// running target (Ctrl+C in gdb).
+const interruptByte = 0x03
+
+// event is one thing read from the client: a packet, or an interrupt.
+type event struct {
+	packet    string
+	badSum    bool // checksum mismatch; the client should resend
+	interrupt bool
+}
+
+// readEvents parses the client's byte stream into events until it ends,
+// then closes events. Acknowledgements ('+', '-') from the client are
+// dropped — a reliable transport never needs a resend.
+func readEvents(r io.Reader, events chan<- event) {
+	defer close(events)
+	br := bufio.NewReader(r)
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return
+		}
+		switch c {
+		case interruptByte:
+			events <- event{interrupt: true}
+		case '$':
+			data, err := br.ReadString('#')
+			if err != nil {
+				return
+			}
+			var sum [2]byte
+			if _, err := io.ReadFull(br, sum[:]); err != nil {
+				return
+			}
+			data = data[:len(data)-1]
+			events <- event{packet: unescape(data), badSum: fmt.Sprintf("%02x", checksum(data)) != string(sum[:])}
+		}
+	}
+}
+
+// unescape undoes the protocol's escaping of '#', '$', '}' and '*' as '}'
+// followed by the byte XOR 0x20.
+func unescape(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '}' {
+			out := []byte(s[:i])
+			for ; i < len(s); i++ {
+				if s[i] == '}' && i+1 < len(s) {
+					i++
+					out = append(out, s[i]^0x20)
+				} else {
+					out = append(out, s[i])
+				}
+			}
+			return string(out)
+		}
+	}
+	return s
+}
+
+func checksum(s string) uint8 {
+	var sum uint8
+	for i := 0; i < len(s); i++ {
+		sum += s[i]
+	}
+	return sum
+}
+
+// writePacket frames data as $data#cs. Replies never contain bytes that
+// need escaping: they are hex, plain text or target.xml.
+func writePacket(w io.Writer, data string) error {
+	_, err := fmt.Fprintf(w, "$%s#%02x", data, checksum(data))
+	return err
+}
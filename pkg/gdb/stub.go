@@ -0,0 +1,559 @@
+// Package gdb is a GDB remote serial protocol stub for the 6502, so a
+// debugger that speaks the protocol — a cc65 toolchain frontend, an IDA or
+// Ghidra plugin, or gdb itself with a custom target description — can
+// attach to a running game, inspect and change registers and memory, set
+// breakpoints, and continue or single-step.
+//
+// Registers travel in this order, each as little-endian hex (target.xml
+// describes them to clients that ask): A, X, Y, P, SP (8 bits each) and
+// PC (16 bits). Memory reads go through memory.Memory.Peek, so a debugger
+// looking at the I/O registers ($2000-$5FFF, read as 0) can't acknowledge
+// an interrupt or clear VBlank behind the game's back; writes go through
+// the CPU bus like an STA. Breakpoints (Z0/Z1) are PC matches checked
+// before every instruction — nothing is patched into memory — and only
+// one client is served at a time. With a Stub.Undo journal the client can
+// also step and continue backwards (bs/bc; gdb's reverse-stepi and
+// reverse-continue). "monitor" commands (qRcmd) add what
+// the protocol lacks — conditional breakpoints, logpoints and watches over
+// package expr expressions (see monitor.go) — and pass anything else to
+// Stub.Monitor, where the frontend offers extras such as dumping the
+// instruction trace.
+package gdb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yoshiomiyamaegones/pkg/expr"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+	"github.com/yoshiomiyamaegones/pkg/undo"
+)
+
+// Stop replies: the target stopped with SIGTRAP (breakpoint or step),
+// SIGINT (interrupted by the client) or SIGILL (a step ran into a JAM
+// opcode), or stepping backwards ran out of history.
+const (
+	stopTrap         = "S05"
+	stopInt          = "S02"
+	stopIllegal      = "S04"
+	stopHistoryStart = "T05replaylog:begin;" // bs/bc reached the journal's start
+)
+
+// maxPacket is the PacketSize advertised to clients, in bytes of packet
+// data; an m reply holds half as many memory bytes.
+const maxPacket = 0x1000
+
+// targetXML describes the register layout of the g packet.
+const targetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.gones.m6502">
+    <reg name="a" bitsize="8" type="uint8" regnum="0"/>
+    <reg name="x" bitsize="8" type="uint8"/>
+    <reg name="y" bitsize="8" type="uint8"/>
+    <reg name="p" bitsize="8" type="uint8"/>
+    <reg name="sp" bitsize="8" type="data_ptr"/>
+    <reg name="pc" bitsize="16" type="code_ptr"/>
+  </feature>
+</target>
+`
+
+// numRegs is the register count of targetXML.
+const numRegs = 6
+
+// Stub debugs one NES. The frontend keeps stepping frames as usual, with
+// lock held around each StepFrame, and stops stepping while Halted; the
+// stub takes lock whenever it touches the machine, so it only ever sees
+// it between instructions the frontend isn't running.
+type Stub struct {
+	nes  *nes.NES
+	lock sync.Locker
+
+	halted atomic.Bool
+	// stopped carries the stop reply from a breakpoint hit on the
+	// frontend's goroutine to the client's.
+	stopped chan string
+	// console carries logpoint output to the client while it waits for
+	// a stop; lines that don't fit are dropped.
+	console chan string
+
+	// Guarded by lock. conditions and logpoints come from monitor
+	// commands; breakpoints from Z packets.
+	breakpoints map[uint16]bool
+	conditions  map[uint16]*expr.Expr
+	logpoints   map[uint16][]*expr.Expr
+	watches     []*expr.Expr
+	skipBreak   bool // resuming: don't stop at a breakpoint on the current PC
+
+	// OnHalt, if set, is called with lock held whenever the target stops
+	// or resumes, so the frontend can park its frame loop (or wake it).
+	OnHalt func(halted bool)
+
+	// Monitor, if set, runs the frontend's own commands — gdb's "monitor
+	// trace" — with lock held, returning the text to show the user.
+	Monitor func(cmd string) string
+
+	// Symbols, if set, resolves labels in monitor expressions. Set it
+	// with lock held.
+	Symbols *symbols.Table
+
+	// Undo, if set, journals every instruction the target runs so the
+	// client can step back through them. Set it before serving.
+	Undo *undo.Journal
+}
+
+// New returns a stub for n, whose frames the frontend steps with lock
+// held. It takes over n.Break.
+func New(n *nes.NES, lock sync.Locker) *Stub {
+	s := &Stub{
+		nes:         n,
+		lock:        lock,
+		stopped:     make(chan string, 1),
+		console:     make(chan string, 64),
+		breakpoints: make(map[uint16]bool),
+		conditions:  make(map[uint16]*expr.Expr),
+		logpoints:   make(map[uint16][]*expr.Expr),
+	}
+	n.Break = s.checkBreak
+	return s
+}
+
+// Halted reports whether a debugger has the target stopped. The frontend
+// must not step frames while it does.
+func (s *Stub) Halted() bool { return s.halted.Load() }
+
+// setHalted records a stop or resume and tells the frontend. Called with
+// lock held.
+func (s *Stub) setHalted(h bool) {
+	if s.halted.Swap(h) == h {
+		return
+	}
+	if s.OnHalt != nil {
+		s.OnHalt(h)
+	}
+}
+
+// checkBreak is n.Break: it runs before every instruction, with lock held
+// by the frontend.
+func (s *Stub) checkBreak() bool {
+	if s.skipBreak {
+		s.skipBreak = false
+		if s.Undo != nil {
+			s.Undo.Record()
+		}
+		return false
+	}
+	pc := s.nes.CPU.PC
+	if logs := s.logpoints[pc]; logs != nil {
+		s.logpoint(pc, logs)
+	}
+	hit := s.breakpoints[pc]
+	if cond := s.conditions[pc]; cond != nil && cond.True(s.nes) {
+		hit = true
+	}
+	if !hit {
+		if s.Undo != nil {
+			s.Undo.Record()
+		}
+		return false
+	}
+	s.setHalted(true)
+	select {
+	case s.stopped <- stopTrap:
+	default:
+	}
+	return true
+}
+
+// ListenAndServe serves clients on the TCP address addr, one at a time.
+func (s *Stub) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts clients on ln and serves each in turn until ln is closed.
+func (s *Stub) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		logger.LogInfo("GDB: %s attached", conn.RemoteAddr())
+		if err := s.ServeConn(conn); err != nil {
+			logger.LogError("GDB: %v", err)
+		}
+		conn.Close()
+		logger.LogInfo("GDB: %s detached", conn.RemoteAddr())
+	}
+}
+
+// ServeConn runs one debugging session over rw: the target is stopped on
+// attach and resumed, with its breakpoints cleared, when the client
+// detaches or the connection ends.
+func (s *Stub) ServeConn(rw io.ReadWriter) error {
+	s.lock.Lock()
+	s.setHalted(true)
+	s.lock.Unlock()
+	defer s.detach()
+
+	events := make(chan event)
+	go readEvents(rw, events)
+	sess := &session{s: s, w: rw}
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			done, err := sess.handle(ev)
+			if err != nil || done {
+				return err
+			}
+		case reply := <-s.stopped:
+			if sess.running {
+				sess.running = false
+				s.lock.Lock()
+				watches := s.watchReport()
+				s.lock.Unlock()
+				if err := sess.sendStop(reply, watches); err != nil {
+					return err
+				}
+			}
+		case line := <-s.console:
+			if sess.running {
+				if err := sess.sendOutput(line); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (s *Stub) detach() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.breakpoints = make(map[uint16]bool)
+	s.conditions = make(map[uint16]*expr.Expr)
+	s.logpoints = make(map[uint16][]*expr.Expr)
+	s.watches = nil
+	s.skipBreak = false
+	s.setHalted(false)
+	for len(s.console) > 0 {
+		<-s.console
+	}
+}
+
+// session is one client's protocol state.
+type session struct {
+	s       *Stub
+	w       io.Writer
+	noAck   bool // QStartNoAckMode
+	running bool // continued; a stop reply is owed
+}
+
+func (c *session) send(data string) error { return writePacket(c.w, data) }
+
+// sendOutput sends text for the client to print on its console.
+func (c *session) sendOutput(text string) error {
+	return c.send("O" + hex.EncodeToString([]byte(text)))
+}
+
+// sendStop sends a stop reply. Logpoint lines still queued go first, since
+// they were printed before the stop, then the watch values, so the client
+// shows them at every stop.
+func (c *session) sendStop(reply, watches string) error {
+	for len(c.s.console) > 0 {
+		if err := c.sendOutput(<-c.s.console); err != nil {
+			return err
+		}
+	}
+	if watches != "" {
+		if err := c.sendOutput(watches); err != nil {
+			return err
+		}
+	}
+	return c.send(reply)
+}
+
+// handle answers one event, reporting whether the session is over.
+func (c *session) handle(ev event) (bool, error) {
+	if ev.interrupt {
+		return false, c.interrupt()
+	}
+	if !c.noAck {
+		ack := "+"
+		if ev.badSum {
+			ack = "-"
+		}
+		if _, err := io.WriteString(c.w, ack); err != nil {
+			return true, err
+		}
+		if ev.badSum {
+			return false, nil
+		}
+	}
+	switch ev.packet {
+	case "D":
+		return true, c.send("OK")
+	case "k":
+		return true, nil
+	case "QStartNoAckMode":
+		err := c.send("OK")
+		c.noAck = true
+		return false, err
+	}
+
+	c.s.lock.Lock()
+	reply, resumed := c.s.command(ev.packet)
+	var watches string
+	if (ev.packet[0] == 's' || ev.packet[0] == 'b') && (reply == stopTrap || reply == stopHistoryStart) {
+		watches = c.s.watchReport()
+	}
+	c.s.lock.Unlock()
+	if resumed {
+		c.running = true
+		return false, nil
+	}
+	return false, c.sendStop(reply, watches)
+}
+
+// interrupt stops a running target where the frontend's current frame
+// ends — taking lock waits for it — and owes the client a SIGINT stop.
+func (c *session) interrupt() error {
+	if !c.running {
+		return nil
+	}
+	c.s.lock.Lock()
+	wasRunning := !c.s.halted.Load()
+	c.s.setHalted(true)
+	watches := c.s.watchReport()
+	c.s.lock.Unlock()
+	if !wasRunning {
+		return nil // a breakpoint got there first; its reply is queued
+	}
+	c.running = false
+	return c.sendStop(stopInt, watches)
+}
+
+// command executes one packet with lock held. It returns the reply, or
+// resumed = true when the target was continued and the reply will be a
+// later stop.
+func (s *Stub) command(p string) (reply string, resumed bool) {
+	if p == "" {
+		return "", false
+	}
+	cpu := s.nes.CPU
+	switch args := p[1:]; p[0] {
+	case '?':
+		return stopTrap, false
+	case 'g':
+		return fmt.Sprintf("%02x%02x%02x%02x%02x%02x%02x", cpu.A, cpu.X, cpu.Y, cpu.P, cpu.SP, uint8(cpu.PC), cpu.PC>>8), false
+	case 'G':
+		b, err := hex.DecodeString(args)
+		if err != nil || len(b) != 7 {
+			return "E01", false
+		}
+		cpu.A, cpu.X, cpu.Y, cpu.P, cpu.SP = b[0], b[1], b[2], b[3], b[4]
+		cpu.PC = uint16(b[5]) | uint16(b[6])<<8
+		return "OK", false
+	case 'p':
+		n, err := strconv.ParseUint(args, 16, 8)
+		if err != nil || n >= numRegs {
+			return "E01", false
+		}
+		if n == numRegs-1 {
+			return fmt.Sprintf("%02x%02x", uint8(cpu.PC), cpu.PC>>8), false
+		}
+		return fmt.Sprintf("%02x", *s.reg8(int(n))), false
+	case 'P':
+		num, val, ok := strings.Cut(args, "=")
+		n, err := strconv.ParseUint(num, 16, 8)
+		b, herr := hex.DecodeString(val)
+		if !ok || err != nil || herr != nil || n >= numRegs {
+			return "E01", false
+		}
+		switch {
+		case n == numRegs-1 && len(b) == 2:
+			cpu.PC = uint16(b[0]) | uint16(b[1])<<8
+		case n < numRegs-1 && len(b) == 1:
+			*s.reg8(int(n)) = b[0]
+		default:
+			return "E01", false
+		}
+		return "OK", false
+	case 'm':
+		addr, n, ok := parseAddrLen(args)
+		if !ok || n > maxPacket/2 {
+			return "E01", false
+		}
+		buf := make([]byte, n)
+		for i := range buf {
+			buf[i] = s.nes.Memory.Peek(addr + uint16(i))
+		}
+		return hex.EncodeToString(buf), false
+	case 'M':
+		spec, data, _ := strings.Cut(args, ":")
+		addr, n, ok := parseAddrLen(spec)
+		b, err := hex.DecodeString(data)
+		if !ok || err != nil || len(b) != n {
+			return "E01", false
+		}
+		for i, v := range b {
+			s.nes.Memory.Write(addr+uint16(i), v)
+		}
+		return "OK", false
+	case 'Z', 'z':
+		kind, rest, _ := strings.Cut(args, ",")
+		if kind != "0" && kind != "1" {
+			return "", false // watchpoints aren't supported
+		}
+		addr, _, ok := parseAddrLen(rest)
+		if !ok {
+			return "E01", false
+		}
+		if p[0] == 'Z' {
+			s.breakpoints[addr] = true
+		} else {
+			delete(s.breakpoints, addr)
+		}
+		return "OK", false
+	case 'c':
+		if !s.setPC(args) {
+			return "E01", false
+		}
+		// Drop a stop left over from a breakpoint the client never saw.
+		select {
+		case <-s.stopped:
+		default:
+		}
+		s.skipBreak = true
+		s.setHalted(false)
+		return "", true
+	case 's':
+		if !s.setPC(args) {
+			return "E01", false
+		}
+		if s.Undo != nil {
+			s.Undo.Record()
+		}
+		if err := s.nes.Step(); err != nil {
+			logger.LogError("GDB: %v", err)
+			return stopIllegal, false
+		}
+		return stopTrap, false
+	case 'b':
+		if s.Undo == nil || (args != "s" && args != "c") {
+			return "", false
+		}
+		return s.reverse(args == "c"), false
+	case 'H', 'T':
+		return "OK", false // one thread
+	case 'q':
+		return s.query(args), false
+	}
+	return "", false
+}
+
+// reverse steps back one instruction, or with cont back until a
+// breakpoint or condition matches, and returns the stop reply.
+func (s *Stub) reverse(cont bool) string {
+	for {
+		if !s.Undo.Back() {
+			return stopHistoryStart
+		}
+		if !cont {
+			return stopTrap
+		}
+		pc := s.nes.CPU.PC
+		if cond := s.conditions[pc]; s.breakpoints[pc] || (cond != nil && cond.True(s.nes)) {
+			return stopTrap
+		}
+	}
+}
+
+// reg8 returns the 8-bit register numbered n in targetXML.
+func (s *Stub) reg8(n int) *uint8 {
+	cpu := s.nes.CPU
+	return [...]*uint8{&cpu.A, &cpu.X, &cpu.Y, &cpu.P, &cpu.SP}[n]
+}
+
+// setPC handles the optional resume address of c and s.
+func (s *Stub) setPC(arg string) bool {
+	if arg == "" {
+		return true
+	}
+	pc, err := strconv.ParseUint(arg, 16, 16)
+	if err != nil {
+		return false
+	}
+	s.nes.CPU.PC = uint16(pc)
+	return true
+}
+
+func (s *Stub) query(q string) string {
+	switch {
+	case strings.HasPrefix(q, "Supported"):
+		reply := fmt.Sprintf("PacketSize=%x;qXfer:features:read+;QStartNoAckMode+", maxPacket)
+		if s.Undo != nil {
+			reply += ";ReverseStep+;ReverseContinue+"
+		}
+		return reply
+	case q == "Attached":
+		return "1"
+	case q == "C":
+		return "QC1"
+	case q == "fThreadInfo":
+		return "m1"
+	case q == "sThreadInfo":
+		return "l"
+	case strings.HasPrefix(q, "Rcmd,"):
+		cmd, err := hex.DecodeString(strings.TrimPrefix(q, "Rcmd,"))
+		if err != nil {
+			return "E01"
+		}
+		out, ok := s.monitor(string(cmd))
+		if !ok {
+			if s.Monitor == nil {
+				return ""
+			}
+			out = s.Monitor(string(cmd))
+		}
+		if out == "" {
+			return "OK"
+		}
+		return hex.EncodeToString([]byte(out))
+	case strings.HasPrefix(q, "Xfer:features:read:target.xml:"):
+		off, n, ok := parseAddrLen(strings.TrimPrefix(q, "Xfer:features:read:target.xml:"))
+		if !ok || int(off) > len(targetXML) {
+			return "E01"
+		}
+		chunk := targetXML[off:]
+		if len(chunk) > n {
+			return "m" + chunk[:n]
+		}
+		return "l" + chunk
+	}
+	return ""
+}
+
+// parseAddrLen parses the "addr,length" argument of m, M, Z and qXfer.
+func parseAddrLen(s string) (uint16, int, bool) {
+	a, l, ok := strings.Cut(s, ",")
+	addr, err1 := strconv.ParseUint(a, 16, 16)
+	n, err2 := strconv.ParseUint(l, 16, 16)
+	if !ok || err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return uint16(addr), int(n), true
+}
@@ -0,0 +1,411 @@
+package gdb
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
+	"github.com/yoshiomiyamaegones/pkg/undo"
+)
+
+// testNES is a powered-on NES running a NROM image of NOPs. Running off
+// the end hits the NMI vector's $00, a BRK, whose vector leads back into
+// the NOPs past $8010, where the tests put their breakpoint.
+func testNES(t *testing.T) *nes.NES {
+	t.Helper()
+	n, err := testrom.New().FillPRG(func(int) byte { return 0xEA }).IRQ(0x9000).NES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// client is the debugger end of a session.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// call sends packet and returns the reply's data, skipping acks.
+func (c *client) call(packet string) string {
+	c.t.Helper()
+	c.send(packet)
+	return c.reply()
+}
+
+func (c *client) send(packet string) {
+	fmt.Fprintf(c.conn, "$%s#%02x", packet, checksum(packet))
+}
+
+func (c *client) reply() string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			c.t.Fatalf("read reply: %v", err)
+		}
+		if b != '$' {
+			continue // ack
+		}
+		data, err := c.r.ReadString('#')
+		if err != nil {
+			c.t.Fatalf("read reply: %v", err)
+		}
+		sum := make([]byte, 2)
+		if _, err := c.r.Read(sum); err != nil {
+			c.t.Fatal(err)
+		}
+		data = data[:len(data)-1]
+		if want := fmt.Sprintf("%02x", checksum(data)); string(sum) != want {
+			c.t.Errorf("reply %q checksum %s, want %s", data, sum, want)
+		}
+		return data
+	}
+}
+
+func TestStubSession(t *testing.T) {
+	n := testNES(t)
+	var mu sync.Mutex
+	stub := New(n, &mu)
+	var halts []bool
+	stub.OnHalt = func(h bool) { halts = append(halts, h) }
+	stub.Monitor = func(cmd string) string {
+		if cmd == "quiet" {
+			return ""
+		}
+		return "ran " + cmd + "\n"
+	}
+
+	// The frontend: step frames unless the debugger holds the target.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			mu.Lock()
+			if !stub.Halted() {
+				n.StepFrame()
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	srv, conn := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- stub.ServeConn(srv) }()
+	c := &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	if got := c.call("?"); got != "S05" {
+		t.Errorf("? = %q", got)
+	}
+	if !stub.Halted() {
+		t.Fatal("attaching should stop the target")
+	}
+	if got := c.call("qSupported:multiprocess+"); !strings.Contains(got, "PacketSize=1000") || !strings.Contains(got, "qXfer:features:read+") {
+		t.Errorf("qSupported = %q", got)
+	}
+	if got := c.call("qXfer:features:read:target.xml:0,fff"); !strings.HasPrefix(got, "l<?xml") || !strings.Contains(got, `name="pc" bitsize="16"`) {
+		t.Errorf("target.xml = %q", got)
+	}
+	if got := c.call("qXfer:features:read:target.xml:0,10"); got != "m"+targetXML[:16] {
+		t.Errorf("partial target.xml = %q", got)
+	}
+
+	// monitor commands: hex both ways, OK when there's nothing to print.
+	if got := c.call("qRcmd," + hex.EncodeToString([]byte("trace"))); got != hex.EncodeToString([]byte("ran trace\n")) {
+		t.Errorf("monitor trace = %q", got)
+	}
+	if got := c.call("qRcmd," + hex.EncodeToString([]byte("quiet"))); got != "OK" {
+		t.Errorf("monitor quiet = %q, want OK", got)
+	}
+
+	// Registers: A X Y P SP PC(lo hi).
+	if got := c.call("G12345624fd0080"); got != "OK" {
+		t.Fatalf("G = %q", got)
+	}
+	if got := c.call("g"); got != "12345624fd0080" {
+		t.Errorf("g = %q", got)
+	}
+	if got := c.call("p1"); got != "34" {
+		t.Errorf("p1 = %q", got)
+	}
+	if got := c.call("P2=99"); got != "OK" || n.CPU.Y != 0x99 {
+		t.Errorf("P2=99 = %q, Y=%02X", got, n.CPU.Y)
+	}
+	if got := c.call("P9=00"); got != "E01" {
+		t.Errorf("P9 = %q, want E01", got)
+	}
+
+	// Memory.
+	if got := c.call("M10,3:abcdef"); got != "OK" {
+		t.Errorf("M = %q", got)
+	}
+	if got := c.call("m10,3"); got != "abcdef" {
+		t.Errorf("m = %q", got)
+	}
+	if got := c.call("m2002,1"); got != "00" {
+		t.Errorf("m2002 = %q, want the side-effect-free 00", got)
+	}
+
+	// Breakpoint, continue, step.
+	if got := c.call("Z0,8010,1"); got != "OK" {
+		t.Errorf("Z0 = %q", got)
+	}
+	if got := c.call("c"); got != "S05" {
+		t.Errorf("c = %q, want a breakpoint stop", got)
+	}
+	if got := c.call("p5"); got != "1080" {
+		t.Errorf("stopped at PC %q, want $8010", got)
+	}
+	if got := c.call("s"); got != "S05" || n.CPU.PC != 0x8011 {
+		t.Errorf("s = %q, PC=%04X", got, n.CPU.PC)
+	}
+	if got := c.call("Z2,10,1"); got != "" {
+		t.Errorf("watchpoint = %q, want unsupported", got)
+	}
+
+	// Continue from the breakpoint itself runs past it, until interrupted.
+	c.send("c8010")
+	if b, err := c.r.ReadByte(); err != nil || b != '+' {
+		t.Fatalf("c ack = %q, %v", b, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if stub.Halted() {
+		t.Error("continuing from a breakpoint should not stop on it again at once")
+	}
+	c.conn.Write([]byte{interruptByte})
+	if got := c.reply(); got != "S02" {
+		t.Errorf("interrupt = %q", got)
+	}
+
+	if got := c.call("D"); got != "OK" {
+		t.Errorf("D = %q", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("ServeConn: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if stub.Halted() || len(stub.breakpoints) != 0 {
+		t.Error("detaching should resume the target and clear breakpoints")
+	}
+	if want := "[true false true false true false]"; fmt.Sprint(halts) != want {
+		t.Errorf("OnHalt calls %v, want %s", halts, want)
+	}
+}
+
+// monitorCall runs a monitor command and returns its decoded output.
+func (c *client) monitorCall(cmd string) string {
+	c.t.Helper()
+	got := c.call("qRcmd," + hex.EncodeToString([]byte(cmd)))
+	if got == "OK" {
+		return ""
+	}
+	out, err := hex.DecodeString(got)
+	if err != nil {
+		c.t.Fatalf("monitor %s = %q", cmd, got)
+	}
+	return string(out)
+}
+
+// output reads a console output packet and returns its text.
+func (c *client) output() string {
+	c.t.Helper()
+	got := c.reply()
+	out, err := hex.DecodeString(strings.TrimPrefix(got, "O"))
+	if !strings.HasPrefix(got, "O") || err != nil {
+		c.t.Fatalf("got %q, want console output", got)
+	}
+	return string(out)
+}
+
+func TestStubMonitorExpressions(t *testing.T) {
+	n := testNES(t)
+	var mu sync.Mutex
+	stub := New(n, &mu)
+	stub.Symbols = symbols.NewTable([]symbols.Symbol{{Name: "Counter", Address: 0x0010, PRG: -1}})
+	stub.Monitor = func(cmd string) string { return "frontend\n" }
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			mu.Lock()
+			if !stub.Halted() {
+				n.StepFrame()
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	srv, conn := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- stub.ServeConn(srv) }()
+	c := &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+	c.call("?")
+
+	if got := c.call("P5=0080"); got != "OK" {
+		t.Fatalf("P5 = %q", got)
+	}
+	if got := c.call("M10,1:2a"); got != "OK" {
+		t.Fatalf("M = %q", got)
+	}
+	for cmd, want := range map[string]string{
+		"watch [Counter]":             "watch 1: [Counter] = 42 ($2A)\n",
+		"log $8004 pc, [Counter]":     "logpoint at $8004\n",
+		"break $8006 if X == 1":       "break at $8006 if X == 1\n",
+		"break $8000+8 if X == 0":     "break at $8008 if X == 0\n",
+		"print Counter + 2 * 3":       "Counter + 2 * 3 = 22 ($16)\n",
+		"print [Counter] & $0F == 10": "[Counter] & $0F == 10 = 1 ($01)\n",
+		"other":                       "frontend\n",
+	} {
+		if got := c.monitorCall(cmd); got != want {
+			t.Errorf("monitor %s = %q, want %q", cmd, got, want)
+		}
+	}
+	if got := c.monitorCall("break"); got != "$8006  break if X == 1\n$8008  break if X == 0\n$8004  log pc, [Counter]\n" {
+		t.Errorf("monitor break = %q", got)
+	}
+	if got := c.monitorCall("print [Nowhere]"); !strings.Contains(got, `unknown name "Nowhere"`) {
+		t.Errorf("monitor print of an unknown label = %q", got)
+	}
+	if got := c.monitorCall("asm $0300 lda #1; sta Counter"); got != "$0300  LDA #$01\n$0302  STA $10\n" {
+		t.Errorf("monitor asm = %q", got)
+	}
+	if got := c.call("m300,4"); got != "a9018510" {
+		t.Errorf("memory after asm = %q", got)
+	}
+	if got := c.monitorCall("asm $0300 lda #$100"); !strings.Contains(got, "doesn't fit") {
+		t.Errorf("monitor asm of a bad operand = %q", got)
+	}
+
+	// The logpoint prints and carries on; the false condition doesn't
+	// stop; the true one does, after the watches.
+	c.send("c")
+	if got := c.output(); got != "$8004: pc = 32772 ($8004), [Counter] = 42 ($2A)\n" {
+		t.Errorf("logpoint = %q", got)
+	}
+	if got := c.output(); got != "1: [Counter] = 42 ($2A)\n" {
+		t.Errorf("watches = %q", got)
+	}
+	if got := c.reply(); got != "S05" {
+		t.Errorf("c = %q", got)
+	}
+	if got := c.call("p5"); got != "0880" {
+		t.Errorf("stopped at PC %q, want $8008", got)
+	}
+	if got := c.monitorCall("unwatch 1"); got != "" || c.monitorCall("watch") != "no watches\n" {
+		t.Errorf("unwatch = %q", got)
+	}
+	c.monitorCall("clear $8004")
+	if got := c.monitorCall("break"); got != "$8006  break if X == 1\n$8008  break if X == 0\n" {
+		t.Errorf("after clear, monitor break = %q", got)
+	}
+
+	c.call("D")
+	if err := <-done; err != nil {
+		t.Errorf("ServeConn: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(stub.conditions) != 0 || len(stub.logpoints) != 0 || len(stub.watches) != 0 {
+		t.Error("detaching should clear conditions, logpoints and watches")
+	}
+}
+
+func TestStubStepJam(t *testing.T) {
+	n, err := testrom.New().FillPRG(func(i int) byte {
+		if i == 1 {
+			return 0x02 // JAM at $8001
+		}
+		return 0xEA
+	}).IRQ(0x9000).NES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	stub := New(n, &mu)
+	if reply, _ := stub.command("s8000"); reply != stopTrap {
+		t.Errorf("stepping the NOP = %q, want %s", reply, stopTrap)
+	}
+	if reply, _ := stub.command("s"); reply != stopIllegal || !n.CPU.Halted() {
+		t.Errorf("stepping the JAM = %q, halted=%v, want %s", reply, n.CPU.Halted(), stopIllegal)
+	}
+}
+
+func TestStubReverse(t *testing.T) {
+	n := testNES(t)
+	var mu sync.Mutex
+	stub := New(n, &mu)
+	stub.Undo = undo.New(n, 100)
+	srv, conn := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- stub.ServeConn(srv) }()
+	c := &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+	c.call("?")
+
+	if got := c.call("qSupported"); !strings.Contains(got, "ReverseStep+;ReverseContinue+") {
+		t.Errorf("qSupported = %q", got)
+	}
+	c.call("P5=0080")
+	for i := 0; i < 4; i++ {
+		c.call("s")
+	}
+	if got := c.call("bs"); got != "S05" || n.CPU.PC != 0x8003 {
+		t.Errorf("bs = %q, PC=$%04X, want $8003", got, n.CPU.PC)
+	}
+	c.call("Z0,8001,1")
+	if got := c.call("bc"); got != "S05" || n.CPU.PC != 0x8001 {
+		t.Errorf("bc = %q, PC=$%04X, want the breakpoint at $8001", got, n.CPU.PC)
+	}
+	if got := c.call("bc"); got != stopHistoryStart || n.CPU.PC != 0x8000 {
+		t.Errorf("bc = %q, PC=$%04X, want the start of history at $8000", got, n.CPU.PC)
+	}
+	for i := 0; i < 3; i++ {
+		c.call("s")
+	}
+	if got := c.monitorCall("back 5"); got != "back 3 to $8000, 0 more in history\n" {
+		t.Errorf("monitor back = %q", got)
+	}
+
+	c.call("D")
+	if err := <-done; err != nil {
+		t.Errorf("ServeConn: %v", err)
+	}
+
+	// Without a journal there's no reverse execution to offer.
+	stub = New(n, &mu)
+	if got := stub.query("Supported"); strings.Contains(got, "Reverse") {
+		t.Errorf("qSupported without Undo = %q", got)
+	}
+	if reply, _ := stub.command("bs"); reply != "" {
+		t.Errorf("bs without Undo = %q, want unsupported", reply)
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	if got := unescape("a}\x03b}]"); got != "a#b}" {
+		t.Errorf("unescape = %q", got)
+	}
+}
@@ -9,6 +9,7 @@ package gui
 import (
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/yoshiomiyamaegones/pkg/logger"
@@ -17,11 +18,85 @@ import (
 // Audio constants
 const (
 	AudioSampleRate = 44100
-	AudioBufferSize = 1024             // Standard buffer size
+	AudioBufferSize = 1024             // Default device buffer when no latency is set
 	AudioChannels   = 1                // Mono
 	AudioFormat     = sdl.AUDIO_F32LSB // 32-bit float, little-endian
 )
 
+// Bounds for the device buffer audioBufferSamples picks from a latency
+// target, in sample frames: about 6 and 93 ms at 44.1 kHz.
+const (
+	minAutoAudioBuffer = 256
+	maxAutoAudioBuffer = 4096
+)
+
+// maxAudioQueueBufs is as far as underruns may grow the automatic queue
+// cap, in device buffers.
+const maxAudioQueueBufs = 6
+
+// audioBufferSamples is the device buffer to request. An explicit size
+// wins. With a latency target it's the largest power of two that lets two
+// buffers fit in the target, so the queue cap never starves the device;
+// otherwise AudioBufferSize.
+func audioBufferSamples(requested int, latency time.Duration) uint16 {
+	if requested > 0 {
+		return uint16(requested)
+	}
+	if latency <= 0 {
+		return AudioBufferSize
+	}
+	half := int(int64(AudioSampleRate) * latency.Milliseconds() / 2000)
+	n := minAutoAudioBuffer
+	for n*2 <= half && n < maxAutoAudioBuffer {
+		n *= 2
+	}
+	return uint16(n)
+}
+
+// AudioStats is a snapshot of the audio output queue, for the OSD and
+// frontends tuning -audio-latency / -audio-buffer.
+type AudioStats struct {
+	Latency   time.Duration // audio queued ahead of playback right now
+	Buffer    int           // device buffer in sample frames, as SDL granted it
+	Underruns int           // times the queue had run dry when more audio arrived
+}
+
+// AudioStats reports the current queue depth and the underrun count. It
+// calls into SDL, so like the rest of the GUI it must be used on the SDL
+// thread. Zero without an audio device.
+func (g *NESGUI) AudioStats() AudioStats {
+	if g.audioDevice == 0 {
+		return AudioStats{}
+	}
+	return AudioStats{
+		Latency:   queuedDuration(sdl.GetQueuedAudioSize(g.audioDevice), g.audioSpec),
+		Buffer:    int(g.audioSpec.Samples),
+		Underruns: g.audioUnderruns,
+	}
+}
+
+// audioFrameBytes is the size of one output frame (a sample for every
+// channel) in spec's format, or 0 for a format queueAudio can't produce.
+func audioFrameBytes(spec *sdl.AudioSpec) int {
+	switch spec.Format {
+	case sdl.AUDIO_F32LSB:
+		return 4 * int(spec.Channels)
+	case sdl.AUDIO_S16LSB:
+		return 2 * int(spec.Channels)
+	}
+	return 0
+}
+
+// queuedDuration is how long n queued bytes of spec-format audio play for.
+func queuedDuration(n uint32, spec *sdl.AudioSpec) time.Duration {
+	frameBytes := audioFrameBytes(spec)
+	if frameBytes == 0 || spec.Freq <= 0 {
+		return 0
+	}
+	frames := int64(n) / int64(frameBytes)
+	return time.Duration(frames) * time.Second / time.Duration(spec.Freq)
+}
+
 // initAudio initializes SDL audio device and callback
 func (g *NESGUI) initAudio() error {
 	// List available audio drivers for debugging
@@ -40,7 +115,7 @@ func (g *NESGUI) initAudio() error {
 		Freq:     AudioSampleRate,
 		Format:   AudioFormat,
 		Channels: AudioChannels,
-		Samples:  AudioBufferSize,
+		Samples:  audioBufferSamples(g.opts.AudioBuffer, g.opts.AudioLatency),
 	}
 
 	logger.LogInfo("Requesting audio format: %dHz, %d channels, format 0x%x, buffer %d",
@@ -77,7 +152,8 @@ func (g *NESGUI) initAudio() error {
 	return nil
 }
 
-// queueAudio queues APU audio samples to SDL.
+// queueAudio moves the samples the emulation goroutine left in g.audio to
+// the SDL output queue. Runs on the SDL thread once per Run iteration.
 //
 // The APU is a mono source but the audio device may have been opened with more
 // channels (WASAPI on Windows often refuses mono and gives us stereo). Each
@@ -86,7 +162,7 @@ func (g *NESGUI) initAudio() error {
 // playing audio at twice the intended rate (one octave high) with severe
 // inter-sample noise from the L/R mismatch.
 func (g *NESGUI) queueAudio() {
-	if g.audioDevice == 0 {
+	if g.audioDevice == 0 || g.isPaused() {
 		return
 	}
 
@@ -97,29 +173,45 @@ func (g *NESGUI) queueAudio() {
 	// maxBytes guard below still caps queue growth so SDL doesn't grow
 	// unbounded during long turbo bursts.
 
-	apuOutput := g.nes.APU.Output
+	// Always drain the ring, even when the samples end up discarded below:
+	// leaving them queued would make every later sample that much late.
+	if g.audioPCM == nil {
+		g.audioPCM = make([]float32, audioRingSize)
+	}
+	apuOutput := g.audioPCM[:g.audio.pop(g.audioPCM)]
 	if len(apuOutput) == 0 {
 		return
 	}
-	defer func() { g.nes.APU.Output = g.nes.APU.Output[:0] }()
 
-	var bytesPerSample int
-	switch g.audioSpec.Format {
-	case sdl.AUDIO_F32LSB:
-		bytesPerSample = 4
-	case sdl.AUDIO_S16LSB:
-		bytesPerSample = 2
-	default:
+	bytesPerFrame := audioFrameBytes(g.audioSpec)
+	if bytesPerFrame == 0 {
 		return
 	}
 	channels := int(g.audioSpec.Channels)
-	bytesPerFrame := bytesPerSample * channels
 
-	// Cap queued audio at ~2 device buffers' worth to keep latency bounded
-	// while still tolerating short emulation hiccups. Uses the *actual* buffer
-	// size SDL gave us (the requested AudioBufferSize is often downgraded).
-	maxBytes := uint32(int(g.audioSpec.Samples) * bytesPerFrame * 2)
-	if sdl.GetQueuedAudioSize(g.audioDevice) >= maxBytes {
+	// The device drained everything we gave it before this batch was
+	// ready: an audible gap. With no latency configured, allow one more
+	// device buffer of queue so a host that stalls this thread often
+	// settles on a cap it can keep fed.
+	queued := sdl.GetQueuedAudioSize(g.audioDevice)
+	if queued == 0 && g.audioStarted {
+		g.audioUnderruns++
+		if g.opts.AudioLatency == 0 && g.audioQueueBufs < maxAudioQueueBufs {
+			g.audioQueueBufs++
+			logger.LogInfo("Audio underrun: queue cap raised to %d device buffers", g.audioQueueBufs)
+		}
+	}
+
+	// Cap queued audio at a few device buffers' worth (two to start) to keep
+	// latency bounded while still tolerating short stalls of this thread.
+	// Uses the *actual* buffer size SDL gave us (the requested size is often
+	// changed). A configured latency replaces the cap with that many ms of
+	// audio.
+	maxBytes := uint32(int(g.audioSpec.Samples) * bytesPerFrame * g.audioQueueBufs)
+	if g.opts.AudioLatency > 0 {
+		maxBytes = uint32(int64(g.audioSpec.Freq) * g.opts.AudioLatency.Milliseconds() / 1000 * int64(bytesPerFrame))
+	}
+	if queued >= maxBytes {
 		return
 	}
 
@@ -130,18 +222,12 @@ func (g *NESGUI) queueAudio() {
 		g.audioBuf = g.audioBuf[:needed]
 	}
 
-	// 2× gain brings the APU's post-HPF peak (~±0.5) up near full scale,
-	// matching the recorder so what you hear is what you record.
+	// The APU mixer has already applied gain and volume and clipped to
+	// -1..1; this is only format conversion.
 	switch g.audioSpec.Format {
 	case sdl.AUDIO_F32LSB:
 		for i, sample := range apuOutput {
-			v := sample * 2.0
-			if v > 1.0 {
-				v = 1.0
-			} else if v < -1.0 {
-				v = -1.0
-			}
-			bits := math.Float32bits(v)
+			bits := math.Float32bits(sample)
 			base := i * bytesPerFrame
 			for ch := 0; ch < channels; ch++ {
 				off := base + ch*4
@@ -153,12 +239,6 @@ func (g *NESGUI) queueAudio() {
 		}
 	case sdl.AUDIO_S16LSB:
 		for i, sample := range apuOutput {
-			sample *= 2.0
-			if sample > 1.0 {
-				sample = 1.0
-			} else if sample < -1.0 {
-				sample = -1.0
-			}
 			intSample := int16(sample * 32767)
 			base := i * bytesPerFrame
 			for ch := 0; ch < channels; ch++ {
@@ -170,4 +250,5 @@ func (g *NESGUI) queueAudio() {
 	}
 
 	sdl.QueueAudio(g.audioDevice, g.audioBuf)
+	g.audioStarted = true
 }
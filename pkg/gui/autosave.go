@@ -0,0 +1,213 @@
+// Package gui — the rolling autosave (Options.Autosave) and the crash dump.
+//
+// With autosave on, the whole machine is written to <rom>.autosave after
+// every Options.Autosave of play, when another ROM is opened and on exit,
+// each write replacing the last. Its metadata (package savestate) carries
+// the ROM's SHA-1, so when the same dump — not merely a file of the same
+// name — is opened again the GUI offers to pick up where it left off:
+// Enter resumes, Esc starts over. Like the menus, the offer takes every
+// key until it's answered.
+//
+// Whether or not autosave is on, a panic on the emulation goroutine leaves
+// <rom>.crash.state, a last-ditch save of the machine, and <rom>.crash.log
+// with the panic, its stack and the log ring, to attach to a bug report.
+package gui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/savestate"
+)
+
+// autosaveOffer is the resume prompt shown when a ROM with an autosave is
+// opened.
+type autosaveOffer struct {
+	path string
+	meta savestate.Meta
+}
+
+// autosavePath is <rom>.autosave, beside the state slots.
+func (g *NESGUI) autosavePath() string {
+	return nes.CompanionFileIn(g.opts.StateDir, g.romPath, ".autosave")
+}
+
+// romSHA1 identifies the loaded dump; "" with no cartridge.
+func (g *NESGUI) romSHA1() string {
+	if g.nes.Cartridge == nil {
+		return ""
+	}
+	return g.nes.Cartridge.Hashes().ROMSHA1
+}
+
+// checkAutosave writes the autosave once Options.Autosave of frames have
+// run since the last. Called from update with emuMu held, so time spent
+// paused doesn't count.
+func (g *NESGUI) checkAutosave() {
+	if g.opts.Autosave <= 0 {
+		return
+	}
+	g.sinceAutosave++
+	if g.sinceAutosave >= uint64(g.opts.Autosave/FrameTime) {
+		g.writeAutosave()
+	}
+}
+
+// writeAutosave replaces the loaded ROM's autosave with the machine as it
+// is now. Does nothing with autosave off, or while the autosave there is
+// still on offer: quitting without answering mustn't lose it.
+func (g *NESGUI) writeAutosave() {
+	g.sinceAutosave = 0
+	if g.opts.Autosave <= 0 || g.romPath == "" || g.nes.Cartridge == nil || g.autosaveOffer != nil {
+		return
+	}
+	path := g.autosavePath()
+	if err := g.writeStateFile(path); err != nil {
+		logger.LogError("Autosave: %v", err)
+		return
+	}
+	g.writeStateMeta(path)
+	logger.LogDebug("Autosaved to %s", path)
+}
+
+// writeStateFile saves the machine to path through a temporary file
+// renamed into place, so a crash while writing leaves the old file whole.
+func (g *NESGUI) writeStateFile(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+	if err := g.nes.SaveState(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// offerAutosave asks whether to resume the loaded ROM's autosave, if
+// autosave is on and there is one made from the same dump. Called when a
+// ROM is opened.
+func (g *NESGUI) offerAutosave() {
+	g.dismissAutosave()
+	if g.opts.Autosave <= 0 || g.romPath == "" {
+		return
+	}
+	path := g.autosavePath()
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	m, err := savestate.Read(path)
+	if err != nil {
+		logger.LogError("Autosave: %v", err)
+		return
+	}
+	if m.ROMSHA1 == "" || m.ROMSHA1 != g.romSHA1() {
+		logger.LogInfo("Autosave %s was made from a different ROM; not offering it", path)
+		return
+	}
+	g.autosaveOffer = &autosaveOffer{path: path, meta: m}
+	g.osd.SetPersistent(osdKeyMenu, fmt.Sprintf("Resume autosave? %s, played %s",
+		m.Saved.Local().Format("01-02 15:04"), savestate.FormatPlayTime(m.PlayTime)))
+	g.osd.SetPersistent(osdKeyMenuHelp, "Enter:resume  Esc:start over")
+}
+
+func (g *NESGUI) dismissAutosave() {
+	if g.autosaveOffer == nil {
+		return
+	}
+	g.autosaveOffer = nil
+	g.osd.SetPersistent(osdKeyMenu, "")
+	g.osd.SetPersistent(osdKeyMenuHelp, "")
+}
+
+// handleAutosaveKey consumes every keyboard event while the offer is up.
+func (g *NESGUI) handleAutosaveKey(e *sdl.KeyboardEvent) {
+	if e.State != sdl.PRESSED {
+		return
+	}
+	switch e.Keysym.Sym {
+	case sdl.K_RETURN, sdl.K_KP_ENTER:
+		g.resumeAutosave()
+	case sdl.K_ESCAPE:
+		g.dismissAutosave()
+	}
+}
+
+// resumeAutosave loads the offered autosave.
+func (g *NESGUI) resumeAutosave() {
+	o := g.autosaveOffer
+	g.dismissAutosave()
+	f, err := os.Open(o.path)
+	if err != nil {
+		logger.LogError("Autosave: %v", err)
+		g.osd.Notify("Autosave: load failed")
+		return
+	}
+	defer f.Close()
+	if err := g.nes.LoadState(f); err != nil {
+		logger.LogError("Autosave: %s: %v", o.path, err)
+		g.osd.Notify("Autosave: load failed")
+		return
+	}
+	g.resetUndo()
+	g.playFrames = uint64(o.meta.PlayTime / FrameTime)
+	g.sinceAutosave = 0
+	g.notify("Resumed from autosave")
+}
+
+// dumpCrashOnPanic is deferred by the emulation goroutine: a panic writes
+// the crash dump, then continues. The goroutine holds emuMu.
+func (g *NESGUI) dumpCrashOnPanic() {
+	if r := recover(); r != nil {
+		g.writeCrashDump(r, debug.Stack())
+		panic(r)
+	}
+}
+
+// writeCrashDump writes <rom>.crash.log and, with a ROM loaded,
+// <rom>.crash.state — gones.crash.log in the working directory without
+// one. The machine may be in any state by now, so saving it is allowed to
+// fail, even by panicking; the log says so.
+func (g *NESGUI) writeCrashDump(r any, stack []byte) {
+	logPath := "gones.crash.log"
+	if g.romPath != "" {
+		logPath = nes.CompanionFileIn(g.opts.StateDir, g.romPath, ".crash.log")
+		statePath := nes.CompanionFileIn(g.opts.StateDir, g.romPath, ".crash.state")
+		if err := g.saveCrashState(statePath); err != nil {
+			logger.LogError("Crash dump: state: %v", err)
+		} else {
+			logger.LogError("Crash dump: state saved to %s", statePath)
+		}
+	}
+	f, err := os.Create(logPath)
+	if err != nil {
+		logger.LogError("Crash dump: %v", err)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "panic: %v\n\n%s\n", r, stack)
+	logger.DumpRing(f)
+	logger.LogError("Crash dump: log written to %s", logPath)
+}
+
+func (g *NESGUI) saveCrashState(path string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panicked: %v", r)
+		}
+	}()
+	return g.writeStateFile(path)
+}
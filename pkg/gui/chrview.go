@@ -0,0 +1,97 @@
+// Package gui — the CHR bank viewer (Ctrl+B).
+//
+// While it's on, package chrdiff snapshots the pattern tables after every
+// frame and drawOSD shows them along the bottom of the picture, each 1KB
+// slot outlined in red when its contents changed, fading over
+// chrFadeFrames so a bank switched for a single frame still shows. A
+// persistent OSD line names the writes behind the last change; with
+// -mapper-log chrdiff logs each one among the mapper's own messages.
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/chrdiff"
+	"github.com/yoshiomiyamaegones/pkg/osd"
+)
+
+// chrFadeFrames is how long a changed slot stays lit.
+const chrFadeFrames = 30
+
+// osdKeyCHR is the viewer's persistent OSD line.
+const osdKeyCHR = "chr"
+
+// toggleCHRViewer starts or stops the viewer.
+func (g *NESGUI) toggleCHRViewer() {
+	if g.chrDiff == nil {
+		g.chrDiff = chrdiff.Start(g.nes)
+		g.chrLit = [chrdiff.Slots]float64{}
+	} else {
+		g.chrDiff.Stop()
+		g.chrDiff = nil
+		g.osd.SetPersistent(osdKeyCHR, "")
+	}
+	g.notify("CHR viewer: %s", onOff(g.chrDiff != nil))
+}
+
+// endCHRFrame runs after every frame while the viewer is on, with emuMu
+// held: it lights the slots the frame changed and captions them.
+func (g *NESGUI) endCHRFrame() {
+	g.chrDiff.EndFrame()
+	slots := g.chrDiff.LastFrame()
+	for s, sl := range slots {
+		if sl.Changed || len(sl.Writes) > 0 {
+			g.chrLit[s] = 1
+		} else {
+			g.chrLit[s] = max(g.chrLit[s]-1.0/chrFadeFrames, 0)
+		}
+	}
+	if caption := chrCaption(slots); caption != "" {
+		g.osd.SetPersistent(osdKeyCHR, caption)
+	}
+}
+
+// chrCaption describes the writes that changed slots, e.g.
+// "CHR 0-3 $8001=$04@C123 4 $2007x64@C5A0", or "" when none did. Slots
+// changed by the same last write are listed as a range.
+func chrCaption(slots *[chrdiff.Slots]chrdiff.Slot) string {
+	var parts []string
+	for s := 0; s < len(slots); {
+		writes := slots[s].Writes
+		if len(writes) == 0 {
+			s++
+			continue
+		}
+		last := writes[len(writes)-1]
+		end := s
+		for end+1 < len(slots) && len(slots[end+1].Writes) > 0 && slots[end+1].Writes[len(slots[end+1].Writes)-1] == last {
+			end++
+		}
+		slot := fmt.Sprint(s)
+		if end > s {
+			slot = fmt.Sprintf("%d-%d", s, end)
+		}
+		if last.DataWrite() {
+			parts = append(parts, fmt.Sprintf("%s $2007x%d@%04X", slot, len(writes)+slots[s].More, last.PC))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s $%04X=$%02X@%04X", slot, last.Address, last.Value, last.PC))
+		}
+		s = end + 1
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "CHR " + strings.Join(parts, " ")
+}
+
+// drawCHR draws the pattern tables into textureBuf. Called from drawOSD
+// with emuMu held.
+func (g *NESGUI) drawCHR(w, h int) {
+	snap := g.chrDiff.Snapshot()
+	g.chrBuf = g.chrBuf[:0]
+	for s := range snap {
+		g.chrBuf = append(g.chrBuf, snap[s][:]...)
+	}
+	osd.DrawCHR(g.textureBuf, w, h, g.chrBuf, g.chrLit[:])
+}
@@ -0,0 +1,84 @@
+// Package gui — GDB remote debugging (Options.GDBPort).
+//
+// The stub (package gdb) runs on its own goroutine and takes emuMu like
+// any other caller touching g.nes. While a debugger holds the target,
+// debugHalted parks the emulation goroutine exactly as a pause does, and
+// the window keeps redrawing the frame the target stopped in. The undo
+// journal that lets it step backwards starts afresh whenever the machine
+// is replaced under it: a new ROM, a loaded state, a power cycle.
+package gui
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/gdb"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/undo"
+)
+
+// startDebugger listens for GDB clients on localhost:port, journaling the
+// last undoSize instructions for stepping back. The stub can rewrite
+// memory and registers, so it is never exposed beyond this host.
+func (g *NESGUI) startDebugger(port, undoSize int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return err
+	}
+	g.debugger = gdb.New(g.nes, &g.emuMu)
+	g.debugger.OnHalt = g.debuggerHalt
+	g.debugger.Monitor = g.debuggerMonitor
+	g.debugger.Symbols = g.symbols
+	if undoSize > 0 {
+		g.debugger.Undo = undo.New(g.nes, undoSize)
+	}
+	g.debugListener = ln
+	go g.debugger.Serve(ln)
+	logger.LogInfo("GDB stub listening on %s", ln.Addr())
+	return nil
+}
+
+// resetUndo forgets the debugger's history after the machine state was
+// replaced wholesale, which the journal can't step back across.
+func (g *NESGUI) resetUndo() {
+	if g.debugger != nil && g.debugger.Undo != nil {
+		g.debugger.Undo.Reset()
+	}
+}
+
+// debuggerHalt is the stub's OnHalt, called with emuMu held.
+func (g *NESGUI) debuggerHalt(halted bool) {
+	g.debugHalted.Store(halted)
+	if halted {
+		pc := g.nes.CPU.PC
+		g.notify("Debugger: stopped at %s", g.symbols.Label(pc, g.nes.PRGOffset(pc)))
+		return
+	}
+	select {
+	case g.resume <- struct{}{}:
+	default:
+	}
+}
+
+// debuggerMonitor is the stub's Monitor, called with emuMu held for the
+// commands the stub doesn't handle itself (break, log, watch and the
+// other expression commands): gdb's "monitor trace" writes the
+// instruction trace ("trace clear" empties it), and "monitor ppu
+// [on|off]" drives the PPU write log.
+func (g *NESGUI) debuggerMonitor(cmd string) string {
+	name, arg, _ := strings.Cut(strings.TrimSpace(cmd), " ")
+	switch name {
+	case "trace":
+		if arg == "clear" {
+			if g.tracer != nil {
+				g.tracer.Reset()
+			}
+			return ""
+		}
+		return g.writeTrace() + "\n"
+	case "ppu":
+		return g.ppuLogMonitor(arg)
+	}
+	return "trace, trace clear   write or empty the instruction trace\nppu [on|off]         show or drive the PPU write log\n"
+}
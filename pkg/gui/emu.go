@@ -0,0 +1,220 @@
+// Package gui — emulation goroutine and its hand-off to the SDL thread.
+//
+// The NES core runs on its own goroutine (emulate) so a slow Present or a
+// stalled event pump on the SDL thread can't stretch an emulated frame and
+// starve the audio queue. Everything SDL stays on the OS thread locked in
+// NewNESGUI; the two sides meet in three places:
+//
+//   - frameBuffers: a triple buffer the emulator publishes each finished
+//     frame into and the renderer picks the newest one out of, without
+//     either side ever waiting for the other;
+//   - audioRing: a single-producer/single-consumer ring of APU samples the
+//     SDL thread drains into the device queue;
+//   - emuMu: held by the emulator while it steps a frame, and by the SDL
+//     thread around anything that touches g.nes (hotkeys, input, ROM
+//     swaps) or state the emulator writes (FPS, recorder).
+package gui
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+)
+
+// idlePoll bounds how long Run waits for a frame before servicing events
+// and audio anyway.
+const idlePoll = 5 * time.Millisecond
+
+// pausedRedrawInterval is how often Run re-presents the held frame while
+// paused, so expiring OSD messages clear and an uncovered window repaints.
+const pausedRedrawInterval = 100 * time.Millisecond
+
+// frameFresh marks frameBuffers.spare as holding a frame the reader hasn't
+// taken yet. The low bits are the buffer index.
+const frameFresh = 4
+
+// frameBuffers is a lock-free triple buffer. The writer owns back, the
+// reader owns front, and the third buffer is parked in spare; both sides
+// only ever exchange their own buffer with spare, so neither can touch a
+// buffer the other is using.
+type frameBuffers struct {
+	bufs  [3][]uint32
+	spare atomic.Uint32
+	back  uint32 // writer side
+	front uint32 // reader side
+}
+
+func newFrameBuffers(size int) *frameBuffers {
+	f := &frameBuffers{back: 0, front: 1}
+	for i := range f.bufs {
+		f.bufs[i] = make([]uint32, size)
+	}
+	f.spare.Store(2)
+	return f
+}
+
+// publish copies src into the back buffer and makes it the newest frame.
+// A frame the reader never picked up is simply overwritten next time.
+func (f *frameBuffers) publish(src []uint32) {
+	copy(f.bufs[f.back], src)
+	f.back = f.spare.Swap(f.back|frameFresh) &^ frameFresh
+}
+
+// latest returns the newest published frame and whether it is new since the
+// previous call. The slice stays valid until the next call.
+func (f *frameBuffers) latest() ([]uint32, bool) {
+	if f.spare.Load()&frameFresh == 0 {
+		return f.bufs[f.front], false
+	}
+	f.front = f.spare.Swap(f.front) &^ frameFresh
+	return f.bufs[f.front], true
+}
+
+// audioRingSize is the ring's capacity in samples (~370ms at 44.1kHz) —
+// far more than the device queue cap in queueAudio, so the ring only fills
+// when the SDL thread is stalled or turbo outruns playback. Power of two so
+// positions wrap with a mask.
+const audioRingSize = 1 << 14
+
+// audioRing is a lock-free single-producer/single-consumer sample queue.
+// head and tail count samples ever written/read; only the producer stores
+// head and only the consumer stores tail.
+type audioRing struct {
+	buf  [audioRingSize]float32
+	head atomic.Uint64
+	tail atomic.Uint64
+}
+
+// push appends as many of samples as fit and returns that count. Samples
+// that don't fit are dropped: a full ring means playback is already far
+// behind, and queueing more would only add latency.
+func (r *audioRing) push(samples []float32) int {
+	head, tail := r.head.Load(), r.tail.Load()
+	n := audioRingSize - int(head-tail)
+	if n > len(samples) {
+		n = len(samples)
+	}
+	for i := 0; i < n; i++ {
+		r.buf[(head+uint64(i))&(audioRingSize-1)] = samples[i]
+	}
+	r.head.Store(head + uint64(n))
+	return n
+}
+
+// pop moves up to len(dst) samples into dst and returns the count.
+func (r *audioRing) pop(dst []float32) int {
+	head, tail := r.head.Load(), r.tail.Load()
+	n := int(head - tail)
+	if n > len(dst) {
+		n = len(dst)
+	}
+	for i := 0; i < n; i++ {
+		dst[i] = r.buf[(tail+uint64(i))&(audioRingSize-1)]
+	}
+	r.tail.Store(tail + uint64(n))
+	return n
+}
+
+// len reports how many samples are waiting.
+func (r *audioRing) len() int {
+	return int(r.head.Load() - r.tail.Load())
+}
+
+// isPaused reports whether emulation is held, by the user, by focus loss,
+// or by a debugger.
+func (g *NESGUI) isPaused() bool {
+	return g.paused || g.backgroundPaused || g.debugHalted.Load()
+}
+
+// setPause sets both pause reasons. When that changes whether emulation is
+// held, it pauses or resumes the audio device — SDL keeps the queued
+// samples, so playback picks up exactly where it stopped — and on resume
+// wakes the emulation goroutine. Called on the SDL thread with emuMu held.
+func (g *NESGUI) setPause(user, background bool) {
+	was := g.isPaused()
+	g.paused, g.backgroundPaused = user, background
+	now := g.isPaused()
+	if now == was {
+		return
+	}
+	if g.audioDevice != 0 {
+		sdl.PauseAudioDevice(g.audioDevice, now)
+	}
+	if now {
+		// The queue drains to nothing during a long pause; that isn't an
+		// underrun.
+		g.audioStarted = false
+		return
+	}
+	g.fpsCounter = 0
+	g.fpsTimer = time.Now()
+	select {
+	case g.resume <- struct{}{}:
+	default:
+	}
+}
+
+// emulate is the emulation goroutine: step a frame, publish it, pace, until
+// stop is closed. While paused it blocks on g.resume instead of stepping.
+// Pacing is the same target-time accumulation the loop used when it ran on
+// the SDL thread — see waitForNextFrame.
+func (g *NESGUI) emulate(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	defer logger.DumpOnPanic()
+	defer g.dumpTraceOnPanic()
+	defer g.dumpCrashOnPanic()
+
+	frameCount := 0
+	startTime := time.Now()
+	wasTurbo := false
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		g.emuMu.Lock()
+		if g.isPaused() {
+			g.emuMu.Unlock()
+			select {
+			case <-stop:
+				return
+			case <-g.resume:
+			}
+			// Restart the pacing baseline, as when turbo ends, so the
+			// limiter doesn't race through the frames the pause skipped.
+			frameCount = 0
+			startTime = time.Now()
+			continue
+		}
+		turbo := g.turbo
+		g.hideFrame = !turbo && !g.skip.show(g.behind(startTime, frameCount))
+		g.update() // publishes the frame through ReceiveFrame
+		shown := !g.hideFrame
+		g.emuMu.Unlock()
+
+		// Wake the SDL thread if it is waiting for a frame.
+		if shown {
+			select {
+			case g.frameReady <- struct{}{}:
+			default:
+			}
+		}
+
+		// When exiting turbo, reset the frame-pacing baseline so the limiter
+		// doesn't try to "catch up" by running the next several frames with
+		// zero sleep.
+		if wasTurbo && !turbo {
+			frameCount = 0
+			startTime = time.Now()
+		}
+		wasTurbo = turbo
+
+		frameCount++
+		g.waitForNextFrame(startTime, frameCount, turbo)
+	}
+}
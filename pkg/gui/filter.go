@@ -0,0 +1,88 @@
+// Package gui — the video filter (Options.Filter, the Esc menu's Filter).
+//
+// A filter scales the cropped picture, OSD included, into filterBuf, and
+// the texture is sized to its output; the renderer then stretches that to
+// the window as it does the unfiltered picture. Screenshots, read back
+// from the renderer, show the filter too.
+package gui
+
+import (
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/vfilter"
+)
+
+// filterName is the filter in use, as vfilter.Names spells it.
+func (g *NESGUI) filterName() string {
+	if g.opts.Filter == "" {
+		return vfilter.Names()[0]
+	}
+	return g.opts.Filter
+}
+
+// setFilter switches to the filter called name, resizing the texture to
+// its output. On an error the current filter stays.
+func (g *NESGUI) setFilter(name string) error {
+	f, err := vfilter.New(name)
+	if err != nil {
+		return err
+	}
+	g.filter = f
+	g.opts.Filter = name
+	return g.resizeTexture()
+}
+
+// cycleFilter moves dir places through vfilter.Names, wrapping around.
+func (g *NESGUI) cycleFilter(dir int) {
+	names := vfilter.Names()
+	i := 0
+	for j, n := range names {
+		if n == g.filterName() {
+			i = j
+		}
+	}
+	name := names[(i+dir+len(names))%len(names)]
+	if err := g.setFilter(name); err != nil {
+		g.notify("Filter: %v", err)
+	}
+}
+
+// resizeTexture sizes textureBuf to the cropped picture and the texture
+// and filterBuf to the filter's output of it. Runs on the SDL thread.
+func (g *NESGUI) resizeTexture() error {
+	w, h := g.opts.Overscan.size()
+	s := 1
+	if g.filter != nil {
+		s = g.filter.Scale()
+	}
+	if g.renderer != nil {
+		texture, err := g.renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, int32(w*s), int32(h*s))
+		if err != nil {
+			return err
+		}
+		texture.SetBlendMode(sdl.BLENDMODE_NONE)
+		if g.texture != nil {
+			g.texture.Destroy()
+		}
+		g.texture = texture
+	}
+	g.textureBuf = make([]uint32, w*h)
+	g.filterBuf = nil
+	if s > 1 {
+		g.filterBuf = make([]uint32, w*s*h*s)
+	}
+	return nil
+}
+
+// uploadFrame copies textureBuf, through the filter if there is one, to
+// the texture.
+func (g *NESGUI) uploadFrame() {
+	w, h := g.opts.Overscan.size()
+	buf, pitch := g.textureBuf, w
+	if g.filter != nil {
+		g.filter.Apply(g.filterBuf, g.textureBuf, w, h)
+		buf, pitch = g.filterBuf, w*g.filter.Scale()
+	}
+	g.texture.Update(nil, unsafe.Pointer(&buf[0]), pitch*4)
+}
@@ -6,26 +6,61 @@
 // split across files by concern:
 //
 //   - gui.go      window/renderer/texture lifecycle and the main Run loop
+//   - emu.go      emulation goroutine, triple-buffered frames, audio ring
 //   - audio.go    SDL audio init and per-frame sample queueing
-//   - timing.go   frame pacing, FPS counter, window-title updates
+//   - timing.go   frame pacing and skip, FPS counter, window-title updates
+//   - stats.go    Ctrl+F11 performance HUD and -stats-csv telemetry
+//   - title.go    window title contents and multi-instance numbering
+//   - icon.go     the window icon
+//   - filter.go   the video filter (package vfilter) between picture and texture
 //   - hotkeys.go  emulator-level key dispatch (Esc/Tab/Fn/1-6 + modifiers)
+//   - menu.go     Esc menu: ROM browser, settings, controls, cheats
 //   - state.go    save/load state slots, screenshots, cheat-file loading
 //   - input.go    InputManager — keyboard/joystick/gamepad → NES buttons
 //   - recorder.go wavRecorder + toggleRecording (Ctrl+E audio capture)
+//   - recent.go   drag-and-drop ROM swap, per-game settings and the Ctrl+O
+//     recent-ROMs menu
+//   - profiler.go Ctrl+U cycle profiler and its report
+//   - debugger.go GDB remote debugging stub
+//   - trace.go    instruction trace ring, written out on Ctrl+T or a crash
+//   - ppulog.go   Ctrl+W PPU register write log of one frame
+//   - watch.go    reloading the ROM when its file changes (-watch)
+//   - scope.go    Ctrl+A per-channel audio oscilloscope
+//   - chrview.go  Ctrl+B pattern tables with changed CHR banks lit
+//   - picker.go   Ctrl+S save-state picker with thumbnails
+//   - autosave.go rolling autosave, the resume offer and the crash dump
+//   - keyboard.go Ctrl+K typing on the Family BASIC keyboard
+//   - osd.go      on-screen text (notifications, FPS, menus) via pkg/osd
 package gui
 
 import (
+	"net"
+	"path/filepath"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/apu"
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/chrdiff"
+	"github.com/yoshiomiyamaegones/pkg/core"
+	"github.com/yoshiomiyamaegones/pkg/gdb"
+	"github.com/yoshiomiyamaegones/pkg/input"
 	"github.com/yoshiomiyamaegones/pkg/logger"
 	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/osd"
 	"github.com/yoshiomiyamaegones/pkg/ppu"
+	"github.com/yoshiomiyamaegones/pkg/ppulog"
+	"github.com/yoshiomiyamaegones/pkg/profile"
+	"github.com/yoshiomiyamaegones/pkg/rules"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+	"github.com/yoshiomiyamaegones/pkg/trace"
+	"github.com/yoshiomiyamaegones/pkg/vfilter"
 )
 
-// Window constants
+// Window constants. WindowScale is the default; Options.Scale overrides it.
 const (
 	WindowScale  = 3
 	WindowWidth  = ppu.ScreenWidth * WindowScale
@@ -45,13 +80,52 @@ type NESGUI struct {
 	// Audio
 	audioDevice sdl.AudioDeviceID
 	audioSpec   *sdl.AudioSpec
-	audioBuf    []byte // grown on demand; reused across queueAudio calls
+	audioBuf    []byte    // grown on demand; reused across queueAudio calls
+	audioPCM    []float32 // queueAudio's scratch for samples popped off audio
+	audio       audioRing // emulator → SDL thread sample hand-off
+
+	// Audio queue telemetry and the automatic cap (see queueAudio).
+	// audioQueueBufs is the cap in device buffers when no AudioLatency is
+	// set; it grows by one on each underrun, up to maxAudioQueueBufs.
+	audioQueueBufs int
+	audioUnderruns int
+	audioStarted   bool // something has been queued, so an empty queue is an underrun
+
+	// volumeChanged is set once the hotkeys move the volume or mute, so
+	// Destroy hands them to Options.SaveVolume.
+	volumeChanged bool
+
+	// Emulation thread hand-off (see emu.go). emuMu guards g.nes and every
+	// field the emulation goroutine writes; frames carries finished frames
+	// to render, and frameReady wakes Run when one is published.
+	emuMu      sync.Mutex
+	frames     *frameBuffers
+	frameReady chan struct{}
+
+	// Frame skip (see timing.go). skip, owned by the emulation goroutine,
+	// picks the frames that reach the screen; hideFrame, set with emuMu
+	// held around each step, tells ReceiveFrame to drop the frame.
+	skip      frameSkip
+	hideFrame bool
+
+	// Pause. paused is the P hotkey; backgroundPaused is set while the
+	// window is unfocused and Options.PauseInBackground is on. Emulation
+	// holds while either is set, blocked on resume (see emulate). Written
+	// only by the SDL thread, with emuMu held.
+	paused           bool
+	backgroundPaused bool
+	resume           chan struct{}
+
+	// debugger is the GDB stub when Options.GDBPort is set (see
+	// debugger.go); debugHalted holds emulation while a client has the
+	// target stopped. It is set from the stub's goroutine, hence atomic.
+	debugger      *gdb.Stub
+	debugListener net.Listener
+	debugHalted   atomic.Bool
 
 	// Timing. lastRenderTime gates the turbo throttle; the frame-counter
 	// baseline is reset whenever turbo turns off so the limiter doesn't try
 	// to "catch up" by running the next several frames with zero sleep.
-	lastFrameTime  time.Time
-	nextFrameTime  time.Time
 	lastRenderTime time.Time
 
 	// FPS tracking
@@ -60,15 +134,101 @@ type NESGUI struct {
 	currentFPS float64
 	showFPS    bool
 
+	// Telemetry (see stats.go). showStats is the Ctrl+F11 HUD; statsLog
+	// the -stats-csv file. updateTime is the last frame's update, written
+	// with emuMu held; renderTime the last render in nanoseconds, written
+	// by the SDL thread and read by the emulation goroutine for the CSV.
+	showStats  bool
+	statsLog   *statsLog
+	updateTime time.Duration
+	renderTime atomic.Int64
+
+	// showInput draws the controllers' buttons in the bottom-right corner
+	// (Ctrl+I); padBuf is reused for the per-frame button snapshot.
+	showInput bool
+	padBuf    []core.ButtonState
+
 	// Turbo mode: skip frame limiter (and audio output) to fast-forward.
 	// Toggled with Tab.
 	turbo bool
 
+	// frameSamples is how many samples the last frame produced, for
+	// Stats.
+	frameSamples int
+
+	// halted mirrors nes.CPU.Halted() as of the last frame, so a JAM is
+	// reported once when it happens rather than on every frame after.
+	halted bool
+
+	// kbCapture sends the keyboard to the Family BASIC keyboard instead
+	// of the hotkeys and player 1 (see keyboard.go).
+	kbCapture bool
+
+	// tvControl is the TV knob [ and ] turn, an index into tvControls.
+	tvControl int
+
+	// playFrames counts the frames the current game has run, for the
+	// play time saved with each state slot.
+	playFrames uint64
+
+	// symbols names the ROM's routines (<rom>.dbg/.fns/.nl), nil when it
+	// has no symbol files.
+	symbols *symbols.Table
+
+	// profiler is the Ctrl+U cycle profiler, nil when off; lineColors is
+	// reused for its scanline bar.
+	profiler   *profile.Profiler
+	lineColors []uint32
+
+	// picker is the open Ctrl+S state picker (picker.go), nil when closed.
+	picker *statePicker
+
+	// menu is the open Esc menu (menu.go), nil when closed.
+	menu *gameMenu
+
+	// autosaveOffer is the resume prompt for the ROM's autosave
+	// (autosave.go), nil when none is showing; sinceAutosave counts the
+	// frames run since the last autosave.
+	autosaveOffer *autosaveOffer
+	sinceAutosave uint64
+
+	// scopeBuf is reused for the Ctrl+A oscilloscope's panes (scope.go).
+	scopeBuf []osd.Scope
+
+	// chrDiff watches the pattern tables for the Ctrl+B CHR viewer
+	// (chrview.go), nil when off; chrLit fades each 1KB slot's highlight
+	// and chrBuf is reused for drawing.
+	chrDiff *chrdiff.Diff
+	chrLit  [chrdiff.Slots]float64
+	chrBuf  []uint8
+
+	// watch polls the ROM file for watch.go, nil unless Options.Watch.
+	watch *romWatch
+
+	// tracer keeps the last instructions for trace.go, nil when neither
+	// Options.Trace nor CPU logging asked for it.
+	tracer *trace.Tracer
+
+	// ppuLog records PPU register writes while on (see ppulog.go);
+	// ppuLogCapture saves and stops it after the current frame.
+	ppuLog        *ppulog.Log
+	ppuLogCapture bool
+
+	// rules watches memory for the conditions in <rom>.rules.json and
+	// flashes each rule's message when it fires. Checked after every frame
+	// on the emulation goroutine.
+	rules *rules.Engine
+
 	// Texture upload buffer. PPU.FrameBuffer is embedded in a struct that
 	// holds other Go pointers, which cgo rejects when handed to SDL. A
 	// make()'d slice has no such issue; copy() is a fast memmove.
 	textureBuf []uint32
 
+	// filter scales textureBuf into filterBuf before upload; nil for none
+	// (see filter.go).
+	filter    vfilter.VideoFilter
+	filterBuf []uint32
+
 	// Input management
 	inputManager *InputManager
 
@@ -76,13 +236,165 @@ type NESGUI struct {
 	// and recording paths (<rom>.<timestamp>.wav).
 	romPath string
 
+	// romName is the loaded game's name for the title bar (romTitle);
+	// instance numbers this window among running emulators (claimInstance),
+	// instanceLock holding the number until Destroy. title is the title
+	// last set.
+	romName      string
+	instance     int
+	instanceLock net.Listener
+	title        string
+
 	// WAV recorder for Ctrl+E audio capture. nil when not recording.
 	recorder *wavRecorder
+
+	// On-screen display composited into textureBuf before upload.
+	osd *osd.OSD
+
+	// Recently loaded ROMs (persisted under the user config dir) and the
+	// Ctrl+O menu state. While the menu is open it owns the keyboard.
+	recent          *recentROMs
+	recentMenuOpen  bool
+	recentMenuIndex int
+
+	// opts are the user settings NewNESGUI was given (see Options).
+	opts Options
+}
+
+// Options carries the user-configurable front-end settings, normally
+// filled from the config file and command line. The zero value is the
+// stock setup: 3× window, two device buffers of audio latency, Z/X/A/S +
+// arrow keys, companion files next to the ROM, cheats loaded from <rom>.cht.
+type Options struct {
+	Scale        int           // window size as a multiple of 256×240; 0 means WindowScale
+	AudioLatency time.Duration // cap on audio queued ahead of playback; 0 means two device buffers
+	AudioBuffer  int           // SDL device buffer in sample frames; 0 picks one from AudioLatency
+	Volume       int           // master volume in percent, 1-100; 0 means 100
+	Muted        bool          // start with sound off
+
+	// SaveVolume, if set, is called on exit with the master volume in
+	// percent (0-100) and the mute state when the hotkeys changed them,
+	// to write them back to the settings file.
+	SaveVolume func(volume int, muted bool) error
+
+	// PauseInBackground holds emulation while the window doesn't have
+	// keyboard focus.
+	PauseInBackground bool
+
+	// Pacing is how frames are timed: PacingHybrid (the default, also for
+	// ""), PacingSleep or PacingVSync. See timing.go.
+	Pacing string
+
+	// FrameSkip drops this many frames after each one shown, still
+	// emulating them all; AutoFrameSkip also drops up to maxAutoFrameSkip
+	// in a row whenever emulation falls behind real time. Either saves the
+	// render work on hosts too slow for it, without gaps in the sound.
+	FrameSkip     int
+	AutoFrameSkip bool
+
+	// StatsCSV, if set, is a file to write the telemetry (Stats) of every
+	// frame to as CSV.
+	StatsCSV string
+
+	// Filter names the video filter the picture starts with (see package
+	// vfilter); "" is none.
+	Filter string
+
+	// Overscan hides the picture's edges, as a TV's bezel did; the window,
+	// the OSD and screenshots all use the cropped size. The zero value
+	// shows the full 256×240. The core's framebuffer stays uncropped.
+	Overscan Overscan
+
+	// Keys are player 1's bindings as SDL key names, in NES button order
+	// (A, B, Select, Start, Up, Down, Left, Right). Empty names keep the
+	// default key for that button.
+	Keys [8]string
+
+	SaveDir       string // battery .sav files; "" = next to the ROM
+	StateDir      string // .stateN slots; "" = next to the ROM
+	ScreenshotDir string // screenshots; "" = working directory
+
+	NoCheatAutoLoad bool // don't read <rom>.cht when a ROM is loaded
+
+	InputDisplay bool // start with the controller overlay on (Ctrl+I toggles)
+
+	// NTSC is where the TV controls (see tv.go) start when the NTSC
+	// palette is off; the zero value means ppu.DefaultNTSC. Switching the
+	// palette on at startup is the PPU's business (SetNTSC).
+	NTSC ppu.NTSC
+
+	// GDBPort, if non-zero, serves the GDB remote protocol on that
+	// localhost TCP port so a debugger can attach to the running game.
+	// GDBUndo is how many instructions it can step back through.
+	GDBPort int
+	GDBUndo int
+
+	// Trace keeps the last Trace instructions that pass TraceFilter in
+	// memory, to write out on demand or after a crash (see trace.go).
+	Trace       int
+	TraceFilter trace.Filter
+
+	// Watch reloads the ROM when its file changes, keeping WatchKeep
+	// (see watch.go).
+	Watch     bool
+	WatchKeep nes.SwapMode
+
+	// Autosave writes <rom>.autosave after this much play, on a ROM swap
+	// and on exit, and offers to resume it when the ROM is next opened
+	// (see autosave.go); 0 = off.
+	Autosave time.Duration
+
+	// Game, if set, gives a ROM opened in the window (drop, Ctrl+O) its
+	// per-game settings, and may rebuild its cartridge; an error refuses
+	// the ROM. The ROM given to NewNESGUI is already set up.
+	Game func(*cartridge.Cartridge) (*cartridge.Cartridge, GameSettings, error)
+}
+
+// GameSettings are the settings that follow the loaded ROM, from its game
+// file (see Options.Game).
+type GameSettings struct {
+	Overscan      Overscan
+	Palette       *[64][3]uint8 // nil is the built-in palette
+	NoSpriteLimit bool
+	FourScore     bool
+	Expansion     input.ExpansionDevice // nil for none
+	VSDIPs        uint8                 // VS. System games only
+	VSPPU         *ppu.Model            // nil: the PPU the header names
+	Region        apu.Region            // the APU's period tables
+}
+
+// Overscan is how many pixels to crop from each edge of the 256×240
+// picture. Many games leave garbage in the top and bottom 8 lines or the
+// left 8 columns, where a CRT never showed it.
+type Overscan struct {
+	Top, Bottom, Left, Right int
+}
+
+// size is the picture's size after cropping.
+func (o Overscan) size() (w, h int) {
+	return ppu.ScreenWidth - o.Left - o.Right, ppu.ScreenHeight - o.Top - o.Bottom
+}
+
+// crop copies the visible part of frame, a full 256×240 picture, into dst,
+// which holds size() pixels.
+func (o Overscan) crop(dst, frame []uint32) {
+	w, h := o.size()
+	for y := 0; y < h; y++ {
+		src := (y+o.Top)*ppu.ScreenWidth + o.Left
+		copy(dst[y*w:(y+1)*w], frame[src:src+w])
+	}
 }
 
 // NewNESGUI creates a new NES GUI. romPath is used to derive save-state file
 // names (<romPath-without-ext>.stateN); pass "" to disable save-state I/O.
-func NewNESGUI(nesSystem *nes.NES, romPath string) (*NESGUI, error) {
+// From here on the GUI owns the cartridge's battery save: Destroy writes
+// <rom>.sav for whichever ROM is loaded at exit.
+func NewNESGUI(nesSystem *nes.NES, romPath string, opts Options) (*NESGUI, error) {
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = WindowScale
+	}
+
 	// Lock main thread for SDL
 	runtime.LockOSThread()
 
@@ -91,22 +403,30 @@ func NewNESGUI(nesSystem *nes.NES, romPath string) (*NESGUI, error) {
 		return nil, err
 	}
 
-	// Create window
+	// Create window, sized to the picture after overscan cropping
+	viewW, viewH := opts.Overscan.size()
 	window, err := sdl.CreateWindow(
 		WindowTitle,
 		sdl.WINDOWPOS_UNDEFINED,
 		sdl.WINDOWPOS_UNDEFINED,
-		WindowWidth,
-		WindowHeight,
+		int32(viewW*scale),
+		int32(viewH*scale),
 		sdl.WINDOW_SHOWN,
 	)
 	if err != nil {
 		sdl.Quit()
 		return nil, err
 	}
+	setWindowIcon(window)
 
 	// Create renderer
-	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
+	rendererFlags := uint32(sdl.RENDERER_ACCELERATED)
+	if opts.Pacing == PacingVSync {
+		// Present waits for vblank; the emulator follows the audio clock
+		// (see waitForAudio), so neither side tears or judders.
+		rendererFlags |= sdl.RENDERER_PRESENTVSYNC
+	}
+	renderer, err := sdl.CreateRenderer(window, -1, rendererFlags)
 	if err != nil {
 		window.Destroy()
 		sdl.Quit()
@@ -122,8 +442,8 @@ func NewNESGUI(nesSystem *nes.NES, romPath string) (*NESGUI, error) {
 	texture, err := renderer.CreateTexture(
 		sdl.PIXELFORMAT_ARGB8888,
 		sdl.TEXTUREACCESS_STREAMING,
-		ppu.ScreenWidth,
-		ppu.ScreenHeight,
+		int32(viewW),
+		int32(viewH),
 	)
 	if err != nil {
 		renderer.Destroy()
@@ -143,12 +463,29 @@ func NewNESGUI(nesSystem *nes.NES, romPath string) (*NESGUI, error) {
 		nes:           nesSystem,
 		running:       true,
 		screenshotNum: 0,
-		lastFrameTime: time.Now(),
-		nextFrameTime: time.Now().Add(FrameTime),
 		fpsTimer:      time.Now(),
 		showFPS:       true,
-		textureBuf:    make([]uint32, ppu.ScreenWidth*ppu.ScreenHeight),
+		showInput:     opts.InputDisplay,
+		textureBuf:    make([]uint32, viewW*viewH),
+		frames:        newFrameBuffers(ppu.ScreenWidth * ppu.ScreenHeight),
+		frameReady:    make(chan struct{}, 1),
+		skip:          frameSkip{fixed: opts.FrameSkip, auto: opts.AutoFrameSkip},
+		resume:        make(chan struct{}, 1),
 		romPath:       romPath,
+		romName:       romTitle(nesSystem.Cartridge, romPath),
+		osd:           osd.New(),
+		opts:          opts,
+
+		audioQueueBufs: 2,
+	}
+
+	if opts.StatsCSV != "" {
+		gui.startStatsLog(opts.StatsCSV)
+	}
+	if opts.Filter != "" {
+		if err := gui.setFilter(opts.Filter); err != nil {
+			logger.LogError("Video filter: %v", err)
+		}
 	}
 
 	// Setup audio device
@@ -159,12 +496,54 @@ func NewNESGUI(nesSystem *nes.NES, romPath string) (*NESGUI, error) {
 	} else {
 		logger.LogInfo("Audio initialization successful")
 	}
+	if opts.Pacing == PacingVSync && gui.audioDevice == 0 {
+		logger.LogInfo("vsync pacing follows the audio clock; without audio, frames are timed as with hybrid pacing")
+	}
+
+	// The core delivers each frame's picture and sound to the GUI and
+	// polls the input manager's staging buffer for the buttons.
+	nesSystem.SetVideoSink(gui)
+	nesSystem.SetAudioSink(gui)
+	if opts.Volume > 0 {
+		nesSystem.APU.SetVolume(float32(opts.Volume) / 100)
+	}
+	nesSystem.APU.Muted = opts.Muted
 
 	// Initialize input manager
 	gui.inputManager = NewInputManager(nesSystem)
 	gui.inputManager.Initialize()
+	if err := gui.inputManager.SetKeyBindings(opts.Keys); err != nil {
+		logger.LogError("Key bindings: %v (keeping defaults)", err)
+	}
 
-	gui.loadCheats()
+	if !opts.NoCheatAutoLoad {
+		gui.loadCheats()
+	}
+	gui.rules = rules.NewEngine(nil)
+	gui.rules.OnEvent(gui.ruleFired)
+	gui.loadRules()
+	gui.loadSymbols()
+	gui.startTrace(opts.Trace, opts.TraceFilter)
+	if opts.Watch {
+		gui.watch = &romWatch{keep: opts.WatchKeep}
+	}
+
+	if opts.GDBPort != 0 {
+		if err := gui.startDebugger(opts.GDBPort, opts.GDBUndo); err != nil {
+			logger.LogError("GDB stub: %v", err)
+		}
+	}
+
+	gui.recent = loadRecentROMs(defaultRecentROMsPath())
+	if romPath != "" {
+		if abs, err := filepath.Abs(romPath); err == nil {
+			gui.romPath = abs
+		}
+		gui.recent.add(gui.romPath)
+	}
+	gui.instance, gui.instanceLock = claimInstance()
+	gui.updateWindowTitle()
+	gui.offerAutosave()
 
 	return gui, nil
 }
@@ -178,6 +557,21 @@ func (g *NESGUI) Destroy() {
 		}
 		g.recorder = nil
 	}
+	if g.profiler != nil {
+		g.toggleProfiler() // write the report
+	}
+
+	g.saveBattery()
+	g.writeAutosave()
+	g.saveVolume()
+	g.closeStatsLog()
+
+	if g.debugListener != nil {
+		g.debugListener.Close()
+	}
+	if g.instanceLock != nil {
+		g.instanceLock.Close()
+	}
 
 	// Close input devices
 	if g.inputManager != nil {
@@ -201,51 +595,85 @@ func (g *NESGUI) Destroy() {
 	sdl.Quit()
 }
 
-// Run starts the main GUI loop.
+// Run starts the emulation goroutine and runs the SDL side of the loop
+// until quit.
 //
-// Each iteration: poll events → step the NES → render (throttled in turbo)
-// → wait until the next frame deadline. The deadline is computed from
-// startTime + frameCount*FrameTime, so Sleep() overshoot doesn't drift the
-// framerate downward. Exiting turbo resets that baseline to avoid a
-// catch-up burst of zero-sleep frames.
+// Each iteration: poll events → hand pending samples to SDL → render the
+// newest finished frame (throttled in turbo) → sleep until the emulator
+// publishes another. Frame pacing lives in the emulation goroutine, so a
+// slow Present here delays only the picture, never the emulated frame or
+// the samples it produces. While paused no frames arrive, so the last one
+// is redrawn every pausedRedrawInterval to keep the window and OSD live.
 func (g *NESGUI) Run() {
-	frameCount := 0
-	startTime := time.Now()
-	wasTurbo := false
-
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go g.emulate(stop, done)
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	idle := time.NewTimer(idlePoll)
+	defer idle.Stop()
 	for g.running {
-		frameStart := time.Now()
-
 		g.handleEvents()
-		g.update()
+		g.checkROMWatch()
+		g.queueAudio()
 		if !g.turbo || time.Since(g.lastRenderTime) >= TurboRenderInterval {
-			g.render()
-			g.lastRenderTime = time.Now()
+			frame, fresh := g.frames.latest()
+			if fresh || g.isPaused() && time.Since(g.lastRenderTime) >= pausedRedrawInterval {
+				g.render(frame)
+				g.lastRenderTime = time.Now()
+			}
 		}
 
-		// When exiting turbo, reset the frame-pacing baseline so the limiter
-		// doesn't try to "catch up" by running the next several frames with
-		// zero sleep.
-		if wasTurbo && !g.turbo {
-			frameCount = 0
-			startTime = time.Now()
+		// The timeout keeps events and audio serviced if the emulator is
+		// stuck in a long frame (ROM swap, state load).
+		idle.Reset(idlePoll)
+		select {
+		case <-g.frameReady:
+			if !idle.Stop() {
+				<-idle.C
+			}
+		case <-idle.C:
 		}
-		wasTurbo = g.turbo
-
-		frameCount++
-		g.waitForNextFrame(startTime, frameStart, frameCount)
-
-		g.lastFrameTime = time.Now()
 	}
 }
 
-// handleEvents processes SDL events
+// handleEvents processes SDL events. Handlers reach into g.nes, so the
+// whole batch runs with the emulator held between frames.
 func (g *NESGUI) handleEvents() {
+	g.emuMu.Lock()
+	defer g.emuMu.Unlock()
 	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
 		switch e := event.(type) {
 		case *sdl.QuitEvent:
 			g.running = false
+		case *sdl.DropEvent:
+			g.handleDrop(e)
+		case *sdl.WindowEvent:
+			g.handleWindowEvent(e)
 		case *sdl.KeyboardEvent:
+			if g.autosaveOffer != nil {
+				g.handleAutosaveKey(e)
+				continue
+			}
+			if g.menu != nil {
+				g.handleMenuKey(e)
+				continue
+			}
+			if g.recentMenuOpen {
+				g.handleRecentMenuKey(e)
+				continue
+			}
+			if g.picker != nil {
+				g.handlePickerKey(e)
+				continue
+			}
+			if g.kbCapture {
+				g.handleKeyboardKey(e)
+				continue
+			}
 			if g.handleHotkey(e) {
 				continue
 			}
@@ -256,52 +684,103 @@ func (g *NESGUI) handleEvents() {
 	}
 }
 
-// update runs the NES emulation for one frame
+// handleWindowEvent pauses emulation while the window is in the background,
+// when Options.PauseInBackground asks for it.
+func (g *NESGUI) handleWindowEvent(e *sdl.WindowEvent) {
+	if !g.opts.PauseInBackground {
+		return
+	}
+	switch e.Event {
+	case sdl.WINDOWEVENT_FOCUS_LOST:
+		g.setPause(g.paused, true)
+	case sdl.WINDOWEVENT_FOCUS_GAINED:
+		g.setPause(g.paused, false)
+	}
+}
+
+// update runs the NES emulation for one frame. Called by the emulation
+// goroutine with emuMu held.
 func (g *NESGUI) update() {
-	// Track APU cycles before frame
-	apuCyclesBefore := g.nes.APU.Cycles
+	start := time.Now()
 
 	// Run NES for one frame (approximately 29780 CPU cycles)
 	g.nes.StepFrame()
-
-	if g.nes.Frame%60 == 0 && g.nes.Frame > 0 {
-		apuCyclesThisFrame := g.nes.APU.Cycles - apuCyclesBefore
-		samplesGenerated := len(g.nes.APU.Output)
-		// Expected: ~29780 cycles/frame, ~732 samples/frame at 44100 Hz
-		logger.LogDebug("Frame %d: APU cycles=%d (expected ~29780), samples=%d (expected ~732)",
-			g.nes.Frame, apuCyclesThisFrame, samplesGenerated)
+	g.playFrames++
+	if g.profiler != nil {
+		g.profiler.EndFrame()
+	}
+	if g.ppuLog != nil {
+		g.endPPULogFrame()
+	}
+	if g.chrDiff != nil {
+		g.endCHRFrame()
 	}
 
-	// Capture samples before queueAudio drains the buffer. Records raw APU
-	// output (no 0.5x volume scaling) so the file is directly comparable
-	// against other emulators' recordings for analysis.
-	if g.recorder != nil && len(g.nes.APU.Output) > 0 {
-		if err := g.recorder.WriteSamples(g.nes.APU.Output); err != nil {
-			logger.LogError("Recording: write failed: %v", err)
+	g.rules.Check(g.nes.Memory.Peek, g.nes.Frame)
+	g.checkAutosave()
+
+	if halted := g.nes.CPU.Halted(); halted != g.halted {
+		g.halted = halted
+		if info := g.nes.CPU.HaltInfo(); info != nil {
+			logger.LogError("%v", info)
+			g.notify("CPU halted: JAM $%02X at $%04X", info.Opcode, info.PC)
+			if g.tracer != nil && g.tracer.Len() > 0 {
+				g.writeTrace()
+			}
 		}
 	}
 
-	// Queue audio samples
-	g.queueAudio()
-
 	// Update FPS counter
 	g.updateFPS()
+	g.updateTime = time.Since(start)
+	if g.statsLog != nil {
+		g.logStats()
+	}
 }
 
-// render draws the current frame to the screen.
-func (g *NESGUI) render() {
-	copy(g.textureBuf, g.nes.GetDisplayFramebufferRaw())
-	g.texture.Update(nil, unsafe.Pointer(&g.textureBuf[0]), ppu.ScreenWidth*4)
+// ReceiveFrame implements core.VideoSink: the finished frame goes into the
+// triple buffer for the SDL thread to render, unless frame skip drops it.
+func (g *NESGUI) ReceiveFrame(frame []uint32) {
+	if g.hideFrame {
+		return
+	}
+	g.frames.publish(frame)
+}
+
+// ReceiveSamples implements core.AudioSink: the samples are recorded when
+// Ctrl+E is on and handed to the SDL thread, whose queueAudio drains the
+// ring into the device.
+func (g *NESGUI) ReceiveSamples(samples []float32) {
+	g.frameSamples = len(samples)
+	// Records the samples exactly as queued for SDL, volume included.
+	if g.recorder != nil && len(samples) > 0 {
+		if err := g.recorder.WriteSamples(samples); err != nil {
+			logger.LogError("Recording: write failed: %v", err)
+		}
+	}
+	g.audio.push(samples)
+}
+
+// render draws frame (from g.frames) to the screen, cropped to
+// Options.Overscan.
+func (g *NESGUI) render(frame []uint32) {
+	start := time.Now()
+	g.opts.Overscan.crop(g.textureBuf, frame)
+
+	// The FPS figure and turbo flag are written by the emulation goroutine.
+	g.emuMu.Lock()
+	g.drawOSD()
+	// Update window title with the game, FPS or the open recent-ROMs menu
+	g.updateWindowTitle()
+	g.emuMu.Unlock()
+
+	g.uploadFrame()
 
 	g.renderer.SetDrawColor(0, 0, 0, 255)
 	g.renderer.Clear()
 	g.renderer.Copy(g.texture, nil, nil)
 
-	// Update window title with FPS if enabled
-	if g.showFPS {
-		g.updateWindowTitle()
-	}
-
 	// Present the rendered frame
 	g.renderer.Present()
+	g.renderTime.Store(int64(time.Since(start)))
 }
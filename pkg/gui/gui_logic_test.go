@@ -2,27 +2,46 @@ package gui
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/apu"
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/cheat"
+	"github.com/yoshiomiyamaegones/pkg/chrdiff"
+	"github.com/yoshiomiyamaegones/pkg/core"
 	"github.com/yoshiomiyamaegones/pkg/input"
 	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/osd"
+	"github.com/yoshiomiyamaegones/pkg/ppu"
+	"github.com/yoshiomiyamaegones/pkg/rules"
+	"github.com/yoshiomiyamaegones/pkg/savestate"
+	"github.com/yoshiomiyamaegones/pkg/trace"
 )
 
 // newTestGUI builds a NESGUI with only the SDL-free fields populated. The
 // window/renderer/texture/audio handles stay nil — tests must not call methods
 // that touch them (NewNESGUI/Run/render/saveScreenshot/updateWindowTitle).
 func newTestGUI(romPath string) *NESGUI {
-	return &NESGUI{
+	g := &NESGUI{
 		nes:     nes.NewNES(),
 		romPath: romPath,
 		running: true,
 		showFPS: true,
 		fpsTimer: time.Now(),
+		osd:      osd.New(),
+		rules:    rules.NewEngine(nil),
 	}
+	g.rules.OnEvent(g.ruleFired)
+	return g
 }
 
 func keyEvent(sym sdl.Keycode, mod uint16, pressed bool, repeat uint8) *sdl.KeyboardEvent {
@@ -44,7 +63,7 @@ func TestInputKeyboardMapping(t *testing.T) {
 	system := nes.NewNES()
 	im := NewInputManager(system)
 	im.Initialize()
-	ctrl := system.GetInput()
+	ctrl := im.staging
 
 	keys := []struct {
 		sym  sdl.Keycode
@@ -61,19 +80,51 @@ func TestInputKeyboardMapping(t *testing.T) {
 	}
 	for _, k := range keys {
 		im.HandleEvent(keyEvent(k.sym, 0, true, 0))
-		if ctrl.GetButtons()&k.mask == 0 {
+		if uint8(ctrl.Buttons(0))&k.mask == 0 {
 			t.Errorf("key %d press: button mask %#02x not set", k.sym, k.mask)
 		}
+		if system.GetInput().Controller(0).GetButtons() != 0 {
+			t.Errorf("key %d press reached the controller before the frame latch", k.sym)
+		}
 		im.HandleEvent(keyEvent(k.sym, 0, false, 0))
-		if ctrl.GetButtons()&k.mask != 0 {
+		if uint8(ctrl.Buttons(0))&k.mask != 0 {
 			t.Errorf("key %d release: button mask %#02x still set", k.sym, k.mask)
 		}
 	}
 
 	// An unmapped key is ignored without affecting state.
 	im.HandleEvent(keyEvent(sdl.K_q, 0, true, 0))
-	if ctrl.GetButtons() != 0 {
-		t.Errorf("unmapped key changed state: %#02x", ctrl.GetButtons())
+	if ctrl.Buttons(0) != 0 {
+		t.Errorf("unmapped key changed state: %#02x", ctrl.Buttons(0))
+	}
+}
+
+func TestInputKeyBindings(t *testing.T) {
+	im := NewInputManager(nes.NewNES())
+	ctrl := im.staging
+
+	// Rebind A to J and Start to Return; the other buttons keep defaults.
+	if err := im.SetKeyBindings([8]string{"J", "", "", "Return"}); err != nil {
+		t.Fatalf("SetKeyBindings: %v", err)
+	}
+	im.HandleEvent(keyEvent(sdl.K_j, 0, true, 0))
+	im.HandleEvent(keyEvent(sdl.K_RETURN, 0, true, 0))
+	im.HandleEvent(keyEvent(sdl.K_x, 0, true, 0))
+	want := uint8(input.ButtonMaskA | input.ButtonMaskStart | input.ButtonMaskB)
+	if got := uint8(ctrl.Buttons(0)); got != want {
+		t.Errorf("buttons = %#02x, want %#02x", got, want)
+	}
+	im.HandleEvent(keyEvent(sdl.K_z, 0, true, 0)) // old A key is unbound
+	if got := uint8(ctrl.Buttons(0)); got != want {
+		t.Errorf("old A key still bound: %#02x", got)
+	}
+
+	// An unknown name is rejected and leaves the bindings alone.
+	if err := im.SetKeyBindings([8]string{"NoSuchKey"}); err == nil {
+		t.Error("unknown key name should be an error")
+	}
+	if im.keys[0] != sdl.K_j {
+		t.Errorf("failed rebind changed A to %d", im.keys[0])
 	}
 }
 
@@ -109,6 +160,18 @@ func TestInputDispatchAndSlots(t *testing.T) {
 	im.Cleanup()
 }
 
+func TestInputFourScoreSlots(t *testing.T) {
+	system := nes.NewNES()
+	im := NewInputManager(system)
+	if im.maxControllers() != 2 {
+		t.Errorf("maxControllers = %d without Four Score, want 2", im.maxControllers())
+	}
+	system.GetInput().SetFourScore(true)
+	if im.maxControllers() != 4 {
+		t.Errorf("maxControllers = %d with Four Score, want 4", im.maxControllers())
+	}
+}
+
 // --- recorder.go ---
 
 func TestWAVRecorderRoundTrip(t *testing.T) {
@@ -191,6 +254,11 @@ func TestStateSlotsRoundTrip(t *testing.T) {
 	if got := g.stateSlotPath(3); got != filepath.Join(dir, "game.state3") {
 		t.Errorf("stateSlotPath = %q", got)
 	}
+	g.opts.StateDir = filepath.Join(dir, "states")
+	if got := g.stateSlotPath(3); got != filepath.Join(dir, "states", "game.state3") {
+		t.Errorf("stateSlotPath with StateDir = %q", got)
+	}
+	g.opts.StateDir = ""
 
 	g.saveStateSlot(1)
 	if _, err := os.Stat(g.stateSlotPath(1)); err != nil {
@@ -207,6 +275,29 @@ func TestStateSlotsRoundTrip(t *testing.T) {
 	g.loadStateSlot(9)
 }
 
+// A slot saved into a state directory that doesn't exist yet gets it
+// made, with metadata beside it whose play time comes back on load.
+func TestStateSlotMetadata(t *testing.T) {
+	dir := t.TempDir()
+	g := newTestGUI(filepath.Join(dir, "game.nes"))
+	g.opts.StateDir = filepath.Join(dir, "saves")
+	g.playFrames = 3600
+	g.saveStateSlot(2)
+	m, err := savestate.Read(g.stateSlotPath(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.PlayTime != 3600*FrameTime || len(m.Thumbnail) == 0 || time.Since(m.Saved) > time.Minute {
+		t.Errorf("metadata = %v, %v, %d-byte thumbnail", m.Saved, m.PlayTime, len(m.Thumbnail))
+	}
+
+	g.playFrames = 10
+	g.loadStateSlot(2)
+	if g.playFrames != 3600 {
+		t.Errorf("playFrames = %d after load, want 3600", g.playFrames)
+	}
+}
+
 func TestLoadCheats(t *testing.T) {
 	dir := t.TempDir()
 	romPath := filepath.Join(dir, "game.nes")
@@ -230,6 +321,62 @@ func TestLoadCheats(t *testing.T) {
 	newTestGUI("").loadCheats()
 }
 
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	romPath := filepath.Join(dir, "game.nes")
+	data := `[{"name": "Ten", "message": "Reached ten", "conditions": [{"address": "$0010", "value": 10}]}]`
+	if err := os.WriteFile(filepath.Join(dir, "game.rules.json"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g := newTestGUI(romPath)
+	g.loadRules()
+	if n := len(g.rules.Rules()); n != 1 {
+		t.Fatalf("loaded %d rules, want 1", n)
+	}
+
+	g.nes.Memory.RAM[0x10] = 10
+	g.rules.Check(g.nes.Memory.Peek, 1)
+	if msgs := g.osd.Messages(); len(msgs) != 1 || msgs[0] != "Reached ten" {
+		t.Errorf("OSD messages = %v, want [Reached ten]", msgs)
+	}
+
+	// Another ROM without a rule file drops the old rules.
+	g.romPath = filepath.Join(dir, "other.nes")
+	g.loadRules()
+	if n := len(g.rules.Rules()); n != 0 {
+		t.Errorf("%d rules left after loading a ROM without a rule file", n)
+	}
+}
+
+func TestLoadSymbols(t *testing.T) {
+	dir := t.TempDir()
+	romPath := filepath.Join(dir, "Game (U) [!].nes")
+	for name, data := range map[string]string{
+		"Game (U) [!].fns":      "Reset = $C000\n",
+		"Game (U) [!].nes.2.nl": "$8000#Bank2#\n",
+		"Game (U) [!].nes.3.nl": "oops\n",
+		"Game.nes.4.nl":         "$8000#OtherROM#\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := newTestGUI(romPath)
+	g.loadSymbols()
+	if g.symbols.Len() != 2 {
+		t.Fatalf("loaded %v, want Reset and Bank2", g.symbols.Symbols())
+	}
+	if s, ok := g.symbols.Lookup(0x8000, 2*0x4000); !ok || s.Name != "Bank2" {
+		t.Errorf("bank 2 symbol = %+v, %v", s, ok)
+	}
+
+	g.romPath = filepath.Join(dir, "other.nes")
+	g.loadSymbols()
+	if g.symbols != nil {
+		t.Error("symbols kept after loading a ROM without symbol files")
+	}
+}
+
 func TestSaveFramebufferAsRaw(t *testing.T) {
 	g := newTestGUI("")
 	path := filepath.Join(t.TempDir(), "fb.raw")
@@ -251,10 +398,14 @@ func TestSaveFramebufferAsRaw(t *testing.T) {
 func TestHotkeySimpleActions(t *testing.T) {
 	g := newTestGUI("")
 
-	if !g.handleHotkey(keyEvent(sdl.K_ESCAPE, 0, true, 0)) || g.running {
-		t.Error("Esc should quit (running=false) and be consumed")
+	if !g.handleHotkey(keyEvent(sdl.K_q, sdl.KMOD_CTRL, true, 0)) || g.running {
+		t.Error("Ctrl+Q should quit (running=false) and be consumed")
 	}
 	g.running = true
+	if !g.handleHotkey(keyEvent(sdl.K_ESCAPE, 0, true, 0)) || g.menu == nil || !g.running {
+		t.Error("Esc should open the menu, not quit, and be consumed")
+	}
+	g.closeMenu()
 
 	if !g.handleHotkey(keyEvent(sdl.K_TAB, 0, true, 0)) || !g.turbo {
 		t.Error("Tab should enable turbo and be consumed")
@@ -271,6 +422,10 @@ func TestHotkeySimpleActions(t *testing.T) {
 	if !g.handleHotkey(keyEvent(sdl.K_r, sdl.KMOD_CTRL, true, 0)) {
 		t.Error("Ctrl+R (reset) should be consumed")
 	}
+	g.nes.Memory.RAM[0x10] = 0x42
+	if !g.handleHotkey(keyEvent(sdl.K_p, sdl.KMOD_CTRL, true, 0)) || g.nes.Memory.RAM[0x10] != 0 {
+		t.Error("Ctrl+P (power cycle) should clear RAM and be consumed")
+	}
 	if !g.handleHotkey(keyEvent(sdl.K_h, sdl.KMOD_CTRL, true, 0)) {
 		t.Error("Ctrl+H (cheats) should be consumed")
 	}
@@ -302,6 +457,176 @@ func TestHotkeyChannelMute(t *testing.T) {
 	}
 }
 
+func TestHotkeyVolume(t *testing.T) {
+	g := newTestGUI("")
+	apu := g.nes.APU
+	if !g.handleHotkey(keyEvent(sdl.K_MINUS, 0, true, 0)) || volumePercent(apu.Volume) != 90 {
+		t.Errorf("- should lower the volume to 90%%, got %d%%", volumePercent(apu.Volume))
+	}
+	// Volume keys auto-repeat; the floor is 0.
+	for i := 0; i < 12; i++ {
+		g.handleHotkey(keyEvent(sdl.K_KP_MINUS, 0, true, 1))
+	}
+	if apu.Volume != 0 {
+		t.Errorf("volume after holding - = %v, want 0", apu.Volume)
+	}
+	if !g.handleHotkey(keyEvent(sdl.K_m, sdl.KMOD_CTRL, true, 0)) || !apu.Muted {
+		t.Error("Ctrl+M should mute and be consumed")
+	}
+	// Changing the volume unmutes.
+	if !g.handleHotkey(keyEvent(sdl.K_EQUALS, 0, true, 0)) || apu.Muted || volumePercent(apu.Volume) != 10 {
+		t.Errorf("= should unmute at 10%%, got muted=%v %d%%", apu.Muted, volumePercent(apu.Volume))
+	}
+	if g.handleHotkey(keyEvent(sdl.K_m, 0, true, 0)) {
+		t.Error("M without Ctrl should not match the mute hotkey")
+	}
+}
+
+func TestSaveVolume(t *testing.T) {
+	g := newTestGUI("")
+	calls := 0
+	var volume int
+	var muted bool
+	g.opts.SaveVolume = func(v int, m bool) error {
+		calls++
+		volume, muted = v, m
+		return nil
+	}
+	g.saveVolume()
+	if calls != 0 {
+		t.Error("saved a volume the hotkeys never changed")
+	}
+	g.handleHotkey(keyEvent(sdl.K_MINUS, 0, true, 0))
+	g.handleHotkey(keyEvent(sdl.K_m, sdl.KMOD_CTRL, true, 0))
+	g.saveVolume()
+	if calls != 1 || volume != 90 || !muted {
+		t.Errorf("%d saves, last %d%% muted=%v; want one of 90%% muted", calls, volume, muted)
+	}
+	g.saveVolume()
+	if calls != 1 {
+		t.Error("saved again with nothing changed")
+	}
+}
+
+// fme7Chip stands in for a cartridge's 5B.
+type fme7Chip struct{}
+
+func (fme7Chip) AudioSample() float32 { return 0.1 }
+func (fme7Chip) AudioChip() string    { return apu.ChipFME7 }
+
+func TestHotkeyExpansionAudio(t *testing.T) {
+	g := newTestGUI("")
+	a := g.nes.APU
+	if !g.handleHotkey(keyEvent(sdl.K_EQUALS, sdl.KMOD_CTRL, true, 0)) || a.Volume != 1 {
+		t.Error("Ctrl+= without a chip should be consumed and leave the master volume")
+	}
+	a.SetExpansionAudio(fme7Chip{})
+	g.handleHotkey(keyEvent(sdl.K_EQUALS, sdl.KMOD_CTRL, true, 0))
+	g.handleHotkey(keyEvent(sdl.K_KP_PLUS, sdl.KMOD_CTRL, true, 1))
+	if got := a.ExpansionLevel(apu.ChipFME7); volumePercent(got) != 120 || a.Sources[0].Gain != got {
+		t.Errorf("5B level = %v, want 1.2 in ExpansionLevels and on the source", got)
+	}
+	if !g.handleHotkey(keyEvent(sdl.K_6, sdl.KMOD_CTRL, true, 0)) || !a.NESAudio || a.ExpansionMuted {
+		t.Error("Ctrl+6 should switch to NES audio, not mute the chip")
+	}
+	if !g.handleHotkey(keyEvent(sdl.K_6, 0, true, 0)) || !a.ExpansionMuted {
+		t.Error("6 should still mute the chip")
+	}
+}
+
+func TestHotkeyTVControls(t *testing.T) {
+	g := newTestGUI("")
+	pm := g.nes.PPU.PaletteManager
+	g.opts.NTSC = ppu.DefaultNTSC
+	g.opts.NTSC.Hue = 10
+
+	// ] turns hue up from Options.NTSC and switches the palette on.
+	if !g.handleHotkey(keyEvent(sdl.K_RIGHTBRACKET, 0, true, 0)) {
+		t.Fatal("] not consumed")
+	}
+	if n := pm.NTSC(); n == nil || n.Hue != 15 {
+		t.Fatalf("after ] NTSC = %+v, want hue 15", n)
+	}
+	// 9 moves to saturation; [ held down turns it, clamped at 0.
+	g.handleHotkey(keyEvent(sdl.K_9, 0, true, 0))
+	for i := 0; i < 30; i++ {
+		g.handleHotkey(keyEvent(sdl.K_LEFTBRACKET, 0, true, 1))
+	}
+	if n := pm.NTSC(); n.Saturation != 0 || n.Hue != 15 {
+		t.Errorf("after 30× [ on saturation NTSC = %+v, want saturation 0, hue 15", n)
+	}
+	for i := 0; i < 4; i++ {
+		g.handleHotkey(keyEvent(sdl.K_9, 0, true, 0))
+	}
+	if g.tvControl != 0 {
+		t.Errorf("five presses of 9 land on control %d, want hue again", g.tvControl)
+	}
+	if !g.handleHotkey(keyEvent(sdl.K_LEFTBRACKET, 0, false, 0)) {
+		t.Error("[ release should be consumed")
+	}
+}
+
+func TestHotkeyDiskSide(t *testing.T) {
+	g := newTestGUI("")
+	if !g.handleHotkey(keyEvent(sdl.K_d, sdl.KMOD_CTRL, true, 0)) {
+		t.Fatal("Ctrl+D should be consumed even without a disk")
+	}
+	img := make([]byte, 2*65500)
+	copy(img, "\x01*NINTENDO-HVC*")
+	copy(img[65500:], "\x01*NINTENDO-HVC*")
+	cart, err := cartridge.LoadFDS(img, make([]byte, cartridge.FDSBIOSSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.nes.LoadCartridge(cart)
+	for _, want := range []int{1, 0} {
+		g.handleHotkey(keyEvent(sdl.K_d, sdl.KMOD_CTRL, true, 0))
+		if got := cart.Disk().DiskSide(); got != want {
+			t.Errorf("after Ctrl+D the drive has side %d, want %d", got, want)
+		}
+	}
+}
+
+func TestHotkeyInsertCoin(t *testing.T) {
+	g := newTestGUI("")
+	if !g.handleHotkey(keyEvent(sdl.K_c, sdl.KMOD_CTRL, true, 0)) {
+		t.Fatal("Ctrl+C should be consumed even on a console")
+	}
+	vs := input.NewVSPanel(0)
+	g.nes.GetInput().SetVS(vs)
+	g.handleHotkey(keyEvent(sdl.K_c, sdl.KMOD_CTRL, true, 0))
+	if g.nes.GetInput().Read(0)&0x20 == 0 {
+		t.Error("Ctrl+C should close coin switch 1")
+	}
+}
+
+func TestHotkeyCHRViewer(t *testing.T) {
+	g := newTestGUI("")
+	g.handleHotkey(keyEvent(sdl.K_b, sdl.KMOD_CTRL, true, 0))
+	if g.chrDiff == nil {
+		t.Fatal("Ctrl+B should start the CHR viewer")
+	}
+	g.endCHRFrame()
+	g.handleHotkey(keyEvent(sdl.K_b, sdl.KMOD_CTRL, true, 0))
+	if g.chrDiff != nil || g.osd.Persistent(osdKeyCHR) != "" {
+		t.Error("a second Ctrl+B should stop it and clear its line")
+	}
+
+	var slots [chrdiff.Slots]chrdiff.Slot
+	if got := chrCaption(&slots); got != "" {
+		t.Errorf("caption with no writes = %q", got)
+	}
+	bank := chrdiff.Write{PC: 0xC123, Address: 0x8001, Value: 4}
+	for s := 0; s < 4; s++ {
+		slots[s].Writes = []chrdiff.Write{bank}
+	}
+	slots[5].Writes = []chrdiff.Write{{PC: 0xC5A0, Address: 0x2007, Target: 0x1400}}
+	slots[5].More = 63
+	if got, want := chrCaption(&slots), "CHR 0-3 $8001=$04@C123 5 $2007x64@C5A0"; got != want {
+		t.Errorf("caption = %q, want %q", got, want)
+	}
+}
+
 func TestHotkeyStateSlots(t *testing.T) {
 	dir := t.TempDir()
 	g := newTestGUI(filepath.Join(dir, "game.nes"))
@@ -336,7 +661,7 @@ func TestHotkeyReleaseConsumption(t *testing.T) {
 }
 
 func TestIsHotkeyKey(t *testing.T) {
-	for _, k := range []sdl.Keycode{sdl.K_ESCAPE, sdl.K_TAB, sdl.K_F1, sdl.K_F12, sdl.K_1, sdl.K_8} {
+	for _, k := range []sdl.Keycode{sdl.K_ESCAPE, sdl.K_TAB, sdl.K_F1, sdl.K_F12, sdl.K_1, sdl.K_8, sdl.K_MINUS, sdl.K_KP_PLUS} {
 		if !isHotkeyKey(k) {
 			t.Errorf("isHotkeyKey(%d) = false, want true", k)
 		}
@@ -367,19 +692,1403 @@ func TestWaitForNextFrame(t *testing.T) {
 	g := newTestGUI("")
 
 	// Turbo: must return effectively immediately (no frame-pacing sleep).
-	g.turbo = true
 	t0 := time.Now()
-	g.waitForNextFrame(t0, t0, 1)
+	g.waitForNextFrame(t0, 1, true)
 	if elapsed := time.Since(t0); elapsed > 50*time.Millisecond {
 		t.Errorf("turbo waitForNextFrame slept %v, want ~0", elapsed)
 	}
 
 	// Non-turbo with a deadline already an hour in the past: also no sleep.
-	// frameCount=60 exercises the periodic timing-deviation log branch.
-	g.turbo = false
 	t1 := time.Now()
-	g.waitForNextFrame(t1.Add(-time.Hour), t1, 60)
+	g.waitForNextFrame(t1.Add(-time.Hour), 60, false)
 	if elapsed := time.Since(t1); elapsed > 50*time.Millisecond {
 		t.Errorf("past-deadline waitForNextFrame slept %v, want ~0", elapsed)
 	}
 }
+
+func TestSleepUntil(t *testing.T) {
+	for _, spin := range []bool{false, true} {
+		deadline := time.Now().Add(3 * time.Millisecond)
+		sleepUntil(deadline, spin)
+		if late := time.Since(deadline); late < 0 || late > 50*time.Millisecond {
+			t.Errorf("spin=%v: returned %v after the deadline", spin, late)
+		}
+	}
+	// A deadline already past returns at once either way.
+	t0 := time.Now()
+	sleepUntil(t0.Add(-time.Second), true)
+	if elapsed := time.Since(t0); elapsed > 50*time.Millisecond {
+		t.Errorf("past deadline slept %v", elapsed)
+	}
+}
+
+func TestFrameSkip(t *testing.T) {
+	pattern := func(s frameSkip, behind []bool) string {
+		var out strings.Builder
+		for _, b := range behind {
+			if s.show(b) {
+				out.WriteByte('#')
+			} else {
+				out.WriteByte('.')
+			}
+		}
+		return out.String()
+	}
+	ontime := make([]bool, 8)
+	late := []bool{true, true, true, true, true, true, true, false, false}
+	for _, tc := range []struct {
+		s      frameSkip
+		behind []bool
+		want   string
+	}{
+		{frameSkip{}, ontime, "########"},
+		{frameSkip{fixed: 2}, ontime, "..#..#.."},
+		{frameSkip{auto: true}, ontime, "########"},
+		{frameSkip{auto: true}, late, "....#..##"},
+		{frameSkip{fixed: 1, auto: true}, late, "....#..#."},
+	} {
+		if got := pattern(tc.s, tc.behind); got != tc.want {
+			t.Errorf("%+v: drew %s, want %s", tc.s, got, tc.want)
+		}
+	}
+
+	// Frames frame skip drops never reach the renderer.
+	g := newTestGUI("")
+	g.frames = newFrameBuffers(4)
+	g.hideFrame = true
+	g.ReceiveFrame([]uint32{1, 2, 3, 4})
+	if _, fresh := g.frames.latest(); fresh {
+		t.Error("hidden frame published")
+	}
+	g.hideFrame = false
+	g.ReceiveFrame([]uint32{1, 2, 3, 4})
+	if _, fresh := g.frames.latest(); !fresh {
+		t.Error("shown frame not published")
+	}
+}
+
+func TestAudioLead(t *testing.T) {
+	g := newTestGUI("")
+	g.audioSpec = &sdl.AudioSpec{Freq: 44100, Samples: 1024}
+	// Two 1024-sample buffers (~46ms) less a frame.
+	if got := g.audioLead(); got < 29*time.Millisecond || got > 30*time.Millisecond {
+		t.Errorf("default lead = %v, want ~29.8ms", got)
+	}
+	g.opts.AudioLatency = 100 * time.Millisecond
+	if got, want := g.audioLead(), 100*time.Millisecond-FrameTime; got != want {
+		t.Errorf("lead with -audio-latency 100 = %v, want %v", got, want)
+	}
+	g.opts.AudioLatency = 10 * time.Millisecond
+	if got := g.audioLead(); got != FrameTime {
+		t.Errorf("lead never drops below a frame, got %v", got)
+	}
+	// vsync pacing needs the device to follow.
+	g.opts.Pacing = PacingVSync
+	if g.audioClocked() {
+		t.Error("audio-clocked without an audio device")
+	}
+}
+
+// --- gui.go ---
+
+func TestOverscanCrop(t *testing.T) {
+	frame := make([]uint32, ppu.ScreenWidth*ppu.ScreenHeight)
+	for i := range frame {
+		frame[i] = uint32(i) // pixel value = its index in the raw frame
+	}
+	o := Overscan{Top: 8, Bottom: 16, Left: 4, Right: 2}
+	w, h := o.size()
+	if w != 250 || h != 216 {
+		t.Fatalf("size = %dx%d, want 250x216", w, h)
+	}
+	dst := make([]uint32, w*h)
+	o.crop(dst, frame)
+	for _, p := range [][2]int{{0, 0}, {w - 1, 0}, {0, h - 1}, {w - 1, h - 1}} {
+		want := uint32((p[1]+8)*ppu.ScreenWidth + p[0] + 4)
+		if got := dst[p[1]*w+p[0]]; got != want {
+			t.Errorf("cropped (%d,%d) = raw %d, want raw %d", p[0], p[1], got, want)
+		}
+	}
+
+	var none Overscan
+	if w, h := none.size(); w != ppu.ScreenWidth || h != ppu.ScreenHeight {
+		t.Errorf("zero Overscan size = %dx%d", w, h)
+	}
+}
+
+// --- recent.go ---
+
+// writeTestROM writes a minimal NROM image (16KB PRG, 8KB CHR) to dir/name.
+// battery sets flags6 bit 1 so the swap path exercises .sav handling.
+func writeTestROM(t *testing.T, dir, name string, battery bool) string {
+	t.Helper()
+	rom := make([]byte, 16+16384+8192)
+	copy(rom, "NES\x1A")
+	rom[4], rom[5] = 1, 1
+	if battery {
+		rom[6] = 0x02
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, rom, 0o644); err != nil {
+		t.Fatalf("write rom: %v", err)
+	}
+	return path
+}
+
+func TestRecentROMsAddPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cfg", "recent_roms.txt")
+	r := loadRecentROMs(path) // missing file: empty list
+	if len(r.entries) != 0 {
+		t.Fatalf("fresh list has %d entries", len(r.entries))
+	}
+	for i := 0; i < maxRecentROMs+3; i++ {
+		r.add(filepath.Join("/roms", string(rune('a'+i))+".nes"))
+	}
+	r.add("/roms/c.nes") // re-adding moves to front without duplicating
+
+	if len(r.entries) != maxRecentROMs {
+		t.Fatalf("len = %d, want cap %d", len(r.entries), maxRecentROMs)
+	}
+	if r.entries[0] != "/roms/c.nes" {
+		t.Errorf("front = %q, want /roms/c.nes", r.entries[0])
+	}
+	seen := map[string]bool{}
+	for _, e := range r.entries {
+		if seen[e] {
+			t.Errorf("duplicate entry %q", e)
+		}
+		seen[e] = true
+	}
+
+	reloaded := loadRecentROMs(path)
+	if len(reloaded.entries) != len(r.entries) || reloaded.entries[0] != r.entries[0] {
+		t.Errorf("reloaded = %v, want %v", reloaded.entries, r.entries)
+	}
+}
+
+func TestLoadROMSwapsCartridge(t *testing.T) {
+	dir := t.TempDir()
+	first := writeTestROM(t, dir, "first.nes", true)
+	second := writeTestROM(t, dir, "second.nes", false)
+
+	g := newTestGUI("")
+	g.recent = loadRecentROMs(filepath.Join(dir, "recent.txt"))
+	if err := g.loadROM(first); err != nil {
+		t.Fatalf("loadROM(first): %v", err)
+	}
+	g.nes.Cartridge.PRGRAM[0] = 0x5A
+	g.nes.Cheats.Add(cheat.Cheat{Address: 0x0000, Value: 1})
+
+	g.handleDrop(&sdl.DropEvent{Type: sdl.DROPFILE, File: second})
+	if g.romPath != second {
+		t.Errorf("romPath = %q, want %q", g.romPath, second)
+	}
+	if g.nes.Cheats.Count() != 0 {
+		t.Error("cheats for the previous ROM should be cleared on swap")
+	}
+	// The outgoing battery cart was flushed to its .sav.
+	sav, err := os.ReadFile(filepath.Join(dir, "first.sav"))
+	if err != nil || sav[0] != 0x5A {
+		t.Errorf("first.sav not written on swap (err=%v)", err)
+	}
+	if g.recent.entries[0] != second || g.recent.entries[1] != first {
+		t.Errorf("recent = %v, want [second first]", g.recent.entries)
+	}
+
+	// A bad file leaves the running cart alone.
+	bad := filepath.Join(dir, "bad.nes")
+	os.WriteFile(bad, []byte("nope"), 0o644)
+	cart := g.nes.Cartridge
+	if err := g.loadROM(bad); err == nil {
+		t.Error("loadROM(bad) should fail")
+	}
+	if g.nes.Cartridge != cart || g.romPath != second {
+		t.Error("failed load must not replace the running cartridge")
+	}
+}
+
+func TestLoadROMAppliesGameSettings(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestROM(t, dir, "game.nes", false)
+
+	g := newTestGUI("")
+	palette := ppu.MasterPalette()
+	g.opts.Game = func(cart *cartridge.Cartridge) (*cartridge.Cartridge, GameSettings, error) {
+		return cart, GameSettings{Palette: &palette, NoSpriteLimit: true, FourScore: true}, nil
+	}
+	if err := g.loadROM(path); err != nil {
+		t.Fatalf("loadROM: %v", err)
+	}
+	if !g.nes.PPU.NoSpriteLimit || !g.nes.GetInput().FourScore() {
+		t.Error("game settings not applied")
+	}
+
+	g.opts.Game = func(*cartridge.Cartridge) (*cartridge.Cartridge, GameSettings, error) {
+		return nil, GameSettings{}, errors.New("bad game file")
+	}
+	cart := g.nes.Cartridge
+	if err := g.loadROM(path); err == nil || g.nes.Cartridge != cart {
+		t.Errorf("loadROM with a bad game file = %v; the running cart must stay", err)
+	}
+}
+
+func TestLoadROMAppliesPatch(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestROM(t, dir, "game.nes", false)
+	// Set the first PRG byte, just past the 16-byte header.
+	ips := append([]byte("PATCH\x00\x00\x10\x00\x01\xEA"), "EOF"...)
+	if err := os.WriteFile(filepath.Join(dir, "game.ips"), ips, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	g := newTestGUI("")
+	if err := g.loadROM(path); err != nil {
+		t.Fatalf("loadROM: %v", err)
+	}
+	if got := g.nes.Cartridge.PRGROM[0]; got != 0xEA {
+		t.Errorf("PRG[0] = %02X, want the patch's EA", got)
+	}
+
+	// A patch that doesn't apply keeps the running game.
+	if err := os.WriteFile(filepath.Join(dir, "game.ips"), []byte("PATCH\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cart := g.nes.Cartridge
+	if err := g.loadROM(path); err == nil || g.nes.Cartridge != cart {
+		t.Errorf("loadROM with a damaged patch = %v; the running cart must stay", err)
+	}
+}
+
+func TestRecentMenuNavigation(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTestROM(t, dir, "a.nes", false)
+	b := writeTestROM(t, dir, "b.nes", false)
+
+	g := newTestGUI("")
+	g.recent = &recentROMs{entries: []string{a, b}}
+
+	// Ctrl+O through the hotkey table opens the menu on the previous ROM.
+	g.handleHotkey(keyEvent(sdl.K_o, sdl.KMOD_CTRL, true, 0))
+	if !g.recentMenuOpen || g.recentMenuIndex != 1 {
+		t.Fatalf("menu open=%v index=%d, want open at 1", g.recentMenuOpen, g.recentMenuIndex)
+	}
+	g.handleRecentMenuKey(keyEvent(sdl.K_DOWN, 0, true, 0))
+	if g.recentMenuIndex != 0 {
+		t.Errorf("Down wraps to 0, got %d", g.recentMenuIndex)
+	}
+	// Esc closes the menu instead of quitting.
+	g.handleRecentMenuKey(keyEvent(sdl.K_ESCAPE, 0, true, 0))
+	if g.recentMenuOpen || !g.running {
+		t.Errorf("Esc: open=%v running=%v, want closed and still running", g.recentMenuOpen, g.running)
+	}
+
+	g.openRecentMenu()
+	g.handleRecentMenuKey(keyEvent(sdl.K_RETURN, 0, true, 0))
+	if g.recentMenuOpen || g.romPath != b {
+		t.Errorf("Enter: open=%v romPath=%q, want closed with %q loaded", g.recentMenuOpen, g.romPath, b)
+	}
+
+	// Empty list: Ctrl+O is a logged no-op.
+	g = newTestGUI("")
+	g.recent = &recentROMs{}
+	g.openRecentMenu()
+	if g.recentMenuOpen {
+		t.Error("menu should not open with an empty list")
+	}
+}
+
+// --- osd.go ---
+
+func TestNotifyAndDrawOSD(t *testing.T) {
+	g := newTestGUI("")
+	g.textureBuf = make([]uint32, 256*240)
+
+	g.toggleTurbo()
+	if msgs := g.osd.Messages(); len(msgs) != 1 || msgs[0] != "Turbo: ON" {
+		t.Fatalf("OSD messages = %v, want [Turbo: ON]", msgs)
+	}
+
+	g.showFPS = true
+	g.currentFPS = 60
+	g.drawOSD()
+	if got := g.osd.Persistent(osdKeyFPS); got != "60.0 FPS TURBO" {
+		t.Errorf("FPS line = %q", got)
+	}
+	drawn := false
+	for _, px := range g.textureBuf {
+		if px != 0 {
+			drawn = true
+			break
+		}
+	}
+	if !drawn {
+		t.Error("drawOSD left textureBuf untouched")
+	}
+
+	g.showFPS = false
+	g.drawOSD()
+	if g.osd.Persistent(osdKeyFPS) != "" {
+		t.Error("FPS line should clear when display is off")
+	}
+}
+
+func TestInputDisplay(t *testing.T) {
+	g := newTestGUI("")
+	g.textureBuf = make([]uint32, 256*240)
+	corner := (240-8)*256 + 250 // inside player 2's pad
+	g.drawOSD()
+	if g.textureBuf[corner] != 0 {
+		t.Fatal("pads drawn with the input display off")
+	}
+
+	if !g.handleHotkey(keyEvent(sdl.K_i, sdl.KMOD_CTRL, true, 0)) || !g.showInput {
+		t.Fatal("Ctrl+I should turn the input display on and be consumed")
+	}
+	g.nes.Input.SetButton(0, 0, true)
+	g.drawOSD()
+	if g.textureBuf[corner] == 0 {
+		t.Error("input display left the corner untouched")
+	}
+	if len(g.padBuf) != 2 || g.padBuf[0] != core.ButtonA {
+		t.Errorf("pads drawn from %v, want [A, none]", g.padBuf)
+	}
+}
+
+func TestRecentMenuOSDLine(t *testing.T) {
+	g := newTestGUI("")
+	g.recent = &recentROMs{entries: []string{"/roms/a.nes", "/roms/b.nes"}}
+	g.openRecentMenu()
+	if got := g.osd.Persistent(osdKeyMenu); got != "Recent [2/2] b.nes" {
+		t.Errorf("menu line = %q", got)
+	}
+	g.handleRecentMenuKey(keyEvent(sdl.K_ESCAPE, 0, true, 0))
+	if g.osd.Persistent(osdKeyMenu) != "" || g.osd.Persistent(osdKeyMenuHelp) != "" {
+		t.Error("closing the menu should clear its OSD lines")
+	}
+}
+
+// --- emu.go ---
+
+// The GUI is the core's video and audio sink: StepFrame's output lands in
+// the triple buffer and the sample ring.
+func TestGUIAsCoreSinks(t *testing.T) {
+	g := newTestGUI("")
+	g.frames = newFrameBuffers(ppu.ScreenWidth * ppu.ScreenHeight)
+	g.nes.SetVideoSink(g)
+	g.nes.SetAudioSink(g)
+
+	g.nes.StepFrame()
+	if _, fresh := g.frames.latest(); !fresh {
+		t.Error("StepFrame didn't publish a frame")
+	}
+	if g.audio.len() == 0 || g.audio.len() != g.frameSamples {
+		t.Errorf("ring holds %d samples, frame produced %d", g.audio.len(), g.frameSamples)
+	}
+	if len(g.nes.APU.Output) != 0 {
+		t.Error("APU.Output should be drained into the ring")
+	}
+}
+
+// While paused the emulation goroutine parks on resume without stepping;
+// the P hotkey releases it.
+func TestPauseHoldsEmulation(t *testing.T) {
+	g := newTestGUI("")
+	g.frameReady = make(chan struct{}, 1)
+	g.resume = make(chan struct{}, 1)
+	if !g.handleHotkey(keyEvent(sdl.K_p, 0, true, 0)) || !g.paused {
+		t.Fatal("P should pause and be consumed")
+	}
+	if g.osd.Messages()[0] != "Pause: ON" {
+		t.Errorf("OSD = %v", g.osd.Messages())
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go g.emulate(stop, done)
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	g.emuMu.Lock()
+	frame := g.nes.Frame
+	g.handleHotkey(keyEvent(sdl.K_p, 0, true, 0))
+	g.emuMu.Unlock()
+	if frame != 0 {
+		t.Errorf("emulated %d frames while paused", frame)
+	}
+	select {
+	case <-g.frameReady:
+	case <-time.After(time.Second):
+		t.Fatal("no frame after resuming")
+	}
+}
+
+func TestPauseInBackground(t *testing.T) {
+	g := newTestGUI("")
+	lost := &sdl.WindowEvent{Event: sdl.WINDOWEVENT_FOCUS_LOST}
+	gained := &sdl.WindowEvent{Event: sdl.WINDOWEVENT_FOCUS_GAINED}
+
+	g.handleWindowEvent(lost)
+	if g.isPaused() {
+		t.Error("focus loss paused without PauseInBackground")
+	}
+	g.opts.PauseInBackground = true
+	g.handleWindowEvent(lost)
+	g.togglePause()
+	g.handleWindowEvent(gained)
+	if !g.paused || g.backgroundPaused {
+		t.Errorf("paused=%v background=%v: regaining focus should keep only the P pause",
+			g.paused, g.backgroundPaused)
+	}
+	g.togglePause()
+	if g.isPaused() {
+		t.Error("still paused after P with focus back")
+	}
+}
+
+// An attached debugger parks the emulation goroutine like a pause, and
+// detaching lets it run on.
+func TestDebuggerHoldsEmulation(t *testing.T) {
+	g := newTestGUI("")
+	g.frameReady = make(chan struct{}, 1)
+	g.resume = make(chan struct{}, 1)
+	if err := g.startDebugger(0, 0); err != nil {
+		t.Skipf("no loopback: %v", err)
+	}
+	defer g.debugListener.Close()
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go g.emulate(stop, done)
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	conn, err := net.Dial("tcp", g.debugListener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(conn, "$?#3f")
+	if reply := make([]byte, 8); !readFull(conn, reply) || string(reply) != "+$S05#b8" {
+		t.Fatalf("attach reply %q", reply)
+	}
+	g.emuMu.Lock()
+	frame := g.nes.Frame
+	paused := g.isPaused()
+	g.emuMu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	g.emuMu.Lock()
+	if !paused || g.nes.Frame != frame {
+		t.Errorf("paused=%v, frames %d → %d while the debugger held the target", paused, frame, g.nes.Frame)
+	}
+	if msgs := g.osd.Messages(); len(msgs) == 0 || !strings.HasPrefix(msgs[len(msgs)-1], "Debugger: stopped at $") {
+		t.Errorf("OSD = %v", msgs)
+	}
+	g.emuMu.Unlock()
+
+	conn.Close()
+	for deadline := time.Now().Add(time.Second); g.debugHalted.Load(); {
+		if time.Now().After(deadline) {
+			t.Fatal("still halted after the debugger went away")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Drain a frame published before the attach, then wait for a new one.
+	select {
+	case <-g.frameReady:
+	default:
+	}
+	select {
+	case <-g.frameReady:
+	case <-time.After(time.Second):
+		t.Fatal("no frame after the debugger detached")
+	}
+}
+
+// The debugger's undo journal records the frames the GUI runs and is
+// dropped when the machine is replaced under it.
+func TestDebuggerUndoReset(t *testing.T) {
+	dir := t.TempDir()
+	g := newTestGUI("")
+	if err := g.startDebugger(0, 1000); err != nil {
+		t.Skipf("no loopback: %v", err)
+	}
+	defer g.debugListener.Close()
+	if err := g.loadROM(writeTestROM(t, dir, "a.nes", false)); err != nil {
+		t.Fatal(err)
+	}
+	g.nes.StepFrame()
+	if g.debugger.Undo.Len() == 0 {
+		t.Fatal("a frame left no history")
+	}
+	g.powerCycle()
+	if n := g.debugger.Undo.Len(); n != 0 {
+		t.Errorf("%d instructions of history survived a power cycle", n)
+	}
+	g.nes.StepFrame()
+	if err := g.loadROM(writeTestROM(t, dir, "b.nes", false)); err != nil {
+		t.Fatal(err)
+	}
+	if n := g.debugger.Undo.Len(); n != 0 {
+		t.Errorf("%d instructions of history survived loading a ROM", n)
+	}
+}
+
+func readFull(r io.Reader, buf []byte) bool {
+	_, err := io.ReadFull(r, buf)
+	return err == nil
+}
+
+func TestFrameBuffersHandOff(t *testing.T) {
+	f := newFrameBuffers(4)
+	if _, fresh := f.latest(); fresh {
+		t.Fatal("nothing published yet")
+	}
+
+	f.publish([]uint32{1, 1, 1, 1})
+	f.publish([]uint32{2, 2, 2, 2}) // overwrites the unread frame
+	got, fresh := f.latest()
+	if !fresh || got[0] != 2 {
+		t.Fatalf("latest = %v fresh=%v, want the newest frame", got, fresh)
+	}
+	if _, fresh := f.latest(); fresh {
+		t.Error("same frame reported fresh twice")
+	}
+
+	// Publishing must never write into the buffer the reader holds.
+	f.publish([]uint32{3, 3, 3, 3})
+	f.publish([]uint32{4, 4, 4, 4})
+	if got[0] != 2 {
+		t.Errorf("reader's frame changed to %d under it", got[0])
+	}
+	if got, _ := f.latest(); got[0] != 4 {
+		t.Errorf("latest = %d, want 4", got[0])
+	}
+}
+
+func TestFrameBuffersConcurrent(t *testing.T) {
+	f := newFrameBuffers(64)
+	frame := make([]uint32, 64)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := uint32(1); n <= 2000; n++ {
+			for i := range frame {
+				frame[i] = n
+			}
+			f.publish(frame)
+		}
+	}()
+	last := uint32(0)
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		got, fresh := f.latest()
+		if !fresh {
+			continue
+		}
+		for _, px := range got {
+			if px != got[0] {
+				t.Fatalf("torn frame: %d and %d in one buffer", got[0], px)
+			}
+		}
+		if got[0] < last {
+			t.Fatalf("frame %d after %d", got[0], last)
+		}
+		last = got[0]
+	}
+	if got, _ := f.latest(); got[0] != 2000 {
+		t.Errorf("final frame %d, want 2000", got[0])
+	}
+}
+
+func TestAudioRing(t *testing.T) {
+	var r audioRing
+	in := make([]float32, 2*audioRingSize)
+	for i := range in {
+		in[i] = float32(i)
+	}
+	if n := r.push(in[:100]); n != 100 || r.len() != 100 {
+		t.Fatalf("push 100 = %d, len %d", n, r.len())
+	}
+	out := make([]float32, 60)
+	if n := r.pop(out); n != 60 || out[0] != 0 || out[59] != 59 {
+		t.Fatalf("pop = %d, %v..%v", n, out[0], out[59])
+	}
+
+	// Fill past capacity: the overflow is dropped, the rest wraps intact.
+	if n := r.push(in[100:]); n != audioRingSize-40 {
+		t.Errorf("push into %d free = %d", audioRingSize-40, n)
+	}
+	all := make([]float32, audioRingSize*2)
+	n := r.pop(all)
+	if n != audioRingSize {
+		t.Fatalf("pop all = %d, want %d", n, audioRingSize)
+	}
+	for i := 0; i < n; i++ {
+		if all[i] != float32(60+i) {
+			t.Fatalf("sample %d = %v, want %d", i, all[i], 60+i)
+		}
+	}
+	if r.pop(all) != 0 {
+		t.Error("ring should be empty")
+	}
+}
+
+func TestAudioBufferSamples(t *testing.T) {
+	for _, tc := range []struct {
+		requested int
+		latency   time.Duration
+		want      uint16
+	}{
+		{0, 0, AudioBufferSize},
+		{512, 0, 512},
+		{2048, 20 * time.Millisecond, 2048}, // explicit size wins
+		{0, 100 * time.Millisecond, 2048},   // 2205 frames per half
+		{0, 45 * time.Millisecond, 512},
+		{0, 5 * time.Millisecond, minAutoAudioBuffer},
+		{0, time.Second, maxAutoAudioBuffer},
+	} {
+		if got := audioBufferSamples(tc.requested, tc.latency); got != tc.want {
+			t.Errorf("audioBufferSamples(%d, %v) = %d, want %d", tc.requested, tc.latency, got, tc.want)
+		}
+	}
+}
+
+func TestQueuedDuration(t *testing.T) {
+	stereo := &sdl.AudioSpec{Freq: 48000, Format: sdl.AUDIO_F32LSB, Channels: 2}
+	if got := queuedDuration(48000*8/10, stereo); got != 100*time.Millisecond {
+		t.Errorf("4800 stereo float frames = %v, want 100ms", got)
+	}
+	mono := &sdl.AudioSpec{Freq: 44100, Format: sdl.AUDIO_S16LSB, Channels: 1}
+	if got := queuedDuration(44100*2, mono); got != time.Second {
+		t.Errorf("44100 mono s16 frames = %v, want 1s", got)
+	}
+	if got := queuedDuration(1000, &sdl.AudioSpec{Freq: 44100, Format: sdl.AUDIO_U8, Channels: 1}); got != 0 {
+		t.Errorf("unsupported format = %v, want 0", got)
+	}
+}
+
+// --- profiler.go ---
+
+func TestProfilerToggle(t *testing.T) {
+	dir := t.TempDir()
+	romPath := writeTestROM(t, dir, "game.nes", false)
+	// NOPs from $8000 on, entered by reset.
+	rom, _ := os.ReadFile(romPath)
+	prg := rom[16 : 16+16384]
+	for i := range prg {
+		prg[i] = 0xEA
+	}
+	prg[0x3FFC], prg[0x3FFD] = 0x00, 0x80
+	os.WriteFile(romPath, rom, 0o644)
+	os.WriteFile(filepath.Join(dir, "game.fns"), []byte("Main = $8000\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "game.nes.0.nl"), []byte("$C100#Tail#\n"), 0o644)
+
+	g := newTestGUI("")
+	if err := g.loadROM(romPath); err != nil {
+		t.Fatal(err)
+	}
+	g.textureBuf = make([]uint32, 256*240)
+	if !g.handleHotkey(keyEvent(sdl.K_u, sdl.KMOD_CTRL, true, 0)) || g.profiler == nil || g.nes.Profile == nil {
+		t.Fatal("Ctrl+U should start the profiler")
+	}
+	g.update()
+	g.update()
+	g.drawOSD()
+	if g.textureBuf[120*256+255] == 0 {
+		t.Error("no scanline bar at the right edge")
+	}
+
+	g.toggleProfiler()
+	if g.profiler != nil || g.nes.Profile != nil {
+		t.Fatal("second toggle should stop the profiler")
+	}
+	report, err := os.ReadFile(filepath.Join(dir, "game.profile.txt"))
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	for _, want := range []string{"2 frames", "Main", "Tail"} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+}
+
+// --- trace.go ---
+
+func TestTraceDump(t *testing.T) {
+	dir := t.TempDir()
+	romPath := writeTestROM(t, dir, "game.nes", false)
+	rom, _ := os.ReadFile(romPath)
+	prg := rom[16 : 16+16384]
+	for i := range prg {
+		prg[i] = 0xEA
+	}
+	prg[0x3FFC], prg[0x3FFD] = 0x00, 0x80
+	os.WriteFile(romPath, rom, 0o644)
+
+	g := newTestGUI("")
+	g.startTrace(8, trace.Filter{})
+	if g.tracer == nil || g.nes.Trace == nil {
+		t.Fatal("startTrace with a size should install the tracer")
+	}
+	if err := g.loadROM(romPath); err != nil {
+		t.Fatal(err)
+	}
+	g.update()
+	if !g.handleHotkey(keyEvent(sdl.K_t, sdl.KMOD_CTRL, true, 0)) {
+		t.Fatal("Ctrl+T not handled")
+	}
+	dump, err := os.ReadFile(filepath.Join(dir, "game.trace.txt"))
+	if err != nil {
+		t.Fatalf("trace: %v", err)
+	}
+	if !strings.Contains(string(dump), "; last 8 of ") || !strings.Contains(string(dump), "NOP") {
+		t.Errorf("trace:\n%s", dump)
+	}
+
+	if got := g.debuggerMonitor("trace clear"); got != "" || g.tracer.Len() != 0 {
+		t.Errorf("monitor trace clear = %q, %d entries left", got, g.tracer.Len())
+	}
+	if got := g.debuggerMonitor("trace"); !strings.Contains(got, "empty") {
+		t.Errorf("monitor trace on an empty ring = %q", got)
+	}
+}
+
+// --- ppulog.go ---
+
+func TestPPULogCapture(t *testing.T) {
+	dir := t.TempDir()
+	romPath := writeTestROM(t, dir, "game.nes", false)
+	rom, _ := os.ReadFile(romPath)
+	prg := rom[16 : 16+16384]
+	copy(prg, []byte{0xA9, 0x1E, 0x8D, 0x01, 0x20, 0x4C, 0x05, 0x80}) // LDA #$1E; STA $2001; JMP *
+	prg[0x3FFC], prg[0x3FFD] = 0x00, 0x80
+	os.WriteFile(romPath, rom, 0o644)
+
+	g := newTestGUI("")
+	if err := g.loadROM(romPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.debuggerMonitor("ppu"); !strings.Contains(got, "off") {
+		t.Errorf("monitor ppu with the log off = %q", got)
+	}
+	if !g.handleHotkey(keyEvent(sdl.K_w, sdl.KMOD_CTRL, true, 0)) || g.ppuLog == nil {
+		t.Fatal("Ctrl+W should start the PPU log")
+	}
+	g.update()
+	if g.ppuLog != nil {
+		t.Error("the log should stop after the captured frame")
+	}
+	csv, err := os.ReadFile(filepath.Join(dir, "game.ppu.csv"))
+	if err != nil {
+		t.Fatalf("PPU log: %v", err)
+	}
+	if !strings.Contains(string(csv), ",8002,PPUMASK,2001,1E") {
+		t.Errorf("PPU log:\n%s", csv)
+	}
+
+	g.nes.Reset()
+	g.debuggerMonitor("ppu on")
+	g.update()
+	if got := g.debuggerMonitor("ppu"); !strings.Contains(got, "PPUMASK $2001 = $1E") {
+		t.Errorf("monitor ppu = %q", got)
+	}
+	g.debuggerMonitor("ppu off")
+	if g.ppuLog != nil {
+		t.Error("monitor ppu off left the log running")
+	}
+}
+
+// --- watch.go ---
+
+// A rebuilt ROM is reloaded once the file holds still, keeping RAM when
+// asked to.
+func TestROMWatchReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestROM(t, dir, "game.nes", false)
+	g := newTestGUI("")
+	if err := g.loadROM(path); err != nil {
+		t.Fatal(err)
+	}
+	g.watch = &romWatch{keep: nes.SwapKeepRAM}
+	poll := func() {
+		g.watch.next = time.Time{}
+		g.checkROMWatch()
+	}
+	poll() // starts watching path
+	cart := g.nes.Cartridge
+	g.nes.Memory.RAM[0x300] = 0x5A
+
+	rebuilt := time.Now().Add(time.Minute)
+	writeTestROM(t, dir, "game.nes", false)
+	if err := os.Chtimes(path, rebuilt, rebuilt); err != nil {
+		t.Fatal(err)
+	}
+	poll()
+	if g.nes.Cartridge != cart {
+		t.Fatal("reloaded before the file held still")
+	}
+	poll()
+	if g.nes.Cartridge == cart {
+		t.Fatal("not reloaded after the file changed")
+	}
+	if g.nes.Memory.RAM[0x300] != 0x5A {
+		t.Errorf("RAM[$300] = %#02x, want kept", g.nes.Memory.RAM[0x300])
+	}
+	if msgs := g.osd.Messages(); len(msgs) == 0 || msgs[len(msgs)-1] != "Reloaded game.nes (kept ram)" {
+		t.Errorf("OSD = %v", msgs)
+	}
+
+	cart = g.nes.Cartridge
+	poll()
+	if g.nes.Cartridge != cart {
+		t.Error("reloaded an unchanged file")
+	}
+}
+
+// --- scope.go ---
+
+func TestScopeToggle(t *testing.T) {
+	g := newTestGUI("")
+	g.textureBuf = make([]uint32, 256*240)
+	if !g.handleHotkey(keyEvent(sdl.K_a, sdl.KMOD_CTRL, true, 0)) || g.nes.APU.Taps == nil {
+		t.Fatal("Ctrl+A should attach the channel taps")
+	}
+	a := g.nes.APU
+	a.WriteRegister(0x4015, 0x01)
+	a.WriteRegister(0x4000, 0xBF) // 50% duty, constant volume 15
+	a.WriteRegister(0x4002, 0xFD)
+	a.WriteRegister(0x4003, 0x08)
+	a.StepN(29780)
+	if len(a.Taps.Samples[apu.ChannelPulse1]) == 0 {
+		t.Fatal("no samples tapped")
+	}
+	g.drawOSD()
+	if g.textureBuf[8*256+250] == 0 {
+		t.Error("no scope pane at the top right")
+	}
+	if got := g.scopeBuf[apu.ChannelPulse1].Label; got != "P1 A4 440Hz v15 50%" {
+		t.Errorf("pulse 1 caption = %q", got)
+	}
+	if !g.scopeBuf[apu.ChannelNoise].Dim || g.scopeBuf[apu.ChannelPulse1].Dim {
+		t.Error("silent noise should be dimmed, sounding pulse 1 not")
+	}
+
+	g.toggleScope()
+	if a.Taps != nil {
+		t.Error("second toggle should detach the taps")
+	}
+}
+
+// --- picker.go ---
+
+func TestStatePicker(t *testing.T) {
+	dir := t.TempDir()
+	g := newTestGUI(filepath.Join(dir, "game.nes"))
+	g.textureBuf = make([]uint32, 256*240)
+	if !g.handleHotkey(keyEvent(sdl.K_2, sdl.KMOD_LSHIFT, true, 0)) {
+		t.Fatal("Shift+2 should be consumed")
+	}
+	g.handleHotkey(keyEvent(sdl.K_0, sdl.KMOD_LSHIFT, true, 0))
+	for _, slot := range []int{2, 10} {
+		if _, err := os.Stat(g.stateSlotPath(slot)); err != nil {
+			t.Fatalf("slot %d not saved: %v", slot, err)
+		}
+	}
+	if g.nes.APU.ChannelMute[1] {
+		t.Error("Shift+2 also muted channel 2")
+	}
+
+	if !g.handleHotkey(keyEvent(sdl.K_s, sdl.KMOD_CTRL, true, 0)) || g.picker == nil {
+		t.Fatal("Ctrl+S should open the picker")
+	}
+	if g.picker.slot != 10 || g.picker.thumb == nil {
+		t.Errorf("opened on slot %d (thumbnail %v), want the newest, 10, with its thumbnail", g.picker.slot, g.picker.thumb != nil)
+	}
+	g.drawOSD()
+	if g.textureBuf[120*256+128] == 0 {
+		t.Error("picker not drawn")
+	}
+
+	g.handlePickerKey(keyEvent(sdl.K_RIGHT, 0, true, 0))
+	if g.picker.slot != 1 || g.picker.thumb != nil {
+		t.Errorf("Right from 10 selected %d, want the empty slot 1", g.picker.slot)
+	}
+
+	g.nes.Memory.RAM[0x10] = 0x77
+	g.handlePickerKey(keyEvent(sdl.K_2, 0, true, 0))
+	if g.picker != nil {
+		t.Fatal("loading a slot should close the picker")
+	}
+	if g.nes.Memory.RAM[0x10] != 0 {
+		t.Error("2 in the picker didn't load slot 2")
+	}
+	if msgs := g.osd.Messages(); msgs[len(msgs)-1] != "State 2 loaded" {
+		t.Errorf("OSD = %v", msgs)
+	}
+
+	g.openStatePicker()
+	g.handlePickerKey(keyEvent(sdl.K_ESCAPE, 0, true, 0))
+	if g.picker != nil || g.osd.Persistent(osdKeyMenuHelp) != "" {
+		t.Error("Esc should close the picker and its help line")
+	}
+}
+
+// --- menu.go ---
+
+// selectMenuItem selects the top page's item whose label starts with
+// prefix.
+func selectMenuItem(t *testing.T, g *NESGUI, prefix string) {
+	t.Helper()
+	p := g.menuPage()
+	for i, item := range p.items {
+		if strings.HasPrefix(item.label(g), prefix) {
+			p.selected = i
+			return
+		}
+	}
+	t.Fatalf("no %q item on page %q", prefix, p.title)
+}
+
+func TestMenuSettings(t *testing.T) {
+	g := newTestGUI("")
+	g.textureBuf = make([]uint32, 256*240)
+	press := func(sym sdl.Keycode) { g.handleMenuKey(keyEvent(sym, 0, true, 0)) }
+
+	g.openMenu()
+	g.drawOSD()
+	if g.textureBuf[120*256+128] == 0 {
+		t.Error("menu not drawn")
+	}
+	press(sdl.K_DOWN)
+	if g.menuPage().selected != 1 {
+		t.Errorf("Down selected %d, want 1", g.menuPage().selected)
+	}
+	press(sdl.K_UP)
+	press(sdl.K_UP)
+	if got := g.menuPage().items[g.menuPage().selected].label(g); got != "Quit" {
+		t.Errorf("Up from the top selected %q, want the last item", got)
+	}
+
+	selectMenuItem(t, g, "Scale")
+	press(sdl.K_LEFT)
+	if g.scale() != WindowScale-1 {
+		t.Errorf("Left on scale = %d, want %d", g.scale(), WindowScale-1)
+	}
+	g.setScale(maxScale)
+	press(sdl.K_RIGHT)
+	if g.scale() != 1 {
+		t.Errorf("Right past %dx = %d, want 1", maxScale, g.scale())
+	}
+
+	selectMenuItem(t, g, "Palette")
+	press(sdl.K_RETURN)
+	if g.nes.PPU.PaletteManager.NTSC() == nil {
+		t.Error("Enter on the palette should switch to NTSC")
+	}
+	press(sdl.K_RIGHT)
+	if g.nes.PPU.PaletteManager.NTSC() != nil {
+		t.Error("Right on the palette should switch back")
+	}
+
+	selectMenuItem(t, g, "Filter")
+	press(sdl.K_LEFT)
+	if g.filterName() != "crt" || g.filter == nil || len(g.filterBuf) != 9*len(g.textureBuf) {
+		t.Errorf("Left from nearest: filter %q with a %d-pixel buffer, want crt at 3x", g.filterName(), len(g.filterBuf))
+	}
+	press(sdl.K_RIGHT)
+	if g.filterName() != "nearest" || g.filter != nil || g.filterBuf != nil {
+		t.Errorf("Right from crt: filter %q, want nearest and no buffer", g.filterName())
+	}
+	if err := g.setFilter("hq9x"); err == nil || g.filterName() != "nearest" {
+		t.Errorf("unknown filter: %v, now %q", err, g.filterName())
+	}
+
+	selectMenuItem(t, g, "APU region")
+	press(sdl.K_RIGHT)
+	if g.nes.APU.Region() != apu.RegionPAL {
+		t.Errorf("region %v, want PAL", g.nes.APU.Region())
+	}
+
+	g.nes.Cheats.Add(cheat.Cheat{Address: 0x0000, Value: 1, Source: "0000:01"})
+	g.nes.Cheats.Add(cheat.Cheat{Address: 0x0001, Value: 2, Source: "0001:02"})
+	selectMenuItem(t, g, "Cheats")
+	press(sdl.K_RETURN)
+	selectMenuItem(t, g, "[x] 0001:02")
+	press(sdl.K_RETURN)
+	if c := g.nes.Cheats.List(); !c[0].Enabled || c[1].Enabled {
+		t.Errorf("cheats enabled %v/%v, want only the second off", c[0].Enabled, c[1].Enabled)
+	}
+	selectMenuItem(t, g, "All cheats: ON (2 loaded)")
+	press(sdl.K_LEFT)
+	if g.nes.Cheats.Enabled() {
+		t.Error("the master switch should be off")
+	}
+
+	// Esc goes back a page, then closes.
+	press(sdl.K_ESCAPE)
+	if g.menu == nil || g.menuPage().title != "GoNES" {
+		t.Fatal("Esc on a submenu should go back to the first page")
+	}
+	selectMenuItem(t, g, "Quit")
+	press(sdl.K_RETURN)
+	if g.running {
+		t.Error("Quit should stop the emulator")
+	}
+	g.running = true
+	press(sdl.K_ESCAPE)
+	if g.menu != nil || g.osd.Persistent(osdKeyMenuHelp) != "" {
+		t.Error("Esc on the first page should close the menu and its help line")
+	}
+}
+
+func TestMenuBrowser(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	for _, name := range []string{"b.nes", "a.ZIP", "notes.txt", ".hidden.nes"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644)
+	}
+	g := newTestGUI(filepath.Join(dir, "b.nes"))
+	press := func(sym sdl.Keycode) { g.handleMenuKey(keyEvent(sym, 0, true, 0)) }
+
+	g.openMenu()
+	selectMenuItem(t, g, "Load ROM")
+	press(sdl.K_RETURN)
+	p := g.menuPage()
+	var labels []string
+	for _, item := range p.items {
+		labels = append(labels, item.label(g))
+	}
+	if p.title != dir || strings.Join(labels, " ") != "../ sub/ a.ZIP b.nes" {
+		t.Fatalf("browser on %s lists %q", p.title, labels)
+	}
+
+	selectMenuItem(t, g, "sub/")
+	press(sdl.K_RETURN)
+	if g.menuPage().title != filepath.Join(dir, "sub") || len(g.menu.pages) != 2 {
+		t.Fatalf("entering sub/ gave page %q with %d pages under it", g.menuPage().title, len(g.menu.pages))
+	}
+	press(sdl.K_RETURN) // ../
+	if g.menuPage().title != dir {
+		t.Fatalf("../ gave %q, want %q", g.menuPage().title, dir)
+	}
+
+	// A file that isn't a ROM closes the menu and leaves the game running.
+	selectMenuItem(t, g, "b.nes")
+	press(sdl.K_RETURN)
+	if g.menu != nil {
+		t.Error("picking a ROM should close the menu")
+	}
+	if msgs := g.osd.Messages(); len(msgs) == 0 || msgs[len(msgs)-1] != "Cannot load b.nes" {
+		t.Errorf("OSD = %v", msgs)
+	}
+}
+
+func TestMenuControls(t *testing.T) {
+	g := newTestGUI("")
+	g.inputManager = NewInputManager(g.nes)
+	press := func(sym sdl.Keycode) { g.handleMenuKey(keyEvent(sym, 0, true, 0)) }
+
+	g.openMenu()
+	selectMenuItem(t, g, "Controls")
+	press(sdl.K_RETURN)
+	press(sdl.K_RETURN) // A
+	if g.menu.binding != 0 {
+		t.Fatalf("binding %d, want A waiting for a key", g.menu.binding)
+	}
+	press(sdl.K_j)
+	if g.menu.binding != -1 || g.inputManager.keys[0] != sdl.K_j {
+		t.Errorf("A bound to %d, want J", g.inputManager.keys[0])
+	}
+
+	// Taking another button's key swaps them.
+	press(sdl.K_DOWN)
+	press(sdl.K_RETURN) // B
+	press(sdl.K_j)
+	if g.inputManager.keys[1] != sdl.K_j || g.inputManager.keys[0] != sdl.K_x {
+		t.Errorf("A/B on %d/%d, want X/J", g.inputManager.keys[0], g.inputManager.keys[1])
+	}
+
+	// Esc cancels a binding without leaving the page.
+	press(sdl.K_RETURN)
+	press(sdl.K_ESCAPE)
+	if g.menu.binding != -1 || g.inputManager.keys[1] != sdl.K_j || g.menuPage().title != "Controls (player 1)" {
+		t.Error("Esc while binding should only cancel it")
+	}
+
+	selectMenuItem(t, g, "Reset to defaults")
+	press(sdl.K_RETURN)
+	if g.inputManager.keys != defaultKeys {
+		t.Errorf("keys %v, want the defaults", g.inputManager.keys)
+	}
+}
+
+// --- autosave.go ---
+
+func TestAutosaveResume(t *testing.T) {
+	dir := t.TempDir()
+	rom := writeTestROM(t, dir, "game.nes", false)
+	open := func() *NESGUI {
+		g := newTestGUI("")
+		g.opts.Autosave = time.Second
+		g.opts.StateDir = filepath.Join(dir, "states")
+		if err := g.loadROM(rom); err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+
+	g := open()
+	if g.autosaveOffer != nil {
+		t.Fatal("offered an autosave before there was one")
+	}
+	g.nes.Memory.RAM[0x10] = 0x42
+	g.playFrames = 3600
+	for i := 0; i < 59; i++ {
+		g.checkAutosave()
+	}
+	if _, err := os.Stat(g.autosavePath()); err == nil {
+		t.Fatal("autosaved before a second of play")
+	}
+	g.checkAutosave()
+	m, err := savestate.Read(g.autosavePath())
+	if err != nil {
+		t.Fatalf("no autosave after a second: %v", err)
+	}
+	if m.ROMSHA1 != g.nes.Cartridge.Hashes().ROMSHA1 {
+		t.Errorf("autosave ROM hash %q", m.ROMSHA1)
+	}
+
+	// The next launch offers it; quitting unanswered keeps it.
+	g = open()
+	if g.autosaveOffer == nil || g.osd.Persistent(osdKeyMenuHelp) == "" {
+		t.Fatal("autosave not offered on reopening the ROM")
+	}
+	g.writeAutosave()
+	g.handleAutosaveKey(keyEvent(sdl.K_RETURN, 0, true, 0))
+	if g.autosaveOffer != nil || g.osd.Persistent(osdKeyMenu) != "" {
+		t.Error("Enter should close the offer")
+	}
+	if g.nes.Memory.RAM[0x10] != 0x42 || g.playFrames != 3600 {
+		t.Errorf("resumed RAM[$10] = $%02X, playFrames %d", g.nes.Memory.RAM[0x10], g.playFrames)
+	}
+
+	g = open()
+	g.handleAutosaveKey(keyEvent(sdl.K_ESCAPE, 0, true, 0))
+	if g.autosaveOffer != nil || g.nes.Memory.RAM[0x10] != 0 {
+		t.Error("Esc should start over")
+	}
+
+	// A different dump under the same name isn't offered.
+	data, err := os.ReadFile(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[16] = 0xEA
+	if err := os.WriteFile(rom, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if g = open(); g.autosaveOffer != nil {
+		t.Error("offered an autosave made from another ROM")
+	}
+}
+
+func TestCrashDump(t *testing.T) {
+	dir := t.TempDir()
+	g := newTestGUI(filepath.Join(dir, "game.nes"))
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("recovered %v, want the original panic", r)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "game.crash.state")); err != nil {
+			t.Error(err)
+		}
+		log, err := os.ReadFile(filepath.Join(dir, "game.crash.log"))
+		if err != nil || !strings.Contains(string(log), "panic: boom") {
+			t.Errorf("crash log %q (%v)", log, err)
+		}
+	}()
+	func() {
+		defer g.dumpCrashOnPanic()
+		panic("boom")
+	}()
+}
+
+// --- keyboard.go ---
+
+func TestKeyboardCapture(t *testing.T) {
+	g := newTestGUI("")
+	ports := g.nes.GetInput()
+	g.handleHotkey(keyEvent(sdl.K_k, sdl.KMOD_CTRL, true, 0))
+	if g.kbCapture {
+		t.Fatal("Ctrl+K without a keyboard attached should not capture")
+	}
+
+	ports.SetExpansion(input.NewKeyboard())
+	g.handleHotkey(keyEvent(sdl.K_k, sdl.KMOD_CTRL, true, 0))
+	if !g.kbCapture {
+		t.Fatal("Ctrl+K should start typing on the keyboard")
+	}
+	// Row 0, column 0 holds RETURN on bit 2.
+	row0 := func() uint8 {
+		ports.Write(0x05)
+		ports.Write(0x04)
+		return ports.Read(1) & 0x1E
+	}
+	g.handleKeyboardKey(keyEvent(sdl.K_RETURN, 0, true, 0))
+	if v := row0(); v != 0x1A {
+		t.Errorf("row 0 with Return held = %02X, want 1A", v)
+	}
+
+	g.handleKeyboardKey(keyEvent(sdl.K_k, sdl.KMOD_CTRL, true, 0))
+	if g.kbCapture || row0() != 0x1E {
+		t.Error("Ctrl+K again should stop typing and let go of every key")
+	}
+}
+
+// --- title.go ---
+
+func TestWindowTitle(t *testing.T) {
+	g := newTestGUI("")
+	g.currentFPS = 60
+	if got, want := g.windowTitle(), WindowTitle+" - FPS: 60.0"; got != want {
+		t.Errorf("no ROM: %q, want %q", got, want)
+	}
+
+	// A dump the database doesn't know goes by its file name; once it is
+	// registered, by its name there.
+	rom := make([]byte, 16+16384+8192)
+	copy(rom, "NES\x1A\x01\x01")
+	copy(rom[16:], "title test")
+	path := filepath.Join(t.TempDir(), "Some Game (U).nes")
+	if err := os.WriteFile(path, rom, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.loadROM(path); err != nil {
+		t.Fatal(err)
+	}
+	g.turbo = true
+	if got, want := g.windowTitle(), "Some Game (U) - GoNES - FPS: 60.0 [TURBO]"; got != want {
+		t.Errorf("file name: %q, want %q", got, want)
+	}
+	crc := g.nes.Cartridge.Hashes().ROMCRC32
+	cartridge.RegisterGame(crc, cartridge.GameInfo{Name: "Some Game (USA)"})
+	t.Cleanup(func() { cartridge.RegisterGame(crc, cartridge.GameInfo{}) })
+	if err := g.loadROM(path); err != nil {
+		t.Fatal(err)
+	}
+	g.turbo, g.showFPS, g.instance = false, false, 2
+	if got, want := g.windowTitle(), "Some Game (USA) - GoNES #2"; got != want {
+		t.Errorf("database name: %q, want %q", got, want)
+	}
+}
+
+func TestClaimInstance(t *testing.T) {
+	first, l1 := claimInstance()
+	if l1 == nil {
+		t.Skip("no loopback ports to claim")
+	}
+	defer l1.Close()
+	second, l2 := claimInstance()
+	if l2 == nil {
+		t.Fatal("second instance got no number")
+	}
+	if second <= first {
+		t.Errorf("second instance = %d, want above %d", second, first)
+	}
+	l2.Close()
+	if again, l := claimInstance(); l == nil || again != second {
+		t.Errorf("after #%d exits the next claim = %d, want it back", second, again)
+	} else {
+		l.Close()
+	}
+}
+
+// --- icon.go ---
+
+func TestIconPixels(t *testing.T) {
+	pix, w, h := iconPixels()
+	if len(pix) != w*h || w != 16 || h != 16 {
+		t.Fatalf("icon %dx%d with %d pixels", w, h, len(pix))
+	}
+	for y, row := range iconArt {
+		for x := 0; x < len(row); x++ {
+			if _, ok := iconColors[row[x]]; !ok {
+				t.Errorf("(%d,%d) %q has no colour", x, y, row[x])
+			}
+		}
+	}
+	if pix[0] != 0 || pix[len(pix)-1] != 0 {
+		t.Error("corners should be transparent")
+	}
+}
+
+// --- stats.go ---
+
+func TestStatsHUD(t *testing.T) {
+	g := newTestGUI("")
+	g.textureBuf = make([]uint32, 256*240)
+	if err := g.loadROM(writeTestROM(t, t.TempDir(), "a.nes", false)); err != nil {
+		t.Fatal(err)
+	}
+	g.nes.StepFrame()
+	if s := g.Stats(); s.Instructions != 0 {
+		t.Errorf("the core timed itself with nothing asking: %+v", s.Stats)
+	}
+
+	g.toggleStats()
+	g.nes.StepFrame()
+	s := g.Stats()
+	if s.Frame != g.nes.Frame || s.Instructions == 0 || s.Total == 0 {
+		t.Errorf("Stats = %+v after a timed frame", s.Stats)
+	}
+	g.drawOSD()
+	if got := g.osd.Persistent(osdKeyStats); !strings.HasPrefix(got, "CPU ") {
+		t.Errorf("HUD line = %q", got)
+	}
+
+	g.toggleStats()
+	g.drawOSD()
+	if g.osd.Persistent(osdKeyStats) != "" || g.nes.Stats() != (nes.Stats{}) {
+		t.Error("hiding the HUD should clear it and stop the core's timing")
+	}
+}
+
+func TestStatsCSV(t *testing.T) {
+	g := newTestGUI("")
+	if err := g.loadROM(writeTestROM(t, t.TempDir(), "a.nes", false)); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "stats.csv")
+	g.startStatsLog(path)
+	if g.statsLog == nil {
+		t.Fatal("no log opened")
+	}
+	for i := 0; i < 3; i++ {
+		g.nes.StepFrame()
+		g.logStats()
+	}
+	g.closeStatsLog()
+	if g.nes.Stats() != (nes.Stats{}) {
+		t.Error("closing the log should stop the core's timing")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 || lines[0] != strings.Join(statsCSVHeader, ",") {
+		t.Fatalf("CSV = %q, want the header and 3 rows", lines)
+	}
+	row := strings.Split(lines[3], ",")
+	if len(row) != len(statsCSVHeader) || row[0] != fmt.Sprint(g.nes.Frame) {
+		t.Errorf("last row = %q, want frame %d", row, g.nes.Frame)
+	}
+}
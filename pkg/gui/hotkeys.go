@@ -8,8 +8,14 @@
 package gui
 
 import (
+	"math"
+	"strings"
+
 	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/apu"
+	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
 	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/savestate"
 )
 
 // hotkey is one entry in hotkeyTable. The handler runs when key+modMask
@@ -26,15 +32,25 @@ type hotkey struct {
 // expressed as plain function values. Inlined methods would also work but
 // these read more directly.
 func (g *NESGUI) quit()        { g.running = false }
-func (g *NESGUI) toggleTurbo() { g.turbo = !g.turbo; logger.LogInfo("Turbo mode: %v", g.turbo) }
+func (g *NESGUI) toggleTurbo() { g.turbo = !g.turbo; g.notify("Turbo: %s", onOff(g.turbo)) }
 func (g *NESGUI) toggleFPS()   { g.showFPS = !g.showFPS }
-func (g *NESGUI) resetNES()    { g.nes.SoftReset(); logger.LogInfo("NES reset") }
+func (g *NESGUI) resetNES()    { g.nes.SoftReset(); g.notify("Reset") }
+func (g *NESGUI) powerCycle() {
+	g.nes.PowerOn()
+	g.rules.Reset()
+	g.resetUndo()
+	g.notify("Power cycle")
+}
+func (g *NESGUI) toggleInputDisplay() {
+	g.showInput = !g.showInput
+	g.notify("Input display: %s", onOff(g.showInput))
+}
 func (g *NESGUI) toggleCheats() {
 	on := g.nes.Cheats.ToggleAll()
-	logger.LogInfo("Cheats (%d loaded): %v", g.nes.Cheats.Count(), on)
+	g.notify("Cheats (%d loaded): %s", g.nes.Cheats.Count(), onOff(on))
 }
 func (g *NESGUI) toggleFilter() {
-	logger.LogInfo("Analog filter chain: %v", g.nes.APU.ToggleFilter())
+	g.notify("Analog filter: %s", onOff(g.nes.APU.ToggleFilter()))
 }
 func (g *NESGUI) toggleSpriteLimit() {
 	g.nes.PPU.NoSpriteLimit = !g.nes.PPU.NoSpriteLimit
@@ -42,19 +58,130 @@ func (g *NESGUI) toggleSpriteLimit() {
 	if g.nes.PPU.NoSpriteLimit {
 		state = "unlimited (no flicker)"
 	}
-	logger.LogInfo("Sprite limit: %s", state)
+	g.notify("Sprite limit: %s", state)
+}
+
+// volumeStep is how far one press of -/+ moves the master volume.
+const volumeStep = 0.1
+
+// changeVolume moves the master volume by delta, snapped to volumeStep so
+// repeated presses don't drift, and unmutes — pressing +/- while muted
+// shouldn't leave you wondering why nothing changed.
+func (g *NESGUI) changeVolume(delta float32) {
+	apu := g.nes.APU
+	v := apu.SetVolume(float32(math.Round(float64((apu.Volume+delta)/volumeStep))) * volumeStep)
+	apu.Muted = false
+	g.volumeChanged = true
+	g.notify("Volume: %d%%", volumePercent(v))
+}
+func (g *NESGUI) volumeUp()   { g.changeVolume(volumeStep) }
+func (g *NESGUI) volumeDown() { g.changeVolume(-volumeStep) }
+
+func (g *NESGUI) toggleMute() {
+	g.volumeChanged = true
+	g.notify("Sound: %s", onOff(!g.nes.APU.ToggleMute()))
+}
+
+// saveVolume hands a volume or mute the hotkeys changed to
+// Options.SaveVolume, so it outlasts the session.
+func (g *NESGUI) saveVolume() {
+	if !g.volumeChanged || g.opts.SaveVolume == nil {
+		return
+	}
+	if err := g.opts.SaveVolume(volumePercent(g.nes.APU.Volume), g.nes.APU.Muted); err != nil {
+		logger.LogError("Volume: %v", err)
+	}
+	g.volumeChanged = false
+}
+
+// volumePercent renders an APU volume (0-1) as a whole percentage.
+func volumePercent(v float32) int { return int(v*100 + 0.5) }
+
+func (g *NESGUI) togglePause() {
+	g.setPause(!g.paused, g.backgroundPaused)
+	g.notify("Pause: %s", onOff(g.paused))
 }
+
 func (g *NESGUI) toggleExpansionAudio() {
 	muted, ok := g.nes.APU.ToggleExpansionMute()
 	if !ok {
-		logger.LogInfo("Expansion audio: cartridge has no expansion sound chip")
+		g.notify("Expansion audio: none on this cartridge")
 		return
 	}
 	state := "ON"
 	if muted {
 		state = "MUTED"
 	}
-	logger.LogInfo("Expansion audio: %s", state)
+	g.notify("Expansion audio (%s): %s", expansionChips(g.nes.APU), state)
+}
+
+// toggleConsoleAudio switches between a Famicom, which mixes the
+// cartridge's sound chip in, and an NES, which doesn't.
+func (g *NESGUI) toggleConsoleAudio() {
+	a := g.nes.APU
+	a.NESAudio = !a.NESAudio
+	if a.NESAudio {
+		g.notify("Console: NES (expansion audio not routed)")
+	} else {
+		g.notify("Console: Famicom (expansion audio mixed)")
+	}
+}
+
+// changeExpansionLevel moves the level of every chip on the cartridge by
+// delta, snapped to volumeStep like the master volume.
+func (g *NESGUI) changeExpansionLevel(delta float32) {
+	a := g.nes.APU
+	if len(a.Sources) == 0 {
+		g.notify("Expansion audio: none on this cartridge")
+		return
+	}
+	var level float32
+	for _, s := range a.Sources {
+		level = a.SetExpansionLevel(s.Chip, float32(math.Round(float64((s.Gain+delta)/volumeStep)))*volumeStep)
+	}
+	g.notify("Expansion level (%s): %d%%", expansionChips(a), volumePercent(level))
+}
+func (g *NESGUI) expansionLevelUp()   { g.changeExpansionLevel(volumeStep) }
+func (g *NESGUI) expansionLevelDown() { g.changeExpansionLevel(-volumeStep) }
+
+// expansionChips names the cartridge's sound chips for the OSD.
+func expansionChips(a *apu.APU) string {
+	names := make([]string, 0, len(a.Sources))
+	for _, s := range a.Sources {
+		name := strings.ToUpper(s.Chip)
+		if name == "" {
+			name = "?"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "+")
+}
+
+// switchDiskSide ejects the Disk System disk and puts the next side in,
+// as turning it over in the drive would.
+func (g *NESGUI) switchDiskSide() {
+	var disk mapper.DiskDrive
+	if g.nes.Cartridge != nil {
+		disk = g.nes.Cartridge.Disk()
+	}
+	if disk == nil {
+		g.notify("Disk: not a Disk System image")
+		return
+	}
+	side := (disk.DiskSide() + 1) % disk.DiskSides()
+	disk.InsertDisk(side)
+	g.notify("Disk %d side %c", side/2+1, 'A'+side%2)
+}
+
+// insertCoin drops a coin into a VS. System cabinet's first slot.
+func (g *NESGUI) insertCoin() {
+	vs := g.nes.GetInput().VS()
+	if vs == nil {
+		g.notify("Coin: not a VS. System game")
+		return
+	}
+	vs.InsertCoin(0)
+	g.notify("Coin inserted")
 }
 
 // hotkeyTable lists the simple, fixed-modifier hotkeys. F1-F10 (variable
@@ -62,28 +189,74 @@ func (g *NESGUI) toggleExpansionAudio() {
 // because expressing "Ctrl optional" in this table would hurt readability
 // more than it helps.
 var hotkeyTable = []hotkey{
-	{sdl.K_ESCAPE, 0, (*NESGUI).quit, true},
+	{sdl.K_ESCAPE, 0, (*NESGUI).openMenu, false},
+	{sdl.K_q, sdl.KMOD_CTRL, (*NESGUI).quit, false},
 	{sdl.K_TAB, 0, (*NESGUI).toggleTurbo, false},
-	{sdl.K_F11, 0, (*NESGUI).toggleFPS, true},
+	{sdl.K_F11, sdl.KMOD_CTRL, (*NESGUI).toggleStats, false},
+	{sdl.K_F11, 0, (*NESGUI).toggleFPS, true}, // after Ctrl+F11, which it would shadow
 	{sdl.K_F12, 0, (*NESGUI).saveScreenshot, true},
 	{sdl.K_r, sdl.KMOD_CTRL, (*NESGUI).resetNES, false},
+	{sdl.K_p, sdl.KMOD_CTRL, (*NESGUI).powerCycle, false},
+	{sdl.K_p, 0, (*NESGUI).togglePause, false}, // after Ctrl+P, which it would shadow
 	{sdl.K_h, sdl.KMOD_CTRL, (*NESGUI).toggleCheats, false},
 	{sdl.K_e, sdl.KMOD_CTRL, (*NESGUI).toggleRecording, false},
+	{sdl.K_o, sdl.KMOD_CTRL, (*NESGUI).openRecentMenu, false},
+	{sdl.K_i, sdl.KMOD_CTRL, (*NESGUI).toggleInputDisplay, false},
+	{sdl.K_u, sdl.KMOD_CTRL, (*NESGUI).toggleProfiler, false},
+	{sdl.K_t, sdl.KMOD_CTRL, (*NESGUI).dumpTrace, false},
+	{sdl.K_w, sdl.KMOD_CTRL, (*NESGUI).capturePPULog, false},
+	{sdl.K_a, sdl.KMOD_CTRL, (*NESGUI).toggleScope, false},
+	{sdl.K_b, sdl.KMOD_CTRL, (*NESGUI).toggleCHRViewer, false},
+	{sdl.K_s, sdl.KMOD_CTRL, (*NESGUI).openStatePicker, false},
+	{sdl.K_k, sdl.KMOD_CTRL, (*NESGUI).toggleKeyboardCapture, false},
+	{sdl.K_d, sdl.KMOD_CTRL, (*NESGUI).switchDiskSide, false},
+	{sdl.K_c, sdl.KMOD_CTRL, (*NESGUI).insertCoin, false},
+	{sdl.K_6, sdl.KMOD_CTRL, (*NESGUI).toggleConsoleAudio, false},
 	{sdl.K_6, 0, (*NESGUI).toggleExpansionAudio, false},
 	{sdl.K_7, 0, (*NESGUI).toggleFilter, false},
 	{sdl.K_8, 0, (*NESGUI).toggleSpriteLimit, false},
+	{sdl.K_9, 0, (*NESGUI).nextTVControl, false},
+	{sdl.K_LEFTBRACKET, 0, (*NESGUI).tvControlDown, true},
+	{sdl.K_RIGHTBRACKET, 0, (*NESGUI).tvControlUp, true},
+	{sdl.K_m, sdl.KMOD_CTRL, (*NESGUI).toggleMute, false},
+	{sdl.K_MINUS, sdl.KMOD_CTRL, (*NESGUI).expansionLevelDown, true},
+	{sdl.K_KP_MINUS, sdl.KMOD_CTRL, (*NESGUI).expansionLevelDown, true},
+	{sdl.K_EQUALS, sdl.KMOD_CTRL, (*NESGUI).expansionLevelUp, true},
+	{sdl.K_PLUS, sdl.KMOD_CTRL, (*NESGUI).expansionLevelUp, true},
+	{sdl.K_KP_PLUS, sdl.KMOD_CTRL, (*NESGUI).expansionLevelUp, true},
+	{sdl.K_MINUS, 0, (*NESGUI).volumeDown, true},
+	{sdl.K_KP_MINUS, 0, (*NESGUI).volumeDown, true},
+	{sdl.K_EQUALS, 0, (*NESGUI).volumeUp, true}, // the + key on US layouts
+	{sdl.K_PLUS, 0, (*NESGUI).volumeUp, true},
+	{sdl.K_KP_PLUS, 0, (*NESGUI).volumeUp, true},
 }
 
 // isHotkeyKey reports whether a key is one of those the emulator owns. Used
 // for release-event consumption so the InputManager doesn't see a phantom
 // game-button release for a key that was never a game button to begin with.
 func isHotkeyKey(k sdl.Keycode) bool {
-	return k == sdl.K_ESCAPE || k == sdl.K_TAB ||
-		(k >= sdl.K_F1 && k <= sdl.K_F12) ||
-		(k >= sdl.K_1 && k <= sdl.K_8)
+	switch k {
+	case sdl.K_ESCAPE, sdl.K_TAB, sdl.K_MINUS, sdl.K_KP_MINUS, sdl.K_EQUALS, sdl.K_PLUS, sdl.K_KP_PLUS,
+		sdl.K_LEFTBRACKET, sdl.K_RIGHTBRACKET:
+		return true
+	}
+	return (k >= sdl.K_F1 && k <= sdl.K_F12) ||
+		(k >= sdl.K_0 && k <= sdl.K_9)
 }
 
-// handleHotkey processes emulator-level hotkeys (Esc/Tab/F1-F12/1-8).
+// numberSlot maps the number keys to state slots: 1-9, and 0 for the
+// tenth.
+func numberSlot(k sdl.Keycode) (int, bool) {
+	switch {
+	case k >= sdl.K_1 && k <= sdl.K_9:
+		return int(k-sdl.K_1) + 1, true
+	case k == sdl.K_0:
+		return savestate.Slots, true
+	}
+	return 0, false
+}
+
+// handleHotkey processes emulator-level hotkeys (Esc/Tab/F1-F12/0-9/-/+).
 // Returns true if the event was consumed; false means it's a game input and
 // should be forwarded to the InputManager.
 func (g *NESGUI) handleHotkey(e *sdl.KeyboardEvent) bool {
@@ -93,6 +266,15 @@ func (g *NESGUI) handleHotkey(e *sdl.KeyboardEvent) bool {
 		return isHotkeyKey(e.Keysym.Sym)
 	}
 
+	// Shift+number saves to that slot. Checked ahead of the table, whose
+	// plain number keys (6-8) would otherwise take it.
+	if e.Keysym.Mod&sdl.KMOD_SHIFT != 0 && e.Repeat == 0 {
+		if slot, ok := numberSlot(e.Keysym.Sym); ok {
+			g.saveStateSlot(slot)
+			return true
+		}
+	}
+
 	// Table-driven simple hotkeys. Mirrors the original switch's behaviour:
 	// a row that matches key+modMask but fails the repeat gate is *not*
 	// consumed — the original switch falls through, returning false, so the
@@ -119,7 +301,7 @@ func (g *NESGUI) handleHotkey(e *sdl.KeyboardEvent) bool {
 		if muted {
 			state = "MUTED"
 		}
-		logger.LogInfo("Channel %s: %s", name, state)
+		g.notify("Channel %s: %s", name, state)
 		return true
 	}
 
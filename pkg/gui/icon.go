@@ -0,0 +1,69 @@
+// Package gui — the window icon, a controller drawn in iconArt.
+package gui
+
+import (
+	"runtime"
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+)
+
+// iconArt is the window icon, one string per row, one character per pixel
+// coloured from iconColors; '.' is transparent.
+var iconArt = [...]string{
+	"........k.......",
+	"........k.......",
+	"........k.......",
+	"........k.......",
+	"kkkkkkkkkkkkkkkk",
+	"kggggggggggggggk",
+	"kgbbbbbbbbbbbbgk",
+	"kgbbgbbbbbbbbbgk",
+	"kgbgggbbbrrbrrgk",
+	"kgbbgbbbbrrbrrgk",
+	"kgbbbbwbwbbbbbgk",
+	"kggggggggggggggk",
+	"kkkkkkkkkkkkkkkk",
+	"................",
+	"................",
+	"................",
+}
+
+// iconColors maps iconArt's characters to ARGB8888.
+var iconColors = map[byte]uint32{
+	'.': 0x00000000,
+	'k': 0xFF404040, // outline and cord
+	'g': 0xFFB0B0B0, // case
+	'b': 0xFF202020, // face plate and D-pad
+	'w': 0xFF808080, // Select, Start
+	'r': 0xFFC01010, // B, A
+}
+
+// iconPixels returns iconArt as ARGB8888 pixels, row by row, with its
+// width and height.
+func iconPixels() ([]uint32, int, int) {
+	w, h := len(iconArt[0]), len(iconArt)
+	pix := make([]uint32, 0, w*h)
+	for _, row := range iconArt {
+		for i := 0; i < len(row); i++ {
+			pix = append(pix, iconColors[row[i]])
+		}
+	}
+	return pix, w, h
+}
+
+// setWindowIcon gives window the controller icon. A failure only leaves
+// the platform's default icon, so it is logged and otherwise ignored.
+func setWindowIcon(window *sdl.Window) {
+	pix, w, h := iconPixels()
+	surface, err := sdl.CreateRGBSurfaceWithFormatFrom(unsafe.Pointer(&pix[0]),
+		int32(w), int32(h), 32, int32(w*4), sdl.PIXELFORMAT_ARGB8888)
+	if err != nil {
+		logger.LogError("Window icon: %v", err)
+		return
+	}
+	window.SetIcon(surface) // SDL copies the pixels
+	surface.Free()
+	runtime.KeepAlive(pix)
+}
@@ -1,7 +1,10 @@
 package gui
 
 import (
+	"fmt"
+
 	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/input"
 	"github.com/yoshiomiyamaegones/pkg/logger"
 	"github.com/yoshiomiyamaegones/pkg/nes"
 )
@@ -11,15 +14,57 @@ type InputManager struct {
 	nes             *nes.NES
 	joysticks       []*sdl.Joystick
 	gameControllers []*sdl.GameController
+
+	// staging collects button changes as the events arrive; the NES
+	// latches it into the controllers once per frame, so a press never
+	// lands halfway through one.
+	staging *input.Staging
+
+	// keys maps each player-1 button (A, B, Select, Start, Up, Down, Left,
+	// Right) to its keyboard key.
+	keys [8]sdl.Keycode
+}
+
+// defaultKeys is the stock keyboard layout: Z/X for A/B, A/S for
+// Select/Start, arrow keys for the D-pad.
+var defaultKeys = [8]sdl.Keycode{
+	sdl.K_z, sdl.K_x, sdl.K_a, sdl.K_s,
+	sdl.K_UP, sdl.K_DOWN, sdl.K_LEFT, sdl.K_RIGHT,
 }
 
-// NewInputManager creates a new input manager
+// NewInputManager creates a new input manager and makes its staging
+// buffer the NES's input provider for all four controllers.
 func NewInputManager(nesSystem *nes.NES) *InputManager {
-	return &InputManager{
+	im := &InputManager{
 		nes:             nesSystem,
-		joysticks:       make([]*sdl.Joystick, 0, 2),
-		gameControllers: make([]*sdl.GameController, 0, 2),
+		joysticks:       make([]*sdl.Joystick, 0, 4),
+		gameControllers: make([]*sdl.GameController, 0, 4),
+		staging:         &input.Staging{},
+		keys:            defaultKeys,
+	}
+	for i := 0; i < 4; i++ {
+		nesSystem.SetInputProvider(i, im.staging.Pad(i))
+	}
+	return im
+}
+
+// SetKeyBindings rebinds player 1's keyboard buttons from SDL key names
+// ("Z", "Up", "Left Shift", ...) in NES button order. An empty name keeps
+// the default key. If any name is unknown nothing is changed.
+func (im *InputManager) SetKeyBindings(names [8]string) error {
+	keys := defaultKeys
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		k := sdl.GetKeyFromName(name)
+		if k == sdl.K_UNKNOWN {
+			return fmt.Errorf("unknown key name %q", name)
+		}
+		keys[i] = k
 	}
+	im.keys = keys
+	return nil
 }
 
 // Initialize prepares the input manager. Devices are opened lazily via the
@@ -30,8 +75,15 @@ func (im *InputManager) Initialize() {
 	logger.LogInfo("Input ready (controllers detected via hot-plug events). Use keyboard if none connected.")
 }
 
-// maxControllers is the limit (NES has two controller ports).
-const maxControllers = 2
+// maxControllers is the number of gamepads routed to the NES: one per
+// controller port, or four with a Four Score attached (slots 3 and 4 drive
+// its third and fourth pads).
+func (im *InputManager) maxControllers() int {
+	if im.nes.GetInput().FourScore() {
+		return 4
+	}
+	return 2
+}
 
 // gameControllerSlot returns the slice index of an opened SDL GameController
 // matching the given instance ID, or -1 if not found. Used to route
@@ -118,8 +170,8 @@ func (im *InputManager) HandleEvent(event sdl.Event) bool {
 func (im *InputManager) handleControllerDevice(event *sdl.ControllerDeviceEvent) {
 	switch event.Type {
 	case sdl.CONTROLLERDEVICEADDED:
-		if len(im.gameControllers) >= maxControllers {
-			logger.LogInfo("Ignoring extra GameController (NES supports at most %d)", maxControllers)
+		if len(im.gameControllers) >= im.maxControllers() {
+			logger.LogInfo("Ignoring extra GameController (NES supports at most %d)", im.maxControllers())
 			return
 		}
 		controller := sdl.GameControllerOpen(int(event.Which))
@@ -150,8 +202,8 @@ func (im *InputManager) handleJoyDeviceAdded(event *sdl.JoyDeviceAddedEvent) {
 	if sdl.IsGameController(deviceIndex) {
 		return
 	}
-	if len(im.joysticks) >= maxControllers {
-		logger.LogInfo("Ignoring extra joystick (NES supports at most %d)", maxControllers)
+	if len(im.joysticks) >= im.maxControllers() {
+		logger.LogInfo("Ignoring extra joystick (NES supports at most %d)", im.maxControllers())
 		return
 	}
 	joy := sdl.JoystickOpen(deviceIndex)
@@ -179,25 +231,12 @@ func (im *InputManager) handleJoyDeviceRemoved(event *sdl.JoyDeviceRemovedEvent)
 // handleKeyboard maps keyboard input to NES controller
 func (im *InputManager) handleKeyboard(event *sdl.KeyboardEvent) {
 	pressed := event.State == sdl.PRESSED
-	input := im.nes.GetInput()
-
-	switch event.Keysym.Sym {
-	case sdl.K_z: // A button
-		input.SetButton(0, 0, pressed)
-	case sdl.K_x: // B button
-		input.SetButton(0, 1, pressed)
-	case sdl.K_a: // Select
-		input.SetButton(0, 2, pressed)
-	case sdl.K_s: // Start
-		input.SetButton(0, 3, pressed)
-	case sdl.K_UP:
-		input.SetButton(0, 4, pressed)
-	case sdl.K_DOWN:
-		input.SetButton(0, 5, pressed)
-	case sdl.K_LEFT:
-		input.SetButton(0, 6, pressed)
-	case sdl.K_RIGHT:
-		input.SetButton(0, 7, pressed)
+	pads := im.staging
+
+	for button, key := range im.keys {
+		if event.Keysym.Sym == key {
+			pads.SetButton(0, button, pressed)
+		}
 	}
 }
 
@@ -209,22 +248,22 @@ func (im *InputManager) handleJoyButton(event *sdl.JoyButtonEvent) {
 		return
 	}
 
-	input := im.nes.GetInput()
+	pads := im.staging
 
 	// Standard gamepad button mapping (works with most controllers)
 	switch event.Button {
 	case 0: // A button
-		input.SetButton(controllerIndex, 0, pressed)
+		pads.SetButton(controllerIndex, 0, pressed)
 	case 1: // B button
-		input.SetButton(controllerIndex, 1, pressed)
+		pads.SetButton(controllerIndex, 1, pressed)
 	case 2: // X button - also map to B
-		input.SetButton(controllerIndex, 1, pressed)
+		pads.SetButton(controllerIndex, 1, pressed)
 	case 3: // Y button - also map to A
-		input.SetButton(controllerIndex, 0, pressed)
+		pads.SetButton(controllerIndex, 0, pressed)
 	case 8: // Select/Back button
-		input.SetButton(controllerIndex, 2, pressed)
+		pads.SetButton(controllerIndex, 2, pressed)
 	case 9: // Start button
-		input.SetButton(controllerIndex, 3, pressed)
+		pads.SetButton(controllerIndex, 3, pressed)
 	}
 }
 
@@ -235,31 +274,31 @@ func (im *InputManager) handleJoyAxis(event *sdl.JoyAxisEvent) {
 		return
 	}
 
-	input := im.nes.GetInput()
+	pads := im.staging
 	deadzone := int16(8000)
 
 	switch event.Axis {
 	case 0: // Left stick horizontal
 		if event.Value < -deadzone {
-			input.SetButton(controllerIndex, 6, true)
-			input.SetButton(controllerIndex, 7, false)
+			pads.SetButton(controllerIndex, 6, true)
+			pads.SetButton(controllerIndex, 7, false)
 		} else if event.Value > deadzone {
-			input.SetButton(controllerIndex, 7, true)
-			input.SetButton(controllerIndex, 6, false)
+			pads.SetButton(controllerIndex, 7, true)
+			pads.SetButton(controllerIndex, 6, false)
 		} else {
-			input.SetButton(controllerIndex, 6, false)
-			input.SetButton(controllerIndex, 7, false)
+			pads.SetButton(controllerIndex, 6, false)
+			pads.SetButton(controllerIndex, 7, false)
 		}
 	case 1: // Left stick vertical
 		if event.Value < -deadzone {
-			input.SetButton(controllerIndex, 4, true)
-			input.SetButton(controllerIndex, 5, false)
+			pads.SetButton(controllerIndex, 4, true)
+			pads.SetButton(controllerIndex, 5, false)
 		} else if event.Value > deadzone {
-			input.SetButton(controllerIndex, 5, true)
-			input.SetButton(controllerIndex, 4, false)
+			pads.SetButton(controllerIndex, 5, true)
+			pads.SetButton(controllerIndex, 4, false)
 		} else {
-			input.SetButton(controllerIndex, 4, false)
-			input.SetButton(controllerIndex, 5, false)
+			pads.SetButton(controllerIndex, 4, false)
+			pads.SetButton(controllerIndex, 5, false)
 		}
 	}
 }
@@ -271,12 +310,12 @@ func (im *InputManager) handleJoyHat(event *sdl.JoyHatEvent) {
 		return
 	}
 
-	input := im.nes.GetInput()
+	pads := im.staging
 
-	input.SetButton(controllerIndex, 4, event.Value&sdl.HAT_UP != 0)
-	input.SetButton(controllerIndex, 5, event.Value&sdl.HAT_DOWN != 0)
-	input.SetButton(controllerIndex, 6, event.Value&sdl.HAT_LEFT != 0)
-	input.SetButton(controllerIndex, 7, event.Value&sdl.HAT_RIGHT != 0)
+	pads.SetButton(controllerIndex, 4, event.Value&sdl.HAT_UP != 0)
+	pads.SetButton(controllerIndex, 5, event.Value&sdl.HAT_DOWN != 0)
+	pads.SetButton(controllerIndex, 6, event.Value&sdl.HAT_LEFT != 0)
+	pads.SetButton(controllerIndex, 7, event.Value&sdl.HAT_RIGHT != 0)
 }
 
 // handleControllerButton handles SDL GameController button events
@@ -287,30 +326,30 @@ func (im *InputManager) handleControllerButton(event *sdl.ControllerButtonEvent)
 		return
 	}
 
-	input := im.nes.GetInput()
+	pads := im.staging
 
 	// SDL GameController API provides standardized button mapping
 	switch event.Button {
 	case sdl.CONTROLLER_BUTTON_A:
-		input.SetButton(controllerIndex, 0, pressed)
+		pads.SetButton(controllerIndex, 0, pressed)
 	case sdl.CONTROLLER_BUTTON_B:
-		input.SetButton(controllerIndex, 1, pressed)
+		pads.SetButton(controllerIndex, 1, pressed)
 	case sdl.CONTROLLER_BUTTON_X:
-		input.SetButton(controllerIndex, 1, pressed)
+		pads.SetButton(controllerIndex, 1, pressed)
 	case sdl.CONTROLLER_BUTTON_Y:
-		input.SetButton(controllerIndex, 0, pressed)
+		pads.SetButton(controllerIndex, 0, pressed)
 	case sdl.CONTROLLER_BUTTON_BACK:
-		input.SetButton(controllerIndex, 2, pressed)
+		pads.SetButton(controllerIndex, 2, pressed)
 	case sdl.CONTROLLER_BUTTON_START:
-		input.SetButton(controllerIndex, 3, pressed)
+		pads.SetButton(controllerIndex, 3, pressed)
 	case sdl.CONTROLLER_BUTTON_DPAD_UP:
-		input.SetButton(controllerIndex, 4, pressed)
+		pads.SetButton(controllerIndex, 4, pressed)
 	case sdl.CONTROLLER_BUTTON_DPAD_DOWN:
-		input.SetButton(controllerIndex, 5, pressed)
+		pads.SetButton(controllerIndex, 5, pressed)
 	case sdl.CONTROLLER_BUTTON_DPAD_LEFT:
-		input.SetButton(controllerIndex, 6, pressed)
+		pads.SetButton(controllerIndex, 6, pressed)
 	case sdl.CONTROLLER_BUTTON_DPAD_RIGHT:
-		input.SetButton(controllerIndex, 7, pressed)
+		pads.SetButton(controllerIndex, 7, pressed)
 	}
 }
 
@@ -321,31 +360,31 @@ func (im *InputManager) handleControllerAxis(event *sdl.ControllerAxisEvent) {
 		return
 	}
 
-	input := im.nes.GetInput()
+	pads := im.staging
 	deadzone := int16(8000)
 
 	switch event.Axis {
 	case sdl.CONTROLLER_AXIS_LEFTX:
 		if event.Value < -deadzone {
-			input.SetButton(controllerIndex, 6, true)
-			input.SetButton(controllerIndex, 7, false)
+			pads.SetButton(controllerIndex, 6, true)
+			pads.SetButton(controllerIndex, 7, false)
 		} else if event.Value > deadzone {
-			input.SetButton(controllerIndex, 7, true)
-			input.SetButton(controllerIndex, 6, false)
+			pads.SetButton(controllerIndex, 7, true)
+			pads.SetButton(controllerIndex, 6, false)
 		} else {
-			input.SetButton(controllerIndex, 6, false)
-			input.SetButton(controllerIndex, 7, false)
+			pads.SetButton(controllerIndex, 6, false)
+			pads.SetButton(controllerIndex, 7, false)
 		}
 	case sdl.CONTROLLER_AXIS_LEFTY:
 		if event.Value < -deadzone {
-			input.SetButton(controllerIndex, 4, true)
-			input.SetButton(controllerIndex, 5, false)
+			pads.SetButton(controllerIndex, 4, true)
+			pads.SetButton(controllerIndex, 5, false)
 		} else if event.Value > deadzone {
-			input.SetButton(controllerIndex, 5, true)
-			input.SetButton(controllerIndex, 4, false)
+			pads.SetButton(controllerIndex, 5, true)
+			pads.SetButton(controllerIndex, 4, false)
 		} else {
-			input.SetButton(controllerIndex, 4, false)
-			input.SetButton(controllerIndex, 5, false)
+			pads.SetButton(controllerIndex, 4, false)
+			pads.SetButton(controllerIndex, 5, false)
 		}
 	}
 }
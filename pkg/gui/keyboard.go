@@ -0,0 +1,96 @@
+// Package gui — typing on the Family BASIC keyboard (Ctrl+K).
+//
+// With the keyboard in the expansion port (-expansion keyboard), Ctrl+K
+// hands the PC keyboard to it: every key goes to the matrix, by name where
+// the keys match and by position for the Famicom's own (GRPH, KANA, STOP,
+// ...), until Ctrl+K is pressed again. Hotkeys and player 1's keys are off
+// meanwhile; gamepads still play.
+package gui
+
+import (
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/input"
+)
+
+// osdKeyKeyboard is the persistent line shown while typing.
+const osdKeyKeyboard = "keyboard"
+
+// familyKeys names the keys whose SDL name isn't the Family BASIC one;
+// letters, digits, F1-F8 and the punctuation both share go by SDL name.
+var familyKeys = map[sdl.Keycode]string{
+	sdl.K_RETURN:    "RETURN",
+	sdl.K_KP_ENTER:  "RETURN",
+	sdl.K_SPACE:     "SPACE",
+	sdl.K_ESCAPE:    "ESC",
+	sdl.K_LCTRL:     "CTRL",
+	sdl.K_LSHIFT:    "LSHIFT",
+	sdl.K_RSHIFT:    "RSHIFT",
+	sdl.K_LALT:      "GRPH",
+	sdl.K_RALT:      "KANA",
+	sdl.K_END:       "STOP",
+	sdl.K_HOME:      "CLR",
+	sdl.K_INSERT:    "INS",
+	sdl.K_BACKSPACE: "DEL",
+	sdl.K_DELETE:    "DEL",
+	sdl.K_UP:        "UP",
+	sdl.K_DOWN:      "DOWN",
+	sdl.K_LEFT:      "LEFT",
+	sdl.K_RIGHT:     "RIGHT",
+	sdl.K_EQUALS:    "^",
+	sdl.K_QUOTE:     ":",
+	sdl.K_BACKQUOTE: "@",
+	sdl.K_BACKSLASH: "YEN",
+	sdl.K_RCTRL:     "_",
+}
+
+// familyKeyName is the Family BASIC key sym types.
+func familyKeyName(sym sdl.Keycode) string {
+	if name, ok := familyKeys[sym]; ok {
+		return name
+	}
+	return strings.ToUpper(sdl.GetKeyName(sym))
+}
+
+// familyKeyboard is the keyboard in the expansion port, or nil.
+func (g *NESGUI) familyKeyboard() *input.Keyboard {
+	kb, _ := g.nes.GetInput().Expansion().(*input.Keyboard)
+	return kb
+}
+
+// toggleKeyboardCapture starts or stops typing on the Family BASIC
+// keyboard.
+func (g *NESGUI) toggleKeyboardCapture() {
+	kb := g.familyKeyboard()
+	if kb == nil {
+		g.notify("Keyboard: not connected (-expansion keyboard)")
+		return
+	}
+	g.kbCapture = !g.kbCapture
+	kb.Release()
+	if g.kbCapture {
+		g.osd.SetPersistent(osdKeyKeyboard, "KEYBOARD  Ctrl+K:release")
+	} else {
+		g.osd.SetPersistent(osdKeyKeyboard, "")
+	}
+}
+
+// handleKeyboardKey consumes every keyboard event while typing.
+func (g *NESGUI) handleKeyboardKey(e *sdl.KeyboardEvent) {
+	if e.Keysym.Sym == sdl.K_k && e.Keysym.Mod&sdl.KMOD_CTRL != 0 {
+		if e.State == sdl.PRESSED && e.Repeat == 0 {
+			g.toggleKeyboardCapture()
+		}
+		return
+	}
+	kb := g.familyKeyboard()
+	if kb == nil { // unplugged since, through the Go API
+		g.kbCapture = false
+		g.osd.SetPersistent(osdKeyKeyboard, "")
+		return
+	}
+	if e.Repeat == 0 {
+		kb.SetKey(familyKeyName(e.Keysym.Sym), e.State == sdl.PRESSED)
+	}
+}
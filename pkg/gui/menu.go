@@ -0,0 +1,387 @@
+// Package gui — the Esc menu.
+//
+// Esc opens a menu over the picture from which everything the command
+// line and the hotkeys set up can be reached: a file browser and the
+// recent list for loading ROMs, the state picker, the window scale, the
+// video filter, the palette, the APU's region, player 1's keys and the
+// loaded cheats. Each submenu is a page pushed on a stack; Esc or
+// Backspace goes back a page and closes the menu from the first. Like the
+// state picker it takes every key while open, and the game keeps running
+// underneath.
+package gui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/apu"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/osd"
+)
+
+// maxScale is the largest window scale the menu offers.
+const maxScale = 8
+
+// menuItem is one line of a page. label renders it, with the current
+// value for a setting; activate runs on Enter and adjust on Left/Right
+// with -1 or +1. Either may be nil.
+type menuItem struct {
+	label    func(*NESGUI) string
+	activate func(*NESGUI)
+	adjust   func(*NESGUI, int)
+}
+
+// menuPage is one screen of the menu. Pages are built when they open, so
+// lists (files, cheats) are as of then.
+type menuPage struct {
+	title    string
+	items    []menuItem
+	selected int
+}
+
+// gameMenu is the open menu: its pages, the top one showing, and the
+// player 1 button waiting for a key on the controls page (-1 for none).
+type gameMenu struct {
+	pages   []*menuPage
+	binding int
+}
+
+// text is a menuItem label that doesn't change.
+func text(s string) func(*NESGUI) string { return func(*NESGUI) string { return s } }
+
+// openMenu opens the menu on its first page, or closes it when it's open.
+func (g *NESGUI) openMenu() {
+	if g.menu != nil {
+		g.closeMenu()
+		return
+	}
+	g.menu = &gameMenu{binding: -1}
+	g.pushMenuPage(g.rootMenuPage())
+	g.osd.SetPersistent(osdKeyMenuHelp, "Up/Down  Left/Right:change  Enter  Esc:back")
+}
+
+func (g *NESGUI) closeMenu() {
+	g.menu = nil
+	g.osd.SetPersistent(osdKeyMenuHelp, "")
+}
+
+func (g *NESGUI) pushMenuPage(p *menuPage) { g.menu.pages = append(g.menu.pages, p) }
+
+// popMenuPage goes back a page, closing the menu from the first.
+func (g *NESGUI) popMenuPage() {
+	if len(g.menu.pages) <= 1 {
+		g.closeMenu()
+		return
+	}
+	g.menu.pages = g.menu.pages[:len(g.menu.pages)-1]
+}
+
+// replaceMenuPage swaps the top page for p, as the file browser does
+// moving between directories so Esc still leaves it in one step.
+func (g *NESGUI) replaceMenuPage(p *menuPage) { g.menu.pages[len(g.menu.pages)-1] = p }
+
+func (g *NESGUI) menuPage() *menuPage { return g.menu.pages[len(g.menu.pages)-1] }
+
+// handleMenuKey consumes every keyboard event while the menu is open.
+// While a button is waiting for its key, the next key pressed is taken,
+// Esc excepted, which cancels.
+func (g *NESGUI) handleMenuKey(e *sdl.KeyboardEvent) {
+	if e.State != sdl.PRESSED {
+		return
+	}
+	m := g.menu
+	if m.binding >= 0 {
+		if e.Keysym.Sym != sdl.K_ESCAPE {
+			g.bindKey(m.binding, e.Keysym.Sym)
+		}
+		m.binding = -1
+		return
+	}
+	p := g.menuPage()
+	var item *menuItem
+	if len(p.items) > 0 {
+		item = &p.items[p.selected]
+	}
+	switch e.Keysym.Sym {
+	case sdl.K_ESCAPE, sdl.K_BACKSPACE:
+		g.popMenuPage()
+	case sdl.K_UP:
+		if n := len(p.items); n > 0 {
+			p.selected = (p.selected + n - 1) % n
+		}
+	case sdl.K_DOWN:
+		if n := len(p.items); n > 0 {
+			p.selected = (p.selected + 1) % n
+		}
+	case sdl.K_LEFT, sdl.K_RIGHT:
+		if item != nil && item.adjust != nil {
+			dir := 1
+			if e.Keysym.Sym == sdl.K_LEFT {
+				dir = -1
+			}
+			item.adjust(g, dir)
+		}
+	case sdl.K_RETURN, sdl.K_KP_ENTER:
+		switch {
+		case item == nil:
+		case item.activate != nil:
+			item.activate(g)
+		case item.adjust != nil:
+			item.adjust(g, 1)
+		}
+	}
+}
+
+// drawMenu draws the top page into textureBuf. Called from drawOSD with
+// emuMu held.
+func (g *NESGUI) drawMenu(w, h int) {
+	p := g.menuPage()
+	labels := make([]string, len(p.items))
+	for i, item := range p.items {
+		labels[i] = item.label(g)
+	}
+	footer := ""
+	if g.menu.binding >= 0 {
+		footer = "Press a key (Esc cancels)"
+	}
+	osd.DrawMenu(g.textureBuf, w, h, osd.Menu{
+		Title:    p.title,
+		Items:    labels,
+		Selected: p.selected,
+		Footer:   footer,
+	})
+}
+
+// rootMenuPage is the first page.
+func (g *NESGUI) rootMenuPage() *menuPage {
+	return &menuPage{title: "GoNES", items: []menuItem{
+		{label: text("Resume"), activate: (*NESGUI).closeMenu},
+		{label: text("Load ROM..."), activate: func(g *NESGUI) { g.openBrowser(g.browseStart()) }},
+		{label: text("Recent ROMs..."), activate: (*NESGUI).openRecentPage},
+		{label: text("Save states..."), activate: func(g *NESGUI) {
+			g.closeMenu()
+			g.openStatePicker()
+		}},
+		{
+			label:  func(g *NESGUI) string { return fmt.Sprintf("Scale: %dx", g.scale()) },
+			adjust: func(g *NESGUI, dir int) { g.setScale((g.scale()+dir+maxScale-1)%maxScale + 1) },
+		},
+		{
+			label:  func(g *NESGUI) string { return "Filter: " + g.filterName() },
+			adjust: (*NESGUI).cycleFilter,
+		},
+		{
+			label: func(g *NESGUI) string {
+				if g.nes.PPU.PaletteManager.NTSC() != nil {
+					return "Palette: NTSC"
+				}
+				return "Palette: Standard"
+			},
+			adjust: func(g *NESGUI, _ int) { g.toggleNTSCPalette() },
+		},
+		{
+			// Only the APU's period tables follow it; the CPU and PPU keep
+			// NTSC timing, so this isn't a PAL mode.
+			label: func(g *NESGUI) string { return "APU region: " + g.nes.APU.Region().String() },
+			adjust: func(g *NESGUI, _ int) {
+				r := apu.RegionPAL
+				if g.nes.APU.Region() == apu.RegionPAL {
+					r = apu.RegionNTSC
+				}
+				g.nes.APU.SetRegion(r)
+			},
+		},
+		{label: text("Controls..."), activate: func(g *NESGUI) { g.pushMenuPage(g.controlsPage()) }},
+		{label: text("Cheats..."), activate: func(g *NESGUI) { g.pushMenuPage(g.cheatsPage()) }},
+		{label: text("Quit"), activate: (*NESGUI).quit},
+	}}
+}
+
+// scale is the window's size as a multiple of the picture.
+func (g *NESGUI) scale() int {
+	if g.opts.Scale <= 0 {
+		return WindowScale
+	}
+	return g.opts.Scale
+}
+
+// setScale resizes the window to s times the (cropped) picture.
+func (g *NESGUI) setScale(s int) {
+	g.opts.Scale = s
+	if g.window != nil {
+		w, h := g.opts.Overscan.size()
+		g.window.SetSize(int32(w*s), int32(h*s))
+	}
+}
+
+// toggleNTSCPalette switches between the palette generated from the NTSC
+// signal, at the TV controls' settings (see tv.go), and the fixed one.
+func (g *NESGUI) toggleNTSCPalette() {
+	pm := g.nes.PPU.PaletteManager
+	if pm.NTSC() != nil {
+		pm.SetNTSC(nil)
+		return
+	}
+	n := g.tvNTSC()
+	pm.SetNTSC(&n)
+}
+
+// romExtensions are the files the browser lists: ROMs and the archives
+// openROM looks inside.
+var romExtensions = []string{".nes", ".fds", ".zip", ".gz"}
+
+// browseStart is where the browser opens: the running ROM's directory,
+// else the working directory.
+func (g *NESGUI) browseStart() string {
+	if g.romPath != "" {
+		return filepath.Dir(g.romPath)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		return wd
+	}
+	return "."
+}
+
+// openBrowser pushes a file browser page on dir.
+func (g *NESGUI) openBrowser(dir string) {
+	if p := g.browserPage(dir); p != nil {
+		g.pushMenuPage(p)
+	}
+}
+
+// browserPage lists dir: its parent, then its subdirectories, then the
+// ROMs in it, hidden files left out. nil if dir can't be read.
+func (g *NESGUI) browserPage(dir string) *menuPage {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logger.LogError("Load ROM: %v", err)
+		g.notify("Cannot open %s", filepath.Base(dir))
+		return nil
+	}
+	p := &menuPage{title: dir}
+	into := func(path string) func(*NESGUI) {
+		return func(g *NESGUI) {
+			if next := g.browserPage(path); next != nil {
+				g.replaceMenuPage(next)
+			}
+		}
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		p.items = append(p.items, menuItem{label: text("../"), activate: into(parent)})
+	}
+	var files []menuItem
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if e.IsDir() {
+			p.items = append(p.items, menuItem{label: text(name + "/"), activate: into(path)})
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(name))
+		for _, want := range romExtensions {
+			if ext == want {
+				files = append(files, menuItem{label: text(name), activate: func(g *NESGUI) { g.menuLoadROM(path) }})
+				break
+			}
+		}
+	}
+	p.items = append(p.items, files...)
+	return p
+}
+
+// menuLoadROM closes the menu and loads the ROM at path.
+func (g *NESGUI) menuLoadROM(path string) {
+	g.closeMenu()
+	if err := g.loadROM(path); err != nil {
+		logger.LogError("Load %s: %v", path, err)
+		g.osd.Notify("Cannot load " + filepath.Base(path))
+	}
+}
+
+// openRecentPage pushes the recent-ROMs list (see recent.go).
+func (g *NESGUI) openRecentPage() {
+	if g.recent == nil || len(g.recent.entries) == 0 {
+		g.notify("Recent ROMs: list is empty")
+		return
+	}
+	p := &menuPage{title: "Recent ROMs"}
+	for _, path := range g.recent.entries {
+		path := path
+		p.items = append(p.items, menuItem{label: text(filepath.Base(path)), activate: func(g *NESGUI) { g.menuLoadROM(path) }})
+	}
+	g.pushMenuPage(p)
+}
+
+// buttonNames are player 1's buttons in InputManager.keys order.
+var buttonNames = [8]string{"A", "B", "Select", "Start", "Up", "Down", "Left", "Right"}
+
+// controlsPage lists player 1's keys; Enter on a button waits for its new
+// key. The bindings last until the emulator exits — Options.Keys (the
+// config file) is where they are kept.
+func (g *NESGUI) controlsPage() *menuPage {
+	p := &menuPage{title: "Controls (player 1)"}
+	for i, name := range buttonNames {
+		i, name := i, name
+		p.items = append(p.items, menuItem{
+			label: func(g *NESGUI) string {
+				if g.menu.binding == i {
+					return name + ": ..."
+				}
+				return name + ": " + sdl.GetKeyName(g.inputManager.keys[i])
+			},
+			activate: func(g *NESGUI) { g.menu.binding = i },
+		})
+	}
+	p.items = append(p.items, menuItem{
+		label:    text("Reset to defaults"),
+		activate: func(g *NESGUI) { g.inputManager.keys = defaultKeys },
+	})
+	return p
+}
+
+// bindKey gives button key. A button that had key already takes over the
+// button's old one, so no key ends up on two buttons.
+func (g *NESGUI) bindKey(button int, key sdl.Keycode) {
+	keys := &g.inputManager.keys
+	for i, k := range keys {
+		if k == key && i != button {
+			keys[i] = keys[button]
+		}
+	}
+	keys[button] = key
+	g.inputManager.staging.SetButtons(0, 0) // a held button's key may have moved
+}
+
+// cheatsPage has the master switch and a line per loaded cheat.
+func (g *NESGUI) cheatsPage() *menuPage {
+	p := &menuPage{title: "Cheats", items: []menuItem{{
+		label: func(g *NESGUI) string {
+			return fmt.Sprintf("All cheats: %s (%d loaded)", onOff(g.nes.Cheats.Enabled()), g.nes.Cheats.Count())
+		},
+		adjust: func(g *NESGUI, _ int) { g.nes.Cheats.ToggleAll() },
+	}}}
+	for i := range g.nes.Cheats.List() {
+		i := i
+		p.items = append(p.items, menuItem{
+			label: func(g *NESGUI) string {
+				c := g.nes.Cheats.List()[i]
+				mark := "[ ]"
+				if c.Enabled {
+					mark = "[x]"
+				}
+				if c.Comment != "" {
+					return fmt.Sprintf("%s %s %s", mark, c.Source, c.Comment)
+				}
+				return mark + " " + c.Source
+			},
+			adjust: func(g *NESGUI, _ int) { g.nes.Cheats.Toggle(i) },
+		})
+	}
+	return p
+}
@@ -0,0 +1,106 @@
+// Package gui — on-screen display glue.
+//
+// User-facing feedback (hotkey toggles, state slots, ROM swaps) goes
+// through notify so it appears over the picture rather than only in the
+// log. Persistent lines (FPS, the recent-ROMs menu) are keyed so each
+// feature can update or clear its own line without touching the others.
+package gui
+
+import (
+	"fmt"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/osd"
+)
+
+// Persistent OSD line keys.
+const (
+	osdKeyFPS      = "fps"
+	osdKeyStats    = "stats"
+	osdKeyStats2   = "stats2"
+	osdKeyMute     = "mute"
+	osdKeyPause    = "pause"
+	osdKeyMenu     = "menu"
+	osdKeyMenuHelp = "menu-help"
+)
+
+// notify logs a message at info level and flashes it on the OSD.
+func (g *NESGUI) notify(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	logger.LogInfo("%s", msg)
+	g.osd.Notify(msg)
+}
+
+// onOff renders a toggle state for notify messages.
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// drawOSD refreshes the FPS, mute and pause lines and composites the OSD —
+// and, with the input display, profiler, oscilloscope, CHR viewer, state
+// picker or menu on, the controllers, the scanline bar, the channel scopes,
+// the pattern tables, the picker or the menu —
+// into textureBuf.
+// Skipped entirely when there is nothing to show, which is the common case
+// with FPS display off.
+func (g *NESGUI) drawOSD() {
+	fps := ""
+	if g.showFPS {
+		fps = fmt.Sprintf("%.1f FPS", g.currentFPS)
+		if g.turbo {
+			fps += " TURBO"
+		}
+		if g.audioDevice != 0 {
+			a := g.AudioStats()
+			fps += fmt.Sprintf(" audio %dms", a.Latency.Milliseconds())
+			if a.Underruns > 0 {
+				fps += fmt.Sprintf(" %d underruns", a.Underruns)
+			}
+		}
+	}
+	g.osd.SetPersistent(osdKeyFPS, fps)
+	stats, stats2 := "", ""
+	if g.showStats {
+		stats, stats2 = g.stats().statsLines()
+	}
+	g.osd.SetPersistent(osdKeyStats, stats)
+	g.osd.SetPersistent(osdKeyStats2, stats2)
+	mute := ""
+	if g.nes.APU.Muted {
+		mute = "MUTE"
+	}
+	g.osd.SetPersistent(osdKeyMute, mute)
+	pause := ""
+	if g.isPaused() {
+		pause = "PAUSED"
+	}
+	g.osd.SetPersistent(osdKeyPause, pause)
+	w, h := g.opts.Overscan.size()
+	if g.showInput {
+		g.padBuf = g.nes.Input.AppendButtons(g.padBuf[:0])
+		osd.DrawPads(g.textureBuf, w, h, g.padBuf)
+	}
+	if g.profiler != nil {
+		g.lineColors = g.profiler.LineColors(g.lineColors)
+		osd.DrawScanlineBar(g.textureBuf, w, h, g.lineColors)
+	}
+	if g.nes.APU.Taps != nil {
+		g.drawScopes(w, h)
+	}
+	if g.chrDiff != nil {
+		g.drawCHR(w, h)
+	}
+	if g.picker != nil {
+		g.drawStatePicker(w, h)
+	}
+	if g.menu != nil {
+		g.drawMenu(w, h)
+	}
+	if g.osd.Empty() {
+		return
+	}
+	g.osd.Draw(g.textureBuf, w, h)
+}
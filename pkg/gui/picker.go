@@ -0,0 +1,154 @@
+// Package gui — the save-state picker (Ctrl+S).
+//
+// The picker lays the ROM's ten slots out in a row, numbered 1-9 and 0
+// as on the keyboard, and shows the selected one's thumbnail, save time
+// and play time from the metadata saveStateSlot writes beside each state
+// (package savestate). A slot saved before there was metadata shows
+// without a preview. Like the recent-ROMs menu it takes every key while
+// open.
+package gui
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/osd"
+	"github.com/yoshiomiyamaegones/pkg/savestate"
+)
+
+// statePicker is the open picker's view of the slots, read when it opens.
+type statePicker struct {
+	slot   int // selected, 1 to savestate.Slots
+	filled []bool
+	metas  [savestate.Slots]*savestate.Meta
+
+	// thumb is the selected slot's decoded thumbnail, nil for none.
+	thumb          []uint32
+	thumbW, thumbH int
+}
+
+// openStatePicker opens the picker on the most recently saved slot, or
+// closes it when it's open.
+func (g *NESGUI) openStatePicker() {
+	if g.picker != nil {
+		g.closeStatePicker()
+		return
+	}
+	if g.romPath == "" {
+		g.notify("States: no ROM loaded")
+		return
+	}
+	p := &statePicker{slot: 1, filled: make([]bool, savestate.Slots)}
+	for slot := 1; slot <= savestate.Slots; slot++ {
+		path := g.stateSlotPath(slot)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		p.filled[slot-1] = true
+		m, err := savestate.Read(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logger.LogError("State picker: %v", err)
+			}
+			continue
+		}
+		p.metas[slot-1] = &m
+		if newest := p.metas[p.slot-1]; newest == nil || m.Saved.After(newest.Saved) {
+			p.slot = slot
+		}
+	}
+	g.picker = p
+	g.selectPickerSlot(p.slot)
+	g.osd.SetPersistent(osdKeyMenuHelp, "Left/Right  Enter:load  Shift+Enter:save  Esc")
+}
+
+func (g *NESGUI) closeStatePicker() {
+	g.picker = nil
+	g.osd.SetPersistent(osdKeyMenuHelp, "")
+}
+
+// selectPickerSlot selects slot and decodes its thumbnail.
+func (g *NESGUI) selectPickerSlot(slot int) {
+	p := g.picker
+	p.slot, p.thumb = slot, nil
+	m := p.metas[slot-1]
+	if m == nil {
+		return
+	}
+	pix, w, h, err := m.Pixels()
+	if err != nil {
+		logger.LogError("State %d thumbnail: %v", slot, err)
+		return
+	}
+	p.thumb, p.thumbW, p.thumbH = pix, w, h
+}
+
+// handlePickerKey consumes every keyboard event while the picker is open.
+// A number key loads that slot, or with Shift saves to it, as it does
+// with the picker closed.
+func (g *NESGUI) handlePickerKey(e *sdl.KeyboardEvent) {
+	if e.State != sdl.PRESSED {
+		return
+	}
+	p := g.picker
+	shift := e.Keysym.Mod&sdl.KMOD_SHIFT != 0
+	slot := p.slot
+	switch sym := e.Keysym.Sym; sym {
+	case sdl.K_ESCAPE:
+		g.closeStatePicker()
+		return
+	case sdl.K_s:
+		if e.Keysym.Mod&sdl.KMOD_CTRL != 0 {
+			g.closeStatePicker()
+		}
+		return
+	case sdl.K_LEFT:
+		g.selectPickerSlot((p.slot+savestate.Slots-2)%savestate.Slots + 1)
+		return
+	case sdl.K_RIGHT:
+		g.selectPickerSlot(p.slot%savestate.Slots + 1)
+		return
+	case sdl.K_RETURN, sdl.K_KP_ENTER:
+	default:
+		n, ok := numberSlot(sym)
+		if !ok {
+			return
+		}
+		slot = n
+	}
+	g.closeStatePicker()
+	if shift {
+		g.saveStateSlot(slot)
+	} else {
+		g.loadStateSlot(slot)
+	}
+}
+
+// drawStatePicker draws the open picker into textureBuf. Called from
+// drawOSD with emuMu held.
+func (g *NESGUI) drawStatePicker(w, h int) {
+	p := g.picker
+	caption := []string{fmt.Sprintf("Slot %d  empty", p.slot)}
+	if m := p.metas[p.slot-1]; m != nil {
+		caption = []string{
+			fmt.Sprintf("Slot %d  %s", p.slot, m.Saved.Local().Format("2006-01-02 15:04")),
+			"Played " + savestate.FormatPlayTime(m.PlayTime),
+		}
+	} else if p.filled[p.slot-1] {
+		caption[0] = fmt.Sprintf("Slot %d  no preview", p.slot)
+	}
+	thumbW, thumbH := savestate.ThumbWidth, savestate.ThumbHeight
+	if p.thumb != nil {
+		thumbW, thumbH = p.thumbW, p.thumbH
+	}
+	osd.DrawPicker(g.textureBuf, w, h, osd.Picker{
+		Filled:   p.filled,
+		Selected: p.slot - 1,
+		Thumb:    p.thumb,
+		ThumbW:   thumbW,
+		ThumbH:   thumbH,
+		Caption:  caption,
+	})
+}
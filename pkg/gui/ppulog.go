@@ -0,0 +1,100 @@
+// Package gui — the PPU register write log (Ctrl+W).
+//
+// Ctrl+W records the next frame's writes to $2000-$2007 and $4014, each
+// stamped with its scanline and dot (package ppulog), and saves them as
+// <rom>.ppu.csv. Under the GDB stub, "monitor ppu on" keeps the log
+// running and "monitor ppu" lists the writes of the frame the target
+// stopped in.
+package gui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/ppulog"
+)
+
+// capturePPULog is Ctrl+W: log the next frame, then save it.
+func (g *NESGUI) capturePPULog() {
+	if g.ppuLog == nil {
+		g.ppuLog = ppulog.Start(g.nes)
+	}
+	g.ppuLogCapture = true
+	g.notify("PPU log: recording the next frame")
+}
+
+// endPPULogFrame runs after every frame while the log is on, with emuMu
+// held. A Ctrl+W capture is written out and the log stopped.
+func (g *NESGUI) endPPULogFrame() {
+	g.ppuLog.EndFrame()
+	if !g.ppuLogCapture {
+		return
+	}
+	writes := g.ppuLog.LastFrame()
+	g.stopPPULog()
+	if g.romPath == "" {
+		logger.LogInfo("PPU log: %d writes; no ROM path to save them next to", len(writes))
+		return
+	}
+	path := nes.CompanionFile(g.romPath, ".ppu.csv")
+	if err := writePPULog(path, writes); err != nil {
+		logger.LogError("PPU log: %v", err)
+		g.notify("PPU log: save failed")
+		return
+	}
+	g.notify("PPU log: %d writes saved to %s", len(writes), filepath.Base(path))
+}
+
+// stopPPULog removes the log's bus hooks.
+func (g *NESGUI) stopPPULog() {
+	if g.ppuLog != nil {
+		g.ppuLog.Stop()
+		g.ppuLog = nil
+	}
+	g.ppuLogCapture = false
+}
+
+// ppuLogMonitor handles "monitor ppu [on|off]" for debuggerMonitor.
+func (g *NESGUI) ppuLogMonitor(arg string) string {
+	switch arg {
+	case "on":
+		if g.ppuLog == nil {
+			g.ppuLog = ppulog.Start(g.nes)
+		}
+		return "PPU log on\n"
+	case "off":
+		g.stopPPULog()
+		return "PPU log off\n"
+	}
+	if g.ppuLog == nil {
+		return "PPU log off (monitor ppu on to start it)\n"
+	}
+	writes := g.ppuLog.Current()
+	if len(writes) == 0 {
+		writes = g.ppuLog.LastFrame()
+	}
+	var b strings.Builder
+	for _, w := range writes {
+		b.WriteString(w.String())
+		b.WriteByte('\n')
+	}
+	if b.Len() == 0 {
+		return "no PPU writes yet\n"
+	}
+	return b.String()
+}
+
+func writePPULog(path string, writes []ppulog.Write) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := ppulog.WriteCSV(f, writes); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
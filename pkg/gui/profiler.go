@@ -0,0 +1,58 @@
+// Package gui — the cycle profiler (Ctrl+U).
+//
+// While it runs, every instruction is charged to a routine from the ROM's
+// symbol files (g.symbols), and the scanline bar down the right edge shows which
+// routine owned each line of the last frame. Turning it off writes the
+// report of the hottest routines next to the ROM.
+package gui
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/profile"
+)
+
+// profileReportRoutines is how many routines the report lists.
+const profileReportRoutines = 40
+
+// toggleProfiler starts profiling or stops and writes the report to
+// <rom>.profile.txt (to the log when no ROM path is known).
+func (g *NESGUI) toggleProfiler() {
+	if g.profiler == nil {
+		g.profiler = profile.New(g.symbols.Symbols(), g.nes.PRGOffset)
+		g.nes.Profile = g.profiler.Record
+		g.notify("Profiler: ON")
+		return
+	}
+	p := g.profiler
+	g.profiler = nil
+	g.nes.Profile = nil
+	if g.romPath == "" {
+		logger.LogInfo("Profiler: %d frames profiled; no ROM path to write a report next to", p.Frames())
+		g.notify("Profiler: OFF")
+		return
+	}
+	path := nes.CompanionFile(g.romPath, ".profile.txt")
+	if err := writeProfile(path, p); err != nil {
+		logger.LogError("Profiler: %v", err)
+		g.notify("Profiler: OFF (report failed)")
+		return
+	}
+	logger.LogInfo("Profiler: %d frames written to %s", p.Frames(), path)
+	g.notify("Profiler: OFF, saved %s", filepath.Base(path))
+}
+
+func writeProfile(path string, p *profile.Profiler) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.WriteReport(f, profileReportRoutines); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
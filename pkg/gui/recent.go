@@ -0,0 +1,314 @@
+// Package gui — ROM hot-swapping (drag-and-drop) and the recent-ROMs list.
+//
+// Dropping a file onto the window, or picking an entry from the Ctrl+O
+// recent menu, replaces the cartridge in place: the outgoing cart's
+// battery RAM is flushed and its autosave written, the new one is loaded
+// with a power-on reset, and its companion files (.sav, .cht) are picked
+// up just as on startup. Its per-game settings (Options.Game) replace the
+// last one's, resizing the window if the overscan changes.
+package gui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/patch"
+)
+
+// maxRecentROMs caps the persisted list; older entries fall off the end.
+const maxRecentROMs = 10
+
+// recentROMs is the most-recently-used ROM list, newest first. path is the
+// backing file (one ROM path per line); empty disables persistence.
+type recentROMs struct {
+	path    string
+	entries []string
+}
+
+// defaultRecentROMsPath returns <user config dir>/gones/recent_roms.txt, or
+// "" when the platform has no config directory (persistence is skipped).
+func defaultRecentROMsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gones", "recent_roms.txt")
+}
+
+// loadRecentROMs reads the list at path. A missing or unreadable file just
+// yields an empty list — the recent menu is a convenience, not state worth
+// failing startup over.
+func loadRecentROMs(path string) *recentROMs {
+	r := &recentROMs{path: path}
+	if path == "" {
+		return r
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogError("Recent ROMs: open %s: %v", path, err)
+		}
+		return r
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() && len(r.entries) < maxRecentROMs {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			r.entries = append(r.entries, line)
+		}
+	}
+	return r
+}
+
+// add moves rom to the front of the list (deduplicating) and persists it.
+func (r *recentROMs) add(rom string) {
+	kept := []string{rom}
+	for _, e := range r.entries {
+		if e != rom && len(kept) < maxRecentROMs {
+			kept = append(kept, e)
+		}
+	}
+	r.entries = kept
+	if err := r.save(); err != nil {
+		logger.LogError("Recent ROMs: %v", err)
+	}
+}
+
+// save writes the list to r.path, creating the config directory if needed.
+func (r *recentROMs) save() error {
+	if r.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	data := strings.Join(r.entries, "\n")
+	if data != "" {
+		data += "\n"
+	}
+	return os.WriteFile(r.path, []byte(data), 0o644)
+}
+
+// openROM reads the cartridge in the ROM file or archive at path.
+// Archives holding several ROMs give their first entry — there is no
+// terminal to prompt on once the window is up. A .ips or .bps patch
+// beside the file is applied to it.
+func openROM(path string) (*cartridge.Cartridge, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := cartridge.FindROMs(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 1 {
+		logger.LogInfo("%s contains %d ROMs; loading %s", filepath.Base(path), len(entries), entries[0].Name)
+	}
+	e := entries[0]
+	data, patchPath, err := patch.ApplyFor(e.Data, path, "")
+	if err != nil {
+		return nil, err
+	}
+	if patchPath != "" {
+		logger.LogInfo("Patch: applied %s", filepath.Base(patchPath))
+	}
+	e.Data = data
+	return cartridge.LoadEntry(e)
+}
+
+// loadROM swaps the running cartridge for the ROM at path (plain .nes or a
+// zip/gzip archive; see openROM). On any load error the current game
+// keeps running.
+func (g *NESGUI) loadROM(path string) error {
+	cart, err := openROM(path)
+	if err != nil {
+		return err
+	}
+	var game *GameSettings
+	if g.opts.Game != nil {
+		var gs GameSettings
+		if cart, gs, err = g.opts.Game(cart); err != nil {
+			return err
+		}
+		game = &gs
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	g.saveBattery()
+	g.writeAutosave()
+	if g.recorder != nil {
+		g.toggleRecording() // the WAV is named after the outgoing ROM
+	}
+	if g.profiler != nil {
+		g.toggleProfiler() // so are the report and the symbols
+	}
+
+	g.nes.LoadCartridge(cart)
+	g.nes.PowerOn()
+	if game != nil {
+		g.applyGame(*game)
+	}
+	g.nes.Cheats.Clear()
+	if g.tracer != nil {
+		g.tracer.Reset()
+	}
+	g.resetUndo()
+	g.playFrames = 0
+	g.romPath = path
+	g.romName = romTitle(cart, path)
+	if battery := cart.NonVolatile(); battery != nil {
+		nes.LoadBatterySave(battery, nes.CompanionFileIn(g.opts.SaveDir, path, ".sav"))
+	}
+	if !g.opts.NoCheatAutoLoad {
+		g.loadCheats()
+	}
+	g.loadRules()
+	g.loadSymbols()
+	if g.recent != nil {
+		g.recent.add(path)
+	}
+	g.notify("Loaded %s", filepath.Base(path))
+	g.offerAutosave()
+	return nil
+}
+
+// applyGame switches to a newly loaded ROM's settings.
+func (g *NESGUI) applyGame(gs GameSettings) {
+	g.nes.PPU.PaletteManager.SetPalette(gs.Palette)
+	g.nes.PPU.NoSpriteLimit = gs.NoSpriteLimit
+	g.nes.APU.SetRegion(gs.Region)
+	g.nes.GetInput().SetFourScore(gs.FourScore)
+	g.nes.GetInput().SetExpansion(gs.Expansion)
+	if vs := g.nes.GetInput().VS(); vs != nil {
+		vs.DIPs = gs.VSDIPs
+		if gs.VSPPU != nil {
+			g.nes.PPU.PaletteManager.SetModel(*gs.VSPPU)
+		}
+	}
+	if g.kbCapture && g.familyKeyboard() == nil {
+		g.toggleKeyboardCapture()
+	}
+	if gs.Overscan != g.opts.Overscan {
+		g.setOverscan(gs.Overscan)
+	}
+}
+
+// setOverscan changes the crop, resizing the texture and the window to
+// match at the current scale. Runs on the SDL thread, which renders.
+func (g *NESGUI) setOverscan(o Overscan) {
+	old := g.opts.Overscan
+	g.opts.Overscan = o
+	if err := g.resizeTexture(); err != nil {
+		logger.LogError("Overscan: %v", err)
+		g.opts.Overscan = old
+		return
+	}
+	if g.window != nil {
+		oldW, _ := old.size()
+		ww, _ := g.window.GetSize()
+		w, h := o.size()
+		scale := max(int(ww)/oldW, 1)
+		g.window.SetSize(int32(w*scale), int32(h*scale))
+	}
+}
+
+// saveBattery flushes the current cartridge's non-volatile memory (battery
+// RAM, disk or save chip) to <rom>.sav.
+// Called before a ROM swap and from Destroy.
+func (g *NESGUI) saveBattery() {
+	cart := g.nes.Cartridge
+	if g.romPath == "" || cart == nil || cart.NonVolatile() == nil {
+		return
+	}
+	nes.SaveBatterySave(cart.NonVolatile(), nes.CompanionFileIn(g.opts.SaveDir, g.romPath, ".sav"))
+}
+
+// handleDrop loads a file dropped onto the window.
+func (g *NESGUI) handleDrop(e *sdl.DropEvent) {
+	if e.Type != sdl.DROPFILE || e.File == "" {
+		return
+	}
+	if err := g.loadROM(e.File); err != nil {
+		logger.LogError("Drop %s: %v", e.File, err)
+		g.osd.Notify("Cannot load " + filepath.Base(e.File))
+	}
+}
+
+// openRecentMenu opens the recent-ROMs menu, or advances the selection
+// when it is already open, so repeated Ctrl+O presses cycle the list.
+func (g *NESGUI) openRecentMenu() {
+	if g.recent == nil || len(g.recent.entries) == 0 {
+		g.notify("Recent ROMs: list is empty")
+		return
+	}
+	if g.recentMenuOpen {
+		g.recentMenuIndex = (g.recentMenuIndex + 1) % len(g.recent.entries)
+	} else {
+		g.recentMenuOpen = true
+		// Entry 0 is the running ROM; start on the one before it.
+		g.recentMenuIndex = 0
+		if len(g.recent.entries) > 1 {
+			g.recentMenuIndex = 1
+		}
+	}
+	g.showRecentMenu()
+}
+
+// showRecentMenu refreshes the OSD line for the open menu, or removes it
+// once the menu has closed.
+func (g *NESGUI) showRecentMenu() {
+	if !g.recentMenuOpen {
+		g.osd.SetPersistent(osdKeyMenu, "")
+		g.osd.SetPersistent(osdKeyMenuHelp, "")
+		return
+	}
+	g.osd.SetPersistent(osdKeyMenu, g.recentMenuLabel())
+	g.osd.SetPersistent(osdKeyMenuHelp, "Up/Down  Enter:load  Esc:close")
+}
+
+// recentMenuLabel describes the current menu selection. It is shown on the
+// OSD and in the window title while the menu is open.
+func (g *NESGUI) recentMenuLabel() string {
+	return fmt.Sprintf("Recent [%d/%d] %s",
+		g.recentMenuIndex+1, len(g.recent.entries), filepath.Base(g.recent.entries[g.recentMenuIndex]))
+}
+
+// handleRecentMenuKey consumes every keyboard event while the menu is
+// open so navigation keys don't leak through as game input or hotkeys
+// (Esc must close the menu, not quit).
+func (g *NESGUI) handleRecentMenuKey(e *sdl.KeyboardEvent) {
+	if e.State != sdl.PRESSED {
+		return
+	}
+	n := len(g.recent.entries)
+	switch e.Keysym.Sym {
+	case sdl.K_ESCAPE:
+		g.recentMenuOpen = false
+	case sdl.K_UP:
+		g.recentMenuIndex = (g.recentMenuIndex + n - 1) % n
+	case sdl.K_DOWN:
+		g.recentMenuIndex = (g.recentMenuIndex + 1) % n
+	case sdl.K_o:
+		if e.Keysym.Mod&sdl.KMOD_CTRL != 0 {
+			g.openRecentMenu()
+		}
+	case sdl.K_RETURN, sdl.K_KP_ENTER:
+		g.recentMenuOpen = false
+		path := g.recent.entries[g.recentMenuIndex]
+		if err := g.loadROM(path); err != nil {
+			logger.LogError("Recent ROM %s: %v", path, err)
+			g.osd.Notify("Cannot load " + filepath.Base(path))
+		}
+	}
+	g.showRecentMenu()
+}
@@ -14,10 +14,10 @@ import (
 // header is written with placeholder sizes up front; Close seeks back and
 // patches them so the file remains valid no matter when the user stops.
 //
-// Samples are written with the same 2× gain queueAudio applies for SDL —
-// what you hear equals what's recorded. If FilterEnabled is off, the raw
-// mixer is unipolar 0..1 and the recording shows the DC bias (correct,
-// but unusual for a WAV).
+// Samples are the mixer's output as SDL plays it, master volume and mute
+// included — what you hear equals what's recorded. If FilterEnabled is
+// off, the raw mixer is unipolar and the recording shows the DC bias
+// (correct, but unusual for a WAV).
 type wavRecorder struct {
 	f          *os.File
 	dataBytes  uint32
@@ -61,9 +61,9 @@ func (r *wavRecorder) writeHeader() error {
 	return err
 }
 
-// WriteSamples writes APU samples as signed 16-bit PCM with the same 2×
-// gain queueAudio uses. The mixer's HPF already centers the signal, so no
-// DC removal is needed here.
+// WriteSamples writes APU samples as signed 16-bit PCM. The mixer has
+// already applied gain and volume (and its HPF centred the signal); the
+// clamp here only keeps out-of-range input from wrapping around int16.
 func (r *wavRecorder) WriteSamples(samples []float32) error {
 	if len(samples) == 0 {
 		return nil
@@ -75,13 +75,12 @@ func (r *wavRecorder) WriteSamples(samples []float32) error {
 		r.buf = r.buf[:need]
 	}
 	for i, s := range samples {
-		v := s * 2.0
-		if v > 1.0 {
-			v = 1.0
-		} else if v < -1.0 {
-			v = -1.0
+		if s > 1.0 {
+			s = 1.0
+		} else if s < -1.0 {
+			s = -1.0
 		}
-		iv := int16(v * 32767)
+		iv := int16(s * 32767)
 		r.buf[i*2] = byte(iv)
 		r.buf[i*2+1] = byte(iv >> 8)
 	}
@@ -127,6 +126,7 @@ func (g *NESGUI) toggleRecording() {
 		} else {
 			seconds := float64(bytes) / float64(g.recorder.sampleRate*2)
 			logger.LogInfo("Recording stopped: %s (%.2fs)", path, seconds)
+			g.osd.Notify(fmt.Sprintf("Recording stopped (%.1fs)", seconds))
 		}
 		g.recorder = nil
 		return
@@ -139,4 +139,5 @@ func (g *NESGUI) toggleRecording() {
 	}
 	g.recorder = rec
 	logger.LogInfo("Recording started: %s", path)
+	g.osd.Notify("Recording...")
 }
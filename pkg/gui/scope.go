@@ -0,0 +1,78 @@
+// Package gui — the audio channel oscilloscope (Ctrl+A).
+//
+// While it's on the APU records each channel's mixer input
+// (apu.ChannelTaps) and drawOSD draws a pane per channel down the right
+// of the picture: the channel's waveform, captioned with the note, volume
+// and duty it's playing. Silent and muted channels are drawn faintly.
+package gui
+
+import (
+	"fmt"
+
+	"github.com/yoshiomiyamaegones/pkg/apu"
+	"github.com/yoshiomiyamaegones/pkg/osd"
+)
+
+// Per-channel trace colours, short names and full-scale levels.
+var (
+	scopeColors = [apu.NumChannels]uint32{0xFF6060, 0xFFB040, 0x60A0FF, 0xE0E0E0, 0x60E060}
+	scopeNames  = [apu.NumChannels]string{"P1", "P2", "TRI", "NOI", "DMC"}
+	scopeMax    = [apu.NumChannels]uint8{15, 15, 15, 15, 127}
+)
+
+var dutyNames = [4]string{"12.5%", "25%", "50%", "75%"}
+
+// toggleScope attaches or detaches the APU's channel taps.
+func (g *NESGUI) toggleScope() {
+	a := g.nes.APU
+	if a.Taps == nil {
+		a.Taps = &apu.ChannelTaps{}
+	} else {
+		a.Taps = nil
+	}
+	g.notify("Audio scope: %s", onOff(a.Taps != nil))
+}
+
+// scopeLabel captions channel ch's pane, e.g. "P1 A4 440Hz v15 50%".
+func scopeLabel(ch int, s apu.ChannelState) string {
+	name := scopeNames[ch]
+	if !s.Active {
+		return name
+	}
+	switch ch {
+	case apu.ChannelNoise:
+		mode := ""
+		if s.Duty == 1 {
+			mode = " short"
+		}
+		return fmt.Sprintf("%s %.1fkHz v%d%s", name, s.Frequency/1000, s.Volume, mode)
+	case apu.ChannelDMC:
+		return fmt.Sprintf("%s %.1fkHz L%d", name, s.Frequency/1000, s.Volume)
+	}
+	label := fmt.Sprintf("%s %.0fHz", name, s.Frequency)
+	if note := apu.NoteName(s.Frequency); note != "" {
+		label = fmt.Sprintf("%s %s %.0fHz", name, note, s.Frequency)
+	}
+	if ch == apu.ChannelTriangle {
+		return label
+	}
+	return fmt.Sprintf("%s v%d %s", label, s.Volume, dutyNames[s.Duty&3])
+}
+
+// drawScopes draws the oscilloscope panes into textureBuf. Called from
+// drawOSD with emuMu held.
+func (g *NESGUI) drawScopes(w, h int) {
+	a := g.nes.APU
+	g.scopeBuf = g.scopeBuf[:0]
+	for ch := 0; ch < apu.NumChannels; ch++ {
+		s := a.ChannelState(ch)
+		g.scopeBuf = append(g.scopeBuf, osd.Scope{
+			Label:   scopeLabel(ch, s),
+			Samples: a.Taps.Samples[ch],
+			Max:     scopeMax[ch],
+			Color:   scopeColors[ch],
+			Dim:     !s.Active || a.ChannelMute[ch],
+		})
+	}
+	osd.DrawScopes(g.textureBuf, w, h, g.scopeBuf)
+}
@@ -1,15 +1,22 @@
-// Package gui — save/load state slots, screenshots, and cheat-file loading.
+// Package gui — save/load state slots, screenshots, and cheat-, rule- and
+// symbol-file loading.
 package gui
 
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/yoshiomiyamaegones/pkg/cheat"
 	"github.com/yoshiomiyamaegones/pkg/logger"
 	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/rules"
+	"github.com/yoshiomiyamaegones/pkg/savestate"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
 )
 
 // loadCheats reads <romPath>.cht if present and feeds the entries to the
@@ -38,9 +45,81 @@ func (g *NESGUI) loadCheats() {
 	logger.LogInfo("Cheats: loaded %d from %s", len(cheats), path)
 }
 
-// stateSlotPath returns the .stateN path next to the ROM.
+// loadRules reads <romPath>.rules.json if present into the rule engine,
+// replacing the previous ROM's rules; without one nothing is watched.
+// Like cheats, a malformed rule is logged and the rest still load.
+func (g *NESGUI) loadRules() {
+	g.rules.SetRules(nil)
+	if g.romPath == "" {
+		return
+	}
+	path := nes.CompanionFile(g.romPath, ".rules.json")
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogError("Rules: open %s: %v", path, err)
+		}
+		return
+	}
+	defer f.Close()
+	loaded, err := rules.Load(f)
+	if err != nil {
+		logger.LogError("Rules: %v", err)
+	}
+	g.rules.SetRules(loaded)
+	logger.LogInfo("Rules: loaded %d from %s", len(loaded), path)
+}
+
+// loadSymbols reads the ROM's label files — ld65's <rom>.dbg, NESASM's
+// <rom>.fns and FCEUX's <rom>.nes.*.nl, one per bank — for the profiler,
+// the trace, debugger messages and expressions. None is fine; a malformed file is logged and
+// skipped.
+func (g *NESGUI) loadSymbols() {
+	g.symbols = nil
+	if g.romPath == "" {
+		return
+	}
+	paths := []string{nes.CompanionFile(g.romPath, ".dbg"), nes.CompanionFile(g.romPath, ".fns")}
+	// Not filepath.Glob: dump names like "Game (U) [!].nes" are patterns.
+	dir, base := filepath.Split(g.romPath)
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if name := e.Name(); strings.HasPrefix(name, base+".") && strings.HasSuffix(name, ".nl") {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	var syms []symbols.Symbol
+	for _, path := range paths {
+		loaded, err := symbols.LoadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logger.LogError("Symbols: %v", err)
+			}
+			continue
+		}
+		logger.LogInfo("Symbols: loaded %d from %s", len(loaded), path)
+		syms = append(syms, loaded...)
+	}
+	if len(syms) > 0 {
+		g.symbols = symbols.NewTable(syms)
+	}
+	if g.tracer != nil {
+		g.tracer.Symbols = g.symbols
+	}
+	if g.debugger != nil {
+		g.debugger.Symbols = g.symbols
+	}
+}
+
+// ruleFired shows a rule's message on the OSD (and in the log).
+func (g *NESGUI) ruleFired(ev rules.Event) {
+	g.notify("%s", ev.Rule.Text())
+}
+
+// stateSlotPath returns the .stateN path, next to the ROM unless a state
+// directory is configured.
 func (g *NESGUI) stateSlotPath(slot int) string {
-	return nes.CompanionFile(g.romPath, fmt.Sprintf(".state%d", slot))
+	return nes.CompanionFileIn(g.opts.StateDir, g.romPath, fmt.Sprintf(".state%d", slot))
 }
 
 func (g *NESGUI) saveStateSlot(slot int) {
@@ -49,6 +128,12 @@ func (g *NESGUI) saveStateSlot(slot int) {
 		return
 	}
 	path := g.stateSlotPath(slot)
+	if g.opts.StateDir != "" {
+		if err := os.MkdirAll(g.opts.StateDir, 0o755); err != nil {
+			logger.LogError("Save state slot %d: %v", slot, err)
+			return
+		}
+	}
 	f, err := os.Create(path)
 	if err != nil {
 		logger.LogError("Save state slot %d: %v", slot, err)
@@ -59,7 +144,9 @@ func (g *NESGUI) saveStateSlot(slot int) {
 		logger.LogError("Save state slot %d: %v", slot, err)
 		return
 	}
+	g.writeStateMeta(path)
 	logger.LogInfo("Saved state to slot %d: %s", slot, path)
+	g.osd.Notify(fmt.Sprintf("State %d saved", slot))
 }
 
 func (g *NESGUI) loadStateSlot(slot int) {
@@ -71,19 +158,47 @@ func (g *NESGUI) loadStateSlot(slot int) {
 	f, err := os.Open(path)
 	if err != nil {
 		logger.LogError("Load state slot %d: %v", slot, err)
+		g.osd.Notify(fmt.Sprintf("State %d: nothing saved", slot))
 		return
 	}
 	defer f.Close()
 	if err := g.nes.LoadState(f); err != nil {
 		logger.LogError("Load state slot %d: %v", slot, err)
+		g.osd.Notify(fmt.Sprintf("State %d: load failed", slot))
 		return
 	}
+	g.resetUndo()
+	if m, err := savestate.Read(path); err == nil {
+		g.playFrames = uint64(m.PlayTime / FrameTime)
+	}
 	logger.LogInfo("Loaded state from slot %d: %s", slot, path)
+	g.osd.Notify(fmt.Sprintf("State %d loaded", slot))
+}
+
+// writeStateMeta records, beside the state at path, when it was saved,
+// the play time, the ROM's hash and a thumbnail of the screen for the
+// state picker. Failing costs the picker its preview, not the save.
+func (g *NESGUI) writeStateMeta(path string) {
+	m := savestate.Meta{Saved: time.Now(), PlayTime: g.playTime(), ROMSHA1: g.romSHA1()}
+	thumb, err := savestate.Thumbnail(g.nes.GetFramebuffer())
+	if err != nil {
+		logger.LogError("Save state thumbnail: %v", err)
+	}
+	m.Thumbnail = thumb
+	if err := savestate.Write(path, m); err != nil {
+		logger.LogError("Save state metadata: %v", err)
+	}
+}
+
+// playTime is how long the current game has been played: frames run
+// since it was opened, carried on from the slot when a state is loaded.
+func (g *NESGUI) playTime() time.Duration {
+	return time.Duration(g.playFrames) * FrameTime
 }
 
 // saveScreenshot saves the current screen to a file
 func (g *NESGUI) saveScreenshot() {
-	filename := fmt.Sprintf("screenshot_%03d.png", g.screenshotNum)
+	filename := filepath.Join(g.opts.ScreenshotDir, fmt.Sprintf("screenshot_%03d.png", g.screenshotNum))
 	g.screenshotNum++
 	g.saveScreenshotWithName(filename)
 }
@@ -119,4 +234,5 @@ func (g *NESGUI) saveFramebufferAsRaw(filename string, data []uint8) {
 	}
 
 	logger.LogInfo("Raw framebuffer saved: %s (%d bytes)\n", filename, len(data))
+	g.osd.Notify("Screenshot: " + filename)
 }
@@ -0,0 +1,146 @@
+// Package gui — performance telemetry: the Ctrl+F11 HUD and -stats-csv.
+//
+// Stats joins the core's per-chip timing (nes.Stats) with the front end's
+// own: the emulation goroutine's whole frame step, the SDL thread's last
+// render, how much sound is queued and the FPS. The HUD shows it over the
+// picture; Options.StatsCSV writes a row of it for every frame. The core
+// only times itself while either is on.
+package gui
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// Stats is the telemetry of the latest frame.
+type Stats struct {
+	nes.Stats               // the core's StepFrame; zero while nothing asks for it
+	Update    time.Duration // the emulation goroutine's frame: the core plus rules, autosave, profiler
+	Render    time.Duration // the SDL thread's last render: crop, OSD, filter, upload, present
+	Audio     time.Duration // sound waiting to play, in the ring and the device queue
+	Samples   int           // samples the frame produced
+	FPS       float64
+}
+
+// Stats returns the telemetry of the latest frame. Safe on any goroutine.
+func (g *NESGUI) Stats() Stats {
+	g.emuMu.Lock()
+	defer g.emuMu.Unlock()
+	return g.stats()
+}
+
+// stats is Stats with emuMu already held.
+func (g *NESGUI) stats() Stats {
+	return Stats{
+		Stats:   g.nes.Stats(),
+		Update:  g.updateTime,
+		Render:  time.Duration(g.renderTime.Load()),
+		Audio:   g.audioBuffered(),
+		Samples: g.frameSamples,
+		FPS:     g.currentFPS,
+	}
+}
+
+// statsCSVHeader names the columns csvRecord fills.
+var statsCSVHeader = []string{
+	"frame", "cpu_us", "ppu_us", "apu_us", "core_us", "instructions",
+	"update_us", "render_us", "audio_ms", "samples", "fps",
+}
+
+// csvRecord is s as a statsCSVHeader row.
+func (s Stats) csvRecord() []string {
+	us := func(d time.Duration) string { return strconv.FormatInt(d.Microseconds(), 10) }
+	return []string{
+		strconv.FormatUint(s.Frame, 10), us(s.CPU), us(s.PPU), us(s.APU), us(s.Total),
+		strconv.Itoa(s.Instructions), us(s.Update), us(s.Render),
+		strconv.FormatInt(s.Audio.Milliseconds(), 10), strconv.Itoa(s.Samples), strconv.FormatFloat(s.FPS, 'f', 1, 64),
+	}
+}
+
+// statsLog is an open -stats-csv file.
+type statsLog struct {
+	f *os.File
+	w *csv.Writer
+}
+
+// createStatsLog creates path and writes the header row.
+func createStatsLog(path string) (*statsLog, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &statsLog{f: f, w: csv.NewWriter(f)}
+	if err := l.w.Write(statsCSVHeader); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// write appends a row for s.
+func (l *statsLog) write(s Stats) error {
+	return l.w.Write(s.csvRecord())
+}
+
+// Close flushes the rows and closes the file.
+func (l *statsLog) Close() error {
+	l.w.Flush()
+	if err := l.w.Error(); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// startStatsLog opens Options.StatsCSV, logging a failure.
+func (g *NESGUI) startStatsLog(path string) {
+	l, err := createStatsLog(path)
+	if err != nil {
+		logger.LogError("Stats: %v", err)
+		return
+	}
+	g.statsLog = l
+	g.nes.EnableStats(true)
+	logger.LogInfo("Stats: writing every frame to %s", path)
+}
+
+// logStats writes the frame just stepped to the CSV. A write error ends
+// the log. Called on the emulation goroutine with emuMu held.
+func (g *NESGUI) logStats() {
+	if err := g.statsLog.write(g.stats()); err != nil {
+		logger.LogError("Stats: %v", err)
+		g.closeStatsLog()
+	}
+}
+
+// closeStatsLog finishes the CSV, if one is open.
+func (g *NESGUI) closeStatsLog() {
+	if g.statsLog == nil {
+		return
+	}
+	if err := g.statsLog.Close(); err != nil {
+		logger.LogError("Stats: %v", err)
+	}
+	g.statsLog = nil
+	g.nes.EnableStats(g.showStats)
+}
+
+// toggleStats shows or hides the performance HUD (Ctrl+F11).
+func (g *NESGUI) toggleStats() {
+	g.showStats = !g.showStats
+	g.nes.EnableStats(g.showStats || g.statsLog != nil)
+	g.notify("Performance HUD %s", onOff(g.showStats))
+}
+
+// statsLines are the HUD's two lines, in milliseconds.
+func (s Stats) statsLines() (string, string) {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	return fmt.Sprintf("CPU %.2f PPU %.2f APU %.2f ms", ms(s.CPU), ms(s.PPU), ms(s.APU)),
+		fmt.Sprintf("emu %.2f draw %.2f ms audio %dms", ms(s.Update), ms(s.Render), s.Audio.Milliseconds())
+}
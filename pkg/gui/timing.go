@@ -1,15 +1,20 @@
-// Package gui — frame pacing, FPS counter, and window-title updates.
+// Package gui — frame pacing, frame skip, FPS counter, and window-title
+// updates.
 //
 // The pacing strategy is target-time accumulation: each frame's deadline is
 // startTime + frameCount*FrameTime, not the previous deadline + FrameTime.
-// That way Sleep() overshoot doesn't drift the framerate downward.
+// That way Sleep() overshoot doesn't drift the framerate downward. Within a
+// frame, Options.Pacing picks how the deadline is met: a plain Sleep, a
+// Sleep that stops short and spins the rest (the default, which removes the
+// judder of an occasional late wake-up), or no timer at all — with vsync the
+// audio device's clock sets the rate instead.
 package gui
 
 import (
-	"fmt"
+	"runtime"
 	"time"
 
-	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/veandco/go-sdl2/sdl"
 )
 
 // Timing constants
@@ -22,42 +27,145 @@ const (
 	TurboRenderInterval = 16 * time.Millisecond
 )
 
+// Frame pacing modes for Options.Pacing.
+const (
+	PacingHybrid = "hybrid" // sleep to just short of the deadline, then spin (default)
+	PacingSleep  = "sleep"  // sleep only: least CPU, but a late wake-up shows as judder
+	PacingVSync  = "vsync"  // present on vblank; emulate as fast as the audio device plays
+)
+
+// spinMargin is how early hybrid pacing wakes from Sleep to spin out the
+// rest of the frame — comfortably more than a typical timer overshoot.
+const spinMargin = 2 * time.Millisecond
+
+// audioPollInterval is how often audio-clocked pacing checks the queue.
+const audioPollInterval = time.Millisecond
+
 // NTSC NES frame rate: 60.0988 FPS (more precisely: 1789773 / 29780.5 = 60.0988139...)
 // Frame time = 1,000,000,000 / 60.0988139 = 16,639,266.85 ns
-var FrameTime = time.Duration(16639267) * time.Nanosecond // 16.639267ms per frame
-
-// waitForNextFrame sleeps until the next frame deadline and logs noticeable
-// timing deviations every 60 frames. startTime/frameCount form the
-// accumulating-target baseline; frameStart is when this frame's work began
-// (used only for the deviation log). In turbo mode the pacing is skipped
-// entirely — frames run as fast as they can.
-func (g *NESGUI) waitForNextFrame(startTime, frameStart time.Time, frameCount int) {
-	targetEndTime := startTime.Add(time.Duration(frameCount) * FrameTime)
-
-	if !g.turbo {
-		now := time.Now()
-		if now.Before(targetEndTime) {
-			time.Sleep(targetEndTime.Sub(now))
-		}
+const FrameTime = time.Duration(16639267) * time.Nanosecond // 16.639267ms per frame
+
+// waitForNextFrame sleeps until the next frame deadline. startTime and
+// frameCount form the accumulating-target baseline. In turbo mode the
+// pacing is skipped entirely — frames run as fast as they can. turbo is
+// passed in rather than read from g because the emulation goroutine calls
+// this without emuMu. How long frames actually take is in Stats.
+func (g *NESGUI) waitForNextFrame(startTime time.Time, frameCount int, turbo bool) {
+	if turbo {
+		return
+	}
+	if g.audioClocked() {
+		g.waitForAudio()
+		return
 	}
+	sleepUntil(startTime.Add(time.Duration(frameCount)*FrameTime), g.opts.Pacing != PacingSleep)
+}
 
-	// Debug: Log frame timing every 60 frames (skipped in turbo — frames
-	// run as fast as they can by design).
-	if frameCount%60 == 0 && !g.turbo {
-		actualFrameTime := time.Since(frameStart)
-		expectedFrameTime := FrameTime
-		deviation := float64(actualFrameTime-expectedFrameTime) / float64(expectedFrameTime) * 100
-
-		// Also check average frame rate
-		avgFrameTime := time.Since(startTime) / time.Duration(frameCount)
-		avgDeviation := float64(avgFrameTime-expectedFrameTime) / float64(expectedFrameTime) * 100
-
-		if deviation > 5 || deviation < -5 || avgDeviation > 2 || avgDeviation < -2 {
-			logger.LogInfo("Frame timing: actual=%.3fms, avg=%.3fms, expected=%.3fms, deviation=%.1f%%, avg_dev=%.1f%%",
-				actualFrameTime.Seconds()*1000, avgFrameTime.Seconds()*1000,
-				expectedFrameTime.Seconds()*1000, deviation, avgDeviation)
+// sleepUntil returns at deadline. With spin it sleeps only to spinMargin
+// before it and yields in a loop for the rest, trading a little CPU for
+// not depending on when the OS timer fires.
+func sleepUntil(deadline time.Time, spin bool) {
+	d := time.Until(deadline)
+	if spin {
+		d -= spinMargin
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+	for spin && time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
+}
+
+// audioClocked reports whether frames are paced by the audio device rather
+// than a timer: vsync pacing with a working device.
+func (g *NESGUI) audioClocked() bool {
+	return g.opts.Pacing == PacingVSync && g.audioDevice != 0
+}
+
+// audioLead is how much audio audio-clocked pacing keeps buffered ahead of
+// playback: the queue cap queueAudio starts with, less one frame so a
+// frame's samples always fit under it. Fixed at startup — the emulation
+// goroutine can't follow the cap as underruns grow it.
+func (g *NESGUI) audioLead() time.Duration {
+	lead := g.opts.AudioLatency
+	if lead <= 0 && g.audioSpec.Freq > 0 {
+		lead = 2 * time.Duration(g.audioSpec.Samples) * time.Second / time.Duration(g.audioSpec.Freq)
+	}
+	if lead -= FrameTime; lead < FrameTime {
+		lead = FrameTime
+	}
+	return lead
+}
+
+// waitForAudio paces a frame off the audio clock: it returns once the
+// samples waiting in the ring and the device queue play for less than
+// audioLead, so emulation runs exactly as fast as the device consumes
+// sound and never drifts from it. A device that isn't draining (paused, stalled) gives
+// up after two frames, so the caller falls back to timer-like pacing
+// rather than hanging.
+func (g *NESGUI) waitForAudio() {
+	lead := g.audioLead()
+	giveUp := time.Now().Add(2 * FrameTime)
+	for time.Now().Before(giveUp) {
+		if g.audioBuffered() < lead {
+			return
 		}
+		time.Sleep(audioPollInterval)
+	}
+}
+
+// maxAutoFrameSkip caps how many frames in a row auto frame skip drops, so
+// the picture still moves at a few frames a second on a struggling host.
+const maxAutoFrameSkip = 4
+
+// frameSkip decides which emulated frames are drawn (Options.FrameSkip,
+// Options.AutoFrameSkip). run counts the frames dropped since the last one
+// shown.
+type frameSkip struct {
+	fixed int
+	auto  bool
+	run   int
+}
+
+// show reports whether the next frame should be drawn; behind is whether
+// emulation has fallen behind real time.
+func (s *frameSkip) show(behind bool) bool {
+	limit := s.fixed
+	if s.auto && behind {
+		limit = max(limit, maxAutoFrameSkip)
+	}
+	if s.run < limit {
+		s.run++
+		return false
+	}
+	s.run = 0
+	return true
+}
+
+// behind reports whether emulation is more than a frame behind real time
+// as frame frameCount+1 starts: past its deadline on the pacing timer, or,
+// when the audio device sets the pace, with less than a frame of sound
+// left to play.
+func (g *NESGUI) behind(startTime time.Time, frameCount int) bool {
+	if !g.skip.auto {
+		return false
+	}
+	if g.audioClocked() {
+		return g.audioBuffered() < FrameTime
 	}
+	return time.Since(startTime.Add(time.Duration(frameCount)*FrameTime)) > FrameTime
+}
+
+// audioBuffered is how long the samples waiting in the ring and the
+// device queue play for. GetQueuedAudioSize is one of SDL's thread-safe
+// calls.
+func (g *NESGUI) audioBuffered() time.Duration {
+	ring := time.Duration(g.audio.len()) * time.Second / AudioSampleRate
+	if g.audioDevice == 0 {
+		return ring
+	}
+	return ring + queuedDuration(sdl.GetQueuedAudioSize(g.audioDevice), g.audioSpec)
 }
 
 // updateFPS calculates the current FPS
@@ -68,27 +176,18 @@ func (g *NESGUI) updateFPS() {
 	elapsed := time.Since(g.fpsTimer)
 	if elapsed >= 500*time.Millisecond {
 		g.currentFPS = float64(g.fpsCounter) / elapsed.Seconds()
-
-		// Debug: Log if FPS is significantly off target (skipped in turbo —
-		// the FPS is *expected* to be off target while fast-forwarding).
-		if g.fpsCounter%30 == 0 && !g.turbo {
-			deviation := (g.currentFPS - TargetFPS) / TargetFPS * 100
-			if deviation > 5 || deviation < -5 {
-				logger.LogInfo("FPS: %.2f (target: %.2f, deviation: %.1f%%)",
-					g.currentFPS, TargetFPS, deviation)
-			}
-		}
-
 		g.fpsCounter = 0
 		g.fpsTimer = time.Now()
 	}
 }
 
-// updateWindowTitle updates the window title with FPS information
+// updateWindowTitle sets the window title to windowTitle, calling SDL
+// only when it changed.
 func (g *NESGUI) updateWindowTitle() {
-	title := fmt.Sprintf("%s - FPS: %.1f", WindowTitle, g.currentFPS)
-	if g.turbo {
-		title += " [TURBO]"
+	title := g.windowTitle()
+	if title == g.title {
+		return
 	}
+	g.title = title
 	g.window.SetTitle(title)
 }
@@ -0,0 +1,78 @@
+// Package gui — the window title and instance numbering.
+//
+// The title names the game (from the game database, else the file name),
+// so windows are told apart in a task bar, and a second emulator running
+// at once is marked #2, a third #3 and so on.
+package gui
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+)
+
+// Instance numbering. Each running emulator holds a listener on the first
+// free loopback port from instancePortBase, and its position in the range
+// is its number. The OS frees the port when the process exits, crash or
+// not, so a number never stays taken by a window that is gone. Nothing
+// connects to the port.
+const (
+	instancePortBase = 47811
+	maxInstances     = 16
+)
+
+// claimInstance takes the lowest free instance number, 1 upwards, and
+// returns it with the listener that holds it. When the whole range is
+// taken (or loopback is unavailable) it returns 0 and nil: the window then
+// goes unnumbered rather than failing to open.
+func claimInstance() (int, net.Listener) {
+	for n := 1; n <= maxInstances; n++ {
+		l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", instancePortBase+n-1))
+		if err == nil {
+			return n, l
+		}
+	}
+	return 0, nil
+}
+
+// romTitle names a loaded ROM for the title bar: its game database name
+// when the dump is known, else the file name without its extension.
+func romTitle(cart *cartridge.Cartridge, path string) string {
+	if cart != nil {
+		if info, ok := cart.Game(); ok && info.Name != "" {
+			return info.Name
+		}
+	}
+	if path == "" {
+		return ""
+	}
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// windowTitle is the title for the current state: "<game> - GoNES" (the
+// full WindowTitle with no game), " #N" from the second instance on, then
+// the recent-ROMs selection while that menu is open, or the FPS when it is
+// shown and the turbo flag.
+func (g *NESGUI) windowTitle() string {
+	title := WindowTitle
+	if g.romName != "" {
+		title = g.romName + " - GoNES"
+	}
+	if g.instance > 1 {
+		title += fmt.Sprintf(" #%d", g.instance)
+	}
+	if g.recentMenuOpen {
+		return title + " - " + g.recentMenuLabel()
+	}
+	if g.showFPS {
+		title += fmt.Sprintf(" - FPS: %.1f", g.currentFPS)
+	}
+	if g.turbo {
+		title += " [TURBO]"
+	}
+	return title
+}
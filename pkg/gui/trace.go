@@ -0,0 +1,64 @@
+// Package gui — the instruction trace (Options.Trace).
+//
+// The last Options.Trace instructions that pass Options.TraceFilter stay
+// in memory (package trace) and are written to <rom>.trace.txt only when
+// wanted: on Ctrl+T, on the GDB stub's "monitor trace", when the CPU jams,
+// and when the emulation goroutine panics.
+package gui
+
+import (
+	"path/filepath"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/trace"
+)
+
+// tracePath is where the trace is written: next to the ROM, or in the
+// working directory when no ROM path is known.
+func (g *NESGUI) tracePath() string {
+	if g.romPath == "" {
+		return "gones.trace.txt"
+	}
+	return nes.CompanionFile(g.romPath, ".trace.txt")
+}
+
+// writeTrace writes the ring to tracePath and returns a message saying
+// where, or why not. Called with emuMu held.
+func (g *NESGUI) writeTrace() string {
+	if g.tracer == nil || g.tracer.Len() == 0 {
+		return "Trace: empty (start with -trace N)"
+	}
+	path := g.tracePath()
+	if err := g.tracer.WriteFile(path); err != nil {
+		logger.LogError("Trace: %v", err)
+		return "Trace: write failed"
+	}
+	logger.LogInfo("Trace: last %d instructions written to %s", g.tracer.Len(), path)
+	return "Trace: saved " + filepath.Base(path)
+}
+
+// dumpTrace is Ctrl+T.
+func (g *NESGUI) dumpTrace() {
+	g.notify("%s", g.writeTrace())
+}
+
+// dumpTraceOnPanic is deferred by the emulation goroutine: a panic
+// writes the trace, then continues. The goroutine holds emuMu.
+func (g *NESGUI) dumpTraceOnPanic() {
+	if r := recover(); r != nil {
+		if g.tracer != nil && g.tracer.Len() > 0 {
+			g.writeTrace()
+		}
+		panic(r)
+	}
+}
+
+// startTrace installs the tracer when a ring was asked for, or when CPU
+// logging is on and wants the instructions logged.
+func (g *NESGUI) startTrace(size int, filter trace.Filter) {
+	if size > 0 || logger.CPUEnabled() {
+		g.tracer = trace.New(g.nes, size, filter)
+		g.tracer.Symbols = g.symbols
+	}
+}
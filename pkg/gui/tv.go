@@ -0,0 +1,75 @@
+package gui
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/yoshiomiyamaegones/pkg/ppu"
+)
+
+// TV controls for the NTSC palette (ppu.NTSC): 9 picks a control, [ and ]
+// turn it down and up. Turning one switches the generated palette on, from
+// Options.NTSC if it was off, and the PPU rebuilds its colour tables on
+// the spot, so the picture changes under the knob as on a TV.
+
+// tvControl is one of the TV's knobs: the ppu.NTSC field it turns, how far
+// a press moves it, its range (config.Video's), and how it's shown.
+type tvControl struct {
+	name     string
+	field    func(*ppu.NTSC) *float64
+	step     float64
+	min, max float64
+	format   func(float64) string
+}
+
+func percent(v float64) string { return fmt.Sprintf("%d%%", int(math.Round(v*100))) }
+
+var tvControls = [...]tvControl{
+	{"Hue", func(n *ppu.NTSC) *float64 { return &n.Hue }, 5, -180, 180,
+		func(v float64) string { return fmt.Sprintf("%+d°", int(math.Round(v))) }},
+	{"Saturation", func(n *ppu.NTSC) *float64 { return &n.Saturation }, 0.05, 0, 2, percent},
+	{"Brightness", func(n *ppu.NTSC) *float64 { return &n.Brightness }, 0.02, -1, 1, percent},
+	{"Contrast", func(n *ppu.NTSC) *float64 { return &n.Contrast }, 0.05, 0, 2, percent},
+	{"Gamma", func(n *ppu.NTSC) *float64 { return &n.Gamma }, 0.05, 1, 3.5,
+		func(v float64) string { return fmt.Sprintf("%.2f", v) }},
+}
+
+// turn moves c on n by steps presses, snapped to the step so repeated
+// presses don't drift, and clamped to its range.
+func (c *tvControl) turn(n *ppu.NTSC, steps int) {
+	v := c.field(n)
+	*v = math.Max(c.min, math.Min(c.max, (math.Round(*v/c.step)+float64(steps))*c.step))
+}
+
+// tvNTSC returns the controls the knobs start from: the PPU's when the
+// NTSC palette is on, else Options.NTSC.
+func (g *NESGUI) tvNTSC() ppu.NTSC {
+	if n := g.nes.PPU.PaletteManager.NTSC(); n != nil {
+		return *n
+	}
+	if g.opts.NTSC == (ppu.NTSC{}) {
+		return ppu.DefaultNTSC
+	}
+	return g.opts.NTSC
+}
+
+// nextTVControl selects the next knob and shows where it's set.
+func (g *NESGUI) nextTVControl() {
+	g.tvControl = (g.tvControl + 1) % len(tvControls)
+	c := &tvControls[g.tvControl]
+	n := g.tvNTSC()
+	g.notify("TV %s: %s", c.name, c.format(*c.field(&n)))
+}
+
+// turnTVControl turns the selected knob by steps and regenerates the
+// palette.
+func (g *NESGUI) turnTVControl(steps int) {
+	c := &tvControls[g.tvControl]
+	n := g.tvNTSC()
+	c.turn(&n, steps)
+	g.nes.PPU.PaletteManager.SetNTSC(&n)
+	g.notify("TV %s: %s", c.name, c.format(*c.field(&n)))
+}
+
+func (g *NESGUI) tvControlUp()   { g.turnTVControl(1) }
+func (g *NESGUI) tvControlDown() { g.turnTVControl(-1) }
@@ -0,0 +1,112 @@
+// Package gui — reloading the ROM when its file changes (Options.Watch).
+//
+// For homebrew work: rebuild with cc65 or asm6 and the running game picks
+// up the new ROM a moment later, starting over or, per Options.WatchKeep,
+// keeping RAM or the whole machine state (nes.NES.SwapCartridge). The
+// file is polled from the main loop rather than watched through the OS,
+// and a change is acted on only once the file has held still for a poll,
+// so a linker still writing it isn't caught halfway.
+package gui
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// watchPoll is how often the ROM file is checked.
+const watchPoll = 250 * time.Millisecond
+
+// fileStamp is what a poll compares: a rebuild changes one or the other.
+type fileStamp struct {
+	size int64
+	mod  time.Time
+}
+
+func statFile(path string) (fileStamp, bool) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{fi.Size(), fi.ModTime()}, true
+}
+
+// romWatch is the state of Options.Watch.
+type romWatch struct {
+	keep nes.SwapMode
+	next time.Time // the next poll
+
+	// path and loaded are the file running and how it was when loaded;
+	// changed is a newer version seen at the last poll, reloaded if the
+	// next poll finds it the same.
+	path    string
+	loaded  fileStamp
+	changed *fileStamp
+}
+
+// checkROMWatch polls the ROM file, reloading it once a change has
+// settled. Called from the main loop without emuMu held.
+func (g *NESGUI) checkROMWatch() {
+	w := g.watch
+	if w == nil || time.Now().Before(w.next) {
+		return
+	}
+	w.next = time.Now().Add(watchPoll)
+	g.emuMu.Lock()
+	path := g.romPath
+	g.emuMu.Unlock()
+	if path == "" {
+		return
+	}
+	st, ok := statFile(path)
+	if path != w.path {
+		// A new ROM was opened; watch it from here.
+		w.path, w.loaded, w.changed = path, st, nil
+		return
+	}
+	if !ok || st == w.loaded {
+		w.changed = nil
+		return
+	}
+	if w.changed == nil || *w.changed != st {
+		w.changed = &st // still being written, perhaps
+		return
+	}
+	w.loaded, w.changed = st, nil
+
+	g.emuMu.Lock()
+	defer g.emuMu.Unlock()
+	g.reloadROM()
+	w.path = g.romPath
+}
+
+// reloadROM loads the ROM file over the running one, keeping what
+// Options.WatchKeep says. Called with emuMu held.
+func (g *NESGUI) reloadROM() {
+	name := filepath.Base(g.romPath)
+	if g.watch.keep == nes.SwapPowerOn {
+		if err := g.loadROM(g.romPath); err != nil {
+			logger.LogError("Watch: %s: %v", name, err)
+			g.notify("Reload failed: %s", name)
+		}
+		return
+	}
+	cart, err := openROM(g.romPath)
+	if err != nil {
+		logger.LogError("Watch: %s: %v", name, err)
+		g.notify("Reload failed: %s", name)
+		return
+	}
+	if err := g.nes.SwapCartridge(cart, g.watch.keep); err != nil {
+		logger.LogError("Watch: %s: %v", name, err)
+	}
+	if g.tracer != nil {
+		g.tracer.Reset()
+	}
+	g.resetUndo()
+	g.loadSymbols() // the rebuild moved the labels
+	g.notify("Reloaded %s (kept %s)", name, g.watch.keep)
+}
@@ -5,7 +5,7 @@ type Controller struct {
 	// Button states
 	buttons   uint8
 	strobe    bool
-	index     uint8
+	shift     uint8 // latched report, shifted out LSB-first by Read
 	
 	// Button mapping
 	ButtonA      bool
@@ -49,8 +49,18 @@ func (c *Controller) SetButton(controller int, button int, pressed bool) {
 	} else {
 		c.buttons &^= buttonMask
 	}
-	
-	// Update individual button states for easier access
+	c.syncButtons()
+}
+
+// SetButtons replaces the whole button state with mask (ButtonMask* bits),
+// for frontends that sample every button at once.
+func (c *Controller) SetButtons(mask uint8) {
+	c.buttons = mask
+	c.syncButtons()
+}
+
+// syncButtons updates the individual button fields from c.buttons.
+func (c *Controller) syncButtons() {
 	c.ButtonA = c.buttons&ButtonMaskA != 0
 	c.ButtonB = c.buttons&ButtonMaskB != 0
 	c.ButtonSelect = c.buttons&ButtonMaskSelect != 0
@@ -61,27 +71,30 @@ func (c *Controller) SetButton(controller int, button int, pressed bool) {
 	c.ButtonRight = c.buttons&ButtonMaskRight != 0
 }
 
-// Read reads the controller state
+// Read returns the next serial bit (in bit 0) and shifts the report.
+//
+// The standard controller is a 4021 shift register: while the strobe
+// line is high it continuously reloads from the buttons, so every read
+// returns the live state of A. Once strobe drops the latched report
+// shifts out A, B, Select, Start, Up, Down, Left, Right; the serial input
+// is tied high, so every read after the eighth returns 1.
 func (c *Controller) Read() uint8 {
-	if c.index > 7 {
-		return 1
-	}
-	
-	result := (c.buttons >> c.index) & 1
-	
-	if !c.strobe {
-		c.index++
+	if c.strobe {
+		return c.buttons & 1
 	}
-	
+	result := c.shift & 1
+	c.shift = c.shift>>1 | 0x80
 	return result
 }
 
-// Write writes to the controller (strobe)
+// Write receives the $4016 OUT latch. Bit 0 is the strobe line. The
+// register keeps reloading for as long as strobe is high, so the report
+// that gets shifted out is the button state at the moment strobe falls.
 func (c *Controller) Write(value uint8) {
-	c.strobe = value&1 != 0
-	if c.strobe {
-		c.index = 0
+	if c.strobe || value&1 != 0 {
+		c.shift = c.buttons
 	}
+	c.strobe = value&1 != 0
 }
 
 // GetButtons returns the current button state
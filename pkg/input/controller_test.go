@@ -48,3 +48,87 @@ func TestStrobeHighLocks(t *testing.T) {
 		}
 	}
 }
+
+// TestStrobeHighTracksA verifies that reads with strobe held high follow
+// A as it changes — games like Paperboy poll this way — and that the
+// other buttons never show.
+func TestStrobeHighTracksA(t *testing.T) {
+	c := New()
+	c.Write(1)
+	for _, mask := range []uint8{ButtonMaskA, 0, ButtonMaskB | ButtonMaskStart, ButtonMaskA | ButtonMaskRight, 0} {
+		c.SetButtons(mask)
+		want := mask & ButtonMaskA
+		for j := 0; j < 3; j++ {
+			if got := c.Read(); got != want {
+				t.Errorf("buttons $%02X, read %d: got %d want %d", mask, j, got, want)
+			}
+		}
+	}
+	// Ports share the strobe: both ports report their own A.
+	p1, p2 := New(), New()
+	ports := NewPorts(p1, p2)
+	ports.Write(1)
+	p2.SetButtons(ButtonMaskA)
+	if ports.Read(0) != 0 || ports.Read(1) != 1 {
+		t.Error("strobe high: want P1 A=0, P2 A=1")
+	}
+	p1.SetButtons(ButtonMaskA)
+	p2.SetButtons(0)
+	if ports.Read(0) != 1 || ports.Read(1) != 0 {
+		t.Error("strobe high after the change: want P1 A=1, P2 A=0")
+	}
+}
+
+// TestLatchOnStrobeFall verifies the report is the button state when
+// strobe falls, not when it rose, and that later presses don't leak into
+// a report that is already being shifted out.
+func TestLatchOnStrobeFall(t *testing.T) {
+	c := New()
+	c.Write(1)
+	c.SetButton(0, 3, true) // Start pressed while strobe is still high
+	c.Write(0)
+	c.SetButton(0, 0, true) // A pressed mid-read
+
+	want := []uint8{0, 0, 0, 1, 0, 0, 0, 0}
+	for i, w := range want {
+		if got := c.Read() & 1; got != w {
+			t.Errorf("read %d: got %d want %d", i, got, w)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if c.Read() != 1 {
+			t.Errorf("read %d past the report: want 1", 9+i)
+		}
+	}
+}
+
+func TestPortsRouting(t *testing.T) {
+	p1, p2 := New(), New()
+	ports := NewPorts(p1, p2)
+
+	ports.SetButton(0, 0, true) // P1 A
+	ports.SetButton(1, 1, true) // P2 B
+	if p1.GetButtons() != ButtonMaskA || p2.GetButtons() != ButtonMaskB {
+		t.Fatalf("SetButton routing: p1=%#02x p2=%#02x", p1.GetButtons(), p2.GetButtons())
+	}
+
+	// The strobe is shared by both ports.
+	ports.Write(1)
+	ports.Write(0)
+	if ports.Read(0) != 1 || ports.Read(1) != 0 {
+		t.Error("first bit: want P1 A=1, P2 A=0")
+	}
+	if ports.Read(0) != 0 || ports.Read(1) != 1 {
+		t.Error("second bit: want P1 B=0, P2 B=1")
+	}
+
+	// An empty port reads as all lines low and ignores input.
+	ports.Connect(1, nil)
+	ports.SetButton(1, 0, true)
+	if ports.Read(1) != 0 || ports.Controller(1) != nil {
+		t.Error("unplugged port should read 0 and have no controller")
+	}
+	if ports.Device(2) != nil || ports.Read(-1) != 0 {
+		t.Error("out-of-range ports should be empty")
+	}
+}
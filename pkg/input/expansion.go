@@ -0,0 +1,71 @@
+package input
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ExpansionDevice is a peripheral in the Famicom's expansion port (the
+// Family BASIC keyboard, a Barcode Battler, ...). The port carries the
+// same OUT latch as the controller ports and its own data lines, which
+// the console ORs onto D1-D4 of both $4016 and $4017; D0 stays the
+// controllers'.
+type ExpansionDevice interface {
+	// Read returns the device's lines for a $4016 (port 0) or $4017
+	// (port 1) read. Only bits 1-4 are used.
+	Read(port int) uint8
+	// Write receives the value written to $4016.
+	Write(value uint8)
+}
+
+// expansionDevices maps RegisterExpansion names to constructors.
+var (
+	expansionMu      sync.RWMutex
+	expansionDevices = map[string]func() ExpansionDevice{}
+)
+
+// RegisterExpansion makes a device available to NewExpansion under name,
+// replacing any registered before. Peripherals register themselves from
+// init, as the keyboard does, so adding one touches neither the ports nor
+// the bus.
+func RegisterExpansion(name string, newDevice func() ExpansionDevice) {
+	expansionMu.Lock()
+	defer expansionMu.Unlock()
+	expansionDevices[strings.ToLower(name)] = newDevice
+}
+
+// NewExpansion builds the device registered as name (case-insensitive);
+// "" or "none" is no device (nil).
+func NewExpansion(name string) (ExpansionDevice, error) {
+	name = strings.ToLower(name)
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+	expansionMu.RLock()
+	newDevice, ok := expansionDevices[name]
+	expansionMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown expansion device %q (have %s)", name, strings.Join(ExpansionDevices(), ", "))
+	}
+	return newDevice(), nil
+}
+
+// ExpansionDevices lists the registered device names, sorted.
+func ExpansionDevices() []string {
+	expansionMu.RLock()
+	defer expansionMu.RUnlock()
+	names := make([]string, 0, len(expansionDevices))
+	for name := range expansionDevices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetExpansion plugs d into the expansion port; nil unplugs it.
+func (p *Ports) SetExpansion(d ExpansionDevice) { p.expansion = d }
+
+// Expansion returns the device in the expansion port, or nil.
+func (p *Ports) Expansion() ExpansionDevice { return p.expansion }
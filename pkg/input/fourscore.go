@@ -0,0 +1,75 @@
+package input
+
+// FourScore emulates the NES Four Score / NES Satellite four-player
+// adapter. Controllers 1 and 3 report through port 1 ($4016), 2 and 4
+// through port 2 ($4017). After a strobe each port shifts out a 24-bit
+// report (NESdev "Four Score"):
+//
+//	reads  1-8:  controller 1 (or 2)
+//	reads  9-16: controller 3 (or 4)
+//	reads 17-24: signature — 0,0,0,1,0,0,0,0 on $4016 and
+//	             0,0,1,0,0,0,0,0 on $4017 ($10 / $20 assembled MSB-first)
+//
+// Games check the signature to tell the adapter from two plain pads.
+// Reads past the 24th return 1, like a lone controller past its 8th.
+type FourScore struct {
+	Controllers [4]*Controller
+
+	strobe bool
+	reads  [2]int // bits shifted out of each port since the last strobe
+}
+
+// fourScoreSignature holds each port's signature bits in read order
+// (bit 0 = read 17).
+var fourScoreSignature = [2]uint8{0x08, 0x04}
+
+// NewFourScore returns an adapter with four fresh controllers.
+func NewFourScore() *FourScore {
+	return &FourScore{Controllers: [4]*Controller{New(), New(), New(), New()}}
+}
+
+// Port returns the Device to plug into port (0 or 1).
+func (f *FourScore) Port(port int) Device {
+	return &fourScorePort{fs: f, port: port & 1}
+}
+
+// write latches every controller and restarts both reports.
+func (f *FourScore) write(value uint8) {
+	for _, c := range f.Controllers {
+		c.Write(value)
+	}
+	if f.strobe || value&1 != 0 {
+		f.reads = [2]int{}
+	}
+	f.strobe = value&1 != 0
+}
+
+func (f *FourScore) read(port int) uint8 {
+	if f.strobe {
+		return f.Controllers[port].Read()
+	}
+	n := f.reads[port]
+	if n < 24 {
+		f.reads[port]++
+	}
+	switch {
+	case n < 8:
+		return f.Controllers[port].Read()
+	case n < 16:
+		return f.Controllers[port+2].Read()
+	case n < 24:
+		return fourScoreSignature[port] >> (n - 16) & 1
+	}
+	return 1
+}
+
+// fourScorePort is one connector's view of a FourScore. Ports.Write
+// delivers each $4016 write to both connectors, so the adapter sees it
+// twice; write is idempotent for a repeated value, which makes that safe.
+type fourScorePort struct {
+	fs   *FourScore
+	port int
+}
+
+func (p *fourScorePort) Read() uint8       { return p.fs.read(p.port) }
+func (p *fourScorePort) Write(value uint8) { p.fs.write(value) }
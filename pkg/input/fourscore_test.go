@@ -0,0 +1,115 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/core"
+)
+
+// readReport strobes the ports and collects n bits from port.
+func readReport(p *Ports, port, n int) []uint8 {
+	p.Write(1)
+	p.Write(0)
+	bits := make([]uint8, n)
+	for i := range bits {
+		bits[i] = p.Read(port)
+	}
+	return bits
+}
+
+func TestFourScoreReport(t *testing.T) {
+	p := NewPorts(New(), New())
+	p.SetFourScore(true)
+	p.SetButton(0, 0, true) // P1 A
+	p.SetButton(1, 3, true) // P2 Start
+	p.SetButton(2, 7, true) // P3 Right
+	p.SetButton(3, 1, true) // P4 B
+
+	want := [2][]uint8{
+		{1, 0, 0, 0, 0, 0, 0, 0, // P1
+			0, 0, 0, 0, 0, 0, 0, 1, // P3
+			0, 0, 0, 1, 0, 0, 0, 0, // signature $10
+			1, 1},
+		{0, 0, 0, 1, 0, 0, 0, 0, // P2
+			0, 1, 0, 0, 0, 0, 0, 0, // P4
+			0, 0, 1, 0, 0, 0, 0, 0, // signature $20
+			1, 1},
+	}
+	for port := 0; port < 2; port++ {
+		got := readReport(p, port, len(want[port]))
+		for i := range got {
+			if got[i] != want[port][i] {
+				t.Errorf("port %d read %d: got %d want %d", port+1, i+1, got[i], want[port][i])
+			}
+		}
+	}
+}
+
+// TestFourScoreSignatureMSBFirst assembles the signature the way games
+// do (ROL per read) and checks the documented $10 / $20 values.
+func TestFourScoreSignatureMSBFirst(t *testing.T) {
+	p := NewPorts(New(), New())
+	p.SetFourScore(true)
+	for port, want := range []uint8{0x10, 0x20} {
+		bits := readReport(p, port, 24)
+		var sig uint8
+		for _, b := range bits[16:] {
+			sig = sig<<1 | b
+		}
+		if sig != want {
+			t.Errorf("port %d signature = $%02X, want $%02X", port+1, sig, want)
+		}
+	}
+}
+
+func TestFourScoreStrobeHigh(t *testing.T) {
+	p := NewPorts(New(), New())
+	p.SetFourScore(true)
+	p.SetButton(1, 0, true) // P2 A
+	p.Write(1)
+	for i := 0; i < 30; i++ {
+		if p.Read(0) != 0 || p.Read(1) != 1 {
+			t.Fatalf("read %d with strobe high should return live A of P1/P2", i)
+		}
+	}
+}
+
+func TestSetFourScoreKeepsControllers(t *testing.T) {
+	p := NewPorts(New(), New())
+	p.SetButton(0, 0, true)
+	if p.Controller(2) != nil {
+		t.Error("controller 3 should not exist without a Four Score")
+	}
+
+	p.SetFourScore(true)
+	if !p.FourScore() {
+		t.Fatal("FourScore() = false after enabling")
+	}
+	if !p.Controller(0).IsPressed(ButtonMaskA) {
+		t.Error("P1 state lost when attaching the Four Score")
+	}
+	if p.Controller(2) == nil || p.Controller(3) == nil || p.Controller(4) != nil {
+		t.Error("Four Score should expose exactly controllers 1-4")
+	}
+
+	p.SetFourScore(false)
+	if p.FourScore() || !p.Controller(0).IsPressed(ButtonMaskA) {
+		t.Error("detaching should restore plain pads with P1 state intact")
+	}
+	if p.Controller(2) != nil {
+		t.Error("controller 3 should be gone after detaching")
+	}
+}
+
+func TestAppendButtons(t *testing.T) {
+	p := NewPorts(New(), New())
+	p.SetButton(1, 3, true)
+	if got := p.AppendButtons(nil); len(got) != 2 || got[0] != 0 || got[1] != core.ButtonStart {
+		t.Errorf("two pads: %v", got)
+	}
+	p.SetFourScore(true)
+	p.SetButton(3, 0, true)
+	if got := p.AppendButtons(nil); len(got) != 4 || got[1] != core.ButtonStart || got[3] != core.ButtonA {
+		t.Errorf("Four Score: %v", got)
+	}
+}
@@ -0,0 +1,97 @@
+package input
+
+import "strings"
+
+// Keyboard emulates the Family BASIC keyboard (HVC-007) in the expansion
+// port. Its 72 keys form a matrix of 9 rows by 2 columns of 4 keys, which
+// the game scans through the OUT latch (NESdev "Family BASIC Keyboard"):
+//
+//	$4016 write  bit 0: back to row 0
+//	             bit 1: column; going from 1 to 0 moves to the next row
+//	             bit 2: keyboard enable (0 reads as all lines low)
+//	$4017 read   bits 1-4: the selected row and column's four keys,
+//	             0 = pressed
+//
+// Scanning past the ninth row reads as no keys held, which is how
+// software detects the keyboard.
+type Keyboard struct {
+	held    [keyboardRows][2]uint8 // bits 1-4 of each row and column's keys held
+	row     int
+	column  int
+	enabled bool
+}
+
+const keyboardRows = 9
+
+// keyboardMatrix names each key by its row, column and $4017 bit (1-4).
+var keyboardMatrix = [keyboardRows][2][4]string{
+	{{"F8", "RETURN", "[", "]"}, {"KANA", "RSHIFT", "YEN", "STOP"}},
+	{{"F7", "@", ":", ";"}, {"_", "/", "-", "^"}},
+	{{"F6", "O", "L", "K"}, {".", ",", "P", "0"}},
+	{{"F5", "I", "U", "J"}, {"M", "N", "9", "8"}},
+	{{"F4", "Y", "G", "H"}, {"B", "V", "7", "6"}},
+	{{"F3", "T", "R", "D"}, {"F", "C", "5", "4"}},
+	{{"F2", "W", "S", "A"}, {"X", "Z", "E", "3"}},
+	{{"F1", "ESC", "Q", "CTRL"}, {"LSHIFT", "GRPH", "1", "2"}},
+	{{"CLR", "UP", "RIGHT", "LEFT"}, {"DOWN", "SPACE", "DEL", "INS"}},
+}
+
+func init() {
+	RegisterExpansion("keyboard", func() ExpansionDevice { return NewKeyboard() })
+}
+
+// NewKeyboard returns a keyboard with no keys held.
+func NewKeyboard() *Keyboard { return &Keyboard{} }
+
+// SetKey presses or releases the key named name, as keyboardMatrix spells
+// it (letters, digits, F1-F8, RETURN, SPACE, LSHIFT, ...; case doesn't
+// matter). It reports false for a name the keyboard doesn't have.
+func (k *Keyboard) SetKey(name string, pressed bool) bool {
+	name = strings.ToUpper(name)
+	for row := range keyboardMatrix {
+		for col := range keyboardMatrix[row] {
+			for i, key := range keyboardMatrix[row][col] {
+				if key != name {
+					continue
+				}
+				if pressed {
+					k.held[row][col] |= 2 << i
+				} else {
+					k.held[row][col] &^= 2 << i
+				}
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Release lets go of every key.
+func (k *Keyboard) Release() { k.held = [keyboardRows][2]uint8{} }
+
+// Read implements ExpansionDevice. The keyboard answers on $4017 only.
+func (k *Keyboard) Read(port int) uint8 {
+	if port != 1 || !k.enabled {
+		return 0
+	}
+	if k.row >= keyboardRows {
+		return 0x1E
+	}
+	return ^k.held[k.row][k.column] & 0x1E
+}
+
+// Write implements ExpansionDevice.
+func (k *Keyboard) Write(value uint8) {
+	prev := k.column
+	k.column = int(value>>1) & 1
+	k.enabled = value&4 != 0
+	if !k.enabled {
+		return
+	}
+	if prev == 1 && k.column == 0 {
+		k.row = (k.row + 1) % (keyboardRows + 1)
+	}
+	if value&1 != 0 {
+		k.row = 0
+	}
+}
@@ -0,0 +1,81 @@
+package input
+
+import (
+	"strings"
+	"testing"
+)
+
+// scanKeyboard reads the whole matrix the way Family BASIC does: reset to
+// row 0, then for each row read column 0, select column 1 and read it,
+// and deselect to step to the next row. It returns $4017 bits 1-4 of
+// every read.
+func scanKeyboard(p *Ports) [keyboardRows + 1][2]uint8 {
+	var rows [keyboardRows + 1][2]uint8
+	p.Write(0x05)
+	for row := range rows {
+		p.Write(0x04)
+		rows[row][0] = p.Read(1) & 0x1E
+		p.Write(0x06)
+		rows[row][1] = p.Read(1) & 0x1E
+	}
+	return rows
+}
+
+func TestKeyboardMatrixScan(t *testing.T) {
+	p := NewPorts(New(), New())
+	dev, err := NewExpansion("Keyboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetExpansion(dev)
+	kb := dev.(*Keyboard)
+	for _, key := range []string{"return", "Z", "space"} {
+		if !kb.SetKey(key, true) {
+			t.Fatalf("SetKey(%q) found no such key", key)
+		}
+	}
+	if kb.SetKey("F9", true) {
+		t.Error("SetKey(F9) should report an unknown key")
+	}
+
+	rows := scanKeyboard(p)
+	want := map[[2]int]uint8{
+		{0, 0}: 0x1E &^ 0x04, // RETURN, bit 2
+		{6, 1}: 0x1E &^ 0x04, // Z, bit 2
+		{8, 1}: 0x1E &^ 0x04, // SPACE, bit 2
+	}
+	for row := 0; row < keyboardRows; row++ {
+		for col := 0; col < 2; col++ {
+			w, ok := want[[2]int{row, col}]
+			if !ok {
+				w = 0x1E
+			}
+			if rows[row][col] != w {
+				t.Errorf("row %d column %d = %02X, want %02X", row, col, rows[row][col], w)
+			}
+		}
+	}
+	if rows[keyboardRows] != [2]uint8{0x1E, 0x1E} {
+		t.Errorf("row past the matrix = %02X, want no keys", rows[keyboardRows])
+	}
+
+	// The controllers keep D0, and a disabled keyboard drives nothing.
+	p.SetButton(1, 0, true)
+	p.Write(1)
+	p.Write(0)
+	if v := p.Read(1); v != 0x01 {
+		t.Errorf("$4017 with the keyboard disabled = %02X, want only the pad's A", v)
+	}
+	if v := p.Read(0); v&0x1E != 0 {
+		t.Errorf("$4016 = %02X; the keyboard answers on $4017 only", v)
+	}
+}
+
+func TestNewExpansion(t *testing.T) {
+	if d, err := NewExpansion("none"); d != nil || err != nil {
+		t.Errorf(`NewExpansion("none") = %v, %v; want no device`, d, err)
+	}
+	if _, err := NewExpansion("zapper2"); err == nil || !strings.Contains(err.Error(), "keyboard") {
+		t.Errorf("unknown device error = %v, want it to list the registered ones", err)
+	}
+}
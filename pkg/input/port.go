@@ -0,0 +1,149 @@
+package input
+
+import "github.com/yoshiomiyamaegones/pkg/core"
+
+// Device is a peripheral plugged into one of the two controller ports.
+//
+// The CPU sees a port through $4016 (port 1) or $4017 (port 2): a read
+// clocks the device once and returns its data lines D0-D4 in bits 0-4,
+// while bits 5-7 are not driven and come from CPU open bus. A write to
+// $4016 sets the OUT latch shared by both ports; bit 0 is the strobe
+// every serial device listens to.
+type Device interface {
+	// Read returns the device's D0-D4 lines for one port read. Bits 5-7
+	// of the result are ignored.
+	Read() uint8
+	// Write receives the value written to $4016.
+	Write(value uint8)
+}
+
+// Ports is the console side of the controller connectors: it routes
+// $4016/$4017 accesses to whatever Device is plugged into each port, and
+// to the Famicom expansion port's device (see ExpansionDevice), and in a
+// VS. System cabinet to its panel (see VSPanel). An empty port reads as
+// all data lines low.
+type Ports struct {
+	devices   [2]Device
+	expansion ExpansionDevice
+	vs        *VSPanel
+}
+
+// NewPorts returns Ports with p1 and p2 connected (either may be nil).
+func NewPorts(p1, p2 Device) *Ports {
+	return &Ports{devices: [2]Device{p1, p2}}
+}
+
+// Connect plugs d into port (0 or 1), replacing whatever was there. A
+// nil d unplugs the port.
+func (p *Ports) Connect(port int, d Device) {
+	if port < 0 || port >= len(p.devices) {
+		return
+	}
+	p.devices[port] = d
+}
+
+// Device returns the device in port (0 or 1), or nil.
+func (p *Ports) Device(port int) Device {
+	if port < 0 || port >= len(p.devices) {
+		return nil
+	}
+	return p.devices[port]
+}
+
+// Read handles a $4016 (port 0) or $4017 (port 1) read, returning the
+// DrivenLines only. The memory bus merges in the open-bus bits.
+func (p *Ports) Read(port int) uint8 {
+	var v uint8
+	if d := p.Device(port); d != nil {
+		v = d.Read() & 0x1F
+	}
+	if p.expansion != nil {
+		v |= p.expansion.Read(port) & 0x1E
+	}
+	if p.vs != nil {
+		v |= p.vs.read(port)
+	}
+	return v & p.DrivenLines(port)
+}
+
+// Write handles a $4016 write. The OUT latch is wired to both ports and
+// the expansion port, so every connected device sees it.
+func (p *Ports) Write(value uint8) {
+	for _, d := range p.devices {
+		if d != nil {
+			d.Write(value)
+		}
+	}
+	if p.expansion != nil {
+		p.expansion.Write(value)
+	}
+}
+
+// Controller returns standard controller i: 0 and 1 are the pads in
+// ports 1 and 2, and with a Four Score attached 2 and 3 are its third and
+// fourth pads. It returns nil when no such controller is connected.
+func (p *Ports) Controller(i int) *Controller {
+	if i < 0 || i > 3 {
+		return nil
+	}
+	switch d := p.Device(i & 1).(type) {
+	case *Controller:
+		if i < 2 {
+			return d
+		}
+	case *fourScorePort:
+		return d.fs.Controllers[i]
+	}
+	return nil
+}
+
+// AppendButtons appends the buttons of every connected controller to dst,
+// in Controller order: two entries, or four with a Four Score. It's the
+// state the game reads, as of the last latch — what an input display
+// should show.
+func (p *Ports) AppendButtons(dst []core.ButtonState) []core.ButtonState {
+	for i := 0; i < 4; i++ {
+		if c := p.Controller(i); c != nil {
+			dst = append(dst, core.ButtonState(c.GetButtons()))
+		}
+	}
+	return dst
+}
+
+// SetButton presses or releases button (0-7, A..Right) on controller
+// (see Controller for numbering). Input for a controller that isn't
+// connected is dropped.
+func (p *Ports) SetButton(controller int, button int, pressed bool) {
+	if c := p.Controller(controller); c != nil {
+		c.SetButton(0, button, pressed)
+	}
+}
+
+// SetFourScore plugs a Four Score into both ports (on) or goes back to
+// two standard controllers (off). Controllers 1 and 2 carry over in
+// either direction, so held buttons survive the switch.
+func (p *Ports) SetFourScore(on bool) {
+	if on == p.FourScore() {
+		return
+	}
+	c1, c2 := p.Controller(0), p.Controller(1)
+	if c1 == nil {
+		c1 = New()
+	}
+	if c2 == nil {
+		c2 = New()
+	}
+	if !on {
+		p.devices = [2]Device{c1, c2}
+		return
+	}
+	fs := NewFourScore()
+	fs.Controllers[0], fs.Controllers[1] = c1, c2
+	p.devices = [2]Device{fs.Port(0), fs.Port(1)}
+}
+
+// FourScore reports whether a Four Score is attached.
+func (p *Ports) FourScore() bool {
+	_, ok := p.devices[0].(*fourScorePort)
+	return ok
+}
@@ -0,0 +1,73 @@
+package input
+
+import (
+	"sync/atomic"
+
+	"github.com/yoshiomiyamaegones/pkg/core"
+)
+
+// Staging is a thread-safe holding area for up to four controllers'
+// buttons. Frontends write into it from whatever goroutine sees the input
+// — an SDL event loop, a netplay receiver, a movie player — and the
+// emulator only reads it through Pad providers, which nes.NES polls at its
+// chosen latch point (once per frame by default). The CPU therefore never
+// sees a button change partway through a frame, however the writer's
+// timing falls.
+type Staging struct {
+	state atomic.Uint32 // controller i's buttons in bits 8i-8i+7
+}
+
+// SetButton presses or releases button (0-7, A..Right) on controller
+// (0-3). Out-of-range arguments are ignored.
+func (s *Staging) SetButton(controller int, button int, pressed bool) {
+	if controller < 0 || controller > 3 || button < 0 || button > 7 {
+		return
+	}
+	bit := uint32(1) << (controller*8 + button)
+	for {
+		old := s.state.Load()
+		next := old &^ bit
+		if pressed {
+			next |= bit
+		}
+		if s.state.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// SetButtons replaces controller's whole button state with b.
+func (s *Staging) SetButtons(controller int, b core.ButtonState) {
+	if controller < 0 || controller > 3 {
+		return
+	}
+	shift := controller * 8
+	for {
+		old := s.state.Load()
+		next := old&^(0xFF<<shift) | uint32(b)<<shift
+		if s.state.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// Buttons returns controller's staged buttons.
+func (s *Staging) Buttons(controller int) core.ButtonState {
+	if controller < 0 || controller > 3 {
+		return 0
+	}
+	return core.ButtonState(s.state.Load() >> (controller * 8))
+}
+
+// Pad returns the core.InputProvider that reports controller's staged
+// buttons, for nes.NES.SetInputProvider.
+func (s *Staging) Pad(controller int) core.InputProvider {
+	return stagedPad{s, controller}
+}
+
+type stagedPad struct {
+	s          *Staging
+	controller int
+}
+
+func (p stagedPad) Poll() core.ButtonState { return p.s.Buttons(p.controller) }
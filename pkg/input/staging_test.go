@@ -0,0 +1,50 @@
+package input
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/core"
+)
+
+func TestStaging(t *testing.T) {
+	var s Staging
+	s.SetButton(0, 3, true) // Start
+	s.SetButtons(2, core.ButtonA|core.ButtonRight)
+	s.SetButton(2, 0, false)
+	s.SetButton(4, 0, true) // out of range: ignored
+	s.SetButton(1, 8, true)
+
+	for i, want := range []core.ButtonState{core.ButtonStart, 0, core.ButtonRight, 0} {
+		if got := s.Pad(i).Poll(); got != want {
+			t.Errorf("pad %d = %#02x, want %#02x", i, got, want)
+		}
+	}
+	if s.Buttons(-1) != 0 || s.Buttons(4) != 0 {
+		t.Error("out-of-range controllers should read 0")
+	}
+}
+
+// TestStagingConcurrentWriters: writers on different goroutines never
+// lose each other's updates (run with -race).
+func TestStagingConcurrentWriters(t *testing.T) {
+	var s Staging
+	var wg sync.WaitGroup
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				s.SetButton(c, i%8, i%2 == 0)
+				s.Pad(c).Poll()
+			}
+			s.SetButtons(c, core.ButtonState(c+1))
+		}(c)
+	}
+	wg.Wait()
+	for c := 0; c < 4; c++ {
+		if got := s.Buttons(c); got != core.ButtonState(c+1) {
+			t.Errorf("controller %d = %#02x, want %#02x", c, got, c+1)
+		}
+	}
+}
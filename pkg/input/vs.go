@@ -0,0 +1,88 @@
+package input
+
+// VSPanel is the VS. System cabinet's own inputs: the bank of eight DIP
+// switches on the mainboard, the coin mechs and the service button. They
+// share $4016/$4017 with the controllers, on lines a console leaves to
+// open bus:
+//
+//	$4016  bit 2 service, bits 3-4 DIP 1-2, bits 5-6 coin 1-2
+//	$4017  bits 2-7 DIP 3-8
+type VSPanel struct {
+	// DIPs holds the switches, DIP 1 in bit 0; a set bit is on. What
+	// each switch does is up to the game (difficulty, lives, coinage).
+	DIPs uint8
+	// Service is the service button, held while true.
+	Service bool
+
+	// coins counts down the frames each coin switch stays closed.
+	coins [2]int
+}
+
+// vsCoinFrames is how long a coin dropped through the mech holds its
+// switch closed: long enough for a game polling once a frame to see it.
+const vsCoinFrames = 4
+
+// NewVSPanel returns a cabinet with its DIP switches set to dips.
+func NewVSPanel(dips uint8) *VSPanel { return &VSPanel{DIPs: dips} }
+
+// InsertCoin drops a coin into slot 0 or 1.
+func (v *VSPanel) InsertCoin(slot int) {
+	if slot >= 0 && slot < len(v.coins) {
+		v.coins[slot] = vsCoinFrames
+	}
+}
+
+// StepFrame releases the coin switches as their coins fall through.
+func (v *VSPanel) StepFrame() {
+	for i := range v.coins {
+		if v.coins[i] > 0 {
+			v.coins[i]--
+		}
+	}
+}
+
+// read returns the panel's lines for a $4016 (port 0) or $4017 (port 1)
+// read.
+func (v *VSPanel) read(port int) uint8 {
+	if port == 1 {
+		return v.DIPs & 0xFC
+	}
+	b := (v.DIPs & 0x03) << 3
+	if v.Service {
+		b |= 0x04
+	}
+	for i, n := range v.coins {
+		if n > 0 {
+			b |= 0x20 << i
+		}
+	}
+	return b
+}
+
+// vsLines are the lines of $4016 and $4017 driven on a VS. System: the
+// controllers' D0-D4 plus the panel's.
+var vsLines = [2]uint8{0x7F, 0xFF}
+
+// SetVS puts the ports in a VS. System cabinet with panel v; nil is a
+// console again.
+func (p *Ports) SetVS(v *VSPanel) { p.vs = v }
+
+// VS returns the cabinet panel, or nil on a console.
+func (p *Ports) VS() *VSPanel { return p.vs }
+
+// DrivenLines returns the bits of a $4016 (port 0) or $4017 (port 1)
+// read that Read supplies; the rest are CPU open bus.
+func (p *Ports) DrivenLines(port int) uint8 {
+	if p.vs != nil && port >= 0 && port < len(vsLines) {
+		return vsLines[port]
+	}
+	return 0x1F
+}
+
+// StepFrame advances whatever in the ports keeps time in frames (a VS.
+// System's coin switches). The console calls it once per frame.
+func (p *Ports) StepFrame() {
+	if p.vs != nil {
+		p.vs.StepFrame()
+	}
+}
@@ -0,0 +1,32 @@
+package input
+
+import "testing"
+
+func TestVSPanel(t *testing.T) {
+	p := NewPorts(New(), New())
+	if p.DrivenLines(0) != 0x1F || p.Read(1) != 0 {
+		t.Fatal("a console drives D0-D4 only")
+	}
+
+	vs := NewVSPanel(0xA5) // switches 1, 3, 6 and 8
+	p.SetVS(vs)
+	vs.Service = true
+	if got := p.Read(0); got != 0x0C {
+		t.Errorf("$4016 = %02X, want service and DIP 1 ($0C)", got)
+	}
+	if got := p.Read(1); got != 0xA4 {
+		t.Errorf("$4017 = %02X, want DIPs 3-8 ($A4)", got)
+	}
+
+	vs.Service = false
+	vs.InsertCoin(1)
+	for frame := 0; frame < vsCoinFrames; frame++ {
+		if p.Read(0)&0x40 == 0 {
+			t.Fatalf("coin 2 switch open on frame %d", frame)
+		}
+		p.StepFrame()
+	}
+	if p.Read(0)&0x60 != 0 {
+		t.Error("the coin switch should open once the coin has dropped")
+	}
+}
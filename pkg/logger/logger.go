@@ -1,9 +1,25 @@
+// Package logger is the emulator's process-wide structured logger. Every
+// entry carries a component (cpu, ppu, apu, mapper, bus — or general, for
+// frontend messages) and a level, and each component has its own threshold,
+// so e.g. PPU tracing can be on while the CPU stays quiet. Entries are
+// written as text lines or JSON, and the most recent ones are also kept in
+// an in-memory ring that can be dumped after a crash or read back by a
+// debugger.
+//
+// Checking a component's gate is a single array load, but the arguments of
+// a Log* call are boxed at the call site whether or not anything is
+// emitted. Hot paths therefore guard with CPUEnabled/PPUEnabled/BusEnabled
+// (or Enabled), or pass a closure to LogFunc so nothing is formatted unless
+// the entry is kept.
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,19 +35,109 @@ const (
 	LogLevelTrace
 )
 
+var levelNames = [...]string{"off", "error", "warn", "info", "debug", "trace"}
+
+func (l LogLevel) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// Component identifies the subsystem an entry comes from.
+type Component uint8
+
+const (
+	General Component = iota // frontend / lifecycle messages (LogInfo etc.)
+	CPU
+	PPU
+	APU
+	Mapper
+	Bus
+	numComponents
+)
+
+var componentNames = [numComponents]string{"general", "cpu", "ppu", "apu", "mapper", "bus"}
+
+func (c Component) String() string {
+	if c >= numComponents {
+		return fmt.Sprintf("component(%d)", int(c))
+	}
+	return componentNames[c]
+}
+
+// ParseComponent maps a name as printed by Component.String back to the
+// Component.
+func ParseComponent(name string) (Component, bool) {
+	for i, n := range componentNames {
+		if n == name {
+			return Component(i), true
+		}
+	}
+	return 0, false
+}
+
+// Format selects how entries are written.
+type Format uint8
+
+const (
+	FormatText Format = iota // "[15:04:05.000] PPU: message"
+	FormatJSON               // one JSON object per line, for tooling
+)
+
+// Entry is one log record, as kept in the ring buffer.
+type Entry struct {
+	Time      time.Time
+	Level     LogLevel
+	Component Component
+	Message   string
+}
+
+// DefaultRingSize is how many entries Initialize keeps in memory.
+const DefaultRingSize = 256
+
 // Logger handles all logging for the emulator
 type Logger struct {
-	level         LogLevel
-	writer        io.Writer
-	cpuEnabled    bool
-	ppuEnabled    bool
-	apuEnabled    bool
-	mapperEnabled bool
+	// level is the threshold passed to Initialize. The Set*Logging toggles
+	// move a component between it and off.
+	level  LogLevel
+	levels [numComponents]LogLevel
+	writer io.Writer
+	format Format
+
+	// mu serialises output and the ring: the emulation goroutine and the
+	// SDL thread both log. Only taken once an entry has passed its gate.
+	mu       sync.Mutex
+	ring     []Entry
+	ringNext int
+	ringFull bool
 }
 
+// globalLogger backs the package-level functions. Code that needs its own
+// output and thresholds — a second emulator instance, a test — can use a
+// Logger from New instead.
 var globalLogger *Logger
 
-// Initialize sets up the global logger
+// New returns a logger writing to w with the same initial thresholds as
+// Initialize. It is independent of the global logger. A nil *Logger is
+// valid and drops everything.
+func New(level LogLevel, w io.Writer) *Logger {
+	l := &Logger{
+		level:  level,
+		writer: w,
+		ring:   make([]Entry, DefaultRingSize),
+	}
+	l.levels[General] = level
+	l.levels[CPU] = level
+	return l
+}
+
+// Default returns the global logger set up by Initialize, or nil before it.
+func Default() *Logger { return globalLogger }
+
+// Initialize sets up the global logger. General and CPU entries start at
+// level; PPU, APU, mapper and bus logging are off until enabled with their
+// Set*Logging toggle or SetLevel.
 func Initialize(level LogLevel, filename string) error {
 	var writer io.Writer = os.Stdout
 
@@ -43,143 +149,328 @@ func Initialize(level LogLevel, filename string) error {
 		writer = file
 	}
 
-	globalLogger = &Logger{
-		level:         level,
-		writer:        writer,
-		cpuEnabled:    true,
-		ppuEnabled:    false,
-		apuEnabled:    false,
-		mapperEnabled: false,
+	globalLogger = New(level, writer)
+	return nil
+}
+
+// SetLevel sets one component's threshold.
+func (l *Logger) SetLevel(c Component, level LogLevel) {
+	if l != nil && c < numComponents {
+		l.levels[c] = level
 	}
+}
 
+// SetLevel sets one component's threshold on the global logger.
+func SetLevel(c Component, level LogLevel) { globalLogger.SetLevel(c, level) }
+
+// Level returns one component's threshold (LogLevelOff for a nil logger).
+func (l *Logger) Level(c Component) LogLevel {
+	if l == nil || c >= numComponents {
+		return LogLevelOff
+	}
+	return l.levels[c]
+}
+
+// Level returns one component's threshold (LogLevelOff before Initialize).
+func Level(c Component) LogLevel { return globalLogger.Level(c) }
+
+// ParseLevels applies a comma-separated list of component=level pairs, as
+// given to -log-components (e.g. "ppu=trace,mapper=debug"). Pairs before a
+// malformed one are still applied.
+func ParseLevels(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, lvl, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("log level %q: want component=level", pair)
+		}
+		c, ok := ParseComponent(strings.TrimSpace(name))
+		if !ok {
+			return fmt.Errorf("unknown log component %q (want one of %s)", name, strings.Join(componentNames[:], ", "))
+		}
+		level, ok := parseLevel(strings.TrimSpace(lvl))
+		if !ok {
+			return fmt.Errorf("unknown log level %q for %s", lvl, c)
+		}
+		SetLevel(c, level)
+	}
 	return nil
 }
 
-// SetCPULogging enables or disables CPU instruction logging
-func SetCPULogging(enabled bool) {
-	if globalLogger != nil {
-		globalLogger.cpuEnabled = enabled
+// SetFormat switches between text and JSON output.
+func (l *Logger) SetFormat(f Format) {
+	if l != nil {
+		l.format = f
 	}
 }
 
-// SetPPULogging enables or disables PPU logging
-func SetPPULogging(enabled bool) {
-	if globalLogger != nil {
-		globalLogger.ppuEnabled = enabled
+// SetFormat switches the global logger between text and JSON output.
+func SetFormat(f Format) { globalLogger.SetFormat(f) }
+
+// SetRingSize resizes the in-memory ring, dropping what it held. 0 turns it
+// off.
+func (l *Logger) SetRingSize(n int) {
+	if l == nil {
+		return
 	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ring = make([]Entry, n)
+	l.ringNext = 0
+	l.ringFull = false
 }
 
-// SetAPULogging enables or disables APU logging
-func SetAPULogging(enabled bool) {
-	if globalLogger != nil {
-		globalLogger.apuEnabled = enabled
+// SetRingSize resizes the global logger's ring.
+func SetRingSize(n int) { globalLogger.SetRingSize(n) }
+
+// setComponent backs the Set*Logging toggles.
+func setComponent(c Component, enabled bool) {
+	if globalLogger == nil {
+		return
+	}
+	if enabled {
+		globalLogger.levels[c] = globalLogger.level
+	} else {
+		globalLogger.levels[c] = LogLevelOff
 	}
 }
 
+// SetCPULogging enables or disables CPU instruction logging
+func SetCPULogging(enabled bool) { setComponent(CPU, enabled) }
+
+// SetPPULogging enables or disables PPU logging
+func SetPPULogging(enabled bool) { setComponent(PPU, enabled) }
+
+// SetAPULogging enables or disables APU logging
+func SetAPULogging(enabled bool) { setComponent(APU, enabled) }
+
 // SetMapperLogging enables or disables mapper logging
-func SetMapperLogging(enabled bool) {
-	if globalLogger != nil {
-		globalLogger.mapperEnabled = enabled
-	}
+func SetMapperLogging(enabled bool) { setComponent(Mapper, enabled) }
+
+// SetBusLogging enables or disables CPU bus (memory map) logging
+func SetBusLogging(enabled bool) { setComponent(Bus, enabled) }
+
+// Enabled reports whether an entry for c at level would be kept.
+func (l *Logger) Enabled(c Component, level LogLevel) bool {
+	return l != nil && level > LogLevelOff && l.levels[c] >= level
+}
+
+// Enabled reports whether the global logger would keep an entry for c at
+// level.
+func Enabled(c Component, level LogLevel) bool {
+	return globalLogger.Enabled(c, level)
 }
 
 // CPUEnabled reports whether LogCPU would actually emit. Guard hot-path
 // LogCPU sites with this so their arguments aren't boxed into []interface{}
 // (a heap allocation that happens at the call site even when logging is off).
 func CPUEnabled() bool {
-	return globalLogger != nil && globalLogger.cpuEnabled && globalLogger.level >= LogLevelDebug
+	return Enabled(CPU, LogLevelDebug)
 }
 
 // PPUEnabled reports whether LogPPU would actually emit. See CPUEnabled for
 // why hot-path callers must guard with this.
 func PPUEnabled() bool {
-	return globalLogger != nil && globalLogger.ppuEnabled && globalLogger.level >= LogLevelTrace
+	return Enabled(PPU, LogLevelTrace)
+}
+
+// BusEnabled reports whether LogBus would actually emit; bus logging sits
+// on the memory map's read/write path, so every call site is guarded.
+func BusEnabled() bool {
+	return Enabled(Bus, LogLevelDebug)
+}
+
+// Logf records a formatted entry for c at level, if that component's
+// threshold lets it through.
+func (l *Logger) Logf(c Component, level LogLevel, format string, args ...interface{}) {
+	if !l.Enabled(c, level) {
+		return
+	}
+	l.emit(c, level, fmt.Sprintf(format, args...))
+}
+
+// Logf records a formatted entry on the global logger.
+func Logf(c Component, level LogLevel, format string, args ...interface{}) {
+	globalLogger.Logf(c, level, format, args...)
+}
+
+// LogFunc is Logf with the message built by msg, which is only called when
+// the entry is kept — for messages that are expensive to assemble.
+func (l *Logger) LogFunc(c Component, level LogLevel, msg func() string) {
+	if !l.Enabled(c, level) {
+		return
+	}
+	l.emit(c, level, msg())
+}
+
+// LogFunc is Logger.LogFunc on the global logger.
+func LogFunc(c Component, level LogLevel, msg func() string) {
+	globalLogger.LogFunc(c, level, msg)
 }
 
 // LogCPU logs CPU instruction execution (disabled for performance). Gate
 // reused by CPUEnabled so hot-path call-site guards can't drift from it.
 func LogCPU(format string, args ...interface{}) {
-	if !CPUEnabled() {
-		return
-	}
-	timestamp := time.Now().Format("15:04:05.000")
-	message := fmt.Sprintf(format, args...)
-	fmt.Fprintf(globalLogger.writer, "[%s] CPU: %s\n", timestamp, message)
+	Logf(CPU, LogLevelDebug, format, args...)
 }
 
 // LogPPU logs PPU operations
 func LogPPU(format string, args ...interface{}) {
-	if !PPUEnabled() {
-		return
-	}
-	timestamp := time.Now().Format("15:04:05.000")
-	message := fmt.Sprintf(format, args...)
-	fmt.Fprintf(globalLogger.writer, "[%s] PPU: %s\n", timestamp, message)
+	Logf(PPU, LogLevelTrace, format, args...)
 }
 
 // LogAPU logs APU operations
 func LogAPU(format string, args ...interface{}) {
-	if globalLogger != nil && globalLogger.apuEnabled && globalLogger.level >= LogLevelDebug {
-		timestamp := time.Now().Format("15:04:05.000")
-		message := fmt.Sprintf(format, args...)
-		fmt.Fprintf(globalLogger.writer, "[%s] APU: %s\n", timestamp, message)
-	}
+	Logf(APU, LogLevelDebug, format, args...)
 }
 
 // LogMapper logs mapper operations
 func LogMapper(format string, args ...interface{}) {
-	if globalLogger != nil && globalLogger.mapperEnabled && globalLogger.level >= LogLevelDebug {
-		timestamp := time.Now().Format("15:04:05.000")
-		message := fmt.Sprintf(format, args...)
-		fmt.Fprintf(globalLogger.writer, "[%s] MAPPER: %s\n", timestamp, message)
-	}
+	Logf(Mapper, LogLevelDebug, format, args...)
+}
+
+// LogBus logs CPU bus accesses
+func LogBus(format string, args ...interface{}) {
+	Logf(Bus, LogLevelDebug, format, args...)
 }
 
 // LogInfo logs general information
 func LogInfo(format string, args ...interface{}) {
-	if globalLogger != nil && globalLogger.level >= LogLevelInfo {
-		timestamp := time.Now().Format("15:04:05.000")
-		message := fmt.Sprintf(format, args...)
-		fmt.Fprintf(globalLogger.writer, "[%s] INFO: %s\n", timestamp, message)
-	}
+	Logf(General, LogLevelInfo, format, args...)
+}
+
+// LogWarn logs warnings
+func LogWarn(format string, args ...interface{}) {
+	Logf(General, LogLevelWarn, format, args...)
 }
 
 // LogError logs errors
 func LogError(format string, args ...interface{}) {
-	if globalLogger != nil && globalLogger.level >= LogLevelError {
-		timestamp := time.Now().Format("15:04:05.000")
-		message := fmt.Sprintf(format, args...)
-		fmt.Fprintf(globalLogger.writer, "[%s] ERROR: %s\n", timestamp, message)
-	}
+	Logf(General, LogLevelError, format, args...)
 }
 
 // LogDebug logs debug information
 func LogDebug(format string, args ...interface{}) {
-	if globalLogger != nil && globalLogger.level >= LogLevelDebug {
-		timestamp := time.Now().Format("15:04:05.000")
-		message := fmt.Sprintf(format, args...)
-		fmt.Fprintf(globalLogger.writer, "[%s] DEBUG: %s\n", timestamp, message)
+	Logf(General, LogLevelDebug, format, args...)
+}
+
+// emit stores e in the ring and writes it out.
+func (l *Logger) emit(c Component, level LogLevel, msg string) {
+	e := Entry{Time: time.Now(), Level: level, Component: c, Message: msg}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.ring) > 0 {
+		l.ring[l.ringNext] = e
+		l.ringNext++
+		if l.ringNext == len(l.ring) {
+			l.ringNext = 0
+			l.ringFull = true
+		}
+	}
+	writeEntry(l.writer, l.format, e)
+}
+
+// tag is the text-format label: the level for general entries, the
+// component otherwise.
+func (e Entry) tag() string {
+	if e.Component == General {
+		return strings.ToUpper(e.Level.String())
+	}
+	return strings.ToUpper(e.Component.String())
+}
+
+// jsonEntry is Entry's JSON wire form.
+type jsonEntry struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Component string `json:"component"`
+	Message   string `json:"msg"`
+}
+
+func writeEntry(w io.Writer, f Format, e Entry) {
+	if f == FormatJSON {
+		line, _ := json.Marshal(jsonEntry{
+			Time:      e.Time.Format(time.RFC3339Nano),
+			Level:     e.Level.String(),
+			Component: e.Component.String(),
+			Message:   e.Message,
+		})
+		w.Write(append(line, '\n'))
+		return
+	}
+	fmt.Fprintf(w, "[%s] %s: %s\n", e.Time.Format("15:04:05.000"), e.tag(), e.Message)
+}
+
+// Recent returns the entries held in the ring, oldest first.
+func (l *Logger) Recent() []Entry {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.ringFull {
+		return append([]Entry(nil), l.ring[:l.ringNext]...)
 	}
+	out := make([]Entry, 0, len(l.ring))
+	out = append(out, l.ring[l.ringNext:]...)
+	return append(out, l.ring[:l.ringNext]...)
+}
+
+// Recent returns the global logger's ring, oldest first.
+func Recent() []Entry { return globalLogger.Recent() }
+
+// DumpRing writes the ring's entries to w, oldest first, in the logger's
+// output format.
+func (l *Logger) DumpRing(w io.Writer) {
+	if l == nil {
+		return
+	}
+	entries := l.Recent()
+	if l.format == FormatText {
+		fmt.Fprintf(w, "--- last %d log entries ---\n", len(entries))
+	}
+	for _, e := range entries {
+		writeEntry(w, l.format, e)
+	}
+}
+
+// DumpRing writes the global logger's ring to w.
+func DumpRing(w io.Writer) { globalLogger.DumpRing(w) }
+
+// panicOutput is where DumpOnPanic writes; a variable so tests can capture
+// it.
+var panicOutput io.Writer = os.Stderr
+
+// DumpOnPanic is deferred at the top of each long-lived goroutine: if the
+// goroutine is panicking it dumps the ring to stderr — the entries leading
+// up to a crash are usually the useful ones, and with a log file or a high
+// threshold they'd otherwise be lost — then lets the panic continue.
+func DumpOnPanic() {
+	if r := recover(); r != nil {
+		DumpRing(panicOutput)
+		panic(r)
+	}
+}
+
+// parseLevel is GetLogLevelFromString without the fallback.
+func parseLevel(s string) (LogLevel, bool) {
+	for i, n := range levelNames {
+		if n == s {
+			return LogLevel(i), true
+		}
+	}
+	return LogLevelOff, false
 }
 
 // GetLogLevelFromString converts string to LogLevel
 func GetLogLevelFromString(level string) LogLevel {
-	switch level {
-	case "off":
-		return LogLevelOff
-	case "error":
-		return LogLevelError
-	case "warn":
-		return LogLevelWarn
-	case "info":
-		return LogLevelInfo
-	case "debug":
-		return LogLevelDebug
-	case "trace":
-		return LogLevelTrace
-	default:
-		return LogLevelInfo
+	if l, ok := parseLevel(level); ok {
+		return l
 	}
+	return LogLevelInfo
 }
 
 // Close closes the logger and any associated files
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -159,3 +160,132 @@ func TestInitializeBadPath(t *testing.T) {
 		t.Error("Initialize with unwritable path should error")
 	}
 }
+
+func TestComponentLevels(t *testing.T) {
+	buf := withBuffer(t, LogLevelInfo)
+	if err := ParseLevels("ppu=trace, bus=debug"); err != nil {
+		t.Fatalf("ParseLevels: %v", err)
+	}
+	if Level(PPU) != LogLevelTrace || Level(Bus) != LogLevelDebug || Level(APU) != LogLevelOff {
+		t.Errorf("levels ppu=%v bus=%v apu=%v", Level(PPU), Level(Bus), Level(APU))
+	}
+	if !PPUEnabled() || !BusEnabled() || CPUEnabled() {
+		t.Error("gates don't follow the per-component levels")
+	}
+	LogBus("bus %d", 1)
+	if !strings.Contains(buf.String(), "BUS: bus 1") {
+		t.Errorf("bus entry missing: %q", buf.String())
+	}
+
+	for _, bad := range []string{"ppu", "gpu=trace", "ppu=loud"} {
+		if err := ParseLevels(bad); err == nil {
+			t.Errorf("ParseLevels(%q) accepted", bad)
+		}
+	}
+}
+
+func TestLogFuncIsLazy(t *testing.T) {
+	withBuffer(t, LogLevelInfo)
+	called := false
+	LogFunc(APU, LogLevelDebug, func() string { called = true; return "x" })
+	if called {
+		t.Error("LogFunc built the message for a disabled component")
+	}
+	SetLevel(APU, LogLevelDebug)
+	LogFunc(APU, LogLevelDebug, func() string { called = true; return "x" })
+	if !called {
+		t.Error("LogFunc skipped an enabled component")
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	withBuffer(t, LogLevelInfo)
+	SetRingSize(3)
+	for i := 1; i <= 5; i++ {
+		LogInfo("entry %d", i)
+	}
+	got := Recent()
+	if len(got) != 3 || got[0].Message != "entry 3" || got[2].Message != "entry 5" {
+		t.Fatalf("Recent = %+v, want entries 3..5", got)
+	}
+	if got[0].Component != General || got[0].Level != LogLevelInfo {
+		t.Errorf("entry fields = %v/%v", got[0].Component, got[0].Level)
+	}
+
+	var dump bytes.Buffer
+	DumpRing(&dump)
+	if !strings.Contains(dump.String(), "last 3 log entries") || !strings.Contains(dump.String(), "INFO: entry 5") {
+		t.Errorf("DumpRing output: %q", dump.String())
+	}
+
+	SetRingSize(0)
+	LogInfo("dropped")
+	if len(Recent()) != 0 {
+		t.Error("ring of size 0 kept entries")
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	buf := withBuffer(t, LogLevelTrace)
+	SetFormat(FormatJSON)
+	SetPPULogging(true)
+	LogPPU("scanline %d", 241)
+
+	var e struct {
+		Time, Level, Component, Msg string
+	}
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("not a JSON line: %q (%v)", buf.String(), err)
+	}
+	if e.Level != "trace" || e.Component != "ppu" || e.Msg != "scanline 241" || e.Time == "" {
+		t.Errorf("decoded %+v", e)
+	}
+}
+
+func TestDumpOnPanicRepanics(t *testing.T) {
+	withBuffer(t, LogLevelInfo)
+	LogInfo("before the crash")
+	var dump bytes.Buffer
+	panicOutput = &dump
+	defer func() {
+		panicOutput = os.Stderr
+		if r := recover(); r != "boom" {
+			t.Errorf("recovered %v, want the original panic", r)
+		}
+		if !strings.Contains(dump.String(), "before the crash") {
+			t.Errorf("ring not dumped: %q", dump.String())
+		}
+	}()
+	func() {
+		defer DumpOnPanic()
+		panic("boom")
+	}()
+}
+
+func TestNewIsIndependent(t *testing.T) {
+	global := withBuffer(t, LogLevelInfo)
+	var own bytes.Buffer
+	l := New(LogLevelDebug, &own)
+	l.SetLevel(PPU, LogLevelTrace)
+
+	l.Logf(PPU, LogLevelTrace, "scanline %d", 0)
+	LogInfo("global")
+	if !strings.Contains(own.String(), "PPU: scanline 0") || strings.Contains(own.String(), "global") {
+		t.Errorf("instance output: %q", own.String())
+	}
+	if strings.Contains(global.String(), "scanline") {
+		t.Errorf("instance entry reached the global logger: %q", global.String())
+	}
+	if PPUEnabled() || Level(PPU) != LogLevelOff {
+		t.Error("SetLevel on an instance changed the global thresholds")
+	}
+	if got := l.Recent(); len(got) != 1 || got[0].Message != "scanline 0" {
+		t.Errorf("instance ring = %+v", got)
+	}
+
+	var nilLogger *Logger
+	nilLogger.Logf(General, LogLevelError, "x") // must not panic
+	if nilLogger.Enabled(General, LogLevelError) || nilLogger.Recent() != nil {
+		t.Error("nil logger should keep nothing")
+	}
+}
@@ -0,0 +1,150 @@
+package memory
+
+// The CPU address space is a stack of Regions, each answering a range of
+// addresses. New lays down the console's map (see nesMap); a machine that
+// wires its bus differently — a disk system's RAM adapter, an arcade
+// board's extra ports, a test harness's RAM where the cartridge goes —
+// Maps its own regions on top, and accesses go to whichever region was
+// mapped last over an address.
+//
+// Mirroring is a property of the region, not of its handler: a region
+// with a Mirror size folds every address onto its first copy before the
+// handler sees it, so no handler has to mask addresses itself and no two
+// mirrors of a register can disagree.
+//
+// The cartridge slot is a region like the others: until SetCartridge maps
+// one, $6000-$FFFF is HighMem.
+//
+// Like hooks.go, lookups go through a 256-entry page table: a page one
+// region covers whole points straight at it, and only pages split between
+// regions ($40xx on the console) search the stack.
+
+// ReadFunc answers a read of addr, already folded onto the region's first
+// mirror. It returns the byte and the data lines the device drives; the
+// lines it leaves undriven read as open bus.
+type ReadFunc func(addr uint16) (value, driven uint8)
+
+// WriteFunc takes a write of value to addr, already folded onto the
+// region's first mirror.
+type WriteFunc func(addr uint16, value uint8)
+
+// Region is one device on the CPU bus.
+type Region struct {
+	// Name identifies the region in debug output and tests.
+	Name string
+
+	// Start and End are the first and last address of the region.
+	Start, End uint16
+
+	// Mirror is the size of the block repeated through Start-End, a power
+	// of two; 0 means the region isn't mirrored.
+	Mirror uint16
+
+	// Mem, when set, backs the region directly: reads and writes go to
+	// Mem[addr-Start] (after folding) with no handler call. Read and
+	// Write are ignored.
+	Mem []uint8
+
+	// Read answers reads; nil leaves the whole bus open.
+	Read ReadFunc
+	// Write takes writes; nil drops them.
+	Write WriteFunc
+
+	// Internal marks a device inside the CPU package: its reads never
+	// reach the external data bus, so they don't update the open-bus
+	// latch.
+	Internal bool
+
+	// IO marks device registers, where a read has side effects. Peek,
+	// which must not disturb anything, returns 0 for them.
+	IO bool
+
+	mask uint16
+}
+
+// fold maps addr onto the region's first mirror.
+func (r *Region) fold(addr uint16) uint16 {
+	if r.mask == 0 {
+		return addr
+	}
+	return r.Start + (addr-r.Start)&r.mask
+}
+
+func (r *Region) contains(addr uint16) bool {
+	return addr >= r.Start && addr <= r.End
+}
+
+// openBus answers addresses no region covers.
+var openBus = &Region{Name: "open bus"}
+
+// Map adds r to the bus on top of the regions already there: from now on
+// addresses in r's range go to r. It panics on an empty range or a Mirror
+// that isn't a power of two, both wiring mistakes.
+func (m *Memory) Map(r Region) {
+	if r.End < r.Start {
+		panic("memory: region " + r.Name + " ends before it starts")
+	}
+	if r.Mirror != 0 {
+		if r.Mirror&(r.Mirror-1) != 0 {
+			panic("memory: region " + r.Name + " has a mirror size that isn't a power of two")
+		}
+		r.mask = r.Mirror - 1
+	}
+	m.regions = append(m.regions, &r)
+	m.rebuildPages()
+}
+
+// Unmap removes every region mapped under name, the console's own
+// included, uncovering whatever lies beneath. A machine swapping a
+// device out (a new cartridge and the ports it brings) unmaps the old
+// one's regions before mapping the new.
+func (m *Memory) Unmap(name string) {
+	kept := m.regions[:0]
+	for _, r := range m.regions {
+		if r.Name != name {
+			kept = append(kept, r)
+		}
+	}
+	clear(m.regions[len(kept):])
+	m.regions = kept
+	m.rebuildPages()
+}
+
+// RegionAt returns the region that answers accesses to addr.
+func (m *Memory) RegionAt(addr uint16) Region {
+	return *m.region(addr)
+}
+
+// rebuildPages points each page at the topmost region touching it when
+// that region covers the whole page, and at nil (search) otherwise.
+func (m *Memory) rebuildPages() {
+	for p := range m.pages {
+		lo, hi := uint16(p)<<8, uint16(p)<<8|0xFF
+		m.pages[p] = openBus
+		for i := len(m.regions) - 1; i >= 0; i-- {
+			r := m.regions[i]
+			if r.End < lo || r.Start > hi {
+				continue
+			}
+			if r.Start <= lo && r.End >= hi {
+				m.pages[p] = r
+			} else {
+				m.pages[p] = nil
+			}
+			break
+		}
+	}
+}
+
+// region finds the region answering addr.
+func (m *Memory) region(addr uint16) *Region {
+	if r := m.pages[addr>>8]; r != nil {
+		return r
+	}
+	for i := len(m.regions) - 1; i >= 0; i-- {
+		if r := m.regions[i]; r.contains(addr) {
+			return r
+		}
+	}
+	return openBus
+}
@@ -0,0 +1,118 @@
+package memory
+
+import "testing"
+
+func TestRegionAt(t *testing.T) {
+	m := New()
+	for _, tc := range []struct {
+		addr uint16
+		name string
+	}{
+		{0x0000, "RAM"}, {0x1FFF, "RAM"}, {0x2000, "PPU"}, {0x3FFF, "PPU"},
+		{0x4000, "APU"}, {0x4013, "APU"}, {0x4014, "OAM DMA"}, {0x4015, "APU status"},
+		{0x4016, "port 0"}, {0x4017, "port 1"}, {0x401F, "test"},
+		{0x4020, "expansion"}, {0x5FFF, "expansion"}, {0x6000, "cartridge"}, {0xFFFF, "cartridge"},
+	} {
+		if got := m.RegionAt(tc.addr).Name; got != tc.name {
+			t.Errorf("RegionAt($%04X) = %q, want %q", tc.addr, got, tc.name)
+		}
+	}
+}
+
+func TestMapOverlay(t *testing.T) {
+	m := New()
+	m.SetCartridge(&gatedCart{ramOn: true})
+
+	// A test harness putting plain RAM where the cartridge goes: the
+	// region shadows the cartridge's, mirrors included.
+	var ram [0x2000]uint8
+	m.Map(Region{Name: "test RAM", Start: 0x6000, End: 0xFFFF, Mirror: 0x2000, Mem: ram[:]})
+	m.Write(0xE123, 0x77)
+	if ram[0x0123] != 0x77 {
+		t.Errorf("write to $E123 landed at %#02x, want RAM $0123", ram[0x0123])
+	}
+	if got := m.Read(0x6123); got != 0x77 {
+		t.Errorf("Read($6123) = %#02x, want the mirror's 0x77", got)
+	}
+
+	// A region splitting a page with the one below: $4030-$403F answers,
+	// the rest of $40xx stays as it was.
+	var written []uint16
+	m.Map(Region{
+		Name: "disk", Start: 0x4030, End: 0x403F, IO: true,
+		Read:  func(addr uint16) (uint8, uint8) { return uint8(addr), 0xFF },
+		Write: func(addr uint16, _ uint8) { written = append(written, addr) },
+	})
+	if got := m.Read(0x4032); got != 0x32 {
+		t.Errorf("Read($4032) = %#02x, want 0x32", got)
+	}
+	if got := m.RegionAt(0x402F).Name; got != "expansion" {
+		t.Errorf("$402F is answered by %q, want expansion", got)
+	}
+	if got := m.RegionAt(0x4015).Name; got != "APU status" {
+		t.Errorf("$4015 is answered by %q, want APU status", got)
+	}
+	m.Write(0x403F, 0)
+	m.Write(0x4040, 0)
+	if len(written) != 1 || written[0] != 0x403F {
+		t.Errorf("disk writes = %X, want [403F]", written)
+	}
+	if got := m.Peek(0x4032); got != 0 {
+		t.Errorf("Peek of an I/O region = %#02x, want 0", got)
+	}
+}
+
+func TestMapRejectsBadRegions(t *testing.T) {
+	for _, r := range []Region{
+		{Name: "backwards", Start: 0x5000, End: 0x4FFF},
+		{Name: "odd mirror", Start: 0x5000, End: 0x5FFF, Mirror: 0x300},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Map(%s) didn't panic", r.Name)
+				}
+			}()
+			New().Map(r)
+		}()
+	}
+}
+
+// regPPU records the register addresses the bus hands the PPU.
+type regPPU struct{ reads, writes []uint16 }
+
+func (p *regPPU) ReadRegister(addr uint16) uint8 {
+	p.reads = append(p.reads, addr)
+	return uint8(addr)
+}
+
+func (p *regPPU) WriteRegister(addr uint16, value uint8) {
+	p.writes = append(p.writes, addr)
+}
+
+func TestPPURegisterMirrors(t *testing.T) {
+	// Every address in $2000-$3FFF is one of the eight registers: games
+	// write $3456 for $2006 and expect it to land.
+	m := New()
+	ppu := &regPPU{}
+	m.SetPPU(ppu)
+	for addr := 0x2000; addr <= 0x3FFF; addr++ {
+		want := uint16(0x2000 | addr&7)
+		if got := m.Read(uint16(addr)); got != uint8(want) {
+			t.Fatalf("Read($%04X) = %#02x, want register $%04X", addr, got, want)
+		}
+		m.Write(uint16(addr), 0)
+		if r, w := ppu.reads[len(ppu.reads)-1], ppu.writes[len(ppu.writes)-1]; r != want || w != want {
+			t.Fatalf("$%04X reached the PPU as read $%04X, write $%04X; want $%04X", addr, r, w, want)
+		}
+	}
+	if len(ppu.reads) != 0x2000 || len(ppu.writes) != 0x2000 {
+		t.Errorf("PPU saw %d reads, %d writes; want one of each per address", len(ppu.reads), len(ppu.writes))
+	}
+	ppu.writes = nil
+	m.Write(0x4000, 0)
+	m.Write(0x1FFF, 0)
+	if ppu.writes != nil {
+		t.Errorf("writes outside $2000-$3FFF reached the PPU: %X", ppu.writes)
+	}
+}
@@ -0,0 +1,96 @@
+package memory
+
+// Bus hooks let debugger watchpoints, trace tools and similar observers
+// see (and optionally rewrite) CPU accesses without special-casing them in
+// read/Write. Hooks are matched by address range; a 256-entry page table
+// per direction records which pages have any hook at all, so an access to
+// an unhooked page — every access, when no hooks are installed — costs a
+// single array load.
+
+// Hook observes one CPU bus access. For a read, value is what the CPU is
+// about to receive (after cheat patching); for a write, the byte being
+// stored. The return value replaces it — return value unchanged to only
+// watch. Hooks run in registration order, each seeing its predecessor's
+// result.
+//
+// A hook must not call Read or Write for an address inside its own range;
+// that would recurse into itself.
+type Hook func(addr uint16, value uint8) uint8
+
+// HookID identifies an installed hook for RemoveHook.
+type HookID int
+
+type hook struct {
+	id         HookID
+	start, end uint16
+	fn         Hook
+}
+
+// hookTable is the set of hooks for one access direction.
+type hookTable struct {
+	hooks []hook
+	pages [256]bool // pages[addr>>8]: some hook's range touches this page
+}
+
+func (t *hookTable) add(h hook) {
+	t.hooks = append(t.hooks, h)
+	t.rebuild()
+}
+
+func (t *hookTable) remove(id HookID) bool {
+	for i, h := range t.hooks {
+		if h.id == id {
+			t.hooks = append(t.hooks[:i], t.hooks[i+1:]...)
+			t.rebuild()
+			return true
+		}
+	}
+	return false
+}
+
+func (t *hookTable) rebuild() {
+	t.pages = [256]bool{}
+	for _, h := range t.hooks {
+		for p := int(h.start >> 8); p <= int(h.end>>8); p++ {
+			t.pages[p] = true
+		}
+	}
+}
+
+func (t *hookTable) run(addr uint16, value uint8) uint8 {
+	for _, h := range t.hooks {
+		if addr >= h.start && addr <= h.end {
+			value = h.fn(addr, value)
+		}
+	}
+	return value
+}
+
+// AddReadHook installs fn for CPU reads of start..end (inclusive) and
+// returns an ID for RemoveHook.
+func (m *Memory) AddReadHook(start, end uint16, fn Hook) HookID {
+	return m.addHook(&m.readHooks, start, end, fn)
+}
+
+// AddWriteHook installs fn for CPU writes to start..end (inclusive). The
+// hook runs before the write reaches RAM or a device, so its return value
+// is what actually gets stored.
+func (m *Memory) AddWriteHook(start, end uint16, fn Hook) HookID {
+	return m.addHook(&m.writeHooks, start, end, fn)
+}
+
+func (m *Memory) addHook(t *hookTable, start, end uint16, fn Hook) HookID {
+	if end < start {
+		start, end = end, start
+	}
+	m.nextHookID++
+	t.add(hook{id: m.nextHookID, start: start, end: end, fn: fn})
+	return m.nextHookID
+}
+
+// RemoveHook uninstalls a read or write hook. Unknown IDs are ignored.
+func (m *Memory) RemoveHook(id HookID) {
+	if !m.readHooks.remove(id) {
+		m.writeHooks.remove(id)
+	}
+}
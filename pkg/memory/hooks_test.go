@@ -0,0 +1,100 @@
+package memory
+
+import "testing"
+
+func TestReadHookRangeAndOverride(t *testing.T) {
+	m := New()
+	m.RAM[0x10] = 0x11
+	m.RAM[0x20] = 0x22
+
+	var seen []uint16
+	id := m.AddReadHook(0x0010, 0x001F, func(addr uint16, v uint8) uint8 {
+		seen = append(seen, addr)
+		return v + 1
+	})
+
+	if got := m.Read(0x0010); got != 0x12 {
+		t.Errorf("hooked read = %#02x, want 0x12", got)
+	}
+	if got := m.Read(0x0020); got != 0x22 {
+		t.Errorf("read outside range = %#02x, want 0x22 (unhooked)", got)
+	}
+	if len(seen) != 1 || seen[0] != 0x0010 {
+		t.Errorf("hook saw %v, want [0x0010]", seen)
+	}
+
+	m.RemoveHook(id)
+	if got := m.Read(0x0010); got != 0x11 {
+		t.Errorf("read after RemoveHook = %#02x, want 0x11", got)
+	}
+	if m.readHooks.pages[0] {
+		t.Error("page table not cleared after removing the last hook")
+	}
+}
+
+func TestHooksChainInOrder(t *testing.T) {
+	m := New()
+	m.RAM[0] = 1
+	m.AddReadHook(0, 0, func(_ uint16, v uint8) uint8 { return v * 2 })
+	m.AddReadHook(0, 0, func(_ uint16, v uint8) uint8 { return v + 3 })
+	if got := m.Read(0); got != 5 {
+		t.Errorf("chained hooks = %d, want (1*2)+3 = 5", got)
+	}
+}
+
+// TestReadHookSeesCheats: the hook observes the value the CPU will actually
+// get, i.e. after Game Genie / RAM patches.
+func TestReadHookSeesCheats(t *testing.T) {
+	m := New()
+	m.Cheats = patchAll(0x99)
+	var got uint8
+	m.AddReadHook(0x0000, 0x07FF, func(_ uint16, v uint8) uint8 { got = v; return v })
+	m.Read(0x0005)
+	if got != 0x99 {
+		t.Errorf("hook saw %#02x, want the patched 0x99", got)
+	}
+}
+
+type patchAll uint8
+
+func (p patchAll) Apply(uint16, uint8) uint8 { return uint8(p) }
+
+func TestWriteHookRewritesStore(t *testing.T) {
+	m := New()
+	var watched []uint8
+	m.AddWriteHook(0x0300, 0x0300, func(_ uint16, v uint8) uint8 {
+		watched = append(watched, v)
+		return v &^ 0x0F
+	})
+	m.Write(0x0300, 0xAB)
+	m.Write(0x0301, 0xCD)
+	if m.RAM[0x300] != 0xA0 {
+		t.Errorf("hooked write stored %#02x, want 0xA0", m.RAM[0x300])
+	}
+	if m.RAM[0x301] != 0xCD {
+		t.Errorf("unhooked write stored %#02x, want 0xCD", m.RAM[0x301])
+	}
+	if len(watched) != 1 || watched[0] != 0xAB {
+		t.Errorf("write hook saw %v, want [0xAB]", watched)
+	}
+}
+
+func TestHookRangeAcrossPages(t *testing.T) {
+	m := New()
+	// Reversed bounds are normalised.
+	id := m.AddWriteHook(0x02FF, 0x00F0, func(_ uint16, v uint8) uint8 { return v })
+	for p := 0; p <= 2; p++ {
+		if !m.writeHooks.pages[p] {
+			t.Errorf("page %d should be marked", p)
+		}
+	}
+	if m.writeHooks.pages[3] {
+		t.Error("page 3 should not be marked")
+	}
+	// Removing via the wrong table is harmless; the write hook goes away.
+	m.RemoveHook(id)
+	m.RemoveHook(12345)
+	if len(m.writeHooks.hooks) != 0 {
+		t.Error("write hook not removed")
+	}
+}
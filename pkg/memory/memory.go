@@ -21,21 +21,37 @@ type APUBus interface {
 }
 
 // CartridgeBus is the subset of the cartridge that the memory bus needs:
-// PRG read/write at $6000-$FFFF (plus $4020-$5FFF for mappers that
-// HaveExpansion). CHR access goes through the PPU, not here.
+// PRG read/write at $6000-$FFFF. A board that also decodes $4020-$5FFF
+// is given that range with Map by whoever inserts it. CHR access goes
+// through the PPU, not here.
 type CartridgeBus interface {
 	ReadPRG(addr uint16) uint8
 	WritePRG(addr uint16, value uint8)
-	HasExpansion() bool
 }
 
-// InputBus is the subset of the controller used by the memory bus:
-// strobe write to $4016 and serial read.
+// InputBus is the controller-port side of the memory bus: the shared
+// $4016 OUT-latch write, and serial reads of port 0 ($4016) or port 1
+// ($4017) returning the data lines D0-D4.
 type InputBus interface {
-	Read() uint8
+	Read(port int) uint8
 	Write(value uint8)
 }
 
+// PRGRAMGate is optionally implemented by the cartridge when its mapper
+// can switch the $6000-$7FFF PRG RAM off (MMC1, MMC3). A disabled chip
+// doesn't drive the data bus, so the read sees CPU open bus.
+type PRGRAMGate interface {
+	PRGRAMEnabled() bool
+}
+
+// PRGBankSource is optionally implemented by the cartridge to expose its
+// mapper's $8000-$FFFF mapping as four live 8KB PRG ROM slices. Reads
+// through the table skip the ReadPRG call entirely — PRG fetches are the
+// bulk of CPU bus traffic. A nil table or window falls back to ReadPRG.
+type PRGBankSource interface {
+	PRGBanks() *[4][]uint8
+}
+
 // CheatPatcher is an optional read-time byte patcher. Implementations
 // (e.g. Game Genie / PAR) get the address and the value the underlying
 // region returned, and may override it.
@@ -48,8 +64,9 @@ type Memory struct {
 	// CPU RAM (2KB, mirrored to fill 8KB)
 	RAM [2048]uint8
 
-	// Test memory for high addresses (for testing purposes)
-	HighMem [0xA000]uint8 // 0x6000-0xFFFF
+	// HighMem is what $6000-$FFFF reads and writes while no cartridge
+	// is in: plain RAM that CPU tests put their code and vectors in.
+	HighMem [0xA000]uint8
 
 	PPU       PPUBus
 	APU       APUBus
@@ -70,15 +87,74 @@ type Memory struct {
 	// the open-bus value (the high byte of the preceding JMP) decoding as
 	// RTI; without proper tracking the CPU fetches $00=BRK and crashes.
 	cpuBus uint8
+
+	// prgRAMGate caches the cartridge's optional PRGRAMGate assertion;
+	// nil when the cartridge can't disable its PRG RAM.
+	prgRAMGate PRGRAMGate
+
+	// prgBanks is the cartridge's PRGBankSource table, cached at
+	// SetCartridge; nil means every PRG read goes through ReadPRG.
+	prgBanks *[4][]uint8
+
+	// regions is the bus map, bottom first, and pages its lookup table
+	// (see bus.go).
+	regions []*Region
+	pages   [256]*Region
+
+	// stall is the CPU stall the write being handled costs; see Write.
+	stall int
+
+	// readHooks / writeHooks hold the bus hooks installed through
+	// AddReadHook / AddWriteHook (see hooks.go).
+	readHooks  hookTable
+	writeHooks hookTable
+	nextHookID HookID
 }
 
-// New creates a new Memory instance
+// New creates a new Memory instance wired as the console's bus.
 func New() *Memory {
-	return &Memory{}
+	m := &Memory{}
+	for _, r := range m.nesMap() {
+		m.Map(r)
+	}
+	return m
 }
 
-// SetCartridge sets the cartridge reference
-func (m *Memory) SetCartridge(cart CartridgeBus) { m.Cartridge = cart }
+// nesMap is the console's CPU memory map. The handlers reach the devices
+// through m, so SetPPU and friends rewire them without remapping.
+func (m *Memory) nesMap() []Region {
+	return []Region{
+		{Name: "RAM", Start: 0x0000, End: 0x1FFF, Mirror: 0x800, Mem: m.RAM[:]},
+		{Name: "PPU", Start: 0x2000, End: 0x3FFF, Mirror: 8, Read: m.readPPU, Write: m.writePPU, IO: true},
+		// $4000-$4013 are write-only APU ports, $4018-$401F the
+		// disabled CPU-test registers; reads of both are open bus.
+		{Name: "APU", Start: 0x4000, End: 0x4013, Write: m.writeAPU, IO: true},
+		{Name: "OAM DMA", Start: 0x4014, End: 0x4014, Write: m.writeOAMDMA, IO: true},
+		{Name: "APU status", Start: 0x4015, End: 0x4015, Read: m.readAPUStatus, Write: m.writeAPU, Internal: true, IO: true},
+		{Name: "port 0", Start: 0x4016, End: 0x4016, Read: m.readInput, Write: m.writeOUT, IO: true},
+		{Name: "port 1", Start: 0x4017, End: 0x4017, Read: m.readInput, Write: m.writeAPU, IO: true},
+		{Name: "test", Start: 0x4018, End: 0x401F, Write: m.writeAPU, IO: true},
+		// $4020-$5FFF is open bus on the console: boards that decode it
+		// (MMC5, the Disk System, ...) are mapped over it with their
+		// cartridge. Left undriven, it keeps the $40xx latching the
+		// cpu_exec_space test ROM relies on for its JMP into APU space.
+		{Name: "expansion", Start: 0x4020, End: 0x5FFF, IO: true},
+		{Name: "cartridge", Start: 0x6000, End: 0xFFFF, Mem: m.HighMem[:]},
+	}
+}
+
+// SetCartridge puts cart in the $6000-$FFFF slot, in place of HighMem or
+// the cartridge before it.
+func (m *Memory) SetCartridge(cart CartridgeBus) {
+	m.Cartridge = cart
+	m.prgRAMGate, _ = cart.(PRGRAMGate)
+	m.prgBanks = nil
+	if src, ok := cart.(PRGBankSource); ok {
+		m.prgBanks = src.PRGBanks()
+	}
+	m.Unmap("cartridge")
+	m.Map(Region{Name: "cartridge", Start: 0x6000, End: 0xFFFF, Read: m.readCartridge, Write: m.writeCartridge})
+}
 
 // SetPPU sets the PPU reference
 func (m *Memory) SetPPU(ppu PPUBus) { m.PPU = ppu }
@@ -90,83 +166,58 @@ func (m *Memory) SetAPU(apu APUBus) { m.APU = apu }
 func (m *Memory) SetInput(input InputBus) { m.Input = input }
 
 // Read reads a byte from the given address. The cheat patcher (when set)
-// gets the last word so it can overlay Game Genie / RAM cheats on top of
-// whatever the underlying region returned.
+// overlays Game Genie / RAM cheats on top of whatever the underlying region
+// returned, and read hooks covering addr see the patched value last.
 func (m *Memory) Read(addr uint16) uint8 {
 	v := m.read(addr)
 	if m.Cheats != nil {
-		return m.Cheats.Apply(addr, v)
+		v = m.Cheats.Apply(addr, v)
+	}
+	if m.readHooks.pages[addr>>8] {
+		v = m.readHooks.run(addr, v)
 	}
 	return v
 }
 
-// read is the unpatched memory read. Every successful read latches into
-// cpuBus; addresses that don't drive the bus (write-only APU ports,
-// $4018-$401F, unmapped cartridge space) return the previous latched
-// value instead of zero.
-func (m *Memory) read(addr uint16) uint8 {
-	if addr < 0x2000 {
-		v := m.RAM[addr&0x7FF]
-		m.cpuBus = v
-		return v
+// Peek returns the byte at addr without touching the bus, for watchers
+// and debuggers looking at memory between frames: memory regions as the
+// CPU would see them (cheats and hooks left out). I/O regions, where a
+// read has side effects, always return 0.
+func (m *Memory) Peek(addr uint16) uint8 {
+	if m.region(addr).IO {
+		return 0
 	}
+	latched := m.cpuBus
+	v := m.read(addr)
+	m.cpuBus = latched
+	return v
+}
 
-	if addr >= 0x6000 {
-		if m.Cartridge != nil {
-			v := m.Cartridge.ReadPRG(addr)
-			m.cpuBus = v
-			return v
-		}
-		v := m.HighMem[addr-0x6000]
+// read is the unpatched memory read. Every read latches what the CPU
+// saw into cpuBus, except reads of Internal regions; lines the region
+// doesn't drive (write-only APU ports, $4018-$401F, unmapped cartridge
+// space, disabled PRG RAM, bits 5-7 of the controller ports) return the
+// previous latched value instead of zero.
+func (m *Memory) read(addr uint16) uint8 {
+	r := m.pages[addr>>8]
+	if r == nil {
+		r = m.region(addr)
+	}
+	addr = r.fold(addr)
+	if r.Mem != nil {
+		v := r.Mem[addr-r.Start]
 		m.cpuBus = v
 		return v
 	}
-
-	if addr < 0x4000 {
-		if m.PPU != nil {
-			v := m.PPU.ReadRegister(0x2000 + (addr & 0x7))
-			m.cpuBus = v
-			return v
-		}
-		return m.cpuBus
-	}
-
-	if addr == 0x4015 {
-		if m.APU != nil {
-			v := m.APU.ReadRegister(addr)
-			m.cpuBus = v
-			return v
-		}
-		return m.cpuBus
-	}
-
-	if addr == 0x4016 {
-		// Bit 0 from controller; bits 1-7 are open bus on real hardware.
-		if m.Input != nil {
-			v := (m.cpuBus & 0xFE) | (m.Input.Read() & 0x01)
-			m.cpuBus = v
-			return v
-		}
+	if r.Read == nil {
 		return m.cpuBus
 	}
-
-	// $4000-$4014 are write-only APU ports, $4017 read is player-2 controller
-	// (not modelled yet), $4018-$401F is CPU-test/unallocated.
-	if addr < 0x4020 {
-		return m.cpuBus
-	}
-
-	// $4020-$5FFF is cartridge expansion. Only mappers that opt in
-	// (mapper.ExpansionDecoder — currently MMC5) decode this range;
-	// everything else leaves it as open bus so the cpu_exec_space
-	// test ROM can keep relying on $40xx latching for its
-	// JMP-into-APU-space round-trip.
-	if m.Cartridge != nil && m.Cartridge.HasExpansion() {
-		v := m.Cartridge.ReadPRG(addr)
+	v, driven := r.Read(addr)
+	v = (m.cpuBus &^ driven) | (v & driven)
+	if !r.Internal {
 		m.cpuBus = v
-		return v
 	}
-	return m.cpuBus
+	return v
 }
 
 // oamDMAStallCycles is the cost an OAM DMA adds to the CPU on top of the
@@ -179,54 +230,105 @@ const oamDMAStallCycles = 513
 // count — non-zero only for OAM DMA at $4014. Other callers may safely
 // ignore the return.
 func (m *Memory) Write(addr uint16, value uint8) int {
+	if m.writeHooks.pages[addr>>8] {
+		value = m.writeHooks.run(addr, value)
+	}
 	m.cpuBus = value
-	switch {
-	case addr < 0x2000:
-		// CPU RAM (0x0000-0x1FFF, mirrored every 0x800 bytes)
-		m.RAM[addr%0x800] = value
+	r := m.pages[addr>>8]
+	if r == nil {
+		r = m.region(addr)
+	}
+	addr = r.fold(addr)
+	if r.Mem != nil {
+		r.Mem[addr-r.Start] = value
+		return 0
+	}
+	if r.Write == nil {
+		return 0
+	}
+	r.Write(addr, value)
+	if m.stall != 0 {
+		stall := m.stall
+		m.stall = 0
+		return stall
+	}
+	return 0
+}
 
-	case addr < 0x4000:
-		// PPU registers (0x2000-0x3FFF, mirrored every 8 bytes)
-		if m.PPU != nil {
-			ppuAddr := 0x2000 + (addr & 0x7)
-			if logger.CPUEnabled() && (ppuAddr == 0x2006 || ppuAddr == 0x2007) {
-				logger.LogCPU("Memory Write PPU $%04X: value=$%02X", ppuAddr, value)
-			}
-			m.PPU.WriteRegister(ppuAddr, value)
-		}
+// The console's devices, as nesMap wires them.
 
-	case addr == 0x4014:
-		m.performOAMDMA(value)
-		return oamDMAStallCycles
+func (m *Memory) readPPU(addr uint16) (uint8, uint8) {
+	if m.PPU == nil {
+		return 0, 0
+	}
+	return m.PPU.ReadRegister(addr), 0xFF
+}
 
-	case addr == 0x4016:
-		if m.Input != nil {
-			m.Input.Write(value)
-		}
+func (m *Memory) writePPU(addr uint16, value uint8) {
+	if m.PPU == nil {
+		return
+	}
+	if logger.BusEnabled() && (addr == 0x2006 || addr == 0x2007) {
+		logger.LogBus("Write PPU $%04X: value=$%02X", addr, value)
+	}
+	m.PPU.WriteRegister(addr, value)
+}
 
-	case addr < 0x4020:
-		if m.APU != nil {
-			m.APU.WriteRegister(addr, value)
-		}
-	case addr < 0x6000:
-		// Cartridge expansion ($4020-$5FFF). Only opt-in mappers (MMC5)
-		// receive these writes; for everything else the write is a
-		// no-op and the bus latch above stands.
-		if m.Cartridge != nil && m.Cartridge.HasExpansion() {
-			m.Cartridge.WritePRG(addr, value)
-		}
-	case addr >= 0x6000:
-		if m.Cartridge != nil {
-			m.Cartridge.WritePRG(addr, value)
-		} else {
-			index := addr - 0x6000
-			if index >= 0xA000 {
-				return 0
-			}
-			m.HighMem[index] = value
+func (m *Memory) writeAPU(addr uint16, value uint8) {
+	if m.APU != nil {
+		m.APU.WriteRegister(addr, value)
+	}
+}
+
+// readAPUStatus reads $4015. The APU lives inside the 2A03, so its
+// region is Internal: bit 5 (undriven) shows open bus, and the latch
+// keeps its previous value rather than taking the status.
+func (m *Memory) readAPUStatus(addr uint16) (uint8, uint8) {
+	if m.APU == nil {
+		return 0, 0
+	}
+	return m.APU.ReadRegister(addr), 0xDF
+}
+
+func (m *Memory) writeOAMDMA(_ uint16, value uint8) {
+	m.performOAMDMA(value)
+	m.stall = oamDMAStallCycles
+}
+
+// readInput reads $4016/$4017. D0-D4 come from the device in the port;
+// bits 5-7 aren't driven and keep the open-bus value — normally $40, the
+// high byte of the LDA $4016 operand, giving the familiar $40/$41 reads.
+// A VS. System, which drives them too, maps its own ports over these.
+func (m *Memory) readInput(addr uint16) (uint8, uint8) {
+	if m.Input == nil {
+		return 0, 0
+	}
+	return m.Input.Read(int(addr - 0x4016)), 0x1F
+}
+
+// writeOUT latches $4016 into the OUT pins the controller ports see.
+func (m *Memory) writeOUT(_ uint16, value uint8) {
+	if m.Input != nil {
+		m.Input.Write(value)
+	}
+}
+
+// readCartridge reads $6000-$FFFF once SetCartridge has put a cartridge
+// in: the PRG bank table when the mapper exposes one, else ReadPRG.
+func (m *Memory) readCartridge(addr uint16) (uint8, uint8) {
+	if addr >= 0x8000 && m.prgBanks != nil {
+		if b := m.prgBanks[(addr>>13)&3]; b != nil {
+			return b[addr&0x1FFF], 0xFF
 		}
 	}
-	return 0
+	if addr < 0x8000 && m.prgRAMGate != nil && !m.prgRAMGate.PRGRAMEnabled() {
+		return 0, 0
+	}
+	return m.Cartridge.ReadPRG(addr), 0xFF
+}
+
+func (m *Memory) writeCartridge(addr uint16, value uint8) {
+	m.Cartridge.WritePRG(addr, value)
 }
 
 // SaveState writes the CPU work RAM contents (2KB) to w.
@@ -235,6 +337,11 @@ func (m *Memory) SaveState(w io.Writer) error {
 	return err
 }
 
+// AppendState appends the bytes SaveState writes to b.
+func (m *Memory) AppendState(b []byte) []byte {
+	return append(b, m.RAM[:]...)
+}
+
 // LoadState restores the CPU work RAM from r.
 func (m *Memory) LoadState(r io.Reader) error {
 	_, err := io.ReadFull(r, m.RAM[:])
@@ -245,6 +352,9 @@ func (m *Memory) LoadState(r io.Reader) error {
 func (m *Memory) performOAMDMA(page uint8) {
 	// Transfer 256 bytes from CPU memory to PPU OAM
 	baseAddr := uint16(page) << 8
+	if logger.BusEnabled() {
+		logger.LogBus("OAM DMA from $%04X", baseAddr)
+	}
 
 	for i := 0; i < 256; i++ {
 		value := m.Read(baseAddr + uint16(i))
@@ -45,6 +45,24 @@ func TestHighMemFallback(t *testing.T) {
 	}
 }
 
+func TestPeek(t *testing.T) {
+	m := New()
+	m.RAM[0x10] = 0x42
+	m.Write(0x6000, 0xAB)
+	m.cpuBus = 0x5A
+	for _, tc := range []struct {
+		addr uint16
+		want uint8
+	}{{0x0010, 0x42}, {0x1810, 0x42}, {0x6000, 0xAB}, {0x2002, 0}, {0x4016, 0}} {
+		if got := m.Peek(tc.addr); got != tc.want {
+			t.Errorf("Peek($%04X) = %#02x, want %#02x", tc.addr, got, tc.want)
+		}
+	}
+	if m.cpuBus != 0x5A {
+		t.Errorf("Peek moved the bus latch to %#02x", m.cpuBus)
+	}
+}
+
 func TestWriteNilComponents(t *testing.T) {
 	m := New()
 	// These must be safe no-ops (or bus latches) with no devices attached.
@@ -74,3 +92,141 @@ func TestSaveLoadStateRAM(t *testing.T) {
 		t.Errorf("restored RAM[5] = %#02x, want 0x99", m2.RAM[5])
 	}
 }
+
+// fakePorts records $4016 writes and returns a fixed D0-D4 value per port
+// (with junk in bits 5-7 that the bus must discard).
+type fakePorts struct {
+	data    [2]uint8
+	written uint8
+}
+
+func (f *fakePorts) Read(port int) uint8 { return 0xE0 | f.data[port] }
+func (f *fakePorts) Write(value uint8)   { f.written = value }
+
+func TestControllerPortReads(t *testing.T) {
+	m := New()
+	ports := &fakePorts{data: [2]uint8{0x01, 0x18}}
+	m.SetInput(ports)
+
+	m.Write(0x4016, 0x01)
+	if ports.written != 0x01 {
+		t.Errorf("$4016 write reached ports as %#02x, want 0x01", ports.written)
+	}
+
+	// Bits 5-7 keep the open-bus value — $40 after fetching the high byte
+	// of an LDA $4016 operand.
+	m.cpuBus = 0x40
+	if got := m.Read(0x4016); got != 0x41 {
+		t.Errorf("$4016 read = %#02x, want 0x41", got)
+	}
+	m.cpuBus = 0x40
+	if got := m.Read(0x4017); got != 0x58 {
+		t.Errorf("$4017 read = %#02x, want 0x58 (port 2 D3/D4)", got)
+	}
+}
+
+func TestControllerPortDrivenLines(t *testing.T) {
+	// A VS. System drives every line of its ports: it maps them over the
+	// console's.
+	m := New()
+	ports := &fakePorts{data: [2]uint8{0x01, 0x18}}
+	m.Map(Region{Name: "VS port 0", Start: 0x4016, End: 0x4016, IO: true,
+		Read: func(uint16) (uint8, uint8) { return ports.Read(0), 0xFF }})
+	m.cpuBus = 0x40
+	if got := m.Read(0x4016); got != 0xE1 {
+		t.Errorf("$4016 read = %#02x, want the input's 0xe1 on every line", got)
+	}
+	m.Unmap("VS port 0")
+	m.SetInput(ports)
+	m.cpuBus = 0x40
+	if got := m.Read(0x4016); got != 0x41 {
+		t.Errorf("$4016 read after Unmap = %#02x, want the console's 0x41", got)
+	}
+}
+
+type fakeAPU struct{ status uint8 }
+
+func (f *fakeAPU) ReadRegister(uint16) uint8   { return f.status }
+func (f *fakeAPU) WriteRegister(uint16, uint8) {}
+
+func TestAPUStatusOpenBus(t *testing.T) {
+	m := New()
+	m.SetAPU(&fakeAPU{status: 0xFF})
+
+	m.cpuBus = 0x40
+	if got := m.Read(0x4015); got != 0xDF {
+		t.Errorf("$4015 with bus $40 = %#02x, want 0xDF (bit 5 from open bus)", got)
+	}
+	m.cpuBus = 0x20
+	if got := m.Read(0x4015); got != 0xFF {
+		t.Errorf("$4015 with bus $20 = %#02x, want 0xFF", got)
+	}
+	// The status read is internal to the CPU and doesn't reach the latch.
+	if m.cpuBus != 0x20 {
+		t.Errorf("$4015 read changed the bus latch to %#02x", m.cpuBus)
+	}
+}
+
+// gatedCart is a cartridge whose PRG RAM can be switched off.
+type gatedCart struct{ ramOn bool }
+
+func (c *gatedCart) ReadPRG(addr uint16) uint8 { return 0x00 }
+func (c *gatedCart) WritePRG(uint16, uint8)    {}
+func (c *gatedCart) PRGRAMEnabled() bool       { return c.ramOn }
+
+func TestDisabledPRGRAMReadsOpenBus(t *testing.T) {
+	m := New()
+	cart := &gatedCart{ramOn: true}
+	m.SetCartridge(cart)
+
+	m.cpuBus = 0x60
+	if got := m.Read(0x6000); got != 0x00 {
+		t.Errorf("enabled PRG RAM read = %#02x, want the cartridge's 0x00", got)
+	}
+	cart.ramOn = false
+	m.cpuBus = 0x60
+	if got := m.Read(0x6000); got != 0x60 {
+		t.Errorf("disabled PRG RAM read = %#02x, want open bus 0x60", got)
+	}
+	// The gate only covers $6000-$7FFF; PRG ROM is always driven.
+	m.cpuBus = 0x60
+	if got := m.Read(0x8000); got != 0x00 {
+		t.Errorf("PRG ROM read = %#02x, want 0x00", got)
+	}
+}
+
+func TestFillRAM(t *testing.T) {
+	m := New()
+	m.FillRAM(RAMFF, 0)
+	if m.RAM[0] != 0xFF || m.RAM[len(m.RAM)-1] != 0xFF {
+		t.Errorf("RAMFF: RAM[0]=%#02x last=%#02x", m.RAM[0], m.RAM[len(m.RAM)-1])
+	}
+	m.FillRAM(RAMZero, 0)
+	if m.RAM != [len(m.RAM)]uint8{} {
+		t.Error("RAMZero left non-zero bytes")
+	}
+
+	// The same seed must reproduce the same contents.
+	m.FillRAM(RAMRandom, 42)
+	first := m.RAM
+	m.FillRAM(RAMRandom, 42)
+	if m.RAM != first {
+		t.Error("RAMRandom with the same seed differed")
+	}
+	m.FillRAM(RAMRandom, 43)
+	if m.RAM == first {
+		t.Error("RAMRandom ignored the seed")
+	}
+}
+
+func TestParseRAMPattern(t *testing.T) {
+	for in, want := range map[string]RAMPattern{"00": RAMZero, "FF": RAMFF, "random": RAMRandom} {
+		got, err := ParseRAMPattern(in)
+		if err != nil || got != want {
+			t.Errorf("ParseRAMPattern(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseRAMPattern("aa"); err == nil {
+		t.Error("ParseRAMPattern accepted an unknown pattern")
+	}
+}
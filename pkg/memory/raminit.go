@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// RAMPattern selects what FillRAM writes into CPU work RAM at power-on.
+// Real consoles power up with a chip-dependent, partly random pattern; a
+// few games (and many homebrew bugs) read RAM before writing it, so the
+// choice can change behaviour. Reset never touches RAM.
+type RAMPattern int
+
+const (
+	RAMZero   RAMPattern = iota // every byte $00 (the default)
+	RAMFF                       // every byte $FF
+	RAMRandom                   // pseudo-random bytes from a seed
+)
+
+// ParseRAMPattern maps a -ram-init flag value ("00", "ff", "random") to a
+// RAMPattern.
+func ParseRAMPattern(s string) (RAMPattern, error) {
+	switch strings.ToLower(s) {
+	case "00", "0", "zero":
+		return RAMZero, nil
+	case "ff":
+		return RAMFF, nil
+	case "random":
+		return RAMRandom, nil
+	}
+	return RAMZero, fmt.Errorf("unknown RAM init pattern %q (want 00, ff or random)", s)
+}
+
+func (p RAMPattern) String() string {
+	switch p {
+	case RAMFF:
+		return "ff"
+	case RAMRandom:
+		return "random"
+	}
+	return "00"
+}
+
+// FillRAM overwrites the 2KB of CPU work RAM with pattern. seed is only used
+// by RAMRandom; the same seed always produces the same contents.
+func (m *Memory) FillRAM(pattern RAMPattern, seed int64) {
+	switch pattern {
+	case RAMFF:
+		for i := range m.RAM {
+			m.RAM[i] = 0xFF
+		}
+	case RAMRandom:
+		rand.New(rand.NewSource(seed)).Read(m.RAM[:])
+	default:
+		m.RAM = [len(m.RAM)]uint8{}
+	}
+}
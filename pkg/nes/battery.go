@@ -0,0 +1,50 @@
+package nes
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+)
+
+// LoadBatterySave fills the cartridge's non-volatile memory (battery RAM,
+// disk, flash or EEPROM; Cartridge.NonVolatile) from the .sav file at
+// path. A missing file is the normal first-boot case and only logged;
+// other I/O errors are logged and leave the memory as it was. Shared by
+// cmd/gones and the GUI, which reloads saves when a new ROM is dropped
+// onto the window.
+func LoadBatterySave(ram cartridge.NonVolatile, path string) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.LogInfo("No save file at %s (fresh save)", path)
+		return
+	}
+	if err != nil {
+		logger.LogError("Failed to open save file %s: %v", path, err)
+		return
+	}
+	defer f.Close()
+	if err := ram.LoadRAM(f); err != nil {
+		logger.LogError("Failed to read save file %s: %v", path, err)
+		return
+	}
+	logger.LogInfo("Loaded %s save file: %s", ram.Kind(), path)
+}
+
+// SaveBatterySave writes the non-volatile memory to path, logging any
+// failure.
+func SaveBatterySave(ram cartridge.NonVolatile, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		logger.LogError("Failed to create save file %s: %v", path, err)
+		return
+	}
+	defer f.Close()
+	if err := ram.SaveRAM(f); err != nil {
+		logger.LogError("Failed to write save file %s: %v", path, err)
+		return
+	}
+	logger.LogInfo("Wrote %s save file: %s", ram.Kind(), path)
+}
@@ -0,0 +1,108 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/memory"
+)
+
+func TestWithDeterministicPinsRAMSeed(t *testing.T) {
+	var ram [2][0x800]uint8
+	for i := range ram {
+		n := NewNES(WithDeterministic())
+		n.LoadCartridge(testCartridge(t))
+		n.RAMInit = memory.RAMRandom
+		n.PowerOn()
+		ram[i] = n.Memory.RAM
+	}
+	if ram[0] != ram[1] {
+		t.Error("deterministic power-ons filled RAM differently")
+	}
+	var m memory.Memory
+	m.FillRAM(memory.RAMRandom, DeterministicSeed)
+	if ram[0] != m.RAM {
+		t.Error("RAM not filled from DeterministicSeed")
+	}
+}
+
+func TestStateHash(t *testing.T) {
+	n := NewNES()
+	n.LoadCartridge(testCartridge(t))
+	n.PowerOn()
+	n.StepFrame()
+	h := n.StateHash()
+	if allocs := testing.AllocsPerRun(10, func() { n.StateHash() }); allocs != 0 {
+		t.Errorf("StateHash allocates %.0f times per call", allocs)
+	}
+
+	var state bytes.Buffer
+	if err := n.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		what  string
+		poke  func()
+		unset func()
+	}{
+		{"CPU A", func() { n.CPU.A++ }, func() { n.CPU.A-- }},
+		{"RAM", func() { n.Memory.RAM[0x7FF]++ }, func() { n.Memory.RAM[0x7FF]-- }},
+		{"VRAM", func() { n.PPU.VRAM[0x2400]++ }, func() { n.PPU.VRAM[0x2400]-- }},
+		{"OAM", func() { n.PPU.OAM[255]++ }, func() { n.PPU.OAM[255]-- }},
+		{"PRG RAM", func() { n.Cartridge.PRGRAM[0]++ }, func() { n.Cartridge.PRGRAM[0]-- }},
+	} {
+		tc.poke()
+		if n.StateHash() == h {
+			t.Errorf("%s: hash unchanged", tc.what)
+		}
+		tc.unset()
+	}
+
+	n.StepFrame()
+	if err := n.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.StateHash(); got != h {
+		t.Errorf("after LoadState: %016X, want %016X", got, h)
+	}
+}
+
+func BenchmarkStateHash(b *testing.B) {
+	n := NewNES()
+	n.LoadCartridge(testCartridge(b))
+	n.PowerOn()
+	n.StepFrame()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n.StateHash()
+	}
+}
+
+func TestCheckDeterminism(t *testing.T) {
+	newNES := func() *NES {
+		n := NewNES(WithDeterministic())
+		n.LoadCartridge(testCartridge(t))
+		n.PowerOn()
+		return n
+	}
+	movie := make(Movie, 30)
+	movie[5][0] = 0x08 // Start
+
+	// A power cycle must land exactly where a new instance starts.
+	a, b := newNES(), newNES()
+	for i := 0; i < 20; i++ {
+		b.StepFrame()
+	}
+	b.PowerOn()
+	if err := CheckDeterminism(a, b, movie); err != nil {
+		t.Fatalf("fresh vs power-cycled: %v", err)
+	}
+
+	a, b = newNES(), newNES()
+	b.Memory.RAM[0x10] = 1
+	var d *Divergence
+	if err := CheckDeterminism(a, b, movie); !errors.As(err, &d) || d.Frame != 0 {
+		t.Errorf("perturbed RAM: got %v, want a divergence at frame 0", err)
+	}
+}
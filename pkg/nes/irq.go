@@ -0,0 +1,93 @@
+package nes
+
+import "strings"
+
+// IRQSource is one of the devices wired to the 6502's /IRQ input. The
+// values are bits, so an IRQSource also holds a set of them.
+type IRQSource uint8
+
+const (
+	IRQMapper       IRQSource = 1 << iota // the cartridge: MMC3's scanline counter, FME-7's timer, the FDS drive…
+	IRQFrameCounter                       // the APU frame counter in 4-step mode
+	IRQDMC                                // the APU DMC at the end of a non-looping sample
+)
+
+var irqSourceNames = [...]string{"mapper", "frame", "dmc"}
+
+// String names the sources in s, e.g. "mapper|dmc", or "none".
+func (s IRQSource) String() string {
+	var names []string
+	for i, name := range irqSourceNames {
+		if s&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// IRQLine is the /IRQ line the sources share. It is open-collector on
+// the console: each source pulls it low on its own and lets go on its own,
+// and the line stays asserted while any of them holds it. So a game
+// acknowledging one source — reading $4015, writing MMC3's $E000 — never
+// hides another that is still waiting, and a source that stays asserted
+// keeps interrupting until it is acknowledged, once per RTI, as a level
+// and not an edge.
+type IRQLine struct {
+	held IRQSource
+}
+
+// Set drives src's level: asserted pulls the line, false lets go of it.
+func (l *IRQLine) Set(src IRQSource, asserted bool) {
+	if asserted {
+		l.held |= src
+	} else {
+		l.held &^= src
+	}
+}
+
+// Asserted reports whether any source holds the line.
+func (l *IRQLine) Asserted() bool { return l.held != 0 }
+
+// Held returns the sources holding the line.
+func (l *IRQLine) Held() IRQSource { return l.held }
+
+// sampleIRQ drives the line from every source's level and hands it to
+// the CPU. Step calls it once the PPU, APU and mapper have caught up with
+// the instruction, just before the CPU's poll: a source that rose during
+// the instruction is seen, and one acknowledged by it is already gone.
+// Only IRQ-capable mappers are asked — for every other cart the mapper
+// level stays low without an interface call per instruction.
+//
+// So the line is sampled once per instruction, not on every CPU cycle as
+// on hardware, where the 6502 polls it during the instruction's next to
+// last cycle. A source that rises on an instruction's last cycle is seen
+// one instruction early, and a level held for less than an instruction
+// and dropped within it is missed. Sampling per cycle needs the CPU to
+// step a cycle at a time with the devices alongside, which it doesn't.
+func (n *NES) sampleIRQ() {
+	n.IRQ.Set(IRQMapper, n.cartHasIRQ && n.Cartridge.IRQLine())
+	n.IRQ.Set(IRQFrameCounter, n.APU.FrameIRQ)
+	n.IRQ.Set(IRQDMC, n.APU.DMC.InterruptFlag)
+	n.CPU.IRQ = n.IRQ.Asserted()
+}
+
+// AcknowledgeIRQ acknowledges the sources in src the way each one's own
+// hardware does — the mapper's ClearIRQ, the frame counter's flag that a
+// $4015 read clears, the DMC's that a $4015 write clears — without the
+// side effects of the register access itself. For debuggers and tests;
+// games acknowledge through the registers.
+func (n *NES) AcknowledgeIRQ(src IRQSource) {
+	if src&IRQMapper != 0 && n.Cartridge != nil {
+		n.Cartridge.ClearIRQ()
+	}
+	if src&IRQFrameCounter != 0 {
+		n.APU.FrameIRQ = false
+	}
+	if src&IRQDMC != 0 {
+		n.APU.DMC.InterruptFlag = false
+	}
+	n.sampleIRQ()
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/yoshiomiyamaegones/pkg/apu"
 	"github.com/yoshiomiyamaegones/pkg/cartridge"
 	"github.com/yoshiomiyamaegones/pkg/cheat"
+	"github.com/yoshiomiyamaegones/pkg/core"
 	"github.com/yoshiomiyamaegones/pkg/cpu"
 	"github.com/yoshiomiyamaegones/pkg/input"
 	"github.com/yoshiomiyamaegones/pkg/memory"
@@ -23,7 +24,7 @@ type NES struct {
 	APU       *apu.APU
 	Memory    *memory.Memory
 	Cartridge *cartridge.Cartridge
-	Input     *input.Controller
+	Input     *input.Ports
 	Cheats    *cheat.Manager
 
 	Cycles uint64
@@ -37,24 +38,115 @@ type NES struct {
 	nmiDelay   bool
 	pendingNMI bool
 
+	// IRQ is the CPU's /IRQ line, driven by the mapper and the APU.
+	IRQ IRQLine
+
 	// cartHasIRQ mirrors Cartridge.HasIRQ() — true only for mappers that can
 	// assert the CPU IRQ line (MMC3/MMC5/FME-7). Step polls the mapper's
-	// IsIRQPending every instruction; for every other cart this stays false
+	// IRQLine every instruction; for every other cart this stays false
 	// so that per-instruction interface dispatch is skipped. Set in
 	// LoadCartridge.
 	cartHasIRQ bool
+
+	// RAMInit and RAMSeed choose what PowerOn fills CPU RAM with (see
+	// memory.RAMPattern); RAMSeed only matters for memory.RAMRandom.
+	RAMInit memory.RAMPattern
+	RAMSeed int64
+
+	// ppuAlignment and ppuWarmUp are construction-time settings (see
+	// Option); Reset applies both. deterministic pins the RAM seed (see
+	// WithDeterministic).
+	ppuAlignment  int
+	ppuWarmUp     bool
+	deterministic bool
+
+	// TrapOnHalt makes StepFrame return as soon as a JAM opcode halts the
+	// CPU, instead of running the PPU and APU on around the frozen CPU the
+	// way the hardware does. The frontend then reports CPU.HaltInfo().
+	TrapOnHalt bool
+
+	// Break, when set, is called before every instruction StepFrame runs;
+	// returning true makes StepFrame return at once, mid-frame. Debug
+	// tools use it for PC and memory breakpoints.
+	Break func() bool
+
+	// Trace, when set, is called before every instruction StepFrame runs
+	// (after Break lets it run), with the CPU about to fetch at CPU.PC;
+	// package trace records instructions through it.
+	Trace func()
+
+	// Profile, when set, is called after every instruction StepFrame runs
+	// with the instruction's address, the scanline it started on (-1 for
+	// the pre-render line) and the CPU cycles it took, so package profile
+	// can attribute the frame's time. Interrupt entry and DMA stalls count
+	// against the instruction they follow.
+	Profile func(pc uint16, scanline, cycles int)
+
+	// Frontend hooks driven by StepFrame (see package core); any may be
+	// nil. Not part of save-state, untouched by Reset.
+	video  core.VideoSink
+	audio  core.AudioSink
+	inputs [4]core.InputProvider
+
+	// inputLatch is when the input providers are polled; strobeHooks are
+	// the $4016 write and $4016/$4017 read hooks that poll them under
+	// LatchOnStrobe, and strobe the strobe line as the last write left it.
+	inputLatch  InputLatch
+	strobeHooks [2]memory.HookID
+	strobe      bool
+
+	// hashBuf is StateHash's scratch buffer, kept so that hashing every
+	// frame doesn't allocate.
+	hashBuf []byte
+
+	// stats times each frame while EnableStats is on; nil otherwise.
+	stats *statsClock
+}
+
+// PPUAlignments is the number of distinct CPU/PPU clock alignments the
+// emulator can start in. The NTSC PPU runs three dots per CPU cycle, and
+// which of those dots a CPU cycle lines up with is fixed at power-on by
+// whatever state the two clock dividers come up in. NESdev counts four
+// alignments at master-clock resolution; at dot resolution they collapse
+// to these three.
+const PPUAlignments = 3
+
+// Option configures a NES at construction; see NewNES.
+type Option func(*NES)
+
+// WithPPUAlignment picks the CPU/PPU alignment used from power-on: the PPU
+// starts phase dots (0 to PPUAlignments-1) ahead of the CPU. Some timing
+// test ROMs only pass in particular alignments, and a few games behave
+// differently between them on real consoles. Out-of-range values wrap.
+func WithPPUAlignment(phase int) Option {
+	return func(n *NES) {
+		n.ppuAlignment = ((phase % PPUAlignments) + PPUAlignments) % PPUAlignments
+	}
 }
 
-// NewNES creates a new NES instance
-func NewNES() *NES {
-	nes := &NES{}
+// WithPPUWarmUp turns the PPU's power-up warm-up on or off (on by default):
+// while it lasts, $2000/$2001/$2005/$2006 writes are ignored. See
+// ppu.PPU.BeginWarmUp.
+func WithPPUWarmUp(on bool) Option {
+	return func(n *NES) {
+		n.ppuWarmUp = on
+	}
+}
+
+// NewNES creates a new NES instance. With no options it starts in PPU
+// alignment 0 with the PPU warm-up enabled.
+func NewNES(opts ...Option) *NES {
+	nes := &NES{ppuWarmUp: true}
+	for _, opt := range opts {
+		opt(nes)
+	}
 
 	// Initialize components
 	nes.Memory = memory.New()
 	nes.CPU = cpu.New(nes.Memory)
 	nes.PPU = ppu.New(nes.Memory)
 	nes.APU = apu.New()
-	nes.Input = input.New()
+	nes.Input = input.NewPorts(input.New(), input.New())
 	nes.Cheats = cheat.NewManager()
 
 	// Connect components to memory
@@ -70,43 +162,134 @@ func NewNES() *NES {
 	return nes
 }
 
-// LoadCartridge loads a cartridge into the NES
+// LoadCartridge loads a cartridge into the NES. A VS. System game brings
+// its cabinet along: the ports get a VS panel (DIP switches off) and the
+// PPU the palette of the chip the header names. Anything else runs on a
+// console's 2C02 with no panel.
 func (n *NES) LoadCartridge(cart *cartridge.Cartridge) {
 	n.Cartridge = cart
 	n.cartHasIRQ = cart.HasIRQ()
 	n.Memory.SetCartridge(cart)
 	n.PPU.SetCartridge(cart)
-	n.APU.SetExpansionAudio(cart)
+	n.APU.SetExpansionAudio(cart.ExpansionAudio())
+	n.mapCartridge(cart)
+	if cart.VS() {
+		n.Input.SetVS(input.NewVSPanel(0))
+		n.PPU.PaletteManager.SetModel(ppu.VSModel(cart.Header.VSPPUType()))
+	} else {
+		n.Input.SetVS(nil)
+		n.PPU.PaletteManager.SetModel(ppu.Model2C02)
+	}
 }
 
-// Reset performs a power-on reset of the whole system. For the
-// reset-button path (CPU registers and RAM preserved) use SoftReset.
+// Bus regions a cartridge brings beyond its $6000-$FFFF slot.
+const (
+	regionExpansion = "cartridge expansion"
+	regionVSPort0   = "VS port 0"
+	regionVSPort1   = "VS port 1"
+)
+
+// mapCartridge replaces the previous cartridge's extra bus regions with
+// cart's: $4020-$5FFF for a board that decodes it (MMC5, the Disk System,
+// Namco 163, a VS. System's protection), and for a VS. System the
+// controller ports, whose DIP switches and coin slots drive lines a
+// console leaves open, with $4016 writes also going to the board's OUT
+// latch.
+func (n *NES) mapCartridge(cart *cartridge.Cartridge) {
+	for _, name := range []string{regionExpansion, regionVSPort0, regionVSPort1} {
+		n.Memory.Unmap(name)
+	}
+	if cart.HasExpansion() {
+		n.Memory.Map(memory.Region{
+			Name: regionExpansion, Start: 0x4020, End: 0x5FFF, IO: true,
+			Read:  func(addr uint16) (uint8, uint8) { return cart.ReadPRG(addr), 0xFF },
+			Write: cart.WritePRG,
+		})
+	}
+	if !cart.VS() {
+		return
+	}
+	readPort := func(addr uint16) (uint8, uint8) {
+		port := int(addr - 0x4016)
+		return n.Input.Read(port), n.Input.DrivenLines(port)
+	}
+	n.Memory.Map(memory.Region{
+		Name: regionVSPort0, Start: 0x4016, End: 0x4016, IO: true,
+		Read: readPort,
+		Write: func(_ uint16, value uint8) {
+			n.Input.Write(value)
+			cart.WriteOUT(value)
+		},
+	})
+	n.Memory.Map(memory.Region{
+		Name: regionVSPort1, Start: 0x4017, End: 0x4017, IO: true,
+		Read:  readPort,
+		Write: n.APU.WriteRegister,
+	})
+}
+
+// PowerOn models switching the console on: CPU RAM is filled according to
+// RAMInit/RAMSeed, the PPU and APU drop the state only a power cycle clears
+// (PPU.PowerOn, APU.PowerOn), then every chip is put in its power-up state
+// (Reset). A power cycle therefore matches a freshly built NES exactly.
+// Battery-backed PRG RAM is the cartridge's and is left alone.
+func (n *NES) PowerOn() {
+	n.Memory.FillRAM(n.RAMInit, n.ramSeed())
+	n.PPU.PowerOn()
+	n.APU.PowerOn()
+	n.Reset()
+}
+
+// Reset puts the CPU, PPU and APU in their power-up state without touching
+// RAM, clears the mapper's IRQ logic (Cartridge.Reset), then advances the PPU by the configured alignment and starts its
+// warm-up. For a full power cycle use PowerOn; for the reset button,
+// SoftReset.
 func (n *NES) Reset() {
 	n.CPU.Reset()
 	n.PPU.Reset()
 	n.APU.Reset()
+	if n.Cartridge != nil {
+		n.Cartridge.Reset()
+	}
+	n.PPU.StepN(n.ppuAlignment)
+	if n.ppuWarmUp {
+		n.PPU.BeginWarmUp()
+	}
 	n.Cycles = 0
 	n.Frame = 0
 	n.nmiDelay = false
 	n.pendingNMI = false
 }
 
-// SoftReset models the reset button: only the CPU's I-flag and stack
-// pointer change (A,X,Y and RAM are preserved). PPU/APU still get a full
-// reset for a usable display/audio state — blargg's cpu_reset suite
-// only checks CPU state, so cycle-accurate partial PPU/APU reset isn't
-// needed here.
+// SoftReset models the reset button: RAM and A/X/Y are preserved, the CPU
+// only sets I and drops SP by 3, and the PPU and APU keep the state their
+// reset lines don't clear (VRAM, OAM and palette; channel registers) — see
+// CPU.SoftReset, PPU.SoftReset and APU.SoftReset. On the NES (unlike the
+// Famicom) the PPU shares the reset line, so the warm-up starts over too.
 func (n *NES) SoftReset() {
 	n.CPU.SoftReset()
-	n.PPU.Reset()
-	n.APU.Reset()
+	n.PPU.SoftReset()
+	n.APU.SoftReset()
+	if n.ppuWarmUp {
+		n.PPU.BeginWarmUp()
+	}
 	n.nmiDelay = false
 	n.pendingNMI = false
 }
 
-// Step executes one CPU cycle
-func (n *NES) Step() {
+// Step executes one CPU instruction and catches the PPU, APU and mapper
+// up with it. Once a JAM opcode has halted the CPU it returns the
+// *cpu.HaltError describing where; the rest of the console keeps running
+// on each further Step, as on hardware, until a reset.
+func (n *NES) Step() error {
+	sc := n.stats
+	if sc != nil {
+		sc.mark()
+	}
 	cpuCycles := n.CPU.Step()
+	if sc != nil {
+		sc.add(&sc.cur.CPU)
+	}
 
 	// Capture an immediate NMI assertion (set inside CPU.Step by a $2000
 	// write enabling NMI while VBL is set) — see the pipeline comment
@@ -114,14 +297,6 @@ func (n *NES) Step() {
 	// assertion.
 	immediateNMI := n.PPU.ConsumeNMI()
 
-	// The CPU instruction may have written $E000 (MMC3 IRQ ack); refresh
-	// the cached mirror so the level-triggered c.IRQ sync below sees it.
-	// Only IRQ-capable mappers can move this line — for every other cart
-	// MapperIRQ stays false, so skip the per-instruction interface dispatch.
-	if n.cartHasIRQ {
-		n.PPU.MapperIRQ = n.Cartridge.IsIRQPending()
-	}
-
 	// NMI delivery pipeline — each stage advances one nes.Step:
 	//   Immediate ($2000 write inside CPU.Step): immediateNMI →
 	//     pendingNMI → c.NMI. 2-step (nmi_control test 11).
@@ -140,6 +315,9 @@ func (n *NES) Step() {
 	}
 
 	n.PPU.StepN(cpuCycles * 3)
+	if sc != nil {
+		sc.add(&sc.cur.PPU)
+	}
 
 	// The PPU asserts NMIRequested at most once per frame (the VBL-set
 	// transition), and nmiDelay only needs to be true by the end of this
@@ -151,19 +329,20 @@ func (n *NES) Step() {
 	}
 
 	n.APU.StepN(cpuCycles)
+	if sc != nil {
+		sc.add(&sc.cur.APU)
+		sc.cur.Instructions++
+	}
 
 	// CPU-rate mapper timers (FME-7's IRQ counter).
 	if n.Cartridge != nil {
 		n.Cartridge.TickCPU(cpuCycles)
-		if n.cartHasIRQ {
-			n.PPU.MapperIRQ = n.Cartridge.IsIRQPending()
-		}
 	}
 
-	// Level-triggered IRQ — OR together every line tied to the 6502's IRQ
-	// input. APU frame/DMC IRQs and mapper IRQs (MMC3) all share the same
-	// physical line; the CPU samples it once per cycle.
-	n.CPU.IRQ = n.PPU.MapperIRQ || n.APU.FrameIRQ || n.APU.DMC.InterruptFlag
+	// Level-triggered IRQ: the mapper and the APU's frame counter and DMC
+	// share one line (see IRQLine), sampled here once per instruction
+	// rather than per cycle (see sampleIRQ).
+	n.sampleIRQ()
 
 	// Run the just-completed instruction's end-of-cycle IRQ poll now that
 	// the bus has caught up with this instruction's PPU/APU output. An
@@ -171,15 +350,131 @@ func (n *NES) Step() {
 	n.CPU.PollIRQ()
 
 	n.Cycles += uint64(cpuCycles)
+	if n.CPU.Halted() {
+		return n.CPU.HaltInfo()
+	}
+	return nil
+}
+
+// SetVideoSink makes StepFrame deliver every finished frame to s (nil
+// stops delivery).
+func (n *NES) SetVideoSink(s core.VideoSink) { n.video = s }
+
+// SetAudioSink makes StepFrame deliver each frame's samples to s and then
+// drain APU.Output. With no sink the samples stay in APU.Output for the
+// caller to collect.
+func (n *NES) SetAudioSink(s core.AudioSink) { n.audio = s }
+
+// SetInputProvider makes StepFrame poll p for controller i's buttons (see
+// input.Ports.Controller for numbering) at the start of every frame, or
+// at each strobe (see SetInputLatch); a provider for a controller that
+// isn't connected isn't polled. nil hands the controller back to direct
+// SetButton calls. input.Staging provides thread-safe providers.
+func (n *NES) SetInputProvider(i int, p core.InputProvider) {
+	if i >= 0 && i < len(n.inputs) {
+		n.inputs[i] = p
+	}
 }
 
-// StepFrame executes until frame is complete
+// InputLatch is the point at which the input providers are polled.
+type InputLatch int
+
+const (
+	// LatchPerFrame polls once at the start of each StepFrame: a frame
+	// runs on one fixed set of buttons, which is what movie playback and
+	// netplay need.
+	LatchPerFrame InputLatch = iota
+	// LatchOnStrobe polls whenever the game raises the $4016 strobe, so
+	// the game reads the buttons as they stand at that moment — up to a
+	// frame less latency, at the cost of depending on when the writer
+	// changed them. While the strobe is held high every port read polls
+	// again, since the controllers then report A live.
+	LatchOnStrobe
+)
+
+// SetInputLatch chooses when StepFrame's input providers are polled.
+// Runtime preference — not part of save-state, untouched by Reset.
+func (n *NES) SetInputLatch(l InputLatch) {
+	if l == n.inputLatch {
+		return
+	}
+	n.inputLatch = l
+	if l == LatchOnStrobe {
+		n.strobe = false
+		n.strobeHooks = [2]memory.HookID{
+			n.Memory.AddWriteHook(0x4016, 0x4016, func(_ uint16, value uint8) uint8 {
+				// Before the write reaches the port, so the controllers
+				// reload from the freshly polled buttons.
+				n.strobe = value&1 != 0
+				if n.strobe {
+					n.pollInputs()
+				}
+				return value
+			}),
+			// Read hooks run after the port has answered, so with the
+			// strobe high the port is asked again once the buttons are
+			// polled. A read doesn't clock the devices then, so asking
+			// twice is harmless.
+			n.Memory.AddReadHook(0x4016, 0x4017, func(addr uint16, value uint8) uint8 {
+				if !n.strobe {
+					return value
+				}
+				n.pollInputs()
+				port := int(addr - 0x4016)
+				return value&^n.Input.DrivenLines(port) | n.Input.Read(port)
+			}),
+		}
+		return
+	}
+	for _, id := range n.strobeHooks {
+		n.Memory.RemoveHook(id)
+	}
+}
+
+// pollInputs copies each input provider's buttons into its controller.
+func (n *NES) pollInputs() {
+	for i, p := range n.inputs {
+		if p == nil {
+			continue
+		}
+		if c := n.Input.Controller(i); c != nil {
+			c.SetButtons(uint8(p.Poll()))
+		}
+	}
+}
+
+// StepFrame polls the input providers (under LatchPerFrame), executes
+// until the frame is complete, then hands the frame and its samples to
+// the sinks.
 func (n *NES) StepFrame() {
+	if n.stats != nil {
+		n.stats.beginFrame()
+	}
+	if n.inputLatch == LatchPerFrame {
+		n.pollInputs()
+	}
+	n.Input.StepFrame()
+
 	stepCount := 0
 	maxSteps := 50000 // Proper limit for normal NES frame processing
 
 	for !n.PPU.FrameComplete {
-		n.Step()
+		if n.TrapOnHalt && n.CPU.Halted() {
+			break
+		}
+		if n.Break != nil && n.Break() {
+			break
+		}
+		if n.Trace != nil {
+			n.Trace()
+		}
+		if n.Profile != nil {
+			pc, line, start := n.CPU.PC, n.PPU.Scanline, n.Cycles
+			n.Step()
+			n.Profile(pc, line, int(n.Cycles-start))
+		} else {
+			n.Step()
+		}
 		stepCount++
 
 		// Safety check to prevent infinite loops during game freezes
@@ -192,10 +487,21 @@ func (n *NES) StepFrame() {
 	n.PPU.FrameComplete = false
 	// Frame counter is managed by PPU, don't increment here
 	n.Frame = n.PPU.Frame
+	if n.stats != nil {
+		n.stats.endFrame(n.Frame)
+	}
+
+	if n.video != nil {
+		n.video.ReceiveFrame(n.GetDisplayFramebufferRaw())
+	}
+	if n.audio != nil {
+		n.audio.ReceiveSamples(n.APU.Output)
+		n.APU.Output = n.APU.Output[:0]
+	}
 }
 
-// GetInput returns the input controller
-func (n *NES) GetInput() *input.Controller {
+// GetInput returns the controller ports
+func (n *NES) GetInput() *input.Ports {
 	return n.Input
 }
 
@@ -204,19 +510,41 @@ func (n *NES) GetFramebuffer() []uint8 {
 	return n.PPU.GetFramebuffer()
 }
 
+// GetFramebufferInto is GetFramebuffer into a caller-provided buffer; see
+// ppu.PPU.GetFramebufferInto.
+func (n *NES) GetFramebufferInto(dst []uint8) []uint8 {
+	return n.PPU.GetFramebufferInto(dst)
+}
+
+// GetFramebufferRGB565Into is the framebuffer as RGB565 in a
+// caller-provided buffer; see ppu.PPU.GetFramebufferRGB565Into.
+func (n *NES) GetFramebufferRGB565Into(dst []uint16) []uint16 {
+	return n.PPU.GetFramebufferRGB565Into(dst)
+}
+
 // GetFrame returns the current frame number
 func (n *NES) GetFrame() uint64 {
 	return n.Frame
 }
 
-// GetFramebufferRaw returns the raw framebuffer as 32-bit integers
+// PRGOffset returns the PRG ROM offset mapped at addr, or -1 (see
+// cartridge.Cartridge.PRGOffset), for looking up bank-aware symbols.
+func (n *NES) PRGOffset(addr uint16) int {
+	if n.Cartridge == nil {
+		return -1
+	}
+	return n.Cartridge.PRGOffset(addr)
+}
+
+// GetFramebufferRaw returns the framebuffer in the PPU's native 0xAARRGGBB
+// format, without copying; see ppu.PPU.FrameBufferARGB.
 func (n *NES) GetFramebufferRaw() []uint32 {
-	return n.PPU.FrameBuffer[:]
+	return n.PPU.FrameBufferARGB()
 }
 
 // GetDisplayFramebufferRaw returns the framebuffer to display this frame.
 func (n *NES) GetDisplayFramebufferRaw() []uint32 {
-	return n.PPU.FrameBuffer[:]
+	return n.PPU.FrameBufferARGB()
 }
 
 // CompanionFile returns a path co-located with romPath, with its extension
@@ -226,11 +554,22 @@ func CompanionFile(romPath, suffix string) string {
 	return strings.TrimSuffix(romPath, filepath.Ext(romPath)) + suffix
 }
 
+// CompanionFileIn is CompanionFile relocated into dir, for users who keep
+// saves, states or screenshots apart from their ROMs. An empty dir means
+// next to the ROM.
+func CompanionFileIn(dir, romPath, suffix string) string {
+	path := CompanionFile(romPath, suffix)
+	if dir == "" {
+		return path
+	}
+	return filepath.Join(dir, filepath.Base(path))
+}
+
 // Save-state file header constants. Bump StateVersion whenever the on-disk
 // layout of any component changes — older files are then rejected at load.
 const (
 	stateMagic   uint32 = 0x47_4E_53_54 // "GNST"
-	StateVersion uint32 = 4              // v4: + NES.nmiDelay; + PPU vblSuppressed/nmiAssertCountdown/oddFrame
+	StateVersion uint32 = 8             // v8: + FME-7 audio registers (v7: + PPU warmUp; v6: + CPU halted/haltOpcode; v5: + PPU sprite0HitPending; v4: + NES.nmiDelay; + PPU vblSuppressed/nmiAssertCountdown/oddFrame)
 )
 
 // SaveState writes a complete emulator snapshot (CPU + PPU + APU + memory +
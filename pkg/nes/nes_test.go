@@ -3,9 +3,13 @@ package nes
 import (
 	"bytes"
 	"errors"
+	"path/filepath"
 	"testing"
 
 	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/core"
+	"github.com/yoshiomiyamaegones/pkg/cpu"
+	"github.com/yoshiomiyamaegones/pkg/memory"
 )
 
 // failWriter accepts bytes until `limit` total, then fails — used to drive
@@ -29,7 +33,7 @@ func (w *failWriter) Write(p []byte) (int, error) {
 
 // testCartridge builds a minimal NROM (mapper 0) image: 16KB PRG filled with
 // NOPs and a reset vector at $8000, plus 8KB CHR. Enough to run frames.
-func testCartridge(t *testing.T) *cartridge.Cartridge {
+func testCartridge(t testing.TB) *cartridge.Cartridge {
 	t.Helper()
 	prg := make([]byte, 16384)
 	for i := range prg {
@@ -56,6 +60,127 @@ func testCartridge(t *testing.T) *cartridge.Cartridge {
 	return cart
 }
 
+// testFrontend records what StepFrame hands a frontend and feeds back a
+// fixed button state.
+type testFrontend struct {
+	frames, samples int
+	frameLen        int
+	buttons         core.ButtonState
+	polls           int
+}
+
+func (f *testFrontend) ReceiveFrame(frame []uint32) { f.frames++; f.frameLen = len(frame) }
+func (f *testFrontend) ReceiveSamples(s []float32)  { f.samples += len(s) }
+func (f *testFrontend) Poll() core.ButtonState      { f.polls++; return f.buttons }
+
+func TestFrontendHooks(t *testing.T) {
+	n := NewNES()
+	n.LoadCartridge(testCartridge(t))
+	n.PowerOn()
+
+	fe := &testFrontend{buttons: core.ButtonA | core.ButtonStart}
+	n.SetVideoSink(fe)
+	n.SetAudioSink(fe)
+	n.SetInputProvider(0, fe)
+	n.SetInputProvider(3, fe) // no Four Score: no controller 3, never polled
+	n.SetInputProvider(7, fe) // out of range: ignored
+
+	for i := 0; i < 3; i++ {
+		n.StepFrame()
+	}
+	if fe.frames != 3 || fe.frameLen != core.FrameWidth*core.FrameHeight {
+		t.Errorf("frames = %d of %d pixels, want 3 of %d", fe.frames, fe.frameLen, core.FrameWidth*core.FrameHeight)
+	}
+	// ~734 samples a frame; the first frame after power-on is short.
+	if fe.samples < 1500 || fe.samples > 2300 {
+		t.Errorf("samples = %d over 3 frames", fe.samples)
+	}
+	if len(n.APU.Output) != 0 {
+		t.Errorf("APU.Output not drained: %d samples left", len(n.APU.Output))
+	}
+	if fe.polls != 3 {
+		t.Errorf("polls = %d, want 3 (once a frame)", fe.polls)
+	}
+	if got := n.Input.Controller(0).GetButtons(); got != uint8(core.ButtonA|core.ButtonStart) {
+		t.Errorf("controller 0 buttons = %#02x", got)
+	}
+
+	// Unhooked, the frontend stops hearing from the core and the samples
+	// accumulate in APU.Output again.
+	n.SetVideoSink(nil)
+	n.SetAudioSink(nil)
+	n.SetInputProvider(0, nil)
+	n.SetInputProvider(3, nil)
+	n.StepFrame()
+	if fe.frames != 3 || fe.polls != 3 || len(n.APU.Output) == 0 {
+		t.Errorf("after unhooking: frames=%d polls=%d output=%d", fe.frames, fe.polls, len(n.APU.Output))
+	}
+}
+
+func TestStats(t *testing.T) {
+	n := NewNES()
+	n.LoadCartridge(testCartridge(t))
+	n.PowerOn()
+
+	n.StepFrame()
+	if s := n.Stats(); s != (Stats{}) {
+		t.Errorf("stats off: %+v, want zero", s)
+	}
+	n.EnableStats(true)
+	n.StepFrame()
+	n.StepFrame()
+	s := n.Stats()
+	if s.Frame != n.Frame || s.Instructions == 0 {
+		t.Errorf("frame %d with %d instructions, want frame %d and some", s.Frame, s.Instructions, n.Frame)
+	}
+	if s.CPU <= 0 || s.PPU <= 0 || s.APU <= 0 || s.CPU+s.PPU+s.APU > s.Total {
+		t.Errorf("CPU %v + PPU %v + APU %v should be positive and within Total %v", s.CPU, s.PPU, s.APU, s.Total)
+	}
+	n.EnableStats(false)
+	if s := n.Stats(); s != (Stats{}) {
+		t.Errorf("stats switched off: %+v, want zero", s)
+	}
+}
+
+func TestInputLatch(t *testing.T) {
+	n := NewNES()
+	n.LoadCartridge(testCartridge(t))
+	n.PowerOn()
+	fe := &testFrontend{buttons: core.ButtonB}
+	n.SetInputProvider(0, fe)
+
+	n.SetInputLatch(LatchOnStrobe)
+	n.StepFrame() // the NOP cartridge never strobes
+	if fe.polls != 0 {
+		t.Errorf("polls = %d after a frame without a strobe, want 0", fe.polls)
+	}
+	n.Memory.Write(0x4016, 1)
+	n.Memory.Write(0x4016, 0)
+	if fe.polls != 1 || n.Input.Controller(0).Read() != 0 || n.Input.Controller(0).Read() != 1 {
+		t.Errorf("after strobe: polls = %d, want 1 with B latched", fe.polls)
+	}
+
+	// With the strobe held high, every read polls again and sees A live.
+	n.Memory.Write(0x4016, 1)
+	for i, b := range []core.ButtonState{core.ButtonA, core.ButtonB, core.ButtonA | core.ButtonStart} {
+		fe.buttons = b
+		if got, want := n.Memory.Read(0x4016)&1, uint8(b&core.ButtonA); got != want {
+			t.Errorf("strobe-high read %d = %d, want A = %d", i, got, want)
+		}
+	}
+	n.Memory.Write(0x4016, 0)
+	if fe.polls != 5 {
+		t.Errorf("polls = %d after a strobe and three reads with it high, want 5", fe.polls)
+	}
+
+	n.SetInputLatch(LatchPerFrame)
+	n.Memory.Write(0x4016, 1)
+	n.StepFrame()
+	if fe.polls != 6 {
+		t.Errorf("polls = %d back on LatchPerFrame, want 6 (strobe hooks removed)", fe.polls)
+	}
+}
+
 func TestNESRunAndGetters(t *testing.T) {
 	n := NewNES()
 	n.LoadCartridge(testCartridge(t))
@@ -88,9 +213,280 @@ func TestNESResetVariants(t *testing.T) {
 	n.Reset()
 	n.StepFrame()
 
-	// SoftReset preserves RAM/registers; just ensure it doesn't panic and
-	// re-initialises the interrupt pipeline.
+	// SoftReset preserves RAM and only drops SP by 3.
+	n.Memory.RAM[0x300] = 0x5A
+	sp := n.CPU.SP
 	n.SoftReset()
+	if n.Memory.RAM[0x300] != 0x5A {
+		t.Errorf("RAM[$300] = %#02x after SoftReset, want preserved", n.Memory.RAM[0x300])
+	}
+	if n.CPU.SP != sp-3 {
+		t.Errorf("SP = %#02x after SoftReset, want %#02x", n.CPU.SP, sp-3)
+	}
+
+	// PowerOn refills RAM with the configured pattern.
+	n.RAMInit = memory.RAMFF
+	n.PowerOn()
+	if n.Memory.RAM[0x300] != 0xFF || n.Memory.RAM[0] != 0xFF {
+		t.Errorf("RAM after PowerOn with RAMFF: $0=%#02x $300=%#02x", n.Memory.RAM[0], n.Memory.RAM[0x300])
+	}
+	if n.CPU.PC != 0x8000 {
+		t.Errorf("PC = %04X after PowerOn, want $8000", n.CPU.PC)
+	}
+}
+
+func TestSwapCartridge(t *testing.T) {
+	n := NewNES()
+	n.RAMInit = memory.RAMFF
+	n.LoadCartridge(testCartridge(t))
+	n.PowerOn()
+	n.StepFrame()
+	n.Memory.RAM[0x300] = 0x5A
+	n.Cartridge.PRGRAM[0] = 0xA5
+
+	// Keeping RAM resets into the new cartridge.
+	next := testCartridge(t)
+	if err := n.SwapCartridge(next, SwapKeepRAM); err != nil {
+		t.Fatal(err)
+	}
+	if n.Cartridge != next || n.CPU.PC != 0x8000 || n.Frame != 0 {
+		t.Errorf("after SwapKeepRAM: PC=$%04X frame %d, want a reset into the new cartridge", n.CPU.PC, n.Frame)
+	}
+	if n.Memory.RAM[0x300] != 0x5A || next.PRGRAM[0] != 0xA5 {
+		t.Errorf("after SwapKeepRAM: RAM[$300]=%#02x PRG RAM[0]=%#02x, want both kept", n.Memory.RAM[0x300], next.PRGRAM[0])
+	}
+
+	// Keeping the state carries on mid-frame.
+	n.StepFrame()
+	for i := 0; i < 100; i++ {
+		n.Step()
+	}
+	pc, cycles, scanline := n.CPU.PC, n.Cycles, n.PPU.Scanline
+	if err := n.SwapCartridge(testCartridge(t), SwapKeepState); err != nil {
+		t.Fatal(err)
+	}
+	if n.CPU.PC != pc || n.Cycles != cycles || n.PPU.Scanline != scanline || n.Memory.RAM[0x300] != 0x5A {
+		t.Errorf("after SwapKeepState: PC=$%04X cycles %d scanline %d, want $%04X %d %d", n.CPU.PC, n.Cycles, n.PPU.Scanline, pc, cycles, scanline)
+	}
+
+	// A power-on swap keeps nothing.
+	next = testCartridge(t)
+	if err := n.SwapCartridge(next, SwapPowerOn); err != nil {
+		t.Fatal(err)
+	}
+	if n.Memory.RAM[0x300] != 0xFF || next.PRGRAM[0] != 0 {
+		t.Errorf("after SwapPowerOn: RAM[$300]=%#02x PRG RAM[0]=%#02x", n.Memory.RAM[0x300], next.PRGRAM[0])
+	}
+
+	for _, m := range []SwapMode{SwapPowerOn, SwapKeepRAM, SwapKeepState} {
+		if got, err := ParseSwapMode(m.String()); err != nil || got != m {
+			t.Errorf("ParseSwapMode(%q) = %v, %v", m, got, err)
+		}
+	}
+	if _, err := ParseSwapMode("keep"); err == nil {
+		t.Error("ParseSwapMode(keep) succeeded")
+	}
+}
+
+func TestNESJamHalt(t *testing.T) {
+	cart := testCartridge(t)
+	cart.PRGROM[0x10] = 0x02 // JAM at $8010
+	n := NewNES()
+	n.LoadCartridge(cart)
+	n.Reset()
+
+	// Without the trap the PPU runs on around the frozen CPU.
+	n.StepFrame()
+	n.StepFrame()
+	if !n.CPU.Halted() || n.CPU.PC != 0x8010 {
+		t.Fatalf("halted=%v PC=%04X, want halted at $8010", n.CPU.Halted(), n.CPU.PC)
+	}
+	if n.GetFrame() != 2 {
+		t.Errorf("frame = %d, want 2", n.GetFrame())
+	}
+
+	// With it, StepFrame stops dead.
+	n.TrapOnHalt = true
+	cycles := n.Cycles
+	n.StepFrame()
+	if n.Cycles != cycles {
+		t.Errorf("trapped StepFrame ran %d cycles", n.Cycles-cycles)
+	}
+
+	// A single Step reports the halt and where it happened.
+	var halt *cpu.HaltError
+	if err := n.Step(); !errors.As(err, &halt) || halt.PC != 0x8010 || halt.Opcode != 0x02 {
+		t.Errorf("Step() = %v, want the JAM at $8010", err)
+	}
+
+	n.SoftReset()
+	if n.CPU.Halted() || n.CPU.PC != 0x8000 {
+		t.Errorf("after SoftReset: halted=%v PC=%04X", n.CPU.Halted(), n.CPU.PC)
+	}
+	if err := n.Step(); err != nil {
+		t.Errorf("Step() after SoftReset = %v", err)
+	}
+}
+
+func TestNESBreak(t *testing.T) {
+	n := NewNES()
+	n.LoadCartridge(testCartridge(t))
+	n.Reset()
+	n.Break = func() bool { return n.CPU.PC == 0x8010 }
+	n.StepFrame()
+	if n.CPU.PC != 0x8010 || n.Cycles != 16*2 {
+		t.Errorf("stopped at PC=%04X after %d cycles, want $8010 after 16 NOPs", n.CPU.PC, n.Cycles)
+	}
+	n.Break = nil
+	n.StepFrame()
+	if n.CPU.PC == 0x8010 {
+		t.Error("StepFrame without Break should run on")
+	}
+}
+
+func TestNESProfileHook(t *testing.T) {
+	n := NewNES()
+	n.LoadCartridge(testCartridge(t))
+	n.Reset()
+	var cycles, calls int
+	lines := map[int]bool{}
+	n.Profile = func(pc uint16, scanline, c int) {
+		if pc < 0x8000 {
+			t.Errorf("profiled PC $%04X outside the NOP slide", pc)
+		}
+		lines[scanline] = true
+		cycles += c
+		calls++
+	}
+	n.StepFrame()
+	start := n.Cycles
+	cycles, calls = 0, 0
+	n.StepFrame()
+	if uint64(cycles) != n.Cycles-start || calls < cycles/7 {
+		t.Errorf("profiled %d cycles in %d calls; the frame ran %d cycles", cycles, calls, n.Cycles-start)
+	}
+	if len(lines) != 262 || !lines[-1] || !lines[260] {
+		t.Errorf("instructions seen on %d scanlines, want all 262 (-1..260)", len(lines))
+	}
+}
+
+func TestNESTraceHook(t *testing.T) {
+	n := NewNES()
+	n.LoadCartridge(testCartridge(t))
+	n.Reset()
+	var traced, profiled int
+	n.Trace = func() { traced++ }
+	n.Profile = func(uint16, int, int) { profiled++ }
+	n.StepFrame()
+	if traced == 0 || traced != profiled {
+		t.Errorf("traced %d instructions, profiled %d", traced, profiled)
+	}
+
+	// An instruction Break holds back isn't traced.
+	traced = 0
+	n.Break = func() bool { return true }
+	n.StepFrame()
+	if traced != 0 {
+		t.Errorf("traced %d instructions Break stopped", traced)
+	}
+}
+
+func TestNESPPUWarmUp(t *testing.T) {
+	n := NewNES()
+	n.LoadCartridge(testCartridge(t))
+	n.Reset()
+	n.Memory.Write(0x2000, 0x80)
+	if n.PPU.PPUCTRL != 0 {
+		t.Errorf("PPUCTRL = %02X right after Reset, want the write ignored", n.PPU.PPUCTRL)
+	}
+	// The warm-up ends at the first pre-render line, ~29658 CPU cycles in.
+	n.StepFrame()
+	if n.PPU.WarmingUp() || n.Cycles < 29600 || n.Cycles > 29700 {
+		t.Errorf("warm-up=%v after %d cycles", n.PPU.WarmingUp(), n.Cycles)
+	}
+	n.Memory.Write(0x2000, 0x80)
+	if n.PPU.PPUCTRL != 0x80 {
+		t.Errorf("PPUCTRL = %02X after warm-up, want $80", n.PPU.PPUCTRL)
+	}
+
+	n = NewNES(WithPPUWarmUp(false))
+	n.LoadCartridge(testCartridge(t))
+	n.Reset()
+	n.Memory.Write(0x2000, 0x80)
+	if n.PPU.PPUCTRL != 0x80 {
+		t.Errorf("PPUCTRL = %02X with warm-up disabled, want $80", n.PPU.PPUCTRL)
+	}
+}
+
+// TestPPURegisterMirrorWrites drives the PPU through mirrors far from
+// $2000: $3456 is $2006, $3FFF is $2007.
+func TestPPURegisterMirrorWrites(t *testing.T) {
+	n := NewNES(WithPPUWarmUp(false))
+	n.LoadCartridge(testCartridge(t))
+	n.Reset()
+	n.Memory.Write(0x3456, 0x21)
+	n.Memory.Write(0x2A0E, 0x08)
+	if got := n.PPU.VRAMAddress(); got != 0x2108 {
+		t.Fatalf("VRAM address = $%04X after $3456/$2A0E writes, want $2108", got)
+	}
+	n.Memory.Write(0x3FFF, 0x5A)
+	if got := n.PPU.PeekNameTable(0x2108); got != 0x5A {
+		t.Errorf("nametable $2108 = $%02X after a $3FFF write, want $5A", got)
+	}
+}
+
+// TestNMIEnableDuringVBlank: turning PPUCTRL's NMI bit on while the VBlank
+// flag is set raises an NMI right away, and every further off→on toggle in
+// the same VBlank raises another. Rewriting the bit while it's already on,
+// or enabling it after a $2002 read has cleared the flag, does nothing.
+func TestNMIEnableDuringVBlank(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		code []byte
+		want uint8
+	}{
+		{"enable", []byte{0xA9, 0x80, 0x8D, 0x00, 0x20}, 1},
+		{"toggle twice", []byte{
+			0xA9, 0x80, 0x8D, 0x00, 0x20, // LDA #$80; STA $2000
+			0xA9, 0x00, 0x8D, 0x00, 0x20, // LDA #$00; STA $2000
+			0xA9, 0x80, 0x8D, 0x00, 0x20, // LDA #$80; STA $2000
+		}, 2},
+		{"rewrite", []byte{0xA9, 0x80, 0x8D, 0x00, 0x20, 0x8D, 0x00, 0x20}, 1},
+		{"after $2002 read", []byte{0xAD, 0x02, 0x20, 0xA9, 0x80, 0x8D, 0x00, 0x20}, 0},
+	} {
+		cart := testCartridge(t)
+		copy(cart.PRGROM, []byte{0x4C, 0x00, 0x80}) // JMP $8000 until we jump in
+		copy(cart.PRGROM[0x100:], tc.code)
+		copy(cart.PRGROM[0x200:], []byte{0xE6, 0x00, 0x40}) // NMI: INC $00; RTI
+		cart.PRGROM[0x3FFA], cart.PRGROM[0x3FFB] = 0x00, 0x82
+		n := NewNES(WithPPUWarmUp(false))
+		n.LoadCartridge(cart)
+		n.Reset()
+		for n.PPU.Scanline != 245 {
+			n.Step()
+		}
+		n.CPU.PC = 0x8100
+		for i := 0; i < 30; i++ {
+			n.Step()
+		}
+		if got := n.Memory.Read(0x0000); got != tc.want {
+			t.Errorf("%s: %d NMIs, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNESPPUAlignment(t *testing.T) {
+	for phase := 0; phase < PPUAlignments; phase++ {
+		n := NewNES(WithPPUAlignment(phase))
+		n.LoadCartridge(testCartridge(t))
+		n.Reset()
+		if n.PPU.Cycle != phase {
+			t.Errorf("alignment %d: PPU starts at dot %d", phase, n.PPU.Cycle)
+		}
+	}
+	if n := NewNES(WithPPUAlignment(4)); n.ppuAlignment != 1 {
+		t.Errorf("alignment 4 wrapped to %d, want 1", n.ppuAlignment)
+	}
 }
 
 func TestNESSaveLoadStateRoundTrip(t *testing.T) {
@@ -195,3 +591,88 @@ func TestCompanionFile(t *testing.T) {
 		}
 	}
 }
+
+func TestCompanionFileIn(t *testing.T) {
+	if got := CompanionFileIn("", "/roms/game.nes", ".sav"); got != "/roms/game.sav" {
+		t.Errorf("empty dir: %q", got)
+	}
+	if got := CompanionFileIn("/saves", "/roms/game.nes", ".state2"); got != filepath.Join("/saves", "game.state2") {
+		t.Errorf("with dir: %q", got)
+	}
+}
+
+func TestIRQLine(t *testing.T) {
+	var l IRQLine
+	l.Set(IRQMapper, true)
+	l.Set(IRQDMC, true)
+	l.Set(IRQMapper, false)
+	if !l.Asserted() || l.Held() != IRQDMC {
+		t.Fatalf("line held by %v after the mapper let go, want dmc", l.Held())
+	}
+	if s := (IRQMapper | IRQDMC).String(); s != "mapper|dmc" {
+		t.Errorf("String = %q", s)
+	}
+
+	n := NewNES()
+	n.LoadCartridge(testCartridge(t))
+	n.Reset()
+	n.CPU.P &^= cpu.FlagInterrupt
+	n.APU.FrameIRQ = true
+	n.APU.DMC.InterruptFlag = true
+	n.Step()
+	if n.IRQ.Held() != IRQFrameCounter|IRQDMC || !n.CPU.IRQ {
+		t.Fatalf("line held by %v, CPU.IRQ %v; want frame|dmc", n.IRQ.Held(), n.CPU.IRQ)
+	}
+	n.Step()
+	if n.CPU.PC < 0xEAEA || n.CPU.P&cpu.FlagInterrupt == 0 {
+		t.Fatalf("PC = $%04X after the IRQ, want the handler at $EAEA", n.CPU.PC)
+	}
+
+	// Acknowledging the frame counter leaves the DMC holding the line.
+	n.AcknowledgeIRQ(IRQFrameCounter)
+	if n.IRQ.Held() != IRQDMC || !n.CPU.IRQ || n.APU.FrameIRQ {
+		t.Errorf("after acknowledging the frame counter: held by %v, CPU.IRQ %v", n.IRQ.Held(), n.CPU.IRQ)
+	}
+	n.AcknowledgeIRQ(IRQDMC)
+	if n.IRQ.Asserted() || n.CPU.IRQ {
+		t.Errorf("line still held by %v with every source acknowledged", n.IRQ.Held())
+	}
+}
+
+func TestCartridgeBusRegions(t *testing.T) {
+	// A VS. System game on mapper 99 with TKO Boxing's protection chip:
+	// NES 2.0 console type 1, PPU 3, protection 2. CHR bank b is all b.
+	image := make([]byte, 16, 16+32768+16384)
+	copy(image, "NES\x1A\x02\x02\x00\x00")
+	image[6] = 0x30            // mapper 99 low nibble
+	image[7] = 0x60 | 0x08 | 1 // high nibble, NES 2.0, VS.
+	image[13] = 2<<4 | 3
+	image = append(image, make([]byte, 32768)...)
+	image = append(image, make([]byte, 8192)...)
+	image = append(image, bytes.Repeat([]byte{1}, 8192)...)
+	vs, err := cartridge.LoadFromReader(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewNES()
+	n.LoadCartridge(vs)
+	for addr, name := range map[uint16]string{0x4016: "VS port 0", 0x4017: "VS port 1", 0x5E01: "cartridge expansion"} {
+		if got := n.Memory.RegionAt(addr).Name; got != name {
+			t.Errorf("$%04X is answered by %q, want %q", addr, got, name)
+		}
+	}
+	// $4016 reaches the board's OUT latch: OUT2 picks CHR bank 1.
+	n.Memory.Write(0x4016, 0x04)
+	if got := vs.ReadCHR(0); got != 1 {
+		t.Errorf("CHR after OUT2 = %d, want bank 1", got)
+	}
+
+	// A console cartridge takes the VS. regions out again.
+	n.LoadCartridge(testCartridge(t))
+	for addr, name := range map[uint16]string{0x4016: "port 0", 0x4017: "port 1", 0x5E01: "expansion"} {
+		if got := n.Memory.RegionAt(addr).Name; got != name {
+			t.Errorf("after the swap $%04X is answered by %q, want %q", addr, got, name)
+		}
+	}
+}
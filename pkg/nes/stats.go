@@ -0,0 +1,68 @@
+package nes
+
+import "time"
+
+// Stats is where one frame's emulation time went, measured while
+// EnableStats is on. The PPU and APU catch up inside CPU.Step when the
+// program touches their registers, and that work counts as CPU time; the
+// rest of Total (mapper timers, IRQ sampling, input, debug hooks) is
+// spent between the chips.
+type Stats struct {
+	Frame        uint64        // the frame measured
+	CPU          time.Duration // CPU.Step, bus accesses included
+	PPU          time.Duration // PPU.StepN
+	APU          time.Duration // APU.StepN
+	Total        time.Duration // StepFrame up to handing the frame to the sinks
+	Instructions int
+}
+
+// statsClock collects Stats for the frame in progress: cur accumulates,
+// last is the frame before, lap is when the current measurement started.
+type statsClock struct {
+	cur, last Stats
+	start     time.Time
+	lap       time.Time
+}
+
+// beginFrame starts a frame's Stats.
+func (s *statsClock) beginFrame() {
+	s.cur = Stats{}
+	s.start = time.Now()
+}
+
+// endFrame finishes the frame's Stats and makes them the last.
+func (s *statsClock) endFrame(frame uint64) {
+	s.cur.Frame = frame
+	s.cur.Total = time.Since(s.start)
+	s.last = s.cur
+}
+
+// mark starts timing at now.
+func (s *statsClock) mark() { s.lap = time.Now() }
+
+// add charges the time since the last mark or add to *d.
+func (s *statsClock) add(d *time.Duration) {
+	now := time.Now()
+	*d += now.Sub(s.lap)
+	s.lap = now
+}
+
+// EnableStats turns per-frame timing on or off. It reads the clock four
+// times an instruction, which costs some speed, so it is off by default.
+func (n *NES) EnableStats(on bool) {
+	switch {
+	case !on:
+		n.stats = nil
+	case n.stats == nil:
+		n.stats = &statsClock{}
+	}
+}
+
+// Stats returns the timing of the last frame StepFrame finished, zero
+// when EnableStats is off or no frame has finished since it was turned on.
+func (n *NES) Stats() Stats {
+	if n.stats == nil {
+		return Stats{}
+	}
+	return n.stats.last
+}
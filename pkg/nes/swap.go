@@ -0,0 +1,83 @@
+package nes
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+)
+
+// SwapMode chooses how much of the running game SwapCartridge keeps.
+type SwapMode int
+
+const (
+	// SwapPowerOn starts the new cartridge from a power cycle, the same
+	// as loading a ROM afresh.
+	SwapPowerOn SwapMode = iota
+	// SwapKeepRAM resets into the new cartridge with CPU RAM and PRG RAM
+	// as they were, so a rebuilt game boots with its variables intact.
+	SwapKeepRAM
+	// SwapKeepState carries the whole machine over — registers, PPU,
+	// APU, mapper — and the game carries on where it was, running the
+	// new code.
+	SwapKeepState
+)
+
+var swapModeNames = [...]string{"power", "ram", "state"}
+
+func (m SwapMode) String() string {
+	if m < 0 || int(m) >= len(swapModeNames) {
+		return fmt.Sprintf("SwapMode(%d)", int(m))
+	}
+	return swapModeNames[m]
+}
+
+// ParseSwapMode parses a SwapMode's name: power, ram or state.
+func ParseSwapMode(s string) (SwapMode, error) {
+	for i, name := range swapModeNames {
+		if s == name {
+			return SwapMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("swap mode %q must be power, ram or state", s)
+}
+
+// SwapCartridge replaces the running cartridge with cart, keeping what
+// mode says — for reloading a ROM that was just rebuilt. The swap always
+// happens; the error only reports a SwapKeepState whose state the new
+// cartridge couldn't take (its mapper changed, say), in which case it
+// falls back to SwapKeepRAM.
+func (n *NES) SwapCartridge(cart *cartridge.Cartridge, mode SwapMode) error {
+	var prgRAM []uint8
+	var state bytes.Buffer
+	var err error
+	if old := n.Cartridge; old != nil {
+		prgRAM = append(prgRAM, old.PRGRAM...)
+		if mode == SwapKeepState {
+			if err = n.SaveState(&state); err != nil {
+				mode = SwapKeepRAM
+			}
+		}
+	} else if mode != SwapPowerOn {
+		mode = SwapPowerOn // nothing to keep
+	}
+
+	n.LoadCartridge(cart)
+	switch mode {
+	case SwapPowerOn:
+		n.PowerOn()
+		return nil
+	case SwapKeepState:
+		if err = n.LoadState(&state); err == nil {
+			return nil
+		}
+	}
+	// A failed LoadState may have got partway; Reset puts every chip
+	// back in its power-up state and leaves CPU RAM alone.
+	copy(cart.PRGRAM, prgRAM)
+	n.Reset()
+	if err != nil {
+		return fmt.Errorf("state not carried over, kept RAM only: %w", err)
+	}
+	return nil
+}
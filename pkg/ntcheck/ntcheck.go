@@ -0,0 +1,151 @@
+// Package ntcheck catches nametable and attribute bytes being changed
+// while the PPU is drawing. A game only feeds VRAM through $2007 in
+// VBlank or with rendering turned off; a write that lands mid-frame with
+// rendering on garbles the picture on hardware, so seeing one usually
+// means the emulator's timing let an NMI handler run long, a sprite-0 or
+// IRQ split fire late, or VBlank start early. Each such write is recorded
+// with the instruction that made it, for triaging a game's glitch down to
+// the routine involved.
+//
+// Post-render line 240 counts as safe, as the PPU is idle there; the
+// pre-render line (-1) does not. Writes that leave the byte as it was are
+// ignored.
+package ntcheck
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/yoshiomiyamaegones/pkg/memory"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// MaxWrites bounds what a Checker keeps; later writes are only counted.
+const MaxWrites = 10000
+
+// Write is one nametable byte changed while the PPU was drawing.
+type Write struct {
+	Frame    uint64 // ppu.PPU.Frame
+	Scanline int    // -1 for the pre-render line
+	Dot      int    // 0-340
+	PC       uint16 // the instruction writing $2007
+	Address  uint16 // $2000-$2FFF
+	Old      uint8
+	Value    uint8
+}
+
+// Attribute reports whether w hit an attribute table rather than tiles.
+func (w Write) Attribute() bool { return w.Address&0x3FF >= 0x3C0 }
+
+// String renders w for a log line:
+//
+//	F123 SL 31 DOT 256  $C123  nametable $2041: $24 -> $00
+func (w Write) String() string {
+	kind := "nametable"
+	if w.Attribute() {
+		kind = "attribute"
+	}
+	return fmt.Sprintf("F%d SL %d DOT %d  $%04X  %s $%04X: $%02X -> $%02X",
+		w.Frame, w.Scanline, w.Dot, w.PC, kind, w.Address, w.Old, w.Value)
+}
+
+// Checker watches one NES. Like the machine, it isn't safe for concurrent
+// use.
+type Checker struct {
+	nes  *nes.NES
+	hook memory.HookID
+
+	writes  []Write
+	dropped int
+
+	// OnWrite, if set, is called with each write as it is found — to log
+	// it while the game runs, say.
+	OnWrite func(Write)
+}
+
+// Start installs a checker on n's bus. Stop removes it.
+func Start(n *nes.NES) *Checker {
+	c := &Checker{nes: n}
+	c.hook = n.Memory.AddWriteHook(0x2000, 0x3FFF, c.check)
+	return c
+}
+
+// Stop uninstalls the checker's bus hook; what it found stays readable.
+func (c *Checker) Stop() {
+	c.nes.Memory.RemoveHook(c.hook)
+}
+
+// check runs before a write to the PPU registers lands, so the nametable
+// still holds the old byte.
+func (c *Checker) check(addr uint16, value uint8) uint8 {
+	if addr&7 != 7 {
+		return value
+	}
+	p := c.nes.PPU
+	if p.PPUMASK&0x18 == 0 || p.Scanline >= 240 {
+		return value
+	}
+	target := p.VRAMAddress()
+	if target < 0x2000 || target >= 0x3F00 {
+		return value // pattern table or palette
+	}
+	target = 0x2000 | target&0x0FFF
+	old := p.PeekNameTable(target)
+	if old == value {
+		return value
+	}
+	w := Write{
+		Frame:    p.Frame,
+		Scanline: p.Scanline,
+		Dot:      p.Cycle,
+		PC:       c.nes.CPU.InstructionPC(),
+		Address:  target,
+		Old:      old,
+		Value:    value,
+	}
+	if len(c.writes) < MaxWrites {
+		c.writes = append(c.writes, w)
+	} else {
+		c.dropped++
+	}
+	if c.OnWrite != nil {
+		c.OnWrite(w)
+	}
+	return value
+}
+
+// Writes returns the writes found so far, oldest first, up to MaxWrites.
+func (c *Checker) Writes() []Write { return c.writes }
+
+// Dropped is how many writes were found past MaxWrites.
+func (c *Checker) Dropped() int { return c.dropped }
+
+// Site totals the writes made by one instruction.
+type Site struct {
+	PC    uint16
+	Count int
+	First Write
+}
+
+// Sites groups writes by the instruction that made them, most writes
+// first (then by address).
+func Sites(writes []Write) []Site {
+	index := map[uint16]int{}
+	var sites []Site
+	for _, w := range writes {
+		i, ok := index[w.PC]
+		if !ok {
+			i = len(sites)
+			index[w.PC] = i
+			sites = append(sites, Site{PC: w.PC, First: w})
+		}
+		sites[i].Count++
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].Count != sites[j].Count {
+			return sites[i].Count > sites[j].Count
+		}
+		return sites[i].PC < sites[j].PC
+	})
+	return sites
+}
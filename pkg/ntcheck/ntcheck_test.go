@@ -0,0 +1,110 @@
+package ntcheck
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
+)
+
+// testNES runs a one-bank NROM program at $8000 that waits out the PPU
+// warm-up, turns rendering on in VBlank and writes $11 to $2040 there,
+// then burns ~5000 cycles into the picture and writes $00 (unchanged) and
+// $55 through $2007 before spinning.
+func testNES(t *testing.T) *nes.NES {
+	t.Helper()
+	n, err := testrom.New().Program(testrom.Code(0x8000).
+		Label("warm").
+		Op("BIT", testrom.Abs(0x2002)).
+		Op("BPL", testrom.To("warm")).
+		Label("vblank").
+		Op("BIT", testrom.Abs(0x2002)).
+		Op("BPL", testrom.To("vblank")).
+		Op("LDA", testrom.Imm(0x20)).
+		Op("STA", testrom.Abs(0x2006)).
+		Op("LDA", testrom.Imm(0x40)).
+		Op("STA", testrom.Abs(0x2006)).
+		Op("LDA", testrom.Imm(0x1E)).
+		Op("STA", testrom.Abs(0x2001)).
+		Op("LDA", testrom.Imm(0x11)).
+		Op("STA", testrom.Abs(0x2007)).
+		Op("LDY", testrom.Imm(4)).
+		Op("LDX", testrom.Imm(0)).
+		Label("wait").
+		Op("DEX").
+		Op("BNE", testrom.To("wait")).
+		Op("DEY").
+		Op("BNE", testrom.To("wait")).
+		Op("LDA", testrom.Imm(0x00)).
+		Op("STA", testrom.Abs(0x2007)).
+		Op("LDA", testrom.Imm(0x55)).
+		Op("STA", testrom.Abs(0x2007)).
+		Label("spin").
+		Op("JMP", testrom.To("spin"))).
+		NES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestChecker(t *testing.T) {
+	n := testNES(t)
+	c := Start(n)
+	var seen []Write
+	c.OnWrite = func(w Write) { seen = append(seen, w) }
+	for i := 0; i < 4; i++ {
+		n.StepFrame()
+	}
+
+	writes := c.Writes()
+	if len(writes) != 1 || len(seen) != 1 {
+		t.Fatalf("found %v (OnWrite saw %d), want the one $55 write", writes, len(seen))
+	}
+	w := writes[0]
+	if w.PC != 0x802F || w.Value != 0x55 || w.Old == w.Value || w.Scanline < 0 || w.Scanline >= 240 {
+		t.Errorf("write = %v, want $55 from $802F while drawing", w)
+	}
+	if w.Address < 0x2000 || w.Address > 0x2FFF {
+		t.Errorf("address $%04X outside $2000-$2FFF", w.Address)
+	}
+	if s := w.String(); !strings.Contains(s, "$802F  ") || !strings.HasSuffix(s, "-> $55") {
+		t.Errorf("String = %q", s)
+	}
+	if n.PPU.PeekNameTable(0x2040) != 0x11 {
+		t.Error("VBlank write didn't land")
+	}
+	if c.Dropped() != 0 {
+		t.Errorf("dropped %d", c.Dropped())
+	}
+
+	c.Stop()
+	n.Reset()
+	for i := 0; i < 4; i++ {
+		n.StepFrame()
+	}
+	if len(c.Writes()) != 1 {
+		t.Error("still checking after Stop")
+	}
+}
+
+func TestWriteAttribute(t *testing.T) {
+	if (Write{Address: 0x23BF}).Attribute() || !(Write{Address: 0x27C0}).Attribute() {
+		t.Error("attribute table is the last 64 bytes of each nametable")
+	}
+	if s := (Write{Address: 0x23C1}).String(); !strings.Contains(s, "attribute $23C1") {
+		t.Errorf("String = %q", s)
+	}
+}
+
+func TestSites(t *testing.T) {
+	sites := Sites([]Write{
+		{PC: 0xC000, Address: 0x2000},
+		{PC: 0xC100, Address: 0x2001},
+		{PC: 0xC100, Address: 0x2002},
+	})
+	if len(sites) != 2 || sites[0].PC != 0xC100 || sites[0].Count != 2 || sites[0].First.Address != 0x2001 {
+		t.Errorf("sites = %+v", sites)
+	}
+}
@@ -0,0 +1,22 @@
+package osd
+
+// BarWidth is the width of the scanline bar DrawScanlineBar draws.
+const BarWidth = 6
+
+// DrawScanlineBar draws a BarWidth-wide column down the right edge of fb
+// (a width×height ARGB8888 framebuffer), one colour per entry of lines
+// scaled to the full height, so a whole frame's scanlines — VBlank
+// included — fit beside the picture. Entries of 0 are left transparent.
+func DrawScanlineBar(fb []uint32, width, height int, lines []uint32) {
+	if len(lines) == 0 {
+		return
+	}
+	x := width - BarWidth
+	for y := 0; y < height; y++ {
+		c := lines[y*len(lines)/height]
+		if c == 0 {
+			continue
+		}
+		fillRect(fb, width, height, x, y, BarWidth, 1, c&0xFFFFFF, 0.85)
+	}
+}
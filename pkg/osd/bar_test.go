@@ -0,0 +1,25 @@
+package osd
+
+import "testing"
+
+func TestDrawScanlineBar(t *testing.T) {
+	const w, h = 16, 8
+	fb := make([]uint32, w*h)
+	// 16 lines onto 8 rows: row y shows line 2y.
+	lines := make([]uint32, 16)
+	lines[0] = 0xFFFF0000
+	lines[6] = 0xFF00FF00
+	DrawScanlineBar(fb, w, h, lines)
+
+	at := func(x, y int) uint32 { return fb[y*w+x] }
+	if at(w-1, 0)&0xFF0000 == 0 || at(w-BarWidth, 0) == 0 {
+		t.Errorf("row 0 = %08X, want red across the bar", at(w-1, 0))
+	}
+	if at(w-1, 3)&0x00FF00 == 0 {
+		t.Errorf("row 3 = %08X, want green (line 6)", at(w-1, 3))
+	}
+	if at(w-1, 1) != 0 || at(w-BarWidth-1, 0) != 0 {
+		t.Error("drew outside the owned lines or left of the bar")
+	}
+	DrawScanlineBar(fb, w, h, nil)
+}
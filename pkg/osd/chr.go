@@ -0,0 +1,52 @@
+package osd
+
+// Pattern table metrics: DrawCHR lays the two 4KB tables side by side
+// along the bottom of the frame, 16×16 tiles each, so a 1KB slot is a
+// CHRTableSize×CHRSlotHeight strip.
+const (
+	CHRTableSize  = 128
+	CHRSlotHeight = 32
+	chrSlotSize   = 0x400
+	chrGap        = 2
+	chrLitColor   = 0xFF3030
+	chrTint       = 0.35 // opacity of the tint over a fully lit slot
+)
+
+// chrShades are the grey levels of pixel values 0-3.
+var chrShades = [4]uint32{0x000000, 0x555555, 0xAAAAAA, 0xFFFFFF}
+
+// DrawCHR draws chr, pattern table data from $0000 (up to 8KB), into the
+// bottom-left of fb (a width×height ARGB8888 framebuffer) in greys. lit
+// gives a 0-1 strength per 1KB slot: lit slots are outlined and tinted
+// red, fading out with the strength.
+func DrawCHR(fb []uint32, width, height int, chr []uint8, lit []float64) {
+	y0 := height - margin - CHRTableSize
+	for slot := 0; slot*chrSlotSize < len(chr); slot++ {
+		x := margin + slot/4*(CHRTableSize+chrGap)
+		y := y0 + slot%4*CHRSlotHeight
+		drawCHRSlot(fb, width, height, x, y, chr[slot*chrSlotSize:min(len(chr), (slot+1)*chrSlotSize)])
+		if slot < len(lit) && lit[slot] > 0 {
+			a := min(lit[slot], 1)
+			fillRect(fb, width, height, x, y, CHRTableSize, CHRSlotHeight, chrLitColor, a*chrTint)
+			fillRect(fb, width, height, x, y, CHRTableSize, 1, chrLitColor, a)
+			fillRect(fb, width, height, x, y+CHRSlotHeight-1, CHRTableSize, 1, chrLitColor, a)
+			fillRect(fb, width, height, x, y, 1, CHRSlotHeight, chrLitColor, a)
+			fillRect(fb, width, height, x+CHRTableSize-1, y, 1, CHRSlotHeight, chrLitColor, a)
+		}
+	}
+}
+
+// drawCHRSlot draws the 64 tiles of one slot, 16 to a row.
+func drawCHRSlot(fb []uint32, width, height, x, y int, data []uint8) {
+	for tile := 0; tile*16+16 <= len(data); tile++ {
+		tx, ty := x+tile%16*8, y+tile/16*8
+		for row := 0; row < 8; row++ {
+			lo, hi := data[tile*16+row], data[tile*16+row+8]
+			for col := 0; col < 8; col++ {
+				bit := 7 - col
+				v := lo>>bit&1 | hi>>bit&1<<1
+				blend(fb, width, height, tx+col, ty+row, chrShades[v], 1)
+			}
+		}
+	}
+}
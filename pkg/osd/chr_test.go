@@ -0,0 +1,35 @@
+package osd
+
+import "testing"
+
+func TestDrawCHR(t *testing.T) {
+	const w, h = 300, 200
+	fb := make([]uint32, w*h)
+	chr := make([]uint8, 0x2000)
+	chr[0], chr[8] = 0x80, 0x80 // tile 0, top-left pixel: value 3
+	chr[0x1000+8] = 0x40        // slot 4, tile 0, second pixel: value 2
+	DrawCHR(fb, w, h, chr, []float64{0, 0, 0, 0, 0, 0, 0, 1})
+
+	at := func(x, y int) uint32 { return fb[y*w+x] }
+	y0 := h - margin - CHRTableSize
+	x1 := margin + CHRTableSize + chrGap
+	if got := at(margin, y0); got != 0xFFFFFFFF {
+		t.Errorf("table 0 tile 0 pixel 0 = %08X, want white", got)
+	}
+	if got := at(x1+1, y0); got != 0xFFAAAAAA {
+		t.Errorf("table 1 tile 0 pixel 1 = %08X, want light grey", got)
+	}
+	if got := at(margin+1, y0+1); got != 0xFF000000 {
+		t.Errorf("blank pixel = %08X, want black", got)
+	}
+	lit := y0 + 3*CHRSlotHeight
+	if got := at(x1, lit); got != 0xFF000000|chrLitColor {
+		t.Errorf("slot 7 outline = %08X, want red", got)
+	}
+	if got := at(x1+5, lit+5); got&0xFF0000 == 0 || got&0xFF == got>>16&0xFF {
+		t.Errorf("slot 7 inside = %08X, want a red tint", got)
+	}
+	if got := at(x1, y0); got == 0xFF000000|chrLitColor {
+		t.Error("an unlit slot was outlined")
+	}
+}
@@ -0,0 +1,76 @@
+package osd
+
+// Menu is the list DrawMenu draws: a title over one line per item, with
+// the selected item lit, and an optional hint line at the bottom.
+type Menu struct {
+	Title    string
+	Items    []string
+	Selected int    // index into Items
+	Footer   string // "" for none
+}
+
+// menuMoreAlpha is the opacity of the ↑/↓ marks beside a list that runs
+// past the panel.
+const menuMoreAlpha = 0.6
+
+// DrawMenu draws m centred on fb, a width×height ARGB8888 framebuffer.
+// Lines wider than the frame are cut; a list taller than the frame
+// scrolls to keep the selected item in view, the selection as near the
+// middle as the ends allow.
+func DrawMenu(fb []uint32, width, height int, m Menu) {
+	lineH := cellHeight + 2*padding
+	cols := (width - 4*margin - 2*padding) / cellWidth
+	fit := func(s string) string {
+		if len(s) > cols && cols > 0 {
+			return s[:cols]
+		}
+		return s
+	}
+
+	extra := 1 // the title
+	if m.Footer != "" {
+		extra++
+	}
+	rows := min(len(m.Items), max((height-4*margin)/lineH-extra, 1))
+	first := min(max(m.Selected-rows/2, 0), max(len(m.Items)-rows, 0))
+
+	textW := len(fit(m.Title))
+	for _, item := range m.Items {
+		textW = max(textW, len(fit(item)))
+	}
+	textW = max(textW, len(fit(m.Footer)))
+	innerW := textW*cellWidth + 2*padding
+	innerH := (rows+extra)*lineH + margin
+	x := (width - innerW) / 2
+	y := (height - innerH) / 2
+	fillRect(fb, width, height, x-margin, y-margin, innerW+2*margin, innerH+2*margin, shadowColor, backgroundAlpha)
+
+	drawText(fb, width, height, x+padding, y+padding, fit(m.Title), textColor, 1)
+	y += lineH + margin
+	for i := first; i < first+rows; i++ {
+		fg := uint32(textColor)
+		if i == m.Selected {
+			fillRect(fb, width, height, x, y, innerW, lineH, textColor, 1)
+			fg = shadowColor
+		}
+		drawText(fb, width, height, x+padding, y+padding, fit(m.Items[i]), fg, 1)
+		y += lineH
+	}
+	if first > 0 {
+		drawGlyph(fb, width, height, x+innerW+1, y-rows*lineH+padding, '^', textColor, menuMoreAlpha)
+	}
+	if first+rows < len(m.Items) {
+		drawGlyph(fb, width, height, x+innerW+1, y-lineH+padding, 'v', textColor, menuMoreAlpha)
+	}
+	if m.Footer != "" {
+		drawText(fb, width, height, x+padding, y+padding, fit(m.Footer), textColor, menuMoreAlpha)
+	}
+}
+
+// drawText draws text's glyphs from (x, y) with no box behind them.
+func drawText(fb []uint32, width, height, x, y int, text string, rgb uint32, alpha float64) {
+	for _, r := range text {
+		drawGlyph(fb, width, height, x, y, r, rgb, alpha)
+		x += cellWidth
+	}
+}
@@ -0,0 +1,52 @@
+package osd
+
+import "testing"
+
+func TestDrawMenu(t *testing.T) {
+	const w, h = 256, 240
+	fb := make([]uint32, w*h)
+	DrawMenu(fb, w, h, Menu{
+		Title:    "Menu",
+		Items:    []string{"Resume", "Load ROM...", "Quit"},
+		Selected: 1,
+	})
+
+	// The widest line sets the panel; the title takes the first line and
+	// a margin, the items follow.
+	lineH := cellHeight + 2*padding
+	innerW := len("Load ROM...")*cellWidth + 2*padding
+	innerH := 4*lineH + margin
+	x, y := (w-innerW)/2, (h-innerH)/2
+	at := func(x, y int) uint32 { return fb[y*w+x] &^ 0xFF000000 }
+	itemY := y + lineH + margin
+	if got := at(x, itemY+lineH); got != textColor {
+		t.Errorf("selected item %06X, want lit", got)
+	}
+	if got := at(x, itemY); got == textColor {
+		t.Error("unselected item lit")
+	}
+	if fb[0] != 0 || fb[(y-margin-1)*w+w/2] != 0 {
+		t.Error("drew outside the panel")
+	}
+
+	// A list longer than the frame scrolls with the selection: the last
+	// item selected ends up on the panel's bottom row.
+	items := make([]string, 40)
+	for i := range items {
+		items[i] = "Item"
+	}
+	fb = make([]uint32, w*h)
+	DrawMenu(fb, w, h, Menu{Title: "Long", Items: items, Selected: 39, Footer: "Esc"})
+	rows := (h-4*margin)/lineH - 2
+	innerH = (rows+2)*lineH + margin
+	y = (h - innerH) / 2
+	x = (w - (len("Long")*cellWidth + 2*padding)) / 2
+	lastY := y + lineH + margin + (rows-1)*lineH
+	if got := at(x, lastY); got != textColor {
+		t.Errorf("bottom row %06X, want the selected last item lit", got)
+	}
+
+	// A frame too small just draws what fits.
+	DrawMenu(make([]uint32, 4*4), 4, 4, Menu{Title: "x", Items: items})
+	DrawMenu(fb, w, h, Menu{Title: "Empty"})
+}
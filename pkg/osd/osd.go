@@ -0,0 +1,222 @@
+// Package osd draws short text messages ("State saved", FPS, volume, …)
+// straight into the emulator's ARGB framebuffer, so they show up in the
+// window, in screenshots of the window, and on any front-end that can
+// blit a []uint32 — no font library or SDL_ttf dependency.
+//
+// Two kinds of text are supported:
+//
+//   - Notify: transient messages stacked bottom-left. Each stays fully
+//     opaque for its lifetime and then fades out over FadeTime.
+//   - SetPersistent: keyed lines stacked top-left that stay until cleared
+//     (an FPS counter, an open menu). Setting a key again replaces its text
+//     in place, so a per-frame update doesn't reorder the stack.
+//
+// The OSD is not safe for concurrent use; the GUI calls it from its main
+// loop only.
+package osd
+
+import (
+	"time"
+)
+
+const (
+	// DefaultDuration is how long Notify keeps a message before fading.
+	DefaultDuration = 2 * time.Second
+	// FadeTime is the fade-out tail appended to every message's lifetime.
+	FadeTime = 500 * time.Millisecond
+	// maxMessages bounds the transient stack; the oldest drop off first.
+	maxMessages = 4
+
+	margin  = 4 // pixels between the text block and the screen edge
+	padding = 1 // background box padding around each line
+
+	textColor       = 0xFFFFFF // RGB; alpha comes from the fade
+	shadowColor     = 0x000000
+	backgroundAlpha = 0.55 // translucent box behind each line
+)
+
+type message struct {
+	text    string
+	expires time.Time // end of the opaque phase; fade runs FadeTime past it
+}
+
+type persistentLine struct {
+	key, text string
+}
+
+// OSD holds the pending on-screen text.
+type OSD struct {
+	messages   []message
+	persistent []persistentLine
+
+	// now is time.Now outside of tests; injectable so fade timing is
+	// deterministic in unit tests.
+	now func() time.Time
+}
+
+// New returns an empty OSD.
+func New() *OSD {
+	return &OSD{now: time.Now}
+}
+
+// Notify queues a transient message shown for DefaultDuration.
+func (o *OSD) Notify(msg string) {
+	o.NotifyFor(msg, DefaultDuration)
+}
+
+// NotifyFor queues a transient message shown for d before fading. A repeat
+// of the newest message just refreshes its timer instead of stacking a
+// duplicate (e.g. holding a volume key).
+func (o *OSD) NotifyFor(msg string, d time.Duration) {
+	expires := o.now().Add(d)
+	if n := len(o.messages); n > 0 && o.messages[n-1].text == msg {
+		o.messages[n-1].expires = expires
+		return
+	}
+	o.messages = append(o.messages, message{text: msg, expires: expires})
+	if len(o.messages) > maxMessages {
+		o.messages = o.messages[len(o.messages)-maxMessages:]
+	}
+}
+
+// SetPersistent shows msg under key until it is replaced or cleared with
+// an empty msg.
+func (o *OSD) SetPersistent(key, msg string) {
+	for i := range o.persistent {
+		if o.persistent[i].key != key {
+			continue
+		}
+		if msg == "" {
+			o.persistent = append(o.persistent[:i], o.persistent[i+1:]...)
+		} else {
+			o.persistent[i].text = msg
+		}
+		return
+	}
+	if msg != "" {
+		o.persistent = append(o.persistent, persistentLine{key: key, text: msg})
+	}
+}
+
+// Persistent returns the text currently shown under key ("" if none).
+func (o *OSD) Persistent(key string) string {
+	for _, p := range o.persistent {
+		if p.key == key {
+			return p.text
+		}
+	}
+	return ""
+}
+
+// Messages returns the transient messages that are still visible, oldest
+// first. Expired entries are pruned as a side effect.
+func (o *OSD) Messages() []string {
+	o.prune(o.now())
+	out := make([]string, len(o.messages))
+	for i, m := range o.messages {
+		out[i] = m.text
+	}
+	return out
+}
+
+// Empty reports whether Draw would render nothing, letting callers skip
+// the composite pass entirely.
+func (o *OSD) Empty() bool {
+	o.prune(o.now())
+	return len(o.messages) == 0 && len(o.persistent) == 0
+}
+
+func (o *OSD) prune(now time.Time) {
+	kept := o.messages[:0]
+	for _, m := range o.messages {
+		if now.Before(m.expires.Add(FadeTime)) {
+			kept = append(kept, m)
+		}
+	}
+	o.messages = kept
+}
+
+// Draw composites all visible text onto fb, a width×height ARGB8888
+// framebuffer (the PPU's 0xAARRGGBB layout). Lines that don't fit are
+// clipped rather than wrapped.
+func (o *OSD) Draw(fb []uint32, width, height int) {
+	now := o.now()
+	o.prune(now)
+
+	y := margin
+	for _, p := range o.persistent {
+		drawLine(fb, width, height, margin, y, p.text, 1)
+		y += cellHeight + 2*padding
+	}
+
+	y = height - margin - (cellHeight + 2*padding)
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		m := o.messages[i]
+		alpha := 1.0
+		if left := m.expires.Add(FadeTime).Sub(now); left < FadeTime {
+			alpha = float64(left) / float64(FadeTime)
+		}
+		drawLine(fb, width, height, margin, y, m.text, alpha)
+		y -= cellHeight + 2*padding
+	}
+}
+
+// drawLine renders one line of text at (x, y) — the top-left of its
+// background box — blended at the given opacity.
+func drawLine(fb []uint32, width, height, x, y int, text string, alpha float64) {
+	if alpha <= 0 {
+		return
+	}
+	boxW := len(text)*cellWidth + 2*padding
+	boxH := cellHeight + 2*padding
+	for py := y; py < y+boxH; py++ {
+		for px := x; px < x+boxW; px++ {
+			blend(fb, width, height, px, py, shadowColor, alpha*backgroundAlpha)
+		}
+	}
+
+	cx := x + padding
+	for _, r := range text {
+		// One-pixel drop shadow keeps white text legible on bright
+		// backgrounds even where the box is faint.
+		drawGlyph(fb, width, height, cx+1, y+padding+1, r, shadowColor, alpha)
+		drawGlyph(fb, width, height, cx, y+padding, r, textColor, alpha)
+		cx += cellWidth
+	}
+}
+
+// drawGlyph draws r's glyph with its top-left at (x, y).
+func drawGlyph(fb []uint32, width, height, x, y int, r rune, rgb uint32, alpha float64) {
+	g := glyph(r)
+	for col := 0; col < glyphWidth; col++ {
+		bits := g[col]
+		for row := 0; row < glyphHeight; row++ {
+			if bits&(1<<row) != 0 {
+				blend(fb, width, height, x+col, y+row, rgb, alpha)
+			}
+		}
+	}
+}
+
+// blend mixes an RGB colour into fb[x,y] at opacity a, leaving the
+// destination alpha byte opaque. Out-of-bounds pixels are ignored.
+func blend(fb []uint32, width, height, x, y int, rgb uint32, a float64) {
+	if x < 0 || y < 0 || x >= width || y >= height {
+		return
+	}
+	i := y*width + x
+	if i >= len(fb) {
+		return
+	}
+	if a >= 1 {
+		fb[i] = 0xFF000000 | rgb
+		return
+	}
+	dst := fb[i]
+	mix := func(shift uint) uint32 {
+		d := float64((dst >> shift) & 0xFF)
+		s := float64((rgb >> shift) & 0xFF)
+		return uint32(d+(s-d)*a) & 0xFF
+	}
+	fb[i] = 0xFF000000 | mix(16)<<16 | mix(8)<<8 | mix(0)
+}
@@ -0,0 +1,123 @@
+package osd
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock returns an OSD whose clock is advanced by hand.
+func fakeClock() (*OSD, *time.Time) {
+	t := time.Unix(1000, 0)
+	o := New()
+	o.now = func() time.Time { return t }
+	return o, &t
+}
+
+func TestNotifyLifetimeAndFade(t *testing.T) {
+	o, now := fakeClock()
+	o.Notify("State saved")
+
+	if got := o.Messages(); len(got) != 1 || got[0] != "State saved" {
+		t.Fatalf("Messages = %v", got)
+	}
+
+	// Still visible during the fade tail, gone after it.
+	*now = now.Add(DefaultDuration + FadeTime/2)
+	if o.Empty() {
+		t.Fatal("message vanished during fade")
+	}
+	*now = now.Add(FadeTime)
+	if !o.Empty() {
+		t.Fatalf("message should expire, still have %v", o.Messages())
+	}
+}
+
+func TestNotifyDedupAndCap(t *testing.T) {
+	o, _ := fakeClock()
+	o.Notify("Volume 50%")
+	o.Notify("Volume 50%")
+	if n := len(o.Messages()); n != 1 {
+		t.Fatalf("repeat of newest message stacked: %d entries", n)
+	}
+	for i := 0; i < maxMessages+2; i++ {
+		o.Notify(string(rune('A' + i)))
+	}
+	msgs := o.Messages()
+	if len(msgs) != maxMessages || msgs[len(msgs)-1] != string(rune('A'+maxMessages+1)) {
+		t.Fatalf("Messages = %v, want newest %d", msgs, maxMessages)
+	}
+}
+
+func TestSetPersistent(t *testing.T) {
+	o, _ := fakeClock()
+	o.SetPersistent("fps", "FPS 60.0")
+	o.SetPersistent("menu", "Recent ROMs")
+	o.SetPersistent("fps", "FPS 59.9") // replace in place
+
+	if o.Persistent("fps") != "FPS 59.9" || o.persistent[0].key != "fps" {
+		t.Fatalf("replace reordered or lost text: %+v", o.persistent)
+	}
+	o.SetPersistent("fps", "")
+	if o.Persistent("fps") != "" || len(o.persistent) != 1 {
+		t.Fatalf("clear failed: %+v", o.persistent)
+	}
+	o.SetPersistent("menu", "")
+	if !o.Empty() {
+		t.Fatal("OSD should be empty after clearing everything")
+	}
+}
+
+func TestDrawRendersGlyphs(t *testing.T) {
+	const w, h = 256, 240
+	o, now := fakeClock()
+	fb := make([]uint32, w*h)
+	for i := range fb {
+		fb[i] = 0xFF808080
+	}
+
+	// "I" column 2 is a full vertical bar ($7F): every row is text colour.
+	o.SetPersistent("k", "I")
+	o.Draw(fb, w, h)
+	x := margin + padding + 2
+	for row := 0; row < glyphHeight; row++ {
+		if got := fb[(margin+padding+row)*w+x]; got != 0xFFFFFFFF {
+			t.Fatalf("row %d of 'I' = %08X, want white", row, got)
+		}
+	}
+	// The background box darkens the pixel left of the glyph.
+	if got := fb[(margin+padding)*w+margin]; got&0xFF >= 0x80 {
+		t.Errorf("box pixel = %08X, want darkened", got)
+	}
+	// Far corner untouched.
+	if fb[w*h-1] != 0xFF808080 {
+		t.Errorf("unrelated pixel modified: %08X", fb[w*h-1])
+	}
+
+	// A half-faded notification draws grey-ish, not full white.
+	o.SetPersistent("k", "")
+	for i := range fb {
+		fb[i] = 0xFF000000
+	}
+	o.Notify("I")
+	*now = now.Add(DefaultDuration + FadeTime/2)
+	o.Draw(fb, w, h)
+	y := h - margin - (cellHeight + 2*padding) + padding
+	got := fb[y*w+x] & 0xFF
+	if got == 0 || got == 0xFF {
+		t.Errorf("faded pixel = %02X, want partial intensity", got)
+	}
+}
+
+func TestDrawClipsOffscreen(t *testing.T) {
+	// Text wider than the buffer must not panic or write out of range.
+	o, _ := fakeClock()
+	o.SetPersistent("long", "0123456789012345678901234567890123456789012345678901234567890")
+	fb := make([]uint32, 32*16)
+	o.Draw(fb, 32, 16)
+}
+
+func TestGlyphFallback(t *testing.T) {
+	if glyph('\n') != glyph('?') || glyph('é') != glyph('?') {
+		t.Error("non-printable runes should render as '?'")
+	}
+}
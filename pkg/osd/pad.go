@@ -0,0 +1,65 @@
+package osd
+
+import "github.com/yoshiomiyamaegones/pkg/core"
+
+// Controller graphic metrics: a PadWidth×PadHeight box per pad, drawn in
+// the same translucent style as the text lines.
+const (
+	PadWidth  = 32
+	PadHeight = 13
+
+	padReleasedColor = 0x606060
+	padPressedColor  = 0xFFFFFF
+	padFaceColor     = 0xE03030 // A and B when pressed, like the real pad
+)
+
+// padButton places one button on the pad graphic, relative to its top-left.
+type padButton struct {
+	mask       core.ButtonState
+	x, y, w, h int
+	pressed    uint32
+}
+
+var padLayout = [...]padButton{
+	{core.ButtonUp, 5, 2, 3, 3, padPressedColor},
+	{core.ButtonDown, 5, 8, 3, 3, padPressedColor},
+	{core.ButtonLeft, 2, 5, 3, 3, padPressedColor},
+	{core.ButtonRight, 8, 5, 3, 3, padPressedColor},
+	{0, 5, 5, 3, 3, padReleasedColor}, // D-pad hub, never lit
+	{core.ButtonSelect, 13, 7, 4, 2, padPressedColor},
+	{core.ButtonStart, 18, 7, 4, 2, padPressedColor},
+	{core.ButtonB, 23, 6, 3, 3, padFaceColor},
+	{core.ButtonA, 27, 6, 3, 3, padFaceColor},
+}
+
+// DrawPads draws one controller per entry of pads, player 1 leftmost, in
+// the bottom-right corner of fb (a width×height ARGB8888 framebuffer),
+// with each pressed button lit. It's independent of the message stack, so
+// a frontend can burn it into frames it records without showing any text.
+func DrawPads(fb []uint32, width, height int, pads []core.ButtonState) {
+	y := height - margin - PadHeight
+	x := width - len(pads)*(PadWidth+margin)
+	for _, b := range pads {
+		drawPad(fb, width, height, x, y, b)
+		x += PadWidth + margin
+	}
+}
+
+func drawPad(fb []uint32, width, height, x, y int, b core.ButtonState) {
+	fillRect(fb, width, height, x, y, PadWidth, PadHeight, shadowColor, backgroundAlpha)
+	for _, btn := range padLayout {
+		color := uint32(padReleasedColor)
+		if btn.mask != 0 && b.Pressed(btn.mask) {
+			color = btn.pressed
+		}
+		fillRect(fb, width, height, x+btn.x, y+btn.y, btn.w, btn.h, color, 1)
+	}
+}
+
+func fillRect(fb []uint32, width, height, x, y, w, h int, rgb uint32, a float64) {
+	for py := y; py < y+h; py++ {
+		for px := x; px < x+w; px++ {
+			blend(fb, width, height, px, py, rgb, a)
+		}
+	}
+}
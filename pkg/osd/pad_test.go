@@ -0,0 +1,42 @@
+package osd
+
+import (
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/core"
+)
+
+func TestDrawPads(t *testing.T) {
+	const w, h = 256, 240
+	fb := make([]uint32, w*h)
+	DrawPads(fb, w, h, []core.ButtonState{core.ButtonA | core.ButtonUp, core.ButtonStart})
+
+	// Player 1's pad sits left of player 2's, both flush bottom-right.
+	p1x := w - 2*(PadWidth+margin)
+	p2x := w - (PadWidth + margin)
+	y := h - margin - PadHeight
+	at := func(x, y int) uint32 { return fb[y*w+x] &^ 0xFF000000 }
+	for _, tc := range []struct {
+		name string
+		x, y int
+		want uint32
+	}{
+		{"p1 A", p1x + 28, y + 7, padFaceColor},
+		{"p1 B", p1x + 24, y + 7, padReleasedColor},
+		{"p1 up", p1x + 6, y + 3, padPressedColor},
+		{"p1 down", p1x + 6, y + 9, padReleasedColor},
+		{"p1 start", p1x + 19, y + 7, padReleasedColor},
+		{"p2 start", p2x + 19, y + 7, padPressedColor},
+		{"p2 A", p2x + 28, y + 7, padReleasedColor},
+	} {
+		if got := at(tc.x, tc.y); got != tc.want {
+			t.Errorf("%s: %06X, want %06X", tc.name, got, tc.want)
+		}
+	}
+	if fb[0] != 0 || fb[(y-1)*w+w-1] != 0 {
+		t.Error("pads drew outside their corner")
+	}
+
+	// A frame too small for the pads clips instead of panicking.
+	DrawPads(make([]uint32, 16*8), 16, 8, []core.ButtonState{0xFF})
+}
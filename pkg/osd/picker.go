@@ -0,0 +1,68 @@
+package osd
+
+// Picker is the save-state picker DrawPicker draws: a row of numbered
+// slots with the selected one lit and empty ones dimmed, above the
+// selected slot's thumbnail and caption.
+type Picker struct {
+	Filled   []bool // one per slot, numbered from 1; the tenth shows as 0
+	Selected int    // index into Filled
+
+	// Thumb is the selected slot's picture, ThumbW×ThumbH 0xAARRGGBB
+	// pixels; nil leaves a blank box of the same size.
+	Thumb          []uint32
+	ThumbW, ThumbH int
+
+	Caption []string
+}
+
+const (
+	pickerCell       = cellWidth + 2 // width of one slot number's box
+	pickerEmptyAlpha = 0.35          // opacity of an empty slot's number
+)
+
+// DrawPicker draws p centred on fb, a width×height ARGB8888 framebuffer.
+func DrawPicker(fb []uint32, width, height int, p Picker) {
+	rowW := len(p.Filled) * pickerCell
+	innerW := max(rowW, p.ThumbW)
+	for _, line := range p.Caption {
+		innerW = max(innerW, len(line)*cellWidth)
+	}
+	lineH := cellHeight + 2*padding
+	innerH := lineH + margin + p.ThumbH + margin + len(p.Caption)*lineH
+	x := (width - innerW) / 2
+	y := (height - innerH) / 2
+	fillRect(fb, width, height, x-margin, y-margin, innerW+2*margin, innerH+2*margin, shadowColor, backgroundAlpha)
+
+	sx := x + (innerW-rowW)/2
+	for i, filled := range p.Filled {
+		r := rune('0' + (i+1)%10)
+		fg, a := uint32(textColor), 1.0
+		switch {
+		case i == p.Selected:
+			fillRect(fb, width, height, sx, y, pickerCell, lineH, textColor, 1)
+			fg = shadowColor
+		case !filled:
+			a = pickerEmptyAlpha
+		}
+		drawGlyph(fb, width, height, sx+(pickerCell-glyphWidth)/2, y+padding, r, fg, a)
+		sx += pickerCell
+	}
+	y += lineH + margin
+
+	tx := x + (innerW-p.ThumbW)/2
+	if len(p.Thumb) >= p.ThumbW*p.ThumbH {
+		for ty := 0; ty < p.ThumbH; ty++ {
+			for tpx := 0; tpx < p.ThumbW; tpx++ {
+				blend(fb, width, height, tx+tpx, y+ty, p.Thumb[ty*p.ThumbW+tpx]&0xFFFFFF, 1)
+			}
+		}
+	} else {
+		fillRect(fb, width, height, tx, y, p.ThumbW, p.ThumbH, shadowColor, backgroundAlpha)
+	}
+	y += p.ThumbH + margin
+
+	for _, line := range p.Caption {
+		drawLine(fb, width, height, x+(innerW-len(line)*cellWidth)/2-padding, y, line, 1)
+		y += lineH
+	}
+}
@@ -0,0 +1,46 @@
+package osd
+
+import "testing"
+
+func TestDrawPicker(t *testing.T) {
+	const w, h = 256, 240
+	fb := make([]uint32, w*h)
+	thumb := make([]uint32, 16*8)
+	for i := range thumb {
+		thumb[i] = 0xFF00FF00
+	}
+	filled := make([]bool, 10)
+	filled[2] = true
+	DrawPicker(fb, w, h, Picker{
+		Filled:   filled,
+		Selected: 2,
+		Thumb:    thumb,
+		ThumbW:   16,
+		ThumbH:   8,
+		Caption:  []string{"Slot 3"},
+	})
+
+	// The row is wider than the thumbnail and caption, so it sets the
+	// panel: 10 cells centred, the thumbnail centred below it.
+	rowW := 10 * pickerCell
+	lineH := cellHeight + 2*padding
+	innerH := lineH + margin + 8 + margin + lineH
+	x, y := (w-rowW)/2, (h-innerH)/2
+	at := func(x, y int) uint32 { return fb[y*w+x] &^ 0xFF000000 }
+	if at(x+2*pickerCell, y) != textColor {
+		t.Errorf("selected cell %06X, want lit", at(x+2*pickerCell, y))
+	}
+	if at(x+pickerCell, y) == textColor {
+		t.Error("unselected cell lit")
+	}
+	if got := at(w/2, y+lineH+margin+4); got != 0x00FF00 {
+		t.Errorf("thumbnail centre %06X, want green", got)
+	}
+	if fb[0] != 0 || fb[(y-margin-1)*w+w/2] != 0 {
+		t.Error("drew outside the panel")
+	}
+
+	// No thumbnail and a frame too small both just draw what fits.
+	DrawPicker(fb, w, h, Picker{Filled: filled, ThumbW: 16, ThumbH: 8})
+	DrawPicker(make([]uint32, 4*4), 4, 4, Picker{Filled: filled, Thumb: thumb, ThumbW: 16, ThumbH: 8, Caption: []string{"x"}})
+}
@@ -0,0 +1,92 @@
+package osd
+
+// Oscilloscope metrics: DrawScopes stacks one ScopeHeight-tall pane per
+// Scope down the right half of the frame.
+const (
+	ScopeHeight = 24
+	scopeGap    = 2
+	scopeDimmed = 0.4 // trace opacity for a Scope with Dim set
+)
+
+// Scope is one pane of DrawScopes: a channel's recent levels, one per
+// audio sample, and a caption such as its note and volume.
+type Scope struct {
+	Label   string
+	Samples []uint8
+	Max     uint8  // the level at the top of the pane
+	Color   uint32 // RGB of the trace
+	Dim     bool   // faint trace: the channel is silent or muted
+}
+
+// DrawScopes draws scopes as oscilloscope panes down the right half of fb
+// (a width×height ARGB8888 framebuffer), top first. Each pane shows the
+// newest samples that fit its width, one per pixel, starting where the
+// trace rises through its midpoint if it does, so a steady tone stands
+// still from frame to frame instead of rolling.
+func DrawScopes(fb []uint32, width, height int, scopes []Scope) {
+	w := width/2 - margin
+	if w <= 0 {
+		return
+	}
+	x := width - margin - w
+	y := margin
+	for _, s := range scopes {
+		fillRect(fb, width, height, x, y, w, ScopeHeight, shadowColor, backgroundAlpha)
+		drawTrace(fb, width, height, x, y, w, s)
+		if s.Label != "" {
+			drawLine(fb, width, height, x, y, s.Label, 1)
+		}
+		y += ScopeHeight + scopeGap
+	}
+}
+
+func drawTrace(fb []uint32, width, height, x, y, w int, s Scope) {
+	samples := triggered(s.Samples, w)
+	if len(samples) == 0 || s.Max == 0 {
+		return
+	}
+	alpha := 1.0
+	if s.Dim {
+		alpha = scopeDimmed
+	}
+	level := func(v uint8) int {
+		if v > s.Max {
+			v = s.Max
+		}
+		return y + ScopeHeight - 1 - int(v)*(ScopeHeight-1)/int(s.Max)
+	}
+	prev := level(samples[0])
+	for i, v := range samples {
+		// Join each sample to the last with a vertical run, so the edges
+		// of a square wave are drawn rather than only its flats.
+		cur := level(v)
+		top, bottom := min(prev, cur), max(prev, cur)
+		fillRect(fb, width, height, x+i, top, 1, bottom-top+1, s.Color, alpha)
+		prev = cur
+	}
+}
+
+// triggered returns the n samples to show from s: the newest n, moved
+// back (by up to n more) to start on a rising crossing of the midpoint
+// between the lowest and highest level in view.
+func triggered(s []uint8, n int) []uint8 {
+	if len(s) <= n {
+		return s
+	}
+	start := len(s) - n
+	earliest := max(start-n, 1)
+	lo, hi := s[earliest], s[earliest]
+	for _, v := range s[earliest:] {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	if lo == hi {
+		return s[start:]
+	}
+	mid := lo + (hi-lo+1)/2
+	for i := start; i >= earliest; i-- {
+		if s[i-1] < mid && s[i] >= mid {
+			return s[i : i+n]
+		}
+	}
+	return s[start:]
+}
@@ -0,0 +1,50 @@
+package osd
+
+import "testing"
+
+func TestDrawScopes(t *testing.T) {
+	const w, h = 64, 64
+	fb := make([]uint32, w*h)
+	// A square wave, 8 low then 8 high, ending mid-way through a high run
+	// so the trigger has to step back to a rising edge.
+	var square []uint8
+	for i := 0; i < 100; i++ {
+		square = append(square, uint8(15*(i/8%2)))
+	}
+	DrawScopes(fb, w, h, []Scope{
+		{Samples: square, Max: 15, Color: 0xFF0000},
+		{Label: "A4", Samples: nil, Max: 15, Color: 0x00FF00},
+	})
+
+	x := w - margin - (w/2 - margin)
+	top, bottom := margin, margin+ScopeHeight-1
+	at := func(x, y int) uint32 { return fb[y*w+x] &^ 0xFF000000 }
+	// Triggered on a rising edge: high for the first 8 columns, then low.
+	if at(x, top) != 0xFF0000 || at(x+7, top) != 0xFF0000 || at(x+8, bottom) != 0xFF0000 {
+		t.Errorf("trace not triggered on the rising edge: %06X %06X %06X",
+			at(x, top), at(x+7, top), at(x+8, bottom))
+	}
+	if at(x+3, bottom) == 0xFF0000 {
+		t.Error("trace drawn low where the wave is high")
+	}
+	if fb[top*w+x-1] != 0 || fb[(bottom+1)*w+x] != 0 {
+		t.Error("drew outside the first pane")
+	}
+	second := margin + ScopeHeight + scopeGap
+	if fb[second*w+x] == 0 {
+		t.Error("second pane has no background")
+	}
+
+	// A frame too small for the panes clips instead of panicking.
+	DrawScopes(make([]uint32, 4*4), 4, 4, []Scope{{Label: "x", Samples: square, Max: 15}})
+}
+
+func TestTriggered(t *testing.T) {
+	flat := make([]uint8, 50)
+	if got := triggered(flat, 10); len(got) != 10 || &got[0] != &flat[40] {
+		t.Error("flat signal not shown from the newest samples")
+	}
+	if got := triggered(flat[:5], 10); len(got) != 5 {
+		t.Errorf("short input: got %d samples, want all 5", len(got))
+	}
+}
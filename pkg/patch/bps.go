@@ -0,0 +1,150 @@
+package patch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// bpsFooter is the three CRC-32s at the end of a BPS patch: the source,
+// the target and the patch up to this last one.
+const bpsFooter = 12
+
+// bpsReader walks a BPS patch's body.
+type bpsReader struct {
+	p   []byte
+	pos int
+	end int // the footer's offset
+}
+
+// number decodes one of BPS's variable-length numbers: seven bits a
+// byte, low first, the top bit marking the last byte, each continuation
+// adding one more so no value has two encodings.
+func (r *bpsReader) number() (int, error) {
+	v, shift := 0, 1
+	for {
+		if r.pos >= r.end {
+			return 0, corrupt("BPS number runs into the footer at offset %d", r.pos)
+		}
+		if shift > 1<<42 {
+			return 0, corrupt("BPS number too large at offset %d", r.pos)
+		}
+		b := r.p[r.pos]
+		r.pos++
+		v += int(b&0x7F) * shift
+		if b&0x80 != 0 {
+			return v, nil
+		}
+		shift <<= 7
+		v += shift
+	}
+}
+
+// ApplyBPS applies a BPS patch, checking rom against the patch's source
+// size and CRC-32 first and the result against its target size and CRC
+// after.
+func ApplyBPS(rom, p []byte) ([]byte, error) {
+	if len(p) < len(bpsMagic)+bpsFooter {
+		return nil, corrupt("BPS patch of %d bytes is too short", len(p))
+	}
+	end := len(p) - bpsFooter
+	sourceCRC := binary.LittleEndian.Uint32(p[end:])
+	targetCRC := binary.LittleEndian.Uint32(p[end+4:])
+	if want, got := binary.LittleEndian.Uint32(p[end+8:]), crc32.ChecksumIEEE(p[:end+8]); want != got {
+		return nil, fmt.Errorf("%w: BPS patch CRC is %08X, should be %08X", ErrCorrupt, got, want)
+	}
+
+	r := &bpsReader{p: p, pos: len(bpsMagic), end: end}
+	sourceSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	metaSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	if metaSize > r.end-r.pos {
+		return nil, corrupt("BPS metadata of %d bytes runs past the end", metaSize)
+	}
+	r.pos += metaSize
+
+	if got := crc32.ChecksumIEEE(rom); len(rom) != sourceSize || got != sourceCRC {
+		return nil, fmt.Errorf("%w: it expects %d bytes with CRC-32 %08X, the ROM is %d bytes with %08X",
+			ErrWrongROM, sourceSize, sourceCRC, len(rom), got)
+	}
+	if targetSize > 64<<20 {
+		return nil, corrupt("BPS target of %d bytes is implausibly large", targetSize)
+	}
+
+	out := make([]byte, targetSize)
+	n := 0 // bytes of out written
+	var sourceRel, targetRel int
+	for r.pos < r.end {
+		action, err := r.number()
+		if err != nil {
+			return nil, err
+		}
+		length := action>>2 + 1
+		if length > targetSize-n {
+			return nil, corrupt("BPS action at offset %d writes past the %d-byte target", r.pos, targetSize)
+		}
+		switch action & 3 {
+		case 0: // SourceRead: the source's bytes at the same offset
+			if n+length > len(rom) {
+				return nil, corrupt("BPS SourceRead past the end of the source")
+			}
+			copy(out[n:], rom[n:n+length])
+		case 1: // TargetRead: bytes from the patch
+			if length > r.end-r.pos {
+				return nil, corrupt("BPS TargetRead at offset %d runs into the footer", r.pos)
+			}
+			copy(out[n:], p[r.pos:r.pos+length])
+			r.pos += length
+		case 2: // SourceCopy: the source from a relative offset
+			d, err := r.number()
+			if err != nil {
+				return nil, err
+			}
+			sourceRel += signed(d)
+			if sourceRel < 0 || sourceRel+length > len(rom) {
+				return nil, corrupt("BPS SourceCopy from %d outside the source", sourceRel)
+			}
+			copy(out[n:], rom[sourceRel:sourceRel+length])
+			sourceRel += length
+		case 3: // TargetCopy: earlier output, byte by byte so runs repeat
+			d, err := r.number()
+			if err != nil {
+				return nil, err
+			}
+			targetRel += signed(d)
+			if targetRel < 0 || targetRel >= n {
+				return nil, corrupt("BPS TargetCopy from %d outside what is written", targetRel)
+			}
+			for i := 0; i < length; i++ {
+				out[n+i] = out[targetRel]
+				targetRel++
+			}
+		}
+		n += length
+	}
+	if n != targetSize {
+		return nil, corrupt("BPS actions write %d of the %d-byte target", n, targetSize)
+	}
+	if got := crc32.ChecksumIEEE(out); got != targetCRC {
+		return nil, fmt.Errorf("%w: CRC-32 %08X, patch expects %08X", ErrBadResult, got, targetCRC)
+	}
+	return out, nil
+}
+
+// signed decodes a relative offset: the magnitude above the low bit,
+// which is set for a negative one.
+func signed(d int) int {
+	if d&1 != 0 {
+		return -(d >> 1)
+	}
+	return d >> 1
+}
@@ -0,0 +1,59 @@
+package patch
+
+// ApplyIPS applies an IPS patch: after "PATCH", records of a 24-bit
+// offset and a 16-bit length followed by that many bytes, or by a 16-bit
+// count and one byte to repeat when the length is 0; "EOF" ends them and
+// may be followed by a 24-bit size to cut the image to. Records past the
+// end of the image grow it.
+func ApplyIPS(rom, p []byte) ([]byte, error) {
+	out := append([]byte(nil), rom...)
+	pos := len(ipsMagic)
+	read := func(n int) (int, bool) {
+		if pos+n > len(p) {
+			return 0, false
+		}
+		v := 0
+		for _, b := range p[pos : pos+n] {
+			v = v<<8 | int(b)
+		}
+		pos += n
+		return v, true
+	}
+	for {
+		if pos+3 <= len(p) && string(p[pos:pos+3]) == "EOF" {
+			pos += 3
+			break
+		}
+		off, ok1 := read(3)
+		size, ok2 := read(2)
+		if !ok1 || !ok2 {
+			return nil, corrupt("IPS record at offset %d cut short (no EOF marker)", pos)
+		}
+		var data []byte
+		if size == 0 {
+			count, ok1 := read(2)
+			value, ok2 := read(1)
+			if !ok1 || !ok2 {
+				return nil, corrupt("IPS fill record at offset %d cut short", pos)
+			}
+			data = make([]byte, count)
+			for i := range data {
+				data[i] = byte(value)
+			}
+		} else {
+			if pos+size > len(p) {
+				return nil, corrupt("IPS record at offset %d wants %d bytes, patch has %d", pos, size, len(p)-pos)
+			}
+			data = p[pos : pos+size]
+			pos += size
+		}
+		if end := off + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[off:], data)
+	}
+	if size, ok := read(3); ok && size < len(out) {
+		out = out[:size]
+	}
+	return out, nil
+}
@@ -0,0 +1,89 @@
+// Package patch applies IPS and BPS patches to a ROM image in memory, the
+// way translations and ROM hacks are distributed, so the patched image
+// never has to be written to disk ("soft-patching").
+//
+// Apply tells the formats apart by their magic. IPS records overwrite or
+// fill byte ranges and may grow the image; BPS rebuilds the image from
+// the original and carries CRC-32s of the original, the result and the
+// patch itself, all of which are checked.
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+var (
+	ipsMagic = []byte("PATCH")
+	bpsMagic = []byte("BPS1")
+)
+
+// Errors for a patch that can't be applied; the returned errors wrap them
+// with details.
+var (
+	ErrFormat    = errors.New("not an IPS or BPS patch")
+	ErrCorrupt   = errors.New("patch is damaged")
+	ErrWrongROM  = errors.New("patch is for a different ROM")
+	ErrBadResult = errors.New("patched ROM fails its checksum")
+)
+
+// Extensions are the patch files ForROM looks for beside a ROM, in order.
+var Extensions = []string{".ips", ".bps"}
+
+// Apply returns rom with p applied, leaving rom untouched.
+func Apply(rom, p []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(p, ipsMagic):
+		return ApplyIPS(rom, p)
+	case bytes.HasPrefix(p, bpsMagic):
+		return ApplyBPS(rom, p)
+	}
+	return nil, ErrFormat
+}
+
+// ForROM reads the patch to apply to the ROM at romPath: the file named
+// by explicit if it is set, otherwise <rom>.ips or <rom>.bps beside the
+// ROM when there is one (romPath "" looks for none). With no patch it
+// returns nil and "".
+func ForROM(romPath, explicit string) (data []byte, path string, err error) {
+	if explicit != "" {
+		data, err = os.ReadFile(explicit)
+		return data, explicit, err
+	}
+	if romPath == "" {
+		return nil, "", nil
+	}
+	for _, ext := range Extensions {
+		path = nes.CompanionFile(romPath, ext)
+		data, err = os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return data, path, err
+	}
+	return nil, "", nil
+}
+
+// ApplyFor applies the patch ForROM finds for romPath to rom, the image
+// read from it, returning the result and the patch's path; with no patch
+// it returns rom itself and "".
+func ApplyFor(rom []byte, romPath, explicit string) ([]byte, string, error) {
+	p, path, err := ForROM(romPath, explicit)
+	if err != nil || p == nil {
+		return rom, "", err
+	}
+	out, err := Apply(rom, p)
+	if err != nil {
+		return nil, path, fmt.Errorf("%s: %w", path, err)
+	}
+	return out, path, nil
+}
+
+// corrupt reports a patch that ends or points somewhere it can't.
+func corrupt(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,127 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIPS(t *testing.T) {
+	rom := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+	p := []byte("PATCH")
+	p = append(p, 0, 0, 2, 0, 2, 0xAA, 0xBB) // bytes 2-3
+	p = append(p, 0, 0, 6, 0, 0, 0, 4, 0xCC) // fill 6-9, growing the image
+	p = append(p, "EOF"...)
+	got, err := Apply(rom, p)
+	if want := []byte{0, 1, 0xAA, 0xBB, 4, 5, 0xCC, 0xCC, 0xCC, 0xCC}; err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Apply = % X, %v; want % X", got, err, want)
+	}
+	if rom[2] != 2 {
+		t.Error("Apply changed the original ROM")
+	}
+
+	got, err = Apply(rom, append(append([]byte("PATCH"), "EOF"...), 0, 0, 5))
+	if err != nil || len(got) != 5 {
+		t.Errorf("truncating patch: %d bytes, %v", len(got), err)
+	}
+
+	for _, bad := range [][]byte{
+		[]byte("PATCH\x00\x00\x02\x00\x05\xAA"), // record longer than the patch
+		[]byte("PATCH\x00\x00\x02"),             // no EOF
+	} {
+		if _, err := Apply(rom, bad); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("Apply(% X) = %v, want ErrCorrupt", bad, err)
+		}
+	}
+	if _, err := Apply(rom, []byte("UPS1")); !errors.Is(err, ErrFormat) {
+		t.Errorf("unknown format: %v, want ErrFormat", err)
+	}
+}
+
+// bps assembles a BPS patch from source to target with the given action
+// bytes, filling in the header and the three CRCs.
+func bps(source, target, actions []byte) []byte {
+	p := append([]byte("BPS1"), num(len(source))...)
+	p = append(p, num(len(target))...)
+	p = append(p, num(0)...)
+	p = append(p, actions...)
+	p = binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(source))
+	p = binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(p, crc32.ChecksumIEEE(p))
+}
+
+// num encodes a BPS number.
+func num(v int) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(out, b|0x80)
+		}
+		out = append(out, b)
+		v--
+	}
+}
+
+// action encodes a BPS action of the given kind and length.
+func action(kind, length int) []byte { return num((length-1)<<2 | kind) }
+
+func TestBPS(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	target := []byte("ABxyFGHxyFGCDzzzz")
+	var a []byte
+	a = append(a, action(0, 2)...)                         // AB
+	a = append(append(a, action(1, 2)...), "xy"...)        // xy
+	a = append(append(a, action(2, 3)...), num(5<<1)...)   // FGH from source offset 5
+	a = append(append(a, action(3, 4)...), num(2<<1)...)   // xyFG from output offset 2
+	a = append(append(a, action(2, 2)...), num(6<<1|1)...) // CD: back from 8 to 2
+	a = append(append(a, action(1, 1)...), "z"...)         // z
+	a = append(append(a, action(3, 3)...), num(7<<1)...)   // zzz: a run copying itself
+	p := bps(source, target, a)
+
+	got, err := Apply(source, p)
+	if err != nil || !bytes.Equal(got, target) {
+		t.Fatalf("Apply = %q, %v; want %q", got, err, target)
+	}
+
+	if _, err := Apply([]byte("ABCDEFGX"), p); !errors.Is(err, ErrWrongROM) {
+		t.Errorf("other ROM: %v, want ErrWrongROM", err)
+	}
+	damaged := bytes.Clone(p)
+	damaged[6] ^= 1
+	if _, err := Apply(source, damaged); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("damaged patch: %v, want ErrCorrupt", err)
+	}
+	wrongTarget := bps(source, []byte("ABxyFGHxyFGCDzzzy"), a)
+	if _, err := Apply(source, wrongTarget); !errors.Is(err, ErrBadResult) {
+		t.Errorf("wrong target CRC: %v, want ErrBadResult", err)
+	}
+	outside := bps(source, []byte("AB"), append(action(2, 2), num(20<<1)...))
+	if _, err := Apply(source, outside); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("copy outside the source: %v, want ErrCorrupt", err)
+	}
+}
+
+func TestForROM(t *testing.T) {
+	dir := t.TempDir()
+	rom := filepath.Join(dir, "game.nes")
+	if data, path, err := ForROM(rom, ""); data != nil || path != "" || err != nil {
+		t.Errorf("no patch: %q %q %v", data, path, err)
+	}
+	os.WriteFile(filepath.Join(dir, "game.bps"), []byte("BPS1"), 0o644)
+	if _, path, err := ForROM(rom, ""); path != filepath.Join(dir, "game.bps") || err != nil {
+		t.Errorf("beside the ROM: %q %v", path, err)
+	}
+	os.WriteFile(filepath.Join(dir, "game.ips"), []byte("PATCH"), 0o644)
+	if data, _, _ := ForROM(rom, ""); string(data) != "PATCH" {
+		t.Errorf(".ips should win over .bps, got %q", data)
+	}
+	if _, _, err := ForROM(rom, filepath.Join(dir, "missing.ips")); err == nil {
+		t.Error("missing explicit patch: no error")
+	}
+}
@@ -0,0 +1,114 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/memory"
+)
+
+// tickCart records the cycle of every Step (MMC3 counter clock) on
+// scanline line.
+type tickCart struct {
+	patternCart
+	p     *PPU
+	line  int
+	ticks []int
+}
+
+func (c *tickCart) Step() {
+	if c.p.Scanline == c.line {
+		c.ticks = append(c.ticks, c.p.Cycle)
+	}
+}
+
+// a12TicksFor runs a frame with ctrl in PPUCTRL and sprites with the
+// given tiles on scanline 101 — fetched during scanline 100 — and returns the
+// cycles the mapper was clocked on scanline 100.
+func a12TicksFor(ctrl uint8, tiles ...uint8) []int {
+	p := New(memory.New())
+	p.Reset()
+	cart := &tickCart{patternCart: patternCart{make([]uint8, 0x2000)}, p: p, line: 100}
+	p.SetCartridge(cart)
+	for i := range p.OAM {
+		p.OAM[i] = 0xFF
+	}
+	for i, tile := range tiles {
+		p.OAM[i*4] = 100 // OAM Y is screen Y - 1
+		p.OAM[i*4+1] = tile
+	}
+	p.WriteRegister(0x2000, ctrl)
+	p.WriteRegister(0x2001, PPUMASKBGShow|PPUMASKSpriteShow)
+	for i := 0; i < 341*262; i++ {
+		p.StepN(1) // one dot at a time so Step sees the live Cycle
+	}
+	return cart.ticks
+}
+
+func TestA12TicksFollowSpriteFetches(t *testing.T) {
+	const (
+		bg1000  = PPUCTRLBGTable
+		spr1000 = PPUCTRLSpriteTable
+		tall    = PPUCTRLSpriteSize
+	)
+	for _, tc := range []struct {
+		name  string
+		ctrl  uint8
+		tiles []uint8
+		want  []int
+	}{
+		{"8x8 BG $0000, sprites $1000", spr1000, nil, []int{273}},
+		{"8x8 BG $1000, sprites $0000", bg1000, nil, []int{337}},
+		{"8x8 both $0000", 0, nil, nil},
+		{"8x8 both $1000", bg1000 | spr1000, nil, nil},
+		// Empty 8×16 slots fetch tile $FF, from $1000.
+		{"8x16 no sprites", tall, nil, []int{273}},
+		{"8x16 slot 0 on $0000", tall, []uint8{0x02}, []int{281}},
+		{"8x16 slot 0 on $1000", tall, []uint8{0x03}, []int{273}},
+		{"8x16 mixed tables clock twice", tall, []uint8{0x02, 0x03, 0x02, 0x02}, []int{281, 305}},
+		{"8x16 BG $1000, last slot on $0000", tall | bg1000,
+			[]uint8{0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x00}, []int{337}},
+		{"8x16 BG $1000, no sprites", tall | bg1000, nil, nil},
+	} {
+		got := a12TicksFor(tc.ctrl, tc.tiles...)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: ticks at %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: ticks at %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+// Rendering switched on after being off leaves A12 low, so with BG=$1000
+// the first BG fetch clocks the counter on top of the prefetch's clock.
+func TestA12RiseAfterRenderingOff(t *testing.T) {
+	p := New(memory.New())
+	p.Reset()
+	cart := &tickCart{patternCart: patternCart{make([]uint8, 0x2000)}, p: p}
+	p.SetCartridge(cart)
+	p.WriteRegister(0x2000, PPUCTRLBGTable)
+	p.WriteRegister(0x2001, PPUMASKBGShow|PPUMASKSpriteShow)
+	frame := func() {
+		for i := 0; i < 341*262; i++ {
+			p.StepN(1)
+		}
+	}
+	frame()
+	frame()
+	p.WriteRegister(0x2001, 0)
+	p.WriteRegister(0x2001, PPUMASKBGShow|PPUMASKSpriteShow)
+	frame()
+	want := []int{17, 337, 337, 17, 337}
+	if len(cart.ticks) != len(want) {
+		t.Fatalf("line 0 ticks at %v, want %v", cart.ticks, want)
+	}
+	for i := range want {
+		if cart.ticks[i] != want[i] {
+			t.Fatalf("line 0 ticks at %v, want %v", cart.ticks, want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/memory"
+)
+
+// newFetchPPU renders a frame-settled scene for fetch-timing tests: tile 1
+// is solid colour 1 in the $0000 table and solid colour 2 in the $1000
+// table, tile 2 solid colour 3 in both; nametable 0 is all tile 1 and
+// nametable 1 (vertical mirroring) all tile 2. The beam is parked at the
+// start of the pre-render line.
+func newFetchPPU(fast bool) *PPU {
+	chr := make([]uint8, 0x2000)
+	for i := 0; i < 8; i++ {
+		chr[0x0010+i] = 0xFF                      // $0000 tile 1: colour 1
+		chr[0x1018+i] = 0xFF                      // $1000 tile 1: colour 2
+		chr[0x0020+i], chr[0x0028+i] = 0xFF, 0xFF // tile 2: colour 3
+		chr[0x1020+i], chr[0x1028+i] = 0xFF, 0xFF
+	}
+	p := New(memory.New())
+	p.Reset()
+	p.SetCartridge(patternCart{chr})
+	p.SetScanlineRenderer(fast)
+	for a := uint16(0); a < 0x3C0; a++ {
+		p.writeVRAM(0x2000+a, 1)
+		p.writeVRAM(0x2400+a, 2)
+	}
+	p.writeVRAM(0x3F00, 0x0F)
+	p.writeVRAM(0x3F01, 0x16)
+	p.writeVRAM(0x3F02, 0x2A)
+	p.writeVRAM(0x3F03, 0x30)
+	p.WriteRegister(0x2001, PPUMASKBGShow|PPUMASKBGLeft)
+	runFrame(p, nil)
+	return p
+}
+
+// TestMidLinePatternTableSwitch: a $2000 write changes the pattern table
+// for the tiles whose fetch slot ends after it — two tiles ahead of the
+// beam — not for the tile the beam happens to be drawing.
+func TestMidLinePatternTableSwitch(t *testing.T) {
+	for _, fast := range []bool{false, true} {
+		p := newFetchPPU(fast)
+		runFrame(p, []midLineWrite{{line: 100, dot: 50, addr: 0x2000, value: PPUCTRLBGTable}})
+		old := p.PaletteManager.GetBackgroundColor(0, 1)
+		now := p.PaletteManager.GetBackgroundColor(0, 2)
+		// Tile k's slot ends at dot 8(k-2)+7, so tile 8 (x=64) is the
+		// first fetched after dot 50.
+		for _, tc := range []struct {
+			x    int
+			want uint32
+		}{{0, old}, {50, old}, {63, old}, {64, now}, {255, now}} {
+			if got := p.FrameBuffer[100*256+tc.x]; got != tc.want {
+				t.Errorf("fast=%v x=%d: %08X, want %08X", fast, tc.x, got, tc.want)
+			}
+		}
+		if got := p.FrameBuffer[101*256]; got != now {
+			t.Errorf("fast=%v: next line %08X, want %08X", fast, got, now)
+		}
+	}
+}
+
+// TestHBlankNametableSwitch: v takes t's horizontal bits at dot 257, so a
+// $2000 nametable write before it shows on the next line and one after it
+// — where an IRQ handler's write usually lands — on the line after that.
+func TestHBlankNametableSwitch(t *testing.T) {
+	for _, fast := range []bool{false, true} {
+		for _, tc := range []struct {
+			dot       int
+			firstLine int
+		}{{250, 101}, {300, 102}} {
+			p := newFetchPPU(fast)
+			runFrame(p, []midLineWrite{{line: 100, dot: tc.dot, addr: 0x2000, value: 0x01}})
+			nt0 := p.PaletteManager.GetBackgroundColor(0, 1)
+			nt1 := p.PaletteManager.GetBackgroundColor(0, 3)
+			for line := 100; line <= 103; line++ {
+				want := nt0
+				if line >= tc.firstLine {
+					want = nt1
+				}
+				if got := p.FrameBuffer[line*256+128]; got != want {
+					t.Errorf("fast=%v write at dot %d: line %d %08X, want %08X", fast, tc.dot, line, got, want)
+				}
+			}
+		}
+	}
+}
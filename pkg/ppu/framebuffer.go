@@ -0,0 +1,63 @@
+package ppu
+
+// Frame output. The PPU draws into FrameBuffer as 0xAARRGGBB words, the
+// native format, which frontends that can take it (SDL's ARGB8888
+// textures) use as is. The conversions below serve the rest: RGBA bytes
+// for image encoders and GL-style uploads, RGB565 for frontends short on
+// memory or bandwidth (wasm, mobile). They're exported for frames that
+// didn't come straight from a PPU — one with an overlay drawn on a copy,
+// say.
+
+// FrameBufferARGB returns the framebuffer itself, 256*240 0xAARRGGBB
+// pixels, row by row. It isn't a copy: the PPU keeps drawing into it.
+func (p *PPU) FrameBufferARGB() []uint32 { return p.FrameBuffer[:] }
+
+// GetFramebuffer returns the current framebuffer as RGBA bytes in a new
+// slice. Per-frame callers should use GetFramebufferInto.
+func (p *PPU) GetFramebuffer() []uint8 {
+	return p.GetFramebufferInto(nil)
+}
+
+// GetFramebufferInto converts the current framebuffer to RGBA bytes in
+// dst, reusing its storage when it has room for 256*240*4 bytes, and
+// returns the filled slice.
+func (p *PPU) GetFramebufferInto(dst []uint8) []uint8 {
+	return ARGBToRGBA(dst, p.FrameBuffer[:])
+}
+
+// GetFramebufferRGB565Into converts the current framebuffer to RGB565
+// in dst, reusing its storage when it has room for 256*240 pixels, and
+// returns the filled slice.
+func (p *PPU) GetFramebufferRGB565Into(dst []uint16) []uint16 {
+	return ARGBToRGB565(dst, p.FrameBuffer[:])
+}
+
+// ARGBToRGBA converts 0xAARRGGBB pixels to R, G, B, A bytes in dst,
+// reusing its storage when it has room, and returns the filled slice.
+func ARGBToRGBA(dst []uint8, src []uint32) []uint8 {
+	size := len(src) * 4
+	if cap(dst) < size {
+		dst = make([]uint8, size)
+	}
+	rgba := dst[:size]
+	for i, px := range src {
+		o := rgba[i*4 : i*4+4 : i*4+4]
+		o[0], o[1], o[2], o[3] = uint8(px>>16), uint8(px>>8), uint8(px), uint8(px>>24)
+	}
+	return rgba
+}
+
+// ARGBToRGB565 converts 0xAARRGGBB pixels to RGB565 (red in the top 5
+// bits) in dst, reusing its storage when it has room, and returns the
+// filled slice. Alpha is dropped; the low bits of each channel are
+// truncated.
+func ARGBToRGB565(dst []uint16, src []uint32) []uint16 {
+	if cap(dst) < len(src) {
+		dst = make([]uint16, len(src))
+	}
+	out := dst[:len(src)]
+	for i, px := range src {
+		out[i] = uint16(px>>8&0xF800 | px>>5&0x07E0 | px>>3&0x001F)
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/memory"
+)
+
+func TestFrameBufferARGB(t *testing.T) {
+	p := New(memory.New())
+	fb := p.FrameBufferARGB()
+	if len(fb) != ScreenWidth*ScreenHeight {
+		t.Fatalf("len = %d", len(fb))
+	}
+	p.FrameBuffer[5] = 0xFF123456
+	if fb[5] != 0xFF123456 {
+		t.Error("FrameBufferARGB should share the PPU's buffer, not copy it")
+	}
+}
+
+func TestARGBToRGB565(t *testing.T) {
+	for _, tc := range []struct {
+		argb uint32
+		want uint16
+	}{
+		{0xFF000000, 0x0000},
+		{0xFFFFFFFF, 0xFFFF},
+		{0xFFFF0000, 0xF800},
+		{0xFF00FF00, 0x07E0},
+		{0xFF0000FF, 0x001F},
+		{0x00F8FCF8, 0xFFFF}, // alpha dropped, only the low bits short
+		{0xFF070307, 0x0000},
+		{0xFF102030, 0x1106},
+	} {
+		if got := ARGBToRGB565(nil, []uint32{tc.argb})[0]; got != tc.want {
+			t.Errorf("ARGBToRGB565(%08X) = %04X, want %04X", tc.argb, got, tc.want)
+		}
+	}
+
+	p := New(memory.New())
+	buf := make([]uint16, 0, ScreenWidth*ScreenHeight)
+	out := p.GetFramebufferRGB565Into(buf)
+	if len(out) != ScreenWidth*ScreenHeight || &out[0] != &buf[:1][0] {
+		t.Error("GetFramebufferRGB565Into should fill dst when it has room")
+	}
+	if allocs := testing.AllocsPerRun(10, func() { out = p.GetFramebufferRGB565Into(out) }); allocs != 0 {
+		t.Errorf("GetFramebufferRGB565Into: %.1f allocations, want 0", allocs)
+	}
+}
+
+func TestARGBToRGBA(t *testing.T) {
+	got := ARGBToRGBA(nil, []uint32{0x80112233, 0xFFAABBCC})
+	want := []uint8{0x11, 0x22, 0x33, 0x80, 0xAA, 0xBB, 0xCC, 0xFF}
+	if string(got) != string(want) {
+		t.Errorf("ARGBToRGBA = % X, want % X", got, want)
+	}
+}
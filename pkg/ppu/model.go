@@ -0,0 +1,121 @@
+package ppu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Model is the PPU chip the picture comes from. Home consoles have the
+// composite-video 2C02; VS. System and PlayChoice-10 boards have RGB PPUs
+// with a fixed palette of their own, which for the 2C04 variants is also
+// shuffled so a game only looks right on the chip it was written for.
+type Model uint8
+
+const (
+	Model2C02      Model = iota // NES/Famicom, composite video
+	Model2C03                   // RGB, the 2C02's colour order
+	Model2C04_0001              // RGB, scrambled palette, four variants
+	Model2C04_0002
+	Model2C04_0003
+	Model2C04_0004
+)
+
+var modelNames = [...]string{"2c02", "2c03", "2c04-0001", "2c04-0002", "2c04-0003", "2c04-0004"}
+
+func (m Model) String() string {
+	if int(m) < len(modelNames) {
+		return modelNames[m]
+	}
+	return fmt.Sprintf("Model(%d)", uint8(m))
+}
+
+// ParseModel returns the Model named s ("2c03", "2c04-0001", …, as
+// String gives them; case and a leading "rp" don't matter).
+func ParseModel(s string) (Model, error) {
+	name := strings.TrimPrefix(strings.ToLower(s), "rp")
+	for i, n := range modelNames {
+		if name == n {
+			return Model(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown PPU %q (want one of %s)", s, strings.Join(modelNames[:], ", "))
+}
+
+// VSModel returns the PPU of a VS. System game from its NES 2.0 header
+// (byte 13 bits 0-3). The 2C05s share the 2C03's palette; their swapped
+// $2000/$2001 and $2002 ID bits aren't emulated.
+func VSModel(ppuType uint8) Model {
+	switch ppuType {
+	case 2, 3, 4, 5:
+		return Model2C04_0001 + Model(ppuType-2)
+	default:
+		return Model2C03
+	}
+}
+
+// rgb reports whether m is one of the RGB PPUs, whose emphasis bits
+// drive a channel to full instead of dimming the other two.
+func (m Model) rgb() bool { return m != Model2C02 }
+
+// Colors returns the 64 colours palette indices select on m.
+func (m Model) Colors() [64][3]uint8 {
+	switch {
+	case m == Model2C02:
+		return masterPalette
+	case m >= Model2C04_0001 && m <= Model2C04_0004:
+		var colors [64][3]uint8
+		for i, j := range rp2c04LUT[m-Model2C04_0001] {
+			colors[i] = rgbPalette[j]
+		}
+		return colors
+	default:
+		return rgbPalette
+	}
+}
+
+// rgbPalette is the 2C03's palette. The chip has a 3-bit DAC per
+// channel; the NESdev wiki's values, scaled to 8 bits.
+var rgbPalette = rgb333([64]uint16{
+	0333, 0014, 0006, 0326, 0403, 0503, 0510, 0420, 0320, 0120, 0031, 0040, 0022, 0000, 0000, 0000,
+	0555, 0036, 0027, 0407, 0507, 0704, 0700, 0630, 0430, 0140, 0040, 0053, 0044, 0000, 0000, 0000,
+	0777, 0357, 0447, 0637, 0707, 0737, 0740, 0750, 0660, 0360, 0070, 0276, 0077, 0000, 0000, 0000,
+	0777, 0567, 0657, 0757, 0747, 0755, 0764, 0772, 0773, 0572, 0473, 0276, 0467, 0000, 0000, 0000,
+})
+
+func rgb333(octal [64]uint16) (colors [64][3]uint8) {
+	for i, v := range octal {
+		for c := 0; c < 3; c++ {
+			colors[i][c] = uint8((v >> (6 - 3*c) & 7) * 255 / 7)
+		}
+	}
+	return colors
+}
+
+// rp2c04LUT gives, for each 2C04, the 2C03 palette entry every index
+// shows.
+var rp2c04LUT = [4][64]uint8{
+	{ // RP2C04-0001
+		0x35, 0x23, 0x16, 0x22, 0x1C, 0x09, 0x1D, 0x15, 0x20, 0x00, 0x27, 0x05, 0x04, 0x28, 0x08, 0x20,
+		0x21, 0x3E, 0x1F, 0x29, 0x3C, 0x32, 0x36, 0x12, 0x3F, 0x2B, 0x2E, 0x1E, 0x3D, 0x2D, 0x24, 0x01,
+		0x0E, 0x31, 0x33, 0x2A, 0x2C, 0x0C, 0x1B, 0x14, 0x2E, 0x07, 0x34, 0x06, 0x13, 0x02, 0x26, 0x2E,
+		0x2E, 0x19, 0x10, 0x0A, 0x39, 0x03, 0x37, 0x17, 0x0F, 0x11, 0x0B, 0x0D, 0x38, 0x25, 0x18, 0x3A,
+	},
+	{ // RP2C04-0002
+		0x2E, 0x27, 0x18, 0x39, 0x3A, 0x25, 0x1C, 0x31, 0x16, 0x13, 0x38, 0x34, 0x20, 0x23, 0x3C, 0x0B,
+		0x0F, 0x21, 0x06, 0x3D, 0x1B, 0x29, 0x1E, 0x22, 0x1D, 0x24, 0x0E, 0x2B, 0x32, 0x08, 0x2E, 0x03,
+		0x04, 0x36, 0x26, 0x33, 0x11, 0x1F, 0x10, 0x02, 0x14, 0x3F, 0x00, 0x09, 0x12, 0x2E, 0x28, 0x20,
+		0x3E, 0x0D, 0x2A, 0x17, 0x0C, 0x01, 0x15, 0x19, 0x2E, 0x2C, 0x07, 0x37, 0x35, 0x05, 0x0A, 0x2D,
+	},
+	{ // RP2C04-0003
+		0x14, 0x25, 0x3A, 0x10, 0x0B, 0x20, 0x31, 0x09, 0x01, 0x2E, 0x36, 0x08, 0x15, 0x3D, 0x3E, 0x3C,
+		0x22, 0x1C, 0x05, 0x12, 0x19, 0x18, 0x17, 0x1B, 0x00, 0x03, 0x2E, 0x02, 0x16, 0x06, 0x34, 0x35,
+		0x23, 0x0F, 0x0E, 0x37, 0x0D, 0x27, 0x26, 0x20, 0x29, 0x04, 0x21, 0x24, 0x11, 0x2D, 0x2E, 0x1F,
+		0x2C, 0x1E, 0x39, 0x33, 0x07, 0x2A, 0x28, 0x1D, 0x0A, 0x2E, 0x32, 0x38, 0x13, 0x2B, 0x3F, 0x0C,
+	},
+	{ // RP2C04-0004
+		0x18, 0x03, 0x1C, 0x28, 0x2E, 0x35, 0x01, 0x17, 0x10, 0x1F, 0x2A, 0x0E, 0x36, 0x37, 0x0B, 0x39,
+		0x25, 0x1E, 0x12, 0x34, 0x2E, 0x1D, 0x06, 0x26, 0x3E, 0x1B, 0x22, 0x19, 0x04, 0x2E, 0x3A, 0x21,
+		0x05, 0x0A, 0x07, 0x02, 0x13, 0x14, 0x00, 0x15, 0x0C, 0x3D, 0x11, 0x0F, 0x0D, 0x38, 0x2D, 0x24,
+		0x33, 0x20, 0x08, 0x16, 0x3F, 0x2B, 0x20, 0x3C, 0x2E, 0x27, 0x23, 0x31, 0x29, 0x32, 0x2C, 0x09,
+	},
+}
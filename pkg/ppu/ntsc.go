@@ -0,0 +1,135 @@
+package ppu
+
+import "math"
+
+// A 2C02 has no palette: it puts out a composite signal, and the colours
+// are whatever the TV's decoder makes of it. Each palette index is a
+// square wave over the 12 phases of the colour subcarrier, switching
+// between a low and a high voltage picked by the luma bits; the hue bits
+// choose which six phases are high. Emphasis pulls the signal down during
+// the phases opposite the colour it names. NTSC generates the colours by
+// sampling that wave and decoding it as YIQ, with the picture controls a
+// TV has in front of the decoder. The voltages are the NESdev wiki's
+// measurements ("NTSC video").
+
+// NTSC is a TV's picture controls for a palette generated from the
+// 2C02's signal. The zero value is not useful; start from DefaultNTSC.
+type NTSC struct {
+	// Hue rotates every colour, in degrees (the tint knob).
+	Hue float64
+	// Saturation scales the colour signal; 1 is unchanged, 0 grey.
+	Saturation float64
+	// Brightness is added to every colour, in fractions of white.
+	Brightness float64
+	// Contrast scales the whole signal around black; 1 is unchanged.
+	Contrast float64
+	// Gamma is the TV's gamma. Colours are converted from it to the
+	// 2.2 of an sRGB display, so 2.2 leaves them as decoded and larger
+	// values darken the mid-tones.
+	Gamma float64
+}
+
+// DefaultNTSC is a TV with its controls in the middle.
+var DefaultNTSC = NTSC{Saturation: 1, Contrast: 1, Gamma: 2.2}
+
+// Composite levels in volts: the low and high of luma 0-3, black and
+// white. emphasisAttenuation is how far an emphasised phase is pulled
+// down.
+var (
+	ntscLow  = [4]float64{0.350, 0.518, 0.962, 1.550}
+	ntscHigh = [4]float64{1.094, 1.506, 1.962, 1.962}
+)
+
+const (
+	ntscBlack           = 0.518
+	ntscWhite           = 1.962
+	emphasisAttenuation = 0.746
+	// ntscHueOffset lines the decoder's colour burst up with the 2C02's,
+	// in twelfths of a subcarrier cycle: the phase that best matches the
+	// built-in palette.
+	ntscHueOffset = 3.75
+)
+
+// inColorPhase reports whether hue's wave is high on phase.
+func inColorPhase(hue, phase int) bool { return (hue+phase)%12 < 6 }
+
+// emphasisPhases are the hues whose phases each emphasis bit (red, green,
+// blue) attenuates: the colour opposite the one it brings out.
+var emphasisPhases = [3]int{0xC, 0x4, 0x8}
+
+// Colors returns the colours of every palette index under every emphasis
+// combination (PPUMASK bits 5-7 as 0-7).
+func (n NTSC) Colors() (colors [8][64][3]uint8) {
+	for em := range colors {
+		for idx := range colors[em] {
+			colors[em][idx] = n.decode(idx, em)
+		}
+	}
+	return colors
+}
+
+// decode samples index's signal under emphasis em over a subcarrier
+// cycle and converts it to RGB.
+func (n NTSC) decode(index, em int) [3]uint8 {
+	hue, luma := index&0x0F, index>>4
+	lo, hi := ntscLow[luma], ntscHigh[luma]
+	switch {
+	case hue == 0x0:
+		lo = hi
+	case hue == 0xD:
+		hi = lo
+	case hue >= 0xE:
+		lo, hi = ntscBlack, ntscBlack
+	}
+	var y, i, q float64
+	for phase := 0; phase < 12; phase++ {
+		v := lo
+		if inColorPhase(hue, phase) {
+			v = hi
+		}
+		if hue < 0xE {
+			for bit, h := range emphasisPhases {
+				if em&(1<<bit) != 0 && inColorPhase(h, phase) {
+					v *= emphasisAttenuation
+					break
+				}
+			}
+		}
+		v = (v - ntscBlack) / (ntscWhite - ntscBlack)
+		angle := math.Pi * (float64(phase) + ntscHueOffset + n.Hue/30) / 6
+		y += v
+		i += v * math.Cos(angle)
+		q += v * math.Sin(angle)
+	}
+	y = y/12*n.Contrast + n.Brightness
+	i *= n.Saturation * n.Contrast / 6
+	q *= n.Saturation * n.Contrast / 6
+	return [3]uint8{
+		n.gamma(y + 0.946882*i + 0.623557*q),
+		n.gamma(y - 0.274788*i - 0.635691*q),
+		n.gamma(y - 1.108545*i + 1.709007*q),
+	}
+}
+
+// gamma converts a decoded channel (0-1) to an 8-bit sRGB value.
+func (n NTSC) gamma(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 0xFF
+	}
+	return uint8(math.Pow(v, n.Gamma/2.2)*255 + 0.5)
+}
+
+// buildNTSCLUT builds a PaletteManager table from n. Emphasis is already
+// in its colours, so it isn't applied again.
+func buildNTSCLUT(n NTSC) (lut [8][64]uint32) {
+	colors := n.Colors()
+	for em := range lut {
+		for idx, c := range colors[em] {
+			lut[em][idx] = 0xFF000000 | uint32(c[0])<<16 | uint32(c[1])<<8 | uint32(c[2])
+		}
+	}
+	return lut
+}
@@ -0,0 +1,129 @@
+package ppu
+
+import "testing"
+
+func TestNTSCColors(t *testing.T) {
+	colors := DefaultNTSC.Colors()
+	plain := colors[0]
+	if plain[0x0F] != [3]uint8{} || plain[0x1E] != [3]uint8{} {
+		t.Errorf("$0F = %v, $1E = %v, want black", plain[0x0F], plain[0x1E])
+	}
+	if plain[0x20] != [3]uint8{0xFF, 0xFF, 0xFF} {
+		t.Errorf("$20 = %v, want white", plain[0x20])
+	}
+	// The grey columns carry no colour.
+	for _, idx := range []int{0x00, 0x10, 0x2D, 0x3D} {
+		if c := plain[idx]; c[0] != c[1] || c[1] != c[2] {
+			t.Errorf("$%02X = %v, want grey", idx, c)
+		}
+	}
+
+	// The controls in the middle land near the built-in palette.
+	var sum, n int
+	for idx := 0; idx < 64; idx++ {
+		if idx&0x0F >= 0x0D {
+			continue
+		}
+		for ch := 0; ch < 3; ch++ {
+			d := int(plain[idx][ch]) - int(masterPalette[idx][ch])
+			sum += d * d
+			n++
+		}
+	}
+	if ms := sum / n; ms > 40*40 {
+		t.Errorf("default NTSC palette is %d (mean square) from the built-in one", ms)
+	}
+
+	// Red emphasis dims $00's cyan phases.
+	if c := colors[1][0x00]; c[0] <= c[1] || c[0] <= c[2] || c[1] >= plain[0x00][1] {
+		t.Errorf("$00 with red emphasis = %v, want red-tinted and darker than %v", c, plain[0x00])
+	}
+	// Emphasis leaves $xE/$xF black.
+	if c := colors[7][0x0E]; c != [3]uint8{} {
+		t.Errorf("$0E with all emphasis = %v, want black", c)
+	}
+}
+
+func TestNTSCControls(t *testing.T) {
+	base := DefaultNTSC.Colors()[0]
+	with := func(f func(*NTSC)) [64][3]uint8 {
+		n := DefaultNTSC
+		f(&n)
+		return n.Colors()[0]
+	}
+
+	// A hue turn of 30° is one hue step: each colour becomes its
+	// neighbour's.
+	turned := with(func(n *NTSC) { n.Hue = 30 })
+	for idx := 0x21; idx <= 0x2B; idx++ {
+		if turned[idx+1] != base[idx] {
+			t.Errorf("$%02X turned 30° = %v, want $%02X's %v", idx+1, turned[idx+1], idx, base[idx])
+		}
+	}
+
+	grey := with(func(n *NTSC) { n.Saturation = 0 })
+	for idx, c := range grey {
+		if c[0] != c[1] || c[1] != c[2] {
+			t.Fatalf("$%02X with no saturation = %v, want grey", idx, c)
+		}
+	}
+
+	brighter := with(func(n *NTSC) { n.Brightness = 0.1 })
+	darker := with(func(n *NTSC) { n.Gamma = 2.8 })
+	flat := with(func(n *NTSC) { n.Contrast = 0.5 })
+	if b, g := brighter[0x00][0], darker[0x00][0]; b <= base[0x00][0] || g >= base[0x00][0] {
+		t.Errorf("$00 = %d; brightness +0.1 gives %d, gamma 2.8 %d", base[0x00][0], b, g)
+	}
+	if c := flat[0x20][0]; c >= 0xFF || c < 0x70 {
+		t.Errorf("$20 at half contrast = %d, want about half white", c)
+	}
+}
+
+func TestSetNTSC(t *testing.T) {
+	pm := NewPaletteManager()
+	pm.WritePalette(0x01, 0x16)
+	builtIn := pm.GetBackgroundColor(0, 1)
+
+	n := DefaultNTSC
+	n.Hue = 20
+	pm.SetNTSC(&n)
+	want := buildNTSCLUT(n)
+	if got := pm.GetBackgroundColor(0, 1); got != want[0][0x16] || got == builtIn {
+		t.Errorf("NTSC colour $16 = %08X, want %08X", got, want[0][0x16])
+	}
+	// Emphasis comes from the signal, not the fixed-palette dimming.
+	pm.SetEmphasis(0x40)
+	if got := pm.GetBackgroundColor(0, 1); got != want[2][0x16] {
+		t.Errorf("NTSC colour $16 with green emphasis = %08X, want %08X", got, want[2][0x16])
+	}
+	pm.SetEmphasis(0)
+
+	// The manager keeps its own copy of the controls.
+	n.Hue = 0
+	if got := pm.NTSC(); got == nil || got.Hue != 20 {
+		t.Errorf("NTSC() = %+v, want hue 20", got)
+	}
+
+	// A palette file loaded meanwhile waits until NTSC is off.
+	var colors [64][3]uint8
+	colors[0x16] = [3]uint8{1, 2, 3}
+	pm.SetPalette(&colors)
+	if got := pm.GetBackgroundColor(0, 1); got != want[0][0x16] {
+		t.Errorf("SetPalette overrode NTSC: %08X", got)
+	}
+	pm.SetNTSC(nil)
+	if got, want := pm.GetBackgroundColor(0, 1), buildARGBLUT(&colors, false)[0][0x16]; got != want {
+		t.Errorf("after SetNTSC(nil) $16 = %08X, want the file's %08X", got, want)
+	}
+	pm.SetPalette(nil)
+	if got := pm.GetBackgroundColor(0, 1); got != builtIn || pm.NTSC() != nil {
+		t.Errorf("after SetNTSC(nil), SetPalette(nil) $16 = %08X, want built-in %08X", got, builtIn)
+	}
+
+	// RGB PPUs have no composite signal to decode.
+	pm.SetNTSC(&DefaultNTSC)
+	pm.SetModel(Model2C03)
+	if got, want := pm.GetBackgroundColor(0, 1), buildARGBLUT(&rgbPalette, true)[0][0x16]; got != want {
+		t.Errorf("2C03 with NTSC on = %08X, want its own %08X", got, want)
+	}
+}
@@ -1,6 +1,10 @@
 package ppu
 
-import "github.com/yoshiomiyamaegones/pkg/logger"
+import (
+	"fmt"
+
+	"github.com/yoshiomiyamaegones/pkg/logger"
+)
 
 // NES master palette - 64 colors total
 // Each color is represented as RGB values
@@ -41,6 +45,10 @@ type PaletteManager struct {
 	// Emphasis bits for color modification
 	Emphasis uint8 // bits 5-7 of PPUMASK
 
+	// Greyscale mirrors PPUMASK bit 0: palette output is ANDed with $30,
+	// leaving only the grey column of the master palette.
+	Greyscale bool
+
 	// bgColorCache / sprColorCache hold the final ARGB color for every
 	// (palette<<2 | colorIndex) pair, with palette-mirroring, the
 	// colorIndex-0 backdrop/transparent rule, and emphasis already folded in.
@@ -49,11 +57,27 @@ type PaletteManager struct {
 	// rebuildColorCache whenever palette RAM or emphasis changes (rare).
 	bgColorCache  [16]uint32
 	sprColorCache [16]uint32
+
+	// lut is the emphasis × index → ARGB table colours come from: the
+	// shared argbLUT for the built-in palette on a 2C02, else one built by
+	// SetPalette, SetModel or SetNTSC from colors, model and ntsc.
+	lut    *[8][64]uint32
+	colors *[64][3]uint8
+	model  Model
+	ntsc   *NTSC
 }
 
 // NewPaletteManager creates a new palette manager
 func NewPaletteManager() *PaletteManager {
-	pm := &PaletteManager{}
+	pm := &PaletteManager{lut: &argbLUT}
+	pm.powerOnRAM()
+	logger.LogPPU("PaletteManager initialized with debugging colors")
+	return pm
+}
+
+// powerOnRAM puts palette RAM in its power-up state and rebuilds the colour
+// caches from it.
+func (pm *PaletteManager) powerOnRAM() {
 	// Initialize palette RAM with proper power-up state
 	// Universal backdrop should be a reasonable default color
 	pm.PaletteRAM[0] = 0x0F // Universal backdrop (black/dark gray)
@@ -70,63 +94,53 @@ func NewPaletteManager() *PaletteManager {
 	pm.PaletteRAM[2] = 0x10 // Light gray
 	pm.PaletteRAM[3] = 0x00 // Dark gray
 
-	logger.LogPPU("PaletteManager initialized with debugging colors")
 	pm.rebuildColorCache()
-	return pm
 }
 
-// ReadPalette reads a palette value with mirroring
-func (pm *PaletteManager) ReadPalette(addr uint8) uint8 {
-	addr = addr & 0x1F // Ensure within palette range
-
-	// Handle mirroring of backdrop colors
-	// $10, $14, $18, $1C mirror to $00, $04, $08, $0C respectively
-	if addr == 0x10 {
-		addr = 0x00
-	} else if addr == 0x14 {
-		addr = 0x04
-	} else if addr == 0x18 {
-		addr = 0x08
-	} else if addr == 0x1C {
-		addr = 0x0C
+// paletteIndex maps a palette address to its PaletteRAM slot. $10, $14,
+// $18 and $1C are not separate cells: they mirror $00, $04, $08 and $0C,
+// so writing a sprite palette's "colour 0" changes the BG backdrop.
+func paletteIndex(addr uint8) uint8 {
+	addr &= 0x1F
+	if addr&0x13 == 0x10 {
+		addr &^= 0x10
 	}
+	return addr
+}
 
-	value := pm.PaletteRAM[addr]
-
+// ReadPalette reads a palette value with mirroring, as the PPU sees it:
+// with PPUMASK greyscale set, the low four bits read back as zero (the
+// mask is applied on the way out of palette RAM, so it also affects
+// $2007 palette reads, not just the rendered colour).
+func (pm *PaletteManager) ReadPalette(addr uint8) uint8 {
+	value := pm.PaletteRAM[paletteIndex(addr)]
+	if pm.Greyscale {
+		value &= 0x30
+	}
 	return value
 }
 
+// ReadPaletteRaw returns the stored palette entry (with mirroring) without
+// the greyscale mask. Intended for debug viewers, which want the value the
+// game wrote rather than what the PPU is currently outputting.
+func (pm *PaletteManager) ReadPaletteRaw(addr uint8) uint8 {
+	return pm.PaletteRAM[paletteIndex(addr)]
+}
+
 // WritePalette writes a palette value with mirroring
 func (pm *PaletteManager) WritePalette(addr uint8, value uint8) {
-	addr = addr & 0x1F // Ensure within palette range
-
-	if logger.PPUEnabled() {
-		logger.LogPPU("WritePalette: original addr=$%02X, value=$%02X", addr, value)
-	}
-
-	// Handle mirroring of backdrop colors
-	// $10, $14, $18, $1C mirror to $00, $04, $08, $0C respectively
-	if addr == 0x10 {
-		addr = 0x00
-	} else if addr == 0x14 {
-		addr = 0x04
-	} else if addr == 0x18 {
-		addr = 0x08
-	} else if addr == 0x1C {
-		addr = 0x0C
-	}
-
 	if logger.PPUEnabled() {
-		logger.LogPPU("WritePalette: final addr=$%02X, value=$%02X", addr, value)
+		logger.LogPPU("WritePalette: addr=$%02X -> $%02X, value=$%02X", addr&0x1F, paletteIndex(addr), value)
 	}
-	pm.PaletteRAM[addr] = value & 0x3F // Only 6 bits used
+	pm.PaletteRAM[paletteIndex(addr)] = value & 0x3F // Only 6 bits used
 	pm.rebuildColorCache()
 }
 
 // rebuildColorCache recomputes bgColorCache / sprColorCache from the current
 // palette RAM and emphasis. Folds in the colorIndex-0 rule (BG color 0 of
-// every palette is the universal backdrop; sprite color 0 is transparent) and
-// the $10/$14/$18/$1C backdrop mirroring done by ReadPalette. Cheap (32
+// every palette is the universal backdrop; sprite color 0 is transparent),
+// the $10/$14/$18/$1C backdrop mirroring and the greyscale mask, both done
+// by ReadPalette. Cheap (32
 // entries) and only called when palette RAM or emphasis changes.
 func (pm *PaletteManager) rebuildColorCache() {
 	for pal := uint8(0); pal < 4; pal++ {
@@ -167,31 +181,126 @@ func (pm *PaletteManager) GetSpriteColor(palette uint8, colorIndex uint8) uint32
 // value for every (palette index, emphasis-bit-combination) pair. The
 // 3-bit emphasis index packs PPUMASK bits 5-7 (red/green/blue) into
 // bits 0-2. Lookup replaces the per-pixel float32 channel dimming.
-var argbLUT [8][64]uint32
+var argbLUT = buildARGBLUT(&masterPalette, false)
 
-func init() {
+// buildARGBLUT builds the table for colors. On a composite PPU emphasis
+// dims the channels it doesn't name; on an RGB one (rgb) it drives the
+// channels it names to full.
+func buildARGBLUT(colors *[64][3]uint8, rgb bool) (lut [8][64]uint32) {
 	for em := 0; em < 8; em++ {
 		for idx := 0; idx < 64; idx++ {
-			r := masterPalette[idx][0]
-			g := masterPalette[idx][1]
-			b := masterPalette[idx][2]
-			if em&0x1 == 0 {
-				r = uint8(float32(r) * 0.75)
-			}
-			if em&0x2 == 0 {
-				g = uint8(float32(g) * 0.75)
-			}
-			if em&0x4 == 0 {
-				b = uint8(float32(b) * 0.75)
+			r := colors[idx][0]
+			g := colors[idx][1]
+			b := colors[idx][2]
+			if rgb {
+				if em&0x1 != 0 {
+					r = 0xFF
+				}
+				if em&0x2 != 0 {
+					g = 0xFF
+				}
+				if em&0x4 != 0 {
+					b = 0xFF
+				}
+			} else {
+				if em&0x1 == 0 {
+					r = uint8(float32(r) * 0.75)
+				}
+				if em&0x2 == 0 {
+					g = uint8(float32(g) * 0.75)
+				}
+				if em&0x4 == 0 {
+					b = uint8(float32(b) * 0.75)
+				}
 			}
-			argbLUT[em][idx] = 0xFF000000 | uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+			lut[em][idx] = 0xFF000000 | uint32(r)<<16 | uint32(g)<<8 | uint32(b)
 		}
 	}
+	return lut
 }
 
 // getARGBColor converts a 6-bit palette index to 32-bit ARGB color.
 func (pm *PaletteManager) getARGBColor(paletteIndex uint8) uint32 {
-	return argbLUT[pm.Emphasis>>5][paletteIndex&0x3F]
+	return pm.lut[pm.Emphasis>>5][paletteIndex&0x3F]
+}
+
+// MasterPalette returns a copy of the built-in master palette, for tools
+// that draw NES colours without a PPU (rom_analyzer's CHR sheets).
+func MasterPalette() [64][3]uint8 { return masterPalette }
+
+// ParsePalette decodes a .pal file: 64 RGB triples (192 bytes). The
+// 512-entry variant with precomputed emphasis rows (1536 bytes) is accepted
+// too; only its first 64 entries are used, since emphasis is applied here.
+func ParsePalette(data []byte) (*[64][3]uint8, error) {
+	if len(data) != 64*3 && len(data) != 512*3 {
+		return nil, fmt.Errorf("palette: %d bytes, want 192 or 1536", len(data))
+	}
+	var colors [64][3]uint8
+	for i := range colors {
+		copy(colors[i][:], data[i*3:i*3+3])
+	}
+	return &colors, nil
+}
+
+// SetPalette replaces the master palette colours are taken from; nil goes
+// back to the PPU model's own. A palette file wins over the model's
+// colours but not its emphasis (see SetModel). Not part of save-state,
+// untouched by Reset.
+func (pm *PaletteManager) SetPalette(colors *[64][3]uint8) {
+	pm.colors = colors
+	pm.rebuildLUT()
+}
+
+// SetModel switches to the palette and emphasis behaviour of PPU chip m,
+// for VS. System games, whose RGB PPUs show the colours a 2C02 would
+// get wrong. Not part of save-state, untouched by Reset.
+func (pm *PaletteManager) SetModel(m Model) {
+	pm.model = m
+	pm.rebuildLUT()
+}
+
+// Model returns the PPU chip set by SetModel.
+func (pm *PaletteManager) Model() Model { return pm.model }
+
+// SetNTSC generates the colours from the 2C02's composite signal as a TV
+// with controls n would show them, emphasis included; nil goes back to a
+// fixed palette. It wins over SetPalette, and is ignored on the RGB PPUs,
+// which have no composite output. Cheap enough to call on every turn of
+// a knob. Not part of save-state, untouched by Reset.
+func (pm *PaletteManager) SetNTSC(n *NTSC) {
+	if n != nil {
+		c := *n
+		n = &c
+	}
+	pm.ntsc = n
+	pm.rebuildLUT()
+}
+
+// NTSC returns the controls set by SetNTSC, or nil.
+func (pm *PaletteManager) NTSC() *NTSC {
+	if pm.ntsc == nil {
+		return nil
+	}
+	n := *pm.ntsc
+	return &n
+}
+
+func (pm *PaletteManager) rebuildLUT() {
+	switch {
+	case pm.ntsc != nil && !pm.model.rgb():
+		lut := buildNTSCLUT(*pm.ntsc)
+		pm.lut = &lut
+	case pm.colors == nil && pm.model == Model2C02:
+		pm.lut = &argbLUT
+	case pm.colors != nil:
+		lut := buildARGBLUT(pm.colors, pm.model.rgb())
+		pm.lut = &lut
+	default:
+		colors := pm.model.Colors()
+		lut := buildARGBLUT(&colors, true)
+		pm.lut = &lut
+	}
+	pm.rebuildColorCache()
 }
 
 // SetEmphasis sets the color emphasis bits and refreshes the color cache,
@@ -205,6 +314,16 @@ func (pm *PaletteManager) SetEmphasis(emphasis uint8) {
 	pm.rebuildColorCache()
 }
 
+// SetGreyscale sets the PPUMASK greyscale mode and refreshes the color
+// cache.
+func (pm *PaletteManager) SetGreyscale(on bool) {
+	if on == pm.Greyscale {
+		return
+	}
+	pm.Greyscale = on
+	pm.rebuildColorCache()
+}
+
 // GetPaletteDebugInfo returns debug information about current palettes
 func (pm *PaletteManager) GetPaletteDebugInfo() map[string]interface{} {
 	debug := make(map[string]interface{})
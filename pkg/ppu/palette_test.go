@@ -1,6 +1,7 @@
 package ppu
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -235,4 +236,169 @@ func TestPaletteDebugInfo(t *testing.T) {
 	if debug["emphasis"] != pm.Emphasis {
 		t.Errorf("Debug emphasis should match actual emphasis")
 	}
-}
\ No newline at end of file
+}
+
+// Test that $10/$14/$18/$1C alias $00/$04/$08/$0C in both directions,
+// while the other sprite entries stay independent of the BG ones.
+func TestPaletteMirroringBothDirections(t *testing.T) {
+	for _, base := range []uint8{0x00, 0x04, 0x08, 0x0C} {
+		pm := NewPaletteManager()
+		pm.WritePalette(0x10|base, 0x21)
+		if got := pm.ReadPalette(base); got != 0x21 {
+			t.Errorf("write $%02X: read $%02X = %02X, want 21", 0x10|base, base, got)
+		}
+		pm.WritePalette(base, 0x16)
+		if got := pm.ReadPalette(0x10 | base); got != 0x16 {
+			t.Errorf("write $%02X: read $%02X = %02X, want 16", base, 0x10|base, got)
+		}
+	}
+
+	pm := NewPaletteManager()
+	for i := uint8(1); i < 4; i++ {
+		pm.WritePalette(i, 0x01)
+		pm.WritePalette(0x10+i, 0x02)
+		if pm.ReadPalette(i) != 0x01 || pm.ReadPalette(0x10+i) != 0x02 {
+			t.Errorf("$%02X and $%02X should be separate entries", i, 0x10+i)
+		}
+	}
+
+	// Addresses above $1F wrap into the 32-byte window ($3F20-$3FFF).
+	pm.WritePalette(0x30, 0x2A)
+	if got := pm.ReadPalette(0x00); got != 0x2A {
+		t.Errorf("$30 should wrap to $10 -> $00, got %02X", got)
+	}
+
+	// The backdrop mirror is what the renderer actually draws.
+	pm.WritePalette(0x10, 0x11)
+	if pm.GetBackgroundColor(2, 0) != pm.getARGBColor(0x11) {
+		t.Error("BG colour 0 should follow a write to $10")
+	}
+}
+
+// Test greyscale masking of palette reads and rendered colours.
+func TestPaletteGreyscale(t *testing.T) {
+	pm := NewPaletteManager()
+	pm.WritePalette(0x01, 0x2A)
+	pm.WritePalette(0x11, 0x16)
+
+	pm.SetGreyscale(true)
+	if got := pm.ReadPalette(0x01); got != 0x20 {
+		t.Errorf("greyscale ReadPalette = %02X, want 20", got)
+	}
+	if got := pm.ReadPaletteRaw(0x01); got != 0x2A {
+		t.Errorf("ReadPaletteRaw = %02X, want unmasked 2A", got)
+	}
+	if got := pm.ReadPaletteRaw(0x10); got != pm.PaletteRAM[0] {
+		t.Errorf("ReadPaletteRaw should still apply backdrop mirroring, got %02X", got)
+	}
+	if pm.GetBackgroundColor(0, 1) != pm.getARGBColor(0x20) {
+		t.Error("BG colour should be drawn from the grey column")
+	}
+	if pm.GetSpriteColor(0, 1) != pm.getARGBColor(0x10) {
+		t.Error("sprite colour should be drawn from the grey column")
+	}
+
+	pm.SetGreyscale(false)
+	if pm.ReadPalette(0x01) != 0x2A || pm.GetBackgroundColor(0, 1) != pm.getARGBColor(0x2A) {
+		t.Error("clearing greyscale should restore the full colour")
+	}
+}
+
+// Test that PPUMASK bit 0 drives greyscale for $2007 palette reads and
+// survives a save-state round trip.
+func TestPPUMaskGreyscale(t *testing.T) {
+	p := createTestPPU()
+	p.WriteRegister(0x2006, 0x3F)
+	p.WriteRegister(0x2006, 0x05)
+	p.WriteRegister(0x2007, 0x37)
+
+	p.WriteRegister(0x2001, PPUMASKGreyscale)
+	p.WriteRegister(0x2006, 0x3F)
+	p.WriteRegister(0x2006, 0x05)
+	if got := p.ReadRegister(0x2007) & 0x3F; got != 0x30 {
+		t.Errorf("$2007 palette read with greyscale = %02X, want 30", got)
+	}
+
+	var buf bytes.Buffer
+	if err := p.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	q := createTestPPU()
+	if err := q.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !q.PaletteManager.Greyscale || q.PaletteManager.ReadPalette(0x05) != 0x30 {
+		t.Error("greyscale not restored from PPUMASK on LoadState")
+	}
+
+	q.Reset()
+	if q.PaletteManager.Greyscale {
+		t.Error("Reset should clear greyscale")
+	}
+}
+
+// Test loading a custom master palette from .pal data
+func TestSetPalette(t *testing.T) {
+	if _, err := ParsePalette(make([]byte, 100)); err == nil {
+		t.Error("ParsePalette should reject a 100-byte file")
+	}
+
+	data := make([]byte, 512*3)
+	data[0x21*3], data[0x21*3+1], data[0x21*3+2] = 0x12, 0x34, 0x56
+	colors, err := ParsePalette(data)
+	if err != nil {
+		t.Fatalf("ParsePalette: %v", err)
+	}
+
+	pm := NewPaletteManager()
+	pm.WritePalette(0x01, 0x21)
+	builtIn := pm.GetBackgroundColor(0, 1)
+
+	pm.SetPalette(colors)
+	if got, want := pm.GetBackgroundColor(0, 1), buildARGBLUT(colors, false)[0][0x21]; got != want || got == builtIn {
+		t.Errorf("custom palette colour = %08X, want %08X", got, want)
+	}
+	// Other managers keep the built-in palette.
+	if got := NewPaletteManager().getARGBColor(0x21); got != builtIn {
+		t.Errorf("SetPalette leaked into a new manager: %08X", got)
+	}
+
+	pm.SetPalette(nil)
+	if got := pm.GetBackgroundColor(0, 1); got != builtIn {
+		t.Errorf("SetPalette(nil) = %08X, want built-in %08X", got, builtIn)
+	}
+}
+
+// Test the VS. System PPUs' palettes and RGB emphasis
+func TestPaletteModel(t *testing.T) {
+	pm := NewPaletteManager()
+	pm.WritePalette(0x00, 0x0F)
+	pm.WritePalette(0x01, 0x21)
+
+	pm.SetModel(Model2C03)
+	if got, want := pm.GetBackgroundColor(0, 1), uint32(0xFF6DB6FF); got != want {
+		t.Errorf("2C03 colour $21 = %08X, want %08X", got, want)
+	}
+	// RP2C04-0001 shows 2C03 colour $35 for index $00.
+	pm.WritePalette(0x01, 0x00)
+	pm.SetModel(Model2C04_0001)
+	if got, want := pm.GetBackgroundColor(0, 1), buildARGBLUT(&rgbPalette, true)[0][0x35]; got != want {
+		t.Errorf("2C04-0001 colour $00 = %08X, want %08X", got, want)
+	}
+	// Red emphasis turns the black backdrop red rather than dimming.
+	pm.SetModel(Model2C03)
+	pm.SetEmphasis(0x20)
+	if got := pm.GetBackgroundColor(0, 0); got != 0xFFFF0000 {
+		t.Errorf("emphasised black on an RGB PPU = %08X, want FFFF0000", got)
+	}
+
+	if m, err := ParseModel("RP2C04-0003"); err != nil || m != Model2C04_0003 {
+		t.Errorf("ParseModel(RP2C04-0003) = %v, %v", m, err)
+	}
+	if _, err := ParseModel("2c07"); err == nil {
+		t.Error("ParseModel should reject an unknown PPU")
+	}
+	if VSModel(5) != Model2C04_0004 || VSModel(8) != Model2C03 {
+		t.Error("VSModel maps NES 2.0 Vs. PPU types wrongly")
+	}
+}
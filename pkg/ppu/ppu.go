@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"io"
 
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
 	"github.com/yoshiomiyamaegones/pkg/logger"
 	"github.com/yoshiomiyamaegones/pkg/memory"
 )
@@ -21,11 +22,10 @@ type PPU struct {
 	PPUDATA   uint8 // $2007
 
 	// Internal registers
-	v     uint16 // VRAM address
-	t     uint16 // Temporary VRAM address
-	x     uint8  // Fine X scroll
-	xTemp uint8  // Temporary fine X scroll for raster effects
-	w     uint8  // Write toggle
+	v uint16 // VRAM address
+	t uint16 // Temporary VRAM address
+	x uint8  // Fine X scroll
+	w uint8  // Write toggle
 
 	// Scrolling
 	ScrollY uint8 // Y scroll position
@@ -39,30 +39,23 @@ type PPU struct {
 	// NMI
 	NMIRequested bool
 
-	// MapperIRQ mirrors the cartridge's IRQ-pending line. nes.Step reads
-	// this every PPU cycle to set the CPU IRQ flag, so it MUST stay cheaper
-	// than an interface dispatch through Cartridge — every code path that
-	// can change mapper IRQ state (Step at the per-scanline tick,
-	// notifyCartridgeA12, and a post-CPU.Step refresh in nes.Step that
-	// catches $E000 acks) updates this field.
-	MapperIRQ bool
-
-	// mmc3FirstClockPending arms a one-shot extra A12-rise clock on the
-	// next rendering scanline (consumed by the BG=$1000 path in Step).
-	// Set on PPUMASK render-off→on; cleared after one $1000-mode tick.
-	mmc3FirstClockPending bool
-
 	// renderEnabled caches PPUMASK's BG/sprite-show bits — the same value
 	// renderingEnabled() computes — so the per-cycle hot path in Step skips
 	// re-reading PPUMASK every cycle. Kept in sync wherever PPUMASK changes:
 	// the $2001 write, Reset, and LoadState.
 	renderEnabled bool
 
-	// mapperTickCycle is the PPU cycle on which the MMC3 IRQ counter is
-	// clocked for the current PPUCTRL.BGTable setting (273 for BG=$0000,
-	// 337 for BG=$1000). Recomputed only on $2000 write / Reset / LoadState
-	// so Step's per-cycle MMC3 path is a single compare. See Step.
-	mapperTickCycle int
+	// a12High is A12 as the last rendering pattern fetch left it, and
+	// a12ClockCycle the cycle the mapper is next clocked on (-1 = none).
+	// fetchA12 follows the fetches' addresses and arms the clock on the
+	// rises the MMC3 counts.
+	a12High       bool
+	a12ClockCycle int
+
+	// spriteFetches holds the pattern addresses the eight sprite fetch
+	// slots of cycles 257-320 read for the next line, worked out by
+	// spriteFetchAddrs when the slots start.
+	spriteFetches [maxSpritesPerScanline]uint16
 
 	// vblSuppressed records a $2002 read that landed in the race window
 	// where the VBL flag is about to be set. On real hardware a read
@@ -82,12 +75,24 @@ type PPU struct {
 	// counts down per Step, asserts NMIRequested on hitting 0.
 	nmiAssertCountdown uint8
 
+	// sprite0HitPending holds a sprite-0 hit detected on the previous dot
+	// until StepN commits it to PPUSTATUS — see checkSprite0Hit for the
+	// one-dot latency this models.
+	sprite0HitPending bool
+
 	// oddFrame flips at the end of every pre-render scanline. NTSC PPU
 	// "skips" one cycle (cycle 340 of pre-render) on odd frames when BG
 	// rendering is enabled — blargg even_odd_frames / even_odd_timing
 	// rely on this to keep their hit-counter calibration in sync.
 	oddFrame bool
 
+	// warmUp is set from BeginWarmUp until the next pre-render line. For
+	// that stretch (~29658 CPU cycles after power-up) the 2C02 ignores
+	// writes to $2000, $2001, $2005 and $2006 — NESdev "PPU power up
+	// state". Games that poll VBlank twice before touching the PPU never
+	// notice; ones that don't, lose those writes on hardware too.
+	warmUp bool
+
 	// openBus models the PPU's CPU-side data-bus "decay register". Per-bit
 	// refresh because $2002 reads only refresh bits 5-7 (test 7 verifies
 	// bits 0-4 still decay independently) and $2007 palette reads only
@@ -101,19 +106,7 @@ type PPU struct {
 	// / MMC3 / MMC4 can change mirroring at runtime, but only via CPU writes
 	// to mapper registers — refreshing once per scanline is fine-grained
 	// enough for every commercial game.
-	cachedMirroring int
-
-	// currentBGTile holds the most-recently-fetched background tile for
-	// the visible scanline; currentBGTileX is its index (-1 = invalid).
-	// bgTileAt re-fetches when crossing an 8-pixel tile
-	// boundary, so a mid-scanline $2000 D4 toggle (pattern table) or a
-	// $2005/$2006 update affects subsequent tiles within the same
-	// scanline — required by Quietust's scanline test. v.coarseX / fineX
-	// are still constant for the scanline in this PPU model, so the
-	// cache invalidates only on tileX change (≈33 fetches per scanline,
-	// same volume as the old whole-scanline prefetch).
-	currentBGTile  BackgroundTile
-	currentBGTileX int
+	cachedMirroring cartridge.MirroringMode
 
 	// Rendering. currentSprites (declared with the large arrays below) holds
 	// the sprites overlapping the current scanline; currentSpriteCount is how
@@ -124,11 +117,29 @@ type PPU struct {
 
 	// NoSpriteLimit disables the per-scanline 8-sprite cap when set: all
 	// sprites overlapping a scanline render, removing the hardware sprite
-	// flicker. The sprite-overflow STATUS flag still latches per the real
-	// 8-limit, so game logic that polls it is unaffected. Runtime display
-	// preference — not part of save-state, untouched by Reset.
+	// flicker. The sprite-overflow STATUS flag still latches as hardware's
+	// evaluation would (see spriteOverflow), so game logic that polls it
+	// is unaffected. Runtime display preference — not part of save-state,
+	// untouched by Reset.
 	NoSpriteLimit bool
 
+	// lineTiles is the background fetch pipeline's output: the 33 tiles a
+	// line's pixels come from (tile k covers screen x 8k-fineX to
+	// 8k+7-fineX), each stored by fetchTileSlot at its hardware fetch
+	// slot. It isn't saved: StepFrame stops before the pre-render line
+	// fetches anything, so a state loaded at a frame boundary never reads
+	// a stale entry.
+	lineTiles [33]BackgroundTile
+
+	// scanlineRenderer selects the batch renderer in scanline.go for lines
+	// with no mid-line register activity. lineRendered is set while the
+	// current line's pixels came from it; lineHitX is the dot of that
+	// line's first sprite-0 overlap (-1 = none).
+	scanlineRenderer bool
+	lineRendered     bool
+	lineHitX         int
+	mapperWriteHooks [2]memory.HookID
+
 	// PPU read buffer for $2007 reads
 	readBuffer uint8
 
@@ -136,19 +147,7 @@ type PPU struct {
 	Memory *memory.Memory
 
 	// Cartridge interface
-	Cartridge interface {
-		ReadCHR(addr uint16) uint8
-		ReadCHRSprite(addr uint16) uint8 // sprite-side fetch — MMC5 8×16 uses a different CHR set
-		WriteCHR(addr uint16, value uint8)
-		Step() // Called once per scanline for mapper IRQ
-		IsIRQPending() bool
-		ClearIRQ()
-		GetMirroring() int
-		NotifyA12(chrAddr uint16, renderingEnabled bool) // For MMC3 A12 edge detection
-		SetSpriteSize(is8x16 bool)                       // MMC5 tracks this for CHR routing
-		NotifyScanline(scanline int, renderingEnabled bool)
-		HasExpansion() bool // MMC5 — also the only mapper that remaps nametables mid-scanline
-	}
+	Cartridge CartridgeBus
 
 	// dynamicMirroring is true when the mapper can change its nametable
 	// mapping mid-scanline (MMC5's $5105). Only then does the per-NT-read
@@ -157,6 +156,12 @@ type PPU struct {
 	// already catches. Cached from Cartridge.HasExpansion() at SetCartridge.
 	dynamicMirroring bool
 
+	// chrFetchHook is true when the mapper wants to see every pattern
+	// fetch address (MMC2/MMC4 switch CHR banks on tiles $FD/$FE).
+	// Cached from Cartridge.WatchesPatternFetches() at SetCartridge so the
+	// other mappers don't pay an interface call per fetch.
+	chrFetchHook bool
+
 	// Large arrays last so the small, per-pixel-hot scalar fields above
 	// cluster into a few cache lines instead of being pushed hundreds of KB
 	// apart by these buffers (which would alias the FrameBuffer write stream
@@ -212,40 +217,137 @@ const (
 	PPUSTATUSVBlank         = 0x80 // VBlank flag
 )
 
-// Mirroring mode codes returned by Cartridge.GetMirroring() and mapper
-// GetMirroringMode() implementations. Single-screen modes are used by MMC1.
-const (
-	MirroringHorizontal        = 0
-	MirroringVertical          = 1
-	MirroringSingleScreenLower = 2
-	MirroringSingleScreenUpper = 3
-)
+// CartridgeBus is the PPU's view of the cartridge: the CHR bus, the
+// nametable arrangement, and the mapper hooks that follow the PPU's
+// address bus and scanlines. It is the PPU-side subset of
+// cartridge.CartridgeInterface.
+type CartridgeBus interface {
+	ReadCHR(addr uint16) uint8
+	ReadCHRSprite(addr uint16) uint8 // sprite-side fetch — MMC5 8×16 uses a different CHR set
+	WriteCHR(addr uint16, value uint8)
+	GetMirroring() cartridge.MirroringMode
+	Step()                       // Called per counted A12 rise while rendering (MMC3 IRQ)
+	PPUAddressBus(addr uint16)   // MMC3 A12 edges, MMC2/MMC4 tile $FD/$FE latches
+	WatchesPatternFetches() bool // PPUAddressBus wanted for rendering fetches too
+	SetSpriteSize(is8x16 bool)   // MMC5 tracks this for CHR routing
+	NotifyScanline(scanline int, renderingEnabled bool)
+	HasExpansion() bool // MMC5 — also the only mapper that remaps nametables mid-scanline
+}
 
 // New creates a new PPU instance
 func New(mem *memory.Memory) *PPU {
-	return &PPU{
+	p := &PPU{
 		Memory:         mem,
 		Cycle:          0,
 		Scanline:       0,
-		PaletteManager:  NewPaletteManager(),
-		currentBGTileX:  -1,
-		mapperTickCycle: 273, // BG=$0000 default; refreshed on $2000 write
+		PaletteManager: NewPaletteManager(),
+		a12ClockCycle:  -1,
 	}
+	p.refreshDerivedCtrl()
+	return p
 }
 
-// refreshDerivedCtrl recomputes the PPUMASK/PPUCTRL-derived hot-path fields
-// (renderEnabled, mapperTickCycle) that Step reads every cycle. Called from
-// every site that changes PPUMASK or PPUCTRL ($2000/$2001 writes, Reset,
-// LoadState) so the caches never diverge from the live registers.
+// refreshDerivedCtrl recomputes the PPUMASK-derived hot-path field
+// renderEnabled that Step reads every cycle. Called from every site that
+// changes PPUMASK ($2001 writes, Reset, LoadState) so the cache never
+// diverges from the live register. Turning rendering on blanks lineTiles:
+// nothing was fetched while it was off, so tiles whose slots already passed
+// show as transparent rather than whatever an earlier line left there.
 func (p *PPU) refreshDerivedCtrl() {
+	wasEnabled := p.renderEnabled
 	p.renderEnabled = p.renderingEnabled()
-	p.mapperTickCycle = 273
-	if p.PPUCTRL&PPUCTRLBGTable != 0 {
-		p.mapperTickCycle = 337
+	if p.renderEnabled && !wasEnabled {
+		p.lineTiles = [len(p.lineTiles)]BackgroundTile{}
+	}
+}
+
+// a12ClockDelay is how many dots after the fetch slot that raises A12 the
+// MMC3 counter is clocked. The sprite slot k rise is at dot 261+8k on
+// hardware; empirically 12 dots later, 10 after the slot completes here,
+// matches blargg scanline_timing.
+const a12ClockDelay = 10
+
+// fetchA12 follows A12 through the rendering pattern fetch of addr,
+// completed at cycle, and arms the mapper clock when the fetch raises it.
+// Only pattern fetches are tracked: the nametable and attribute fetches
+// between two of them keep A12 low for a few dots, too short for the
+// MMC3's M2 filter, so a rise counts only after a whole slot on $0000.
+// Usually that's one clock a line — the first sprite slot for
+// BG=$0000/sprites=$1000, the BG prefetch for the reverse, none with both
+// on one table — but 8×16 sprites mixing tables can clock the counter late
+// or twice, as on hardware. A $2001 write turning rendering back on drops
+// A12 (it was low while nothing was fetched), so with BG=$1000 the first
+// BG fetch after it clocks the counter once more.
+func (p *PPU) fetchA12(addr uint16, cycle int) {
+	high := addr&0x1000 != 0
+	if high && !p.a12High && p.Cartridge != nil {
+		p.a12ClockCycle = (cycle + a12ClockDelay) % dotsPerLine
 	}
+	p.a12High = high
 }
 
-// Reset resets the PPU to initial state
+// SoftReset models the reset button, per NESdev's PPU power-up table:
+// PPUCTRL, PPUMASK, the $2005/$2006 write toggle and scroll, the $2007 read
+// buffer and the odd-frame flag are cleared, while VRAM, palette, OAM,
+// OAMADDR, the VRAM address and the VBlank flag survive. The beam keeps
+// its position.
+func (p *PPU) SoftReset() {
+	p.splitScanline()
+	p.PPUCTRL = 0
+	p.PPUMASK = 0
+	p.t = 0
+	p.x = 0
+	p.w = 0
+	p.readBuffer = 0
+	p.oddFrame = false
+	p.refreshDerivedCtrl()
+	if p.PaletteManager != nil {
+		p.PaletteManager.SetEmphasis(0)
+		p.PaletteManager.SetGreyscale(false)
+	}
+}
+
+// BeginWarmUp starts the power-up period in which PPUCTRL, PPUMASK,
+// PPUSCROLL and PPUADDR writes are dropped; it ends when the beam next
+// reaches the pre-render line. Kept separate from Reset so a bare PPU (as
+// in unit tests) is writable straight away — the nes package arms it on
+// power-on and reset.
+func (p *PPU) BeginWarmUp() {
+	p.warmUp = true
+}
+
+// WarmingUp reports whether the PPU is still ignoring register writes after
+// BeginWarmUp.
+func (p *PPU) WarmingUp() bool {
+	return p.warmUp
+}
+
+// PowerOn is Reset plus the state a power cycle clears and Reset leaves:
+// VRAM, OAM and palette RAM, the $2007 read buffer, the open-bus latch,
+// and the frame counter with its odd-frame phase. Real chips come up with
+// indeterminate memory; using a fresh PPU's contents means a power cycle
+// and a new instance run identically.
+func (p *PPU) PowerOn() {
+	p.VRAM = [len(p.VRAM)]uint8{}
+	p.OAM = [len(p.OAM)]uint8{}
+	if p.PaletteManager != nil {
+		p.PaletteManager.powerOnRAM()
+	}
+	p.OAMDATA, p.PPUSCROLL, p.PPUADDR, p.PPUDATA = 0, 0, 0, 0
+	p.ScrollY = 0
+	p.readBuffer = 0
+	p.openBusValue = 0
+	p.openBusDecayFrame = [8]uint64{}
+	p.Frame = 0
+	p.oddFrame = false
+	p.NMIRequested = false
+	p.vblSuppressed = false
+	p.nmiAssertCountdown = 0
+	p.warmUp = false
+	p.Reset()
+}
+
+// Reset resets the PPU to its power-on state.
 func (p *PPU) Reset() {
 	p.PPUCTRL = 0
 	p.PPUMASK = 0
@@ -258,180 +360,59 @@ func (p *PPU) Reset() {
 	p.Cycle = 0
 	p.Scanline = 0
 	p.FrameComplete = false
-	p.currentBGTileX = -1
+	p.sprite0HitPending = false
+	p.a12High, p.a12ClockCycle = false, -1
 	p.refreshDerivedCtrl()
-	// PPUMASK was just cleared; keep the palette emphasis in sync with it
-	// (emphasis is only updated on $2001 writes, not derived per-cycle).
+	// PPUMASK was just cleared; keep the palette emphasis/greyscale in sync
+	// with it (both are only updated on $2001 writes, not derived per-cycle).
 	if p.PaletteManager != nil {
 		p.PaletteManager.SetEmphasis(0)
+		p.PaletteManager.SetGreyscale(false)
 	}
 }
 
 // SetCartridge sets the cartridge reference
-func (p *PPU) SetCartridge(cart interface {
-	ReadCHR(addr uint16) uint8
-	ReadCHRSprite(addr uint16) uint8
-	WriteCHR(addr uint16, value uint8)
-	Step()
-	IsIRQPending() bool
-	ClearIRQ()
-	GetMirroring() int
-	NotifyA12(chrAddr uint16, renderingEnabled bool)
-	SetSpriteSize(is8x16 bool)
-	NotifyScanline(scanline int, renderingEnabled bool)
-	HasExpansion() bool
-}) {
+func (p *PPU) SetCartridge(cart CartridgeBus) {
 	p.Cartridge = cart
 	p.dynamicMirroring = cart.HasExpansion()
+	p.chrFetchHook = cart.WatchesPatternFetches()
 	p.refreshMirroringCache()
 }
 
-// Step executes one PPU cycle. Equivalent to StepN(1); retained for
-// single-cycle callers (unit tests).
-func (p *PPU) Step() { p.StepN(1) }
-
-// StepN executes n PPU cycles. nes.Step calls this once per CPU cycle batch
-// (3 PPU cycles per CPU cycle) rather than calling Step in a loop, so the
-// per-cycle Cycle/Scanline counters live in locals for the whole batch instead
-// of being reloaded from the struct around every internal call (renderPixel,
-// Cartridge.Step, etc., which the compiler must assume could alias p.Cycle/
-// p.Scanline). They're written back before returning. Nothing outside the PPU
-// reads p.Cycle/p.Scanline mid-batch — only CPU-side register access does, and
-// that never runs while StepN is executing.
-func (p *PPU) StepN(n int) {
-	cycle, scanline := p.Cycle, p.Scanline
-	for i := 0; i < n; i++ {
-		// NMI-assertion countdown — see PPU.nmiAssertCountdown. Tick down
-		// here so the assertion lands N PPU cycles after the VBL set Step.
-		// Re-check VBL at expiry: if a CPU $2002 read cleared the flag during
-		// the countdown window, NMI is suppressed (blargg suppression test
-		// rows 05-06: flag read back as set but NMI never fires because the
-		// quickly-cleared flag never held the NMI line low long enough).
-		if p.nmiAssertCountdown > 0 {
-			p.nmiAssertCountdown--
-			if p.nmiAssertCountdown == 0 && p.PPUCTRL&PPUCTRLNMIEnable != 0 && p.PPUSTATUS&PPUSTATUSVBlank != 0 {
-				p.NMIRequested = true
-			}
-		}
-
-		// Render visible pixels only — cycles 256-340 of a visible scanline
-		// produce no output, so gating the call here skips ~85 no-op renderPixel
-		// calls per scanline instead of paying the call + early-return.
-		if scanline >= 0 && scanline < 240 && cycle < 256 {
-			p.renderPixel(cycle, scanline)
-		}
-
-		// MMC3 IRQ + per-scanline Y increment both need: rendering enabled, and we're
-		// on a visible scanline (or pre-render). The Y increment makes mid-frame $2006
-		// writes propagate for split-screen effects (e.g. SMB3 title screen) — the
-		// renderer reads v.coarseY/fineY as the *current* scanline's row, not a
-		// frame-start scroll.
-		// renderingActive ⇔ on a render-producing scanline (pre-render -1 .. 239)
-		// with rendering enabled. Scanline is always ≥ -1, so the lower bound is
-		// implicit and this reduces to a single compare against the cached flag.
-		renderingActive := scanline < 240 && p.renderEnabled
-		if renderingActive && p.Cartridge != nil {
-			// MMC3 IRQ clocking — A12 rising edges with a ~3-CPU-cycle low
-			// filter. The counted rise's PPU cycle depends on PPUCTRL.BGTable
-			// (cached in mapperTickCycle):
-			//   BG=$0000, Sprites=$1000: first sprite-pattern fetch (~261);
-			//     empirically cycle 273 matches blargg scanline_timing.
-			//   BG=$1000, Sprites=$0000: prefetch BG-pattern fetch after the
-			//     64-cycle sprite-fetch low window (~cycle 325, emu cycle 337).
-			// Plus a one-shot extra clock on the first rendering scanline
-			// after PPUMASK 0→on (the render-off period satisfies the filter
-			// for the first BG-pattern fetch at cycle ~5 / emu cycle 17;
-			// subsequent cycle-5 rises are filtered out by the short
-			// inter-scanline low gap). bg1000 ⇔ mapperTickCycle == 337.
-			clockMapper := cycle == p.mapperTickCycle
-			if !clockMapper && p.mmc3FirstClockPending && p.mapperTickCycle == 337 && cycle == 17 {
-				clockMapper = true
-				p.mmc3FirstClockPending = false
-			}
-			if clockMapper {
-				p.Cartridge.Step()
-				p.MapperIRQ = p.Cartridge.IsIRQPending()
-			}
-		}
-		if renderingActive && cycle == 256 {
-			p.incrementY()
-		}
-
-		cycle++
-		if cycle >= 341 {
-			cycle = 0
-			p.refreshMirroringCache()
-
-			scanline++
-			// Odd-frame skip: NTSC PPU drops the first idle tick (cycle 0) of
-			// scanline 0 on odd frames when background rendering is enabled.
-			// Realised here by starting the new visible scanline at cycle 1
-			// instead of 0 under those conditions.
-			if scanline == 0 && p.oddFrame && p.PPUMASK&PPUMASKBGShow != 0 {
-				cycle = 1
-			}
-			// Tell the mapper about the new rendering scanline. MMC5 uses
-			// this for its scanline-match IRQ; other mappers ignore it.
-			if p.Cartridge != nil && scanline >= 0 && scanline < 240 {
-				p.Cartridge.NotifyScanline(scanline, p.renderingEnabled())
-			}
-
-			if scanline == 241 {
-				// VBlank start: set VBlank flag immediately so a CPU $2002
-				// read here observes it (vbl_set_time T+5). The NMI assertion
-				// is deferred by nmiAssertDelayPPUCycles so nmi_timing's
-				// calibration table lands on the right CPU instruction.
-				if !p.vblSuppressed {
-					p.PPUSTATUS |= PPUSTATUSVBlank
-					if p.PPUCTRL&PPUCTRLNMIEnable != 0 {
-						p.nmiAssertCountdown = nmiAssertDelayPPUCycles
-					}
-				}
-				p.vblSuppressed = false
-			}
-
-			if scanline >= 261 {
-				scanline = -1 // Pre-render scanline
-
-				// Pre-render line: clear VBlank, sprite 0 hit, and sprite overflow
-				// flags. NESdev says this is at cycle 1 of pre-render; doing it
-				// here at the (260, 340) → (-1, 0) wrap places it one PPU cycle
-				// earlier in absolute terms. Tests vbl_clear_time / suppression
-				// pass with this earlier timing — moving the clear to (-1, 1)
-				// breaks test 3's row-06 expectation.
-				p.PPUSTATUS &^= PPUSTATUSVBlank
-				p.PPUSTATUS &^= PPUSTATUSSprite0Hit
-				p.PPUSTATUS &^= PPUSTATUSSpriteOverflow
-
-				p.FrameComplete = true
-				p.Frame++
-				p.oddFrame = !p.oddFrame
-			}
-		}
-
-		// Handle pre-render scanline (scanline -1/261)
-		if scanline == -1 {
-			// Copy horizontal scroll components from t to v at start of pre-render line
-			if cycle == 304 && p.renderingEnabled() {
-				// Copy vertical scroll components from t to v
-				p.v = (p.v & 0x841F) | (p.t & 0x7BE0)
-			}
-			if cycle == 257 && p.renderingEnabled() {
-				// Copy horizontal scroll components from t to v
-				p.v = (p.v & 0xFBE0) | (p.t & 0x041F)
-			}
+// fetchTileSlot completes the background fetch slot ending at cycle: it
+// stores the tile v points at in lineTiles, reading the pattern table the
+// live PPUCTRL selects, and steps v's coarse X, as hardware dots 8, 16, …
+// 256 and 328, 336 do. The slots up to cycle 255 fetch tiles 2-33 of the
+// current line (33 is never displayed and is skipped); the two at 327 and
+// 335 fetch tiles 0 and 1 of the next. A line the scanline renderer drew
+// has already fetched its own tiles, so its slots only step v, and the
+// pre-render line fetches nothing for itself.
+func (p *PPU) fetchTileSlot(cycle, scanline int) {
+	k := (cycle - 327) >> 3
+	if cycle < 256 {
+		k = cycle>>3 + 2
+		if scanline < 0 || p.lineRendered {
+			k = len(p.lineTiles)
 		}
+	}
+	if k < len(p.lineTiles) {
+		p.lineTiles[k] = p.fetchBackgroundTile()
+	}
+	// Skipped or not, the slot's pattern fetch reads the BG table, and
+	// A12 is that table's bit.
+	p.fetchA12(uint16(p.PPUCTRL&PPUCTRLBGTable)<<8, cycle)
+	p.incrementCoarseX()
+}
 
-		// Copy horizontal scroll from t to v at the start of each visible
-		// scanline and invalidate the single-tile cache so the first fetch
-		// uses the just-restored v / x.
-		if scanline >= 0 && scanline < 240 && cycle == 0 && p.renderingEnabled() {
-			p.v = (p.v & 0xFBE0) | (p.t & 0x041F)
-			p.x = p.xTemp
-			p.currentBGTileX = -1
-		}
+// incrementCoarseX advances v's coarse X by one tile, flipping the
+// horizontal nametable bit when it wraps past column 31.
+func (p *PPU) incrementCoarseX() {
+	if p.v&0x001F == 31 {
+		p.v &^= 0x001F
+		p.v ^= 0x0400
+		return
 	}
-	p.Cycle, p.Scanline = cycle, scanline
+	p.v++
 }
 
 // incrementY advances v's vertical position by one scanline per the NESdev
@@ -479,6 +460,11 @@ func (p *PPU) readVRAMInternal(addr uint16, sprite bool) uint8 {
 			} else {
 				value = p.Cartridge.ReadCHR(addr)
 			}
+			// After the read: a latch switch affects the next fetch,
+			// not this one.
+			if p.chrFetchHook {
+				p.Cartridge.PPUAddressBus(addr)
+			}
 			// Debug: Log CHR reads via PPU - focus on pattern table reads with scanline info
 			if logger.PPUEnabled() && addr <= 0x1FFF && (addr < 0x100 || (addr >= 0x800 && addr < 0x900)) {
 				// Log first 256 bytes of each bank for key areas
@@ -535,32 +521,14 @@ func (p *PPU) writeVRAM(addr uint16, value uint8) {
 	}
 }
 
-// GetFramebuffer returns the current framebuffer as RGBA bytes
-func (p *PPU) GetFramebuffer() []uint8 {
-	// Convert 32-bit framebuffer to RGBA bytes
-	rgba := make([]uint8, 256*240*4)
-
-	for i, pixel := range p.FrameBuffer {
-		// Extract RGB components from 32-bit pixel (0xAARRGGBB format)
-		r := uint8((pixel >> 16) & 0xFF) // Extract R correctly
-		g := uint8((pixel >> 8) & 0xFF)  // Extract G correctly
-		b := uint8(pixel & 0xFF)         // Extract B correctly
-		a := uint8((pixel >> 24) & 0xFF) // Use alpha from pixel
-
-		// Use RGBA order to match test pattern format
-		rgba[i*4+0] = r
-		rgba[i*4+1] = g
-		rgba[i*4+2] = b
-		rgba[i*4+3] = a
-
-		// Debug logging for first few pixels (disabled for performance)
-		// if i < 8 {
-		//	logger.LogPPU("Framebuffer[%d]: pixel=%08X -> RGBA(%02X,%02X,%02X,%02X)",
-		//		i, pixel, r, g, b, a)
-		// }
-	}
+// VRAMAddress returns the VRAM address the next $2007 access goes to (the
+// v register), for debuggers.
+func (p *PPU) VRAMAddress() uint16 { return p.v & 0x3FFF }
 
-	return rgba
+// PeekNameTable returns the nametable byte at addr ($2000-$3EFF, folded
+// through the cartridge's mirroring) without touching PPU state.
+func (p *PPU) PeekNameTable(addr uint16) uint8 {
+	return p.readNameTable(0x2000 | addr&0x0FFF)
 }
 
 // readNameTable reads from nametable with mirroring
@@ -591,13 +559,13 @@ func (p *PPU) mirrorNameTableAddress(addr uint16) uint16 {
 	offset := addr - 0x2000
 
 	switch p.cachedMirroring {
-	case MirroringHorizontal:
+	case cartridge.MirroringHorizontal:
 		return p.applyHorizontalMirroring(offset) + 0x2000
-	case MirroringVertical:
+	case cartridge.MirroringVertical:
 		return p.applyVerticalMirroring(offset) + 0x2000
-	case MirroringSingleScreenLower:
+	case cartridge.MirroringSingleScreenLower:
 		return (offset & 0x3FF) + 0x2000
-	case MirroringSingleScreenUpper:
+	case cartridge.MirroringSingleScreenUpper:
 		return (offset & 0x3FF) + 0x2400
 	default:
 		// Four-screen — no mirroring, use logical address as-is.
@@ -612,7 +580,7 @@ func (p *PPU) refreshMirroringCache() {
 	if p.Cartridge != nil {
 		p.cachedMirroring = p.Cartridge.GetMirroring()
 	} else {
-		p.cachedMirroring = MirroringHorizontal
+		p.cachedMirroring = cartridge.MirroringHorizontal
 	}
 }
 
@@ -630,25 +598,6 @@ func (p *PPU) applyVerticalMirroring(offset uint16) uint16 {
 	return offset & 0x7FF
 }
 
-// IsMapperIRQPending returns the cached mapper IRQ line state. Kept in
-// sync with the cartridge by Step (per-scanline tick), notifyCartridgeA12
-// ($2006/$2007-driven CPU rises), and a post-CPU.Step refresh in nes.Step
-// (catches $E000 acks). nes.Step polls this every PPU cycle, so the field
-// read must stay cheaper than an interface dispatch.
-func (p *PPU) IsMapperIRQPending() bool {
-	return p.MapperIRQ
-}
-
-// ClearMapperIRQ forwards to the cartridge so the mapper's internal pending
-// bit drops. Retained for explicit-clear callers; the regular IRQ servicing
-// path doesn't call this (it relies on the game writing $E000 to ack).
-func (p *PPU) ClearMapperIRQ() {
-	p.MapperIRQ = false
-	if p.Cartridge != nil {
-		p.Cartridge.ClearIRQ()
-	}
-}
-
 // renderingEnabled reports whether either background or sprite rendering is
 // turned on via PPUMASK. Read on every visible PPU cycle, so it's a single
 // AND/compare — inlined by the compiler.
@@ -716,7 +665,7 @@ type ppuState struct {
 	PPUCTRL, PPUMASK, PPUSTATUS, OAMADDR, OAMDATA uint8
 	PPUSCROLL, PPUADDR, PPUDATA                   uint8
 	V, T                                          uint16
-	X, XTemp, W                                   uint8
+	X, _, W                                       uint8 // _ was a scanline-start copy of X; kept for the layout
 	ScrollY                                       uint8
 	ReadBuffer                                    uint8
 	Cycle                                         int32
@@ -726,6 +675,8 @@ type ppuState struct {
 	VblSuppressed                                 bool
 	NmiAssertCountdown                            uint8
 	OddFrame                                      bool
+	Sprite0HitPending                             bool
+	WarmUp                                        bool
 	VRAM                                          [0x4000]uint8
 	OAM                                           [256]uint8
 	PaletteRAM                                    [32]uint8
@@ -738,7 +689,7 @@ func (p *PPU) SaveState(w io.Writer) error {
 		PPUCTRL: p.PPUCTRL, PPUMASK: p.PPUMASK, PPUSTATUS: p.PPUSTATUS,
 		OAMADDR: p.OAMADDR, OAMDATA: p.OAMDATA,
 		PPUSCROLL: p.PPUSCROLL, PPUADDR: p.PPUADDR, PPUDATA: p.PPUDATA,
-		V: p.v, T: p.t, X: p.x, XTemp: p.xTemp, W: p.w,
+		V: p.v, T: p.t, X: p.x, W: p.w,
 		ScrollY:            p.ScrollY,
 		ReadBuffer:         p.readBuffer,
 		Cycle:              int32(p.Cycle),
@@ -748,6 +699,8 @@ func (p *PPU) SaveState(w io.Writer) error {
 		VblSuppressed:      p.vblSuppressed,
 		NmiAssertCountdown: p.nmiAssertCountdown,
 		OddFrame:           p.oddFrame,
+		Sprite0HitPending:  p.sprite0HitPending,
+		WarmUp:             p.warmUp,
 		VRAM:               p.VRAM,
 		OAM:                p.OAM,
 	}
@@ -758,6 +711,40 @@ func (p *PPU) SaveState(w io.Writer) error {
 	return binary.Write(w, binary.LittleEndian, &s)
 }
 
+// AppendState appends the bytes SaveState writes to b without allocating
+// once b has the capacity; NES.StateHash uses it.
+func (p *PPU) AppendState(b []byte) []byte {
+	b = append(b, p.PPUCTRL, p.PPUMASK, p.PPUSTATUS, p.OAMADDR, p.OAMDATA,
+		p.PPUSCROLL, p.PPUADDR, p.PPUDATA)
+	b = binary.LittleEndian.AppendUint16(b, p.v)
+	b = binary.LittleEndian.AppendUint16(b, p.t)
+	b = append(b, p.x, 0, p.w, p.ScrollY, p.readBuffer)
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(p.Cycle)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(p.Scanline)))
+	b = binary.LittleEndian.AppendUint64(b, p.Frame)
+	b = appendBool(b, p.NMIRequested)
+	b = appendBool(b, p.vblSuppressed)
+	b = append(b, p.nmiAssertCountdown)
+	b = appendBool(b, p.oddFrame)
+	b = appendBool(b, p.sprite0HitPending)
+	b = appendBool(b, p.warmUp)
+	b = append(b, p.VRAM[:]...)
+	b = append(b, p.OAM[:]...)
+	if p.PaletteManager == nil {
+		var zero [33]uint8
+		return append(b, zero[:]...)
+	}
+	b = append(b, p.PaletteManager.PaletteRAM[:]...)
+	return append(b, p.PaletteManager.Emphasis)
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
 // LoadState restores PPU state written by SaveState.
 func (p *PPU) LoadState(r io.Reader) error {
 	var s ppuState
@@ -767,7 +754,7 @@ func (p *PPU) LoadState(r io.Reader) error {
 	p.PPUCTRL, p.PPUMASK, p.PPUSTATUS = s.PPUCTRL, s.PPUMASK, s.PPUSTATUS
 	p.OAMADDR, p.OAMDATA = s.OAMADDR, s.OAMDATA
 	p.PPUSCROLL, p.PPUADDR, p.PPUDATA = s.PPUSCROLL, s.PPUADDR, s.PPUDATA
-	p.v, p.t, p.x, p.xTemp, p.w = s.V, s.T, s.X, s.XTemp, s.W
+	p.v, p.t, p.x, p.w = s.V, s.T, s.X, s.W
 	p.ScrollY = s.ScrollY
 	p.readBuffer = s.ReadBuffer
 	p.Cycle, p.Scanline = int(s.Cycle), int(s.Scanline)
@@ -776,14 +763,20 @@ func (p *PPU) LoadState(r io.Reader) error {
 	p.vblSuppressed = s.VblSuppressed
 	p.nmiAssertCountdown = s.NmiAssertCountdown
 	p.oddFrame = s.OddFrame
+	p.sprite0HitPending = s.Sprite0HitPending
+	p.warmUp = s.WarmUp
 	p.VRAM = s.VRAM
 	p.OAM = s.OAM
-	p.refreshDerivedCtrl() // PPUCTRL/PPUMASK restored above; resync caches
+	p.refreshDerivedCtrl() // PPUMASK restored above; resync the cache
+	// States are taken at frame boundaries, where the last pattern fetch
+	// was the BG prefetch and no clock is pending.
+	p.a12High, p.a12ClockCycle = s.PPUCTRL&PPUCTRLBGTable != 0, -1
 	if p.PaletteManager != nil {
 		p.PaletteManager.PaletteRAM = s.PaletteRAM
 		p.PaletteManager.Emphasis = s.PaletteEmphasis
-		// PaletteRAM/Emphasis were set by direct field assignment (bypassing
-		// WritePalette/SetEmphasis), so refresh the derived color cache.
+		p.PaletteManager.Greyscale = s.PPUMASK&PPUMASKGreyscale != 0
+		// PaletteRAM/Emphasis/Greyscale were set by direct field assignment
+		// (bypassing WritePalette/SetEmphasis/SetGreyscale), so refresh the derived color cache.
 		p.PaletteManager.rebuildColorCache()
 	}
 	p.invalidateRenderCache()
@@ -794,6 +787,6 @@ func (p *PPU) LoadState(r io.Reader) error {
 // for the current scanline. Call after restoring VRAM/OAM so the next
 // pixel fetch re-reads from the freshly loaded state.
 func (p *PPU) invalidateRenderCache() {
-	p.currentBGTileX = -1
+	p.lineRendered = false
 	p.currentSpriteCount = 0
 }
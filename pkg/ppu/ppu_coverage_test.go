@@ -20,18 +20,38 @@ func TestGetFramebufferRGBA(t *testing.T) {
 	}
 }
 
-func TestMapperIRQAccessors(t *testing.T) {
+func TestGetFramebufferInto(t *testing.T) {
 	p := New(memory.New())
-	if p.IsMapperIRQPending() {
-		t.Error("fresh PPU should report no mapper IRQ")
+	p.FrameBuffer[1] = 0xFF445566
+	buf := make([]uint8, 0, ScreenWidth*ScreenHeight*4)
+	rgba := p.GetFramebufferInto(buf)
+	if &rgba[0] != &buf[:1][0] {
+		t.Error("GetFramebufferInto should fill dst when it has room")
 	}
-	p.MapperIRQ = true
-	if !p.IsMapperIRQPending() {
-		t.Error("IsMapperIRQPending should reflect MapperIRQ")
+	if rgba[4] != 0x44 || rgba[5] != 0x55 || rgba[6] != 0x66 {
+		t.Errorf("pixel1 RGB = %02X%02X%02X, want 44 55 66", rgba[4], rgba[5], rgba[6])
 	}
-	// ClearMapperIRQ with no cartridge just drops the cached flag.
-	p.ClearMapperIRQ()
-	if p.IsMapperIRQPending() {
-		t.Error("ClearMapperIRQ should clear the flag")
+	if allocs := testing.AllocsPerRun(10, func() { rgba = p.GetFramebufferInto(rgba) }); allocs != 0 {
+		t.Errorf("GetFramebufferInto: %.1f allocations, want 0", allocs)
+	}
+	if short := p.GetFramebufferInto(make([]uint8, 16)); len(short) != ScreenWidth*ScreenHeight*4 {
+		t.Errorf("short dst: len %d", len(short))
+	}
+}
+
+func BenchmarkGetFramebuffer(b *testing.B) {
+	p := New(memory.New())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.GetFramebuffer()
+	}
+}
+
+func BenchmarkGetFramebufferInto(b *testing.B) {
+	p := New(memory.New())
+	var rgba []uint8
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rgba = p.GetFramebufferInto(rgba)
 	}
 }
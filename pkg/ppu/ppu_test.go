@@ -92,6 +92,52 @@ func TestNoSpriteLimit(t *testing.T) {
 	}
 }
 
+// TestSpriteOverflowHardwareScan checks the overflow flag follows the
+// PPU's buggy search for a ninth sprite — reading entry 9's tile byte as
+// its Y, and so on — in both sprite limit modes.
+func TestSpriteOverflowHardwareScan(t *testing.T) {
+	const scanline = 10
+	setup := func(ppu *PPU) {
+		for i := range ppu.OAM {
+			ppu.OAM[i] = 0xFF // off screen, whichever byte is read as Y
+		}
+		for i := 0; i < 8; i++ {
+			ppu.OAM[i*4] = scanline - 1
+			ppu.OAM[i*4+3] = uint8(i * 8)
+		}
+	}
+	for _, noLimit := range []bool{false, true} {
+		ppu := createTestPPU()
+		ppu.NoSpriteLimit = noLimit
+
+		// No ninth sprite, but entry 9's tile byte reads as an in-range Y.
+		setup(ppu)
+		ppu.OAM[9*4+1] = scanline - 1
+		ppu.PPUSTATUS = 0
+		ppu.evaluateSprites(scanline)
+		if ppu.PPUSTATUS&PPUSTATUSSpriteOverflow == 0 {
+			t.Errorf("noLimit=%v: overflow should fire off entry 9's tile byte", noLimit)
+		}
+
+		// A real ninth sprite at entry 9 is missed: its tile byte is read.
+		setup(ppu)
+		ppu.OAM[9*4] = scanline - 1
+		ppu.OAM[9*4+1] = 0xC8
+		ppu.PPUSTATUS = 0
+		ppu.evaluateSprites(scanline)
+		if ppu.PPUSTATUS&PPUSTATUSSpriteOverflow != 0 {
+			t.Errorf("noLimit=%v: overflow fired for a ninth sprite hardware misses", noLimit)
+		}
+		want := 8
+		if noLimit {
+			want = 9
+		}
+		if ppu.currentSpriteCount != want {
+			t.Errorf("noLimit=%v: %d sprites on the line, want %d", noLimit, ppu.currentSpriteCount, want)
+		}
+	}
+}
+
 // Test palette operations
 func TestPaletteOperations(t *testing.T) {
 	ppu := createTestPPU()
@@ -238,6 +284,47 @@ func TestVRAMAddressIncrement(t *testing.T) {
 	}
 }
 
+// Test $2007 access while rendering: coarse X and Y both increment
+// (with nametable wrap) instead of the PPUCTRL +1/+32.
+func TestVRAMAddressIncrementDuringRendering(t *testing.T) {
+	cases := []struct {
+		name     string
+		scanline int
+		mask     uint8
+		inc32    bool
+		v, want  uint16
+	}{
+		{"fine Y and coarse X", 100, PPUMASKBGShow, false, 0x2000, 0x3001},
+		{"ignores +32 bit", 100, PPUMASKSpriteShow, true, 0x2000, 0x3001},
+		{"pre-render line", -1, PPUMASKBGShow, false, 0x2000, 0x3001},
+		{"coarse X wraps to next NT", 10, PPUMASKBGShow, false, 0x201F, 0x3400},
+		{"fine Y overflow bumps coarse Y", 10, PPUMASKBGShow, false, 0x7000, 0x0021},
+		{"coarse Y 29 wraps to next NT", 10, PPUMASKBGShow, false, 0x73A0, 0x0801},
+		// Outside rendering the normal increment applies.
+		{"vblank", 241, PPUMASKBGShow, false, 0x2000, 0x2001},
+		{"rendering off", 100, 0, true, 0x2000, 0x2020},
+	}
+	for _, c := range cases {
+		ppu := createTestPPU()
+		ppu.PPUMASK = c.mask
+		ppu.refreshDerivedCtrl()
+		if c.inc32 {
+			ppu.PPUCTRL |= PPUCTRLIncrement
+		}
+		ppu.Scanline = c.scanline
+		ppu.v = c.v
+		ppu.ReadRegister(0x2007)
+		if ppu.v != c.want {
+			t.Errorf("%s: read: v=%04X, want %04X", c.name, ppu.v, c.want)
+		}
+		ppu.v = c.v
+		ppu.WriteRegister(0x2007, 0)
+		if ppu.v != c.want {
+			t.Errorf("%s: write: v=%04X, want %04X", c.name, ppu.v, c.want)
+		}
+	}
+}
+
 // Test scroll register writes
 func TestScrollRegister(t *testing.T) {
 	ppu := createTestPPU()
@@ -259,3 +346,102 @@ func TestScrollRegister(t *testing.T) {
 		t.Errorf("Expected write toggle=0, got %d", ppu.w)
 	}
 }
+
+// TestPPUSoftReset checks the reset button clears the control registers and
+// scroll latches but leaves memory and the beam alone.
+func TestPPUSoftReset(t *testing.T) {
+	ppu := createTestPPU()
+	ppu.PPUCTRL = 0x90
+	ppu.PPUMASK = 0x1E
+	ppu.OAM[5] = 0x42
+	ppu.OAMADDR = 0x10
+	ppu.w = 1
+	ppu.t = 0x1234
+	ppu.Cycle = 100
+	ppu.Scanline = 50
+
+	ppu.SoftReset()
+
+	if ppu.PPUCTRL != 0 || ppu.PPUMASK != 0 {
+		t.Errorf("PPUCTRL=%02X PPUMASK=%02X, want both cleared", ppu.PPUCTRL, ppu.PPUMASK)
+	}
+	if ppu.w != 0 || ppu.t != 0 {
+		t.Errorf("w=%d t=%04X, want latches cleared", ppu.w, ppu.t)
+	}
+	if ppu.OAM[5] != 0x42 || ppu.OAMADDR != 0x10 {
+		t.Errorf("OAM[5]=%02X OAMADDR=%02X, want preserved", ppu.OAM[5], ppu.OAMADDR)
+	}
+	if ppu.Cycle != 100 || ppu.Scanline != 50 {
+		t.Errorf("beam at %d,%d, want 100,50", ppu.Scanline, ppu.Cycle)
+	}
+}
+
+// TestPPUWarmUp checks $2000/$2001/$2005/$2006 writes are dropped from
+// BeginWarmUp until the pre-render line, while $2003/$2007 still land.
+func TestPPUWarmUp(t *testing.T) {
+	ppu := createTestPPU()
+	ppu.BeginWarmUp()
+
+	ppu.WriteRegister(0x2000, 0x80)
+	ppu.WriteRegister(0x2001, 0x1E)
+	ppu.WriteRegister(0x2005, 0x10)
+	ppu.WriteRegister(0x2003, 0x20)
+	if ppu.PPUCTRL != 0 || ppu.PPUMASK != 0 || ppu.w != 0 {
+		t.Errorf("PPUCTRL=%02X PPUMASK=%02X w=%d during warm-up, want writes ignored", ppu.PPUCTRL, ppu.PPUMASK, ppu.w)
+	}
+	if ppu.OAMADDR != 0x20 {
+		t.Errorf("OAMADDR=%02X, want $2003 honoured during warm-up", ppu.OAMADDR)
+	}
+
+	for !ppu.FrameComplete {
+		ppu.Step()
+	}
+	if ppu.WarmingUp() {
+		t.Fatal("warm-up still active at the pre-render line")
+	}
+	ppu.WriteRegister(0x2000, 0x80)
+	if ppu.PPUCTRL != 0x80 {
+		t.Errorf("PPUCTRL=%02X after warm-up, want $80", ppu.PPUCTRL)
+	}
+}
+
+// TestVBlankReadRace pins the $2002 race windows around the VBL set at the
+// (240, 340) → (241, 0) transition: a read one dot early sees the flag
+// clear and cancels both the flag and the NMI, reads in the next two dots
+// see it set but still cancel the NMI, and later reads leave the NMI alone.
+func TestVBlankReadRace(t *testing.T) {
+	for _, tc := range []struct {
+		scanline, cycle int
+		wantFlag        bool // bit 7 of the racing read
+		wantNMI         bool
+		wantSetAfter    bool // flag visible to a read at (241, 10)
+	}{
+		{240, 339, false, true, true},
+		{240, 340, false, false, false},
+		{241, 0, true, false, false},
+		{241, 1, true, false, false},
+		{241, 2, true, true, false},
+	} {
+		p := createTestPPU()
+		p.WriteRegister(0x2000, PPUCTRLNMIEnable)
+		for p.Scanline != tc.scanline || p.Cycle != tc.cycle {
+			p.Step()
+		}
+		status := p.ReadRegister(0x2002)
+		nmi := false
+		for p.Scanline != 241 || p.Cycle != 10 {
+			p.Step()
+			nmi = nmi || p.ConsumeNMI()
+		}
+		after := p.ReadRegister(0x2002)
+		if got := status&PPUSTATUSVBlank != 0; got != tc.wantFlag {
+			t.Errorf("read at (%d, %d): flag %v, want %v", tc.scanline, tc.cycle, got, tc.wantFlag)
+		}
+		if nmi != tc.wantNMI {
+			t.Errorf("read at (%d, %d): NMI %v, want %v", tc.scanline, tc.cycle, nmi, tc.wantNMI)
+		}
+		if got := after&PPUSTATUSVBlank != 0; got != tc.wantSetAfter {
+			t.Errorf("read at (%d, %d): flag afterwards %v, want %v", tc.scanline, tc.cycle, got, tc.wantSetAfter)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 // CPU-visible PPU register interface ($2000-$2007).
 //
 // The $2005/$2006 first/second-write toggle (`w`), the $2007 stale-byte read
-// latch (`readBuffer`), and the loopy-register dance over `v`/`t`/`x`/`xTemp`
+// latch (`readBuffer`), and the loopy-register dance over `v`/`t`/`x`
 // all live here. The bit-twiddling sequences are deliberately verbatim from
 // the NESdev wiki PPU scrolling page — split-screen effects (e.g. SMB3 title)
 // depend on the exact loopy semantics, so do not paraphrase.
@@ -22,7 +22,10 @@ func (p *PPU) ReadRegister(addr uint16) uint8 {
 		// VBL race: a $2002 read on the cycle just before our VBL-flag set
 		// transition (which lands at the (240, 340) → (241, 0) wrap in our
 		// model — see PPU.vblSuppressed) suppresses the flag set and NMI
-		// for this frame, matching real-hardware behaviour.
+		// for this frame, matching real-hardware behaviour. A read in the
+		// two cycles after it returns the flag set but still cancels the
+		// NMI: clearing the flag below stops nmiAssertCountdown from
+		// asserting it.
 		if p.Scanline == 240 && p.Cycle == 340 {
 			p.vblSuppressed = true
 		}
@@ -43,6 +46,7 @@ func (p *PPU) ReadRegister(addr uint16) uint8 {
 		p.refreshOpenBus(value, 0xFF)
 		return value
 	case 0x2007: // PPUDATA
+		p.splitScanline() // the increment below moves v mid-line
 		var value uint8
 		// The PPU bus is 14-bit; bits 14-15 of v are ignored when deciding
 		// whether the access falls inside the palette region. Without this
@@ -67,7 +71,7 @@ func (p *PPU) ReadRegister(addr uint16) uint8 {
 		}
 
 		p.incrementVRAMAddress()
-		p.notifyCartridgeA12()
+		p.notifyCartridgeAddress()
 
 		if palette {
 			// Palette only drives bits 0-5; bits 6-7 come from open bus.
@@ -88,11 +92,22 @@ func (p *PPU) WriteRegister(addr uint16, value uint8) {
 	// bus, refreshing all 8 bits of the decay register (including for
 	// $2002, which has no normal write effect but still drives the bus).
 	p.refreshOpenBus(value, 0xFF)
+	if p.warmUp {
+		switch addr {
+		case 0x2000, 0x2001, 0x2005, 0x2006:
+			// Dropped during power-up warm-up — see BeginWarmUp. The
+			// $2005/$2006 write toggle doesn't move either.
+			return
+		}
+	}
+	switch addr {
+	case 0x2000, 0x2001, 0x2005, 0x2006, 0x2007:
+		p.splitScanline()
+	}
 	switch addr {
 	case 0x2000: // PPUCTRL
 		oldValue := p.PPUCTRL
 		p.PPUCTRL = value
-		p.refreshDerivedCtrl() // BGTable bit feeds mapperTickCycle
 		p.t = (p.t & 0xF3FF) | ((uint16(value) & 0x03) << 10)
 		if logger.PPUEnabled() {
 			logger.LogPPU("Write PPUCTRL: $%02X -> $%02X (NMI=%v, BG_table=$%04X, Sprite_table=$%04X)",
@@ -125,13 +140,12 @@ func (p *PPU) WriteRegister(addr uint16, value uint8) {
 		// Emphasis (PPUMASK bits 5-7) feeds the palette ARGB LUT index. Set
 		// it here on the write instead of re-deriving it every PPU cycle.
 		p.PaletteManager.SetEmphasis(value & 0xE0)
-		// Render off→on transition: arm the MMC3 "first A12 rise after long
-		// pause" one-shot so the next rendering scanline's cycle-5 BG fetch
-		// (in BG=$1000 mode) clocks the IRQ counter once before the normal
-		// per-scanline cycle-325 ticks take over. See PPU.Step for details.
+		p.PaletteManager.SetGreyscale(value&PPUMASKGreyscale != 0)
+		// Render off→on: the fetches stopped while it was off, so A12
+		// has been low long enough for the next rise to count.
 		const renderShow = PPUMASKBGShow | PPUMASKSpriteShow
 		if oldValue&renderShow == 0 && value&renderShow != 0 {
-			p.mmc3FirstClockPending = true
+			p.a12High = false
 		}
 	case 0x2003: // OAMADDR
 		p.OAMADDR = value
@@ -144,10 +158,12 @@ func (p *PPU) WriteRegister(addr uint16, value uint8) {
 		}
 		if p.w == 0 {
 			p.t = (p.t & 0xFFE0) | (uint16(value) >> 3)
-			p.xTemp = value & 0x07 // Store in temporary register
+			// Fine X has no t-side copy: it changes at once, mid-line
+			// included, shifting the rest of the line's pixels.
+			p.x = value & 0x07
 			p.w = 1
 			if logger.PPUEnabled() {
-				logger.LogPPU("PPUSCROLL X: value=$%02X, xTemp=%d, t=$%04X, scanline=%d", value, p.xTemp, p.t, p.Scanline)
+				logger.LogPPU("PPUSCROLL X: value=$%02X, x=%d, t=$%04X, scanline=%d", value, p.x, p.t, p.Scanline)
 			}
 		} else {
 			p.t = (p.t & 0x8FFF) | ((uint16(value) & 0x07) << 12)
@@ -184,7 +200,7 @@ func (p *PPU) WriteRegister(addr uint16, value uint8) {
 			}
 			// The second $2006 write commits t into v, which can flip A12
 			// (bit 12) — MMC3 IRQ counter clocks on A12 0→1.
-			p.notifyCartridgeA12()
+			p.notifyCartridgeAddress()
 		}
 	case 0x2007: // PPUDATA
 		if logger.PPUEnabled() {
@@ -196,27 +212,41 @@ func (p *PPU) WriteRegister(addr uint16, value uint8) {
 		}
 		p.writeVRAM(p.v, value)
 		p.incrementVRAMAddress()
-		p.notifyCartridgeA12()
+		p.notifyCartridgeAddress()
 	}
 }
 
-// notifyCartridgeA12 hands the current v register to the cartridge so MMC3
-// (and any future A12-IRQ mapper) can detect rising edges from CPU-driven
-// register accesses ($2006 second write, $2007 R/W increments).
-// Rendering-side toggles are handled by Cartridge.Step in the PPU loop.
-// We refresh PPU.MapperIRQ after the notification so the cached flag stays
-// in sync with any IRQ asserted by the CPU-side rise.
-func (p *PPU) notifyCartridgeA12() {
+// notifyCartridgeAddress puts the current v register on the cartridge's
+// PPU address bus after a CPU-driven register access ($2006 second write,
+// $2007 R/W increments), so MMC3 (and any future A12-IRQ mapper) can
+// detect rising edges and MMC2/MMC4 see their latch addresses.
+// Rendering-side A12 rises reach the mapper as Cartridge.Step, from
+// fetchA12.
+// An IRQ the rise asserts reaches the CPU through the mapper's IRQLine,
+// which nes.Step samples after the instruction.
+func (p *PPU) notifyCartridgeAddress() {
 	if p.Cartridge == nil {
 		return
 	}
-	p.Cartridge.NotifyA12(p.v, p.renderingEnabled())
-	p.MapperIRQ = p.Cartridge.IsIRQPending()
+	p.Cartridge.PPUAddressBus(p.v)
 }
 
 // incrementVRAMAddress advances `v` after a $2007 read or write by either 1
 // (across) or 32 (down) per the PPUCTRL increment bit.
+//
+// While the PPU is rendering (pre-render or a visible scanline with BG or
+// sprites on) the address bus belongs to the fetch pipeline, and the
+// $2007 access instead triggers *both* of its scroll counters at once: a
+// coarse X increment and a Y increment, each with its usual nametable
+// wrap, regardless of PPUCTRL (NESdev "PPU scrolling: $2007 reads and
+// writes"). The renderer takes coarse X/Y from v at each tile fetch, so
+// the rest of the line shifts the way it does on hardware.
 func (p *PPU) incrementVRAMAddress() {
+	if p.Scanline < 240 && p.renderEnabled {
+		p.incrementCoarseX()
+		p.incrementY()
+		return
+	}
 	if p.PPUCTRL&PPUCTRLIncrement != 0 {
 		p.v += 32
 	} else {
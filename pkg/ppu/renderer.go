@@ -41,38 +41,17 @@ const (
 	SpritePaletteMask    = 0x03 // Palette selection (bits 0-1)
 )
 
-// fetchBackgroundTileWithScroll fetches tile data for background rendering.
-// v.coarseY / v.fineY are advanced per-scanline by PPU.incrementY(), so the
-// row to fetch comes from v directly. Only the X axis adds the screen tile
-// column to v.coarseX (with NT wrap), since v.coarseX is restored from t at
-// the start of every scanline.
-func (p *PPU) fetchBackgroundTileWithScroll(tileX int) BackgroundTile {
-	coarseX := int(p.v & 0x1F)
-	scrolledTileY := int((p.v >> 5) & 0x1F)
-	scrolledTileX := coarseX + tileX
-
-	nameTableX := 0
-	if scrolledTileX >= 32 {
-		nameTableX = 1
-		scrolledTileX -= 32
-	}
-
-	baseNTX := int(p.v>>10) & 1
-	baseNTY := int(p.v>>11) & 1
-
-	finalNTX := (baseNTX + nameTableX) % 2
-	finalNTY := baseNTY
-
-	nameTableIndex := finalNTY*2 + finalNTX
-	nameTableBase := uint16(0x2000) + uint16(nameTableIndex)*0x400
-	nameTableAddr := nameTableBase + uint16(scrolledTileY*32+scrolledTileX)
-
-	tileIndex := p.readVRAM(nameTableAddr)
-
-	attrAddr := nameTableBase + 0x3C0 + uint16((scrolledTileY/4)*8+(scrolledTileX/4))
-	attrByte := p.readVRAM(attrAddr)
-
-	attrShift := ((scrolledTileY & 2) * 2) + ((scrolledTileX&2)/2)*2
+// fetchBackgroundTile fetches the tile v points at: the nametable byte, its
+// two attribute bits, and the pattern row for v's fine Y from the table
+// PPUCTRL selects at the moment of the fetch. Everything comes from v and
+// the live registers, so a mid-line $2000 pattern-table switch or a
+// $2006/$2007 access moves exactly the tiles fetched after it.
+func (p *PPU) fetchBackgroundTile() BackgroundTile {
+	v := p.v
+	tileIndex := p.readVRAM(0x2000 | v&0x0FFF)
+
+	attrByte := p.readVRAM(0x23C0 | v&0x0C00 | (v>>4)&0x38 | (v>>2)&0x07)
+	attrShift := (v>>4)&0x04 | v&0x02
 	attributes := (attrByte >> attrShift) & 0x03
 
 	patternTableBase := uint16(0x0000)
@@ -82,8 +61,8 @@ func (p *PPU) fetchBackgroundTileWithScroll(tileX int) BackgroundTile {
 
 	tileAddr := patternTableBase + uint16(tileIndex)*16
 
-	// v.fineY is the pixel row within the current tile for this scanline.
-	fineY := (p.v >> 12) & 0x07
+	// v.fineY is the pixel row within the tile for this scanline.
+	fineY := (v >> 12) & 0x07
 	return BackgroundTile{
 		TileIndex:  tileIndex,
 		Attributes: attributes,
@@ -106,12 +85,14 @@ func getPixelColor(patternLo, patternHi uint8, pixelX int) uint8 {
 }
 
 // evaluateSprites fills currentSprites/currentSpriteCount with the (up to 8)
-// sprites overlapping scanline, pre-fetching each one's pattern-row bytes, and
-// runs the next-scanline sprite-overflow evaluation that the PPU does during
-// cycles 65-256 of scanline N (i.e. for scanline N+1). The next-scanline pass
-// is what lets the overflow flag fire when 9+ sprites have Y=239 — those
-// render at scanline 240 (post-render), so the current-scanline count never
-// sees them.
+// sprites overlapping scanline — all of them with NoSpriteLimit —
+// pre-fetching each one's pattern-row bytes, and latches the overflow flag
+// for scanline and for the next one, whose evaluation the PPU runs during
+// cycles 65-256 of scanline N. The next-scanline pass is what lets the
+// flag fire when 9+ sprites have Y=239 — those render at scanline 240
+// (post-render), so the current-scanline count never sees them. The flag
+// comes from spriteOverflow either way, so lifting the limit changes only
+// what is drawn, never what a game reads from PPUSTATUS.
 func (p *PPU) evaluateSprites(scanline int) {
 	spriteHeight := 8
 	if p.PPUCTRL&PPUCTRLSpriteSize != 0 {
@@ -121,84 +102,127 @@ func (p *PPU) evaluateSprites(scanline int) {
 	// Scan through OAM for sprites on this scanline.
 	// OAM Y stores (actual_screen_Y - 1) — a sprite with OAM Y = 143 first
 	// appears at scanline 144 — so we shift by +1 here and in spritePixelAt.
-	// Secondary OAM holds 8 sprites; overflow fires when a 9th matches.
+	// Secondary OAM holds 8 sprites; NoSpriteLimit keeps collecting past
+	// them, in OAM order so priority is preserved.
 	count := 0
 	for i := 0; i < totalOAMSprites; i++ {
-		spriteY := int(p.OAM[i*4]) + 1
-
-		if scanline >= spriteY && scanline < spriteY+spriteHeight {
-			// The 9th+ sprite latches the overflow flag exactly as on
-			// hardware. NoSpriteLimit keeps it for rendering anyway.
-			if count >= maxSpritesPerScanline {
-				p.PPUSTATUS |= PPUSTATUSSpriteOverflow
-				if !p.NoSpriteLimit {
-					break
-				}
-			}
-			s := SpriteInfo{
-				SpriteData: SpriteData{
-					Y:          p.OAM[i*4],
-					TileIndex:  p.OAM[i*4+1],
-					Attributes: p.OAM[i*4+2],
-					X:          p.OAM[i*4+3],
-				},
-				OAMIndex: uint8(i),
-			}
-			p.fetchSpritePattern(&s, scanline, spriteHeight)
-			p.currentSprites[count] = s
-			count++
+		if count == maxSpritesPerScanline && !p.NoSpriteLimit {
+			break
+		}
+		if !spriteOnLine(p.OAM[i*4], scanline, spriteHeight) {
+			continue
 		}
+		s := SpriteInfo{
+			SpriteData: SpriteData{
+				Y:          p.OAM[i*4],
+				TileIndex:  p.OAM[i*4+1],
+				Attributes: p.OAM[i*4+2],
+				X:          p.OAM[i*4+3],
+			},
+			OAMIndex: uint8(i),
+		}
+		p.fetchSpritePattern(&s, scanline, spriteHeight)
+		p.currentSprites[count] = s
+		count++
 	}
 	p.currentSpriteCount = count
 
-	// Next-scanline overflow lookahead. Independent count — the real PPU's
-	// eval phase doesn't know about the render list.
-	next := scanline + 1
-	lookahead := 0
-	for i := 0; i < totalOAMSprites; i++ {
-		spriteY := int(p.OAM[i*4]) + 1
-		if next >= spriteY && next < spriteY+spriteHeight {
-			lookahead++
-			if lookahead > maxSpritesPerScanline {
-				p.PPUSTATUS |= PPUSTATUSSpriteOverflow
-				break
+	if p.spriteOverflow(scanline, spriteHeight) || p.spriteOverflow(scanline+1, spriteHeight) {
+		p.PPUSTATUS |= PPUSTATUSSpriteOverflow
+	}
+}
+
+// spriteOnLine reports whether a sprite whose OAM Y byte is y covers line.
+func spriteOnLine(y uint8, line, height int) bool {
+	top := int(y) + 1
+	return line >= top && line < top+height
+}
+
+// spriteOverflow runs the hardware's overflow search for line. Once
+// secondary OAM holds 8 sprites the PPU goes on looking for a ninth, but
+// a bug in its address increment steps the byte it reads as well as the
+// sprite: entry n+1 is tested by its tile byte, n+2 by its attributes and
+// so on round the four bytes. So the flag can miss a real ninth sprite
+// and fire for one that isn't there, and games that read it see exactly
+// that.
+func (p *PPU) spriteOverflow(line, height int) bool {
+	found := 0
+	for n := 0; n < totalOAMSprites; n++ {
+		if !spriteOnLine(p.OAM[n*4], line, height) {
+			continue
+		}
+		if found++; found < maxSpritesPerScanline {
+			continue
+		}
+		m := 0
+		for n++; n < totalOAMSprites; n++ {
+			if spriteOnLine(p.OAM[n*4+m], line, height) {
+				return true
 			}
+			m = (m + 1) & 3
 		}
+		return false
 	}
+	return false
 }
 
-// fetchSpritePattern fetches the two pattern-plane bytes for the row of sprite
-// that lands on scanline, applying vertical flip and 8×16 tile selection.
-// Sprite fetches route through readVRAMSprite so MMC5 8×16 picks its sprite
-// CHR set.
-func (p *PPU) fetchSpritePattern(sprite *SpriteInfo, scanline, spriteHeight int) {
-	pixelY := scanline - (int(sprite.Y) + 1)
-	if sprite.Attributes&SpriteFlipVertical != 0 {
-		pixelY = (spriteHeight - 1) - pixelY
+// spriteFetchAddrs fills spriteFetches with the pattern addresses the
+// sprite fetch slots read for line: the row of each sprite in range, in OAM
+// order, then tile $FF for every slot left over, as on hardware, whose
+// secondary OAM is left full of $FF.
+func (p *PPU) spriteFetchAddrs(line int) {
+	spriteHeight := 8
+	if p.PPUCTRL&PPUCTRLSpriteSize != 0 {
+		spriteHeight = 16
 	}
+	slot := 0
+	for i := 0; i < totalOAMSprites && slot < maxSpritesPerScanline; i++ {
+		if !spriteOnLine(p.OAM[i*4], line, spriteHeight) {
+			continue
+		}
+		row := line - (int(p.OAM[i*4]) + 1)
+		p.spriteFetches[slot] = p.spritePatternAddr(p.OAM[i*4+1], p.OAM[i*4+2], row, spriteHeight)
+		slot++
+	}
+	for ; slot < maxSpritesPerScanline; slot++ {
+		p.spriteFetches[slot] = p.spritePatternAddr(0xFF, 0, 0, spriteHeight)
+	}
+}
 
-	var tileAddr uint16
+// spritePatternAddr is the address of the low pattern plane of row of a
+// sprite with tile and attributes, applying vertical flip and 8×16 tile
+// selection.
+func (p *PPU) spritePatternAddr(tile, attributes uint8, row, spriteHeight int) uint16 {
+	if attributes&SpriteFlipVertical != 0 {
+		row = (spriteHeight - 1) - row
+	}
 	if spriteHeight == 16 {
 		// 8×16 sprites: bit 0 of the tile index selects the pattern table,
 		// the rest is the (even) top tile; the bottom tile is the next one.
-		tileIndex := sprite.TileIndex & 0xFE
-		if pixelY >= 8 {
-			tileIndex++
-			pixelY -= 8
-		}
 		patternTableBase := uint16(0x0000)
-		if sprite.TileIndex&1 != 0 {
+		if tile&1 != 0 {
 			patternTableBase = 0x1000
 		}
-		tileAddr = patternTableBase + uint16(tileIndex)*16 + uint16(pixelY)
-	} else {
-		patternTableBase := uint16(0x0000)
-		if p.PPUCTRL&PPUCTRLSpriteTable != 0 {
-			patternTableBase = 0x1000
+		tile &= 0xFE
+		if row >= 8 {
+			tile++
+			row -= 8
 		}
-		tileAddr = patternTableBase + uint16(sprite.TileIndex)*16 + uint16(pixelY)
+		return patternTableBase + uint16(tile)*16 + uint16(row)
 	}
+	patternTableBase := uint16(0x0000)
+	if p.PPUCTRL&PPUCTRLSpriteTable != 0 {
+		patternTableBase = 0x1000
+	}
+	return patternTableBase + uint16(tile)*16 + uint16(row)
+}
 
+// fetchSpritePattern fetches the two pattern-plane bytes for the row of sprite
+// that lands on scanline. Sprite fetches route through readVRAMSprite so MMC5
+// 8×16 picks its sprite CHR set.
+func (p *PPU) fetchSpritePattern(sprite *SpriteInfo, scanline, spriteHeight int) {
+	row := scanline - (int(sprite.Y) + 1)
+	tileAddr := p.spritePatternAddr(sprite.TileIndex, sprite.Attributes, row, spriteHeight)
 	sprite.PatternLo = p.readVRAMSprite(tileAddr)
 	sprite.PatternHi = p.readVRAMSprite(tileAddr + 8)
 }
@@ -208,6 +232,13 @@ func (p *PPU) fetchSpritePattern(sprite *SpriteInfo, scanline, spriteHeight int)
 // only shifts bits out of the pre-fetched pattern bytes — no CHR fetch. The y
 // range was already checked in evaluateSprites, so only the x range is tested
 // here. Returns (color, priorityFront, isSprite0).
+//
+// This is the hardware's sprite multiplexer: the winning sprite is chosen
+// on opacity and OAM order alone, and only the winner's priority bit is
+// then compared with the background. A behind-background sprite thus also
+// hides any later front sprite under it wherever the background is opaque
+// (Super Mario Bros. 3 masks items rising out of blocks this way), so the
+// loop must not skip behind sprites looking for a front one.
 func (p *PPU) spritePixelAt(x int) (uint32, bool, bool) {
 	if p.PPUMASK&PPUMASKSpriteShow == 0 {
 		return 0x00000000, false, false
@@ -250,36 +281,26 @@ func (p *PPU) renderPixel(x, y int) {
 	index := y*256 + x
 
 	if !p.renderEnabled {
-		// Rendering disabled, just set background color
+		// Rendering disabled, just set background color.
 		p.FrameBuffer[index] = p.PaletteManager.GetBackgroundColor(0, 0)
 		return
 	}
 
-	// Background pixel. The tile is fetched lazily and cached, refetching only
-	// when crossing an 8-pixel boundary so a mid-scanline $2000/$2005/$2006
-	// write propagates to later tiles. bgColorIndex (0 = transparent) drives
-	// sprite priority / sprite-0 hit and is reused below so the tile is decoded
-	// only once. BG off or left-clip shows the backdrop as transparent index 0.
+	// Background pixel, from the tile the fetch pipeline stored for it (see
+	// fetchTileSlot). bgColorIndex (0 = transparent) drives sprite priority /
+	// sprite-0 hit and is reused below so the tile is decoded only once. BG
+	// off or left-clip shows the backdrop as transparent index 0.
 	var bgColorIndex uint8
 	var bgColor uint32
 	if p.PPUMASK&PPUMASKBGShow == 0 || (x < 8 && p.PPUMASK&PPUMASKBGLeft == 0) {
 		bgColor = p.PaletteManager.GetBackgroundColor(0, 0)
 	} else {
 		adjustedX := x + int(p.x)
-		if tileX := adjustedX >> 3; tileX != p.currentBGTileX {
-			p.currentBGTile = p.fetchBackgroundTileWithScroll(tileX)
-			p.currentBGTileX = tileX
-		}
-		t := &p.currentBGTile
+		t := &p.lineTiles[adjustedX>>3]
 		bgColorIndex = getPixelColor(t.PatternLo, t.PatternHi, adjustedX&7)
 		bgColor = p.PaletteManager.GetBackgroundColor(t.Attributes, bgColorIndex)
 	}
 
-	// Evaluate sprites once per scanline (fetches each sprite's pattern row).
-	if x == 0 {
-		p.evaluateSprites(y)
-	}
-
 	finalColor := bgColor
 	if p.currentSpriteCount > 0 {
 		spriteColor, spritePriority, sprite0Hit := p.spritePixelAt(x)
@@ -296,23 +317,43 @@ func (p *PPU) renderPixel(x, y int) {
 				finalColor = spriteColor
 			}
 
-			// Sprite 0 hit detection.
-			//
-			// NESdev: sprite 0 hit never fires at x=255 (the PPU's output
-			// pipeline rules out that final dot for an "obscure" pixel-
-			// output-circuitry reason). blargg's right_edge test exercises
-			// it directly.
-			if sprite0Hit && p.PPUSTATUS&PPUSTATUSSprite0Hit == 0 && x != 255 {
-				spriteEnabled := p.PPUMASK&PPUMASKSpriteShow != 0
-				bgEnabled := p.PPUMASK&PPUMASKBGShow != 0
-				leftClipped := x < 8 && (p.PPUMASK&(PPUMASKSpriteLeft|PPUMASKBGLeft)) != (PPUMASKSpriteLeft|PPUMASKBGLeft)
-
-				if bgOpaque && spriteEnabled && bgEnabled && !leftClipped {
-					p.PPUSTATUS |= PPUSTATUSSprite0Hit
-				}
+			if sprite0Hit && bgOpaque {
+				p.checkSprite0Hit(x)
 			}
 		}
 	}
 
 	p.FrameBuffer[index] = finalColor
 }
+
+// checkSprite0Hit is called for a pixel where an opaque sprite-0 pixel
+// overlaps an opaque background pixel, and applies the remaining hardware
+// conditions before arming the hit (NESdev "PPU OAM: Sprite zero hits"):
+//
+//   - both BG and sprite rendering must be on (either alone never hits);
+//   - x=255 never hits — the last dot is excluded by the pixel-output
+//     circuitry (blargg sprite_hit_tests 06.right_edge);
+//   - x=0-7 never hits if *either* left-column clip bit is clear, even
+//     when the other layer is visible there (05.left_clip);
+//   - the flag latches once per frame and is only cleared at pre-render.
+//
+// The hit is not written to PPUSTATUS here: the comparator's result
+// reaches the status register one dot after the pixel is produced, so it
+// is parked in sprite0HitPending and committed at the start of the next
+// PPU cycle by StepN. A $2002 read landing on the same dot as the
+// overlapping pixel therefore still sees the flag clear
+// (09.timing_basics / 11.edge_timing measure exactly this edge).
+func (p *PPU) checkSprite0Hit(x int) {
+	if p.PPUSTATUS&PPUSTATUSSprite0Hit != 0 || x == 255 {
+		return
+	}
+	const bothLayers = PPUMASKBGShow | PPUMASKSpriteShow
+	if p.PPUMASK&bothLayers != bothLayers {
+		return
+	}
+	const bothLeft = PPUMASKBGLeft | PPUMASKSpriteLeft
+	if x < 8 && p.PPUMASK&bothLeft != bothLeft {
+		return
+	}
+	p.sprite0HitPending = true
+}
@@ -0,0 +1,190 @@
+package ppu
+
+import "github.com/yoshiomiyamaegones/pkg/memory"
+
+// Scanline renderer — an optional fast path for renderPixel.
+//
+// renderPixel re-checks the clip bits, the tile cache and every evaluated
+// sprite for each of the 256 dots of a line. When nothing that affects
+// rendering changes while a line is being drawn — the common case outside
+// raster effects — the whole line can instead be produced at dot 0: each
+// background tile is fetched once and its 8 pixels decoded together, and
+// the evaluated sprites are painted into a line buffer once rather than
+// searched per pixel.
+//
+// The accurate path stays authoritative. Anything that can change the
+// picture mid-line (a $2000/$2001/$2005/$2006/$2007 access, or a CPU write
+// to mapper registers) calls splitScanline, which hands the rest of the
+// line back to renderPixel from the current dot. Pixels already produced
+// are the ones renderPixel would have drawn with the same, unchanged
+// state, and the fetch slots still ahead refetch their tiles on time, so
+// the output is identical either way.
+//
+// Sprite 0 hit keeps its exact dot: the batch only records where the first
+// qualifying overlap is, and StepN arms the hit when the beam reaches it.
+
+// SetScanlineRenderer turns the scanline fast path on or off. Enabling it
+// installs CPU write hooks on the mapper register ranges so bank switches
+// mid-line fall back to the accurate path. Runtime preference — not part
+// of save-state, untouched by Reset.
+func (p *PPU) SetScanlineRenderer(on bool) {
+	if on == p.scanlineRenderer {
+		return
+	}
+	p.scanlineRenderer = on
+	p.splitScanline()
+	if p.Memory == nil {
+		return
+	}
+	if on {
+		// $6000-$7FFF is left out: games use PRG RAM as work RAM all
+		// frame long, and hooking it would split nearly every line.
+		split := func(_ uint16, value uint8) uint8 {
+			p.splitScanline()
+			return value
+		}
+		p.mapperWriteHooks = [2]memory.HookID{
+			p.Memory.AddWriteHook(0x4020, 0x5FFF, split),
+			p.Memory.AddWriteHook(0x8000, 0xFFFF, split),
+		}
+		return
+	}
+	for _, id := range p.mapperWriteHooks {
+		p.Memory.RemoveHook(id)
+	}
+}
+
+// ScanlineRenderer reports whether the scanline fast path is enabled.
+func (p *PPU) ScanlineRenderer() bool { return p.scanlineRenderer }
+
+// bgVisibleAt reports whether the background layer is drawn at screen x
+// (BG on, and not inside a clipped left column).
+func (p *PPU) bgVisibleAt(x int) bool {
+	return p.PPUMASK&PPUMASKBGShow != 0 && (x >= 8 || p.PPUMASK&PPUMASKBGLeft != 0)
+}
+
+// renderScanline draws visible line y in one pass and reports whether it
+// did. MMC5 lines always go through renderPixel: its nametable mapping and
+// ExRAM attributes can change between tile fetches.
+func (p *PPU) renderScanline(y int) bool {
+	if p.dynamicMirroring {
+		return false
+	}
+	row := p.FrameBuffer[y*256 : y*256+256]
+	p.lineHitX = -1
+
+	if !p.renderEnabled {
+		backdrop := p.PaletteManager.GetBackgroundColor(0, 0)
+		for i := range row {
+			row[i] = backdrop
+		}
+		return true
+	}
+
+	// Background. Tiles 0 and 1 came from the previous line's last fetch
+	// slots; the rest are fetched here, after sprite evaluation (StepN's
+	// dot 0 action, just before), in the order their slots would fetch
+	// them, so mappers that latch on pattern reads (MMC2/MMC4) switch banks
+	// at the same tile on both paths. v is put back afterwards: StepN steps
+	// it through the line slot by slot.
+	v := p.v
+	for k := 2; k < len(p.lineTiles); k++ {
+		p.lineTiles[k] = p.fetchBackgroundTile()
+		p.incrementCoarseX()
+	}
+	p.v = v
+
+	var bgIndex [256]uint8
+	backdrop := p.PaletteManager.GetBackgroundColor(0, 0)
+	fineX := int(p.x)
+	x := 0
+	if !p.bgVisibleAt(0) {
+		end := 256
+		if p.PPUMASK&PPUMASKBGShow != 0 {
+			end = 8
+		}
+		for ; x < end; x++ {
+			row[x] = backdrop
+		}
+	}
+	for x < 256 {
+		tileX := (x + fineX) >> 3
+		t := &p.lineTiles[tileX]
+		end := (tileX+1)*8 - fineX
+		if end > 256 {
+			end = 256
+		}
+		for ; x < end; x++ {
+			ci := getPixelColor(t.PatternLo, t.PatternHi, (x+fineX)&7)
+			bgIndex[x] = ci
+			row[x] = p.PaletteManager.GetBackgroundColor(t.Attributes, ci)
+		}
+	}
+
+	if p.currentSpriteCount == 0 || p.PPUMASK&PPUMASKSpriteShow == 0 {
+		return true
+	}
+
+	// Sprites, painted lowest priority first so a higher-priority opaque
+	// pixel overwrites; transparent pixels never write, which leaves the
+	// front-most *opaque* sprite at each x just as spritePixelAt finds it.
+	var (
+		sprColor [256]uint32
+		sprFront [256]bool
+		sprZero  [256]bool
+	)
+	minX := 0
+	if p.PPUMASK&PPUMASKSpriteLeft == 0 {
+		minX = 8
+	}
+	for i := p.currentSpriteCount - 1; i >= 0; i-- {
+		s := &p.currentSprites[i]
+		front := s.Attributes&SpritePriority == 0
+		zero := s.OAMIndex == 0
+		palette := s.Attributes & SpritePaletteMask
+		for px := 0; px < 8; px++ {
+			sx := int(s.X) + px
+			if sx >= 256 {
+				break
+			}
+			if sx < minX {
+				continue
+			}
+			bit := px
+			if s.Attributes&SpriteFlipHorizontal != 0 {
+				bit = 7 - px
+			}
+			ci := getPixelColor(s.PatternLo, s.PatternHi, bit)
+			if ci == 0 {
+				continue
+			}
+			sprColor[sx] = p.PaletteManager.GetSpriteColor(palette, ci)
+			sprFront[sx] = front
+			sprZero[sx] = zero
+		}
+	}
+
+	const bothLeft = PPUMASKBGLeft | PPUMASKSpriteLeft
+	for x := minX; x < 256; x++ {
+		c := sprColor[x]
+		if c&0xFF000000 == 0 {
+			continue
+		}
+		bgOpaque := bgIndex[x] != 0
+		if sprFront[x] || !bgOpaque {
+			row[x] = c
+		}
+		if sprZero[x] && bgOpaque && p.lineHitX < 0 && x != 255 &&
+			(x >= 8 || p.PPUMASK&bothLeft == bothLeft) {
+			p.lineHitX = x
+		}
+	}
+	return true
+}
+
+// splitScanline hands the rest of a batch-rendered line back to
+// renderPixel, starting at the current dot. Call it before any change to
+// state the renderer reads.
+func (p *PPU) splitScanline() {
+	p.lineRendered = false
+}
@@ -0,0 +1,270 @@
+package ppu
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/memory"
+)
+
+// patternCart serves CHR from a byte slice so tests can fill the pattern
+// tables with arbitrary data.
+type patternCart struct{ chr []uint8 }
+
+func (c patternCart) ReadCHR(a uint16) uint8              { return c.chr[a&0x1FFF] }
+func (c patternCart) ReadCHRSprite(a uint16) uint8        { return c.chr[a&0x1FFF] }
+func (patternCart) WriteCHR(uint16, uint8)                {}
+func (patternCart) Step()                                 {}
+func (patternCart) GetMirroring() cartridge.MirroringMode { return cartridge.MirroringVertical }
+func (patternCart) PPUAddressBus(uint16)                  {}
+func (patternCart) WatchesPatternFetches() bool           { return false }
+func (patternCart) SetSpriteSize(bool)                    {}
+func (patternCart) NotifyScanline(int, bool)              {}
+func (patternCart) HasExpansion() bool                    { return false }
+
+// latchCart models MMC2/MMC4-style CHR latches: each pattern table half
+// has two banks, and fetching the second plane of tile $FD/$FE selects
+// bank 0/1 for that half from the next fetch on.
+type latchCart struct {
+	patternCart
+	banks [2][]uint8
+	latch [2]int
+}
+
+func (c *latchCart) ReadCHR(a uint16) uint8 {
+	return c.banks[c.latch[a>>12&1]][a&0x1FFF]
+}
+func (c *latchCart) ReadCHRSprite(a uint16) uint8 { return c.ReadCHR(a) }
+func (c *latchCart) WatchesPatternFetches() bool  { return true }
+func (c *latchCart) PPUAddressBus(a uint16) {
+	switch a & 0x0FF8 {
+	case 0x0FD8:
+		c.latch[a>>12&1] = 0
+	case 0x0FE8:
+		c.latch[a>>12&1] = 1
+	}
+}
+
+// newScenePPU builds a PPU showing a pseudo-random scene: random CHR,
+// nametables, palette and OAM, fine-scrolled in both axes.
+func newScenePPU(seed int64, mask uint8) *PPU {
+	rng := rand.New(rand.NewSource(seed))
+	chr := make([]uint8, 0x2000)
+	rng.Read(chr)
+	return newScenePPUWith(rng, patternCart{chr}, mask)
+}
+
+// newLatchScenePPU is newScenePPU on a latchCart; the random nametables
+// contain plenty of $FD/$FE tiles, so banks switch mid-scanline.
+func newLatchScenePPU(seed int64, mask uint8) *PPU {
+	rng := rand.New(rand.NewSource(seed))
+	c := &latchCart{}
+	for i := range c.banks {
+		c.banks[i] = make([]uint8, 0x2000)
+		rng.Read(c.banks[i])
+	}
+	return newScenePPUWith(rng, c, mask)
+}
+
+func newScenePPUWith(rng *rand.Rand, cart CartridgeBus, mask uint8) *PPU {
+	p := New(memory.New())
+	p.Reset()
+	p.SetCartridge(cart)
+	for a := uint16(0x2000); a < 0x2800; a++ {
+		p.writeVRAM(a, uint8(rng.Intn(256)))
+	}
+	for a := uint16(0x3F00); a < 0x3F20; a++ {
+		p.writeVRAM(a, uint8(rng.Intn(64)))
+	}
+	rng.Read(p.OAM[:])
+	p.OAM[0], p.OAM[3] = 40, 60 // keep sprite 0 on-screen
+
+	p.WriteRegister(0x2000, PPUCTRLBGTable)
+	p.WriteRegister(0x2005, 13) // fine X = 5
+	p.WriteRegister(0x2005, 21)
+	p.WriteRegister(0x2001, mask)
+	p.StepN(341 * 262) // settle onto a full frame with the scroll in place
+	return p
+}
+
+// midLineWrite is a register write issued when the beam reaches dot.
+type midLineWrite struct {
+	line, dot int
+	addr      uint16
+	value     uint8
+}
+
+// runFrame steps p through one frame, issuing writes at their dots, and
+// returns the dot (line*341+cycle) at which the sprite-0 hit became
+// visible, or -1.
+func runFrame(p *PPU, writes []midLineWrite) int {
+	hitAt := -1
+	for p.Scanline != -1 || p.Cycle != 0 {
+		p.StepN(1)
+	}
+	for i := 0; i < 341*262; i++ {
+		for _, w := range writes {
+			if p.Scanline == w.line && p.Cycle == w.dot {
+				p.WriteRegister(w.addr, w.value)
+			}
+		}
+		p.StepN(1)
+		if hitAt < 0 && p.PPUSTATUS&PPUSTATUSSprite0Hit != 0 {
+			hitAt = p.Scanline*341 + p.Cycle
+		}
+	}
+	return hitAt
+}
+
+func TestScanlineRendererMatchesPixelRenderer(t *testing.T) {
+	const all = PPUMASKBGShow | PPUMASKSpriteShow | PPUMASKBGLeft | PPUMASKSpriteLeft
+	cases := []struct {
+		name   string
+		mask   uint8
+		writes []midLineWrite
+	}{
+		{"static", all, nil},
+		{"left clip", PPUMASKBGShow | PPUMASKSpriteShow, nil},
+		{"BG only", PPUMASKBGShow | PPUMASKBGLeft, nil},
+		{"sprites only", PPUMASKSpriteShow | PPUMASKSpriteLeft, nil},
+		{"mid-line scroll", all, []midLineWrite{
+			{line: 60, dot: 101, addr: 0x2005, value: 77},
+			{line: 60, dot: 101, addr: 0x2005, value: 3},
+			{line: 60, dot: 130, addr: 0x2006, value: 0x24},
+			{line: 60, dot: 130, addr: 0x2006, value: 0x62},
+		}},
+		{"mid-line pattern table", all, []midLineWrite{
+			{line: 90, dot: 50, addr: 0x2000, value: 0},
+		}},
+		{"mid-line mask", all, []midLineWrite{
+			{line: 120, dot: 70, addr: 0x2001, value: 0},
+			{line: 121, dot: 200, addr: 0x2001, value: all},
+		}},
+		{"mid-line palette", all, []midLineWrite{
+			{line: 150, dot: 10, addr: 0x2006, value: 0x3F},
+			{line: 150, dot: 10, addr: 0x2006, value: 0x01},
+			{line: 150, dot: 33, addr: 0x2007, value: 0x16},
+		}},
+	}
+	for _, c := range cases {
+		for seed := int64(1); seed <= 3; seed++ {
+			slow := newScenePPU(seed, c.mask)
+			fast := newScenePPU(seed, c.mask)
+			fast.SetScanlineRenderer(true)
+
+			slowHit := runFrame(slow, c.writes)
+			fastHit := runFrame(fast, c.writes)
+			if slowHit != fastHit {
+				t.Errorf("%s seed %d: sprite 0 hit at dot %d, per-pixel path %d", c.name, seed, fastHit, slowHit)
+			}
+			for i := range slow.FrameBuffer {
+				if slow.FrameBuffer[i] != fast.FrameBuffer[i] {
+					t.Errorf("%s seed %d: pixel (%d,%d) = %08X, per-pixel path %08X",
+						c.name, seed, i%256, i/256, fast.FrameBuffer[i], slow.FrameBuffer[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+// With a latching mapper the batch renderer must issue pattern fetches in
+// the same order as renderPixel, or the bank switch lands on another tile.
+func TestScanlineRendererMatchesPixelRendererWithCHRLatches(t *testing.T) {
+	const all = PPUMASKBGShow | PPUMASKSpriteShow | PPUMASKBGLeft | PPUMASKSpriteLeft
+	for seed := int64(1); seed <= 3; seed++ {
+		slow := newLatchScenePPU(seed, all)
+		fast := newLatchScenePPU(seed, all)
+		fast.SetScanlineRenderer(true)
+		runFrame(slow, nil)
+		runFrame(fast, nil)
+		for i := range slow.FrameBuffer {
+			if slow.FrameBuffer[i] != fast.FrameBuffer[i] {
+				t.Errorf("seed %d: pixel (%d,%d) = %08X, per-pixel path %08X",
+					seed, i%256, i/256, fast.FrameBuffer[i], slow.FrameBuffer[i])
+				break
+			}
+		}
+	}
+}
+
+func TestCHRFetchHookFollowsRead(t *testing.T) {
+	c := &latchCart{latch: [2]int{1, 1}}
+	for i := range c.banks {
+		c.banks[i] = make([]uint8, 0x2000)
+		for a := range c.banks[i] {
+			c.banks[i][a] = uint8(0x10 * (i + 1))
+		}
+	}
+	p := New(memory.New())
+	p.Reset()
+	p.SetCartridge(c)
+
+	// The fetch that hits the trigger still sees the old bank.
+	if got := p.readVRAM(0x0FD8); got != 0x20 {
+		t.Errorf("trigger fetch = %#02x, want 0x20 (bank before the switch)", got)
+	}
+	if got := p.readVRAM(0x0000); got != 0x10 {
+		t.Errorf("after $0FD8 = %#02x, want 0x10 (bank 0)", got)
+	}
+	p.readVRAM(0x0FE8)
+	if got := p.readVRAM(0x0000); got != 0x20 {
+		t.Errorf("after $0FE8 = %#02x, want 0x20 (bank 1)", got)
+	}
+	if got := p.readVRAM(0x1000); got != 0x20 {
+		t.Errorf("$1000 = %#02x, want 0x20: the high table has its own latch", got)
+	}
+
+	// A mapper without the hook is never notified.
+	p.SetCartridge(patternCart{c.banks[1]})
+	if p.chrFetchHook {
+		t.Error("chrFetchHook set for a cartridge that doesn't watch fetches")
+	}
+}
+
+func TestScanlineRendererSplitsOnMapperWrite(t *testing.T) {
+	p := newHitPPU(20, 10)
+	p.SetScanlineRenderer(true)
+	p.StepN(5)
+	if !p.lineRendered {
+		t.Fatal("line should have been batch-rendered")
+	}
+	p.Memory.Write(0x8000, 0)
+	if p.lineRendered {
+		t.Error("mapper register write should hand the line back to renderPixel")
+	}
+
+	p.SetScanlineRenderer(false)
+	p.StepN(341)
+	p.Memory.Write(0x8000, 0)
+	if p.lineRendered {
+		t.Error("scanline renderer still active after disabling it")
+	}
+}
+
+// benchmarkFrame renders whole frames of newScenePPU's random nametables
+// and 64 sprites, seeded so every run draws the same scene.
+func benchmarkFrame(b *testing.B, fast bool) {
+	p := newScenePPU(1, PPUMASKBGShow|PPUMASKSpriteShow|PPUMASKBGLeft|PPUMASKSpriteLeft)
+	p.SetScanlineRenderer(fast)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.StepN(341 * 262)
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "frames/s")
+}
+
+func BenchmarkFramePixelRenderer(b *testing.B)    { benchmarkFrame(b, false) }
+func BenchmarkFrameScanlineRenderer(b *testing.B) { benchmarkFrame(b, true) }
+
+func TestFrameRenderZeroAllocs(t *testing.T) {
+	for _, fast := range []bool{false, true} {
+		p := newScenePPU(1, PPUMASKBGShow|PPUMASKSpriteShow|PPUMASKBGLeft|PPUMASKSpriteLeft)
+		p.SetScanlineRenderer(fast)
+		if allocs := testing.AllocsPerRun(5, func() { p.StepN(341 * 262) }); allocs != 0 {
+			t.Errorf("scanline renderer %v: %.1f allocations per frame, want 0", fast, allocs)
+		}
+	}
+}
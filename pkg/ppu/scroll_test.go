@@ -0,0 +1,152 @@
+package ppu
+
+import "testing"
+
+// regOp is one CPU access in a scroll-register sequence: a write of
+// value to addr, or a read when read is set.
+type regOp struct {
+	addr  uint16
+	value uint8
+	read  bool
+}
+
+func wr(addr uint16, value uint8) regOp { return regOp{addr: addr, value: value} }
+func rd(addr uint16) regOp              { return regOp{addr: addr, read: true} }
+
+// TestScrollRegisterMatrix runs $2000/$2002/$2005/$2006 sequences with
+// rendering off and checks v, t, x and w after them, per the NESdev
+// "PPU scrolling" register summary. Every case starts from v = t = 0,
+// x = 0, w = 0.
+func TestScrollRegisterMatrix(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		ops  []regOp
+		v, t uint16
+		x, w uint8
+	}{
+		{name: "$2000 sets the nametable bits", ops: []regOp{wr(0x2000, 0x03)}, t: 0x0C00},
+		{name: "$2000 leaves w", ops: []regOp{wr(0x2005, 0x00), wr(0x2000, 0x01)}, t: 0x0400, w: 1},
+		{name: "$2005 first write: coarse X and fine X", ops: []regOp{wr(0x2005, 0x7D)}, t: 0x000F, x: 5, w: 1},
+		{name: "$2005 second write: coarse Y and fine Y", ops: []regOp{wr(0x2005, 0x7D), wr(0x2005, 0x5E)}, t: 0x616F, x: 5},
+		{name: "$2006 first write: high byte, bit 14 cleared",
+			ops: []regOp{wr(0x2005, 0xF8), wr(0x2005, 0xFF), wr(0x2006, 0x3D)}, t: 0x3DFF, w: 1},
+		{name: "$2006 second write copies t to v",
+			ops: []regOp{wr(0x2006, 0x3D), wr(0x2006, 0xF0)}, v: 0x3DF0, t: 0x3DF0},
+		{name: "NESdev worked example",
+			ops: []regOp{wr(0x2000, 0x00), rd(0x2002), wr(0x2005, 0x7D), wr(0x2005, 0x5E), wr(0x2006, 0x3D), wr(0x2006, 0xF0)},
+			v:   0x3DF0, t: 0x3DF0, x: 5},
+		{name: "$2002 read resets w between $2005 writes",
+			ops: []regOp{wr(0x2005, 0x10), rd(0x2002), wr(0x2005, 0x28)}, t: 0x0005, x: 0, w: 1},
+		{name: "$2002 read resets w between $2006 writes",
+			ops: []regOp{wr(0x2006, 0x21), rd(0x2002), wr(0x2006, 0x08)}, t: 0x0800, w: 1},
+		{name: "$2005 then $2006 share w",
+			ops: []regOp{wr(0x2005, 0x0B), wr(0x2006, 0x10)}, v: 0x0010, t: 0x0010, x: 3},
+		{name: "$2006 then $2005 share w",
+			ops: []regOp{wr(0x2006, 0x04), wr(0x2005, 0x3A)}, t: 0x24E0},
+		{name: "split-scroll idiom: $2006, $2005, $2005, $2006",
+			ops: []regOp{wr(0x2006, 0x04), wr(0x2005, 0x3A), wr(0x2005, 0x0D), wr(0x2006, 0x81)},
+			v:   0x2481, t: 0x2481, x: 5},
+		{name: "fine X survives a later $2006 pair",
+			ops: []regOp{wr(0x2005, 0x07), wr(0x2005, 0x00), wr(0x2006, 0x23), wr(0x2006, 0xC0)},
+			v:   0x23C0, t: 0x23C0, x: 7},
+		{name: "$2007 moves v, not t",
+			ops: []regOp{wr(0x2006, 0x20), wr(0x2006, 0x00), wr(0x2007, 0)}, v: 0x2001, t: 0x2000},
+	} {
+		p := createTestPPU()
+		for _, op := range tc.ops {
+			if op.read {
+				p.ReadRegister(op.addr)
+			} else {
+				p.WriteRegister(op.addr, op.value)
+			}
+		}
+		if p.v != tc.v || p.t != tc.t || p.x != tc.x || p.w != tc.w {
+			t.Errorf("%s: v=$%04X t=$%04X x=%d w=%d, want v=$%04X t=$%04X x=%d w=%d",
+				tc.name, p.v, p.t, p.x, p.w, tc.v, tc.t, tc.x, tc.w)
+		}
+	}
+}
+
+// stepTo runs p until the beam is at (line, dot).
+func stepTo(p *PPU, line, dot int) {
+	for p.Scanline != line || p.Cycle != dot {
+		p.StepN(1)
+	}
+}
+
+// TestScrollCopyDots checks that the renderer's t-to-v copies happen on
+// their exact dots: horizontal bits at dot 257 of each rendering line,
+// vertical bits on every dot 280-304 of the pre-render line.
+func TestScrollCopyDots(t *testing.T) {
+	p := newFetchPPU(false)
+
+	// A t write before dot 257 is in v's horizontal bits after it.
+	stepTo(p, 50, 200)
+	p.WriteRegister(0x2005, 0x50) // coarse X 10
+	p.WriteRegister(0x2005, 0x00)
+	stepTo(p, 50, 258)
+	if got := p.v & 0x041F; got != 0x000A {
+		t.Errorf("horizontal bits after dot 257 = $%04X, want $000A", got)
+	}
+	// One landing after it waits for the next line.
+	p.WriteRegister(0x2005, 0x18) // coarse X 3
+	p.WriteRegister(0x2005, 0x00)
+	stepTo(p, 50, 320)
+	if got := p.v & 0x041F; got != 0x000A {
+		t.Errorf("horizontal bits after a write past dot 257 = $%04X, want $000A until the next line", got)
+	}
+	stepTo(p, 51, 258)
+	if got := p.v & 0x041F; got != 0x0003 {
+		t.Errorf("next line's horizontal bits = $%04X, want $0003", got)
+	}
+
+	// Vertical bits: the copy starts at dot 280, and a t write in the
+	// middle of the window still lands.
+	stepTo(p, -1, 270)
+	p.WriteRegister(0x2005, 0x00)
+	p.WriteRegister(0x2005, 0x53) // coarse Y 10, fine Y 3
+	stepTo(p, -1, 285)
+	if got := p.v & 0x7BE0; got != 0x3140 {
+		t.Errorf("vertical bits at dot 285 = $%04X, want $3140", got)
+	}
+	stepTo(p, -1, 295)
+	p.WriteRegister(0x2005, 0x00)
+	p.WriteRegister(0x2005, 0x29) // coarse Y 5, fine Y 1
+	stepTo(p, -1, 306)
+	if got := p.v & 0x7BE0; got != 0x10A0 {
+		t.Errorf("vertical bits after a write at dot 295 = $%04X, want $10A0", got)
+	}
+	p.WriteRegister(0x2005, 0x00)
+	p.WriteRegister(0x2005, 0x00)
+	stepTo(p, 0, 2) // not dot 0, which odd frames skip
+	if got := p.v & 0x7BE0; got != 0x10A0 {
+		t.Errorf("vertical bits after a write at dot 306 = $%04X, want $10A0 kept", got)
+	}
+}
+
+// TestMidLineFineX checks a first $2005 write changes fine X at once:
+// the rest of the line shifts, and the pixels already drawn stay.
+func TestMidLineFineX(t *testing.T) {
+	for _, fast := range []bool{false, true} {
+		p := newFetchPPU(fast)
+		// Column 8 of nametable 0 becomes tile 2 (colour 3), so the
+		// boundary at x = 64 shows where the shift starts.
+		p.writeVRAM(0x2000+100/8*32+8, 2)
+		p.writeVRAM(0x2000+100/8*32+16, 2)
+		runFrame(p, []midLineWrite{{line: 100, dot: 100, addr: 0x2005, value: 0x04}})
+		row := p.FrameBuffer[100*256 : 101*256]
+		c1 := p.PaletteManager.GetBackgroundColor(0, 1)
+		c3 := p.PaletteManager.GetBackgroundColor(0, 3)
+		if row[64] != c3 || row[63] != c1 {
+			t.Errorf("fast=%v: column 8 doesn't start at x=64 before the write", fast)
+		}
+		// After the write fine X is 4: column 16 (x 128-135) appears 4
+		// pixels early.
+		if row[124] != c3 || row[123] != c1 {
+			t.Errorf("fast=%v: column 16 doesn't start at x=124 after fine X 4", fast)
+		}
+		if p.x != 4 {
+			t.Errorf("fast=%v: x = %d, want 4", fast, p.x)
+		}
+	}
+}
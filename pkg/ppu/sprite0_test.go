@@ -0,0 +1,173 @@
+package ppu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/memory"
+)
+
+// solidCHRCart is a minimal PPU cartridge whose every pattern byte is $FF,
+// so every BG and sprite pixel is opaque (colour index 3). That reduces
+// sprite-0-hit tests to pure geometry/timing questions.
+type solidCHRCart struct{}
+
+func (solidCHRCart) ReadCHR(uint16) uint8                  { return 0xFF }
+func (solidCHRCart) ReadCHRSprite(uint16) uint8            { return 0xFF }
+func (solidCHRCart) WriteCHR(uint16, uint8)                {}
+func (solidCHRCart) Step()                                 {}
+func (solidCHRCart) GetMirroring() cartridge.MirroringMode { return cartridge.MirroringHorizontal }
+func (solidCHRCart) PPUAddressBus(uint16)                  {}
+func (solidCHRCart) WatchesPatternFetches() bool           { return false }
+func (solidCHRCart) SetSpriteSize(bool)                    {}
+func (solidCHRCart) NotifyScanline(int, bool)              {}
+func (solidCHRCart) HasExpansion() bool                    { return false }
+
+// newHitPPU returns a PPU with BG+sprites (including the left column) on,
+// sprite 0 placed at screen (x, line), and the beam parked at the start of
+// that line, past the previous line's fetches of its first two tiles.
+func newHitPPU(x uint8, line int) *PPU {
+	p := New(memory.New())
+	p.Reset()
+	p.SetCartridge(solidCHRCart{})
+	p.PPUMASK = PPUMASKBGShow | PPUMASKSpriteShow | PPUMASKBGLeft | PPUMASKSpriteLeft
+	p.refreshDerivedCtrl()
+	for i := range p.OAM {
+		p.OAM[i] = 0xFF // park every other sprite off-screen
+	}
+	p.OAM[0] = uint8(line - 1) // OAM Y is screen Y - 1
+	p.OAM[1] = 0
+	p.OAM[2] = 0
+	p.OAM[3] = x
+	p.Scanline = line - 1
+	p.Cycle = 320
+	p.StepN(341 - 320)
+	return p
+}
+
+func hitSet(p *PPU) bool { return p.PPUSTATUS&PPUSTATUSSprite0Hit != 0 }
+
+// TestSprite0HitOneDotLatency: the overlap is detected while producing pixel
+// x (cycle x in this model) but only becomes visible in PPUSTATUS on the
+// following dot.
+func TestSprite0HitOneDotLatency(t *testing.T) {
+	p := newHitPPU(20, 10)
+
+	p.StepN(21) // cycles 0..20 — pixel 20 is the first overlapping pixel
+	if hitSet(p) {
+		t.Fatal("hit visible on the same dot as the overlapping pixel")
+	}
+	if !p.sprite0HitPending {
+		t.Fatal("overlap at x=20 should have armed the pending hit")
+	}
+	p.StepN(1)
+	if !hitSet(p) {
+		t.Fatal("hit should be visible one dot after the overlapping pixel")
+	}
+
+	// Latched for the rest of the frame, cleared at pre-render.
+	p.StepN(341 * (261 - 10))
+	if p.Scanline != -1 || hitSet(p) {
+		t.Errorf("at scanline %d hit=%v, want cleared on pre-render", p.Scanline, hitSet(p))
+	}
+}
+
+func TestSprite0HitNotAtX255(t *testing.T) {
+	// Sprite at x=255 overlaps only the last column.
+	p := newHitPPU(255, 10)
+	p.StepN(341)
+	if hitSet(p) || p.sprite0HitPending {
+		t.Error("sprite 0 hit must never fire at x=255")
+	}
+
+	// One column further left it does.
+	p = newHitPPU(254, 10)
+	p.StepN(341)
+	if !hitSet(p) {
+		t.Error("sprite 0 at x=254 should hit")
+	}
+}
+
+func TestSprite0HitLeftClip(t *testing.T) {
+	cases := []struct {
+		name string
+		mask uint8
+		want bool
+	}{
+		{"both columns shown", PPUMASKBGLeft | PPUMASKSpriteLeft, true},
+		{"BG clipped", PPUMASKSpriteLeft, false},
+		{"sprites clipped", PPUMASKBGLeft, false},
+		{"both clipped", 0, false},
+	}
+	for _, c := range cases {
+		// Sprite at x=0 covers only columns 0-7, entirely inside the clip.
+		p := newHitPPU(0, 10)
+		p.PPUMASK = PPUMASKBGShow | PPUMASKSpriteShow | c.mask
+		p.refreshDerivedCtrl()
+		p.StepN(341)
+		if hitSet(p) != c.want {
+			t.Errorf("%s: hit=%v, want %v", c.name, hitSet(p), c.want)
+		}
+	}
+
+	// A sprite straddling the clip boundary hits at x=8 even when clipped.
+	p := newHitPPU(4, 10)
+	p.PPUMASK = PPUMASKBGShow | PPUMASKSpriteShow
+	p.refreshDerivedCtrl()
+	p.StepN(8 + 1) // cycles 0..8
+	if !p.sprite0HitPending {
+		t.Error("straddling sprite should arm the hit at x=8")
+	}
+}
+
+func TestSprite0HitNeedsBothLayers(t *testing.T) {
+	for _, mask := range []uint8{PPUMASKBGShow, PPUMASKSpriteShow} {
+		p := newHitPPU(20, 10)
+		p.PPUMASK = mask | PPUMASKBGLeft | PPUMASKSpriteLeft
+		p.refreshDerivedCtrl()
+		p.StepN(341)
+		if hitSet(p) {
+			t.Errorf("PPUMASK=%02X: hit with only one layer enabled", p.PPUMASK)
+		}
+	}
+}
+
+// TestSprite0HitNoStaleSprites: a scanline that starts with rendering off
+// performs no sprite evaluation, so turning rendering on mid-line must not
+// reuse the previous line's sprites.
+func TestSprite0HitNoStaleSprites(t *testing.T) {
+	p := newHitPPU(100, 10)
+	p.StepN(341) // line 10 evaluates and hits sprite 0
+	p.PPUSTATUS &^= PPUSTATUSSprite0Hit
+
+	mask := p.PPUMASK
+	p.PPUMASK = 0
+	p.refreshDerivedCtrl()
+	p.StepN(50) // line 11 starts with rendering off
+	p.PPUMASK = mask
+	p.refreshDerivedCtrl()
+	p.StepN(341 - 50)
+	if hitSet(p) {
+		t.Error("hit fired from a sprite evaluated on an earlier scanline")
+	}
+}
+
+func TestSprite0HitPendingSaveState(t *testing.T) {
+	p := newHitPPU(20, 10)
+	p.StepN(21)
+	if !p.sprite0HitPending {
+		t.Fatal("setup: hit should be pending")
+	}
+	var buf bytes.Buffer
+	if err := p.SaveState(&buf); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	q := New(memory.New())
+	if err := q.LoadState(&buf); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if !q.sprite0HitPending {
+		t.Error("pending hit lost across save/load")
+	}
+}
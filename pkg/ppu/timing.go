@@ -0,0 +1,281 @@
+package ppu
+
+// PPU timing as a (scanline, dot) state machine.
+//
+// A frame is 262 lines of 341 dots: the pre-render line (-1), 240 visible
+// lines, the idle post-render line (240) and the vertical blank (241-260).
+// Everything the PPU does on a given dot is listed in dotTable, one row of
+// dotActions per kind of line, and StepN just walks the beam through it,
+// running each dot's actions in a fixed order. Adding a timed event means
+// marking its dots in buildDotTable, not threading another condition
+// through the loop.
+//
+// The table is in the emulator's dots, which run one behind hardware's at
+// the line's end: step n finishes what hardware does up to the start of
+// dot n+1. So the horizontal t→v copy NESdev places at dot 257 is marked
+// on dot 256, after its Y increment, and the pre-render vertical copy over
+// dots 280-304 on 279-303. The VBlank set and the pre-render flag clear
+// sit on the last dot of lines 240 and 260, just before the beam wraps.
+//
+// Two events aren't in the table because their dot moves at runtime: the
+// MMC3 A12 clock (PPU.a12ClockCycle, armed by the pattern fetches in
+// fetchA12) and the odd-frame skip, which starts line 0 at dot 1 when the
+// frame is odd and the background is on.
+
+// dotAction is the set of things the PPU does on one dot.
+type dotAction uint16
+
+const (
+	// dotSpriteEval evaluates the line's sprites (and, with the scanline
+	// renderer on, draws the whole line) before its first pixel.
+	dotSpriteEval dotAction = 1 << iota
+	// dotPixel produces the dot's pixel.
+	dotPixel
+	// dotFetch completes a background fetch slot: stores the tile v
+	// points at and steps v's coarse X (see fetchTileSlot).
+	dotFetch
+	// dotIncY steps v to the next line (see incrementY).
+	dotIncY
+	// dotCopyH copies t's horizontal scroll bits into v.
+	dotCopyH
+	// dotSpriteFetch starts the sprite fetch slots for the next line,
+	// working out the pattern addresses they read (spriteFetchAddrs).
+	dotSpriteFetch
+	// dotSpritePattern completes a sprite fetch slot, putting its pattern
+	// address on the bus for the A12 tracking.
+	dotSpritePattern
+	// dotCopyV copies t's vertical scroll bits into v.
+	dotCopyV
+	// dotSetVBlank raises the VBlank flag and arms the NMI.
+	dotSetVBlank
+	// dotEndFrame clears VBlank, sprite 0 hit and overflow and marks the
+	// frame complete.
+	dotEndFrame
+)
+
+// dotRendering is the v-register actions renderingDot runs; like
+// dotSpriteFetch they only happen while rendering is on.
+const dotRendering = dotFetch | dotIncY | dotCopyH | dotCopyV
+
+// Line kinds: a row of dotTable each.
+const (
+	lineVisible = iota
+	linePreRender
+	linePostRender
+	lineVBlank
+	lineLastVBlank
+	lineKinds
+)
+
+// dotsPerLine is the length of every line; the odd-frame skip shortens a
+// frame by starting a line late, not by shortening one.
+const dotsPerLine = 341
+
+var dotTable = buildDotTable()
+
+func buildDotTable() (t [lineKinds][dotsPerLine]dotAction) {
+	for _, kind := range []int{lineVisible, linePreRender} {
+		row := &t[kind]
+		// Background fetch slots: tiles 2-33 of the line end on dots
+		// 7, 15, …, 255; tiles 0 and 1 of the next on 327 and 335.
+		for dot := 7; dot < 256; dot += 8 {
+			row[dot] |= dotFetch
+		}
+		row[327] |= dotFetch
+		row[335] |= dotFetch
+		row[256] |= dotIncY | dotCopyH
+		row[257] |= dotSpriteFetch
+		// Sprite fetch slots: 257-264 through 313-320 on hardware.
+		for dot := 263; dot < 320; dot += 8 {
+			row[dot] |= dotSpritePattern
+		}
+	}
+	row := &t[lineVisible]
+	row[0] |= dotSpriteEval
+	for dot := 0; dot < 256; dot++ {
+		row[dot] |= dotPixel
+	}
+	for dot := 279; dot <= 303; dot++ {
+		t[linePreRender][dot] |= dotCopyV
+	}
+	t[linePostRender][dotsPerLine-1] |= dotSetVBlank
+	t[lineLastVBlank][dotsPerLine-1] |= dotEndFrame
+	return t
+}
+
+// lineKind returns the dotTable row for scanline.
+func lineKind(scanline int) int {
+	switch {
+	case scanline < 0:
+		return linePreRender
+	case scanline < 240:
+		return lineVisible
+	case scanline == 240:
+		return linePostRender
+	case scanline == 260:
+		return lineLastVBlank
+	}
+	return lineVBlank
+}
+
+// Step executes one PPU cycle. Equivalent to StepN(1); retained for
+// single-cycle callers (unit tests).
+func (p *PPU) Step() { p.StepN(1) }
+
+// StepN executes n PPU cycles. nes.Step calls this once per CPU cycle batch
+// (3 PPU cycles per CPU cycle) rather than calling Step in a loop, so the
+// per-cycle Cycle/Scanline counters live in locals for the whole batch instead
+// of being reloaded from the struct around every internal call (renderPixel,
+// Cartridge.Step, etc., which the compiler must assume could alias p.Cycle/
+// p.Scanline). They're written back before returning. Nothing outside the PPU
+// reads p.Cycle/p.Scanline mid-batch — only CPU-side register access does, and
+// that never runs while StepN is executing.
+func (p *PPU) StepN(n int) {
+	cycle, scanline := p.Cycle, p.Scanline
+	dots := &dotTable[lineKind(scanline)]
+	for i := 0; i < n; i++ {
+		// Commit a sprite-0 hit detected while producing last dot's pixel.
+		if p.sprite0HitPending {
+			p.PPUSTATUS |= PPUSTATUSSprite0Hit
+			p.sprite0HitPending = false
+		}
+
+		// NMI-assertion countdown — see PPU.nmiAssertCountdown. Tick down
+		// here so the assertion lands N PPU cycles after the VBL set Step.
+		// Re-check VBL at expiry: if a CPU $2002 read cleared the flag during
+		// the countdown window, NMI is suppressed (blargg suppression test
+		// rows 05-06: flag read back as set but NMI never fires because the
+		// quickly-cleared flag never held the NMI line low long enough).
+		if p.nmiAssertCountdown > 0 {
+			p.nmiAssertCountdown--
+			if p.nmiAssertCountdown == 0 && p.PPUCTRL&PPUCTRLNMIEnable != 0 && p.PPUSTATUS&PPUSTATUSVBlank != 0 {
+				p.NMIRequested = true
+			}
+		}
+
+		acts := dots[cycle]
+		if acts&dotSpriteEval != 0 {
+			p.evaluateLine(scanline)
+			if p.scanlineRenderer {
+				p.lineRendered = p.renderScanline(scanline)
+			}
+		}
+		if acts&dotPixel != 0 {
+			if !p.lineRendered {
+				p.renderPixel(cycle, scanline)
+			} else if cycle == p.lineHitX {
+				p.checkSprite0Hit(cycle)
+			}
+		}
+
+		// An A12 rise one of the pattern fetches below made a few dots ago
+		// clocks the mapper now (fetchA12).
+		if cycle == p.a12ClockCycle {
+			p.a12ClockCycle = -1
+			p.Cartridge.Step()
+		}
+
+		// The pre-render and visible lines, with rendering on: fetches,
+		// v's increments and copies. The pattern fetches drive A12 — the
+		// BG slots' in fetchTileSlot, the sprite slots' here.
+		if scanline < 240 && p.renderEnabled {
+			if acts&dotSpriteFetch != 0 {
+				p.spriteFetchAddrs(scanline + 1)
+			}
+			if acts&dotSpritePattern != 0 {
+				p.fetchA12(p.spriteFetches[(cycle-263)>>3], cycle)
+			}
+			if acts&dotRendering != 0 {
+				p.renderingDot(acts, cycle, scanline)
+			}
+		}
+
+		if acts&dotSetVBlank != 0 {
+			// VBlank start: set VBlank flag immediately so a CPU $2002
+			// read here observes it (vbl_set_time T+5). The NMI assertion
+			// is deferred by nmiAssertDelayPPUCycles so nmi_timing's
+			// calibration table lands on the right CPU instruction.
+			if !p.vblSuppressed {
+				p.PPUSTATUS |= PPUSTATUSVBlank
+				if p.PPUCTRL&PPUCTRLNMIEnable != 0 {
+					p.nmiAssertCountdown = nmiAssertDelayPPUCycles
+				}
+			}
+			p.vblSuppressed = false
+		}
+		if acts&dotEndFrame != 0 {
+			// Pre-render line: clear VBlank, sprite 0 hit, and sprite
+			// overflow flags. NESdev says this is at cycle 1 of pre-render;
+			// doing it here at the (260, 340) → (-1, 0) wrap places it one
+			// PPU cycle earlier in absolute terms. Tests vbl_clear_time /
+			// suppression pass with this earlier timing — moving the clear
+			// to (-1, 1) breaks test 3's row-06 expectation.
+			p.PPUSTATUS &^= PPUSTATUSVBlank | PPUSTATUSSprite0Hit | PPUSTATUSSpriteOverflow
+			p.FrameComplete = true
+			p.Frame++
+			p.oddFrame = !p.oddFrame
+			p.warmUp = false
+		}
+
+		cycle++
+		if cycle < dotsPerLine {
+			continue
+		}
+		cycle = 0
+		p.lineRendered = false
+		p.refreshMirroringCache()
+		scanline++
+		if scanline > 260 {
+			scanline = -1
+		}
+		dots = &dotTable[lineKind(scanline)]
+		// Odd-frame skip: NTSC PPU drops the first idle tick (cycle 0) of
+		// scanline 0 on odd frames when background rendering is enabled.
+		// Realised here by starting the new visible scanline at cycle 1
+		// instead of 0 under those conditions.
+		if scanline == 0 && p.oddFrame && p.PPUMASK&PPUMASKBGShow != 0 {
+			cycle = 1
+		}
+		// Tell the mapper about the new rendering scanline. MMC5 uses
+		// this for its scanline-match IRQ; other mappers ignore it.
+		if p.Cartridge != nil && scanline >= 0 && scanline < 240 {
+			p.Cartridge.NotifyScanline(scanline, p.renderingEnabled())
+		}
+	}
+	p.Cycle, p.Scanline = cycle, scanline
+}
+
+// renderingDot runs a dot's rendering-only actions, in hardware order.
+func (p *PPU) renderingDot(acts dotAction, cycle, scanline int) {
+	if acts&dotFetch != 0 {
+		p.fetchTileSlot(cycle, scanline)
+	}
+	if acts&dotIncY != 0 {
+		p.incrementY()
+	}
+	// Copying horizontal bits once the line's fetches are done, ahead of
+	// the next line's first two tile fetches at 327/335, means a
+	// $2000/$2005 write later in hblank (after an MMC3 IRQ, say) lands a
+	// line later, as on hardware, rather than on whichever line the IRQ
+	// latency happens to reach.
+	if acts&dotCopyH != 0 {
+		p.v = (p.v & 0xFBE0) | (p.t & 0x041F)
+	}
+	// Hardware repeats the vertical copy on every dot from 280 to 304, so
+	// a t write landing inside that window still reaches v.
+	if acts&dotCopyV != 0 {
+		p.v = (p.v & 0x841F) | (p.t & 0x7BE0)
+	}
+}
+
+// evaluateLine is the sprite evaluation for a visible line. With
+// rendering off no evaluation runs, so last line's sprites are dropped —
+// otherwise re-enabling rendering mid-line would draw (and sprite-0-hit
+// against) stale secondary OAM.
+func (p *PPU) evaluateLine(scanline int) {
+	if !p.renderEnabled {
+		p.currentSpriteCount = 0
+		return
+	}
+	p.evaluateSprites(scanline)
+}
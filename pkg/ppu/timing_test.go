@@ -0,0 +1,138 @@
+package ppu
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// timingEvent is one observable change a single dot made.
+type timingEvent struct {
+	line, dot int
+	what      string // "v", "vblank", "clear", "nmi"
+}
+
+func (e timingEvent) String() string { return fmt.Sprintf("%s@%d:%d", e.what, e.line, e.dot) }
+
+// traceTiming steps p one dot at a time for dots dots and records, for
+// each dot, what it changed: v, the VBlank flag (set or cleared) and the
+// NMI request.
+func traceTiming(p *PPU, dots int) []timingEvent {
+	var events []timingEvent
+	for i := 0; i < dots; i++ {
+		line, dot := p.Scanline, p.Cycle
+		v, status, nmi := p.v, p.PPUSTATUS, p.NMIRequested
+		p.StepN(1)
+		if p.v != v {
+			events = append(events, timingEvent{line, dot, "v"})
+		}
+		switch {
+		case p.PPUSTATUS&PPUSTATUSVBlank != 0 && status&PPUSTATUSVBlank == 0:
+			events = append(events, timingEvent{line, dot, "vblank"})
+		case p.PPUSTATUS&PPUSTATUSVBlank == 0 && status&PPUSTATUSVBlank != 0:
+			events = append(events, timingEvent{line, dot, "clear"})
+		}
+		if p.NMIRequested && !nmi {
+			events = append(events, timingEvent{line, dot, "nmi"})
+		}
+	}
+	return events
+}
+
+// onLine filters events to one line and kind.
+func onLine(events []timingEvent, line int, what string) []int {
+	var dots []int
+	for _, e := range events {
+		if e.line == line && e.what == what {
+			dots = append(dots, e.dot)
+		}
+	}
+	return dots
+}
+
+func TestDotTable(t *testing.T) {
+	count := func(kind int, a dotAction) (n int) {
+		for _, acts := range dotTable[kind] {
+			if acts&a != 0 {
+				n++
+			}
+		}
+		return n
+	}
+	for _, tc := range []struct {
+		kind int
+		a    dotAction
+		want int
+	}{
+		{lineVisible, dotPixel, 256},
+		{lineVisible, dotSpriteEval, 1},
+		{lineVisible, dotFetch, 34},
+		{linePreRender, dotFetch, 34},
+		{linePreRender, dotPixel, 0},
+		{linePreRender, dotCopyV, 25},
+		{lineVisible, dotCopyV, 0},
+		{linePostRender, dotSetVBlank, 1},
+		{lineVBlank, ^dotAction(0), 0},
+		{lineLastVBlank, dotEndFrame, 1},
+	} {
+		if got := count(tc.kind, tc.a); got != tc.want {
+			t.Errorf("line kind %d has %d dots with actions %#x, want %d", tc.kind, got, tc.a, tc.want)
+		}
+	}
+	for line, want := range map[int]int{-1: linePreRender, 0: lineVisible, 239: lineVisible, 240: linePostRender, 241: lineVBlank, 259: lineVBlank, 260: lineLastVBlank} {
+		if got := lineKind(line); got != want {
+			t.Errorf("lineKind(%d) = %d, want %d", line, got, want)
+		}
+	}
+}
+
+// TestTimingTrace runs a frame dot by dot and checks when v moves and
+// the VBlank flag and NMI change.
+func TestTimingTrace(t *testing.T) {
+	p := newFetchPPU(false)
+	p.WriteRegister(0x2000, PPUCTRLNMIEnable)
+	p.WriteRegister(0x2005, 0)
+	p.WriteRegister(0x2005, 0x10) // coarse Y 2: the pre-render copy moves v
+	events := traceTiming(p, 341*262)
+
+	var fetches []int
+	for dot := 7; dot < 256; dot += 8 {
+		fetches = append(fetches, dot)
+	}
+	visible := append(append([]int{}, fetches...), 256, 327, 335)
+	if got := onLine(events, 100, "v"); !reflect.DeepEqual(got, visible) {
+		t.Errorf("line 100 moves v on dots %v, want %v", got, visible)
+	}
+	// The pre-render line's first copy changes v; the repeats copy the
+	// same bits again and don't.
+	preRender := append(append([]int{}, fetches...), 256, 279, 327, 335)
+	if got := onLine(events, -1, "v"); !reflect.DeepEqual(got, preRender) {
+		t.Errorf("pre-render line moves v on dots %v, want %v", got, preRender)
+	}
+	if got := onLine(events, 241, "v"); got != nil {
+		t.Errorf("vblank line moves v on dots %v", got)
+	}
+
+	var flags []timingEvent
+	for _, e := range events {
+		if e.what != "v" {
+			flags = append(flags, e)
+		}
+	}
+	want := []timingEvent{
+		{240, 340, "vblank"},
+		{241, nmiAssertDelayPPUCycles - 1, "nmi"},
+		{260, 340, "clear"},
+	}
+	if !reflect.DeepEqual(flags, want) {
+		t.Errorf("flag events %v, want %v", flags, want)
+	}
+
+	// With rendering off the beam runs the same lines but v stays put.
+	p.WriteRegister(0x2001, 0)
+	for _, e := range traceTiming(p, 341*262) {
+		if e.what == "v" {
+			t.Fatalf("v moved at %v with rendering off", e)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package ppulog records every CPU write to the PPU registers ($2000-$2007
+// and their mirrors) and to OAM DMA ($4014), stamped with the frame,
+// scanline and dot the PPU had reached. Laid out a frame at a time it
+// shows when a game changes scroll, turns rendering on and off or feeds
+// VRAM — the usual way to chase a split that lands on the wrong line.
+//
+// The stamp is the PPU position at the start of the writing instruction:
+// the core applies a register write before the PPU catches up with the
+// instruction's cycles, so that is when the write takes effect here too.
+package ppulog
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/yoshiomiyamaegones/pkg/memory"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// Write is one register write.
+type Write struct {
+	Frame    uint64 // ppu.PPU.Frame
+	Scanline int    // -1 for the pre-render line
+	Dot      int    // 0-340
+	PC       uint16 // the writing instruction
+	Register uint16 // $2000-$2007 with mirrors folded, or $4014
+	Value    uint8
+}
+
+// registerNames are the conventional names of $2000-$2007.
+var registerNames = [8]string{"PPUCTRL", "PPUMASK", "PPUSTATUS", "OAMADDR", "OAMDATA", "PPUSCROLL", "PPUADDR", "PPUDATA"}
+
+// RegisterName is the conventional name of a Write.Register.
+func RegisterName(reg uint16) string {
+	if reg == 0x4014 {
+		return "OAMDMA"
+	}
+	return registerNames[reg&7]
+}
+
+// String renders w for a debugger listing:
+//
+//	F123 SL 31 DOT 256  $C123  PPUSCROLL $2005 = $08
+func (w Write) String() string {
+	return fmt.Sprintf("F%d SL %d DOT %d  $%04X  %-9s $%04X = $%02X",
+		w.Frame, w.Scanline, w.Dot, w.PC, RegisterName(w.Register), w.Register, w.Value)
+}
+
+// Log records the writes of one NES. Like the machine, it isn't safe for
+// concurrent use.
+type Log struct {
+	nes   *nes.NES
+	hooks [2]memory.HookID
+
+	// current collects the frame being run; last is the one EndFrame
+	// finished before it.
+	current, last []Write
+}
+
+// Start installs a log on n's bus. Stop removes it.
+func Start(n *nes.NES) *Log {
+	l := &Log{nes: n}
+	l.hooks = [2]memory.HookID{
+		n.Memory.AddWriteHook(0x2000, 0x3FFF, l.record),
+		n.Memory.AddWriteHook(0x4014, 0x4014, l.record),
+	}
+	return l
+}
+
+// Stop uninstalls the log's bus hooks; what it recorded stays readable.
+func (l *Log) Stop() {
+	for _, id := range l.hooks {
+		l.nes.Memory.RemoveHook(id)
+	}
+}
+
+func (l *Log) record(addr uint16, value uint8) uint8 {
+	p := l.nes.PPU
+	reg := addr
+	if addr < 0x4000 {
+		reg = 0x2000 + addr&7
+	}
+	l.current = append(l.current, Write{
+		Frame:    p.Frame,
+		Scanline: p.Scanline,
+		Dot:      p.Cycle,
+		PC:       l.nes.CPU.InstructionPC(),
+		Register: reg,
+		Value:    value,
+	})
+	return value
+}
+
+// EndFrame is called after each StepFrame: the frame's writes become
+// LastFrame and recording starts afresh.
+func (l *Log) EndFrame() {
+	l.last, l.current = l.current, l.last[:0]
+}
+
+// LastFrame returns the writes of the frame EndFrame last finished, in
+// order. The slice is only valid until the next EndFrame.
+func (l *Log) LastFrame() []Write { return l.last }
+
+// Current returns the writes made so far in the frame being run — where
+// a debugger stopped, say. The slice is only valid until the next write.
+func (l *Log) Current() []Write { return l.current }
+
+// WriteCSV writes writes as CSV with a header row:
+//
+//	frame,scanline,dot,pc,register,address,value
+//	123,31,256,C123,PPUSCROLL,2005,08
+//
+// The PC, address and value are hex without a prefix.
+func WriteCSV(w io.Writer, writes []Write) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"frame", "scanline", "dot", "pc", "register", "address", "value"})
+	for _, wr := range writes {
+		cw.Write([]string{
+			strconv.FormatUint(wr.Frame, 10),
+			strconv.Itoa(wr.Scanline),
+			strconv.Itoa(wr.Dot),
+			fmt.Sprintf("%04X", wr.PC),
+			RegisterName(wr.Register),
+			fmt.Sprintf("%04X", wr.Register),
+			fmt.Sprintf("%02X", wr.Value),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,81 @@
+package ppulog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
+)
+
+// testNES runs a one-bank NROM program at $8000 that writes $1E to $2001,
+// $08 to $2005 through its $200D mirror and starts OAM DMA from page 2,
+// then spins.
+func testNES(t *testing.T) *nes.NES {
+	t.Helper()
+	n, err := testrom.New().Program(testrom.Code(0x8000).
+		Op("LDA", testrom.Imm(0x1E)).
+		Op("STA", testrom.Abs(0x2001)).
+		Op("LDA", testrom.Imm(0x08)).
+		Op("STA", testrom.Abs(0x200D)).
+		Op("LDA", testrom.Imm(0x02)).
+		Op("STA", testrom.Abs(0x4014)).
+		Op("STA", testrom.Abs(0x0300)).
+		Label("spin").
+		Op("JMP", testrom.To("spin"))).
+		NES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestLog(t *testing.T) {
+	n := testNES(t)
+	l := Start(n)
+	n.StepFrame()
+	if got := l.Current(); len(got) != 3 {
+		t.Fatalf("recorded %v before EndFrame, want 3 writes", got)
+	}
+	l.EndFrame()
+	writes := l.LastFrame()
+	if len(writes) != 3 || len(l.Current()) != 0 {
+		t.Fatalf("last frame %v, current %v", writes, l.Current())
+	}
+	want := []struct {
+		pc, reg uint16
+		value   uint8
+	}{{0x8002, 0x2001, 0x1E}, {0x8007, 0x2005, 0x08}, {0x800C, 0x4014, 0x02}}
+	for i, w := range want {
+		got := writes[i]
+		if got.PC != w.pc || got.Register != w.reg || got.Value != w.value {
+			t.Errorf("write %d = %v, want $%04X = $%02X from $%04X", i, got, w.reg, w.value, w.pc)
+		}
+	}
+	if writes[0].Dot >= writes[1].Dot && writes[0].Scanline >= writes[1].Scanline {
+		t.Errorf("stamps don't advance: %v then %v", writes[0], writes[1])
+	}
+	if s := writes[1].String(); !strings.Contains(s, "$8007  PPUSCROLL $2005 = $08") {
+		t.Errorf("String = %q", s)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, writes); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || lines[0] != "frame,scanline,dot,pc,register,address,value" {
+		t.Fatalf("CSV:\n%s", buf.String())
+	}
+	if !strings.HasSuffix(lines[3], ",800C,OAMDMA,4014,02") {
+		t.Errorf("DMA row %q", lines[3])
+	}
+
+	// Stopped, the log keeps what it has but records nothing more.
+	l.Stop()
+	n.Memory.Write(0x2000, 0x80)
+	if len(l.Current()) != 0 || len(l.LastFrame()) != 3 {
+		t.Errorf("write recorded after Stop: %v", l.Current())
+	}
+}
@@ -0,0 +1,284 @@
+// Package profile attributes the CPU's time to code, for homebrew
+// developers working to the real frame budget. Fed every instruction by
+// nes.NES.Profile, a Profiler sums cycles per routine — a label from the
+// ROM's symbol files (package symbols), or a 256-byte page of the address
+// space when there are none — and records which routine owned each
+// scanline of the last frame, which frontends draw as a bar beside the
+// picture (osd.DrawScanlineBar).
+//
+// Symbols tied to a PRG ROM bank only claim the code while their bank is
+// mapped, so two routines switched in at the same address are told apart.
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+)
+
+// NumScanlines is the scanlines in an NTSC frame. Scanline s is kept at
+// index s, except the pre-render line (-1), which comes last.
+const NumScanlines = 262
+
+// Routine is one span of the address space and its share of the time.
+type Routine struct {
+	Name       string
+	Start, End uint16 // inclusive
+	PRG        int    // PRG ROM offset of Start for a banked routine, else -1
+	Cycles     uint64 // over all profiled frames
+	Peak       uint64 // most cycles in any one frame
+}
+
+// lineShare is one routine's cycles on the scanline being accumulated.
+type lineShare struct {
+	routine int32
+	cycles  int
+}
+
+// Profiler accumulates cycle counts. Like rules.Engine it isn't safe for
+// concurrent use: Record and EndFrame run on the goroutine stepping frames.
+type Profiler struct {
+	routines []Routine
+	owner    []int32 // routine index for every address
+
+	// prgOwner is the banked routine for every PRG ROM byte up to the
+	// last banked symbol's bank, -1 where none starts before it; prg
+	// resolves a CPU address to its byte.
+	prgOwner []int32
+	prg      func(addr uint16) int
+
+	frame   []uint64 // this frame's cycles per routine
+	touched []int32  // routines with frame cycles, to reset them cheaply
+	frames  uint64
+	cycles  uint64
+
+	line   int // index of the scanline being accumulated
+	shares []lineShare
+	cur    [NumScanlines]int32 // this frame's scanline owners
+	last   [NumScanlines]int32 // the last finished frame's
+}
+
+// New returns a profiler attributing time to syms: each covers the
+// addresses from its own up to the next symbol's. Symbols below $6000
+// name variables rather than code and are ignored. Banked symbols (see
+// symbols.Symbol.PRG) cover PRG ROM from their byte up to the next banked
+// symbol's, and prg — nes.NES.PRGOffset — tells which is mapped; with a
+// nil prg they count as unbanked. With no symbols the address space is
+// split into 256-byte pages.
+func New(syms []symbols.Symbol, prg func(addr uint16) int) *Profiler {
+	var starts, banked []symbols.Symbol
+	for _, s := range syms {
+		switch {
+		case s.Address < 0x6000:
+		case s.PRG >= 0 && prg != nil:
+			banked = append(banked, s)
+		default:
+			starts = append(starts, s)
+		}
+	}
+	if len(starts) == 0 && len(banked) == 0 {
+		for page := 0; page < 0x100; page++ {
+			starts = append(starts, symbols.Symbol{Address: uint16(page << 8)})
+		}
+	}
+	sort.SliceStable(starts, func(i, j int) bool { return starts[i].Address < starts[j].Address })
+	if len(starts) == 0 || starts[0].Address != 0 {
+		starts = append([]symbols.Symbol{{Address: 0}}, starts...)
+	}
+
+	p := &Profiler{owner: make([]int32, 0x10000), prg: prg}
+	for i, s := range starts {
+		if i > 0 && s.Address == starts[i-1].Address {
+			continue // the same address in another bank
+		}
+		end := 0xFFFF
+		for _, next := range starts[i+1:] {
+			if next.Address != s.Address {
+				end = int(next.Address) - 1
+				break
+			}
+		}
+		r := Routine{Name: s.Name, Start: s.Address, End: uint16(end), PRG: -1}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("$%04X-$%04X", r.Start, r.End)
+		}
+		for a := int(r.Start); a <= end; a++ {
+			p.owner[a] = int32(len(p.routines))
+		}
+		p.routines = append(p.routines, r)
+	}
+	p.addBanked(banked)
+	p.frame = make([]uint64, len(p.routines))
+	p.resetLines()
+	p.last = p.cur
+	return p
+}
+
+// addBanked builds prgOwner from the banked symbols. The last one runs to
+// the end of its 16KB bank, the PRG ROM size being unknown here.
+func (p *Profiler) addBanked(banked []symbols.Symbol) {
+	if len(banked) == 0 {
+		return
+	}
+	sort.SliceStable(banked, func(i, j int) bool { return banked[i].PRG < banked[j].PRG })
+	size := (banked[len(banked)-1].PRG | 0x3FFF) + 1
+	p.prgOwner = make([]int32, size)
+	for i := range p.prgOwner[:banked[0].PRG] {
+		p.prgOwner[i] = -1
+	}
+	for i, s := range banked {
+		if i > 0 && s.PRG == banked[i-1].PRG {
+			continue
+		}
+		end := size
+		for _, next := range banked[i+1:] {
+			if next.PRG != s.PRG {
+				end = next.PRG
+				break
+			}
+		}
+		r := Routine{
+			Name:  s.Name,
+			Start: s.Address,
+			End:   uint16(min(int(s.Address)+end-s.PRG-1, 0xFFFF)),
+			PRG:   s.PRG,
+		}
+		for o := s.PRG; o < end; o++ {
+			p.prgOwner[o] = int32(len(p.routines))
+		}
+		p.routines = append(p.routines, r)
+	}
+}
+
+func (p *Profiler) resetLines() {
+	for i := range p.cur {
+		p.cur[i] = -1
+	}
+}
+
+// Record is nes.NES.Profile: it charges cycles to the routine holding pc
+// and to scanline.
+func (p *Profiler) Record(pc uint16, scanline, cycles int) {
+	r := p.owner[pc]
+	if p.prgOwner != nil && pc >= 0x8000 {
+		if o := p.prg(pc); o >= 0 && o < len(p.prgOwner) && p.prgOwner[o] >= 0 {
+			r = p.prgOwner[o]
+		}
+	}
+	if p.frame[r] == 0 {
+		p.touched = append(p.touched, r)
+	}
+	p.frame[r] += uint64(cycles)
+
+	line := scanline
+	if line < 0 {
+		line = NumScanlines - 1
+	}
+	if line != p.line {
+		p.closeLine()
+		p.line = line
+	}
+	for i := range p.shares {
+		if p.shares[i].routine == r {
+			p.shares[i].cycles += cycles
+			return
+		}
+	}
+	p.shares = append(p.shares, lineShare{r, cycles})
+}
+
+// closeLine makes the routine with the most cycles on the current line
+// its owner.
+func (p *Profiler) closeLine() {
+	best := -1
+	for i, s := range p.shares {
+		if best < 0 || s.cycles > p.shares[best].cycles {
+			best = i
+		}
+	}
+	if best >= 0 {
+		p.cur[p.line] = p.shares[best].routine
+	}
+	p.shares = p.shares[:0]
+}
+
+// EndFrame closes the frame: its cycles go into the totals and peaks, and
+// its scanline owners become what LineColors reports. Call it after each
+// StepFrame.
+func (p *Profiler) EndFrame() {
+	p.closeLine()
+	for _, r := range p.touched {
+		c := p.frame[r]
+		p.routines[r].Cycles += c
+		p.routines[r].Peak = max(p.routines[r].Peak, c)
+		p.cycles += c
+		p.frame[r] = 0
+	}
+	p.touched = p.touched[:0]
+	p.frames++
+	p.last = p.cur
+	p.resetLines()
+}
+
+// Frames is how many frames have been profiled.
+func (p *Profiler) Frames() uint64 { return p.frames }
+
+// Hottest returns up to n routines (all for n <= 0) that have run, most
+// cycles first.
+func (p *Profiler) Hottest(n int) []Routine {
+	var hot []Routine
+	for _, r := range p.routines {
+		if r.Cycles > 0 {
+			hot = append(hot, r)
+		}
+	}
+	sort.SliceStable(hot, func(i, j int) bool { return hot[i].Cycles > hot[j].Cycles })
+	if n > 0 && len(hot) > n {
+		hot = hot[:n]
+	}
+	return hot
+}
+
+// WriteReport writes a table of the n hottest routines (all for n <= 0):
+// total cycles, share of all cycles, the average and peak per frame.
+func (p *Profiler) WriteReport(w io.Writer, n int) error {
+	if p.frames == 0 {
+		_, err := fmt.Fprintln(w, "No frames profiled.")
+		return err
+	}
+	perFrame := float64(p.cycles) / float64(p.frames)
+	fmt.Fprintf(w, "%d frames, %d CPU cycles (%.1f per frame)\n\n", p.frames, p.cycles, perFrame)
+	fmt.Fprintf(w, "%-24s %12s %7s %10s %8s\n", "routine", "cycles", "%", "per frame", "peak")
+	for _, r := range p.Hottest(n) {
+		fmt.Fprintf(w, "%-24s %12d %6.2f%% %10.1f %8d\n",
+			r.Name, r.Cycles, 100*float64(r.Cycles)/float64(p.cycles), float64(r.Cycles)/float64(p.frames), r.Peak)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// LineColors returns, in dst (reused), one ARGB colour per scanline of the
+// last finished frame in the order NumScanlines describes: each routine
+// gets its own colour, and lines no instruction started on are 0.
+func (p *Profiler) LineColors(dst []uint32) []uint32 {
+	dst = dst[:0]
+	for _, r := range p.last {
+		c := uint32(0)
+		if r >= 0 {
+			c = routineColor(r)
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// routineColor spreads routine indexes over saturated hues, so adjacent
+// routines — usually caller and callee — look clearly different.
+func routineColor(r int32) uint32 {
+	h := uint32(r) * 2654435761 // Fibonacci hashing
+	rgb := [3]uint32{h >> 24, h >> 16 & 0xFF, h >> 8 & 0xFF}
+	rgb[h%3] |= 0xC0 // keep one channel bright
+	return 0xFF000000 | rgb[0]<<16 | rgb[1]<<8 | rgb[2]
+}
@@ -0,0 +1,119 @@
+package profile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+)
+
+func TestProfiler(t *testing.T) {
+	p := New([]symbols.Symbol{
+		{Name: "Main", Address: 0x8000, PRG: -1},
+		{Name: "NMI", Address: 0x8100, PRG: -1},
+		{Name: "Main2", Address: 0x8000, PRG: -1},
+		{Name: "temp", Address: 0x0010, PRG: -1},
+	}, nil)
+
+	// Two frames: Main owns lines 0-1, NMI line 241, RAM code shares line 1.
+	for frame := 0; frame < 2; frame++ {
+		p.Record(0x8000, 0, 100)
+		p.Record(0x8050, 1, 60)
+		p.Record(0x0300, 1, 40)
+		p.Record(0x8100, 241, 50+frame*10)
+		p.Record(0x8000, -1, 5)
+		p.EndFrame()
+	}
+
+	hot := p.Hottest(0)
+	if len(hot) != 3 {
+		t.Fatalf("Hottest = %+v, want 3 routines", hot)
+	}
+	if hot[0].Name != "Main" || hot[0].Cycles != 330 || hot[0].Peak != 165 {
+		t.Errorf("hottest = %+v, want Main with 330 cycles, peak 165", hot[0])
+	}
+	if hot[1].Name != "NMI" || hot[1].Cycles != 110 || hot[1].Peak != 60 || hot[1].End != 0xFFFF {
+		t.Errorf("second = %+v, want NMI $8100-$FFFF, 110 cycles, peak 60", hot[1])
+	}
+	if hot[2].Name != "$0000-$7FFF" {
+		t.Errorf("code below the first symbol attributed to %q", hot[2].Name)
+	}
+	if got := p.Hottest(1); len(got) != 1 || got[0].Name != "Main" {
+		t.Errorf("Hottest(1) = %+v", got)
+	}
+
+	colors := p.LineColors(nil)
+	if len(colors) != NumScanlines {
+		t.Fatalf("%d line colours", len(colors))
+	}
+	main, nmi := routineColor(1), routineColor(2)
+	if colors[0] != main || colors[1] != main || colors[241] != nmi || colors[NumScanlines-1] != main {
+		t.Errorf("line owners: 0=%08X 1=%08X 241=%08X pre-render=%08X", colors[0], colors[1], colors[241], colors[NumScanlines-1])
+	}
+	if colors[100] != 0 {
+		t.Errorf("idle line 100 = %08X, want 0", colors[100])
+	}
+	if main == nmi || main>>24 != 0xFF {
+		t.Errorf("routine colours %08X %08X should be distinct and opaque", main, nmi)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteReport(&buf, 2); err != nil {
+		t.Fatal(err)
+	}
+	report := buf.String()
+	for _, want := range []string{"2 frames, 520 CPU cycles (260.0 per frame)", "Main", "63.46%", "165.0", "NMI"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "$0000") {
+		t.Errorf("report should stop at 2 routines:\n%s", report)
+	}
+}
+
+func TestProfilerPages(t *testing.T) {
+	p := New(nil, nil)
+	p.Record(0xC0FF, 0, 7)
+	p.Record(0xC100, 0, 3)
+	p.EndFrame()
+	hot := p.Hottest(0)
+	if len(hot) != 2 || hot[0].Name != "$C000-$C0FF" || hot[1].Name != "$C100-$C1FF" {
+		t.Errorf("pages = %+v", hot)
+	}
+}
+
+func TestProfilerBanks(t *testing.T) {
+	// Two banks switched in at $8000, and a fixed bank at $C000.
+	bank := 0
+	prg := func(addr uint16) int {
+		if addr >= 0xC000 {
+			return 3*0x4000 + int(addr-0xC000)
+		}
+		return bank*0x4000 + int(addr-0x8000)
+	}
+	p := New([]symbols.Symbol{
+		{Name: "Draw", Address: 0x8000, PRG: 0x0000},
+		{Name: "Music", Address: 0x8000, PRG: 0x4000},
+		{Name: "Reset", Address: 0xC000, PRG: -1},
+	}, prg)
+	p.Record(0x8010, 0, 10)
+	bank = 1
+	p.Record(0x8010, 0, 20)
+	p.Record(0xC000, 0, 5)
+	bank = 2
+	p.Record(0x8010, 0, 1) // no symbol in bank 2: falls back to the address
+	p.EndFrame()
+
+	got := map[string]uint64{}
+	for _, r := range p.Hottest(0) {
+		got[r.Name] = r.Cycles
+	}
+	if got["Draw"] != 10 || got["Music"] != 20 || got["Reset"] != 5 || got["$0000-$BFFF"] != 1 {
+		t.Errorf("cycles by routine = %v", got)
+	}
+	if hot := p.Hottest(1); hot[0].PRG != 0x4000 || hot[0].End != 0xBFFF {
+		t.Errorf("Music = %+v, want PRG $4000 covering $8000-$BFFF", hot[0])
+	}
+}
@@ -0,0 +1,379 @@
+// Package remote lets another process drive the emulator over a simple
+// line protocol: each request is one JSON object on its own line, and each
+// gets exactly one JSON line back. Through it a test written in any
+// language — or an agent experimenting with a game — can load ROMs, pause
+// and resume, hold buttons, read and write memory, grab screenshots and
+// advance frame by frame, without linking Go code.
+//
+//	→ {"id": 1, "cmd": "buttons", "player": 1, "buttons": ["start"]}
+//	← {"id": 1, "ok": true, "frame": 0, "paused": true}
+//	→ {"id": 2, "cmd": "frame", "count": 30}
+//	← {"id": 2, "ok": true, "frame": 30, "paused": true}
+//	→ {"id": 3, "cmd": "read", "address": 1885, "length": 2}
+//	← {"id": 3, "ok": true, "frame": 30, "paused": true, "data": [0, 1]}
+//
+// The commands are:
+//
+//	status                        report the frame count and pause state
+//	load       path               load a ROM (.nes or archive) and power on
+//	pause, resume                 stop or restart free-running at 60 Hz
+//	reset, power                  press reset, or power cycle
+//	frame      [count]            run count frames (default 1)
+//	buttons    [player] buttons   hold exactly these buttons on player 1-4
+//	read       address [length]   read bytes (default 1) without side effects
+//	write      address data       write bytes to RAM or $6000-$7FFF
+//	screenshot [path]             save a PNG to path, or return it base64 in png
+//
+// Every response carries ok, and error when ok is false, along with the
+// frame count and pause state after the command; id, if the request had
+// one, is echoed back so a client can pipeline requests. Button names are
+// a, b, select, start, up, down, left and right. As with rule files (see
+// package rules), only RAM and cartridge space can be read — the I/O
+// registers at $2000-$5FFF have side effects a remote client shouldn't
+// trigger behind the game's back. Writes go through the CPU bus and are
+// held to RAM and $6000-$7FFF for the same reason: at $8000-$FFFF they
+// would reach the mapper's registers, switching banks, acknowledging
+// IRQs or starting flash commands.
+//
+// The emulator starts paused, so a client sees every frame from the first.
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/core"
+	"github.com/yoshiomiyamaegones/pkg/input"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// frameTime is one NTSC frame (60.0988 Hz), the free-running pace.
+const frameTime = 16639267 * time.Nanosecond
+
+// maxFrames bounds one frame command — ten minutes of game time — so a
+// typo can't hang every other client.
+const maxFrames = 36000
+
+// Request is one command line.
+type Request struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	Cmd     string          `json:"cmd"`
+	Path    string          `json:"path,omitempty"`
+	Player  int             `json:"player,omitempty"` // 1-4, default 1
+	Buttons []string        `json:"buttons,omitempty"`
+	Count   int             `json:"count,omitempty"`
+	Address int             `json:"address,omitempty"`
+	Length  int             `json:"length,omitempty"`
+	Data    []int           `json:"data,omitempty"`
+}
+
+// Response answers one Request.
+type Response struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	OK     bool            `json:"ok"`
+	Error  string          `json:"error,omitempty"`
+	Frame  uint64          `json:"frame"`
+	Paused bool            `json:"paused"`
+	Data   []int           `json:"data,omitempty"` // read
+	PNG    []byte          `json:"png,omitempty"`  // screenshot without a path
+	ROM    string          `json:"rom,omitempty"`  // status, load
+}
+
+// Server runs one NES on behalf of its clients. Commands from any number
+// of connections are serialised with the free-running frame loop, so each
+// sees the machine between frames.
+type Server struct {
+	mu      sync.Mutex
+	nes     *nes.NES
+	romPath string
+	paused  bool
+	pads    input.Staging
+}
+
+// NewServer takes over n — whose cartridge, if any, came from romPath —
+// feeding its controllers from the buttons clients set.
+func NewServer(n *nes.NES, romPath string) *Server {
+	s := &Server{nes: n, romPath: romPath, paused: true}
+	for i := 0; i < 4; i++ {
+		n.SetInputProvider(i, s.pads.Pad(i))
+	}
+	return s
+}
+
+// Listen opens addr: "unix:/path/to.sock", "tcp:host:port", or a bare
+// "host:port" for TCP.
+func Listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", strings.TrimPrefix(addr, "tcp:"))
+}
+
+// Run steps frames at the NTSC rate whenever the server isn't paused,
+// until stop is closed.
+func (s *Server) Run(stop <-chan struct{}) {
+	tick := time.NewTicker(frameTime)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+		s.mu.Lock()
+		if !s.paused {
+			s.nes.StepFrame()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Serve accepts connections on ln, serving each on its own goroutine,
+// until ln is closed.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := s.ServeConn(conn); err != nil {
+				logger.LogError("Remote %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// ServeConn answers requests read from r on w until r is exhausted. A
+// line that isn't valid JSON gets an error response; only I/O errors end
+// the session early.
+func (s *Server) ServeConn(rw io.ReadWriter) error {
+	sc := bufio.NewScanner(rw)
+	sc.Buffer(make([]byte, 64*1024), 1<<20) // long write data lines
+	enc := json.NewEncoder(rw)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp = s.status()
+			resp.Error = fmt.Sprintf("bad request: %v", err)
+		} else {
+			resp = s.Handle(req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// Handle executes one request.
+func (s *Server) Handle(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var resp Response
+	err := s.do(req, &resp)
+	resp.ID = req.ID
+	resp.OK = err == nil
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	resp.Frame = s.nes.Frame
+	resp.Paused = s.paused
+	return resp
+}
+
+func (s *Server) status() Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Response{Frame: s.nes.Frame, Paused: s.paused}
+}
+
+func (s *Server) do(req Request, resp *Response) error {
+	switch req.Cmd {
+	case "status":
+		resp.ROM = s.romPath
+	case "load":
+		if err := s.load(req.Path); err != nil {
+			return err
+		}
+		resp.ROM = s.romPath
+	case "pause":
+		s.paused = true
+	case "resume":
+		if s.nes.Cartridge == nil {
+			return fmt.Errorf("no ROM loaded")
+		}
+		s.paused = false
+	case "reset":
+		s.nes.SoftReset()
+	case "power":
+		s.nes.PowerOn()
+	case "frame":
+		return s.frames(req.Count)
+	case "buttons":
+		return s.setButtons(req.Player, req.Buttons)
+	case "read":
+		data, err := s.read(req.Address, req.Length)
+		resp.Data = data
+		return err
+	case "write":
+		return s.write(req.Address, req.Data)
+	case "screenshot":
+		data, err := s.screenshot(req.Path)
+		resp.PNG = data
+		return err
+	case "":
+		return fmt.Errorf("no cmd")
+	default:
+		return fmt.Errorf("unknown cmd %q", req.Cmd)
+	}
+	return nil
+}
+
+// load swaps in the ROM at path (plain .nes or a zip/gzip archive, whose
+// first ROM is used) and powers on. On error the current game is kept.
+func (s *Server) load(path string) error {
+	if path == "" {
+		return fmt.Errorf("load: no path")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	entries, err := cartridge.FindROMs(data)
+	if err != nil {
+		return err
+	}
+	cart, err := cartridge.LoadEntry(entries[0])
+	if err != nil {
+		return err
+	}
+	s.nes.LoadCartridge(cart)
+	s.nes.PowerOn()
+	s.nes.Cheats.Clear()
+	s.romPath = path
+	logger.LogInfo("Remote: loaded %s", filepath.Base(path))
+	return nil
+}
+
+func (s *Server) frames(count int) error {
+	if count == 0 {
+		count = 1
+	}
+	if count < 0 || count > maxFrames {
+		return fmt.Errorf("frame count %d out of range 1-%d", count, maxFrames)
+	}
+	if s.nes.Cartridge == nil {
+		return fmt.Errorf("no ROM loaded")
+	}
+	for i := 0; i < count; i++ {
+		s.nes.StepFrame()
+	}
+	return nil
+}
+
+// buttonNames maps the protocol's button names to their bits.
+var buttonNames = map[string]core.ButtonState{
+	"a": core.ButtonA, "b": core.ButtonB, "select": core.ButtonSelect, "start": core.ButtonStart,
+	"up": core.ButtonUp, "down": core.ButtonDown, "left": core.ButtonLeft, "right": core.ButtonRight,
+}
+
+func (s *Server) setButtons(player int, names []string) error {
+	if player == 0 {
+		player = 1
+	}
+	if player < 1 || player > 4 {
+		return fmt.Errorf("player %d out of range 1-4", player)
+	}
+	var b core.ButtonState
+	for _, name := range names {
+		bit, ok := buttonNames[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("unknown button %q", name)
+		}
+		b |= bit
+	}
+	s.pads.SetButtons(player-1, b)
+	return nil
+}
+
+// checkRange rejects spans leaving the address space or touching the I/O
+// registers.
+func checkRange(addr, n int) error {
+	if addr < 0 || n < 1 || addr+n > 0x10000 {
+		return fmt.Errorf("range $%X+%d outside $0000-$FFFF", addr, n)
+	}
+	if addr < 0x6000 && addr+n > 0x2000 {
+		return fmt.Errorf("range $%04X-$%04X touches I/O registers ($2000-$5FFF)", addr, addr+n-1)
+	}
+	return nil
+}
+
+func (s *Server) read(addr, n int) ([]int, error) {
+	if n == 0 {
+		n = 1
+	}
+	if err := checkRange(addr, n); err != nil {
+		return nil, err
+	}
+	data := make([]int, n)
+	for i := range data {
+		data[i] = int(s.nes.Memory.Peek(uint16(addr + i)))
+	}
+	return data, nil
+}
+
+func (s *Server) write(addr int, data []int) error {
+	if err := checkRange(addr, len(data)); err != nil {
+		return err
+	}
+	if addr+len(data) > 0x8000 {
+		return fmt.Errorf("range $%04X-$%04X reaches the mapper registers ($8000-$FFFF)", addr, addr+len(data)-1)
+	}
+	for _, v := range data {
+		if v < 0 || v > 0xFF {
+			return fmt.Errorf("byte %d out of range 0-255", v)
+		}
+	}
+	for i, v := range data {
+		s.nes.Memory.Write(uint16(addr+i), uint8(v))
+	}
+	return nil
+}
+
+// screenshot encodes the last finished frame as PNG, writing it to path,
+// or returning it when path is empty.
+func (s *Server) screenshot(path string) ([]byte, error) {
+	img := &image.RGBA{
+		Pix:    s.nes.GetFramebuffer(),
+		Stride: core.FrameWidth * 4,
+		Rect:   image.Rect(0, 0, core.FrameWidth, core.FrameHeight),
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return buf.Bytes(), nil
+	}
+	return nil, os.WriteFile(path, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,163 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// writeTestROM writes a minimal NROM image — NOPs from a reset vector at
+// $8000 — and returns its path.
+func writeTestROM(t *testing.T) string {
+	t.Helper()
+	prg := bytes.Repeat([]byte{0xEA}, 16384)
+	prg[0x3FFC], prg[0x3FFD] = 0x00, 0x80
+	var buf bytes.Buffer
+	buf.WriteString("NES\x1A\x01\x01")
+	buf.Write(make([]byte, 10))
+	buf.Write(prg)
+	buf.Write(make([]byte, 8192))
+	path := filepath.Join(t.TempDir(), "test.nes")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// session runs the request lines through ServeConn and decodes the replies.
+func session(t *testing.T, s *Server, lines ...string) []Response {
+	t.Helper()
+	var out bytes.Buffer
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(strings.Join(lines, "\n")), &out}
+	if err := s.ServeConn(rw); err != nil {
+		t.Fatalf("ServeConn: %v", err)
+	}
+	var resps []Response
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r Response
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		resps = append(resps, r)
+	}
+	if len(resps) != len(lines) {
+		t.Fatalf("%d responses to %d requests", len(resps), len(lines))
+	}
+	return resps
+}
+
+func TestServeConn(t *testing.T) {
+	rom := writeTestROM(t)
+	n := nes.NewNES()
+	s := NewServer(n, "")
+	shot := filepath.Join(t.TempDir(), "shot.png")
+
+	resps := session(t, s,
+		`{"id": 1, "cmd": "frame"}`,
+		`{"id": 2, "cmd": "load", "path": "`+filepath.ToSlash(rom)+`"}`,
+		`{"id": "b", "cmd": "buttons", "player": 1, "buttons": ["Start", "a"]}`,
+		`{"cmd": "frame", "count": 3}`,
+		`{"cmd": "write", "address": 16, "data": [1, 2]}`,
+		`{"cmd": "read", "address": 16, "length": 2}`,
+		`{"cmd": "read", "address": 8194}`,
+		`{"cmd": "write", "address": 16, "data": [256]}`,
+		`{"cmd": "buttons", "buttons": ["turbo"]}`,
+		`{"cmd": "screenshot", "path": "`+filepath.ToSlash(shot)+`"}`,
+		`{"cmd": "screenshot"}`,
+		`{"cmd": "resume"}`,
+		`{"cmd": "status"}`,
+		`not json`,
+		`{"cmd": "jump"}`,
+		`{"cmd": "write", "address": 32768, "data": [0]}`,
+		`{"cmd": "write", "address": 32767, "data": [0, 0]}`,
+	)
+	for i, want := range []struct {
+		ok    bool
+		frame uint64
+		err   string
+	}{
+		{false, 0, "no ROM loaded"},
+		{true, 0, ""},
+		{true, 0, ""},
+		{true, 3, ""},
+		{true, 3, ""},
+		{true, 3, ""},
+		{false, 3, "I/O registers"},
+		{false, 3, "byte 256"},
+		{false, 3, `unknown button "turbo"`},
+		{true, 3, ""},
+		{true, 3, ""},
+		{true, 3, ""},
+		{true, 3, ""},
+		{false, 3, "bad request"},
+		{false, 3, `unknown cmd "jump"`},
+		{false, 3, "mapper registers"},
+		{false, 3, "mapper registers"},
+	} {
+		r := resps[i]
+		if r.OK != want.ok || r.Frame != want.frame || !strings.Contains(r.Error, want.err) {
+			t.Errorf("response %d = %+v, want ok=%v frame=%d error %q", i+1, r, want.ok, want.frame, want.err)
+		}
+	}
+	if string(resps[0].ID) != "1" || string(resps[2].ID) != `"b"` || resps[3].ID != nil {
+		t.Errorf("ids echoed as %s, %s, %s", resps[0].ID, resps[2].ID, resps[3].ID)
+	}
+	if resps[1].ROM != rom {
+		t.Errorf("load reported ROM %q", resps[1].ROM)
+	}
+	if got := n.Input.Controller(0).GetButtons(); got != 0x09 {
+		t.Errorf("controller 1 buttons = %02X, want A+Start (09)", got)
+	}
+	if d := resps[5].Data; len(d) != 2 || d[0] != 1 || d[1] != 2 {
+		t.Errorf("read back %v, want [1 2]", d)
+	}
+	if _, err := os.Stat(shot); err != nil {
+		t.Errorf("screenshot file: %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(resps[10].PNG)); err != nil {
+		t.Errorf("inline screenshot: %v", err)
+	}
+	if resps[11].Paused || resps[12].Paused {
+		t.Error("resume should leave the server running")
+	}
+}
+
+func TestServeTCP(t *testing.T) {
+	ln, err := Listen("tcp:127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no loopback: %v", err)
+	}
+	defer ln.Close()
+	s := NewServer(nes.NewNES(), "")
+	go s.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, `{"id": 7, "cmd": "status"}`+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r Response
+	if err := json.Unmarshal(line, &r); err != nil || !r.OK || string(r.ID) != "7" || !r.Paused {
+		t.Errorf("status over TCP = %s (%v)", line, err)
+	}
+}
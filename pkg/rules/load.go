@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Load reads a rule file: a JSON array of rules, each with a name, an
+// optional message, one or more conditions that must all hold, and
+// optionally how many consecutive frames they must hold for (default 1)
+// and whether the rule fires again after they stop holding:
+//
+//	[
+//	  {
+//	    "name": "World 1-2",
+//	    "message": "Reached World 1-2",
+//	    "conditions": [
+//	      {"address": "$075F", "value": 0},
+//	      {"address": "$075C", "op": "==", "value": 1}
+//	    ],
+//	    "frames": 2
+//	  },
+//	  {"name": "Lost a life", "conditions": [{"address": "$000E", "value": "$0B"}], "repeat": true}
+//	]
+//
+// Addresses and values are JSON numbers or strings in hex ("$07DD",
+// "0x07DD") or decimal. op is one of == (the default), !=, <, <=, >, >=,
+// & (any of value's bits set) and !& (none set). Only CPU RAM and
+// cartridge space ($6000-$FFFF) can be watched. A malformed rule is
+// reported in the returned error but doesn't stop the others loading.
+func Load(r io.Reader) ([]Rule, error) {
+	var raw []struct {
+		Name       string `json:"name"`
+		Message    string `json:"message"`
+		Conditions []struct {
+			Address json.RawMessage `json:"address"`
+			Op      string          `json:"op"`
+			Value   json.RawMessage `json:"value"`
+		} `json:"conditions"`
+		Frames int  `json:"frames"`
+		Repeat bool `json:"repeat"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("rule file: %w", err)
+	}
+	var rules []Rule
+	var errs []string
+	for i, rr := range raw {
+		rule := Rule{Name: rr.Name, Message: rr.Message, Frames: rr.Frames, Repeat: rr.Repeat}
+		err := func() error {
+			if rr.Name == "" {
+				return fmt.Errorf("no name")
+			}
+			if len(rr.Conditions) == 0 {
+				return fmt.Errorf("no conditions")
+			}
+			if rr.Frames < 0 {
+				return fmt.Errorf("frames %d is negative", rr.Frames)
+			}
+			for j, rc := range rr.Conditions {
+				c, err := parseCondition(rc.Address, rc.Op, rc.Value)
+				if err != nil {
+					return fmt.Errorf("condition %d: %w", j+1, err)
+				}
+				rule.Conditions = append(rule.Conditions, c)
+			}
+			return nil
+		}()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("rule %d (%q): %v", i+1, rr.Name, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if len(errs) > 0 {
+		return rules, fmt.Errorf("rule file: %s", strings.Join(errs, "; "))
+	}
+	return rules, nil
+}
+
+func parseCondition(address json.RawMessage, op string, value json.RawMessage) (Condition, error) {
+	addr, err := parseNumber(address, 0xFFFF)
+	if err != nil {
+		return Condition{}, fmt.Errorf("address: %w", err)
+	}
+	if addr >= 0x2000 && addr < 0x6000 {
+		return Condition{}, fmt.Errorf("$%04X is not RAM or cartridge space", addr)
+	}
+	v, err := parseNumber(value, 0xFF)
+	if err != nil {
+		return Condition{}, fmt.Errorf("value: %w", err)
+	}
+	c := Condition{Address: uint16(addr), Op: Op(op), Value: uint8(v)}
+	switch c.Op {
+	case "":
+		c.Op = OpEq
+	case OpEq, OpNe, OpLt, OpLe, OpGt, OpGe, OpAnd, OpNotAnd:
+	default:
+		return Condition{}, fmt.Errorf("unknown op %q", op)
+	}
+	return c, nil
+}
+
+// parseNumber decodes a JSON number, or a string holding a hex ("$xx",
+// "0xxx") or decimal number, no larger than limit.
+func parseNumber(raw json.RawMessage, limit uint64) (uint64, error) {
+	if len(raw) == 0 {
+		return 0, fmt.Errorf("missing")
+	}
+	s := string(raw)
+	base := 10
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return 0, err
+		}
+		lower := strings.ToLower(strings.TrimSpace(s))
+		switch {
+		case strings.HasPrefix(lower, "$"):
+			s, base = lower[1:], 16
+		case strings.HasPrefix(lower, "0x"):
+			s, base = lower[2:], 16
+		default:
+			s = lower
+		}
+	}
+	v, err := strconv.ParseUint(s, base, 64)
+	if err != nil || v > limit {
+		return 0, fmt.Errorf("bad number %s", raw)
+	}
+	return v, nil
+}
@@ -0,0 +1,177 @@
+// Package rules watches emulated memory for conditions — "the byte at
+// $07DD is 3 for 2 frames" — and fires an event when one is met. Rules come
+// from a per-ROM JSON file (see Load) and drive homemade achievements and
+// automated gameplay checks: the GUI shows each event on the OSD, headless
+// tools print it.
+//
+// The engine is checked once per frame, between frames, through a peek
+// function that must not disturb the machine (memory.Memory.Peek), so
+// watching never changes how a game runs.
+package rules
+
+import "fmt"
+
+// Op compares a watched byte with a condition's value.
+type Op string
+
+// The comparison operators. OpAnd holds when any of Value's bits are set
+// in the byte, OpNotAnd when none are — for flag bytes.
+const (
+	OpEq     Op = "=="
+	OpNe     Op = "!="
+	OpLt     Op = "<"
+	OpLe     Op = "<="
+	OpGt     Op = ">"
+	OpGe     Op = ">="
+	OpAnd    Op = "&"
+	OpNotAnd Op = "!&"
+)
+
+// Condition is one memory test of a rule.
+type Condition struct {
+	Address uint16
+	Op      Op
+	Value   uint8
+}
+
+// Holds reports whether b satisfies the condition.
+func (c Condition) Holds(b uint8) bool {
+	switch c.Op {
+	case OpEq:
+		return b == c.Value
+	case OpNe:
+		return b != c.Value
+	case OpLt:
+		return b < c.Value
+	case OpLe:
+		return b <= c.Value
+	case OpGt:
+		return b > c.Value
+	case OpGe:
+		return b >= c.Value
+	case OpAnd:
+		return b&c.Value != 0
+	case OpNotAnd:
+		return b&c.Value == 0
+	}
+	return false
+}
+
+func (c Condition) String() string {
+	return fmt.Sprintf("$%04X %s $%02X", c.Address, c.Op, c.Value)
+}
+
+// Rule fires once all its Conditions have held at the end of Frames
+// consecutive frames. A rule fires only once unless Repeat is set, in
+// which case it re-arms as soon as its conditions stop holding.
+type Rule struct {
+	Name       string
+	Message    string // shown when the rule fires; defaults to Name
+	Conditions []Condition
+	Frames     int // at least 1
+	Repeat     bool
+}
+
+// Text is the message to show when r fires.
+func (r *Rule) Text() string {
+	if r.Message == "" {
+		return r.Name
+	}
+	return r.Message
+}
+
+// Event is a rule firing.
+type Event struct {
+	Rule  *Rule
+	Frame uint64 // the frame at whose end the rule fired
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("frame %d: %s", e.Frame, e.Rule.Text())
+}
+
+// Engine tracks how long each rule has held and fires its handlers.
+// Like cheat.Manager it isn't safe for concurrent use; the frontend calls
+// Check on the goroutine that steps frames.
+type Engine struct {
+	rules    []Rule
+	held     []int  // consecutive frames each rule's conditions have held
+	fired    []bool // rule has fired and not re-armed
+	handlers []func(Event)
+}
+
+// NewEngine returns an engine watching rules.
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{}
+	e.SetRules(rules)
+	return e
+}
+
+// SetRules replaces the watched rules, e.g. when a different ROM is
+// loaded, and re-arms them all. Handlers are kept.
+func (e *Engine) SetRules(rules []Rule) {
+	e.rules = rules
+	e.held = make([]int, len(rules))
+	e.fired = make([]bool, len(rules))
+}
+
+// Rules returns the watched rules. The slice shares storage with the
+// engine — callers must not mutate.
+func (e *Engine) Rules() []Rule { return e.rules }
+
+// OnEvent registers fn to run, in registration order, for every event.
+func (e *Engine) OnEvent(fn func(Event)) {
+	e.handlers = append(e.handlers, fn)
+}
+
+// Reset re-arms every rule, as after a power cycle.
+func (e *Engine) Reset() {
+	for i := range e.rules {
+		e.held[i] = 0
+		e.fired[i] = false
+	}
+}
+
+// Fired reports how many rules have fired and not re-armed.
+func (e *Engine) Fired() int {
+	n := 0
+	for _, f := range e.fired {
+		if f {
+			n++
+		}
+	}
+	return n
+}
+
+// Check evaluates every rule against memory as peek reports it at the end
+// of frame, and fires the handlers for each rule that is met.
+func (e *Engine) Check(peek func(addr uint16) uint8, frame uint64) {
+	for i := range e.rules {
+		r := &e.rules[i]
+		if !e.holds(r, peek) {
+			e.held[i] = 0
+			if r.Repeat {
+				e.fired[i] = false
+			}
+			continue
+		}
+		e.held[i]++
+		if e.fired[i] || e.held[i] < max(r.Frames, 1) {
+			continue
+		}
+		e.fired[i] = true
+		ev := Event{Rule: r, Frame: frame}
+		for _, fn := range e.handlers {
+			fn(ev)
+		}
+	}
+}
+
+func (e *Engine) holds(r *Rule, peek func(uint16) uint8) bool {
+	for _, c := range r.Conditions {
+		if !c.Holds(peek(c.Address)) {
+			return false
+		}
+	}
+	return true
+}
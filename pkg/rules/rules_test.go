@@ -0,0 +1,114 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	rules, err := Load(strings.NewReader(`[
+		{"name": "World 1-2", "message": "Reached World 1-2", "frames": 2,
+		 "conditions": [{"address": "$075F", "value": 0}, {"address": "0x075C", "op": "==", "value": 1}]},
+		{"name": "Flags", "repeat": true,
+		 "conditions": [{"address": 24576, "op": "&", "value": "$80"}, {"address": "$00", "op": "<=", "value": "12"}]}
+	]`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("loaded %d rules, want 2", len(rules))
+	}
+	r := rules[0]
+	if r.Name != "World 1-2" || r.Message != "Reached World 1-2" || r.Frames != 2 || r.Repeat {
+		t.Errorf("rule 1 = %+v", r)
+	}
+	if len(r.Conditions) != 2 || r.Conditions[0] != (Condition{0x075F, OpEq, 0}) || r.Conditions[1] != (Condition{0x075C, OpEq, 1}) {
+		t.Errorf("rule 1 conditions = %v", r.Conditions)
+	}
+	r = rules[1]
+	if !r.Repeat || r.Conditions[0] != (Condition{0x6000, OpAnd, 0x80}) || r.Conditions[1] != (Condition{0x0000, OpLe, 12}) {
+		t.Errorf("rule 2 = %+v", r)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := Load(strings.NewReader(`{"name": "not a list"}`)); err == nil {
+		t.Error("a non-array file should fail")
+	}
+	rules, err := Load(strings.NewReader(`[
+		{"name": "ok", "conditions": [{"address": "$10", "value": 1}]},
+		{"conditions": [{"address": "$10", "value": 1}]},
+		{"name": "empty"},
+		{"name": "register", "conditions": [{"address": "$2002", "value": 0}]},
+		{"name": "big", "conditions": [{"address": "$10", "value": 256}]},
+		{"name": "op", "conditions": [{"address": "$10", "op": "=", "value": 1}]},
+		{"name": "no value", "conditions": [{"address": "$10"}]}
+	]`))
+	if len(rules) != 1 || rules[0].Name != "ok" {
+		t.Errorf("kept %v, want only the good rule", rules)
+	}
+	if err == nil {
+		t.Fatal("malformed rules should be reported")
+	}
+	for _, want := range []string{"rule 2", "no conditions", "$2002", "value: bad number 256", `unknown op "="`, "value: missing"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+}
+
+func TestConditionOps(t *testing.T) {
+	for _, tc := range []struct {
+		op   Op
+		b    uint8
+		want bool
+	}{
+		{OpEq, 5, true}, {OpEq, 6, false},
+		{OpNe, 6, true}, {OpNe, 5, false},
+		{OpLt, 4, true}, {OpLt, 5, false},
+		{OpLe, 5, true}, {OpLe, 6, false},
+		{OpGt, 6, true}, {OpGt, 5, false},
+		{OpGe, 5, true}, {OpGe, 4, false},
+		{OpAnd, 0x04, true}, {OpAnd, 0x02, false},
+		{OpNotAnd, 0x02, true}, {OpNotAnd, 0x01, false},
+	} {
+		if got := (Condition{Op: tc.op, Value: 5}).Holds(tc.b); got != tc.want {
+			t.Errorf("%d %s 5 = %v, want %v", tc.b, tc.op, got, tc.want)
+		}
+	}
+}
+
+func TestEngine(t *testing.T) {
+	mem := make([]uint8, 0x800)
+	peek := func(addr uint16) uint8 { return mem[addr&0x7FF] }
+	e := NewEngine([]Rule{
+		{Name: "held", Conditions: []Condition{{0x10, OpEq, 3}}, Frames: 2},
+		{Name: "repeat", Conditions: []Condition{{0x11, OpGt, 0}}, Repeat: true},
+	})
+	var got []string
+	e.OnEvent(func(ev Event) { got = append(got, ev.String()) })
+
+	// frame:  1  2  3  4  5  6
+	// $10:    3  0  3  3  3  3   held for 2 from frame 3 → fires at 4, once
+	// $11:    1  1  0  1  0  0   fires at 1 and again at 4
+	steps := [][2]uint8{{3, 1}, {0, 1}, {3, 0}, {3, 1}, {3, 0}, {3, 0}}
+	for i, s := range steps {
+		mem[0x10], mem[0x11] = s[0], s[1]
+		e.Check(peek, uint64(i+1))
+	}
+	want := []string{"frame 1: repeat", "frame 4: held", "frame 4: repeat"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("events %q, want %q", got, want)
+	}
+	if e.Fired() != 1 {
+		t.Errorf("Fired = %d, want 1 (the repeat rule re-armed)", e.Fired())
+	}
+
+	e.Reset()
+	got = nil
+	e.Check(peek, 7)
+	e.Check(peek, 8)
+	if len(got) != 1 || got[0] != "frame 8: held" {
+		t.Errorf("after Reset: %q", got)
+	}
+}
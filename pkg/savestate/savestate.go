@@ -0,0 +1,117 @@
+// Package savestate keeps what a state picker shows about each numbered
+// save-state slot: when it was saved, how long the game had been played
+// by then and a thumbnail of the screen. It lives in a small JSON file
+// beside the state (<rom>.state3.json for <rom>.state3), so the state
+// format itself is untouched and a state saved without one still loads.
+package savestate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"time"
+
+	"github.com/yoshiomiyamaegones/pkg/core"
+)
+
+// Slots is how many numbered slots a ROM has, 1 to Slots.
+const Slots = 10
+
+// Thumbnail size: the picture at half scale.
+const (
+	ThumbWidth  = core.FrameWidth / 2
+	ThumbHeight = core.FrameHeight / 2
+)
+
+// Meta describes one saved slot.
+type Meta struct {
+	Saved    time.Time     `json:"saved"`
+	PlayTime time.Duration `json:"play_time"` // time played, carried on by loading the slot
+	// ROMSHA1 is cartridge.Hashes.ROMSHA1 of the game saved, so a state
+	// can be matched to the same dump rather than just the same file name.
+	ROMSHA1 string `json:"rom_sha1,omitempty"`
+	// Thumbnail is a ThumbWidth×ThumbHeight PNG of the screen, or nil.
+	Thumbnail []byte `json:"thumbnail,omitempty"`
+}
+
+// MetaPath is the metadata file for the state at statePath.
+func MetaPath(statePath string) string { return statePath + ".json" }
+
+// Write stores m as the metadata of the state at statePath.
+func Write(statePath string, m Meta) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(MetaPath(statePath), data, 0o644)
+}
+
+// Read loads the metadata of the state at statePath.
+func Read(statePath string) (Meta, error) {
+	var m Meta
+	data, err := os.ReadFile(MetaPath(statePath))
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("%s: %w", MetaPath(statePath), err)
+	}
+	return m, nil
+}
+
+// Thumbnail scales frame, an RGBA picture as nes.NES.GetFramebufferInto
+// fills it, to ThumbWidth×ThumbHeight by averaging each 2×2 block and
+// encodes the result as PNG.
+func Thumbnail(frame []uint8) ([]byte, error) {
+	const stride = core.FrameWidth * 4
+	if len(frame) < stride*core.FrameHeight {
+		return nil, fmt.Errorf("frame is %d bytes, want %d", len(frame), stride*core.FrameHeight)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, ThumbWidth, ThumbHeight))
+	for y := 0; y < ThumbHeight; y++ {
+		for x := 0; x < ThumbWidth; x++ {
+			i := 2*y*stride + 2*x*4
+			o := img.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				sum := int(frame[i+c]) + int(frame[i+4+c]) + int(frame[i+stride+c]) + int(frame[i+stride+4+c])
+				img.Pix[o+c] = uint8(sum / 4)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Pixels decodes m's thumbnail into 0xAARRGGBB pixels, the layout the
+// OSD draws, returning its size; nil if there is none.
+func (m Meta) Pixels() (pix []uint32, w, h int, err error) {
+	if len(m.Thumbnail) == 0 {
+		return nil, 0, 0, nil
+	}
+	img, err := png.Decode(bytes.NewReader(m.Thumbnail))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	b := img.Bounds()
+	w, h = b.Dx(), b.Dy()
+	pix = make([]uint32, 0, w*h)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			pix = append(pix, 0xFF000000|(r>>8)<<16|(g>>8)<<8|bl>>8)
+		}
+	}
+	return pix, w, h, nil
+}
+
+// FormatPlayTime renders d as h:mm:ss.
+func FormatPlayTime(d time.Duration) string {
+	s := int(d / time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
@@ -0,0 +1,75 @@
+package savestate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yoshiomiyamaegones/pkg/core"
+)
+
+func TestMetaRoundTrip(t *testing.T) {
+	frame := make([]uint8, core.FrameWidth*core.FrameHeight*4)
+	// Left half red, right half blue.
+	for y := 0; y < core.FrameHeight; y++ {
+		for x := 0; x < core.FrameWidth; x++ {
+			i := (y*core.FrameWidth + x) * 4
+			if x < core.FrameWidth/2 {
+				frame[i] = 0xFF
+			} else {
+				frame[i+2] = 0xFF
+			}
+			frame[i+3] = 0xFF
+		}
+	}
+	thumb, err := Thumbnail(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(t.TempDir(), "game.state3")
+	saved := time.Date(2026, 10, 16, 12, 34, 56, 0, time.UTC)
+	if err := Write(state, Meta{Saved: saved, PlayTime: 90 * time.Minute, ROMSHA1: "da39a3ee", Thumbnail: thumb}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(state), "game.state3.json")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Read(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Saved.Equal(saved) || m.PlayTime != 90*time.Minute || m.ROMSHA1 != "da39a3ee" {
+		t.Errorf("read %v, %v, %q", m.Saved, m.PlayTime, m.ROMSHA1)
+	}
+	pix, w, h, err := m.Pixels()
+	if err != nil || w != ThumbWidth || h != ThumbHeight || len(pix) != w*h {
+		t.Fatalf("Pixels: %d×%d, %d pixels, %v", w, h, len(pix), err)
+	}
+	if pix[0] != 0xFFFF0000 || pix[w-1] != 0xFF0000FF {
+		t.Errorf("corners %08X %08X, want red and blue", pix[0], pix[w-1])
+	}
+
+	if _, err := Thumbnail(frame[:100]); err == nil {
+		t.Error("short frame accepted")
+	}
+	if _, err := Read(filepath.Join(t.TempDir(), "none.state1")); !os.IsNotExist(err) {
+		t.Errorf("missing meta: %v", err)
+	}
+	if pix, _, _, err := (Meta{}).Pixels(); pix != nil || err != nil {
+		t.Error("no thumbnail should give no pixels")
+	}
+}
+
+func TestFormatPlayTime(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                "0:00:00",
+		59 * time.Second: "0:00:59",
+		time.Hour + 2*time.Minute + 3*time.Second: "1:02:03",
+		100 * time.Hour: "100:00:00",
+	} {
+		if got := FormatPlayTime(d); got != want {
+			t.Errorf("FormatPlayTime(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
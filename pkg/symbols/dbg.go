@@ -0,0 +1,138 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// inesHeaderSize is the header ld65 configs for the NES write ahead of PRG
+// ROM in the output file.
+const inesHeaderSize = 16
+
+// dbgSegment is the part of a "seg" line that places its bytes in the ROM.
+type dbgSegment struct {
+	start, size int
+	ooffs       int // offset in the output file, -1 if not written out
+}
+
+type dbgSym struct {
+	name   string
+	val    int
+	seg    int // -1 for none
+	parent int // cheap local label's parent symbol, -1 for none
+}
+
+// LoadDBG reads the debug info ld65 writes with --dbgfile (version 2, as
+// cc65 2.14 and later produce). Every label becomes a symbol — cheap
+// locals named after their parent, as "reset@loop" — and a label in a
+// segment written to the ROM is tied to its PRG ROM byte, assuming the
+// output file starts with the usual 16-byte iNES header.
+func LoadDBG(r io.Reader) ([]Symbol, error) {
+	segs := make(map[int]dbgSegment)
+	syms := make(map[int]dbgSym)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		kind, rest, _ := strings.Cut(strings.TrimSpace(sc.Text()), "\t")
+		if kind == "" {
+			continue
+		}
+		attrs, err := parseDBGAttrs(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		switch kind {
+		case "version":
+			if attrs["major"] != "2" {
+				return nil, fmt.Errorf("line %d: unsupported debug info version %s", line, attrs["major"])
+			}
+		case "seg":
+			id, ok1 := dbgInt(attrs, "id", -1)
+			start, ok2 := dbgInt(attrs, "start", 0)
+			size, ok3 := dbgInt(attrs, "size", 0)
+			ooffs, ok4 := dbgInt(attrs, "ooffs", -1)
+			if !ok1 || !ok2 || !ok3 || !ok4 || id < 0 {
+				return nil, fmt.Errorf("line %d: malformed seg", line)
+			}
+			segs[id] = dbgSegment{start, size, ooffs}
+		case "sym":
+			if attrs["type"] != "lab" {
+				continue // equates, imports and exports of other files' labels
+			}
+			id, ok1 := dbgInt(attrs, "id", -1)
+			val, ok2 := dbgInt(attrs, "val", -1)
+			seg, ok3 := dbgInt(attrs, "seg", -1)
+			parent, ok4 := dbgInt(attrs, "parent", -1)
+			if !ok1 || !ok2 || !ok3 || !ok4 || id < 0 || val < 0 || val > 0xFFFF || attrs["name"] == "" {
+				return nil, fmt.Errorf("line %d: malformed sym", line)
+			}
+			syms[id] = dbgSym{attrs["name"], val, seg, parent}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(syms))
+	for id := range syms {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	out := make([]Symbol, 0, len(ids))
+	for _, id := range ids {
+		s := syms[id]
+		name := s.name
+		if p, ok := syms[s.parent]; ok && s.parent >= 0 {
+			name = p.name + name
+		}
+		sym := Symbol{Name: name, Address: uint16(s.val), PRG: -1}
+		if seg, ok := segs[s.seg]; ok && seg.ooffs >= 0 && s.val >= 0x8000 &&
+			s.val >= seg.start && s.val < seg.start+seg.size {
+			if prg := seg.ooffs + s.val - seg.start - inesHeaderSize; prg >= 0 {
+				sym.PRG = prg
+			}
+		}
+		out = append(out, sym)
+	}
+	return out, nil
+}
+
+// parseDBGAttrs splits `id=0,name="reset",val=0xC000` into a map; quoted
+// values may contain commas.
+func parseDBGAttrs(s string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for s != "" {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("malformed attribute %q", s)
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in %s", key)
+			}
+			value, rest = rest[1:end+1], strings.TrimPrefix(rest[end+2:], ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[key] = value
+		s = rest
+	}
+	return attrs, nil
+}
+
+// dbgInt parses the numeric attribute key (decimal or 0x hex), returning
+// def when it is absent.
+func dbgInt(attrs map[string]string, key string, def int) (int, bool) {
+	v, ok := attrs[key]
+	if !ok {
+		return def, true
+	}
+	n, err := strconv.ParseInt(v, 0, 32)
+	return int(n), err == nil
+}
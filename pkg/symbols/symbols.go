@@ -0,0 +1,166 @@
+// Package symbols loads the label files assemblers and other emulators
+// leave beside a ROM, so debugging tools can show routine names instead of
+// raw addresses: FCEUX's .nl files (LoadNL), NESASM's .fns (LoadFNS) and
+// ld65's debug info (LoadDBG).
+//
+// In a bank-switched game one CPU address holds different code depending
+// on the mapping, so a symbol can be tied to a byte of PRG ROM as well as
+// an address; Table.Lookup takes the PRG ROM offset currently mapped
+// there (nes.NES.PRGOffset) and only matches symbols of that bank.
+package symbols
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Symbol names an address.
+type Symbol struct {
+	Name    string
+	Address uint16 // CPU address
+	// PRG is the PRG ROM offset the symbol belongs to, for code in a
+	// switchable bank; -1 means whatever is mapped at Address (RAM, I/O,
+	// or a file that doesn't say).
+	PRG     int
+	Comment string
+}
+
+// LoadFile reads a symbol file, choosing the format by its name: .nl
+// (the bank taken from a <rom>.nes.<bank>.nl name), .fns or .dbg.
+func LoadFile(path string) ([]Symbol, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var syms []Symbol
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".nl":
+		syms, err = LoadNL(f, nlBank(path))
+	case ".fns":
+		syms, err = LoadFNS(f)
+	case ".dbg":
+		syms, err = LoadDBG(f)
+	default:
+		return nil, fmt.Errorf("%s: unknown symbol file type %q (want .nl, .fns or .dbg)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return syms, nil
+}
+
+// nlBank reads the bank number from an FCEUX name list's file name:
+// game.nes.1F.nl is bank $1F, and game.nes.ram.nl (or any name without a
+// bank) is -1.
+func nlBank(path string) int {
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	bank, err := strconv.ParseUint(strings.TrimPrefix(filepath.Ext(stem), "."), 16, 16)
+	if err != nil {
+		return -1
+	}
+	return int(bank)
+}
+
+// Table indexes symbols for lookup by address and by name. The zero of
+// *Table, nil, is an empty table.
+type Table struct {
+	symbols []Symbol
+	byPRG   map[int]int    // banked symbols by PRG offset
+	byAddr  map[uint16]int // bank-agnostic symbols by address
+	anyBank map[uint16]int // the first banked symbol at each address
+	byName  map[string]int
+}
+
+// NewTable indexes syms. When two symbols claim the same address (or the
+// same PRG ROM byte, or the same name) the first one wins, so files loaded
+// first take precedence.
+func NewTable(syms []Symbol) *Table {
+	t := &Table{
+		symbols: syms,
+		byPRG:   make(map[int]int),
+		byAddr:  make(map[uint16]int),
+		anyBank: make(map[uint16]int),
+		byName:  make(map[string]int),
+	}
+	for i, s := range syms {
+		if s.PRG >= 0 {
+			addOnce(t.byPRG, s.PRG, i)
+			addOnce(t.anyBank, s.Address, i)
+		} else {
+			addOnce(t.byAddr, s.Address, i)
+		}
+		addOnce(t.byName, s.Name, i)
+	}
+	return t
+}
+
+func addOnce[K comparable](m map[K]int, k K, i int) {
+	if _, ok := m[k]; !ok {
+		m[k] = i
+	}
+}
+
+// Len is the number of symbols in the table.
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.symbols)
+}
+
+// Symbols returns every symbol, in the order given to NewTable.
+func (t *Table) Symbols() []Symbol {
+	if t == nil {
+		return nil
+	}
+	return t.symbols
+}
+
+// Lookup finds the symbol at addr, where prg is the PRG ROM offset mapped
+// there or -1 if unknown. A symbol of the mapped bank comes first, then
+// one without a bank; when the mapping is unknown a symbol of any bank
+// will do.
+func (t *Table) Lookup(addr uint16, prg int) (Symbol, bool) {
+	if t == nil {
+		return Symbol{}, false
+	}
+	if prg >= 0 {
+		if i, ok := t.byPRG[prg]; ok {
+			return t.symbols[i], true
+		}
+	}
+	if i, ok := t.byAddr[addr]; ok {
+		return t.symbols[i], true
+	}
+	if prg < 0 {
+		if i, ok := t.anyBank[addr]; ok {
+			return t.symbols[i], true
+		}
+	}
+	return Symbol{}, false
+}
+
+// Find returns the symbol called name.
+func (t *Table) Find(name string) (Symbol, bool) {
+	if t == nil {
+		return Symbol{}, false
+	}
+	i, ok := t.byName[name]
+	if !ok {
+		return Symbol{}, false
+	}
+	return t.symbols[i], true
+}
+
+// Label formats addr for display: "$C123 Reset" when a symbol names it,
+// "$C123" otherwise.
+func (t *Table) Label(addr uint16, prg int) string {
+	if s, ok := t.Lookup(addr, prg); ok {
+		return fmt.Sprintf("$%04X %s", addr, s.Name)
+	}
+	return fmt.Sprintf("$%04X", addr)
+}
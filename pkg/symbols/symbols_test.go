@@ -0,0 +1,162 @@
+package symbols
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadNL(t *testing.T) {
+	syms, err := LoadNL(strings.NewReader("$C123#Reset#entry point\n\n$0300/10#Buffer#\n$D000#Sound#"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Symbol{
+		{Name: "Reset", Address: 0xC123, PRG: 3*0x4000 + 0x0123, Comment: "entry point"},
+		{Name: "Buffer", Address: 0x0300, PRG: -1},
+		{Name: "Sound", Address: 0xD000, PRG: 3*0x4000 + 0x1000},
+	}
+	if len(syms) != len(want) {
+		t.Fatalf("got %+v", syms)
+	}
+	for i := range want {
+		if syms[i] != want[i] {
+			t.Errorf("symbol %d = %+v, want %+v", i, syms[i], want[i])
+		}
+	}
+	ram, _ := LoadNL(strings.NewReader("$C000#Fixed#"), -1)
+	if len(ram) != 1 || ram[0].PRG != -1 {
+		t.Errorf("RAM file symbol = %+v, want no bank", ram)
+	}
+	for _, bad := range []string{"$ZZZZ#x#", "$C000##", "C000#x#"} {
+		if _, err := LoadNL(strings.NewReader(bad), 0); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("LoadNL(%q) error = %v", bad, err)
+		}
+	}
+}
+
+func TestLoadFNS(t *testing.T) {
+	syms, err := LoadFNS(strings.NewReader("; main.asm\nReset            = $C000\nNMI = $c123 ; handler\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(syms) != 2 || syms[0] != (Symbol{Name: "Reset", Address: 0xC000, PRG: -1}) || syms[1].Address != 0xC123 {
+		t.Errorf("got %+v", syms)
+	}
+	for _, bad := range []string{"Reset C000", "x = 12", "= $C000"} {
+		if _, err := LoadFNS(strings.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("LoadFNS(%q) error = %v", bad, err)
+		}
+	}
+}
+
+const testDBG = "version\tmajor=2,minor=0\n" +
+	"info\tcsym=0,file=1,lib=0,line=4,mod=1,scope=1,seg=3,span=4,sym=4,type=4\n" +
+	"file\tid=0,name=\"main, final.s\",size=300,mtime=0x5F000000,mod=0\n" +
+	"seg\tid=0,name=\"HEADER\",start=0x000000,size=0x0010,addrsize=absolute,type=ro,oname=\"game.nes\",ooffs=0\n" +
+	"seg\tid=1,name=\"BANK1\",start=0x008000,size=0x4000,addrsize=absolute,type=ro,oname=\"game.nes\",ooffs=16400\n" +
+	"seg\tid=2,name=\"BSS\",start=0x000300,size=0x0100,addrsize=absolute,type=rw\n" +
+	"sym\tid=0,name=\"draw\",addrsize=absolute,scope=0,def=1,ref=2,val=0x8010,seg=1,type=lab\n" +
+	"sym\tid=1,name=\"@loop\",addrsize=absolute,parent=0,def=3,val=0x8014,seg=1,type=lab\n" +
+	"sym\tid=2,name=\"buffer\",addrsize=absolute,scope=0,def=4,val=0x300,seg=2,type=lab\n" +
+	"sym\tid=3,name=\"SPEED\",addrsize=zeropage,scope=0,def=5,val=0x3,type=equ\n"
+
+func TestLoadDBG(t *testing.T) {
+	syms, err := LoadDBG(strings.NewReader(testDBG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Symbol{
+		{Name: "draw", Address: 0x8010, PRG: 0x4010},
+		{Name: "draw@loop", Address: 0x8014, PRG: 0x4014},
+		{Name: "buffer", Address: 0x0300, PRG: -1},
+	}
+	if len(syms) != len(want) {
+		t.Fatalf("got %+v, want %+v", syms, want)
+	}
+	for i := range want {
+		if syms[i] != want[i] {
+			t.Errorf("symbol %d = %+v, want %+v", i, syms[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{
+		"version\tmajor=3,minor=0",
+		"sym\tid=0,name=\"x\",val=0x10000,type=lab",
+		"seg\tid=0,name=\"CODE,start=0",
+		"sym\tid=0,name",
+	} {
+		if _, err := LoadDBG(strings.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("LoadDBG(%q) error = %v", bad, err)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	bank := write("game.nes.1F.nl", "$8000#Banked#")
+	if syms, err := LoadFile(bank); err != nil || syms[0].PRG != 0x1F*0x4000 {
+		t.Errorf("bank $1F file: %+v, %v", syms, err)
+	}
+	ram := write("game.nes.ram.nl", "$0300#Buffer#")
+	if syms, err := LoadFile(ram); err != nil || syms[0].PRG != -1 {
+		t.Errorf("RAM file: %+v, %v", syms, err)
+	}
+	if syms, err := LoadFile(write("game.dbg", testDBG)); err != nil || len(syms) != 3 {
+		t.Errorf("dbg: %+v, %v", syms, err)
+	}
+	if _, err := LoadFile(write("game.fns", "oops")); err == nil || !strings.Contains(err.Error(), "game.fns: line 1") {
+		t.Errorf("bad fns error = %v", err)
+	}
+	if _, err := LoadFile(write("game.sym", "")); err == nil {
+		t.Error("unknown extension should fail")
+	}
+}
+
+func TestTable(t *testing.T) {
+	tab := NewTable([]Symbol{
+		{Name: "Bank0Draw", Address: 0x8000, PRG: 0x0000},
+		{Name: "Bank1Sound", Address: 0x8000, PRG: 0x4000},
+		{Name: "Reset", Address: 0xC000, PRG: -1},
+		{Name: "Again", Address: 0xC000, PRG: -1},
+	})
+	for _, tc := range []struct {
+		addr uint16
+		prg  int
+		want string
+	}{
+		{0x8000, 0x4000, "Bank1Sound"},
+		{0x8000, 0x0000, "Bank0Draw"},
+		{0x8000, 0x8000, ""},       // a bank without symbols
+		{0x8000, -1, "Bank0Draw"},  // mapping unknown: any bank
+		{0xC000, 0x7C000, "Reset"}, // no bank: any mapping, first wins
+		{0x1234, -1, ""},
+	} {
+		s, ok := tab.Lookup(tc.addr, tc.prg)
+		if s.Name != tc.want || ok != (tc.want != "") {
+			t.Errorf("Lookup($%04X, %X) = %q, %v; want %q", tc.addr, tc.prg, s.Name, ok, tc.want)
+		}
+	}
+	if s, ok := tab.Find("Bank1Sound"); !ok || s.PRG != 0x4000 {
+		t.Errorf("Find = %+v, %v", s, ok)
+	}
+	if got := tab.Label(0xC000, -1); got != "$C000 Reset" {
+		t.Errorf("Label = %q", got)
+	}
+	if got := tab.Label(0xC001, -1); got != "$C001" {
+		t.Errorf("unnamed Label = %q", got)
+	}
+
+	var empty *Table
+	if _, ok := empty.Lookup(0xC000, -1); ok || empty.Len() != 0 || empty.Label(0xC000, -1) != "$C000" {
+		t.Error("a nil table should be empty")
+	}
+}
@@ -0,0 +1,79 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// nlBankSize is the PRG ROM bank size FCEUX numbers its .nl files by.
+const nlBankSize = 0x4000
+
+// LoadNL reads an FCEUX name list: one "$addr#name#comment" per line,
+// "$addr/size#..." for an array. FCEUX keeps one file per 16KB PRG bank,
+// <rom>.nes.<bank in hex>.nl, plus <rom>.nes.ram.nl for everything below
+// $8000; bank is the file's bank number, or -1 for the RAM file, and ties
+// its symbols at $8000 and up to that bank.
+func LoadNL(r io.Reader, bank int) ([]Symbol, error) {
+	return scanLines(r, func(text string) (Symbol, bool) {
+		if !strings.HasPrefix(text, "$") {
+			return Symbol{}, false
+		}
+		fields := strings.SplitN(text, "#", 3)
+		addr, _, _ := strings.Cut(fields[0][1:], "/")
+		a, err := strconv.ParseUint(addr, 16, 16)
+		if err != nil || len(fields) < 2 || fields[1] == "" {
+			return Symbol{}, false
+		}
+		s := Symbol{Name: fields[1], Address: uint16(a), PRG: -1}
+		if len(fields) == 3 {
+			s.Comment = fields[2]
+		}
+		if bank >= 0 && s.Address >= 0x8000 {
+			s.PRG = bank*nlBankSize + int(s.Address)%nlBankSize
+		}
+		return s, true
+	})
+}
+
+// LoadFNS reads a NESASM .fns file: one "name = $addr" per line, with ";"
+// starting a comment. NESASM doesn't record banks, so neither do the
+// symbols.
+func LoadFNS(r io.Reader) ([]Symbol, error) {
+	return scanLines(r, func(text string) (Symbol, bool) {
+		if i := strings.IndexByte(text, ';'); i >= 0 {
+			text = text[:i]
+		}
+		name, value, ok := strings.Cut(text, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || !strings.HasPrefix(value, "$") {
+			return Symbol{}, false
+		}
+		a, err := strconv.ParseUint(value[1:], 16, 16)
+		if err != nil {
+			return Symbol{}, false
+		}
+		return Symbol{Name: name, Address: uint16(a), PRG: -1}, true
+	})
+}
+
+// scanLines parses each line of r that isn't blank or a ";" comment,
+// failing on the first one parse rejects.
+func scanLines(r io.Reader, parse func(string) (Symbol, bool)) ([]Symbol, error) {
+	var syms []Symbol
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, ";") {
+			continue
+		}
+		s, ok := parse(text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: not a symbol: %q", line, text)
+		}
+		syms = append(syms, s)
+	}
+	return syms, sc.Err()
+}
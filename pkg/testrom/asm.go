@@ -0,0 +1,168 @@
+package testrom
+
+import (
+	"fmt"
+
+	"github.com/yoshiomiyamaegones/pkg/cpu"
+)
+
+// Program is 6502 code built an instruction at a time, with labels for
+// jump and branch targets, which Assemble resolves:
+//
+//	testrom.Code(0x8000).
+//		Op("LDX", testrom.Imm(0)).
+//		Label("loop").
+//		Op("INX").
+//		Op("BNE", testrom.To("loop")).
+//		Op("JMP", testrom.To("loop"))
+//
+// Op takes the mnemonic and at most one Operand, whose constructor names
+// the addressing mode; no operand means implied, or accumulator for the
+// shifts. Only the documented opcodes are available.
+type Program struct {
+	org    uint16
+	code   []byte
+	labels map[string]uint16
+	fixups []fixup
+	err    error
+}
+
+// fixup is a label reference waiting for Assemble: the operand at code[at]
+// of the instruction that ends at code[next].
+type fixup struct {
+	at, next int
+	label    string
+	relative bool
+}
+
+// Code starts a program that will run at org.
+func Code(org uint16) *Program {
+	return &Program{org: org, labels: map[string]uint16{}}
+}
+
+// Operand is an instruction's argument and addressing mode.
+type Operand struct {
+	mode  cpu.AddressingMode
+	value uint16
+	label string
+}
+
+// Imm is #value.
+func Imm(v uint8) Operand { return Operand{mode: cpu.AddrImmediate, value: uint16(v)} }
+
+// Zp is a zero-page address.
+func Zp(addr uint8) Operand { return Operand{mode: cpu.AddrZeroPage, value: uint16(addr)} }
+
+// ZpX is zp,X.
+func ZpX(addr uint8) Operand { return Operand{mode: cpu.AddrZeroPageX, value: uint16(addr)} }
+
+// ZpY is zp,Y.
+func ZpY(addr uint8) Operand { return Operand{mode: cpu.AddrZeroPageY, value: uint16(addr)} }
+
+// Abs is an absolute address.
+func Abs(addr uint16) Operand { return Operand{mode: cpu.AddrAbsolute, value: addr} }
+
+// AbsX is addr,X.
+func AbsX(addr uint16) Operand { return Operand{mode: cpu.AddrAbsoluteX, value: addr} }
+
+// AbsY is addr,Y.
+func AbsY(addr uint16) Operand { return Operand{mode: cpu.AddrAbsoluteY, value: addr} }
+
+// Ind is (addr), for JMP.
+func Ind(addr uint16) Operand { return Operand{mode: cpu.AddrIndirect, value: addr} }
+
+// IndX is (zp,X).
+func IndX(addr uint8) Operand { return Operand{mode: cpu.AddrIndexedIndirect, value: uint16(addr)} }
+
+// IndY is (zp),Y.
+func IndY(addr uint8) Operand { return Operand{mode: cpu.AddrIndirectIndexed, value: uint16(addr)} }
+
+// To is a label: relative for branches, absolute otherwise.
+func To(label string) Operand { return Operand{mode: cpu.AddrAbsolute, label: label} }
+
+// PC returns the address the next instruction will be at.
+func (p *Program) PC() uint16 { return p.org + uint16(len(p.code)) }
+
+func (p *Program) fail(format string, args ...any) *Program {
+	if p.err == nil {
+		p.err = fmt.Errorf("testrom: $%04X: "+format, append([]any{p.PC()}, args...)...)
+	}
+	return p
+}
+
+// Label names the current address.
+func (p *Program) Label(name string) *Program {
+	if _, ok := p.labels[name]; ok {
+		return p.fail("label %q defined twice", name)
+	}
+	p.labels[name] = p.PC()
+	return p
+}
+
+// Bytes emits raw bytes: data, or an instruction Op can't express.
+func (p *Program) Bytes(b ...byte) *Program {
+	p.code = append(p.code, b...)
+	return p
+}
+
+// Op emits one instruction.
+func (p *Program) Op(name string, arg ...Operand) *Program {
+	if len(arg) > 1 {
+		return p.fail("%s takes one operand, got %d", name, len(arg))
+	}
+	if len(arg) == 0 {
+		for _, mode := range []cpu.AddressingMode{cpu.AddrImplied, cpu.AddrAccumulator} {
+			if op, ok := cpu.Encode(name, mode); ok {
+				return p.Bytes(op)
+			}
+		}
+		return p.fail("%s needs an operand", name)
+	}
+	a := arg[0]
+	if op, ok := cpu.Encode(name, cpu.AddrRelative); ok {
+		if a.label == "" {
+			return p.fail("%s needs a label", name)
+		}
+		p.fixups = append(p.fixups, fixup{at: len(p.code) + 1, next: len(p.code) + 2, label: a.label, relative: true})
+		return p.Bytes(op, 0)
+	}
+	op, ok := cpu.Encode(name, a.mode)
+	if !ok {
+		return p.fail("%s has no such addressing mode", name)
+	}
+	if a.label != "" {
+		p.fixups = append(p.fixups, fixup{at: len(p.code) + 1, next: len(p.code) + 3, label: a.label})
+	}
+	if cpu.InstructionLength(op) == 2 {
+		return p.Bytes(op, byte(a.value))
+	}
+	return p.Bytes(op, byte(a.value), byte(a.value>>8))
+}
+
+// Assemble resolves the labels and returns the machine code.
+func (p *Program) Assemble() ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	code := append([]byte(nil), p.code...)
+	for _, f := range p.fixups {
+		target, ok := p.labels[f.label]
+		if !ok {
+			return nil, fmt.Errorf("testrom: undefined label %q", f.label)
+		}
+		if !f.relative {
+			code[f.at], code[f.at+1] = byte(target), byte(target>>8)
+			continue
+		}
+		dist := int(target) - int(p.org) - f.next
+		if dist < -128 || dist > 127 {
+			return nil, fmt.Errorf("testrom: branch to %q is %d bytes away, out of range", f.label, dist)
+		}
+		code[f.at] = byte(int8(dist))
+	}
+	return code, nil
+}
+
+// Addr returns the address of label, 0 if it isn't defined (yet): tests
+// use it for where a program halts.
+func (p *Program) Addr(label string) uint16 { return p.labels[label] }
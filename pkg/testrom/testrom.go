@@ -0,0 +1,383 @@
+// Package testrom builds cartridge images in memory for tests, so a test
+// that needs a mapper or a few instructions running doesn't hand-pack an
+// iNES header and patch vectors into a byte slice:
+//
+//	cart, err := testrom.New().Mapper(4).PRG(32).CHRRAM(32).
+//		Program(testrom.Code(0xE000).
+//			Op("LDA", testrom.Imm(0x42)).
+//			Op("STA", testrom.Zp(0x00)).
+//			Label("halt").Op("JMP", testrom.To("halt"))).
+//		Reset(0xE000).
+//		Cartridge()
+//
+// Programs can also come from source text through package asm6502 (Asm),
+// and NES puts the cartridge in a powered-on console.
+//
+// A ROM starts out as NROM with 16KB PRG, 8KB CHR ROM, horizontal
+// mirroring and every vector at $8000. Builder methods return the ROM so
+// they chain; the first mistake (a program that doesn't assemble, data
+// past the end of PRG) is kept and returned by Build.
+package testrom
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yoshiomiyamaegones/pkg/asm6502"
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// ROM is a cartridge image under construction.
+type ROM struct {
+	mapper    uint16
+	submapper uint8
+	nes20     bool
+	mirroring cartridge.MirroringMode
+	battery   bool
+	prg       []byte
+	chr       []byte
+	prgRAM    int // NES 2.0 volatile PRG RAM, bytes
+	chrRAM    int // NES 2.0 volatile CHR RAM, bytes
+	vectors   [3]uint16
+	err       error
+}
+
+// New returns an NROM image: 16KB PRG, 8KB CHR ROM, both zero, and the
+// NMI, reset and IRQ vectors all pointing at $8000.
+func New() *ROM {
+	return &ROM{
+		prg:       make([]byte, 16*1024),
+		chr:       make([]byte, 8*1024),
+		mirroring: cartridge.MirroringHorizontal,
+		vectors:   [3]uint16{0x8000, 0x8000, 0x8000},
+	}
+}
+
+func (r *ROM) fail(format string, args ...any) *ROM {
+	if r.err == nil {
+		r.err = fmt.Errorf("testrom: "+format, args...)
+	}
+	return r
+}
+
+// Mapper selects the mapper. Numbers above 255 need NES 2.0, which is
+// switched on for them.
+func (r *ROM) Mapper(n int) *ROM {
+	if n < 0 || n > 0xFFF {
+		return r.fail("mapper %d out of range", n)
+	}
+	r.mapper = uint16(n)
+	if n > 0xFF {
+		r.nes20 = true
+	}
+	return r
+}
+
+// Submapper sets the NES 2.0 submapper, switching the header to NES 2.0.
+func (r *ROM) Submapper(n int) *ROM {
+	if n < 0 || n > 15 {
+		return r.fail("submapper %d out of range", n)
+	}
+	r.submapper = uint8(n)
+	r.nes20 = true
+	return r
+}
+
+// NES20 writes an NES 2.0 header rather than iNES 1.0.
+func (r *ROM) NES20() *ROM {
+	r.nes20 = true
+	return r
+}
+
+// Mirroring sets the header's nametable arrangement: horizontal,
+// vertical or four-screen. Mappers that control mirroring ignore it.
+func (r *ROM) Mirroring(m cartridge.MirroringMode) *ROM {
+	switch m {
+	case cartridge.MirroringHorizontal, cartridge.MirroringVertical, cartridge.MirroringFourScreen:
+		r.mirroring = m
+		return r
+	}
+	return r.fail("mirroring %d can't be set in a header", m)
+}
+
+// Battery marks PRG RAM battery-backed.
+func (r *ROM) Battery() *ROM {
+	r.battery = true
+	return r
+}
+
+// PRG sets the PRG ROM size in KB, a multiple of 16. Contents placed so
+// far are kept as far as they fit.
+func (r *ROM) PRG(kb int) *ROM {
+	if kb <= 0 || kb%16 != 0 || kb/16 > 0xFF {
+		return r.fail("PRG size %dKB isn't a multiple of 16KB from 16KB to 4080KB", kb)
+	}
+	r.prg = resize(r.prg, kb*1024)
+	return r
+}
+
+// CHR sets the CHR ROM size in KB, a multiple of 8; 0 leaves the
+// cartridge with CHR RAM instead.
+func (r *ROM) CHR(kb int) *ROM {
+	if kb < 0 || kb%8 != 0 || kb/8 > 0xFF {
+		return r.fail("CHR size %dKB isn't a multiple of 8KB up to 2040KB", kb)
+	}
+	r.chr = resize(r.chr, kb*1024)
+	return r
+}
+
+// CHRRAM gives the cartridge kb KB of CHR RAM and no CHR ROM. Sizes other
+// than 8KB are written to an NES 2.0 header.
+func (r *ROM) CHRRAM(kb int) *ROM {
+	r.CHR(0)
+	if kb != 8 {
+		r.nes20 = true
+	}
+	return r.setRAM(&r.chrRAM, kb, "CHR")
+}
+
+// PRGRAM gives the cartridge kb KB of PRG RAM in an NES 2.0 header.
+func (r *ROM) PRGRAM(kb int) *ROM {
+	r.nes20 = true
+	return r.setRAM(&r.prgRAM, kb, "PRG")
+}
+
+func (r *ROM) setRAM(size *int, kb int, what string) *ROM {
+	if _, ok := ramShift(kb * 1024); !ok {
+		return r.fail("%s RAM size %dKB isn't a power of two", what, kb)
+	}
+	*size = kb * 1024
+	return r
+}
+
+// ramShift is the NES 2.0 encoding of a RAM size: 64 << shift bytes, 0
+// for none.
+func ramShift(size int) (uint8, bool) {
+	if size == 0 {
+		return 0, true
+	}
+	for shift := uint8(1); shift < 15; shift++ {
+		if 64<<shift == size {
+			return shift, true
+		}
+	}
+	return 0, false
+}
+
+func resize(b []byte, n int) []byte {
+	if n <= len(b) {
+		return b[:n]
+	}
+	return append(b, make([]byte, n-len(b))...)
+}
+
+// prgOffset maps a CPU address to the PRG offset it reads at power-on on
+// a board whose last bank is fixed: $8000-$FFFF show the last 32KB of PRG,
+// or the 16KB twice over on NROM-128.
+func (r *ROM) prgOffset(addr uint16) (int, bool) {
+	if addr < 0x8000 {
+		return 0, false
+	}
+	window := min(len(r.prg), 0x8000)
+	return len(r.prg) - window + int(addr-0x8000)%window, true
+}
+
+// PRGAt copies data into PRG ROM at offset, for banks a mapper switches in.
+func (r *ROM) PRGAt(offset int, data []byte) *ROM {
+	if offset < 0 || offset+len(data) > len(r.prg) {
+		return r.fail("%d bytes at PRG offset $%X run past the %dKB of PRG", len(data), offset, len(r.prg)/1024)
+	}
+	copy(r.prg[offset:], data)
+	return r
+}
+
+// Org copies data into PRG ROM where the CPU sees it at addr (see
+// prgOffset): for NROM anywhere, for bigger boards the fixed last bank.
+func (r *ROM) Org(addr uint16, data []byte) *ROM {
+	offset, ok := r.prgOffset(addr)
+	if !ok || int(addr)+len(data) > 0x10000 {
+		return r.fail("%d bytes at $%04X aren't in $8000-$FFFF", len(data), addr)
+	}
+	return r.PRGAt(offset, data)
+}
+
+// Program assembles p and places it at its origin with Org.
+func (r *ROM) Program(p *Program) *ROM {
+	code, err := p.Assemble()
+	if err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return r
+	}
+	return r.Org(p.org, code)
+}
+
+// ProgramAt assembles p and places it at PRG offset, for code in a bank
+// that is switched in at p's origin.
+func (r *ROM) ProgramAt(offset int, p *Program) *ROM {
+	code, err := p.Assemble()
+	if err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return r
+	}
+	return r.PRGAt(offset, code)
+}
+
+// Asm places a program from package asm6502, each segment where the CPU
+// sees it (see Org). The vectors are still the builder's: words the source
+// puts at $FFFA-$FFFF are overwritten by Build.
+func (r *ROM) Asm(p *asm6502.Program) *ROM {
+	for _, seg := range p.Segments {
+		r.Org(seg.Addr, seg.Code)
+	}
+	return r
+}
+
+// FillPRG sets every PRG byte to f(offset), e.g. each bank its number.
+func (r *ROM) FillPRG(f func(offset int) byte) *ROM {
+	for i := range r.prg {
+		r.prg[i] = f(i)
+	}
+	return r
+}
+
+// FillCHR sets every CHR ROM byte to f(offset).
+func (r *ROM) FillCHR(f func(offset int) byte) *ROM {
+	for i := range r.chr {
+		r.chr[i] = f(i)
+	}
+	return r
+}
+
+// CHRAt copies data into CHR ROM at offset.
+func (r *ROM) CHRAt(offset int, data []byte) *ROM {
+	if offset < 0 || offset+len(data) > len(r.chr) {
+		return r.fail("%d bytes at CHR offset $%X run past the %dKB of CHR ROM", len(data), offset, len(r.chr)/1024)
+	}
+	copy(r.chr[offset:], data)
+	return r
+}
+
+// Tile draws CHR ROM tile n (16 bytes at n*16) from eight rows of eight
+// colour digits, 0-3, e.g. "00112233".
+func (r *ROM) Tile(n int, rows [8]string) *ROM {
+	var tile [16]byte
+	for y, row := range rows {
+		if len(row) != 8 {
+			return r.fail("tile %d row %d is %q, want 8 digits", n, y, row)
+		}
+		for x := 0; x < 8; x++ {
+			c := row[x] - '0'
+			if c > 3 {
+				return r.fail("tile %d row %d has colour %q", n, y, row[x])
+			}
+			bit := byte(0x80) >> x
+			if c&1 != 0 {
+				tile[y] |= bit
+			}
+			if c&2 != 0 {
+				tile[y+8] |= bit
+			}
+		}
+	}
+	return r.CHRAt(n*16, tile[:])
+}
+
+// Vectors sets the NMI, reset and IRQ vectors.
+func (r *ROM) Vectors(nmi, reset, irq uint16) *ROM {
+	r.vectors = [3]uint16{nmi, reset, irq}
+	return r
+}
+
+// Reset sets just the reset vector.
+func (r *ROM) Reset(addr uint16) *ROM {
+	r.vectors[1] = addr
+	return r
+}
+
+// NMI sets just the NMI vector.
+func (r *ROM) NMI(addr uint16) *ROM {
+	r.vectors[0] = addr
+	return r
+}
+
+// IRQ sets just the IRQ vector.
+func (r *ROM) IRQ(addr uint16) *ROM {
+	r.vectors[2] = addr
+	return r
+}
+
+// header returns the 16-byte iNES or NES 2.0 header.
+func (r *ROM) header() []byte {
+	h := []byte("NES\x1A\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	h[4] = byte(len(r.prg) / 0x4000)
+	h[5] = byte(len(r.chr) / 0x2000)
+	h[6] = byte(r.mapper&0x0F) << 4
+	h[7] = byte(r.mapper & 0xF0)
+	switch r.mirroring {
+	case cartridge.MirroringVertical:
+		h[6] |= 0x01
+	case cartridge.MirroringFourScreen:
+		h[6] |= 0x08
+	}
+	if r.battery {
+		h[6] |= 0x02
+	}
+	if r.nes20 {
+		h[7] |= 0x08
+		h[8] = r.submapper<<4 | byte(r.mapper>>8)
+		prg, _ := ramShift(r.prgRAM)
+		chr, _ := ramShift(r.chrRAM)
+		if r.battery {
+			h[10] = prg << 4
+		} else {
+			h[10] = prg
+		}
+		h[11] = chr
+	}
+	return h
+}
+
+// Build returns the iNES file, or the first error a builder call made.
+func (r *ROM) Build() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	prg := append([]byte(nil), r.prg...)
+	off, _ := r.prgOffset(0xFFFA)
+	for i, v := range r.vectors {
+		prg[off+2*i] = byte(v)
+		prg[off+2*i+1] = byte(v >> 8)
+	}
+	var out bytes.Buffer
+	out.Write(r.header())
+	out.Write(prg)
+	out.Write(r.chr)
+	return out.Bytes(), nil
+}
+
+// Cartridge builds the image and loads it as the emulator would a file.
+func (r *ROM) Cartridge() (*cartridge.Cartridge, error) {
+	data, err := r.Build()
+	if err != nil {
+		return nil, err
+	}
+	return cartridge.LoadFromReader(bytes.NewReader(data))
+}
+
+// NES builds the cartridge and powers on a console built with opts
+// running it.
+func (r *ROM) NES(opts ...nes.Option) (*nes.NES, error) {
+	cart, err := r.Cartridge()
+	if err != nil {
+		return nil, err
+	}
+	n := nes.NewNES(opts...)
+	n.LoadCartridge(cart)
+	n.PowerOn()
+	return n, nil
+}
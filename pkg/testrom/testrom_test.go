@@ -0,0 +1,164 @@
+package testrom
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/asm6502"
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+)
+
+func TestProgram(t *testing.T) {
+	p := Code(0x8000).
+		Op("LDX", Imm(0x10)).
+		Label("loop").
+		Op("DEX").
+		Op("BNE", To("loop")).
+		Op("ASL").
+		Op("STA", AbsX(0x0300)).
+		Op("LDA", IndY(0x20)).
+		Op("JMP", To("end")).
+		Bytes(0xFF).
+		Label("end").
+		Op("JMP", Ind(0xFFFC))
+	code, err := p.Assemble()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0xA2, 0x10, // LDX #$10
+		0xCA,       // loop: DEX
+		0xD0, 0xFD, // BNE loop
+		0x0A,             // ASL A
+		0x9D, 0x00, 0x03, // STA $0300,X
+		0xB1, 0x20, // LDA ($20),Y
+		0x4C, 0x0F, 0x80, // JMP end
+		0xFF,
+		0x6C, 0xFC, 0xFF, // end: JMP ($FFFC)
+	}
+	if !bytes.Equal(code, want) {
+		t.Errorf("code = % X\nwant   % X", code, want)
+	}
+	if p.Addr("end") != 0x800F || p.PC() != 0x8012 {
+		t.Errorf("end = $%04X, PC = $%04X; want $800F, $8012", p.Addr("end"), p.PC())
+	}
+
+	for _, tc := range []struct {
+		p    *Program
+		want string
+	}{
+		{Code(0x8000).Op("STA", Imm(1)), "no such addressing mode"},
+		{Code(0x8000).Op("LDA"), "needs an operand"},
+		{Code(0x8000).Op("BEQ", Abs(0x8000)), "needs a label"},
+		{Code(0x8000).Op("JMP", To("nowhere")), `undefined label "nowhere"`},
+		{Code(0x8000).Label("a").Label("a"), "defined twice"},
+		{Code(0x8000).Label("far").Bytes(make([]byte, 200)...).Op("BNE", To("far")), "out of range"},
+	} {
+		if _, err := tc.p.Assemble(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("error %v, want it to mention %q", err, tc.want)
+		}
+	}
+}
+
+func TestBuildNROM(t *testing.T) {
+	data, err := New().Program(Code(0xC000).Op("NOP")).Reset(0xC000).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[:8], []byte("NES\x1A\x01\x01\x00\x00")) || len(data) != 16+0x4000+0x2000 {
+		t.Errorf("header % X, %d bytes", data[:16], len(data))
+	}
+	// NROM-128 shows its 16KB at $8000 and $C000 both.
+	prg := data[16:]
+	if prg[0] != 0xEA || prg[0x3FFC] != 0x00 || prg[0x3FFD] != 0xC0 || prg[0x3FFA] != 0x00 || prg[0x3FFB] != 0x80 {
+		t.Errorf("NOP at %02X, vectors % X", prg[0], prg[0x3FFA:])
+	}
+}
+
+func TestBuildNES20(t *testing.T) {
+	cart, err := New().Mapper(4).Submapper(1).PRG(64).CHRRAM(32).Mirroring(cartridge.MirroringVertical).
+		Org(0xE000, []byte{0x42}).
+		PRGAt(0x2000, []byte{0x24}).
+		Cartridge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := cart.Header
+	if !h.IsNES20() || h.MapperNumber() != 4 || h.Submapper() != 1 || h.CHRRAMSize() != 32*1024 {
+		t.Errorf("header: NES 2.0 %v, mapper %d.%d, CHR RAM %d", h.IsNES20(), h.MapperNumber(), h.Submapper(), h.CHRRAMSize())
+	}
+	if cart.Mirroring != cartridge.MirroringVertical || len(cart.PRGROM) != 64*1024 {
+		t.Errorf("mirroring %d, %d bytes of PRG", cart.Mirroring, len(cart.PRGROM))
+	}
+	// $E000 is in the last 8KB; PRGAt is a plain offset.
+	if cart.PRGROM[0xE000] != 0x42 || cart.PRGROM[0x2000] != 0x24 {
+		t.Errorf("PRG[$E000] = %02X, PRG[$2000] = %02X", cart.PRGROM[0xE000], cart.PRGROM[0x2000])
+	}
+}
+
+func TestNES(t *testing.T) {
+	n, err := New().Program(Code(0x8000).
+		Op("LDA", Imm(0x42)).
+		Op("STA", Zp(0x10)).
+		Label("halt").Op("JMP", To("halt"))).
+		NES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.CPU.PC != 0x8000 {
+		t.Fatalf("PC = $%04X after power-on, want $8000", n.CPU.PC)
+	}
+	n.Step()
+	n.Step()
+	if got := n.Memory.Peek(0x10); got != 0x42 {
+		t.Errorf("$10 = %02X, want 42", got)
+	}
+}
+
+func TestAsm(t *testing.T) {
+	p, err := asm6502.Assemble("lda #1\n.org $FFF0\n.db 2", 0xC000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := New().PRG(32).Asm(p).Reset(0xC000).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prg := data[16:]
+	if prg[0x4000] != 0xA9 || prg[0x4001] != 0x01 || prg[0x7FF0] != 0x02 || prg[0x7FFC] != 0x00 || prg[0x7FFD] != 0xC0 {
+		t.Errorf("PRG[$4000] = % X, PRG[$7FF0] = %02X, reset % X", prg[0x4000:0x4002], prg[0x7FF0], prg[0x7FFC:0x7FFE])
+	}
+}
+
+func TestTile(t *testing.T) {
+	data, err := New().Tile(1, [8]string{"01230123", "33333333", "00000000", "00000000", "00000000", "00000000", "00000000", "00000000"}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile := data[16+0x4000+16:][:16]
+	if tile[0] != 0x55 || tile[8] != 0x33 || tile[1] != 0xFF || tile[9] != 0xFF {
+		t.Errorf("tile planes % X", tile)
+	}
+	if _, err := New().Tile(0, [8]string{"0124"}).Build(); err == nil {
+		t.Error("a short row should fail")
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for _, tc := range []struct {
+		r    *ROM
+		want string
+	}{
+		{New().PRG(24), "multiple of 16KB"},
+		{New().CHRRAM(24), "power of two"},
+		{New().Org(0x6000, []byte{1}), "aren't in $8000-$FFFF"},
+		{New().PRGAt(0x3FFF, []byte{1, 2}), "run past"},
+		{New().Program(Code(0x8000).Op("XYZ")), "XYZ"},
+		{New().Mirroring(cartridge.MirroringSingleScreenLower), "can't be set"},
+	} {
+		if _, err := tc.r.Build(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("error %v, want it to mention %q", err, tc.want)
+		}
+	}
+}
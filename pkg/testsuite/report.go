@@ -0,0 +1,115 @@
+package testsuite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is the JSON form of a run: the results and their tally.
+type Report struct {
+	Counts  map[Outcome]int `json:"counts"`
+	Total   int             `json:"total"`
+	Results []Result        `json:"results"`
+}
+
+// NewReport tallies results.
+func NewReport(results []Result) Report {
+	rep := Report{Counts: map[Outcome]int{}, Total: len(results), Results: results}
+	for _, r := range results {
+		rep.Counts[r.Outcome]++
+	}
+	return rep
+}
+
+// Summary is the tally in a line, e.g. "41/45 passed, 3 fail, 1 timeout".
+func (rep Report) Summary() string {
+	s := fmt.Sprintf("%d/%d passed", rep.Counts[Pass], rep.Total)
+	for _, o := range []Outcome{Fail, Timeout, NoResult, Error} {
+		if n := rep.Counts[o]; n > 0 {
+			s += fmt.Sprintf(", %d %s", n, o)
+		}
+	}
+	return s
+}
+
+// WriteText writes results as a table, one ROM per row, with the first
+// line of what a failing ROM printed, then the summary line.
+func WriteText(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROM\tRESULT\tSTATUS\tFRAMES\tTIME\tMESSAGE")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t$%02X\t%d\t%s\t%s\n",
+			r.ROM, r.Outcome, r.Status, r.Frames, r.Time.Round(10*time.Millisecond), r.message())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, NewReport(results).Summary())
+	return err
+}
+
+// message is the table's MESSAGE: the error, or for a ROM that didn't
+// pass, the last line it printed (where blargg's ROMs put the verdict).
+func (r Result) message() string {
+	if r.Err != "" {
+		return r.Err
+	}
+	if r.Outcome == Pass {
+		return ""
+	}
+	lines := strings.Split(r.Text, "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// WriteJSON writes results as an indented Report.
+func WriteJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewReport(results))
+}
+
+// ReadJSON reads a Report WriteJSON wrote.
+func ReadJSON(r io.Reader) (Report, error) {
+	var rep Report
+	if err := json.NewDecoder(r).Decode(&rep); err != nil {
+		return Report{}, err
+	}
+	return rep, nil
+}
+
+// Change is a ROM whose outcome differs between two runs.
+type Change struct {
+	ROM      string
+	Old, New Outcome // Old is "" for a ROM the earlier run didn't have
+}
+
+// Regressed reports whether the ROM passed before and doesn't now.
+func (c Change) Regressed() bool { return c.Old == Pass && c.New != Pass }
+
+func (c Change) String() string {
+	old := c.Old
+	if old == "" {
+		old = "new"
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.ROM, old, c.New)
+}
+
+// Compare lists the ROMs in results whose outcome differs from base's,
+// in results' order. ROMs base has and results lacks are left out.
+func Compare(base Report, results []Result) []Change {
+	old := make(map[string]Outcome, len(base.Results))
+	for _, r := range base.Results {
+		old[r.ROM] = r.Outcome
+	}
+	var changes []Change
+	for _, r := range results {
+		if o := old[r.ROM]; o != r.Outcome {
+			changes = append(changes, Change{ROM: r.ROM, Old: o, New: r.Outcome})
+		}
+	}
+	return changes
+}
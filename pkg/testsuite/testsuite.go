@@ -0,0 +1,234 @@
+// Package testsuite runs a directory of test ROMs headlessly and reports
+// which pass, so emulator accuracy can be tracked from commit to commit
+// ("gones testsuite"):
+//
+//	results, err := testsuite.Run("nes-test-roms", testsuite.Options{})
+//	testsuite.WriteText(os.Stdout, results)
+//
+// A result comes from the protocol blargg's test ROMs (and many later
+// ones) share: once $6001-$6003 hold the signature DE B0 61, $6000 is $80
+// while the test runs, $81 when it wants the reset button pressed, and
+// the result code when it is done, 0 meaning passed; $6004 on holds the
+// text it printed. A ROM that never writes the signature has no result.
+package testsuite
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// Outcome is how a test ROM ended.
+type Outcome string
+
+const (
+	Pass     Outcome = "pass"      // result code 0
+	Fail     Outcome = "fail"      // a nonzero result code
+	Timeout  Outcome = "timeout"   // still running when a limit ran out
+	NoResult Outcome = "no-result" // never wrote the $6001 signature
+	Error    Outcome = "error"     // didn't load, or crashed the emulator
+)
+
+// Result is one ROM's run.
+type Result struct {
+	ROM     string        `json:"rom"` // path relative to the suite directory
+	Outcome Outcome       `json:"outcome"`
+	Status  uint8         `json:"status"` // $6000 when the run ended
+	Text    string        `json:"text,omitempty"`
+	Frames  int           `json:"frames"`
+	Time    time.Duration `json:"time_ns"`
+	Err     string        `json:"error,omitempty"`
+}
+
+// Limits bound one ROM's run: Frames of emulation and Timeout of wall
+// time, whichever runs out first. Zero means the default.
+type Limits struct {
+	Frames  int
+	Timeout time.Duration
+}
+
+// Default limits: a minute of emulated time covers the longest blargg
+// tests several times over; the wall-clock limit only catches a ROM that
+// makes the emulator crawl.
+const (
+	DefaultFrames  = 3600
+	DefaultTimeout = 60 * time.Second
+)
+
+func (l Limits) withDefaults() Limits {
+	if l.Frames <= 0 {
+		l.Frames = DefaultFrames
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultTimeout
+	}
+	return l
+}
+
+// Protocol addresses and status codes.
+const (
+	statusAddr = 0x6000
+	sigAddr    = 0x6001
+	textAddr   = 0x6004
+	running    = 0x80
+	needReset  = 0x81
+)
+
+// RunNES runs the ROM already loaded into sys until it reports a result
+// or a limit runs out, pressing reset whenever it asks. The result's ROM
+// is left empty.
+func RunNES(sys *nes.NES, lim Limits) Result {
+	lim = lim.withDefaults()
+	start := time.Now()
+	r := Result{Outcome: Timeout}
+	signed := false
+	prev := uint8(0)
+	for r.Frames < lim.Frames && time.Since(start) < lim.Timeout {
+		sys.StepFrame()
+		r.Frames++
+		if !signed {
+			signed = sys.Memory.Peek(sigAddr) == 0xDE && sys.Memory.Peek(sigAddr+1) == 0xB0 && sys.Memory.Peek(sigAddr+2) == 0x61
+			if !signed {
+				continue
+			}
+		}
+		r.Status = sys.Memory.Peek(statusAddr)
+		// $81 stays put through the ROM's own post-reset setup until it
+		// writes $80 again, so reset only on the way in.
+		if r.Status == needReset && prev != needReset {
+			sys.SoftReset()
+		}
+		prev = r.Status
+		if r.Status < running {
+			r.Outcome = Fail
+			if r.Status == 0 {
+				r.Outcome = Pass
+			}
+			break
+		}
+	}
+	if !signed {
+		r.Outcome = NoResult
+	}
+	r.Text = readText(sys)
+	r.Time = time.Since(start)
+	return r
+}
+
+// readText returns the text the ROM printed at $6004, printable ASCII and
+// newlines only, trimmed.
+func readText(sys *nes.NES) string {
+	var b strings.Builder
+	for addr := uint16(textAddr); addr < 0x8000; addr++ {
+		c := sys.Memory.Peek(addr)
+		if c == 0 {
+			break
+		}
+		if c >= 0x20 && c < 0x7F || c == '\n' {
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// RunFile loads the ROM at path into a deterministic NES and runs it. A
+// ROM that fails to load or panics the emulator gives an Error result.
+func RunFile(path string, lim Limits) (r Result) {
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			r = Result{Outcome: Error, Err: fmt.Sprintf("panic: %v", p), Time: time.Since(start)}
+		}
+	}()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{Outcome: Error, Err: err.Error()}
+	}
+	cart, err := cartridge.LoadFromReader(bytes.NewReader(data))
+	if err != nil {
+		return Result{Outcome: Error, Err: err.Error()}
+	}
+	sys := nes.NewNES(nes.WithDeterministic())
+	sys.LoadCartridge(cart)
+	sys.Reset()
+	return RunNES(sys, lim)
+}
+
+// Find returns the .nes files under dir, subdirectories included, sorted.
+func Find(dir string) ([]string, error) {
+	var roms []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".nes") {
+			roms = append(roms, path)
+		}
+		return nil
+	})
+	sort.Strings(roms)
+	return roms, err
+}
+
+// Options configure Run.
+type Options struct {
+	Limits
+	// Jobs is how many ROMs run at once; 0 means one per CPU.
+	Jobs int
+	// Progress, if set, is called with each result as it finishes, from
+	// the goroutine that ran it.
+	Progress func(Result)
+}
+
+// Run runs every .nes file under dir, opts.Jobs at a time, and returns
+// their results in the order Find lists them.
+func Run(dir string, opts Options) ([]Result, error) {
+	roms, err := Find(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(roms) == 0 {
+		return nil, fmt.Errorf("no .nes files in %s", dir)
+	}
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	results := make([]Result, len(roms))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(jobs, len(roms)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r := RunFile(roms[i], opts.Limits)
+				r.ROM = roms[i]
+				if rel, err := filepath.Rel(dir, roms[i]); err == nil {
+					r.ROM = filepath.ToSlash(rel)
+				}
+				results[i] = r
+				if opts.Progress != nil {
+					opts.Progress(r)
+				}
+			}
+		}()
+	}
+	for i := range roms {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results, nil
+}
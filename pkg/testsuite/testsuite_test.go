@@ -0,0 +1,169 @@
+package testsuite
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/testrom"
+)
+
+// report returns a program that signs the protocol, prints text and sets
+// $6000 to status, then spins.
+func report(status uint8, text string) *testrom.Program {
+	p := testrom.Code(0x8000)
+	for i, b := range []byte{0x80, 0xDE, 0xB0, 0x61} {
+		p.Op("LDA", testrom.Imm(b)).Op("STA", testrom.Abs(uint16(0x6000+i)))
+	}
+	for i, c := range []byte(text + "\x00") {
+		p.Op("LDA", testrom.Imm(c)).Op("STA", testrom.Abs(uint16(0x6004+i)))
+	}
+	return p.Op("LDA", testrom.Imm(status)).Op("STA", testrom.Abs(0x6000)).
+		Label("halt").Op("JMP", testrom.To("halt"))
+}
+
+// writeROM builds a ROM running p and writes it to dir/name.
+func writeROM(t *testing.T, dir, name string, p *testrom.Program) {
+	t.Helper()
+	data, err := testrom.New().Program(p).Reset(0x8000).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeROM(t, dir, "a/pass.nes", report(0, "\nPassed"))
+	writeROM(t, dir, "a/fail.nes", report(3, "Timing\n\nFailed #3"))
+	writeROM(t, dir, "busy.nes", report(0x80, "running"))
+	writeROM(t, dir, "silent.nes", testrom.Code(0x8000).Label("l").Op("JMP", testrom.To("l")))
+	if err := os.WriteFile(filepath.Join(dir, "bad.nes"), []byte("not a ROM"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "readme.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress atomic.Int32 // Progress runs on the workers
+	results, err := Run(dir, Options{Limits: Limits{Frames: 20}, Jobs: 3, Progress: func(Result) { progress.Add(1) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		rom     string
+		outcome Outcome
+		status  uint8
+		text    string
+	}{
+		{"a/fail.nes", Fail, 3, "Timing\n\nFailed #3"},
+		{"a/pass.nes", Pass, 0, "Passed"},
+		{"bad.nes", Error, 0, ""},
+		{"busy.nes", Timeout, 0x80, "running"},
+		{"silent.nes", NoResult, 0, ""},
+	}
+	if len(results) != len(want) || int(progress.Load()) != len(want) {
+		t.Fatalf("%d results, %d progress calls; want %d", len(results), progress.Load(), len(want))
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.ROM != w.rom || r.Outcome != w.outcome || r.Status != w.status || r.Text != w.text {
+			t.Errorf("result %d = %+v, want %s %s $%02X %q", i, r, w.rom, w.outcome, w.status, w.text)
+		}
+	}
+	if results[2].Err == "" {
+		t.Error("a ROM that doesn't load should say why")
+	}
+	if results[3].Frames != 20 {
+		t.Errorf("busy ROM ran %d frames, want the limit of 20", results[3].Frames)
+	}
+
+	if _, err := Run(filepath.Join(dir, "a", "pass.nes"), Options{}); err != nil {
+		t.Errorf("a single file is a suite of one: %v", err)
+	}
+	if _, err := Run(t.TempDir(), Options{}); err == nil {
+		t.Error("an empty directory should be an error")
+	}
+}
+
+// TestRunNESReset checks a ROM that asks for reset with $81 gets exactly
+// one: this one reports $81 and on the reset that follows finds $6000
+// still $81 and passes.
+func TestRunNESReset(t *testing.T) {
+	dir := t.TempDir()
+	p := testrom.Code(0x8000).
+		Op("LDA", testrom.Abs(0x6000)).
+		Op("CMP", testrom.Imm(0x81)).
+		Op("BEQ", testrom.To("again"))
+	for i, b := range []byte{0xDE, 0xB0, 0x61, 0x00} {
+		p.Op("LDA", testrom.Imm(b)).Op("STA", testrom.Abs(uint16(0x6001+i)))
+	}
+	p.Op("LDA", testrom.Imm(0x81)).Op("STA", testrom.Abs(0x6000)).
+		Label("wait").Op("JMP", testrom.To("wait")).
+		Label("again").
+		Op("LDA", testrom.Imm(0)).Op("STA", testrom.Abs(0x6000)).
+		Label("halt").Op("JMP", testrom.To("halt"))
+	writeROM(t, dir, "reset.nes", p)
+
+	r := RunFile(filepath.Join(dir, "reset.nes"), Limits{Frames: 60})
+	if r.Outcome != Pass {
+		t.Errorf("result %+v, want a pass after one reset", r)
+	}
+}
+
+func TestReport(t *testing.T) {
+	results := []Result{
+		{ROM: "a.nes", Outcome: Pass},
+		{ROM: "b.nes", Outcome: Fail, Status: 2, Text: "Sprite 0\nFailed #2"},
+		{ROM: "c.nes", Outcome: Timeout, Status: 0x80},
+	}
+	var text bytes.Buffer
+	if err := WriteText(&text, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(text.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "ROM") || !strings.HasSuffix(lines[2], "Failed #2") {
+		t.Errorf("table:\n%s", text.String())
+	}
+	if got := lines[4]; got != "1/3 passed, 1 fail, 1 timeout" {
+		t.Errorf("summary = %q", got)
+	}
+
+	var js bytes.Buffer
+	if err := WriteJSON(&js, results); err != nil {
+		t.Fatal(err)
+	}
+	base, err := ReadJSON(&js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Total != 3 || base.Counts[Pass] != 1 || len(base.Results) != 3 || base.Results[1].Text != results[1].Text {
+		t.Errorf("JSON round trip = %+v", base)
+	}
+
+	now := []Result{
+		{ROM: "a.nes", Outcome: Fail},
+		{ROM: "b.nes", Outcome: Pass},
+		{ROM: "c.nes", Outcome: Timeout},
+		{ROM: "d.nes", Outcome: Pass},
+	}
+	changes := Compare(base, now)
+	if len(changes) != 3 {
+		t.Fatalf("changes = %v", changes)
+	}
+	if !changes[0].Regressed() || changes[1].Regressed() || changes[2].Regressed() {
+		t.Errorf("only a.nes regressed: %v", changes)
+	}
+	if got := changes[2].String(); got != "d.nes: new -> pass" {
+		t.Errorf("new ROM = %q", got)
+	}
+}
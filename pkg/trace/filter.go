@@ -0,0 +1,115 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yoshiomiyamaegones/pkg/cpu"
+)
+
+// BankSize is the PRG ROM bank size Filter.Banks counts in — 16KB, the
+// unit FCEUX and the symbol files number banks by.
+const BankSize = 0x4000
+
+// Range is an inclusive span of CPU addresses.
+type Range struct {
+	Start, End uint16
+}
+
+// Filter chooses the instructions a Tracer keeps. Each list accepts an
+// instruction matching any of its entries, and an empty list accepts all,
+// so the zero Filter traces everything.
+type Filter struct {
+	PC      []Range         // the instruction's address
+	Banks   []int           // the PRG ROM bank it runs from (only code at $8000 and up, in mappers that report banks)
+	Classes cpu.OpcodeClass // its opcode's classes
+}
+
+// Match reports whether the instruction at pc, running from PRG ROM offset
+// prg (-1 when unknown) with the given opcode, passes the filter.
+func (f *Filter) Match(pc uint16, prg int, opcode uint8) bool {
+	if f.Classes != 0 && cpu.Classify(opcode)&f.Classes == 0 {
+		return false
+	}
+	if len(f.PC) > 0 {
+		ok := false
+		for _, r := range f.PC {
+			if pc >= r.Start && pc <= r.End {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.Banks) > 0 {
+		if prg < 0 {
+			return false
+		}
+		for _, b := range f.Banks {
+			if prg/BankSize == b {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ParseFilter parses a filter written as comma-separated terms, each
+// repeatable:
+//
+//	pc=8000-8FFF   an address range (hex), or pc=C123 for one address
+//	bank=3         a 16KB PRG ROM bank (hex, as in FCEUX's .nl names)
+//	class=branch   an opcode class: load, store, alu, transfer, stack,
+//	               branch, jump, nop or illegal
+//
+// The empty string is the zero Filter.
+func ParseFilter(s string) (Filter, error) {
+	var f Filter
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(term, "=")
+		if !ok {
+			return Filter{}, fmt.Errorf("trace filter term %q: want key=value", term)
+		}
+		switch strings.ToLower(key) {
+		case "pc":
+			lo, hi, isRange := strings.Cut(value, "-")
+			if !isRange {
+				hi = lo
+			}
+			start, err1 := parseHex16(lo)
+			end, err2 := parseHex16(hi)
+			if err1 != nil || err2 != nil || start > end {
+				return Filter{}, fmt.Errorf("trace filter: bad PC range %q", value)
+			}
+			f.PC = append(f.PC, Range{start, end})
+		case "bank":
+			b, err := strconv.ParseUint(strings.TrimPrefix(value, "$"), 16, 16)
+			if err != nil {
+				return Filter{}, fmt.Errorf("trace filter: bad bank %q", value)
+			}
+			f.Banks = append(f.Banks, int(b))
+		case "class":
+			c, ok := cpu.ClassNames[strings.ToLower(value)]
+			if !ok {
+				return Filter{}, fmt.Errorf("trace filter: unknown opcode class %q", value)
+			}
+			f.Classes |= c
+		default:
+			return Filter{}, fmt.Errorf("trace filter: unknown key %q (want pc, bank or class)", key)
+		}
+	}
+	return f, nil
+}
+
+func parseHex16(s string) (uint16, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(s), "$"), 16, 16)
+	return uint16(v), err
+}
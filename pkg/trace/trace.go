@@ -0,0 +1,183 @@
+// Package trace records the instructions the CPU executes, for chasing
+// down what led up to a crash or a glitch. Instead of logging every
+// instruction — gigabytes an hour — a Tracer keeps the most recent ones in
+// a fixed-size ring and writes them out only when asked: from a hotkey or
+// debugger command, when the CPU jams, or when the emulator panics. A
+// Filter narrows what is kept to a PC range, a PRG ROM bank or kinds of
+// opcode, so the ring reaches further back into the code that matters.
+//
+// With CPU logging on (-cpu-log) the kept instructions also go to the log
+// as they run.
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yoshiomiyamaegones/pkg/cpu"
+	"github.com/yoshiomiyamaegones/pkg/logger"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+)
+
+// Entry is one instruction as the CPU was about to execute it.
+type Entry struct {
+	Cycle          uint64 // nes.NES.Cycles
+	PRG            int32  // PRG ROM offset of PC, -1 when unknown
+	PC             uint16
+	Scanline       int16
+	Bytes          [3]uint8 // opcode and operands; only InstructionLength are meaningful
+	A, X, Y, P, SP uint8
+}
+
+// Tracer is nes.NES.Trace for one NES. Like the rest of the machine it
+// isn't safe for concurrent use: dump it from the goroutine stepping
+// frames, or with that goroutine held.
+type Tracer struct {
+	nes *nes.NES
+
+	// Filter chooses the instructions kept; Symbols, if set, labels them
+	// when written out.
+	Filter  Filter
+	Symbols *symbols.Table
+
+	ring  []Entry
+	next  int    // where the next entry goes
+	total uint64 // entries recorded since the last Reset
+}
+
+// New returns a tracer keeping the last size instructions of n that pass
+// filter (size 0 keeps none and only logs), and installs it as n.Trace.
+func New(n *nes.NES, size int, filter Filter) *Tracer {
+	t := &Tracer{nes: n, Filter: filter, ring: make([]Entry, size)}
+	n.Trace = t.Record
+	return t
+}
+
+// Record is nes.NES.Trace: it captures the instruction at the CPU's PC if
+// the filter passes it.
+func (t *Tracer) Record() {
+	n := t.nes
+	pc := n.CPU.PC
+	op := n.Memory.Peek(pc)
+	prg := n.PRGOffset(pc)
+	if !t.Filter.Match(pc, prg, op) {
+		return
+	}
+	e := Entry{
+		Cycle:    n.Cycles,
+		PRG:      int32(prg),
+		PC:       pc,
+		Scanline: int16(n.PPU.Scanline),
+		Bytes:    [3]uint8{op, n.Memory.Peek(pc + 1), n.Memory.Peek(pc + 2)},
+		A:        n.CPU.A,
+		X:        n.CPU.X,
+		Y:        n.CPU.Y,
+		P:        n.CPU.P,
+		SP:       n.CPU.SP,
+	}
+	if logger.CPUEnabled() {
+		logger.LogCPU("%s", Format(e, t.Symbols))
+	}
+	if len(t.ring) == 0 {
+		return
+	}
+	t.ring[t.next] = e
+	t.next++
+	if t.next == len(t.ring) {
+		t.next = 0
+	}
+	t.total++
+}
+
+// Len is how many entries the ring holds.
+func (t *Tracer) Len() int {
+	if t.total < uint64(len(t.ring)) {
+		return int(t.total)
+	}
+	return len(t.ring)
+}
+
+// Total is how many instructions have been recorded since the last Reset,
+// including those the ring has since overwritten.
+func (t *Tracer) Total() uint64 { return t.total }
+
+// Entries returns the ring's entries, oldest first.
+func (t *Tracer) Entries() []Entry {
+	n := t.Len()
+	out := make([]Entry, 0, n)
+	if n == len(t.ring) {
+		out = append(out, t.ring[t.next:]...)
+		return append(out, t.ring[:t.next]...)
+	}
+	return append(out, t.ring[:n]...)
+}
+
+// Reset empties the ring.
+func (t *Tracer) Reset() {
+	t.next, t.total = 0, 0
+}
+
+// WriteTo writes the ring to w, oldest instruction first, one Format line
+// each after a comment saying how much of the trace it covers.
+func (t *Tracer) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	fmt.Fprintf(bw, "; last %d of %d traced instructions, oldest first\n", t.Len(), t.total)
+	for _, e := range t.Entries() {
+		bw.WriteString(Format(e, t.Symbols))
+		bw.WriteByte('\n')
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// WriteFile writes the ring to path, as WriteTo.
+func (t *Tracer) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := t.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// countingWriter counts the bytes WriteTo passes on.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Format renders e in the usual trace-log layout:
+//
+//	C123  BD 00 03  LDA $0300,X   A:00 X:05 Y:00 P:24 SP:FD CYC:1234 SL:241  Reset
+//
+// with the PC's symbol, if syms names it, at the end.
+func Format(e Entry, syms *symbols.Table) string {
+	var hex string
+	switch cpu.InstructionLength(e.Bytes[0]) {
+	case 1:
+		hex = fmt.Sprintf("%02X      ", e.Bytes[0])
+	case 2:
+		hex = fmt.Sprintf("%02X %02X   ", e.Bytes[0], e.Bytes[1])
+	default:
+		hex = fmt.Sprintf("%02X %02X %02X", e.Bytes[0], e.Bytes[1], e.Bytes[2])
+	}
+	line := fmt.Sprintf("%04X  %s  %-13s A:%02X X:%02X Y:%02X P:%02X SP:%02X CYC:%d SL:%d",
+		e.PC, hex, cpu.Disassemble(e.PC, e.Bytes), e.A, e.X, e.Y, e.P, e.SP, e.Cycle, e.Scanline)
+	if s, ok := syms.Lookup(e.PC, int(e.PRG)); ok {
+		line += "  " + s.Name
+	}
+	return line
+}
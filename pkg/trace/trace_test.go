@@ -0,0 +1,138 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/cpu"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/symbols"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
+)
+
+// testNES runs a one-bank NROM program at $8000: INX, then BNE back to it.
+func testNES(t *testing.T) *nes.NES {
+	t.Helper()
+	n, err := testrom.New().Program(testrom.Code(0x8000).
+		Label("loop").
+		Op("INX").
+		Op("BNE", testrom.To("loop"))).
+		NES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestTracerRing(t *testing.T) {
+	n := testNES(t)
+	tr := New(n, 4, Filter{})
+	for i := 0; i < 10; i++ {
+		tr.Record()
+		n.Step()
+	}
+	if tr.Len() != 4 || tr.Total() != 10 {
+		t.Fatalf("Len %d Total %d, want 4 and 10", tr.Len(), tr.Total())
+	}
+	entries := tr.Entries()
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Cycle <= entries[i-1].Cycle {
+			t.Fatalf("entries out of order: %+v", entries)
+		}
+	}
+	// Instructions alternate INX, BNE; the tenth was the BNE.
+	last := entries[3]
+	if last.PC != 0x8001 || last.Bytes[0] != 0xD0 || last.PRG != 1 {
+		t.Errorf("last entry %+v, want BNE at $8001, PRG offset 1", last)
+	}
+
+	tr.Reset()
+	if tr.Len() != 0 || len(tr.Entries()) != 0 {
+		t.Errorf("Reset left %d entries", tr.Len())
+	}
+}
+
+func TestTracerFilter(t *testing.T) {
+	n := testNES(t)
+	tr := New(n, 100, Filter{Classes: cpu.ClassBranch})
+	for i := 0; i < 10; i++ {
+		n.Trace()
+		n.Step()
+	}
+	if tr.Len() != 5 {
+		t.Fatalf("kept %d branches, want 5", tr.Len())
+	}
+	for _, e := range tr.Entries() {
+		if e.PC != 0x8001 {
+			t.Errorf("kept $%04X, not the branch", e.PC)
+		}
+	}
+}
+
+func TestTracerWriteTo(t *testing.T) {
+	n := testNES(t)
+	tr := New(n, 2, Filter{})
+	tr.Symbols = symbols.NewTable([]symbols.Symbol{{Name: "Loop", Address: 0x8000, PRG: -1}})
+	for i := 0; i < 3; i++ {
+		tr.Record()
+		n.Step()
+	}
+	var buf bytes.Buffer
+	size, err := tr.WriteTo(&buf)
+	if err != nil || size != int64(buf.Len()) {
+		t.Fatalf("WriteTo = %d, %v; wrote %d bytes", size, err, buf.Len())
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != "; last 2 of 3 traced instructions, oldest first" {
+		t.Fatalf("trace:\n%s", buf.String())
+	}
+	if !strings.HasPrefix(lines[1], "8001  D0 FD     BNE $8000") {
+		t.Errorf("line %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "8000  E8        INX") || !strings.HasSuffix(lines[2], "  Loop") {
+		t.Errorf("line %q", lines[2])
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter("pc=8000-8FFF, pc=$C123, bank=3, class=branch, class=jump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.PC) != 2 || f.PC[0] != (Range{0x8000, 0x8FFF}) || f.PC[1] != (Range{0xC123, 0xC123}) {
+		t.Errorf("PC = %+v", f.PC)
+	}
+	if len(f.Banks) != 1 || f.Banks[0] != 3 || f.Classes != cpu.ClassBranch|cpu.ClassJump {
+		t.Errorf("Banks = %v, Classes = %b", f.Banks, f.Classes)
+	}
+
+	const jsr, lda = 0x20, 0xA9
+	for _, tc := range []struct {
+		pc     uint16
+		prg    int
+		opcode uint8
+		want   bool
+	}{
+		{0x8100, 3 * BankSize, jsr, true},
+		{0xC123, 3*BankSize + 0x123, jsr, true},
+		{0x8100, 3 * BankSize, lda, false}, // wrong class
+		{0x9000, 3 * BankSize, jsr, false}, // outside the PC ranges
+		{0x8100, 2 * BankSize, jsr, false}, // other bank
+		{0x8100, -1, jsr, false},           // bank unknown
+	} {
+		if got := f.Match(tc.pc, tc.prg, tc.opcode); got != tc.want {
+			t.Errorf("Match($%04X, %d, %02X) = %v, want %v", tc.pc, tc.prg, tc.opcode, got, tc.want)
+		}
+	}
+	var zero Filter
+	if !zero.Match(0x0300, -1, lda) {
+		t.Error("the zero Filter should match everything")
+	}
+
+	for _, bad := range []string{"pc", "pc=9000-8000", "pc=xyz", "bank=q", "class=fancy", "op=1"} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded", bad)
+		}
+	}
+}
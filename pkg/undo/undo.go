@@ -0,0 +1,144 @@
+// Package undo lets a debugger step the CPU backwards. A Journal keeps,
+// for each of the last N instructions, the registers before it ran and
+// the old value of every byte it stored to CPU RAM or to $6000-$7FFF — a
+// few bytes an instruction rather than a save state each — so stepping
+// back to where a value got corrupted is just replaying those in reverse.
+//
+// Only the CPU's side is undone. The PPU, APU and mapper registers stay
+// where they are (writes to $2000-$5FFF and $8000-$FFFF aren't
+// journaled), so after stepping back the picture and sound carry on from
+// the present; the registers and memory a game's logic works on are as
+// they were.
+package undo
+
+import (
+	"sort"
+
+	"github.com/yoshiomiyamaegones/pkg/memory"
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// writesPerStep sizes the write ring: an instruction stores at most two
+// bytes (read-modify-write) and an interrupt entry three, and most store
+// none, so this rarely lets writes push steps out early.
+const writesPerStep = 4
+
+// step is the machine before one instruction.
+type step struct {
+	write          uint64 // writes recorded before this instruction ran
+	PC             uint16
+	A, X, Y, P, SP uint8
+}
+
+// write is one journaled store: the byte at Addr was Old.
+type write struct {
+	Addr uint16
+	Old  uint8
+}
+
+// Journal records one NES. Like the machine it isn't safe for concurrent
+// use.
+type Journal struct {
+	nes   *nes.NES
+	hooks [2]memory.HookID
+
+	steps  []step
+	writes []write
+	// nsteps and nwrites count what was recorded since the last Reset;
+	// the rings hold the ones from firstStep and firstWrite on.
+	nsteps, nwrites       uint64
+	firstStep, firstWrite uint64
+	// restoring makes the hooks ignore Back's own writes.
+	restoring bool
+}
+
+// New starts a journal of n keeping the last size instructions. Call
+// Record before each instruction runs; Stop removes the bus hooks.
+func New(n *nes.NES, size int) *Journal {
+	j := &Journal{
+		nes:    n,
+		steps:  make([]step, size),
+		writes: make([]write, size*writesPerStep),
+	}
+	j.hooks = [2]memory.HookID{
+		n.Memory.AddWriteHook(0x0000, 0x1FFF, j.recordWrite),
+		n.Memory.AddWriteHook(0x6000, 0x7FFF, j.recordWrite),
+	}
+	return j
+}
+
+// Stop uninstalls the journal's bus hooks.
+func (j *Journal) Stop() {
+	for _, id := range j.hooks {
+		j.nes.Memory.RemoveHook(id)
+	}
+}
+
+func (j *Journal) recordWrite(addr uint16, value uint8) uint8 {
+	if !j.restoring && len(j.writes) > 0 {
+		j.writes[j.nwrites%uint64(len(j.writes))] = write{addr, j.nes.Memory.Peek(addr)}
+		j.nwrites++
+		if j.nwrites-j.firstWrite > uint64(len(j.writes)) {
+			j.firstWrite = j.nwrites - uint64(len(j.writes))
+		}
+	}
+	return value
+}
+
+// Record captures the registers before the instruction about to run.
+func (j *Journal) Record() {
+	if len(j.steps) == 0 {
+		return
+	}
+	c := j.nes.CPU
+	j.steps[j.nsteps%uint64(len(j.steps))] = step{
+		write: j.nwrites,
+		PC:    c.PC,
+		A:     c.A, X: c.X, Y: c.Y, P: c.P, SP: c.SP,
+	}
+	j.nsteps++
+	if j.nsteps-j.firstStep > uint64(len(j.steps)) {
+		j.firstStep = j.nsteps - uint64(len(j.steps))
+	}
+}
+
+// Len is how many instructions Back can undo.
+func (j *Journal) Len() int {
+	// Steps whose writes the write ring has lost can't be undone; steps
+	// are in write order, so those are the oldest.
+	n := int(j.nsteps - j.firstStep)
+	lost := sort.Search(n, func(i int) bool {
+		return j.steps[(j.firstStep+uint64(i))%uint64(len(j.steps))].write >= j.firstWrite
+	})
+	return n - lost
+}
+
+// Back undoes the most recent instruction: its stores are reverted and
+// the registers put back. It reports false, changing nothing, when the
+// journal goes no further back.
+func (j *Journal) Back() bool {
+	if j.Len() == 0 {
+		return false
+	}
+	j.nsteps--
+	s := j.steps[j.nsteps%uint64(len(j.steps))]
+	j.restoring = true
+	for j.nwrites > s.write {
+		j.nwrites--
+		w := j.writes[j.nwrites%uint64(len(j.writes))]
+		if w.Addr < 0x2000 {
+			j.nes.Memory.RAM[w.Addr&0x7FF] = w.Old
+		} else {
+			j.nes.Memory.Write(w.Addr, w.Old)
+		}
+	}
+	j.restoring = false
+	c := j.nes.CPU
+	c.PC, c.A, c.X, c.Y, c.P, c.SP = s.PC, s.A, s.X, s.Y, s.P, s.SP
+	return true
+}
+
+// Reset forgets the history, as after loading a state or a ROM.
+func (j *Journal) Reset() {
+	j.nsteps, j.nwrites, j.firstStep, j.firstWrite = 0, 0, 0, 0
+}
@@ -0,0 +1,122 @@
+package undo
+
+import (
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
+)
+
+// testNES runs a one-bank NROM program at $8000 with PRG RAM:
+//
+//	loop: INX
+//	      STX $10
+//	      INC $0300
+//	      STX $6000
+//	      JMP loop
+func testNES(t *testing.T) *nes.NES {
+	t.Helper()
+	n, err := testrom.New().Battery().Program(testrom.Code(0x8000).
+		Label("loop").
+		Op("INX").
+		Op("STX", testrom.Zp(0x10)).
+		Op("INC", testrom.Abs(0x0300)).
+		Op("STX", testrom.Abs(0x6000)).
+		Op("JMP", testrom.To("loop"))).
+		NES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// run steps n count instructions, recording each into j.
+func run(j *Journal, n *nes.NES, count int) {
+	for i := 0; i < count; i++ {
+		j.Record()
+		n.Step()
+	}
+}
+
+func TestJournalBack(t *testing.T) {
+	n := testNES(t)
+	j := New(n, 100)
+	defer j.Stop()
+	run(j, n, 10) // two loops
+	if n.CPU.X != 2 || n.Memory.Peek(0x10) != 2 || n.Memory.Peek(0x0300) != 2 || n.Memory.Peek(0x6000) != 2 {
+		t.Fatalf("after two loops X=%d $10=%d $0300=%d $6000=%d", n.CPU.X, n.Memory.Peek(0x10), n.Memory.Peek(0x0300), n.Memory.Peek(0x6000))
+	}
+	if j.Len() != 10 {
+		t.Fatalf("Len = %d, want 10", j.Len())
+	}
+
+	// Back over JMP, STX $6000 and INC $0300 of the second loop.
+	for i := 0; i < 3; i++ {
+		if !j.Back() {
+			t.Fatalf("Back %d failed", i)
+		}
+	}
+	if n.CPU.PC != 0x8003 || n.Memory.Peek(0x6000) != 1 || n.Memory.Peek(0x0300) != 1 || n.Memory.Peek(0x10) != 2 {
+		t.Errorf("PC=$%04X $6000=%d $0300=%d $10=%d, want $8003 1 1 2",
+			n.CPU.PC, n.Memory.Peek(0x6000), n.Memory.Peek(0x0300), n.Memory.Peek(0x10))
+	}
+
+	// Running forward again journals afresh from here.
+	run(j, n, 2)
+	if n.CPU.PC != 0x8009 || n.Memory.Peek(0x0300) != 2 || j.Len() != 9 {
+		t.Errorf("forward again: PC=$%04X $0300=%d Len=%d", n.CPU.PC, n.Memory.Peek(0x0300), j.Len())
+	}
+
+	for j.Back() {
+	}
+	if n.CPU.PC != 0x8000 || n.CPU.X != 0 || n.Memory.Peek(0x10) != 0 || n.Memory.Peek(0x0300) != 0 {
+		t.Errorf("at the start: PC=$%04X X=%d $10=%d $0300=%d", n.CPU.PC, n.CPU.X, n.Memory.Peek(0x10), n.Memory.Peek(0x0300))
+	}
+}
+
+func TestJournalBounded(t *testing.T) {
+	n := testNES(t)
+	j := New(n, 8)
+	defer j.Stop()
+	run(j, n, 100)
+	if j.Len() != 8 {
+		t.Fatalf("Len = %d, want the 8 kept", j.Len())
+	}
+	back := 0
+	for j.Back() {
+		back++
+	}
+	if back != 8 {
+		t.Errorf("stepped back %d, want 8", back)
+	}
+
+	j.Reset()
+	if j.Len() != 0 || j.Back() {
+		t.Error("Reset should forget the history")
+	}
+}
+
+func TestJournalLostWrites(t *testing.T) {
+	n := testNES(t)
+	j := New(n, 4)
+	defer j.Stop()
+	// Every step stores two bytes and the write ring holds four, so only
+	// the last two steps can be undone though the step ring keeps more.
+	j.writes = j.writes[:4]
+	for i := 0; i < 3; i++ {
+		j.Record()
+		n.Memory.Write(0x0200, uint8(i))
+		n.Memory.Write(0x0201, uint8(i))
+	}
+	if j.Len() != 2 {
+		t.Fatalf("Len = %d, want the 2 steps whose writes are kept", j.Len())
+	}
+	j.Back()
+	j.Back()
+	if j.Back() {
+		t.Error("Back went past the lost writes")
+	}
+	if got := n.Memory.Peek(0x0200); got != 0 {
+		t.Errorf("$0200 = %d, want 0 from before the second step", got)
+	}
+}
@@ -0,0 +1,49 @@
+package vfilter
+
+// CRT levels, out of 256: how much of the other two channels each column
+// of the aperture grille lets through, and how bright the gap row between
+// scanlines is.
+const (
+	crtMask     = 176
+	crtScanline = 112
+)
+
+// CRT draws each pixel as a 3×3 cell of a TV screen: three columns behind
+// red, green and blue stripes of an aperture grille, and a dimmer third
+// row for the gap between scanlines.
+type CRT struct{}
+
+// Scale implements VideoFilter.
+func (CRT) Scale() int { return 3 }
+
+// Apply implements VideoFilter.
+func (CRT) Apply(dst, src []uint32, w, h int) {
+	stride := 3 * w
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := src[y*w+x]
+			for col := 0; col < 3; col++ {
+				lit := crtColumn(p, col)
+				i := 3*y*stride + 3*x + col
+				dst[i] = lit
+				dst[i+stride] = lit
+				dst[i+2*stride] = scaleRGB(lit, crtScanline)
+			}
+		}
+	}
+}
+
+// crtColumn is p seen through grille stripe col: 0 red, 1 green, 2 blue.
+func crtColumn(p uint32, col int) uint32 {
+	shift := 16 - 8*col
+	keep := p >> shift & 0xFF << shift
+	return scaleRGB(p&^(0xFF<<shift), crtMask) | keep | 0xFF000000
+}
+
+// scaleRGB multiplies p's colour channels by level/256, opaque.
+func scaleRGB(p uint32, level uint32) uint32 {
+	r := (p >> 16 & 0xFF) * level >> 8
+	g := (p >> 8 & 0xFF) * level >> 8
+	b := (p & 0xFF) * level >> 8
+	return 0xFF000000 | r<<16 | g<<8 | b
+}
@@ -0,0 +1,41 @@
+package vfilter
+
+// Scale2x is the Scale2x (EPX) filter: each pixel becomes four, and a
+// quarter takes the colour of the two neighbours beside it when they match
+// each other but not the pixels across, so staircases become diagonals.
+type Scale2x struct{}
+
+// Scale implements VideoFilter.
+func (Scale2x) Scale() int { return 2 }
+
+// Apply implements VideoFilter.
+func (Scale2x) Apply(dst, src []uint32, w, h int) {
+	for y := 0; y < h; y++ {
+		row := dst[2*y*2*w:]
+		next := row[2*w:]
+		for x := 0; x < w; x++ {
+			e := src[y*w+x]
+			b := clamped(src, w, h, x, y-1)
+			d := clamped(src, w, h, x-1, y)
+			f := clamped(src, w, h, x+1, y)
+			hh := clamped(src, w, h, x, y+1)
+			e0, e1, e2, e3 := e, e, e, e
+			if b != hh && d != f {
+				if d == b {
+					e0 = d
+				}
+				if b == f {
+					e1 = f
+				}
+				if d == hh {
+					e2 = d
+				}
+				if hh == f {
+					e3 = f
+				}
+			}
+			row[2*x], row[2*x+1] = e0, e1
+			next[2*x], next[2*x+1] = e2, e3
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package vfilter scales the picture in software before it goes to the
+// screen, for smoother or more TV-like output than the renderer's
+// nearest-neighbour stretch:
+//
+//   - scale2x  Scale2x (EPX): hard edges between flat colours rounded off
+//   - xbr      xBR, level 1: diagonal edges found by weighing the
+//     neighbourhood's colour distances, the corners blended along them
+//   - crt      a CRT look: an RGB aperture grille and dark scanlines
+//
+// Filters are plain Go over ARGB8888 pixels, so they need no GPU and cost
+// nothing where they aren't used: the headless runner never touches them.
+package vfilter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VideoFilter scales a picture by a whole factor.
+type VideoFilter interface {
+	// Scale is how many output pixels each input pixel becomes, across
+	// and down.
+	Scale() int
+	// Apply writes src, a w×h ARGB8888 picture, scaled into dst, which
+	// holds (w·Scale)×(h·Scale) pixels.
+	Apply(dst, src []uint32, w, h int)
+}
+
+// Names lists the filters New accepts, "nearest" (no filter) first.
+func Names() []string {
+	return []string{"nearest", "scale2x", "xbr", "crt"}
+}
+
+// New returns the filter called name (see Names), case-insensitively.
+// "nearest" and "" mean no filter, which is nil: the renderer's own
+// stretch already is nearest-neighbour.
+func New(name string) (VideoFilter, error) {
+	switch strings.ToLower(name) {
+	case "", "nearest":
+		return nil, nil
+	case "scale2x":
+		return Scale2x{}, nil
+	case "xbr":
+		return &XBR{}, nil
+	case "crt":
+		return CRT{}, nil
+	}
+	return nil, fmt.Errorf("unknown video filter %q (want %s)", name, strings.Join(Names(), ", "))
+}
+
+// clamped returns the pixel at (x, y), the nearest edge pixel for points
+// outside the picture.
+func clamped(src []uint32, w, h, x, y int) uint32 {
+	return src[min(max(y, 0), h-1)*w+min(max(x, 0), w-1)]
+}
+
+// blend50 is the average of a and b, channel by channel, opaque.
+func blend50(a, b uint32) uint32 {
+	return (a&0xFEFEFE)>>1 + (b&0xFEFEFE)>>1 + (a & b & 0x010101) | 0xFF000000
+}
@@ -0,0 +1,122 @@
+package vfilter
+
+import "testing"
+
+const (
+	black = 0xFF000000
+	white = 0xFFFFFFFF
+)
+
+// staircase is a w×h picture, white below the diagonal x < y and black
+// above it.
+func staircase(w, h int) []uint32 {
+	src := make([]uint32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			src[y*w+x] = black
+			if x < y {
+				src[y*w+x] = white
+			}
+		}
+	}
+	return src
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range Names() {
+		f, err := New(name)
+		if err != nil {
+			t.Errorf("New(%q): %v", name, err)
+		}
+		if (f == nil) != (name == "nearest") {
+			t.Errorf("New(%q) = %v", name, f)
+		}
+	}
+	if f, err := New(""); f != nil || err != nil {
+		t.Errorf(`New("") = %v, %v; want no filter`, f, err)
+	}
+	if f, err := New("XBR"); err != nil || f == nil {
+		t.Errorf(`New("XBR") = %v, %v; names are case-insensitive`, f, err)
+	}
+	if _, err := New("hq9x"); err == nil {
+		t.Error("unknown filter accepted")
+	}
+}
+
+// TestFlat checks every filter leaves a single colour alone (the CRT only
+// darkening it) and fills the whole output.
+func TestFlat(t *testing.T) {
+	const w, h = 5, 4
+	src := make([]uint32, w*h)
+	for i := range src {
+		src[i] = 0xFF336699
+	}
+	for _, name := range Names()[1:] {
+		f, _ := New(name)
+		s := f.Scale()
+		dst := make([]uint32, w*s*h*s)
+		f.Apply(dst, src, w, h)
+		for i, p := range dst {
+			if p == 0 {
+				t.Errorf("%s: output pixel %d not written", name, i)
+				break
+			}
+			if name != "crt" && p != src[0] {
+				t.Errorf("%s: output pixel %d = %08X, want %08X", name, i, p, src[0])
+				break
+			}
+		}
+	}
+}
+
+func TestScale2x(t *testing.T) {
+	const w, h = 4, 4
+	dst := make([]uint32, 4*w*h)
+	Scale2x{}.Apply(dst, staircase(w, h), w, h)
+	// (1,1) is black with white to the left and below: its bottom-left
+	// quarter turns white, the rest stay.
+	at := func(x, y int) uint32 { return dst[y*2*w+x] }
+	if at(2, 3) != white {
+		t.Errorf("bottom-left quarter of (1,1) = %08X, want white", at(2, 3))
+	}
+	if at(2, 2) != black || at(3, 2) != black || at(3, 3) != black {
+		t.Error("the other quarters of (1,1) should stay black")
+	}
+}
+
+func TestXBR(t *testing.T) {
+	const w, h = 8, 8
+	dst := make([]uint32, 4*w*h)
+	(&XBR{}).Apply(dst, staircase(w, h), w, h)
+	at := func(x, y int) uint32 { return dst[y*2*w+x] }
+	// Along the staircase the corner quarter facing the edge is blended
+	// half way; the quarters away from it keep their colour.
+	grey := blend50(black, white)
+	if got := at(2*3, 2*3+1); got != grey {
+		t.Errorf("bottom-left quarter of (3,3) = %08X, want %08X", got, grey)
+	}
+	if got := at(2*3+1, 2*3); got != black {
+		t.Errorf("top-right quarter of (3,3) = %08X, want black", got)
+	}
+	if got := at(2*2+1, 2*3); got != grey {
+		t.Errorf("top-right quarter of (2,3) = %08X, want %08X", got, grey)
+	}
+	if got := at(2*2, 2*3+1); got != white {
+		t.Errorf("bottom-left quarter of (2,3) = %08X, want white", got)
+	}
+}
+
+func TestCRT(t *testing.T) {
+	dst := make([]uint32, 9)
+	CRT{}.Apply(dst, []uint32{white}, 1, 1)
+	m := uint32(0xFF * crtMask >> 8)
+	want := [3]uint32{0xFFFF0000 | m<<8 | m, 0xFF00FF00 | m<<16 | m, 0xFF0000FF | m<<16 | m<<8}
+	for col, c := range want {
+		if dst[col] != c || dst[3+col] != c {
+			t.Errorf("column %d = %08X/%08X, want %08X", col, dst[col], dst[3+col], c)
+		}
+		if gap := dst[6+col]; gap != scaleRGB(c, crtScanline) {
+			t.Errorf("column %d scanline gap = %08X, want it dimmed", col, gap)
+		}
+	}
+}
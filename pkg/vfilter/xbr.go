@@ -0,0 +1,99 @@
+package vfilter
+
+// XBR is the xBR filter (Hyllian's "scale by rules"), level 1, at 2×.
+// Each output quarter looks at the 21 pixels around its source pixel E
+// from its own corner:
+//
+//	   A1 B1 C1
+//	A0 A  B  C  C4
+//	D0 D  E  F  F4
+//	G0 G  H  I  I4
+//	   G5 H5 I5
+//
+// (drawn for the bottom-right quarter; the others mirror it). Summing the
+// colour distances between pixels in the H–F direction and in the E–I one
+// tells which way an edge runs; when it runs along H–F, cutting the
+// corner, the quarter is blended half way to whichever of F and H is
+// nearer E.
+type XBR struct {
+	yuv []yuv // src converted once per frame
+}
+
+// yuv is a pixel's luma and chroma, scaled to whole numbers.
+type yuv struct{ y, u, v int32 }
+
+// toYUV converts an ARGB8888 pixel.
+func toYUV(p uint32) yuv {
+	r, g, b := int32(p>>16&0xFF), int32(p>>8&0xFF), int32(p&0xFF)
+	return yuv{
+		y: (299*r + 587*g + 114*b) / 1000,
+		u: (-169*r - 331*g + 500*b) / 1000,
+		v: (500*r - 419*g - 81*b) / 1000,
+	}
+}
+
+// dist is xBR's colour distance: luma weighs most.
+func (a yuv) dist(b yuv) int32 {
+	return 48*abs(a.y-b.y) + 7*abs(a.u-b.u) + 6*abs(a.v-b.v)
+}
+
+func abs(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Scale implements VideoFilter.
+func (*XBR) Scale() int { return 2 }
+
+// Apply implements VideoFilter.
+func (f *XBR) Apply(dst, src []uint32, w, h int) {
+	if len(f.yuv) != w*h {
+		f.yuv = make([]yuv, w*h)
+	}
+	for i, p := range src[:w*h] {
+		f.yuv[i] = toYUV(p)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for q := 0; q < 4; q++ {
+				qx, qy := q&1, q>>1
+				dst[(2*y+qy)*2*w+2*x+qx] = f.corner(src, w, h, x, y, 2*qx-1, 2*qy-1)
+			}
+		}
+	}
+}
+
+// corner is the output quarter of the pixel at (x, y) towards (sx, sy),
+// each ±1, with the neighbourhood mirrored so that corner is bottom-right.
+func (f *XBR) corner(src []uint32, w, h, x, y, sx, sy int) uint32 {
+	at := func(i, j int) (uint32, yuv) {
+		px, py := min(max(x+i*sx, 0), w-1), min(max(y+j*sy, 0), h-1)
+		return src[py*w+px], f.yuv[py*w+px]
+	}
+	pe, e := at(0, 0)
+	pf, ff := at(1, 0)
+	ph, hh := at(0, 1)
+	if pe == pf || pe == ph {
+		return pe
+	}
+	_, b := at(0, -1)
+	_, c := at(1, -1)
+	_, d := at(-1, 0)
+	_, g := at(-1, 1)
+	_, i := at(1, 1)
+	_, f4 := at(2, 0)
+	_, i4 := at(2, 1)
+	_, h5 := at(0, 2)
+	_, i5 := at(1, 2)
+	alongHF := e.dist(c) + e.dist(g) + i.dist(f4) + i.dist(h5) + 4*hh.dist(ff)
+	alongEI := hh.dist(d) + hh.dist(i5) + ff.dist(i4) + ff.dist(b) + 4*e.dist(i)
+	if alongHF >= alongEI {
+		return pe
+	}
+	if e.dist(ff) <= e.dist(hh) {
+		return blend50(pe, pf)
+	}
+	return blend50(pe, ph)
+}
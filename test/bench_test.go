@@ -2,11 +2,13 @@ package test
 
 import (
 	"bytes"
+	"math/rand"
 	"os"
 	"testing"
 
 	"github.com/yoshiomiyamaegones/pkg/cartridge"
 	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
 )
 
 // benchROMs are representative ROMs that exercise the rendering hot paths.
@@ -39,21 +41,141 @@ func newBenchNES(b *testing.B, path string) *nes.NES {
 	return system
 }
 
+// benchProgram is a minimal game loop for a synthetic NROM cartridge: it
+// waits out the PPU warm-up, turns on NMI and rendering, then spins
+// writing RAM while the NMI handler does an OAM DMA from $0300 and sets
+// the scroll — a frame's worth of CPU, PPU, DMA and APU work with no ROM
+// file needed.
+var benchProgram = testrom.Code(0x8000).
+	Op("SEI").
+	Op("CLD").
+	Op("LDX", testrom.Imm(0xFF)).
+	Op("TXS").
+	Label("vblank1").
+	Op("BIT", testrom.Abs(0x2002)).
+	Op("BPL", testrom.To("vblank1")).
+	Label("vblank2").
+	Op("BIT", testrom.Abs(0x2002)).
+	Op("BPL", testrom.To("vblank2")).
+	Op("LDA", testrom.Imm(0x80)).
+	Op("STA", testrom.Abs(0x2000)). // NMI on
+	Op("LDA", testrom.Imm(0x1E)).
+	Op("STA", testrom.Abs(0x2001)). // BG + sprites
+	Label("loop").
+	Op("INC", testrom.Zp(0x00)).
+	Op("LDA", testrom.Zp(0x00)).
+	Op("STA", testrom.AbsX(0x0300)).
+	Op("INX").
+	Op("JMP", testrom.To("loop")).
+	Label("nmi").
+	Op("PHA").
+	Op("LDA", testrom.Imm(0x03)).
+	Op("STA", testrom.Abs(0x4014)).
+	Op("LDA", testrom.Zp(0x00)).
+	Op("STA", testrom.Abs(0x2005)).
+	Op("STA", testrom.Abs(0x2005)).
+	Op("PLA").
+	Op("RTI")
+
+// newSyntheticNES builds a powered-on NES running benchProgram, with CHR
+// ROM filled from a fixed seed so every run renders the same frames.
+func newSyntheticNES(tb testing.TB) *nes.NES {
+	tb.Helper()
+	chr := make([]byte, 0x2000)
+	rand.New(rand.NewSource(1)).Read(chr)
+
+	system, err := testrom.New().
+		Program(benchProgram).
+		Vectors(benchProgram.Addr("nmi"), 0x8000, 0x8000).
+		CHRAt(0, chr).
+		NES()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	system.SetAudioSink(discardAudio{})
+	for i := 0; i < 10; i++ {
+		system.StepFrame()
+	}
+	return system
+}
+
+// discardAudio drains each frame's samples so APU.Output doesn't grow.
+type discardAudio struct{}
+
+func (discardAudio) ReceiveSamples([]float32) {}
+
+// benchFrames is the body of a full-system benchmark: b.N frames, reported
+// as frames/s alongside ns/op.
+func benchFrames(b *testing.B, system *nes.NES) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		system.StepFrame()
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "frames/s")
+}
+
 // BenchmarkStepFrame measures the cost of emulating one full frame
 // (CPU + PPU + APU + mapper) with no SDL/audio/sleep overhead.
 func BenchmarkStepFrame(b *testing.B) {
+	b.Run("synthetic", func(b *testing.B) {
+		benchFrames(b, newSyntheticNES(b))
+	})
 	for _, rom := range benchROMs {
 		b.Run(rom.name, func(b *testing.B) {
 			system := newBenchNES(b, rom.path)
+			system.SetAudioSink(discardAudio{})
 			// Warm up past the boot/title-init so we measure steady-state
 			// rendering rather than the initial black frames.
 			for i := 0; i < 120; i++ {
 				system.StepFrame()
 			}
-			b.ResetTimer()
-			for i := 0; i < b.N; i++ {
-				system.StepFrame()
-			}
+			benchFrames(b, system)
 		})
 	}
 }
+
+// BenchmarkNestest runs nestest in its automated mode (PC forced to
+// $C000), the CPU-heavy end of the full-system numbers.
+func BenchmarkNestest(b *testing.B) {
+	cart, err := loadROMFromFile("nestest.nes")
+	if err != nil {
+		b.Skipf("ROM not available: %v", err)
+	}
+	system := nes.NewNES()
+	system.LoadCartridge(cart)
+	system.PowerOn()
+	system.CPU.PC = 0xC000
+	system.SetAudioSink(discardAudio{})
+	benchFrames(b, system)
+}
+
+// TestStepFrameZeroAllocs holds the frame loop to no heap allocations once
+// running: a per-frame allocation would show up as GC pauses in the GUI.
+func TestStepFrameZeroAllocs(t *testing.T) {
+	system := newSyntheticNES(t)
+	if system.PPU.PPUMASK&0x18 == 0 {
+		t.Fatal("benchmark program didn't turn rendering on")
+	}
+	if allocs := testing.AllocsPerRun(20, system.StepFrame); allocs != 0 {
+		t.Errorf("StepFrame: %.1f allocations per frame, want 0", allocs)
+	}
+}
+
+// TestHaltedStepFrameZeroAllocs does the same for a CPU frozen by a JAM
+// with TrapOnHalt off, the GUI's default: the PPU and APU run on and
+// every Step reports the halt, which must not cost an allocation.
+func TestHaltedStepFrameZeroAllocs(t *testing.T) {
+	system, err := testrom.New().FillPRG(func(int) byte { return 0x02 }).NES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	system.SetAudioSink(discardAudio{})
+	system.StepFrame()
+	if !system.CPU.Halted() {
+		t.Fatal("CPU didn't jam")
+	}
+	if allocs := testing.AllocsPerRun(20, system.StepFrame); allocs != 0 {
+		t.Errorf("halted StepFrame: %.1f allocations per frame, want 0", allocs)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
 )
 
 // TestCartridgeLoader tests the cartridge loading functionality
@@ -76,34 +77,17 @@ func TestInvalidROM(t *testing.T) {
 	}
 }
 
-// createMinimalROM creates a minimal valid iNES ROM for testing
+// createMinimalROM creates a minimal valid iNES ROM for testing: NROM with
+// test values at the start of PRG and CHR. The header is left raw so tests
+// can patch its flags.
 func createMinimalROM() []byte {
-	rom := make([]byte, 0)
-
-	// iNES header (16 bytes)
-	header := []byte{
-		0x4E, 0x45, 0x53, 0x1A, // "NES\x1A"
-		0x01,                                           // 1 x 16KB PRG ROM
-		0x01,                                           // 1 x 8KB CHR ROM
-		0x00,                                           // Flags 6: Horizontal mirroring, Mapper 0
-		0x00,                                           // Flags 7: Mapper 0
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Padding
+	rom, err := testrom.New().
+		PRGAt(0, []byte{0x42}).
+		CHRAt(0, []byte{0x55}).
+		Build()
+	if err != nil {
+		panic(err)
 	}
-	rom = append(rom, header...)
-
-	// PRG ROM (16KB)
-	prgROM := make([]byte, 16384)
-	prgROM[0] = 0x42 // Test value
-	// Fill reset vector (at end of 16KB block)
-	prgROM[0x3FFC] = 0x00 // Reset vector low
-	prgROM[0x3FFD] = 0x80 // Reset vector high
-	rom = append(rom, prgROM...)
-
-	// CHR ROM (8KB)
-	chrROM := make([]byte, 8192)
-	chrROM[0] = 0x55 // Test value
-	rom = append(rom, chrROM...)
-
 	return rom
 }
 
@@ -0,0 +1,38 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/nes"
+)
+
+// TestDeterminismAcrossPowerCycleAndState plays the synthetic game across
+// the two places emulator state most often leaks: a power cycle, which must
+// forget everything, and a save-state round trip, which must forget
+// nothing.
+func TestDeterminismAcrossPowerCycleAndState(t *testing.T) {
+	movie := make(nes.Movie, 120)
+
+	a, b := newSyntheticNES(t), newSyntheticNES(t)
+	for i := 0; i < 50; i++ {
+		b.StepFrame()
+	}
+	a.PowerOn()
+	b.PowerOn()
+	if err := nes.CheckDeterminism(a, b, movie); err != nil {
+		t.Errorf("power cycle: %v", err)
+	}
+
+	var state bytes.Buffer
+	if err := a.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	c := newSyntheticNES(t)
+	if err := c.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if err := nes.CheckDeterminism(a, c, movie); err != nil {
+		t.Errorf("save-state round trip: %v", err)
+	}
+}
@@ -1,8 +1,12 @@
 package test
 
 import (
+	"bytes"
 	"os"
 	"testing"
+
+	"github.com/yoshiomiyamaegones/pkg/cartridge"
+	"github.com/yoshiomiyamaegones/pkg/nes"
 )
 
 // TestDMCDMADuringRead4_4016 runs blargg/Bisqwit's dma_4016_read ROM
@@ -39,3 +43,61 @@ func TestDMCDMADuringRead4_4016(t *testing.T) {
 	}
 	t.Skip("known limitation: DMC DMA + $4016 controller-read glitch requires cycle-accurate DMA stall (whole-instruction stepping can't model it)")
 }
+
+// TestDMCFetchFollowsPRGBanks plays a sample through a UxROM cartridge
+// whose 16KB banks are each filled with their own number. The sample starts
+// at $FFC0 ($4012=$FF), so its first 64 bytes come from the fixed bank at
+// $C000 and the 65th from $8000 after the address wraps — from whichever
+// bank is mapped there when the fetch happens, not when the sample started.
+func TestDMCFetchFollowsPRGBanks(t *testing.T) {
+	var rom bytes.Buffer
+	rom.WriteString("NES\x1A\x04\x01\x20") // 4 PRG banks, mapper 2
+	rom.Write(make([]byte, 9))
+	for bank := 0; bank < 4; bank++ {
+		rom.Write(bytes.Repeat([]byte{0x10 + byte(bank)}, 0x4000))
+	}
+	rom.Write(make([]byte, 0x2000))
+	cart, err := cartridge.LoadFromReader(&rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys := nes.NewNES()
+	sys.LoadCartridge(cart)
+	sys.PowerOn()
+
+	sys.Memory.Write(0x8000, 1)
+	sys.Memory.Write(0x4010, 0x0F) // fastest rate, no loop
+	sys.Memory.Write(0x4012, 0xFF) // $FFC0
+	sys.Memory.Write(0x4013, 0x04) // 65 bytes
+	sys.Memory.Write(0x4015, 0x10)
+
+	// A fetch decrements CurrentLength. The byte is in SampleBuffer, or
+	// already in the shift register if that emptied on the same cycle.
+	dmc := &sys.APU.DMC
+	var got []byte
+	for i := 0; i < 100000 && len(got) < 65; i++ {
+		left := dmc.CurrentLength
+		sys.APU.Step()
+		if dmc.CurrentLength != left {
+			b := dmc.SampleBuffer
+			if dmc.BufferEmpty {
+				b = dmc.Buffer
+			}
+			got = append(got, b)
+			if len(got) == 10 {
+				sys.Memory.Write(0x8000, 2) // switch banks mid-sample
+			}
+		}
+	}
+	if len(got) != 65 {
+		t.Fatalf("fetched %d sample bytes, want 65", len(got))
+	}
+	for i, b := range got[:64] {
+		if b != 0x13 {
+			t.Fatalf("byte %d ($%04X) = $%02X, want $13 from the fixed bank", i, 0xFFC0+i, b)
+		}
+	}
+	if got[64] != 0x12 {
+		t.Errorf("byte after the wrap to $8000 = $%02X, want $12 from the bank switched in mid-sample", got[64])
+	}
+}
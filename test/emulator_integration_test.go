@@ -1,75 +1,74 @@
 package test
 
 import (
-	"bytes"
 	"testing"
 
+	"github.com/yoshiomiyamaegones/pkg/asm6502"
 	"github.com/yoshiomiyamaegones/pkg/cartridge"
 	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
 )
 
 // TestEmulatorWithTestProgram tests the emulator with a custom test program
 func TestEmulatorWithTestProgram(t *testing.T) {
 	// Create a test program that exercises various CPU features
-	testProgram := []uint8{
-		// Test basic arithmetic and flags
-		0xA9, 0x10, // LDA #$10
-		0x69, 0x20, // ADC #$20  ; A = $30, no carry
-		0x69, 0xE0, // ADC #$E0  ; A = $10, carry set
-		0x85, 0x10, // STA $10   ; Store result
-
-		// Test branching
-		0x90, 0x02, // BCC +2    ; Should not branch (carry set)
-		0xA9, 0xFF, // LDA #$FF  ; Error marker
-		0x18,       // CLC       ; Clear carry
-		0x90, 0x02, // BCC +2    ; Should branch (carry clear)
-		0xA9, 0xFF, // LDA #$FF  ; Error marker (skipped)
-
-		// Test stack operations
-		0x48,       // PHA       ; Push A to stack
-		0xA9, 0x55, // LDA #$55  ; Change A
-		0x68,       // PLA       ; Pull from stack
-		0x85, 0x11, // STA $11   ; Store pulled value
-
-		// Test memory operations
-		0xA5, 0x10, // LDA $10   ; Load from zero page
-		0x85, 0x12, // STA $12   ; Store to different location
-
-		// Test increment/decrement
-		0xE6, 0x12, // INC $12   ; Increment memory
-		0xE8, // INX       ; Increment X
-		0xC8, // INY       ; Increment Y
-
-		// Test comparison
-		0xA5, 0x12, // LDA $12   ; Load incremented value
-		0xC9, 0x11, // CMP #$11  ; Compare with expected value
-		0xF0, 0x02, // BEQ +2    ; Branch if equal
-		0xA9, 0xFF, // LDA #$FF  ; Error marker
-
-		// Test logical operations
-		0xA9, 0xF0, // LDA #$F0
-		0x29, 0x0F, // AND #$0F  ; A = $00
-		0x09, 0x42, // ORA #$42  ; A = $42
-		0x49, 0xFF, // EOR #$FF  ; A = $BD
-		0x85, 0x13, // STA $13   ; Store result
-
-		// Test shift operations
-		0xA9, 0x81, // LDA #$81
-		0x4A,       // LSR A     ; A = $40, carry = 1
-		0x2A,       // ROL A     ; A = $81 (with carry)
-		0x85, 0x14, // STA $14   ; Store result
-
-		// Halt with NOP loop
-		0xEA,             // NOP
-		0x4C, 0x4B, 0x80, // JMP $804B (infinite loop at NOP)
-	}
-
-	rom := createTestROM(testProgram)
-	reader := bytes.NewReader(rom)
-	cart, err := cartridge.LoadFromReader(reader)
-	if err != nil {
-		t.Fatalf("Failed to load test ROM: %v", err)
-	}
+	cart, labels := createTestROM(t, `
+	; Test basic arithmetic and flags
+	lda #$10
+	adc #$20        ; A = $30, no carry
+	adc #$E0        ; A = $10, carry set
+	sta $10         ; Store result
+
+	; Test branching
+	bcc bad1        ; Should not branch (carry set)
+	lda #$FF        ; Error marker
+bad1:
+	clc             ; Clear carry
+	bcc good1       ; Should branch (carry clear)
+	lda #$FF        ; Error marker (skipped)
+good1:
+
+	; Test stack operations
+	pha             ; Push A to stack
+	lda #$55        ; Change A
+	pla             ; Pull from stack
+	sta $11         ; Store pulled value
+
+	; Test memory operations
+	lda $10         ; Load from zero page
+	sta $12         ; Store to different location
+
+	; Test increment/decrement
+	inc $12         ; Increment memory
+	inx             ; Increment X
+	iny             ; Increment Y
+
+	; Test comparison
+	lda $12         ; Load incremented value
+	cmp #$11        ; Compare with expected value
+	beq good2       ; Branch if equal
+	lda #$FF        ; Error marker
+good2:
+
+	; Test logical operations
+	lda #$F0
+	and #$0F        ; A = $00
+	ora #$42        ; A = $42
+	eor #$FF        ; A = $BD
+	sta $13         ; Store result
+
+	; Test shift operations
+	lda #$81
+	lsr a           ; A = $40, carry = 1
+	rol a           ; A = $81 (with carry)
+	sta $14         ; Store result
+
+	; Halt with NOP loop
+halt:
+	nop
+	jmp halt
+`)
+	halt := labels["halt"]
 
 	// Create and setup NES system
 	system := nes.NewNES()
@@ -82,7 +81,7 @@ func TestEmulatorWithTestProgram(t *testing.T) {
 		system.Step()
 
 		// Check if we've reached the infinite loop (halt condition)
-		if system.CPU.PC == 0x804B {
+		if system.CPU.PC == halt {
 			break
 		}
 
@@ -126,7 +125,7 @@ func TestEmulatorWithTestProgram(t *testing.T) {
 		system.CPU.A, system.CPU.X, system.CPU.Y)
 
 	// Check that we reached the halt condition
-	if system.CPU.PC != 0x804B {
+	if system.CPU.PC != halt {
 		t.Errorf("Program did not reach halt condition, PC = %04X", system.CPU.PC)
 	}
 }
@@ -134,89 +133,84 @@ func TestEmulatorWithTestProgram(t *testing.T) {
 // TestCPUInstructionCoverage tests that all implemented CPU instructions work
 func TestCPUInstructionCoverage(t *testing.T) {
 	// Create a comprehensive test program
-	testProgram := []uint8{
-		// Load/Store operations
-		0xA9, 0x42, // LDA #$42
-		0xA2, 0x10, // LDX #$10
-		0xA0, 0x20, // LDY #$20
-		0x85, 0x00, // STA $00
-		0x86, 0x01, // STX $01
-		0x84, 0x02, // STY $02
-
-		// Transfer operations
-		0xAA, // TAX
-		0x8A, // TXA
-		0xA8, // TAY
-		0x98, // TYA
-		0x9A, // TXS
-		0xBA, // TSX
-
-		// Arithmetic operations
-		0x69, 0x08, // ADC #$08
-		0xE9, 0x08, // SBC #$08
-
-		// Compare operations
-		0xC9, 0x42, // CMP #$42
-		0xE0, 0x42, // CPX #$42
-		0xC0, 0x20, // CPY #$20
-
-		// Logical operations
-		0x29, 0xFF, // AND #$FF
-		0x09, 0x00, // ORA #$00
-		0x49, 0x00, // EOR #$00
-
-		// Shift operations
-		0x0A, // ASL A
-		0x4A, // LSR A
-		0x2A, // ROL A
-		0x6A, // ROR A
-
-		// Increment/Decrement
-		0xE8,       // INX
-		0xCA,       // DEX
-		0xC8,       // INY
-		0x88,       // DEY
-		0xE6, 0x00, // INC $00
-		0xC6, 0x00, // DEC $00
-
-		// Flag operations
-		0x18, // CLC
-		0x38, // SEC
-		0x58, // CLI
-		0x78, // SEI
-		0xB8, // CLV
-		0xD8, // CLD
-		0xF8, // SED
-
-		// Stack operations
-		0x48, // PHA
-		0x68, // PLA
-		0x08, // PHP
-		0x28, // PLP
-
-		// Branch operations (not taken)
-		0x10, 0x01, // BPL +1
-		0x30, 0x01, // BMI +1
-		0x50, 0x01, // BVC +1
-		0x70, 0x01, // BVS +1
-		0x90, 0x01, // BCC +1
-		0xB0, 0x01, // BCS +1
-		0xD0, 0x01, // BNE +1
-		0xF0, 0x01, // BEQ +1
-
-		// Bit test
-		0x24, 0x00, // BIT $00
-
-		// Jump to end - calculate correct address
-		0x4C, 0x4A, 0x80, // JMP $804A (infinite loop at this location)
-	}
-
-	rom := createTestROM(testProgram)
-	reader := bytes.NewReader(rom)
-	cart, err := cartridge.LoadFromReader(reader)
-	if err != nil {
-		t.Fatalf("Failed to load test ROM: %v", err)
-	}
+	cart, labels := createTestROM(t, `
+	; Load/Store operations
+	lda #$42
+	ldx #$10
+	ldy #$20
+	sta $00
+	stx $01
+	sty $02
+
+	; Transfer operations
+	tax
+	txa
+	tay
+	tya
+	txs
+	tsx
+
+	; Arithmetic operations
+	adc #$08
+	sbc #$08
+
+	; Compare operations
+	cmp #$42
+	cpx #$42
+	cpy #$20
+
+	; Logical operations
+	and #$FF
+	ora #$00
+	eor #$00
+
+	; Shift operations
+	asl a
+	lsr a
+	rol a
+	ror a
+
+	; Increment/Decrement
+	inx
+	dex
+	iny
+	dey
+	inc $00
+	dec $00
+
+	; Flag operations
+	clc
+	sec
+	cli
+	sei
+	clv
+	cld
+	sed
+
+	; Stack operations
+	pha
+	pla
+	php
+	plp
+
+	; Branch operations (to the next instruction, taken or not)
+	bpl *+2
+	bmi *+2
+	bvc *+2
+	bvs *+2
+	bcc *+2
+	bcs *+2
+	bne *+2
+	beq *+2
+
+	; Bit test
+	bit $00
+
+	; Jump to end (infinite loop at this location)
+end:
+	jmp end
+`)
+	end := labels["end"]
 
 	system := nes.NewNES()
 	system.LoadCartridge(cart)
@@ -235,14 +229,14 @@ func TestCPUInstructionCoverage(t *testing.T) {
 		}
 
 		// Check if we've reached the end marker (infinite loop)
-		if system.CPU.PC == 0x804A {
+		if system.CPU.PC == end {
 			break
 		}
 	}
 
 	t.Logf("Executed %d instructions in %d cycles", instructionCount, system.Cycles)
 
-	if system.CPU.PC != 0x804A {
+	if system.CPU.PC != end {
 		t.Errorf("Program did not reach end marker, PC = %04X", system.CPU.PC)
 	}
 
@@ -252,58 +246,35 @@ func TestCPUInstructionCoverage(t *testing.T) {
 	}
 }
 
-// createTestROM creates a test ROM with the given program
-func createTestROM(program []uint8) []byte {
-	rom := make([]byte, 0)
-
-	// iNES header
-	header := []byte{
-		0x4E, 0x45, 0x53, 0x1A, // "NES\x1A"
-		0x01,                                           // 1 x 16KB PRG ROM
-		0x01,                                           // 1 x 8KB CHR ROM
-		0x00,                                           // Flags 6: Horizontal mirroring, Mapper 0
-		0x00,                                           // Flags 7: Mapper 0
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Padding
+// createTestROM assembles src at $8000, where every vector points, and
+// loads it as an NROM cartridge, returning the program's labels too.
+func createTestROM(t *testing.T, src string) (*cartridge.Cartridge, map[string]uint16) {
+	t.Helper()
+	p, err := asm6502.Assemble(src, 0x8000, nil)
+	if err != nil {
+		t.Fatalf("Failed to assemble test program: %v", err)
 	}
-	rom = append(rom, header...)
-
-	// PRG ROM (16KB)
-	prgROM := make([]byte, 16384)
-
-	// Copy program to start of ROM (0x8000)
-	copy(prgROM, program)
-
-	// Set interrupt vectors
-	prgROM[0x3FFA] = 0x00 // NMI vector low
-	prgROM[0x3FFB] = 0x80 // NMI vector high (0x8000)
-	prgROM[0x3FFC] = 0x00 // Reset vector low
-	prgROM[0x3FFD] = 0x80 // Reset vector high (0x8000)
-	prgROM[0x3FFE] = 0x00 // IRQ vector low
-	prgROM[0x3FFF] = 0x80 // IRQ vector high (0x8000)
-
-	rom = append(rom, prgROM...)
-
-	// CHR ROM (8KB) - empty for now
-	chrROM := make([]byte, 8192)
-	rom = append(rom, chrROM...)
-
-	return rom
+	cart, err := testrom.New().Asm(p).Cartridge()
+	if err != nil {
+		t.Fatalf("Failed to load test ROM: %v", err)
+	}
+	return cart, p.Labels
 }
 
 // TestEmulatorPerformance benchmarks basic emulator performance
 func TestEmulatorPerformance(t *testing.T) {
 	// Simple loop program
-	program := []uint8{
-		0xA9, 0x00, // LDA #$00
-		0x69, 0x01, // ADC #$01   ; loop: A = A + 1
-		0xC9, 0xFF, // CMP #$FF   ; compare with 255
-		0xD0, 0xFA, // BNE loop   ; branch back if not equal
-		0x4C, 0x08, 0x80, // JMP $8008  ; infinite loop when done
-	}
-
-	rom := createTestROM(program)
-	reader := bytes.NewReader(rom)
-	cart, err := cartridge.LoadFromReader(reader)
+	program := testrom.Code(0x8000).
+		Op("LDA", testrom.Imm(0x00)).
+		Label("loop").
+		Op("ADC", testrom.Imm(0x01)).  // A = A + 1
+		Op("CMP", testrom.Imm(0xFF)).  // compare with 255
+		Op("BNE", testrom.To("loop")). // branch back if not equal
+		Label("done").
+		Op("JMP", testrom.To("done")) // infinite loop when done
+	done := program.Addr("done")
+
+	cart, err := testrom.New().Program(program).Cartridge()
 	if err != nil {
 		t.Fatalf("Failed to load test ROM: %v", err)
 	}
@@ -318,7 +289,7 @@ func TestEmulatorPerformance(t *testing.T) {
 		system.Step()
 
 		// Check if we've reached the infinite loop (A = 255)
-		if system.CPU.PC == 0x8008 && system.CPU.A == 0xFF {
+		if system.CPU.PC == done && system.CPU.A == 0xFF {
 			break
 		}
 	}
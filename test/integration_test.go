@@ -1,6 +1,8 @@
 package test
 
 import (
+	"bytes"
+	"sync"
 	"testing"
 
 	"github.com/yoshiomiyamaegones/pkg/nes"
@@ -254,3 +256,34 @@ func TestInterruptHandling(t *testing.T) {
 		t.Error("Interrupt flag should be set after NMI")
 	}
 }
+
+// TestConcurrentInstances runs two systems on separate goroutines and checks
+// each renders exactly what a lone system does: no emulation state may live
+// in package variables. Most useful under -race.
+func TestConcurrentInstances(t *testing.T) {
+	const frames = 30
+	ref := newSyntheticNES(t)
+	for i := 0; i < frames; i++ {
+		ref.StepFrame()
+	}
+	want := ref.GetFramebuffer()
+
+	systems := []*nes.NES{newSyntheticNES(t), newSyntheticNES(t)}
+	var wg sync.WaitGroup
+	for _, system := range systems {
+		wg.Add(1)
+		go func(system *nes.NES) {
+			defer wg.Done()
+			for i := 0; i < frames; i++ {
+				system.StepFrame()
+			}
+		}(system)
+	}
+	wg.Wait()
+
+	for i, system := range systems {
+		if !bytes.Equal(system.GetFramebuffer(), want) {
+			t.Errorf("system %d: framebuffer differs from a lone run", i)
+		}
+	}
+}
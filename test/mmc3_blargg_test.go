@@ -9,6 +9,7 @@ import (
 
 	"github.com/yoshiomiyamaegones/pkg/cartridge"
 	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/testsuite"
 )
 
 // blarggMMC3TestDir is the directory containing Shay Green's mmc3_test ROMs.
@@ -21,11 +22,23 @@ const blarggMMC3TestDir = `R:\nes-test-roms-master\mmc3_test`
 // loop lives in runBlarggTest, but tests that read raw framebuffer state
 // (e.g. scanline_test) use loadNES directly.
 func loadNES(t *testing.T, romPath string) *nes.NES {
+	t.Helper()
+	return loadNESSubmapper(t, romPath, 0)
+}
+
+// loadNESSubmapper is loadNES for a ROM whose board variant the iNES 1.0
+// header can't express: a nonzero submapper rewrites the header as NES 2.0
+// with that submapper before loading.
+func loadNESSubmapper(t *testing.T, romPath string, submapper uint8) *nes.NES {
 	t.Helper()
 	data, err := os.ReadFile(romPath)
 	if err != nil {
 		t.Fatalf("read %s: %v", romPath, err)
 	}
+	if submapper != 0 && len(data) >= 16 {
+		data[7] = data[7]&^0x0C | 0x08
+		data[8] = submapper << 4
+	}
 	cart, err := cartridge.LoadFromReader(bytes.NewReader(data))
 	if err != nil {
 		t.Fatalf("load cartridge %s: %v", romPath, err)
@@ -37,69 +50,12 @@ func loadNES(t *testing.T, romPath string) *nes.NES {
 }
 
 // runBlarggTest runs a blargg-style test ROM (status at $6000, ASCII text at
-// $6004+). Returns the final status byte, the printed text, and the number of
-// frames executed.
-func runBlarggTest(t *testing.T, romPath string, maxFrames int) (status uint8, text string, frames int) {
+// $6004+) through testsuite.RunNES. Returns the final status byte, the
+// printed text, and the number of frames executed.
+func runBlarggTest(t *testing.T, romPath string, maxFrames int, submapper uint8) (status uint8, text string, frames int) {
 	t.Helper()
-	system := loadNES(t, romPath)
-
-	const (
-		statusAddr = 0x6000
-		sigAddr    = 0x6001
-		textAddr   = 0x6004
-		runFlag    = 0x80
-		resetFlag  = 0x81
-	)
-
-	signatureSeen := false
-	prevStatus := uint8(0)
-	for frames = 0; frames < maxFrames; frames++ {
-		system.StepFrame()
-
-		s0 := system.Memory.Read(sigAddr)
-		s1 := system.Memory.Read(sigAddr + 1)
-		s2 := system.Memory.Read(sigAddr + 2)
-		if s0 == 0xDE && s1 == 0xB0 && s2 == 0x61 {
-			signatureSeen = true
-		}
-
-		status = system.Memory.Read(statusAddr)
-		if !signatureSeen {
-			continue
-		}
-		// $81 means "press reset". Soft-reset only on the rising edge —
-		// the test ROM holds $81 across its post-reset init until it
-		// rewrites $80, so resetting every frame would loop forever.
-		isReset := status == resetFlag
-		if isReset && prevStatus != resetFlag {
-			system.SoftReset()
-		}
-		prevStatus = status
-		if isReset {
-			continue
-		}
-		if status < runFlag && status != 0x00 {
-			break
-		}
-		if status == 0x00 && frames > 60 {
-			break
-		}
-	}
-
-	var b strings.Builder
-	for i := 0; i < 0x2000; i++ {
-		c := system.Memory.Read(uint16(textAddr + i))
-		if c == 0 {
-			break
-		}
-		if c >= 0x20 && c < 0x7F {
-			b.WriteByte(c)
-		} else if c == '\n' {
-			b.WriteByte('\n')
-		}
-	}
-	text = strings.TrimSpace(b.String())
-	return status, text, frames
+	r := testsuite.RunNES(loadNESSubmapper(t, romPath, submapper), testsuite.Limits{Frames: maxFrames})
+	return r.Status, r.Text, r.Frames
 }
 
 // blarggCase describes one ROM in a blargg-style suite. When expectedToPass
@@ -110,6 +66,7 @@ type blarggCase struct {
 	maxFrames      int
 	expectedToPass bool
 	skipReason     string
+	submapper      uint8 // load as NES 2.0 with this submapper (0 = as is)
 }
 
 // runBlarggSuite runs a slice of blargg ROM cases under t.Run. Shared by
@@ -134,7 +91,7 @@ func runBlarggSuite(t *testing.T, baseDir string, cases []blarggCase) {
 			if _, err := os.Stat(romPath); err != nil {
 				t.Skipf("ROM missing: %v", err)
 			}
-			status, text, frames := runBlarggTest(t, romPath, c.maxFrames)
+			status, text, frames := runBlarggTest(t, romPath, c.maxFrames, c.submapper)
 			t.Logf("frames=%d status=$%02X output=%q", frames, status, text)
 			passed := status == 0x00 && strings.Contains(strings.ToLower(text), "passed")
 			if c.expectedToPass {
@@ -154,10 +111,10 @@ func runBlarggSuite(t *testing.T, baseDir string, cases []blarggCase) {
 }
 
 // TestMMC3BlarggSuite runs each of the 6 MMC3 test ROMs. 6-MMC6 tests the
-// MMC6 / NEC-MMC3 variant where reload-to-0 from natural 0 does NOT fire
-// IRQ — directly conflicts with Sharp MMC3 (which every other test and
-// the vast majority of commercial MMC3 ROMs depend on). We pick Sharp and
-// skip 6.
+// MMC6 / NEC-MMC3A alternate IRQ behaviour, where reload-to-0 from natural
+// 0 does NOT fire IRQ — the opposite of what 5-MMC3 (and most commercial
+// MMC3 games) expect of the Sharp chip. Its iNES 1.0 header can't say so,
+// so it runs as NES 2.0 submapper 1 (MMC6).
 func TestMMC3BlarggSuite(t *testing.T) {
 	runBlarggSuite(t, blarggMMC3TestDir, []blarggCase{
 		{name: "1-clocking.nes", maxFrames: 600, expectedToPass: true},
@@ -165,7 +122,6 @@ func TestMMC3BlarggSuite(t *testing.T) {
 		{name: "3-A12_clocking.nes", maxFrames: 600, expectedToPass: true},
 		{name: "4-scanline_timing.nes", maxFrames: 1800, expectedToPass: true},
 		{name: "5-MMC3.nes", maxFrames: 1800, expectedToPass: true},
-		{name: "6-MMC6.nes", maxFrames: 1200, expectedToPass: false,
-			skipReason: "tests MMC6/NEC-MMC3 variant; we implement Sharp MMC3 (needed by test 5)"},
+		{name: "6-MMC6.nes", maxFrames: 1200, expectedToPass: true, submapper: 1},
 	})
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/yoshiomiyamaegones/pkg/cartridge"
 	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
 )
 
 // ROMTestResult represents the result of a ROM test
@@ -274,44 +275,45 @@ func TestPPUSpriteHitROM(t *testing.T) {
 // TestMapper1Integration tests Mapper 1 functionality with a custom ROM
 func TestMapper1Integration(t *testing.T) {
 	// Create a test program that exercises Mapper 1 features
-	testProgram := []uint8{
+	program := testrom.Code(0x8000).
 		// Test basic MMC1 functionality
-		0xA9, 0x80, // LDA #$80 - Reset MMC1
-		0x8D, 0x00, 0x80, // STA $8000
-
+		Op("LDA", testrom.Imm(0x80)). // Reset MMC1
+		Op("STA", testrom.Abs(0x8000)).
 		// Set control register to 16KB PRG mode, 4KB CHR mode
-		0xA9, 0x0F, // LDA #$0F (all bits set)
-		0x8D, 0x00, 0x80, // STA $8000 (write bit 0)
-		0x4A,             // LSR A
-		0x8D, 0x00, 0x80, // STA $8000 (write bit 1)
-		0x4A,             // LSR A
-		0x8D, 0x00, 0x80, // STA $8000 (write bit 2)
-		0x4A,             // LSR A
-		0x8D, 0x00, 0x80, // STA $8000 (write bit 3)
-		0x4A,             // LSR A
-		0x8D, 0x00, 0x80, // STA $8000 (write bit 4)
-
+		Op("LDA", testrom.Imm(0x0F)).   // all bits set
+		Op("STA", testrom.Abs(0x8000)). // write bit 0
+		Op("LSR").
+		Op("STA", testrom.Abs(0x8000)). // write bit 1
+		Op("LSR").
+		Op("STA", testrom.Abs(0x8000)). // write bit 2
+		Op("LSR").
+		Op("STA", testrom.Abs(0x8000)). // write bit 3
+		Op("LSR").
+		Op("STA", testrom.Abs(0x8000)). // write bit 4
 		// Test PRG bank switching
-		0xA9, 0x01, // LDA #$01 (switch to bank 1)
-		0x8D, 0x00, 0xE0, // STA $E000 (bit 0)
-		0x4A,             // LSR A (now 0)
-		0x8D, 0x00, 0xE0, // STA $E000 (bit 1)
-		0x8D, 0x00, 0xE0, // STA $E000 (bit 2)
-		0x8D, 0x00, 0xE0, // STA $E000 (bit 3)
-		0x8D, 0x00, 0xE0, // STA $E000 (bit 4)
-
+		Op("LDA", testrom.Imm(0x01)).   // switch to bank 1
+		Op("STA", testrom.Abs(0xE000)). // bit 0
+		Op("LSR").                      // now 0
+		Op("STA", testrom.Abs(0xE000)). // bit 1
+		Op("STA", testrom.Abs(0xE000)). // bit 2
+		Op("STA", testrom.Abs(0xE000)). // bit 3
+		Op("STA", testrom.Abs(0xE000)). // bit 4
 		// Simple test to verify we're still executing
-		0xA9, 0x42, // LDA #$42
-		0x85, 0x00, // STA $00
-
+		Op("LDA", testrom.Imm(0x42)).
+		Op("STA", testrom.Zp(0x00)).
 		// Infinite loop
-		0x4C, 0x2A, 0x80, // JMP $802A (current location)
-	}
-
-	// Create ROM with Mapper 1
-	rom := createMapper1TestROM(testProgram)
-	reader := bytes.NewReader(rom)
-	cart, err := cartridge.LoadFromReader(reader)
+		Label("halt").
+		Op("JMP", testrom.To("halt"))
+	halt := program.Addr("halt")
+
+	// Create ROM with Mapper 1: 32KB PRG with the program in both banks,
+	// since bank 1 is switched in under it, and 16KB CHR filled with a
+	// test pattern
+	cart, err := testrom.New().Mapper(1).PRG(32).CHR(16).
+		Program(program).
+		ProgramAt(0x4000, program).
+		FillCHR(func(i int) byte { return byte(i) }).
+		Cartridge()
 	if err != nil {
 		t.Fatalf("Failed to load Mapper 1 test ROM: %v", err)
 	}
@@ -332,7 +334,7 @@ func TestMapper1Integration(t *testing.T) {
 		system.Step()
 
 		// Check if we've reached the infinite loop
-		if system.CPU.PC == 0x802A {
+		if system.CPU.PC == halt {
 			break
 		}
 
@@ -347,7 +349,7 @@ func TestMapper1Integration(t *testing.T) {
 	t.Logf("Test memory location $00: %02X", system.Memory.Read(0x00))
 
 	// Check that we reached the halt condition
-	if system.CPU.PC != 0x802A {
+	if system.CPU.PC != halt {
 		t.Errorf("Program did not reach halt condition, PC = %04X", system.CPU.PC)
 	}
 
@@ -357,60 +359,6 @@ func TestMapper1Integration(t *testing.T) {
 	}
 }
 
-// createMapper1TestROM creates a test ROM that uses Mapper 1
-func createMapper1TestROM(program []uint8) []byte {
-	rom := make([]byte, 0)
-
-	// iNES header for Mapper 1
-	header := []byte{
-		0x4E, 0x45, 0x53, 0x1A, // "NES\x1A"
-		0x02,                                           // 2 x 16KB PRG ROM (32KB total)
-		0x02,                                           // 2 x 8KB CHR ROM (16KB total)
-		0x10,                                           // Flags 6: Mapper 1, horizontal mirroring
-		0x00,                                           // Flags 7: Mapper 1
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Padding
-	}
-	rom = append(rom, header...)
-
-	// PRG ROM (32KB total, 2 banks of 16KB each)
-	prgROM := make([]byte, 32768)
-
-	// Copy program to start of first bank (0x8000)
-	copy(prgROM, program)
-
-	// Put the same program in the second bank for testing
-	copy(prgROM[16384:], program)
-
-	// Set interrupt vectors for both banks
-	// Bank 0 vectors
-	prgROM[0x3FFA] = 0x00 // NMI vector low
-	prgROM[0x3FFB] = 0x80 // NMI vector high (0x8000)
-	prgROM[0x3FFC] = 0x00 // Reset vector low
-	prgROM[0x3FFD] = 0x80 // Reset vector high (0x8000)
-	prgROM[0x3FFE] = 0x00 // IRQ vector low
-	prgROM[0x3FFF] = 0x80 // IRQ vector high (0x8000)
-
-	// Bank 1 vectors (same as bank 0)
-	prgROM[0x7FFA] = 0x00 // NMI vector low
-	prgROM[0x7FFB] = 0x80 // NMI vector high (0x8000)
-	prgROM[0x7FFC] = 0x00 // Reset vector low
-	prgROM[0x7FFD] = 0x80 // Reset vector high (0x8000)
-	prgROM[0x7FFE] = 0x00 // IRQ vector low
-	prgROM[0x7FFF] = 0x80 // IRQ vector high (0x8000)
-
-	rom = append(rom, prgROM...)
-
-	// CHR ROM (16KB total, 4 banks of 4KB each)
-	chrROM := make([]byte, 16384)
-	// Fill with test pattern
-	for i := 0; i < len(chrROM); i++ {
-		chrROM[i] = uint8(i % 256)
-	}
-	rom = append(rom, chrROM...)
-
-	return rom
-}
-
 // BenchmarkROMExecution benchmarks ROM execution performance
 func BenchmarkROMExecution(b *testing.B) {
 	romFile := "nestest.nes"
@@ -4,9 +4,8 @@ import (
 	"bytes"
 	"testing"
 
-	"github.com/yoshiomiyamaegones/pkg/cartridge"
-	"github.com/yoshiomiyamaegones/pkg/cartridge/mapper"
 	"github.com/yoshiomiyamaegones/pkg/nes"
+	"github.com/yoshiomiyamaegones/pkg/testrom"
 )
 
 // makeMMC3NES builds a minimal MMC3-cartridge NES with a 32KB PRG ROM that
@@ -14,21 +13,13 @@ import (
 // representative mapper.
 func makeMMC3NES(t *testing.T) *nes.NES {
 	t.Helper()
-	prgROM := make([]uint8, 32*1024)
-	chrRAM := make([]uint8, 32*1024)
-	// JMP $E000 (so the CPU loops forever at the reset vector location).
-	prgROM[0x6000] = 0x4C
-	prgROM[0x6001] = 0x00
-	prgROM[0x6002] = 0xE0
-	prgROM[0x7FFC] = 0x00
-	prgROM[0x7FFD] = 0xE0
-
-	cartData := &mapper.CartridgeData{PRGROM: prgROM, CHRRAM: chrRAM, PRGRAM: make([]uint8, 8*1024)}
-	cart := &cartridge.Cartridge{
-		PRGROM: prgROM,
-		CHRRAM: chrRAM,
-		PRGRAM: cartData.PRGRAM,
-		Mapper: mapper.NewMapper4(cartData),
+	cart, err := testrom.New().Mapper(4).PRG(32).CHRRAM(32).
+		// JMP $E000 (so the CPU loops forever at the reset vector location).
+		Program(testrom.Code(0xE000).Label("loop").Op("JMP", testrom.To("loop"))).
+		Reset(0xE000).
+		Cartridge()
+	if err != nil {
+		t.Fatal(err)
 	}
 
 	n := nes.NewNES()
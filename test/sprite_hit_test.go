@@ -2,27 +2,62 @@ package test
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 )
 
-// TestSpriteHitRightEdge runs blargg's sprite_hit_tests_2005.10.05
-// 06.right_edge.nes. It checks that sprite 0 hit is suppressed when
-// the visible pixel lands on column 255 (NESdev: "obscure
-// pixel-output-circuitry reason") but fires normally at column 254.
-// Result is rendered to the PPU nametable as "PASSED" / "FAILED #N".
-func TestSpriteHitRightEdge(t *testing.T) {
-	const romPath = `R:\nes-test-roms-master\sprite_hit_tests_2005.10.05\06.right_edge.nes`
-	if _, err := os.Stat(romPath); err != nil {
-		t.Skipf("ROM missing: %v", err)
-	}
-	sys := loadNES(t, romPath)
-	for i := 0; i < 300; i++ {
-		sys.StepFrame()
-	}
-	if nametableContains(sys, "FAILED") {
-		t.Fatalf("06.right_edge reported FAILED — see screenshot for the failing subtest")
+// spriteHitDir holds blargg's sprite_hit_tests_2005.10.05. These predate
+// the $6000 status protocol: each ROM prints "PASSED" / "FAILED #N" to the
+// nametable, so they can't go through runBlarggSuite.
+const spriteHitDir = `R:\nes-test-roms-master\sprite_hit_tests_2005.10.05`
+
+// TestSpriteHit runs the whole sprite_hit_tests_2005.10.05 set. Between
+// them the ROMs pin down every sprite 0 hit condition the PPU models:
+//
+//   - 01-03: basic opacity, alignment, and the corner pixels of the sprite
+//   - 04:    flip bits (the hit must follow the flipped pattern)
+//   - 05:    the PPUMASK left-column clip bits
+//   - 06:    suppressed at column 255 (NESdev: "obscure pixel-output-
+//     circuitry reason") but fires normally at column 254
+//   - 07:    the flag isn't raised on a line where the sprite is clipped
+//     off the bottom of the screen
+//   - 08:    8×16 sprites (the hit can come from the lower tile)
+//   - 09:    hit timing relative to the CPU read, to within a few dots
+//   - 10:    the flag is set on the first overlapping pixel in raster order
+//   - 11:    timing on the edge dots of a scanline
+func TestSpriteHit(t *testing.T) {
+	if _, err := os.Stat(spriteHitDir); err != nil {
+		t.Skipf("ROM directory not available: %v", err)
 	}
-	if !nametableContains(sys, "PASSED") {
-		t.Fatalf("06.right_edge didn't reach PASSED within 300 frames — likely hung")
+	for _, name := range []string{
+		"01.basics.nes",
+		"02.alignment.nes",
+		"03.corners.nes",
+		"04.flip.nes",
+		"05.left_clip.nes",
+		"06.right_edge.nes",
+		"07.screen_bottom.nes",
+		"08.double_height.nes",
+		"09.timing_basics.nes",
+		"10.timing_order.nes",
+		"11.edge_timing.nes",
+	} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			romPath := filepath.Join(spriteHitDir, name)
+			if _, err := os.Stat(romPath); err != nil {
+				t.Skipf("ROM missing: %v", err)
+			}
+			sys := loadNES(t, romPath)
+			for i := 0; i < 300; i++ {
+				sys.StepFrame()
+			}
+			if nametableContains(sys, "FAILED") {
+				t.Fatalf("%s reported FAILED — see screenshot for the failing subtest", name)
+			}
+			if !nametableContains(sys, "PASSED") {
+				t.Fatalf("%s didn't reach PASSED within 300 frames — likely hung", name)
+			}
+		})
 	}
 }